@@ -15,11 +15,13 @@ import (
 	"github.com/linkclaw/backend/internal/config"
 	"github.com/linkclaw/backend/internal/db"
 	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/event"
 	"github.com/linkclaw/backend/internal/i18n"
 	"github.com/linkclaw/backend/internal/llm"
 	"github.com/linkclaw/backend/internal/mcp"
 	"github.com/linkclaw/backend/internal/repository"
 	"github.com/linkclaw/backend/internal/service"
+	"github.com/linkclaw/backend/internal/storage"
 	webhooksub "github.com/linkclaw/backend/internal/webhook"
 	"github.com/linkclaw/backend/internal/ws"
 )
@@ -78,20 +80,50 @@ func main() {
 	personaRepo := repository.NewPersonaOptimizationRepo(pg)
 	webhookRepo := repository.NewWebhookRepo(pg)
 	partnerKeyRepo := repository.NewPartnerAPIKeyRepo(pg)
+	toolPolicyRepo := repository.NewToolPolicyRepo(pg)
+	mcpUpstreamRepo := repository.NewMcpUpstreamRepo(pg)
+	idempotencyRepo := repository.NewIdempotencyRepo(pg)
+	outboxRepo := repository.NewOutboxRepo(pg)
+	channelRepo := repository.NewChannelRepo(pg)
+	deliveryRepo := repository.NewAgentDeliveryRepo(pg)
 
 	// Services
 	agentSvc := service.NewAgentService(agentRepo, companyRepo, deployRepo, taskRepo)
-	taskSvc := service.NewTaskService(taskRepo, collabRepo, messageRepo, companyRepo)
-	messageSvc := service.NewMessageService(messageRepo, companyRepo)
+	outboxRelay := service.NewOutboxRelay(outboxRepo, event.Global)
+	go outboxRelay.Run(context.Background())
+	fileStore, err := storage.New(storageConfig(cfg.Storage))
+	if err != nil {
+		log.Fatalf("storage: %v", err)
+	}
+	taskSvc := service.NewTaskService(taskRepo, collabRepo, messageRepo, companyRepo, agentRepo, repository.NewTransactor(pg), outboxRelay, fileStore)
+	orgSvc := service.NewOrganizationService(deptRepo, agentRepo, approvalRepo)
+	messageSvc := service.NewMessageService(messageRepo, companyRepo, channelRepo, agentRepo, taskRepo, knowledgeRepo, orgSvc, fileStore,
+		service.NewConversationGovernor(conversationGovernorConfig(cfg.Message)), repository.NewTransactor(pg))
+	go messageSvc.PurgeLoop(context.Background())
+	channelSvc := service.NewChannelService(channelRepo, agentRepo, repository.NewTransactor(pg))
+	deliverySvc := service.NewAgentDeliveryService(deliveryRepo, agentRepo, messageSvc)
+	deliverySvc.Start(context.Background())
+	scheduleSvc := service.NewScheduledMessageService(repository.NewScheduledMessageRepo(pg), messageSvc)
+	go scheduleSvc.Run(context.Background())
+	presenceSvc := service.NewPresenceService(agentRepo, repository.NewAgentStatusHistoryRepo(pg), rdb, presenceConfig(cfg.Presence))
+	go presenceSvc.Run(context.Background())
+	agentSvc.OnDelete(presenceSvc.ForgetAgent)
 	knowledgeSvc := service.NewKnowledgeService(knowledgeRepo)
 	deploySvc := service.NewDeploymentService(deployRepo, agentRepo, companyRepo)
 	obsSvc := service.NewObservabilityService(obsRepo)
 	qualitySvc := service.NewQualityScoringService(obsRepo)
-	orgSvc := service.NewOrganizationService(deptRepo, agentRepo, approvalRepo)
-	personaSvc := service.NewPersonaOptimizerService(personaRepo, agentRepo, taskRepo)
+	templateSvc := service.NewTaskTemplateService(repository.NewTaskTemplateRepo(pg), taskSvc)
+	go templateSvc.Run(context.Background())
+	taskSLASvc := service.NewTaskSLAService(taskSvc, orgSvc, taskSLAConfig(cfg.Task))
+	go taskSLASvc.Run(context.Background())
+	personaSvc := service.NewPersonaOptimizerService(personaRepo, agentRepo, taskRepo, collabRepo)
 	webhookSvc := service.NewWebhookService(webhookRepo, 5*time.Second)
 	go webhookSvc.ProcessDeliveryQueue(context.Background())
 	partnerSvc := service.NewPartnerService(partnerKeyRepo, companyRepo)
+	toolPolicySvc := service.NewToolPolicyService(toolPolicyRepo, orgSvc, repository.NewTransactor(pg))
+	mcpFedSvc := service.NewMcpFederationService(mcpUpstreamRepo, obsSvc, auditRepo, cfg.LLM.EncryptKey)
+	idemSvc := service.NewIdempotencyService(idempotencyRepo, 24*time.Hour)
+	go idemSvc.PurgeLoop(context.Background())
 
 	webhookSubscriber := webhooksub.NewSubscriber(webhookSvc)
 	webhookSubscriber.Start()
@@ -102,7 +134,7 @@ func main() {
 	errorWatcher.Start()
 
 	// WebSocket Hub（实时推送）
-	wsHub := ws.NewHub()
+	wsHub := ws.NewHub(channelSvc)
 	go wsHub.Run()
 
 	// LLM Gateway
@@ -120,21 +152,30 @@ func main() {
 	contextAgent := service.NewContextSearchAgent(contextLLMCli, contextRepo)
 	contextScheduler := service.NewContextScheduler(contextRepo, contextLLMCli)
 	go contextScheduler.Start(context.Background())
+	digestSvc := service.NewDigestService(repository.NewDigestRepo(pg), messageSvc, approvalRepo, contextLLMCli, digestConfig(cfg.Digest))
+	agentSvc.OnDelete(digestSvc.DeleteForAgent)
+	if cfg.Digest.Enabled {
+		go digestSvc.Run(context.Background())
+	}
 
 	// Embedding + Memory
 	embeddingCli := service.NewEmbeddingClient(llmRouter)
 	memorySvc := service.NewMemoryService(memoryRepo, companyRepo, embeddingCli)
-	embeddingWorker := service.NewEmbeddingWorker(memoryRepo, companyRepo, embeddingCli)
+	embeddingWorker := service.NewEmbeddingWorker(memoryRepo, messageRepo, companyRepo, embeddingCli)
 	go embeddingWorker.Start(context.Background())
+	messageSearchSvc := service.NewMessageSearchService(messageRepo, companyRepo, messageSvc, embeddingCli)
 
 	// Prompt Service
 	promptLayerRepo := repository.NewPromptLayerRepo(pg)
 	promptSvc := service.NewPromptService(promptLayerRepo, companyRepo, agentRepo)
 
 	// MCP Server
+	toolCallLimiter := service.NewToolCallLimiter(toolCallLimitConfig(cfg.MCP))
 	mcpHandler := mcp.NewHandler(agentSvc, taskSvc, messageSvc, knowledgeSvc, memorySvc,
-		companyRepo, deploySvc, llmRepo, promptSvc, obsSvc, obsRepo, orgSvc, contextSvc)
-	mcpServer := mcp.NewServer(agentRepo, mcpHandler, rdb)
+		companyRepo, deploySvc, llmRepo, promptSvc, obsSvc, obsRepo, orgSvc, contextSvc, toolPolicySvc, mcpFedSvc,
+		toolCallLimiter, idemSvc, templateSvc, channelSvc, messageSearchSvc, deliverySvc, scheduleSvc, presenceSvc, digestSvc)
+	mcpHandler.StartToolCallExecutor()
+	mcpServer := mcp.NewServer(agentRepo, mcpHandler, rdb, presenceSvc)
 
 	// HTTP Server
 	r := gin.New()
@@ -154,7 +195,7 @@ func main() {
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
-		ws.Upgrade(c, wsHub, agent, agentRepo, messageSvc)
+		ws.Upgrade(c, wsHub, agent, presenceSvc, messageSvc)
 	})
 
 	// Agent WebSocket 端点（Agent 容器专用，使用 API Key 认证）
@@ -169,14 +210,14 @@ func main() {
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
-		ws.UpgradeAgent(c, agent, agentRepo, messageSvc)
+		ws.UpgradeAgent(c, agent, presenceSvc, messageSvc, deliverySvc)
 	})
 
 	api.RegisterRoutes(r, agentRepo, cfg.JWT.Secret, cfg.JWT.Expiry,
 		agentSvc, taskSvc, messageSvc, knowledgeSvc, memorySvc,
 		obsSvc, obsRepo, auditRepo, qualitySvc, mcpServer, llmHandler, &cfg.Agent, companyRepo,
 		deploySvc, cfg.ResetSecret, promptSvc, orgSvc, webhookSvc, personaSvc,
-		partnerSvc, partnerKeyRepo, contextSvc, contextAgent, contextScheduler)
+		partnerSvc, partnerKeyRepo, contextSvc, contextAgent, contextScheduler, toolPolicySvc, mcpFedSvc, idemSvc, templateSvc, channelSvc, messageSearchSvc, deliverySvc, scheduleSvc, presenceSvc, digestSvc)
 
 	log.Printf("LinkClaw server starting on :%s", cfg.Server.Port)
 	if err := r.Run(":" + cfg.Server.Port); err != nil {
@@ -184,6 +225,95 @@ func main() {
 	}
 }
 
+// toolCallLimitConfig 由环境配置构造 MCP 工具调用限流配置
+func toolCallLimitConfig(c config.MCPConfig) *service.ToolCallLimitConfig {
+	toolRates, err := service.ParseToolLimits(c.ToolRateLimits)
+	if err != nil {
+		log.Fatalf("MCP_TOOL_RATE_LIMITS: %v", err)
+	}
+	loopThresholds, err := service.ParseToolLimits(c.ToolLoopThresholds)
+	if err != nil {
+		log.Fatalf("MCP_TOOL_LOOP_THRESHOLDS: %v", err)
+	}
+	lc := &service.ToolCallLimitConfig{
+		AgentPerMinute:     float64(c.AgentCallsPerMinute),
+		ToolPerMinute:      toolRates,
+		LoopWindow:         time.Duration(c.LoopWindowSec) * time.Second,
+		LoopThreshold:      c.LoopThreshold,
+		ToolLoopThresholds: make(map[string]int, len(loopThresholds)),
+		Cooldown:           time.Duration(c.LoopCooldownSec) * time.Second,
+	}
+	for tool, n := range loopThresholds {
+		lc.ToolLoopThresholds[tool] = int(n)
+	}
+	return lc
+}
+
+// conversationGovernorConfig 由环境配置构造 AI 之间对话的预算配置
+func conversationGovernorConfig(c config.MessageConfig) service.ConversationGovernorConfig {
+	return service.ConversationGovernorConfig{
+		Window:          time.Duration(c.GovernorWindowMin) * time.Minute,
+		MaxPairTurns:    c.MaxPairTurns,
+		MaxChannelTurns: c.MaxChannelTurns,
+		PingPongTurns:   c.PingPongTurns,
+		PingPongGap:     time.Duration(c.PingPongGapSec) * time.Second,
+		Cooldown:        time.Duration(c.CooldownMin) * time.Minute,
+	}
+}
+
+// presenceConfig 由环境配置构造在线状态心跳与空闲检测参数
+func presenceConfig(c config.PresenceConfig) service.PresenceConfig {
+	return service.PresenceConfig{
+		HeartbeatTTL:  time.Duration(c.HeartbeatTTLSec) * time.Second,
+		IdleAfter:     time.Duration(c.IdleAfterMin) * time.Minute,
+		SweepInterval: time.Duration(c.SweepIntervalSec) * time.Second,
+	}
+}
+
+// digestConfig 由环境配置构造每日摘要参数
+func digestConfig(c config.DigestConfig) service.DigestConfig {
+	return service.DigestConfig{
+		DefaultCron:     c.DefaultCron,
+		DefaultTimezone: c.DefaultTimezone,
+		MaxMessages:     c.MaxMessages,
+		PollInterval:    time.Duration(c.PollIntervalSec) * time.Second,
+	}
+}
+
+// taskSLAConfig 由环境配置构造任务截止提醒与 SLA 配置
+func taskSLAConfig(c config.TaskConfig) service.TaskSLAConfig {
+	return service.TaskSLAConfig{
+		Interval:      time.Duration(c.SLACheckIntervalSec) * time.Second,
+		DueWarning:    time.Duration(c.DueWarningMinutes) * time.Minute,
+		AssignedSLA:   parseTaskSLA("TASK_SLA_ASSIGNED", c.AssignedSLA),
+		InProgressSLA: parseTaskSLA("TASK_SLA_IN_PROGRESS", c.InProgressSLA),
+		Reescalation:  time.Duration(c.EscalationMinutes) * time.Minute,
+	}
+}
+
+func storageConfig(c config.StorageConfig) storage.Config {
+	return storage.Config{
+		Backend:  c.Backend,
+		LocalDir: c.LocalDir,
+		S3: storage.S3Config{
+			Endpoint:  c.S3Endpoint,
+			Region:    c.S3Region,
+			Bucket:    c.S3Bucket,
+			AccessKey: c.S3AccessKey,
+			SecretKey: c.S3SecretKey,
+			PathStyle: c.S3PathStyle,
+		},
+	}
+}
+
+func parseTaskSLA(env, spec string) map[domain.TaskPriority]time.Duration {
+	limits, err := service.ParseTaskSLA(spec)
+	if err != nil {
+		log.Fatalf("%s: %v", env, err)
+	}
+	return limits
+}
+
 // validateWSToken 支持 JWT token 和 API Key 两种方式验证 WS 连接身份
 func validateWSToken(tokenStr, secret string, agentRepo repository.AgentRepo) (*domain.Agent, error) {
 	parsed, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
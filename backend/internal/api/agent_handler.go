@@ -2,6 +2,8 @@ package api
 
 import (
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -10,7 +12,8 @@ import (
 )
 
 type agentHandler struct {
-	agentSvc *service.AgentService
+	agentSvc    *service.AgentService
+	presenceSvc *service.PresenceService
 }
 
 func (h *agentHandler) list(c *gin.Context) {
@@ -115,3 +118,64 @@ func (h *agentHandler) update(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, agent)
 }
+
+// statusHistory GET /agents/:id/status-history?from=&to=  默认最近 7 天
+func (h *agentHandler) statusHistory(c *gin.Context) {
+	from, to, ok := parseStatusRange(c)
+	if !ok {
+		return
+	}
+	list, err := h.presenceSvc.History(c.Request.Context(), currentAgent(c), c.Param("id"), from, to)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list, "total": len(list)})
+}
+
+// utilization GET /agents/utilization?from=&to=  各员工在线 / 忙碌 / 空闲时长统计
+func (h *agentHandler) utilization(c *gin.Context) {
+	from, to, ok := parseStatusRange(c)
+	if !ok {
+		return
+	}
+	list, err := h.presenceSvc.Utilization(c.Request.Context(), currentCompanyID(c), from, to)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list, "from": from, "to": to})
+}
+
+// parseStatusRange 解析 from / to（RFC3339 或 YYYY-MM-DD），缺省为截至当前的最近 7 天
+func parseStatusRange(c *gin.Context) (time.Time, time.Time, bool) {
+	to := time.Now()
+	from := to.Add(-7 * 24 * time.Hour)
+	for key, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+		v := c.Query(key)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			if t, err = time.Parse(time.DateOnly, v); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": key + " must be RFC3339 or YYYY-MM-DD"})
+				return time.Time{}, time.Time{}, false
+			}
+		}
+		*dst = t
+	}
+	return from, to, true
+}
+
+func (h *agentHandler) writeError(c *gin.Context, err error) {
+	msg := err.Error()
+	switch {
+	case strings.HasSuffix(msg, "not found"):
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+	case msg == "permission denied":
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+	}
+}
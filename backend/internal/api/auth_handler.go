@@ -10,7 +10,6 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/linkclaw/backend/internal/i18n"
-	"github.com/linkclaw/backend/internal/domain"
 	"github.com/linkclaw/backend/internal/repository"
 )
 
@@ -71,11 +70,8 @@ func (h *authHandler) login(c *gin.Context) {
 		return
 	}
 
-	// 登录即在线
-	ctx := c.Request.Context()
-	_ = h.agentRepo.UpdateStatus(ctx, agent.ID, domain.StatusOnline)
-	_ = h.agentRepo.UpdateLastSeen(ctx, agent.ID)
-	agent.Status = "online"
+	// 在线状态由 WebSocket 连接维护（PresenceService），登录只刷新最近活跃时间
+	_ = h.agentRepo.UpdateLastSeen(c.Request.Context(), agent.ID)
 
 	token, err := generateJWT(agent.ID, h.jwtSecret, h.jwtExpiry)
 	if err != nil {
@@ -95,11 +91,8 @@ func generateJWT(agentID, secret string, expiryHours int) (string, error) {
 	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
 }
 
+// logout JWT 无服务端会话，前端丢弃 token 并关闭 WebSocket 后由 PresenceService 置为离线
 func (h *authHandler) logout(c *gin.Context) {
-	agent := currentAgent(c)
-	if agent != nil {
-		_ = h.agentRepo.UpdateStatus(c.Request.Context(), agent.ID, domain.StatusOffline)
-	}
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/service"
+)
+
+type channelHandler struct {
+	channelSvc *service.ChannelService
+}
+
+// list GET /channels?include_archived=true&all=true（all 需 channel:manage）
+func (h *channelHandler) list(c *gin.Context) {
+	channels, err := h.channelSvc.List(c.Request.Context(), currentAgent(c),
+		c.Query("all") == "true", c.Query("include_archived") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": channels, "total": len(channels)})
+}
+
+type createChannelRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	IsPrivate   bool     `json:"is_private"`
+	MemberIDs   []string `json:"member_ids"`
+}
+
+func (h *channelHandler) create(c *gin.Context) {
+	var req createChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ch, members, err := h.channelSvc.Create(c.Request.Context(), currentAgent(c), service.CreateChannelInput{
+		Name:        req.Name,
+		Description: req.Description,
+		Private:     req.IsPrivate,
+		MemberIDs:   req.MemberIDs,
+	})
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"channel": ch, "members": members})
+}
+
+func (h *channelHandler) get(c *gin.Context) {
+	ch, err := h.channelSvc.Get(c.Request.Context(), currentAgent(c), c.Param("id"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ch)
+}
+
+type updateChannelRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	IsPrivate   *bool   `json:"is_private"`
+}
+
+func (h *channelHandler) update(c *gin.Context) {
+	var req updateChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ch, err := h.channelSvc.Update(c.Request.Context(), currentAgent(c), c.Param("id"), service.UpdateChannelInput{
+		Name:        req.Name,
+		Description: req.Description,
+		Private:     req.IsPrivate,
+	})
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ch)
+}
+
+func (h *channelHandler) archive(c *gin.Context) {
+	h.setArchived(c, true)
+}
+
+func (h *channelHandler) unarchive(c *gin.Context) {
+	h.setArchived(c, false)
+}
+
+func (h *channelHandler) setArchived(c *gin.Context, archived bool) {
+	ch, err := h.channelSvc.SetArchived(c.Request.Context(), currentAgent(c), c.Param("id"), archived)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ch)
+}
+
+func (h *channelHandler) listMembers(c *gin.Context) {
+	members, err := h.channelSvc.ListMembers(c.Request.Context(), currentAgent(c), c.Param("id"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": members})
+}
+
+type channelMemberRequest struct {
+	AgentID string             `json:"agent_id" binding:"required"`
+	Role    domain.ChannelRole `json:"role"`
+}
+
+// addMember POST /channels/:id/members（已是成员时更新角色）
+func (h *channelHandler) addMember(c *gin.Context) {
+	var req channelMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	m, err := h.channelSvc.AddMember(c.Request.Context(), currentAgent(c), c.Param("id"), req.AgentID, req.Role)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, m)
+}
+
+type updateChannelMemberRequest struct {
+	Role domain.ChannelRole `json:"role" binding:"required"`
+}
+
+func (h *channelHandler) updateMember(c *gin.Context) {
+	var req updateChannelMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	m, err := h.channelSvc.AddMember(c.Request.Context(), currentAgent(c), c.Param("id"), c.Param("agentId"), req.Role)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, m)
+}
+
+// removeMember DELETE /channels/:id/members/:agentId（agentId 为本人时即退出频道）
+func (h *channelHandler) removeMember(c *gin.Context) {
+	if err := h.channelSvc.RemoveMember(c.Request.Context(), currentAgent(c), c.Param("id"), c.Param("agentId")); err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (h *channelHandler) writeError(c *gin.Context, err error) {
+	msg := err.Error()
+	switch {
+	case strings.HasSuffix(msg, "not found"):
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+	case msg == "permission denied":
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+	case strings.Contains(msg, "already exists"):
+		c.JSON(http.StatusConflict, gin.H{"error": msg})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+	}
+}
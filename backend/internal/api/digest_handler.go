@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/linkclaw/backend/internal/service"
+)
+
+type digestHandler struct {
+	digestSvc *service.DigestService
+}
+
+// getSettings GET /digest/settings — 尚未设置时返回默认值
+func (h *digestHandler) getSettings(c *gin.Context) {
+	st, err := h.digestSvc.GetSettings(c.Request.Context(), currentAgent(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, st)
+}
+
+// updateSettings PUT /digest/settings — 只修改请求中出现的字段
+func (h *digestHandler) updateSettings(c *gin.Context) {
+	var req service.DigestSettingsInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	st, err := h.digestSvc.UpdateSettings(c.Request.Context(), currentAgent(c), req)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, st)
+}
+
+func (h *digestHandler) list(c *gin.Context) {
+	list, err := h.digestSvc.List(c.Request.Context(), currentAgent(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+func (h *digestHandler) get(c *gin.Context) {
+	d, err := h.digestSvc.Get(c.Request.Context(), currentAgent(c), c.Param("id"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, d)
+}
+
+// generate POST /digests — 立即生成并按设置投递一次摘要
+func (h *digestHandler) generate(c *gin.Context) {
+	d, err := h.digestSvc.Generate(c.Request.Context(), currentAgent(c))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, d)
+}
+
+func (h *digestHandler) writeError(c *gin.Context, err error) {
+	msg := err.Error()
+	switch {
+	case strings.HasSuffix(msg, "not found"):
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+	case strings.HasPrefix(msg, "nothing new"):
+		c.JSON(http.StatusConflict, gin.H{"error": msg})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+	}
+}
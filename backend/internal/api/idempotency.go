@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/service"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	// maxIdempotentBodyBytes 请求体超过该大小时不做幂等处理，避免整体读入内存计算摘要
+	maxIdempotentBodyBytes = 1 << 20
+)
+
+// idempotencyWriter 缓存响应体，供首次请求完成后保存
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotencyWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware 带 Idempotency-Key 的 POST 请求：TTL 内以同一 key 重试时返回首次响应。
+// 5xx 响应不保存，客户端可以同一 key 重试。multipart 上传与超过 maxIdempotentBodyBytes 的请求不做幂等处理。
+func IdempotencyMiddleware(idemSvc *service.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		agent := currentAgent(c)
+		if idemSvc == nil || key == "" || c.Request.Method != http.MethodPost || agent == nil ||
+			strings.HasPrefix(c.ContentType(), "multipart/") {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxIdempotentBodyBytes+1))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "read body: " + err.Error()})
+			return
+		}
+		// 已读部分放回请求体前面，超限时剩余部分由处理器继续读取
+		c.Request.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.Body}
+		if len(body) > maxIdempotentBodyBytes {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		scope := domain.IdempotencyScopeREST
+		hash := service.HashIdempotentRequest(c.Request.Method, c.Request.URL.Path, c.Request.URL.RawQuery, string(body))
+		rec, lockToken, err := idemSvc.Begin(ctx, agent.ID, scope, key, hash)
+		switch {
+		case errors.Is(err, service.ErrIdempotencyInProgress):
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, service.ErrIdempotencyMismatch):
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case rec != nil:
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(rec.StatusCode, "application/json; charset=utf-8", []byte(rec.Response))
+			c.Abort()
+			return
+		}
+
+		w := &idempotencyWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		bg := context.WithoutCancel(ctx)
+		if status := w.Status(); status >= 500 {
+			idemSvc.Release(bg, agent.ID, scope, key, lockToken)
+		} else {
+			idemSvc.Complete(bg, agent.ID, scope, key, lockToken, status, w.body.String())
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/repository"
+	"github.com/linkclaw/backend/internal/service"
+)
+
+// countingIdempotencyRepo 总能占用幂等键，只记录调用次数
+type countingIdempotencyRepo struct {
+	repository.IdempotencyRepo
+	reserved  int
+	completed int
+}
+
+func (r *countingIdempotencyRepo) Reserve(context.Context, *domain.IdempotencyRecord, time.Time) (bool, error) {
+	r.reserved++
+	return true, nil
+}
+
+func (r *countingIdempotencyRepo) Complete(context.Context, string, domain.IdempotencyScope, string, string, int, string) error {
+	r.completed++
+	return nil
+}
+
+// memIdempotencyRepo 内存幂等键，只处理首次占用
+type memIdempotencyRepo struct {
+	repository.IdempotencyRepo
+	records map[string]*domain.IdempotencyRecord
+}
+
+func (r *memIdempotencyRepo) Reserve(_ context.Context, rec *domain.IdempotencyRecord, _ time.Time) (bool, error) {
+	if _, ok := r.records[rec.Key]; ok {
+		return false, nil
+	}
+	cp := *rec
+	cp.Status = domain.IdempotencyPending
+	r.records[rec.Key] = &cp
+	return true, nil
+}
+
+func (r *memIdempotencyRepo) Get(_ context.Context, _ string, _ domain.IdempotencyScope, key string) (*domain.IdempotencyRecord, error) {
+	return r.records[key], nil
+}
+
+func (r *memIdempotencyRepo) Complete(_ context.Context, _ string, _ domain.IdempotencyScope, key, lockToken string, statusCode int, response string) error {
+	rec := r.records[key]
+	if rec.LockToken != lockToken {
+		return repository.ErrIdempotencyLockLost
+	}
+	rec.Status, rec.StatusCode, rec.Response = domain.IdempotencyDone, statusCode, response
+	return nil
+}
+
+func newIdempotencyRouter(repo repository.IdempotencyRepo) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(ctxAgent, &domain.Agent{ID: "a1"}) })
+	r.Use(IdempotencyMiddleware(service.NewIdempotencyService(repo, time.Hour)))
+	r.POST("/echo", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, strconv.Itoa(len(body)))
+	})
+	return r
+}
+
+func TestIdempotencyMiddleware_BodyHandling(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		size        int
+		wantReserve int
+	}{
+		{"small json is hashed", "application/json", 100, 1},
+		{"body at the cap is hashed", "application/json", maxIdempotentBodyBytes, 1},
+		{"oversized body skips idempotency", "application/json", maxIdempotentBodyBytes + 10, 0},
+		{"multipart skips idempotency", "multipart/form-data; boundary=x", 100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &countingIdempotencyRepo{}
+			req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(bytes.Repeat([]byte("a"), tt.size)))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set(idempotencyKeyHeader, "k1")
+			w := httptest.NewRecorder()
+			newIdempotencyRouter(repo).ServeHTTP(w, req)
+
+			if got := w.Body.String(); got != strconv.Itoa(tt.size) {
+				t.Errorf("handler read %s bytes, want %d", got, tt.size)
+			}
+			if repo.reserved != tt.wantReserve || repo.completed != tt.wantReserve {
+				t.Errorf("reserved %d / completed %d, want %d", repo.reserved, repo.completed, tt.wantReserve)
+			}
+		})
+	}
+}
+
+func TestIdempotencyMiddleware_HashesQueryString(t *testing.T) {
+	router := newIdempotencyRouter(&memIdempotencyRepo{records: map[string]*domain.IdempotencyRecord{}})
+	post := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader([]byte(`{}`)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(idempotencyKeyHeader, "k1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post("/echo?force=false"); w.Code != http.StatusOK {
+		t.Fatalf("first request status = %d", w.Code)
+	}
+	if w := post("/echo?force=true"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("same key with another query string: status = %d, want 422", w.Code)
+	}
+	if w := post("/echo?force=false"); w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("identical retry was not replayed: status = %d", w.Code)
+	}
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/service"
+)
+
+type mcpUpstreamHandler struct {
+	fedSvc *service.McpFederationService
+}
+
+type mcpUpstreamRequest struct {
+	Name         string                      `json:"name" binding:"required"`
+	Description  string                      `json:"description"`
+	Transport    domain.McpUpstreamTransport `json:"transport" binding:"required"`
+	URL          string                      `json:"url"`
+	Image        string                      `json:"image"`
+	Command      string                      `json:"command"`
+	Args         []string                    `json:"args"`
+	AuthHeader   string                      `json:"auth_header"`
+	AuthScheme   string                      `json:"auth_scheme"`
+	AuthEnv      string                      `json:"auth_env"`
+	RequiredPerm string                      `json:"required_perm"`
+	IsActive     *bool                       `json:"is_active"`
+}
+
+func (r mcpUpstreamRequest) toInput() service.McpUpstreamInput {
+	return service.McpUpstreamInput{
+		Name:         r.Name,
+		Description:  r.Description,
+		Transport:    r.Transport,
+		URL:          r.URL,
+		Image:        r.Image,
+		Command:      r.Command,
+		Args:         r.Args,
+		AuthHeader:   r.AuthHeader,
+		AuthScheme:   r.AuthScheme,
+		AuthEnv:      r.AuthEnv,
+		RequiredPerm: r.RequiredPerm,
+		IsActive:     r.IsActive,
+	}
+}
+
+func (h *mcpUpstreamHandler) list(c *gin.Context) {
+	list, err := h.fedSvc.ListUpstreams(c.Request.Context(), currentCompanyID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+func (h *mcpUpstreamHandler) get(c *gin.Context) {
+	u, err := h.fedSvc.GetUpstream(c.Request.Context(), currentCompanyID(c), c.Param("id"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, u)
+}
+
+func (h *mcpUpstreamHandler) create(c *gin.Context) {
+	var req mcpUpstreamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	u, err := h.fedSvc.CreateUpstream(c.Request.Context(), currentCompanyID(c), req.toInput())
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, u)
+}
+
+func (h *mcpUpstreamHandler) update(c *gin.Context) {
+	var req mcpUpstreamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	u, err := h.fedSvc.UpdateUpstream(c.Request.Context(), currentCompanyID(c), c.Param("id"), req.toInput())
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, u)
+}
+
+func (h *mcpUpstreamHandler) delete(c *gin.Context) {
+	if err := h.fedSvc.DeleteUpstream(c.Request.Context(), currentCompanyID(c), c.Param("id")); err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// test POST /mcp-upstreams/:id/test — 用公司级凭证连接上游并列出工具
+func (h *mcpUpstreamHandler) test(c *gin.Context) {
+	tools, err := h.fedSvc.TestUpstream(c.Request.Context(), currentCompanyID(c), c.Param("id"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true, "tools": tools})
+}
+
+func (h *mcpUpstreamHandler) listCredentials(c *gin.Context) {
+	list, err := h.fedSvc.ListCredentials(c.Request.Context(), currentCompanyID(c), c.Param("id"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+type setUpstreamCredentialRequest struct {
+	AgentID *string `json:"agent_id"` // 为空表示公司级凭证
+	Secret  string  `json:"secret" binding:"required"`
+}
+
+// setCredential PUT /mcp-upstreams/:id/credentials — 设置（覆盖）公司级或 Agent 专属凭证
+func (h *mcpUpstreamHandler) setCredential(c *gin.Context) {
+	var req setUpstreamCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.fedSvc.SetCredential(c.Request.Context(), currentCompanyID(c), c.Param("id"), req.AgentID, req.Secret); err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (h *mcpUpstreamHandler) deleteCredential(c *gin.Context) {
+	if err := h.fedSvc.DeleteCredential(c.Request.Context(), currentCompanyID(c), c.Param("id"), c.Param("credId")); err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (h *mcpUpstreamHandler) writeError(c *gin.Context, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+	case strings.Contains(msg, "forbidden"):
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+	}
+}
@@ -1,16 +1,23 @@
 package api
 
 import (
+	"errors"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/linkclaw/backend/internal/domain"
 	"github.com/linkclaw/backend/internal/service"
+	"github.com/linkclaw/backend/internal/storage"
 )
 
 type messageHandler struct {
 	messageSvc *service.MessageService
+	searchSvc  *service.MessageSearchService
 }
 
 func (h *messageHandler) list(c *gin.Context) {
@@ -28,7 +35,7 @@ func (h *messageHandler) list(c *gin.Context) {
 
 	switch {
 	case channel != "":
-		msgs, err = h.messageSvc.GetChannelMessages(c.Request.Context(), agent.CompanyID, channel, limit, beforeID)
+		msgs, err = h.messageSvc.GetChannelMessages(c.Request.Context(), agent.CompanyID, agent.ID, channel, limit, beforeID)
 	case receiverID != "":
 		msgs, err = h.messageSvc.GetDMMessages(c.Request.Context(), agent.ID, receiverID, limit, beforeID)
 	default:
@@ -43,9 +50,11 @@ func (h *messageHandler) list(c *gin.Context) {
 }
 
 type sendMessageRequest struct {
-	Channel    string `json:"channel"`
-	ReceiverID string `json:"receiver_id"`
-	Content    string `json:"content" binding:"required"`
+	Channel      string                 `json:"channel"`
+	ReceiverID   string                 `json:"receiver_id"`
+	ThreadRootID string                 `json:"thread_root_id"`
+	Content      string                 `json:"content"`
+	Blocks       []*domain.MessageBlock `json:"blocks"` // 富消息块，与 content 至少提供一个
 }
 
 func (h *messageHandler) send(c *gin.Context) {
@@ -56,11 +65,13 @@ func (h *messageHandler) send(c *gin.Context) {
 	}
 	agent := currentAgent(c)
 	msg, err := h.messageSvc.Send(c.Request.Context(), service.SendMessageInput{
-		CompanyID:  agent.CompanyID,
-		SenderID:   agent.ID,
-		Channel:    req.Channel,
-		ReceiverID: req.ReceiverID,
-		Content:    req.Content,
+		CompanyID:    agent.CompanyID,
+		SenderID:     agent.ID,
+		Channel:      req.Channel,
+		ReceiverID:   req.ReceiverID,
+		ThreadRootID: req.ThreadRootID,
+		Content:      req.Content,
+		Blocks:       req.Blocks,
 	})
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -69,12 +80,211 @@ func (h *messageHandler) send(c *gin.Context) {
 	c.JSON(http.StatusCreated, msg)
 }
 
-func (h *messageHandler) listChannels(c *gin.Context) {
-	agent    := currentAgent(c)
-	channels, err := h.messageSvc.ListChannels(c.Request.Context(), agent.CompanyID)
+func (h *messageHandler) search(c *gin.Context) {
+	in := service.SearchMessagesInput{
+		Query:     c.Query("q"),
+		Channel:   c.Query("channel"),
+		SenderID:  c.Query("sender_id"),
+		MentionID: c.Query("mention_id"),
+		Semantic:  c.Query("semantic") == "true",
+		Limit:     parseIntQuery(c, "limit", 20),
+		Offset:    parseIntQuery(c, "offset", 0),
+	}
+	for key, dst := range map[string]**time.Time{"after": &in.After, "before": &in.Before} {
+		v := c.Query(key)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			if t, err = time.Parse(time.DateOnly, v); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": key + " must be RFC3339 or YYYY-MM-DD"})
+				return
+			}
+		}
+		*dst = &t
+	}
+	res, err := h.searchSvc.Search(c.Request.Context(), currentAgent(c), in)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *messageHandler) thread(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	root, replies, err := h.messageSvc.GetThread(c.Request.Context(), currentAgent(c), c.Param("id"), limit, c.Query("after_id"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"root": root, "data": replies})
+}
+
+type editMessageRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+func (h *messageHandler) edit(c *gin.Context) {
+	var req editMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	msg, err := h.messageSvc.Edit(c.Request.Context(), currentAgent(c), c.Param("id"), req.Content)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, msg)
+}
+
+func (h *messageHandler) listEdits(c *gin.Context) {
+	edits, err := h.messageSvc.ListEdits(c.Request.Context(), currentAgent(c), c.Param("id"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": edits})
+}
+
+func (h *messageHandler) delete(c *gin.Context) {
+	if err := h.messageSvc.Delete(c.Request.Context(), currentAgent(c), c.Param("id")); err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+type reactRequest struct {
+	Emoji string `json:"emoji" binding:"required"`
+}
+
+func (h *messageHandler) addReaction(c *gin.Context) {
+	var req reactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.react(c, req.Emoji, false)
+}
+
+func (h *messageHandler) removeReaction(c *gin.Context) {
+	h.react(c, c.Param("emoji"), true)
+}
+
+func (h *messageHandler) react(c *gin.Context, emoji string, remove bool) {
+	reactions, err := h.messageSvc.React(c.Request.Context(), currentAgent(c), c.Param("id"), emoji, remove)
 	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": reactions})
+}
+
+func (h *messageHandler) pin(c *gin.Context) {
+	h.setPinned(c, true)
+}
+
+func (h *messageHandler) unpin(c *gin.Context) {
+	h.setPinned(c, false)
+}
+
+func (h *messageHandler) setPinned(c *gin.Context, pinned bool) {
+	msg, err := h.messageSvc.Pin(c.Request.Context(), currentAgent(c), c.Param("id"), pinned)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, msg)
+}
+
+func (h *messageHandler) listPinned(c *gin.Context) {
+	channel := c.Query("channel")
+	if channel == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "channel is required"})
+		return
+	}
+	msgs, err := h.messageSvc.ListPinned(c.Request.Context(), currentAgent(c), channel)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": msgs})
+}
+
+// uploadAttachment POST /messages/attachments（multipart 字段 file），返回的附件 ID 用于文件块
+func (h *messageHandler) uploadAttachment(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	file, err := parseTaskUploadFile(header)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	a, err := h.messageSvc.UploadAttachment(c.Request.Context(), currentAgent(c), file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": a})
+}
+
+// downloadAttachment GET /messages/attachments/:attachmentId
+func (h *messageHandler) downloadAttachment(c *gin.Context) {
+	a, rc, err := h.messageSvc.OpenAttachment(c.Request.Context(), currentAgent(c), c.Param("attachmentId"))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) || err.Error() == "attachment not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"data": channels, "total": len(channels)})
+	defer rc.Close()
+	headers := map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": a.OriginalFilename}),
+	}
+	if a.SHA256 != "" {
+		headers["ETag"] = `"` + a.SHA256 + `"`
+		headers["X-Checksum-SHA256"] = a.SHA256
+	}
+	c.DataFromReader(http.StatusOK, a.FileSize, a.MimeType, rc, headers)
+}
+
+type messageActionRequest struct {
+	ActionID string `json:"action_id" binding:"required"`
+	Value    string `json:"value"`  // 审批卡片：审批请求 ID
+	Reason   string `json:"reason"` // 审批卡片：处理意见
+}
+
+// act POST /messages/:id/actions 点击消息中的按钮或审批卡片
+func (h *messageHandler) act(c *gin.Context) {
+	var req messageActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	msg, err := h.messageSvc.Act(c.Request.Context(), currentAgent(c), c.Param("id"), req.ActionID, req.Value, req.Reason)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, msg)
+}
+
+func (h *messageHandler) writeError(c *gin.Context, err error) {
+	msg := err.Error()
+	switch {
+	case strings.HasSuffix(msg, "not found"):
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+	case msg == "permission denied":
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+	}
 }
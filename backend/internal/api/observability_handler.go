@@ -13,9 +13,11 @@ import (
 )
 
 type observabilityHandler struct {
-	obsSvc     *service.ObservabilityService
-	obsRepo    repository.ObservabilityRepo
-	qualitySvc *service.QualityScoringService
+	obsSvc      *service.ObservabilityService
+	obsRepo     repository.ObservabilityRepo
+	qualitySvc  *service.QualityScoringService
+	deliverySvc *service.AgentDeliveryService
+	governor    *service.ConversationGovernor
 }
 
 func parseIntQuery(c *gin.Context, key string, defaultVal int) int {
@@ -246,3 +248,41 @@ func (h *observabilityHandler) listQualityScores(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"data": scores, "total": len(scores)})
 }
+
+// agentDeliveries Agent 投递指标（含缓冲区满丢弃次数）与待确认积压
+func (h *observabilityHandler) agentDeliveries(c *gin.Context) {
+	stats, err := h.deliverySvc.Stats(c.Request.Context(), currentCompanyID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": stats})
+}
+
+// conversations AI 之间对话的预算使用情况（暂停中的排在前面）
+func (h *observabilityHandler) conversations(c *gin.Context) {
+	var stats []*service.ConversationStat
+	if h.governor != nil {
+		stats = h.governor.Stats(currentCompanyID(c))
+	}
+	if stats == nil {
+		stats = []*service.ConversationStat{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": stats})
+}
+
+// resumeConversation 人工恢复被暂停的 AI 对话
+func (h *observabilityHandler) resumeConversation(c *gin.Context) {
+	var req struct {
+		Key string `json:"key" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if h.governor == nil || !h.governor.Resume(currentCompanyID(c), req.Key) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
@@ -65,8 +65,15 @@ func (m *mockAgentRepo) GetByCompany(ctx context.Context, companyID string) ([]*
 	return nil, nil
 }
 func (m *mockAgentRepo) GetByName(context.Context, string, string) (*domain.Agent, error) { return nil, nil }
+func (m *mockAgentRepo) ListMentioned(context.Context, string, string) ([]*domain.Agent, error) {
+	return nil, nil
+}
 func (m *mockAgentRepo) GetByHireRequestID(context.Context, string) (*domain.Agent, error) { return nil, nil }
 func (m *mockAgentRepo) UpdateStatus(context.Context, string, domain.AgentStatus) error     { return nil }
+func (m *mockAgentRepo) SetStatusIf(context.Context, string, domain.AgentStatus, domain.AgentStatus) (bool, error) {
+	return false, nil
+}
+func (m *mockAgentRepo) ListPresent(context.Context) ([]*domain.Agent, error) { return nil, nil }
 func (m *mockAgentRepo) UpdateLastSeen(context.Context, string) error                        { return nil }
 func (m *mockAgentRepo) UpdateName(context.Context, string, string) error                    { return nil }
 func (m *mockAgentRepo) UpdateModel(context.Context, string, string) error                   { return nil }
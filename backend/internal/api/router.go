@@ -40,6 +40,16 @@ func RegisterRoutes(
 	contextSvc *service.ContextService,
 	contextAgent *service.ContextSearchAgent,
 	contextScheduler *service.ContextScheduler,
+	toolPolicySvc *service.ToolPolicyService,
+	fedSvc *service.McpFederationService,
+	idemSvc *service.IdempotencyService,
+	templateSvc *service.TaskTemplateService,
+	channelSvc *service.ChannelService,
+	messageSearchSvc *service.MessageSearchService,
+	deliverySvc *service.AgentDeliveryService,
+	scheduleSvc *service.ScheduledMessageService,
+	presenceSvc *service.PresenceService,
+	digestSvc *service.DigestService,
 ) {
 	// 前端反向代理（必须放在最前面，优先捕获非 API 请求）
 	r.Use(FrontendProxy())
@@ -63,6 +73,7 @@ func RegisterRoutes(
 		AuthMiddleware(agentRepo, jwtSecret),
 		AuditMiddleware(auditRepo),
 		SensitiveAccessMiddleware(auditRepo),
+		IdempotencyMiddleware(idemSvc),
 	)
 	auth.POST("/auth/logout", authH.logout)
 	auth.POST("/auth/change-password", authH.changePassword)
@@ -70,9 +81,11 @@ func RegisterRoutes(
 	auth.GET("/audit/logs", auditH.listAuditLogs)
 
 	// Agent
-	ah := &agentHandler{agentSvc: agentSvc}
+	ah := &agentHandler{agentSvc: agentSvc, presenceSvc: presenceSvc}
 	auth.GET("/agents", ah.list)
 	auth.POST("/agents", ah.create)
+	auth.GET("/agents/utilization", ChairmanOnly(), ah.utilization)
+	auth.GET("/agents/:id/status-history", ah.statusHistory)
 	auth.GET("/agents/:id", ah.get)
 	auth.PATCH("/agents/:id", ah.update)
 	auth.DELETE("/agents/:id", ChairmanOnly(), ah.delete)
@@ -91,19 +104,91 @@ func RegisterRoutes(
 	auth.GET("/tasks/:id", th.get)
 	auth.DELETE("/tasks/:id", th.delete)
 	auth.GET("/tasks/:id/detail", th.detail)
+	auth.GET("/tasks/:id/dag", th.dag)
+	auth.POST("/tasks/:id/accept", th.accept)
+	auth.POST("/tasks/:id/submit", th.submit)
+	auth.POST("/tasks/:id/fail", th.fail)
+	auth.POST("/tasks/:id/approve", th.approve)
+	auth.POST("/tasks/:id/request-changes", th.requestChanges)
+	auth.POST("/tasks/:id/reassign", th.reassign)
+	auth.POST("/tasks/:id/unassign", th.unassign)
+	auth.POST("/tasks/:id/claim", th.claim)
+	auth.POST("/tasks/:id/auto-assign", th.autoAssign)
+	auth.GET("/tasks/:id/assignee-suggestions", th.assigneeSuggestions)
 	auth.POST("/tasks/:id/comments", th.addComment)
+	auth.PATCH("/tasks/:id/comments/:commentId", th.editComment)
+	auth.GET("/tasks/:id/comments/:commentId/edits", th.listCommentEdits)
 	auth.DELETE("/tasks/:id/comments/:commentId", th.deleteComment)
 	auth.POST("/tasks/:id/dependencies", th.addDependency)
 	auth.DELETE("/tasks/:id/dependencies/:depId", th.removeDependency)
 	auth.POST("/tasks/:id/watchers", th.addWatcher)
 	auth.DELETE("/tasks/:id/watchers", th.removeWatcher)
 	auth.PUT("/tasks/:id/tags", th.updateTags)
+	auth.PATCH("/tasks/:id", th.update)
+	auth.PUT("/tasks/:id/due", th.setDue)
+	auth.GET("/tasks/:id/attachments", th.listAttachments)
+	auth.POST("/tasks/:id/attachments", th.uploadAttachments)
+	auth.GET("/tasks/:id/attachments/:attachmentId", th.downloadAttachment)
+	auth.DELETE("/tasks/:id/attachments/:attachmentId", th.deleteAttachment)
+
+	// Task 模板与周期规则（修改需 task:manage 权限）
+	tth := &taskTemplateHandler{templateSvc: templateSvc}
+	auth.GET("/task-templates", tth.list)
+	auth.POST("/task-templates", tth.create)
+	auth.GET("/task-templates/:id", tth.get)
+	auth.PUT("/task-templates/:id", tth.update)
+	auth.DELETE("/task-templates/:id", tth.delete)
+	auth.POST("/task-templates/:id/instantiate", tth.instantiate)
+	auth.GET("/task-templates/:id/recurrences", tth.listRecurrences)
+	auth.POST("/task-templates/:id/recurrences", tth.createRecurrence)
+	auth.PUT("/task-recurrences/:id", tth.updateRecurrence)
+	auth.DELETE("/task-recurrences/:id", tth.deleteRecurrence)
 
 	// Message
-	mh := &messageHandler{messageSvc: messageSvc}
+	mh := &messageHandler{messageSvc: messageSvc, searchSvc: messageSearchSvc}
 	auth.GET("/messages", mh.list)
 	auth.POST("/messages", mh.send)
-	auth.GET("/channels", mh.listChannels)
+	auth.GET("/messages/search", mh.search)
+	auth.GET("/messages/pinned", mh.listPinned)
+	auth.POST("/messages/attachments", mh.uploadAttachment)
+	auth.GET("/messages/attachments/:attachmentId", mh.downloadAttachment)
+	auth.GET("/messages/:id/thread", mh.thread)
+	auth.PATCH("/messages/:id", mh.edit)
+	auth.DELETE("/messages/:id", mh.delete)
+	auth.GET("/messages/:id/edits", mh.listEdits)
+	auth.POST("/messages/:id/reactions", mh.addReaction)
+	auth.DELETE("/messages/:id/reactions/:emoji", mh.removeReaction)
+	auth.POST("/messages/:id/pin", mh.pin)
+	auth.DELETE("/messages/:id/pin", mh.unpin)
+	auth.POST("/messages/:id/actions", mh.act)
+
+	// 定时消息与提醒（创建者本人管理，董事长可查看全公司）
+	smh := &scheduledMessageHandler{scheduleSvc: scheduleSvc}
+	auth.GET("/scheduled-messages", smh.list)
+	auth.POST("/scheduled-messages", smh.create)
+	auth.GET("/scheduled-messages/:id", smh.get)
+	auth.POST("/scheduled-messages/:id/cancel", smh.cancel)
+
+	// 每日摘要：个人设置与历史
+	dgh := &digestHandler{digestSvc: digestSvc}
+	auth.GET("/digest/settings", dgh.getSettings)
+	auth.PUT("/digest/settings", dgh.updateSettings)
+	auth.GET("/digests", dgh.list)
+	auth.POST("/digests", dgh.generate)
+	auth.GET("/digests/:id", dgh.get)
+
+	// 频道
+	chh := &channelHandler{channelSvc: channelSvc}
+	auth.GET("/channels", chh.list)
+	auth.POST("/channels", chh.create)
+	auth.GET("/channels/:id", chh.get)
+	auth.PATCH("/channels/:id", chh.update)
+	auth.POST("/channels/:id/archive", chh.archive)
+	auth.POST("/channels/:id/unarchive", chh.unarchive)
+	auth.GET("/channels/:id/members", chh.listMembers)
+	auth.POST("/channels/:id/members", chh.addMember)
+	auth.PATCH("/channels/:id/members/:agentId", chh.updateMember)
+	auth.DELETE("/channels/:id/members/:agentId", chh.removeMember)
 
 	// Knowledge
 	kh := &knowledgeHandler{knowledgeSvc: knowledgeSvc}
@@ -137,6 +222,30 @@ func RegisterRoutes(
 	webhookAdmin.PUT("/:id", wh.updateWebhook)
 	webhookAdmin.DELETE("/:id", wh.deleteWebhook)
 
+	// MCP 工具权限策略（Chairman only）
+	tpH := &toolPolicyHandler{policySvc: toolPolicySvc, fedSvc: fedSvc, agentRepo: agentRepo}
+	toolPolicyAdmin := auth.Group("/tool-policies", ChairmanOnly())
+	toolPolicyAdmin.GET("", tpH.list)
+	toolPolicyAdmin.POST("", tpH.create)
+	toolPolicyAdmin.GET("/changes", tpH.listChanges)
+	toolPolicyAdmin.POST("/evaluate", tpH.evaluate)
+	toolPolicyAdmin.GET("/:id", tpH.get)
+	toolPolicyAdmin.PUT("/:id", tpH.update)
+	toolPolicyAdmin.DELETE("/:id", tpH.delete)
+
+	// 外部 MCP Server 联邦（Chairman only）
+	upH := &mcpUpstreamHandler{fedSvc: fedSvc}
+	upstreamAdmin := auth.Group("/mcp-upstreams", ChairmanOnly())
+	upstreamAdmin.GET("", upH.list)
+	upstreamAdmin.POST("", upH.create)
+	upstreamAdmin.GET("/:id", upH.get)
+	upstreamAdmin.PUT("/:id", upH.update)
+	upstreamAdmin.DELETE("/:id", upH.delete)
+	upstreamAdmin.POST("/:id/test", upH.test)
+	upstreamAdmin.GET("/:id/credentials", upH.listCredentials)
+	upstreamAdmin.PUT("/:id/credentials", upH.setCredential)
+	upstreamAdmin.DELETE("/:id/credentials/:credId", upH.deleteCredential)
+
 	// Organization（审批：全员可用；组织管理：仅董事长）
 	orgH := &organizationHandler{orgSvc: orgSvc}
 	orgRoutes := auth.Group("/organization")
@@ -178,7 +287,7 @@ func RegisterRoutes(
 	settingsAdmin.PUT("", settingsH.update)
 
 	// Observability 管理（Chairman only）
-	obsH := &observabilityHandler{obsSvc: obsSvc, obsRepo: obsRepo, qualitySvc: qualitySvc}
+	obsH := &observabilityHandler{obsSvc: obsSvc, obsRepo: obsRepo, qualitySvc: qualitySvc, deliverySvc: deliverySvc, governor: messageSvc.Governor()}
 	obsAdmin := auth.Group("/observability", ChairmanOnly())
 	obsAdmin.GET("/overview", obsH.overview)
 	obsAdmin.GET("/traces", obsH.listTraces)
@@ -192,6 +301,9 @@ func RegisterRoutes(
 	obsAdmin.GET("/error-policies", obsH.listErrorPolicies)
 	obsAdmin.POST("/error-policies", obsH.createErrorPolicy)
 	obsAdmin.GET("/quality-scores", obsH.listQualityScores)
+	obsAdmin.GET("/agent-deliveries", obsH.agentDeliveries)
+	obsAdmin.GET("/conversations", obsH.conversations)
+	obsAdmin.POST("/conversations/resume", obsH.resumeConversation)
 
 	// LLM Gateway 管理 API（Chairman only）
 	llmAdmin := auth.Group("/llm", ChairmanOnly())
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/linkclaw/backend/internal/service"
+)
+
+type scheduledMessageHandler struct {
+	scheduleSvc *service.ScheduledMessageService
+}
+
+// list GET /scheduled-messages?all=true&active=true — all 仅对董事长生效
+func (h *scheduledMessageHandler) list(c *gin.Context) {
+	list, err := h.scheduleSvc.List(c.Request.Context(), currentAgent(c), c.Query("all") == "true", c.Query("active") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+func (h *scheduledMessageHandler) get(c *gin.Context) {
+	m, err := h.scheduleSvc.Get(c.Request.Context(), currentAgent(c), c.Param("id"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, m)
+}
+
+// create POST /scheduled-messages — send_at（RFC3339）与 cron 二选一；kind=reminder 时发给自己
+func (h *scheduledMessageHandler) create(c *gin.Context) {
+	var req service.ScheduleMessageInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	m, err := h.scheduleSvc.Create(c.Request.Context(), currentAgent(c), req)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, m)
+}
+
+// cancel POST /scheduled-messages/:id/cancel
+func (h *scheduledMessageHandler) cancel(c *gin.Context) {
+	m, err := h.scheduleSvc.Cancel(c.Request.Context(), currentAgent(c), c.Param("id"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, m)
+}
+
+func (h *scheduledMessageHandler) writeError(c *gin.Context, err error) {
+	msg := err.Error()
+	switch {
+	case strings.HasSuffix(msg, "not found"):
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+	case strings.HasPrefix(msg, "scheduled message already"):
+		c.JSON(http.StatusConflict, gin.H{"error": msg})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+	}
+}
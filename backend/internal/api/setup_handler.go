@@ -106,10 +106,7 @@ func (h *setupHandler) initialize(c *gin.Context) {
 		return
 	}
 
-	// 初始化即在线
-	_ = h.agentRepo.UpdateStatus(ctx, out.Agent.ID, domain.StatusOnline)
 	_ = h.agentRepo.UpdateLastSeen(ctx, out.Agent.ID)
-	out.Agent.Status = domain.StatusOnline
 
 	token, err := generateJWT(out.Agent.ID, h.jwtSecret, h.jwtExpiry)
 	if err != nil {
@@ -2,11 +2,13 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -21,6 +23,13 @@ type mockTaskRepo struct {
 }
 
 func (m *mockTaskRepo) Create(context.Context, *domain.Task) error { return nil }
+func (m *mockTaskRepo) CreateAttachments(context.Context, []*domain.TaskAttachment) error {
+	return nil
+}
+func (m *mockTaskRepo) GetAttachment(context.Context, string) (*domain.TaskAttachment, error) {
+	return nil, nil
+}
+func (m *mockTaskRepo) DeleteAttachment(context.Context, string) error { return nil }
 func (m *mockTaskRepo) GetByID(ctx context.Context, id string) (*domain.Task, error) {
 	if m.getByIDFn != nil {
 		return m.getByIDFn(ctx, id)
@@ -30,20 +39,59 @@ func (m *mockTaskRepo) GetByID(ctx context.Context, id string) (*domain.Task, er
 func (m *mockTaskRepo) List(context.Context, repository.TaskQuery) ([]*domain.Task, int, error) {
 	return nil, 0, nil
 }
-func (m *mockTaskRepo) UpdateStatus(context.Context, string, domain.TaskStatus, *string, *string) error { return nil }
-func (m *mockTaskRepo) UpdateAssignee(context.Context, string, string, domain.TaskStatus) error         { return nil }
+func (m *mockTaskRepo) Search(context.Context, repository.TaskQuery) (*repository.TaskPage, error) {
+	return &repository.TaskPage{}, nil
+}
+func (m *mockTaskRepo) TransitionStatus(context.Context, string, domain.TaskStatus, int, domain.TaskStatus, *string, *string, *string) error {
+	return nil
+}
+func (m *mockTaskRepo) UpdateAssignee(context.Context, string, int, *string, domain.TaskStatus) error {
+	return nil
+}
+func (m *mockTaskRepo) Update(context.Context, *domain.Task, int) error            { return nil }
+func (m *mockTaskRepo) UpdateDueAt(context.Context, string, int, *time.Time) error { return nil }
+func (m *mockTaskRepo) ListDueSoon(context.Context, time.Time) ([]*domain.Task, error) {
+	return nil, nil
+}
+func (m *mockTaskRepo) ListNewlyOverdue(context.Context) ([]*domain.Task, error) { return nil, nil }
+func (m *mockTaskRepo) ListSLACandidates(context.Context, time.Time) ([]*domain.Task, error) {
+	return nil, nil
+}
+func (m *mockTaskRepo) MarkDueWarned(context.Context, string) (bool, error)        { return false, nil }
+func (m *mockTaskRepo) MarkOverdue(context.Context, string, int) (bool, error)     { return false, nil }
+func (m *mockTaskRepo) MarkSLABreached(context.Context, string, int) (bool, error) { return false, nil }
+func (m *mockTaskRepo) ListEscalationDue(context.Context, time.Time) ([]*domain.Task, error) {
+	return nil, nil
+}
+func (m *mockTaskRepo) MarkEscalated(context.Context, string, int, int) (bool, error) {
+	return false, nil
+}
+func (m *mockTaskRepo) ClearEscalation(context.Context, string) error { return nil }
+func (m *mockTaskRepo) CountOpenByAssignee(context.Context, string) (map[string]int, error) {
+	return nil, nil
+}
+func (m *mockTaskRepo) ListFinishedTagged(context.Context, string, int) ([]*domain.Task, error) {
+	return nil, nil
+}
 func (m *mockTaskRepo) UpdateTags(ctx context.Context, id string, tags domain.StringList) error {
 	if m.updateTagsFn != nil {
 		return m.updateTagsFn(ctx, id, tags)
 	}
 	return nil
 }
-func (m *mockTaskRepo) Delete(context.Context, string) error { return nil }
+func (m *mockTaskRepo) Delete(context.Context, string, []string) ([]string, error) {
+	return nil, nil
+}
+func (m *mockTaskRepo) ListDescendants(context.Context, string) ([]*domain.Task, error) {
+	return nil, nil
+}
+func (m *mockTaskRepo) LockHierarchy(context.Context, string) error { return nil }
 
 type mockCollabRepo struct {
 	addCommentFn       func(ctx context.Context, c *domain.TaskComment) error
 	listCommentsFn     func(ctx context.Context, taskID string) ([]*domain.TaskComment, error)
 	deleteCommentFn    func(ctx context.Context, id, agentID, companyID string) error
+	getCommentFn       func(ctx context.Context, id string) (*domain.TaskComment, error)
 	addDependencyFn    func(ctx context.Context, d *domain.TaskDependency) error
 	listDependencyFn   func(ctx context.Context, taskID string) ([]*domain.TaskDependency, error)
 	deleteDependencyFn func(ctx context.Context, taskID, dependsOnID string) error
@@ -70,6 +118,18 @@ func (m *mockCollabRepo) DeleteComment(ctx context.Context, id, agentID, company
 	}
 	return nil
 }
+func (m *mockCollabRepo) GetComment(ctx context.Context, id string) (*domain.TaskComment, error) {
+	if m.getCommentFn != nil {
+		return m.getCommentFn(ctx, id)
+	}
+	return nil, nil
+}
+func (m *mockCollabRepo) UpdateComment(context.Context, string, string, string, domain.StringList) error {
+	return nil
+}
+func (m *mockCollabRepo) ListCommentEdits(context.Context, string) ([]*domain.TaskCommentEdit, error) {
+	return nil, nil
+}
 func (m *mockCollabRepo) AddDependency(ctx context.Context, d *domain.TaskDependency) error {
 	if m.addDependencyFn != nil {
 		return m.addDependencyFn(ctx, d)
@@ -88,6 +148,18 @@ func (m *mockCollabRepo) DeleteDependency(ctx context.Context, taskID, dependsOn
 	}
 	return nil
 }
+func (m *mockCollabRepo) DependencyReachable(context.Context, string, string) (bool, error) {
+	return false, nil
+}
+func (m *mockCollabRepo) ListOpenBlockers(context.Context, string) ([]*domain.Task, error) {
+	return nil, nil
+}
+func (m *mockCollabRepo) ListDependents(context.Context, string) ([]*domain.Task, error) {
+	return nil, nil
+}
+func (m *mockCollabRepo) ListDependenciesAmong(context.Context, []string) ([]*domain.TaskDependency, error) {
+	return nil, nil
+}
 func (m *mockCollabRepo) AddWatcher(ctx context.Context, w *domain.TaskWatcher) error {
 	if m.addWatcherFn != nil {
 		return m.addWatcherFn(ctx, w)
@@ -106,6 +178,17 @@ func (m *mockCollabRepo) RemoveWatcher(ctx context.Context, taskID, agentID stri
 	}
 	return nil
 }
+func (m *mockCollabRepo) AddReview(context.Context, *domain.TaskReview) error { return nil }
+func (m *mockCollabRepo) ListReviews(context.Context, string) ([]*domain.TaskReview, error) {
+	return nil, nil
+}
+func (m *mockCollabRepo) ReviewStats(context.Context, string, string) (*domain.TaskReviewStats, error) {
+	return &domain.TaskReviewStats{}, nil
+}
+func (m *mockCollabRepo) AddEvents(context.Context, []*domain.TaskEvent) error { return nil }
+func (m *mockCollabRepo) ListEvents(context.Context, string) ([]*domain.TaskEvent, error) {
+	return nil, nil
+}
 
 type mockMessageRepo struct{}
 
@@ -120,6 +203,51 @@ func (m *mockMessageRepo) MarkRead(context.Context, string, []string) error { re
 func (m *mockMessageRepo) ListUnreadForAgent(context.Context, string, string) ([]*domain.Message, error) {
 	return nil, nil
 }
+func (m *mockMessageRepo) GetByID(context.Context, string) (*domain.Message, error) { return nil, nil }
+func (m *mockMessageRepo) ListThread(context.Context, string, int, string) ([]*domain.Message, error) {
+	return nil, nil
+}
+func (m *mockMessageRepo) UpdateContent(context.Context, string, string, string, domain.StringList, domain.StringList) error {
+	return nil
+}
+func (m *mockMessageRepo) ListEdits(context.Context, string) ([]*domain.MessageEdit, error) {
+	return nil, nil
+}
+func (m *mockMessageRepo) SoftDelete(context.Context, string, string) error             { return nil }
+func (m *mockMessageRepo) AddReaction(context.Context, *domain.MessageReaction) error   { return nil }
+func (m *mockMessageRepo) RemoveReaction(context.Context, string, string, string) error { return nil }
+func (m *mockMessageRepo) ListReactions(context.Context, []string) ([]*domain.MessageReaction, error) {
+	return nil, nil
+}
+func (m *mockMessageRepo) SetPinned(context.Context, string, *string) error { return nil }
+func (m *mockMessageRepo) ListPinned(context.Context, string) ([]*domain.Message, error) {
+	return nil, nil
+}
+func (m *mockMessageRepo) UpdateBlocks(context.Context, string, json.RawMessage) error { return nil }
+func (m *mockMessageRepo) CreateAttachment(context.Context, *domain.MessageAttachment) error {
+	return nil
+}
+func (m *mockMessageRepo) GetAttachment(context.Context, string) (*domain.MessageAttachment, error) {
+	return nil, nil
+}
+func (m *mockMessageRepo) LinkAttachments(context.Context, string, []string) (int64, error) {
+	return 0, nil
+}
+func (m *mockMessageRepo) DeleteUnlinkedAttachments(context.Context, time.Time) ([]string, error) {
+	return nil, nil
+}
+func (m *mockMessageRepo) Search(context.Context, repository.MessageQuery) ([]*domain.Message, error) {
+	return nil, nil
+}
+func (m *mockMessageRepo) SemanticSearch(context.Context, repository.MessageQuery, []float32) ([]*domain.Message, error) {
+	return nil, nil
+}
+func (m *mockMessageRepo) ListPendingEmbedding(context.Context, int) ([]*domain.Message, error) {
+	return nil, nil
+}
+func (m *mockMessageRepo) UpdateEmbedding(context.Context, string, []float32) error { return nil }
+func (m *mockMessageRepo) MarkEmbeddingFailed(context.Context, string) error        { return nil }
+func (m *mockMessageRepo) VectorEmbeddingSupported(context.Context) (bool, error)   { return false, nil }
 
 type mockCompanyRepo struct{}
 
@@ -142,7 +270,7 @@ func (m *mockCompanyRepo) GetChannelByName(context.Context, string, string) (*do
 }
 
 func newTaskHandler(task *mockTaskRepo, collab *mockCollabRepo) *taskHandler {
-	return &taskHandler{taskSvc: service.NewTaskService(task, collab, &mockMessageRepo{}, &mockCompanyRepo{})}
+	return &taskHandler{taskSvc: service.NewTaskService(task, collab, &mockMessageRepo{}, &mockCompanyRepo{}, &mockAgentRepo{}, nil, nil, nil)}
 }
 
 func TestTaskHandler_Detail(t *testing.T) {
@@ -235,6 +363,37 @@ func TestTaskHandler_DeleteComment(t *testing.T) {
 	}
 }
 
+func TestTaskHandler_EditComment(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		comment *domain.TaskComment
+		want    int
+	}{
+		{"success", &domain.TaskComment{ID: "comment-1", TaskID: "task-1", CompanyID: "company-1", AgentID: "agent-1", Content: "old"}, http.StatusOK},
+		{"not author", &domain.TaskComment{ID: "comment-1", TaskID: "task-1", CompanyID: "company-1", AgentID: "agent-2", Content: "old"}, http.StatusForbidden},
+		{"other company", &domain.TaskComment{ID: "comment-1", TaskID: "task-1", CompanyID: "company-2", AgentID: "agent-1", Content: "old"}, http.StatusNotFound},
+		{"not found", nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &mockTaskRepo{getByIDFn: func(context.Context, string) (*domain.Task, error) {
+				return &domain.Task{ID: "task-1", CompanyID: "company-1"}, nil
+			}}
+			collab := &mockCollabRepo{getCommentFn: func(context.Context, string) (*domain.TaskComment, error) { return tt.comment, nil }}
+			r := gin.New()
+			r.PATCH("/tasks/:id/comments/:commentId", injectAgent(&domain.Agent{ID: "agent-1", CompanyID: "company-1", RoleType: domain.RoleEmployee}), newTaskHandler(task, collab).editComment)
+			req := httptest.NewRequest(http.MethodPatch, "/tasks/task-1/comments/comment-1", strings.NewReader(`{"content":"new"}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("want %d got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
 func TestTaskHandler_AddDependency(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	tests := []struct{ name, body string; setup func(*mockTaskRepo, *mockCollabRepo); want int }{
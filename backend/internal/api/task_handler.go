@@ -1,66 +1,24 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"mime"
 	"mime/multipart"
 	"net/http"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/linkclaw/backend/internal/domain"
 	"github.com/linkclaw/backend/internal/repository"
 	"github.com/linkclaw/backend/internal/service"
+	"github.com/linkclaw/backend/internal/storage"
 )
 
-const (
-	taskAttachmentMaxFileSize       = 10 << 20 // 10MB
-	taskCreateMultipartMemory int64 = 32 << 20
-)
-
-var (
-	taskAttachmentAllowedExtensions = map[string]struct{}{
-		".jpg":    {},
-		".jpeg":   {},
-		".png":    {},
-		".gif":    {},
-		".webp":   {},
-		".bmp":    {},
-		".svg":    {},
-		".pdf":    {},
-		".doc":    {},
-		".docx":   {},
-		".xls":    {},
-		".xlsx":   {},
-		".ppt":    {},
-		".pptx":   {},
-		".txt":    {},
-		".md":     {},
-		".csv":    {},
-		".zip":    {},
-		".tar.gz": {},
-	}
-	taskAttachmentAllowedMIMETypes = map[string]struct{}{
-		"application/pdf":    {},
-		"application/msword": {},
-		"application/vnd.openxmlformats-officedocument.wordprocessingml.document": {},
-		"application/vnd.ms-excel": {},
-		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         {},
-		"application/vnd.ms-powerpoint":                                             {},
-		"application/vnd.openxmlformats-officedocument.presentationml.presentation": {},
-		"text/plain":                   {},
-		"text/markdown":                {},
-		"text/csv":                     {},
-		"application/zip":              {},
-		"application/x-zip-compressed": {},
-		"application/x-tar":            {},
-		"application/gzip":             {},
-		"application/x-gzip":           {},
-	}
-)
+const taskCreateMultipartMemory int64 = 32 << 20
 
 type taskHandler struct {
 	taskSvc *service.TaskService
@@ -69,19 +27,63 @@ type taskHandler struct {
 func (h *taskHandler) list(c *gin.Context) {
 	companyID := currentCompanyID(c)
 	q := repository.TaskQuery{
-		CompanyID: companyID,
-		Status:    domain.TaskStatus(c.Query("status")),
-		Priority:  domain.TaskPriority(c.Query("priority")),
+		CompanyID:    companyID,
+		AssigneeID:   c.Query("assignee_id"),
+		Status:       domain.TaskStatus(c.Query("status")),
+		Priority:     domain.TaskPriority(c.Query("priority")),
+		Text:         strings.TrimSpace(c.Query("q")),
+		AllTags:      c.Query("tag_mode") == "all",
+		CreatedBy:    c.Query("created_by"),
+		WatcherID:    c.Query("watcher_id"),
+		DepartmentID: c.Query("department_id"),
+		Sort:         repository.TaskSort(c.Query("sort")),
+		Order:        c.Query("order"),
+		Limit:        parseIntQuery(c, "limit", 50),
+		Offset:       parseIntQuery(c, "offset", 0),
+		Cursor:       c.Query("cursor"),
+	}
+	if q.Limit > 200 {
+		q.Limit = 200
+	}
+	for _, tag := range strings.Split(c.Query("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			q.Tags = append(q.Tags, tag)
+		}
+	}
+	for key, dst := range map[string]**time.Time{
+		"created_after":  &q.CreatedAfter,
+		"created_before": &q.CreatedBefore,
+		"updated_after":  &q.UpdatedAfter,
+		"updated_before": &q.UpdatedBefore,
+		"due_before":     &q.DueBefore,
+	} {
+		v := c.Query(key)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			if t, err = time.Parse(time.DateOnly, v); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": key + " must be RFC3339 or YYYY-MM-DD"})
+				return
+			}
+		}
+		*dst = &t
+	}
+	if q.Text != "" || len(q.Tags) > 0 {
+		q.ParentID = new(string)
 	}
-	if aid := c.Query("assignee_id"); aid != "" {
-		q.AssigneeID = aid
+
+	page, err := h.taskSvc.Search(c.Request.Context(), q)
+	if errors.Is(err, repository.ErrInvalidTaskCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	tasks, total, err := h.taskSvc.List(c.Request.Context(), q)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"data": tasks, "total": total})
+	c.JSON(http.StatusOK, gin.H{"data": page.Tasks, "total": page.Total, "next_cursor": page.NextCursor})
 }
 
 func (h *taskHandler) get(c *gin.Context) {
@@ -108,6 +110,10 @@ type createTaskRequest struct {
 	Priority    string `json:"priority"    form:"priority"`
 	AssigneeID  string `json:"assignee_id" form:"assignee_id"`
 	ParentID    string `json:"parent_id"   form:"parent_id"`
+	AutoAssign  bool   `json:"auto_assign" form:"auto_assign"`
+
+	ReviewPolicy string     `json:"review_policy" form:"review_policy"`
+	DueAt        *time.Time `json:"due_at"        form:"-"` // RFC3339
 }
 
 func (h *taskHandler) create(c *gin.Context) {
@@ -138,6 +144,10 @@ func (h *taskHandler) create(c *gin.Context) {
 		Priority:    domain.TaskPriority(req.Priority),
 		CreatedBy:   &agentID,
 		Attachments: files,
+		AutoAssign:  req.AutoAssign,
+		DueAt:       req.DueAt,
+
+		ReviewPolicy: domain.TaskReviewPolicy(req.ReviewPolicy),
 	}
 	if req.AssigneeID != "" {
 		in.AssigneeID = &req.AssigneeID
@@ -164,6 +174,9 @@ func parseCreateTaskMultipart(c *gin.Context) (createTaskRequest, []service.Task
 		Priority:    strings.TrimSpace(c.PostForm("priority")),
 		AssigneeID:  strings.TrimSpace(c.PostForm("assignee_id")),
 		ParentID:    strings.TrimSpace(c.PostForm("parent_id")),
+		AutoAssign:  c.PostForm("auto_assign") == "true",
+
+		ReviewPolicy: strings.TrimSpace(c.PostForm("review_policy")),
 	}
 	if req.AssigneeID == "" {
 		req.AssigneeID = strings.TrimSpace(c.PostForm("assignee"))
@@ -174,6 +187,13 @@ func parseCreateTaskMultipart(c *gin.Context) (createTaskRequest, []service.Task
 	if req.Title == "" {
 		return createTaskRequest{}, nil, fmt.Errorf("title is required")
 	}
+	if v := strings.TrimSpace(c.PostForm("due_at")); v != "" {
+		dueAt, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return createTaskRequest{}, nil, fmt.Errorf("invalid due_at: %w", err)
+		}
+		req.DueAt = &dueAt
+	}
 
 	files, err := parseTaskUploadFiles(c)
 	if err != nil {
@@ -205,64 +225,101 @@ func parseTaskUploadFile(header *multipart.FileHeader) (service.TaskUploadFile,
 	if header == nil {
 		return service.TaskUploadFile{}, fmt.Errorf("invalid file")
 	}
-	if header.Size > taskAttachmentMaxFileSize {
+	if header.Size > service.TaskAttachmentMaxSize {
 		return service.TaskUploadFile{}, fmt.Errorf("file %q exceeds 10MB limit", header.Filename)
 	}
 
-	contentType := strings.TrimSpace(header.Header.Get("Content-Type"))
-	if !isAllowedTaskAttachment(header.Filename, contentType) {
-		return service.TaskUploadFile{}, fmt.Errorf("file %q type is not allowed", header.Filename)
-	}
-
 	file, err := header.Open()
 	if err != nil {
 		return service.TaskUploadFile{}, fmt.Errorf("open file %q: %w", header.Filename, err)
 	}
 	defer file.Close()
 
-	data, err := io.ReadAll(io.LimitReader(file, taskAttachmentMaxFileSize+1))
+	data, err := io.ReadAll(io.LimitReader(file, service.TaskAttachmentMaxSize+1))
 	if err != nil {
 		return service.TaskUploadFile{}, fmt.Errorf("read file %q: %w", header.Filename, err)
 	}
-	if int64(len(data)) > taskAttachmentMaxFileSize {
+	if int64(len(data)) > service.TaskAttachmentMaxSize {
 		return service.TaskUploadFile{}, fmt.Errorf("file %q exceeds 10MB limit", header.Filename)
 	}
 
-	if contentType == "" {
-		contentType = mime.TypeByExtension(taskAttachmentExtension(header.Filename))
-	}
-	if contentType == "" {
-		contentType = "application/octet-stream"
-	}
-
+	// 类型校验与 MIME 补全由 service 统一处理
 	return service.TaskUploadFile{
 		OriginalFilename: header.Filename,
 		Size:             int64(len(data)),
-		MimeType:         contentType,
+		MimeType:         strings.TrimSpace(header.Header.Get("Content-Type")),
 		Content:          data,
 	}, nil
 }
 
-func isAllowedTaskAttachment(filename, mimeType string) bool {
-	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
-	if strings.HasPrefix(mimeType, "image/") {
-		return true
+// listAttachments GET /tasks/:id/attachments
+func (h *taskHandler) listAttachments(c *gin.Context) {
+	attachments, err := h.taskSvc.ListAttachments(c.Request.Context(), c.Param("id"), currentCompanyID(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
 	}
-	if _, ok := taskAttachmentAllowedMIMETypes[mimeType]; ok {
-		return true
+	c.JSON(http.StatusOK, gin.H{"data": attachments})
+}
+
+// uploadAttachments POST /tasks/:id/attachments（multipart，可含多个文件）
+func (h *taskHandler) uploadAttachments(c *gin.Context) {
+	if err := c.Request.ParseMultipartForm(taskCreateMultipartMemory); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid multipart form: " + err.Error()})
+		return
 	}
+	files, err := parseTaskUploadFiles(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	attachments, err := h.taskSvc.AddAttachments(c.Request.Context(), c.Param("id"), currentAgent(c), files)
+	if err != nil {
+		if err.Error() == "task not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": attachments})
+}
 
-	ext := taskAttachmentExtension(filename)
-	_, ok := taskAttachmentAllowedExtensions[ext]
-	return ok
+// downloadAttachment GET /tasks/:id/attachments/:attachmentId
+func (h *taskHandler) downloadAttachment(c *gin.Context) {
+	a, rc, err := h.taskSvc.OpenAttachment(c.Request.Context(), c.Param("id"), c.Param("attachmentId"), currentCompanyID(c))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) || err.Error() == "attachment not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rc.Close()
+	headers := map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": a.OriginalFilename}),
+	}
+	if a.SHA256 != "" {
+		headers["ETag"] = `"` + a.SHA256 + `"`
+		headers["X-Checksum-SHA256"] = a.SHA256
+	}
+	c.DataFromReader(http.StatusOK, a.FileSize, a.MimeType, rc, headers)
 }
 
-func taskAttachmentExtension(filename string) string {
-	lower := strings.ToLower(strings.TrimSpace(filename))
-	if strings.HasSuffix(lower, ".tar.gz") {
-		return ".tar.gz"
+// deleteAttachment DELETE /tasks/:id/attachments/:attachmentId
+func (h *taskHandler) deleteAttachment(c *gin.Context) {
+	err := h.taskSvc.DeleteAttachment(c.Request.Context(), c.Param("id"), c.Param("attachmentId"), currentAgent(c))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	case err.Error() == "attachment not found", err.Error() == "task not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err.Error() == "permission denied":
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 	}
-	return strings.ToLower(filepath.Ext(lower))
 }
 
 func (h *taskHandler) delete(c *gin.Context) {
@@ -274,8 +331,214 @@ func (h *taskHandler) delete(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
+// transitionTaskRequest 状态变更请求；Version 可选，携带时须与任务当前版本一致，否则返回 409
+type transitionTaskRequest struct {
+	Version *int   `json:"version"`
+	Force   bool   `json:"force"`  // accept：忽略未完成的前置依赖
+	Result  string `json:"result"` // submit
+	Reason  string `json:"reason"` // fail
+}
+
+func (h *taskHandler) accept(c *gin.Context) {
+	h.transition(c, func(req transitionTaskRequest, taskID, agentID string) (*domain.Task, error) {
+		return h.taskSvc.Accept(c.Request.Context(), taskID, agentID, req.Version, req.Force)
+	})
+}
+
+func (h *taskHandler) submit(c *gin.Context) {
+	h.transition(c, func(req transitionTaskRequest, taskID, agentID string) (*domain.Task, error) {
+		return h.taskSvc.Submit(c.Request.Context(), taskID, agentID, req.Version, req.Result)
+	})
+}
+
+func (h *taskHandler) fail(c *gin.Context) {
+	h.transition(c, func(req transitionTaskRequest, taskID, agentID string) (*domain.Task, error) {
+		return h.taskSvc.Fail(c.Request.Context(), taskID, agentID, req.Version, req.Reason)
+	})
+}
+
+// transition 执行状态变更，预期版本交由仓库的条件更新校验；并发修改返回 409 及当前版本
+func (h *taskHandler) transition(c *gin.Context, apply func(req transitionTaskRequest, taskID, agentID string) (*domain.Task, error)) {
+	var req transitionTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	taskID := c.Param("id")
+	t, err := h.taskSvc.GetByID(c.Request.Context(), taskID)
+	if err != nil || t == nil || t.CompanyID != currentCompanyID(c) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	updated, err := apply(req, taskID, currentAgent(c).ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTaskConflict) {
+			resp := gin.H{"error": err.Error()}
+			if cur, _ := h.taskSvc.GetByID(c.Request.Context(), taskID); cur != nil {
+				resp["version"] = cur.Version
+			}
+			c.JSON(http.StatusConflict, resp)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+type reassignTaskRequest struct {
+	AssigneeID string `json:"assignee_id" binding:"required"`
+	Reason     string `json:"reason"`
+}
+
+// reassign POST /tasks/:id/reassign
+func (h *taskHandler) reassign(c *gin.Context) {
+	var req reassignTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	task, err := h.taskSvc.Reassign(c.Request.Context(), c.Param("id"), currentAgent(c), req.AssigneeID, req.Reason)
+	respondTaskChange(c, task, err)
+}
+
+type unassignTaskRequest struct {
+	Reason string `json:"reason"`
+}
+
+// unassign POST /tasks/:id/unassign
+func (h *taskHandler) unassign(c *gin.Context) {
+	var req unassignTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	task, err := h.taskSvc.Unassign(c.Request.Context(), c.Param("id"), currentAgent(c), req.Reason)
+	respondTaskChange(c, task, err)
+}
+
+// claim POST /tasks/:id/claim
+func (h *taskHandler) claim(c *gin.Context) {
+	task, err := h.taskSvc.Claim(c.Request.Context(), c.Param("id"), currentAgent(c))
+	respondTaskChange(c, task, err)
+}
+
+// assigneeSuggestions GET /tasks/:id/assignee-suggestions — 自动分配候选人评分
+func (h *taskHandler) assigneeSuggestions(c *gin.Context) {
+	task, err := h.taskSvc.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil || task == nil || task.CompanyID != currentCompanyID(c) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	candidates, err := h.taskSvc.SuggestAssignees(c.Request.Context(), task)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": candidates})
+}
+
+// autoAssign POST /tasks/:id/auto-assign
+func (h *taskHandler) autoAssign(c *gin.Context) {
+	task, err := h.taskSvc.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil || task == nil || task.CompanyID != currentCompanyID(c) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	picked, err := h.taskSvc.AutoAssign(c.Request.Context(), task, currentAgent(c))
+	if err != nil {
+		respondTaskChange(c, nil, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"task": task, "assignee": picked})
+}
+
+type reviewTaskRequest struct {
+	Comment string `json:"comment"`
+}
+
+// approve POST /tasks/:id/approve — 验收通过
+func (h *taskHandler) approve(c *gin.Context) {
+	var req reviewTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	task, err := h.taskSvc.Approve(c.Request.Context(), c.Param("id"), currentAgent(c), req.Comment)
+	respondTaskChange(c, task, err)
+}
+
+// requestChanges POST /tasks/:id/request-changes — 验收不通过，退回修改
+func (h *taskHandler) requestChanges(c *gin.Context) {
+	var req reviewTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	task, err := h.taskSvc.RequestChanges(c.Request.Context(), c.Param("id"), currentAgent(c), req.Comment)
+	respondTaskChange(c, task, err)
+}
+
+func respondTaskChange(c *gin.Context, task *domain.Task, err error) {
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, task)
+	case errors.Is(err, repository.ErrTaskConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err.Error() == "task not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err.Error() == "permission denied", err.Error() == "you are not the reviewer of this task":
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+type updateTaskRequest struct {
+	Version     *int                 `json:"version"`
+	Title       *string              `json:"title"`
+	Description *string              `json:"description"`
+	Priority    *domain.TaskPriority `json:"priority"`
+	ParentID    *string              `json:"parent_id"` // 空串表示移到顶层
+	Tags        *domain.StringList   `json:"tags"`
+}
+
+// update PATCH /tasks/:id
+func (h *taskHandler) update(c *gin.Context) {
+	var req updateTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	task, err := h.taskSvc.Update(c.Request.Context(), c.Param("id"), currentAgent(c), service.UpdateTaskInput{
+		Version:     req.Version,
+		Title:       req.Title,
+		Description: req.Description,
+		Priority:    req.Priority,
+		ParentID:    req.ParentID,
+		Tags:        req.Tags,
+	})
+	respondTaskChange(c, task, err)
+}
+
+type setTaskDueRequest struct {
+	DueAt *time.Time `json:"due_at"` // RFC3339；null 表示清除截止时间
+}
+
+// setDue PUT /tasks/:id/due
+func (h *taskHandler) setDue(c *gin.Context) {
+	var req setTaskDueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	task, err := h.taskSvc.SetDueAt(c.Request.Context(), c.Param("id"), currentAgent(c), req.DueAt)
+	respondTaskChange(c, task, err)
+}
+
 type addTaskCommentRequest struct {
-	Content string `json:"content" binding:"required"`
+	Content         string  `json:"content" binding:"required"`
+	ParentCommentID *string `json:"parent_comment_id"`
 }
 
 func (h *taskHandler) addComment(c *gin.Context) {
@@ -285,7 +548,7 @@ func (h *taskHandler) addComment(c *gin.Context) {
 		return
 	}
 	agent := currentAgent(c)
-	comment, err := h.taskSvc.AddComment(c.Request.Context(), c.Param("id"), agent.ID, req.Content)
+	comment, err := h.taskSvc.AddComment(c.Request.Context(), c.Param("id"), agent.ID, req.Content, req.ParentCommentID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -293,6 +556,40 @@ func (h *taskHandler) addComment(c *gin.Context) {
 	c.JSON(http.StatusCreated, comment)
 }
 
+type editTaskCommentRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// editComment PATCH /tasks/:id/comments/:commentId（仅作者本人，保留编辑历史）
+func (h *taskHandler) editComment(c *gin.Context) {
+	var req editTaskCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	comment, err := h.taskSvc.EditComment(c.Request.Context(), c.Param("commentId"), currentAgent(c), req.Content)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, comment)
+	case err.Error() == "comment not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err.Error() == "permission denied":
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// listCommentEdits GET /tasks/:id/comments/:commentId/edits
+func (h *taskHandler) listCommentEdits(c *gin.Context) {
+	edits, err := h.taskSvc.ListCommentEdits(c.Request.Context(), c.Param("commentId"), currentCompanyID(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": edits})
+}
+
 func (h *taskHandler) deleteComment(c *gin.Context) {
 	agent := currentAgent(c)
 	if err := h.taskSvc.DeleteComment(c.Request.Context(), c.Param("commentId"), agent.ID, agent.CompanyID); err != nil {
@@ -302,6 +599,20 @@ func (h *taskHandler) deleteComment(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
+// dag GET /tasks/:id/dag — 父任务及其子任务的依赖图、拓扑顺序与关键路径
+func (h *taskHandler) dag(c *gin.Context) {
+	dag, err := h.taskSvc.GetDAG(c.Request.Context(), currentCompanyID(c), c.Param("id"))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, dag)
+}
+
 type addTaskDependencyRequest struct {
 	DependsOnID string `json:"depends_on_id" binding:"required"`
 }
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/service"
+)
+
+type taskTemplateHandler struct {
+	templateSvc *service.TaskTemplateService
+}
+
+type taskTemplateRequest struct {
+	Name        string                    `json:"name" binding:"required"`
+	Description string                    `json:"description"`
+	Params      domain.TaskTemplateParams `json:"params"`
+	Spec        domain.TaskTemplateNode   `json:"spec"`
+}
+
+func (r taskTemplateRequest) toTemplate() *domain.TaskTemplate {
+	return &domain.TaskTemplate{Name: r.Name, Description: r.Description, Params: r.Params, Spec: r.Spec}
+}
+
+func (h *taskTemplateHandler) list(c *gin.Context) {
+	list, err := h.templateSvc.ListTemplates(c.Request.Context(), currentCompanyID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+func (h *taskTemplateHandler) get(c *gin.Context) {
+	t, err := h.templateSvc.GetTemplate(c.Request.Context(), currentCompanyID(c), c.Param("id"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+func (h *taskTemplateHandler) create(c *gin.Context) {
+	var req taskTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	t := req.toTemplate()
+	if err := h.templateSvc.CreateTemplate(c.Request.Context(), currentAgent(c), t); err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, t)
+}
+
+func (h *taskTemplateHandler) update(c *gin.Context) {
+	var req taskTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	t, err := h.templateSvc.UpdateTemplate(c.Request.Context(), currentAgent(c), c.Param("id"), req.toTemplate())
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+func (h *taskTemplateHandler) delete(c *gin.Context) {
+	if err := h.templateSvc.DeleteTemplate(c.Request.Context(), currentAgent(c), c.Param("id")); err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+type instantiateTemplateRequest struct {
+	Params map[string]string `json:"params"`
+}
+
+// instantiate POST /task-templates/:id/instantiate — 按模板创建任务树，返回根任务（含子任务）
+func (h *taskTemplateHandler) instantiate(c *gin.Context) {
+	var req instantiateTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	task, err := h.templateSvc.Instantiate(c.Request.Context(), c.Param("id"), currentAgent(c), req.Params)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, task)
+}
+
+func (h *taskTemplateHandler) listRecurrences(c *gin.Context) {
+	list, err := h.templateSvc.ListRecurrences(c.Request.Context(), currentCompanyID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+func (h *taskTemplateHandler) createRecurrence(c *gin.Context) {
+	var req service.TaskRecurrenceInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	r, err := h.templateSvc.CreateRecurrence(c.Request.Context(), currentAgent(c), c.Param("id"), req)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, r)
+}
+
+func (h *taskTemplateHandler) updateRecurrence(c *gin.Context) {
+	var req service.TaskRecurrenceInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	r, err := h.templateSvc.UpdateRecurrence(c.Request.Context(), currentAgent(c), c.Param("id"), req)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+func (h *taskTemplateHandler) deleteRecurrence(c *gin.Context) {
+	if err := h.templateSvc.DeleteRecurrence(c.Request.Context(), currentAgent(c), c.Param("id")); err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (h *taskTemplateHandler) writeError(c *gin.Context, err error) {
+	msg := err.Error()
+	switch {
+	case strings.HasSuffix(msg, "not found"):
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+	case msg == "permission denied":
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+	case strings.Contains(msg, "already exists"):
+		c.JSON(http.StatusConflict, gin.H{"error": msg})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+	}
+}
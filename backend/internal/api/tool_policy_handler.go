@@ -0,0 +1,169 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/mcp"
+	"github.com/linkclaw/backend/internal/repository"
+	"github.com/linkclaw/backend/internal/service"
+)
+
+type toolPolicyHandler struct {
+	policySvc *service.ToolPolicyService
+	fedSvc    *service.McpFederationService
+	agentRepo repository.AgentRepo
+}
+
+type toolPolicyRequest struct {
+	Name        string                       `json:"name" binding:"required"`
+	Description string                       `json:"description"`
+	SubjectType domain.ToolPolicySubjectType `json:"subject_type" binding:"required"`
+	SubjectID   string                       `json:"subject_id"`
+	Tool        string                       `json:"tool" binding:"required"`
+	Effect      domain.ToolPolicyEffect      `json:"effect" binding:"required"`
+	Conditions  domain.ToolArgConditions     `json:"conditions"`
+	IsActive    *bool                        `json:"is_active"`
+}
+
+func (r toolPolicyRequest) toInput() service.ToolPolicyInput {
+	return service.ToolPolicyInput{
+		Name:        r.Name,
+		Description: r.Description,
+		SubjectType: r.SubjectType,
+		SubjectID:   r.SubjectID,
+		Tool:        r.Tool,
+		Effect:      r.Effect,
+		Conditions:  r.Conditions,
+		IsActive:    r.IsActive,
+	}
+}
+
+func (h *toolPolicyHandler) list(c *gin.Context) {
+	list, err := h.policySvc.List(c.Request.Context(), currentCompanyID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+func (h *toolPolicyHandler) get(c *gin.Context) {
+	p, err := h.policySvc.Get(c.Request.Context(), currentCompanyID(c), c.Param("id"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func (h *toolPolicyHandler) create(c *gin.Context) {
+	var req toolPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p, err := h.policySvc.Create(c.Request.Context(), currentCompanyID(c), currentAgent(c).ID, req.toInput())
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, p)
+}
+
+func (h *toolPolicyHandler) update(c *gin.Context) {
+	var req toolPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p, err := h.policySvc.Update(c.Request.Context(), currentCompanyID(c), currentAgent(c).ID, c.Param("id"), req.toInput())
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func (h *toolPolicyHandler) delete(c *gin.Context) {
+	if err := h.policySvc.Delete(c.Request.Context(), currentCompanyID(c), currentAgent(c).ID, c.Param("id")); err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// listChanges GET /tool-policies/changes?policy_id=&limit=&offset=
+func (h *toolPolicyHandler) listChanges(c *gin.Context) {
+	list, total, err := h.policySvc.ListChanges(c.Request.Context(), currentCompanyID(c),
+		c.Query("policy_id"), parseIntQuery(c, "limit", 50), parseIntQuery(c, "offset", 0))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list, "total": total})
+}
+
+type evaluateToolPolicyRequest struct {
+	AgentID   string          `json:"agent_id" binding:"required"`
+	Tool      string          `json:"tool" binding:"required"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// evaluate POST /tool-policies/evaluate — 试运行：给定 Agent、工具和参数，返回评估结果
+func (h *toolPolicyHandler) evaluate(c *gin.Context) {
+	var req evaluateToolPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	companyID := currentCompanyID(c)
+	agent, err := h.agentRepo.GetByID(ctx, req.AgentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if agent == nil || agent.CompanyID != companyID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+		return
+	}
+	policies, err := h.policySvc.PoliciesFor(ctx, companyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// 联邦工具（<upstream>__<tool>）按上游的 required_perm 计算基线
+	var extra []mcp.ToolDef
+	if h.fedSvc != nil {
+		u, _, err := h.fedSvc.Resolve(ctx, companyID, req.Tool)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if u != nil {
+			extra = append(extra, mcp.UpstreamToolDef(u, req.Tool))
+		}
+	}
+	decision := mcp.CheckToolPermission(agent, policies, req.Tool, req.Arguments, extra)
+	c.JSON(http.StatusOK, gin.H{
+		"decision": decision,
+		"baseline": mcp.BaselineToolPermission(agent, req.Tool, extra),
+	})
+}
+
+func (h *toolPolicyHandler) writeError(c *gin.Context, err error) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+	case strings.Contains(msg, "forbidden"):
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+	}
+}
@@ -13,8 +13,14 @@ type Config struct {
 	JWT         JWTConfig
 	LLM         LLMConfig
 	Agent       AgentConfig
-	Context     ContextConfig      // 上下文搜索配置
-	ResetSecret string // 管理员密码重置密钥，从 RESET_SECRET 读取
+	Context     ContextConfig  // 上下文搜索配置
+	MCP         MCPConfig      // MCP 工具调用限流
+	Task        TaskConfig     // 任务截止时间与 SLA
+	Message     MessageConfig  // AI 之间对话的预算与循环熔断
+	Presence    PresenceConfig // 在线状态心跳与空闲检测
+	Digest      DigestConfig   // 人类用户的每日摘要
+	Storage     StorageConfig  // 附件存储
+	ResetSecret string         // 管理员密码重置密钥，从 RESET_SECRET 读取
 }
 
 // ContextConfig 上下文搜索配置
@@ -32,6 +38,63 @@ type ContextConfig struct {
 	MaxSearchTimeoutMs    int     // 最大允许超时 (默认 120000ms)
 }
 
+// MCPConfig MCP 工具调用限流与循环检测配置
+type MCPConfig struct {
+	AgentCallsPerMinute int    // 每个 Agent 每分钟工具调用上限 (默认 120)
+	ToolRateLimits      string // 按工具覆盖每分钟上限，如 "send_message=30,create_task=20"
+	LoopWindowSec       int    // 循环检测窗口 (默认 60s)
+	LoopThreshold       int    // 窗口内相同调用次数阈值 (默认 5)
+	ToolLoopThresholds  string // 按工具覆盖循环阈值，如 "send_message=3"
+	LoopCooldownSec     int    // 判定循环后该工具冷却时间 (默认 120s)
+}
+
+// TaskConfig 任务截止提醒与 SLA 超时升级配置
+type TaskConfig struct {
+	SLACheckIntervalSec int    // 扫描间隔 (默认 60s)
+	DueWarningMinutes   int    // 截止前多少分钟提醒负责人 (默认 120)
+	AssignedSLA         string // 各优先级在 assigned 状态的最长停留分钟数，如 "urgent=30,high=120"
+	InProgressSLA       string // 各优先级在 in_progress 状态的最长停留分钟数
+	EscalationMinutes   int    // 升级后仍未处理时，每隔多少分钟再向上升级一级 (默认 240，0 关闭)
+}
+
+// MessageConfig AI 之间对话的预算与循环熔断配置
+type MessageConfig struct {
+	GovernorWindowMin int // 统计窗口 (默认 60 分钟)
+	MaxPairTurns      int // 两个 AI 之间私信在窗口内的最大轮数 (默认 20)
+	MaxChannelTurns   int // 频道内无人类发言时窗口内 AI 发言的最大轮数 (默认 40)
+	PingPongTurns     int // 两个 AI 连续交替发言达到此轮数视为乒乓循环 (默认 10)
+	PingPongGapSec    int // 相邻两轮间隔不超过此秒数才计入乒乓 (默认 180s)
+	CooldownMin       int // 熔断后暂停时长，人类发言可提前恢复 (默认 30 分钟)
+}
+
+// PresenceConfig 在线状态：连接心跳过期视为断线，长时间无操作视为空闲
+type PresenceConfig struct {
+	HeartbeatTTLSec  int // 连接心跳过期时间 (默认 90s)，超过未续期的连接不再计入在线
+	IdleAfterMin     int // 无操作多久后转为 idle (默认 10 分钟)
+	SweepIntervalSec int // 过期连接与空闲检查间隔 (默认 30s)
+}
+
+// DigestConfig 每日摘要：人类用户默认订阅，可在个人设置中修改时间、范围与投递方式
+type DigestConfig struct {
+	Enabled         bool   // 是否运行摘要定时任务 (默认 true)
+	DefaultCron     string // 新用户的默认发送时间 (默认每天 9 点)
+	DefaultTimezone string // 新用户的默认时区 (默认 UTC)
+	MaxMessages     int    // 单次摘要最多纳入的消息数 (默认 300)，超出时取最新的
+	PollIntervalSec int    // 到期检查间隔 (默认 60s)
+}
+
+// StorageConfig 附件存储配置；S3 后端兼容 MinIO 等自建服务
+type StorageConfig struct {
+	Backend     string // local（默认）/ s3
+	LocalDir    string // 本地存储根目录 (默认 /uploads)
+	S3Endpoint  string // 如 http://minio:9000
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3PathStyle bool // MinIO 需开启 (默认 true)
+}
+
 // AgentConfig 跨公司通信配置
 // 注意：CompanySlug 用于启动时识别当前实例代表的公司， PartnerAPIKey 已废弃，改用数据库中的配对密钥
 type AgentConfig struct {
@@ -125,6 +188,51 @@ func Load() *Config {
 			AgentSearchTimeoutMs:  getEnvInt("CONTEXT_AGENT_SEARCH_TIMEOUT_MS", 60000),
 			MaxSearchTimeoutMs:    getEnvInt("CONTEXT_MAX_SEARCH_TIMEOUT_MS", 120000),
 		},
+		MCP: MCPConfig{
+			AgentCallsPerMinute: getEnvInt("MCP_AGENT_CALLS_PER_MINUTE", 120),
+			ToolRateLimits:      getEnv("MCP_TOOL_RATE_LIMITS", "send_message=30,create_task=20"),
+			LoopWindowSec:       getEnvInt("MCP_LOOP_WINDOW_SEC", 60),
+			LoopThreshold:       getEnvInt("MCP_LOOP_THRESHOLD", 5),
+			ToolLoopThresholds:  getEnv("MCP_TOOL_LOOP_THRESHOLDS", ""),
+			LoopCooldownSec:     getEnvInt("MCP_LOOP_COOLDOWN_SEC", 120),
+		},
+		Task: TaskConfig{
+			SLACheckIntervalSec: getEnvInt("TASK_SLA_CHECK_INTERVAL_SEC", 60),
+			DueWarningMinutes:   getEnvInt("TASK_DUE_WARNING_MINUTES", 120),
+			AssignedSLA:         getEnv("TASK_SLA_ASSIGNED", "urgent=30,high=120,medium=480,low=1440"),
+			InProgressSLA:       getEnv("TASK_SLA_IN_PROGRESS", "urgent=240,high=1440,medium=4320,low=10080"),
+			EscalationMinutes:   getEnvInt("TASK_ESCALATION_INTERVAL_MINUTES", 240),
+		},
+		Message: MessageConfig{
+			GovernorWindowMin: getEnvInt("MSG_GOVERNOR_WINDOW_MIN", 60),
+			MaxPairTurns:      getEnvInt("MSG_MAX_AI_PAIR_TURNS", 20),
+			MaxChannelTurns:   getEnvInt("MSG_MAX_AI_CHANNEL_TURNS", 40),
+			PingPongTurns:     getEnvInt("MSG_PINGPONG_TURNS", 10),
+			PingPongGapSec:    getEnvInt("MSG_PINGPONG_GAP_SEC", 180),
+			CooldownMin:       getEnvInt("MSG_GOVERNOR_COOLDOWN_MIN", 30),
+		},
+		Presence: PresenceConfig{
+			HeartbeatTTLSec:  getEnvInt("PRESENCE_HEARTBEAT_TTL_SEC", 90),
+			IdleAfterMin:     getEnvInt("PRESENCE_IDLE_AFTER_MIN", 10),
+			SweepIntervalSec: getEnvInt("PRESENCE_SWEEP_INTERVAL_SEC", 30),
+		},
+		Digest: DigestConfig{
+			Enabled:         getEnvBool("DIGEST_ENABLED", true),
+			DefaultCron:     getEnv("DIGEST_DEFAULT_CRON", "0 9 * * *"),
+			DefaultTimezone: getEnv("DIGEST_DEFAULT_TIMEZONE", "UTC"),
+			MaxMessages:     getEnvInt("DIGEST_MAX_MESSAGES", 300),
+			PollIntervalSec: getEnvInt("DIGEST_POLL_INTERVAL_SEC", 60),
+		},
+		Storage: StorageConfig{
+			Backend:     getEnv("STORAGE_BACKEND", "local"),
+			LocalDir:    getEnv("STORAGE_LOCAL_DIR", "/uploads"),
+			S3Endpoint:  getEnv("STORAGE_S3_ENDPOINT", ""),
+			S3Region:    getEnv("STORAGE_S3_REGION", "us-east-1"),
+			S3Bucket:    getEnv("STORAGE_S3_BUCKET", ""),
+			S3AccessKey: getEnv("STORAGE_S3_ACCESS_KEY", ""),
+			S3SecretKey: getEnv("STORAGE_S3_SECRET_KEY", ""),
+			S3PathStyle: getEnvBool("STORAGE_S3_PATH_STYLE", true),
+		},
 		ResetSecret: getEnv("RESET_SECRET", ""),
 	}
 }
@@ -0,0 +1,34 @@
+-- 028: 声明式 MCP 工具权限策略 + 策略变更审计
+
+CREATE TABLE IF NOT EXISTS tool_policies (
+    id           VARCHAR(36) PRIMARY KEY DEFAULT uuid_generate_v4(),
+    company_id   VARCHAR(36) NOT NULL,
+    name         VARCHAR(100) NOT NULL,
+    description  TEXT NOT NULL DEFAULT '',
+    subject_type VARCHAR(20) NOT NULL CHECK (subject_type IN ('company','role_type','department','position','agent')),
+    subject_id   VARCHAR(100) NOT NULL DEFAULT '',
+    tool         VARCHAR(100) NOT NULL,
+    effect       VARCHAR(20) NOT NULL CHECK (effect IN ('allow','deny')),
+    conditions   JSONB NOT NULL DEFAULT '[]'::jsonb,
+    is_active    BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by   VARCHAR(36),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS tool_policies_company_id_idx ON tool_policies(company_id);
+CREATE INDEX IF NOT EXISTS tool_policies_subject_idx ON tool_policies(company_id, subject_type, subject_id);
+
+CREATE TABLE IF NOT EXISTS tool_policy_changes (
+    id         VARCHAR(36) PRIMARY KEY DEFAULT uuid_generate_v4(),
+    company_id VARCHAR(36) NOT NULL,
+    policy_id  VARCHAR(36) NOT NULL,
+    actor_id   VARCHAR(36),
+    action     VARCHAR(20) NOT NULL CHECK (action IN ('create','update','delete')),
+    before     JSONB,
+    after      JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS tool_policy_changes_company_id_idx ON tool_policy_changes(company_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS tool_policy_changes_policy_id_idx ON tool_policy_changes(policy_id);
@@ -0,0 +1,25 @@
+-- 029: 工具调用审批闸门（require_approval 策略 + 待审批调用）
+
+ALTER TABLE tool_policies DROP CONSTRAINT IF EXISTS tool_policies_effect_check;
+ALTER TABLE tool_policies ADD CONSTRAINT tool_policies_effect_check
+    CHECK (effect IN ('allow','deny','require_approval'));
+
+CREATE TABLE IF NOT EXISTS tool_call_requests (
+    id          VARCHAR(36) PRIMARY KEY DEFAULT uuid_generate_v4(),
+    company_id  VARCHAR(36) NOT NULL,
+    agent_id    VARCHAR(36) NOT NULL,
+    tool        VARCHAR(100) NOT NULL,
+    arguments   JSONB NOT NULL DEFAULT '{}'::jsonb,
+    policy_id   VARCHAR(36),
+    approval_id VARCHAR(36),
+    status      VARCHAR(20) NOT NULL DEFAULT 'pending'
+                CHECK (status IN ('pending','running','succeeded','failed','rejected')),
+    result      JSONB,
+    is_error    BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    decided_at  TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS tool_call_requests_agent_id_idx ON tool_call_requests(agent_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS tool_call_requests_approval_id_idx ON tool_call_requests(approval_id);
@@ -0,0 +1,42 @@
+-- 030: 外部 MCP Server 联邦（上游注册 + 加密凭证）
+
+CREATE TABLE IF NOT EXISTS mcp_upstreams (
+    id            VARCHAR(36) PRIMARY KEY DEFAULT uuid_generate_v4(),
+    company_id    VARCHAR(36) NOT NULL,
+    name          VARCHAR(32) NOT NULL,
+    description   TEXT NOT NULL DEFAULT '',
+    transport     VARCHAR(10) NOT NULL CHECK (transport IN ('http','stdio')),
+    url           VARCHAR(500) NOT NULL DEFAULT '',
+    image         VARCHAR(300) NOT NULL DEFAULT '',
+    command       VARCHAR(300) NOT NULL DEFAULT '',
+    args          TEXT NOT NULL DEFAULT '[]',
+    auth_header   VARCHAR(100) NOT NULL DEFAULT '',
+    auth_scheme   VARCHAR(50) NOT NULL DEFAULT '',
+    auth_env      VARCHAR(100) NOT NULL DEFAULT '',
+    required_perm VARCHAR(50) NOT NULL DEFAULT '',
+    is_active     BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (company_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS mcp_upstreams_company_id_idx ON mcp_upstreams(company_id);
+
+CREATE TABLE IF NOT EXISTS mcp_upstream_credentials (
+    id          VARCHAR(36) PRIMARY KEY DEFAULT uuid_generate_v4(),
+    company_id  VARCHAR(36) NOT NULL,
+    upstream_id VARCHAR(36) NOT NULL,
+    agent_id    VARCHAR(36),
+    secret_enc  TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- 每个上游最多一条公司级凭证 + 每个 Agent 一条
+CREATE UNIQUE INDEX IF NOT EXISTS mcp_upstream_credentials_owner_idx
+    ON mcp_upstream_credentials(upstream_id, COALESCE(agent_id, ''));
+
+-- 审计：代理调用上游工具
+ALTER TABLE audit_logs DROP CONSTRAINT IF EXISTS audit_logs_action_check;
+ALTER TABLE audit_logs ADD CONSTRAINT audit_logs_action_check
+    CHECK (action IN ('create','read','update','delete','export','login','logout','partner_api_call','mcp_tool_call'));
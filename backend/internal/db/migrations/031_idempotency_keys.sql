@@ -0,0 +1,16 @@
+-- 031: 幂等键（MCP 写操作工具 + REST POST），缓存首次结果供重试复用
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    agent_id     VARCHAR(36)  NOT NULL,
+    scope        VARCHAR(10)  NOT NULL CHECK (scope IN ('mcp','rest')),
+    key          VARCHAR(200) NOT NULL,
+    request_hash VARCHAR(64)  NOT NULL,
+    status       VARCHAR(10)  NOT NULL DEFAULT 'pending' CHECK (status IN ('pending','done')),
+    status_code  INT          NOT NULL DEFAULT 0,
+    response     TEXT         NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    expires_at   TIMESTAMPTZ  NOT NULL,
+    PRIMARY KEY (agent_id, scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys(expires_at);
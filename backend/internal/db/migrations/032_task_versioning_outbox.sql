@@ -0,0 +1,14 @@
+-- 032: 任务乐观锁（version）+ 事件 outbox（事务提交后再发布事件）
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS event_outbox (
+    id           VARCHAR(36) PRIMARY KEY DEFAULT uuid_generate_v4(),
+    event_type   VARCHAR(64) NOT NULL,
+    payload      JSONB NOT NULL DEFAULT '{}',
+    attempts     INT NOT NULL DEFAULT 0,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    published_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS event_outbox_pending_idx ON event_outbox(created_at) WHERE published_at IS NULL;
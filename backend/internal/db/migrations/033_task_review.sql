@@ -0,0 +1,25 @@
+-- 033: 任务验收阶段（in_review）、验收人策略与验收记录
+
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_status_check;
+ALTER TABLE tasks ADD CONSTRAINT tasks_status_check
+    CHECK (status IN ('pending', 'assigned', 'in_progress', 'in_review', 'done', 'failed', 'cancelled'));
+
+ALTER TABLE tasks
+  ADD COLUMN IF NOT EXISTS review_policy VARCHAR(16) NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS reviewer_id UUID;
+
+CREATE INDEX IF NOT EXISTS tasks_reviewer_id_idx ON tasks(reviewer_id) WHERE status = 'in_review';
+
+CREATE TABLE IF NOT EXISTS task_reviews (
+    id          VARCHAR(36) PRIMARY KEY DEFAULT uuid_generate_v4(),
+    task_id     VARCHAR(36) NOT NULL,
+    company_id  VARCHAR(36) NOT NULL,
+    reviewer_id VARCHAR(36) NOT NULL,
+    assignee_id VARCHAR(36) NOT NULL,
+    outcome     VARCHAR(24) NOT NULL CHECK (outcome IN ('approved', 'changes_requested')),
+    comment     TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS task_reviews_task_id_idx ON task_reviews(task_id, created_at);
+CREATE INDEX IF NOT EXISTS task_reviews_assignee_id_idx ON task_reviews(company_id, assignee_id);
@@ -0,0 +1,15 @@
+-- 034: 任务截止提醒、逾期标记与 SLA 超时升级
+
+ALTER TABLE tasks
+  ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  ADD COLUMN IF NOT EXISTS due_warned_at     TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS overdue_at        TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS sla_breached_at   TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS escalation_level  INT NOT NULL DEFAULT 0;
+
+UPDATE tasks SET status_changed_at = updated_at;
+
+CREATE INDEX IF NOT EXISTS tasks_due_at_open_idx
+    ON tasks(due_at) WHERE due_at IS NOT NULL AND status NOT IN ('done', 'failed', 'cancelled');
+CREATE INDEX IF NOT EXISTS tasks_sla_open_idx
+    ON tasks(status_changed_at) WHERE status IN ('assigned', 'in_progress') AND sla_breached_at IS NULL;
@@ -0,0 +1,34 @@
+-- 035: 任务模板（参数化任务树）与周期性实例化规则
+
+CREATE TABLE IF NOT EXISTS task_templates (
+    id          VARCHAR(36) PRIMARY KEY DEFAULT uuid_generate_v4(),
+    company_id  VARCHAR(36) NOT NULL,
+    name        VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    params      JSONB NOT NULL DEFAULT '[]'::jsonb,
+    spec        JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_by  VARCHAR(36),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (company_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS task_recurrences (
+    id           VARCHAR(36) PRIMARY KEY DEFAULT uuid_generate_v4(),
+    company_id   VARCHAR(36) NOT NULL,
+    template_id  VARCHAR(36) NOT NULL,
+    cron         VARCHAR(100) NOT NULL,
+    timezone     VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    params       JSONB NOT NULL DEFAULT '{}'::jsonb,
+    enabled      BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at  TIMESTAMPTZ,
+    last_run_at  TIMESTAMPTZ,
+    last_task_id VARCHAR(36),
+    last_error   TEXT NOT NULL DEFAULT '',
+    created_by   VARCHAR(36) NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS task_recurrences_template_id_idx ON task_recurrences(template_id);
+CREATE INDEX IF NOT EXISTS task_recurrences_due_idx ON task_recurrences(next_run_at) WHERE enabled;
@@ -0,0 +1,14 @@
+-- 036: 任务字段级变更记录（动态时间线）
+
+CREATE TABLE IF NOT EXISTS task_events (
+    id         VARCHAR(36) PRIMARY KEY DEFAULT uuid_generate_v4(),
+    task_id    VARCHAR(36) NOT NULL,
+    company_id VARCHAR(36) NOT NULL,
+    actor_id   VARCHAR(36),
+    field      VARCHAR(32) NOT NULL,
+    old_value  TEXT NOT NULL DEFAULT '',
+    new_value  TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS task_events_task_id_idx ON task_events(task_id, created_at);
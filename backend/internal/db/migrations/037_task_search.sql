@@ -0,0 +1,46 @@
+-- 037: 任务全文搜索（标题、描述、结果、标签与评论）
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS search_vec TSVECTOR;
+ALTER TABLE task_comments ADD COLUMN IF NOT EXISTS search_vec TSVECTOR;
+
+CREATE OR REPLACE FUNCTION tasks_search_vec_update()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vec := to_tsvector('simple',
+        COALESCE(NEW.title, '') || ' ' ||
+        COALESCE(NEW.tags, '') || ' ' ||
+        COALESCE(NEW.description, '') || ' ' ||
+        COALESCE(NEW.result, '')
+    );
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS tasks_tsvectorupdate ON tasks;
+CREATE TRIGGER tasks_tsvectorupdate
+    BEFORE INSERT OR UPDATE OF title, tags, description, result ON tasks
+    FOR EACH ROW EXECUTE FUNCTION tasks_search_vec_update();
+
+CREATE OR REPLACE FUNCTION task_comments_search_vec_update()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vec := to_tsvector('simple', COALESCE(NEW.content, ''));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS task_comments_tsvectorupdate ON task_comments;
+CREATE TRIGGER task_comments_tsvectorupdate
+    BEFORE INSERT OR UPDATE OF content ON task_comments
+    FOR EACH ROW EXECUTE FUNCTION task_comments_search_vec_update();
+
+-- 回填已有数据
+UPDATE tasks SET search_vec = to_tsvector('simple',
+    COALESCE(title, '') || ' ' || COALESCE(tags, '') || ' ' ||
+    COALESCE(description, '') || ' ' || COALESCE(result, ''))
+WHERE search_vec IS NULL;
+UPDATE task_comments SET search_vec = to_tsvector('simple', COALESCE(content, ''))
+WHERE search_vec IS NULL;
+
+CREATE INDEX IF NOT EXISTS tasks_search_idx ON tasks USING GIN(search_vec);
+CREATE INDEX IF NOT EXISTS task_comments_search_idx ON task_comments USING GIN(search_vec);
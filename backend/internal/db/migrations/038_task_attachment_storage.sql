@@ -0,0 +1,7 @@
+-- 038: 任务附件走存储抽象（本地 / S3），记录校验和
+
+ALTER TABLE task_attachments ADD COLUMN IF NOT EXISTS sha256 VARCHAR(64) NOT NULL DEFAULT '';
+
+-- storage_path 改为相对存储根目录的 key（原为 /uploads/tasks/...）
+UPDATE task_attachments SET storage_path = substring(storage_path FROM 10)
+WHERE storage_path LIKE '/uploads/%';
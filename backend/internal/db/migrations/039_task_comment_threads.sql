@@ -0,0 +1,20 @@
+-- 039: 任务评论回复、@提及与编辑历史
+
+ALTER TABLE task_comments
+  ADD COLUMN IF NOT EXISTS parent_comment_id VARCHAR(36),
+  ADD COLUMN IF NOT EXISTS mentions TEXT NOT NULL DEFAULT '[]',
+  ADD COLUMN IF NOT EXISTS edited_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS task_comments_parent_id_idx
+    ON task_comments(parent_comment_id) WHERE parent_comment_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS task_comment_edits (
+    id          VARCHAR(36) PRIMARY KEY DEFAULT uuid_generate_v4(),
+    comment_id  VARCHAR(36) NOT NULL,
+    company_id  VARCHAR(36) NOT NULL,
+    editor_id   VARCHAR(36) NOT NULL,
+    old_content TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS task_comment_edits_comment_id_idx ON task_comment_edits(comment_id, created_at);
@@ -0,0 +1,22 @@
+-- 040: 频道管理：私有频道、归档与成员角色
+-- 公开频道对全公司可见，成员表仅记录角色；私有频道仅成员可读写与接收推送
+
+ALTER TABLE channels
+  ADD COLUMN IF NOT EXISTS is_private  BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN IF NOT EXISTS created_by  UUID,
+  ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+UPDATE channels SET description = '' WHERE description IS NULL;
+
+CREATE TABLE IF NOT EXISTS channel_members (
+    channel_id UUID NOT NULL,
+    agent_id   UUID NOT NULL,
+    company_id UUID NOT NULL,
+    role       VARCHAR(16) NOT NULL DEFAULT 'member', -- owner / admin / member
+    added_by   UUID,
+    joined_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (channel_id, agent_id)
+);
+
+CREATE INDEX IF NOT EXISTS channel_members_agent_id_idx ON channel_members(agent_id);
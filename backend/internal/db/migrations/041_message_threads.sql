@@ -0,0 +1,36 @@
+-- 041: 消息线程回复、表情回应、编辑历史、软删除与置顶
+
+ALTER TABLE messages
+  ADD COLUMN IF NOT EXISTS thread_root_id UUID,
+  ADD COLUMN IF NOT EXISTS reply_count    INT NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS last_reply_at  TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS edited_at      TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS deleted_at     TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS deleted_by     UUID,
+  ADD COLUMN IF NOT EXISTS pinned_at      TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS pinned_by      UUID;
+
+CREATE INDEX IF NOT EXISTS messages_thread_root_id_idx
+    ON messages(thread_root_id, created_at) WHERE thread_root_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS messages_pinned_idx
+    ON messages(channel_id, pinned_at) WHERE pinned_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS message_edits (
+    id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    message_id  UUID NOT NULL,
+    company_id  UUID NOT NULL,
+    editor_id   UUID NOT NULL,
+    old_content TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS message_edits_message_id_idx ON message_edits(message_id, created_at);
+
+CREATE TABLE IF NOT EXISTS message_reactions (
+    message_id UUID NOT NULL,
+    agent_id   UUID NOT NULL,
+    emoji      VARCHAR(64) NOT NULL,
+    company_id UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (message_id, agent_id, emoji)
+);
@@ -0,0 +1,20 @@
+-- 042: 消息结构化 @ 提及（发送时解析，替代按名字子串匹配）
+
+ALTER TABLE messages
+  ADD COLUMN IF NOT EXISTS mentions       TEXT NOT NULL DEFAULT '[]',
+  ADD COLUMN IF NOT EXISTS mention_groups TEXT NOT NULL DEFAULT '[]';
+
+-- 回填历史消息：沿用旧的判定规则（内容包含 "@名字"，不含发送者本人）
+UPDATE messages m
+SET mentions = sub.ids
+FROM (
+  SELECT msg.id, json_agg(a.id::text ORDER BY a.id)::text AS ids
+  FROM messages msg
+  JOIN agents a ON a.company_id = msg.company_id
+   AND a.name <> ''
+   AND msg.content LIKE '%@' || a.name || '%'
+   AND (msg.sender_id IS NULL OR a.id <> msg.sender_id)
+  WHERE msg.content LIKE '%@%'
+  GROUP BY msg.id
+) sub
+WHERE m.id = sub.id;
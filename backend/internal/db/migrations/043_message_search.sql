@@ -0,0 +1,44 @@
+-- 043: 消息全文搜索与可选语义搜索（pgvector 不可用时 embedding 列用 TEXT 存储，语义搜索降级为全文搜索）
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS search_vec TSVECTOR;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS embed_attempts SMALLINT NOT NULL DEFAULT 0;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'vector') THEN
+        EXECUTE 'ALTER TABLE messages ADD COLUMN IF NOT EXISTS embedding vector(1536)';
+    ELSE
+        ALTER TABLE messages ADD COLUMN IF NOT EXISTS embedding TEXT;
+    END IF;
+END
+$$;
+
+CREATE OR REPLACE FUNCTION messages_search_vec_update()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vec := to_tsvector('simple', COALESCE(NEW.content, ''));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS messages_tsvectorupdate ON messages;
+CREATE TRIGGER messages_tsvectorupdate
+    BEFORE INSERT OR UPDATE OF content ON messages
+    FOR EACH ROW EXECUTE FUNCTION messages_search_vec_update();
+
+-- 回填已有数据
+UPDATE messages SET search_vec = to_tsvector('simple', COALESCE(content, ''))
+WHERE search_vec IS NULL;
+
+CREATE INDEX IF NOT EXISTS messages_search_idx ON messages USING GIN(search_vec);
+CREATE INDEX IF NOT EXISTS messages_pending_embed_idx ON messages(created_at)
+    WHERE embedding IS NULL AND deleted_at IS NULL;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_type WHERE typname = 'vector') THEN
+        EXECUTE 'CREATE INDEX IF NOT EXISTS messages_embedding_idx ON messages
+            USING hnsw (embedding vector_cosine_ops) WITH (m = 16, ef_construction = 64)';
+    END IF;
+END
+$$;
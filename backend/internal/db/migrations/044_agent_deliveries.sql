@@ -0,0 +1,30 @@
+-- 044: Agent 投递日志（按 agent 单调递增的 seq，客户端 ack 确认，断线重连从已确认位置续传，未确认的按退避重投）
+
+CREATE TABLE IF NOT EXISTS agent_delivery_cursors (
+    agent_id   VARCHAR(36) PRIMARY KEY,
+    last_seq   BIGINT NOT NULL DEFAULT 0,
+    acked_seq  BIGINT NOT NULL DEFAULT 0,
+    resumed_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS agent_deliveries (
+    agent_id        VARCHAR(36) NOT NULL,
+    seq             BIGINT NOT NULL,
+    company_id      VARCHAR(36) NOT NULL,
+    event_type      VARCHAR(64) NOT NULL,
+    payload         JSONB NOT NULL DEFAULT '{}',
+    message_id      VARCHAR(36),
+    attempts        INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    acked_at        TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (agent_id, seq)
+);
+
+-- 同一条新消息对同一 agent 只记一次（首次续传时用未读消息补齐日志）
+CREATE UNIQUE INDEX IF NOT EXISTS agent_deliveries_message_uniq
+    ON agent_deliveries(agent_id, message_id) WHERE message_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS agent_deliveries_pending_idx
+    ON agent_deliveries(agent_id, next_attempt_at) WHERE acked_at IS NULL;
+CREATE INDEX IF NOT EXISTS agent_deliveries_company_idx ON agent_deliveries(company_id, created_at);
@@ -0,0 +1,27 @@
+-- 045: 富消息：结构化消息块（文件、代码、任务 / 审批 / 知识库卡片、交互按钮）与消息附件
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_msg_type_check;
+ALTER TABLE messages ADD CONSTRAINT messages_msg_type_check
+    CHECK (msg_type IN ('text', 'system', 'task_update', 'rich'));
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS blocks JSONB;
+
+-- 消息附件与任务附件共用存储后端；上传后 message_id 为空，随消息发送时关联
+CREATE TABLE IF NOT EXISTS message_attachments (
+    id                VARCHAR(36) PRIMARY KEY DEFAULT uuid_generate_v4(),
+    company_id        VARCHAR(36) NOT NULL,
+    message_id        VARCHAR(36),
+    filename          VARCHAR(255) NOT NULL,
+    original_filename VARCHAR(255) NOT NULL,
+    file_size         BIGINT NOT NULL,
+    mime_type         VARCHAR(255) NOT NULL,
+    storage_path      VARCHAR(1024) NOT NULL,
+    sha256            VARCHAR(64) NOT NULL DEFAULT '',
+    uploaded_by       VARCHAR(36) NOT NULL,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS message_attachments_message_id_idx
+    ON message_attachments(message_id) WHERE message_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS message_attachments_unlinked_idx
+    ON message_attachments(created_at) WHERE message_id IS NULL;
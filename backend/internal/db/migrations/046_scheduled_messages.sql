@@ -0,0 +1,27 @@
+-- 046: 定时消息与提醒：一次性或按 cron 周期发送到频道 / 私信，提醒以系统私信唤醒创建者本人
+
+CREATE TABLE IF NOT EXISTS scheduled_messages (
+    id              VARCHAR(36) PRIMARY KEY DEFAULT uuid_generate_v4(),
+    company_id      VARCHAR(36) NOT NULL,
+    created_by      VARCHAR(36) NOT NULL,
+    kind            VARCHAR(20) NOT NULL DEFAULT 'message'
+                    CHECK (kind IN ('message', 'reminder')),
+    channel_id      VARCHAR(36),
+    receiver_id     VARCHAR(36),
+    task_id         VARCHAR(36),
+    content         TEXT NOT NULL,
+    cron            VARCHAR(100) NOT NULL DEFAULT '',
+    timezone        VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    status          VARCHAR(20) NOT NULL DEFAULT 'scheduled'
+                    CHECK (status IN ('scheduled', 'sent', 'cancelled', 'failed')),
+    next_run_at     TIMESTAMPTZ,
+    last_run_at     TIMESTAMPTZ,
+    last_message_id VARCHAR(36),
+    last_error      TEXT NOT NULL DEFAULT '',
+    run_count       INT NOT NULL DEFAULT 0,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_messages_creator_idx ON scheduled_messages(company_id, created_by);
+CREATE INDEX IF NOT EXISTS scheduled_messages_due_idx ON scheduled_messages(next_run_at) WHERE status = 'scheduled';
@@ -0,0 +1,21 @@
+-- 047: 在线状态：新增 idle 状态与状态变更历史（用于利用率统计）
+
+ALTER TABLE agents DROP CONSTRAINT IF EXISTS agents_status_check;
+ALTER TABLE agents ADD CONSTRAINT agents_status_check
+    CHECK (status IN ('online', 'busy', 'idle', 'offline'));
+
+-- 每行是一个状态区间；ended_at 为空表示当前状态
+CREATE TABLE IF NOT EXISTS agent_status_history (
+    id          BIGSERIAL PRIMARY KEY,
+    company_id  VARCHAR(36) NOT NULL,
+    agent_id    VARCHAR(36) NOT NULL,
+    status      VARCHAR(20) NOT NULL,
+    prev_status VARCHAR(20) NOT NULL DEFAULT '',
+    reason      VARCHAR(32) NOT NULL DEFAULT '',
+    started_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ended_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS agent_status_history_agent_idx ON agent_status_history(agent_id, started_at DESC);
+CREATE INDEX IF NOT EXISTS agent_status_history_company_idx ON agent_status_history(company_id, started_at);
+CREATE INDEX IF NOT EXISTS agent_status_history_open_idx ON agent_status_history(agent_id) WHERE ended_at IS NULL;
@@ -0,0 +1,41 @@
+-- 048: 人类用户的定期摘要：未读频道消息、任务进展与待办审批，经公司 LLM 汇总后以私信或 webhook 投递
+
+-- 每个用户一行订阅设置；channel_ids 为空表示所有可见频道
+CREATE TABLE IF NOT EXISTS digest_settings (
+    agent_id          VARCHAR(36) PRIMARY KEY,
+    company_id        VARCHAR(36) NOT NULL,
+    enabled           BOOLEAN NOT NULL DEFAULT TRUE,
+    cron              VARCHAR(100) NOT NULL DEFAULT '0 9 * * *',
+    timezone          VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    delivery          VARCHAR(20) NOT NULL DEFAULT 'dm'
+                      CHECK (delivery IN ('dm', 'webhook')),
+    channel_ids       TEXT NOT NULL DEFAULT '[]',
+    include_dms       BOOLEAN NOT NULL DEFAULT TRUE,
+    include_tasks     BOOLEAN NOT NULL DEFAULT TRUE,
+    include_approvals BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at       TIMESTAMPTZ,
+    last_run_at       TIMESTAMPTZ,
+    last_error        TEXT NOT NULL DEFAULT '',
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS digest_settings_due_idx ON digest_settings(next_run_at) WHERE enabled;
+
+CREATE TABLE IF NOT EXISTS digests (
+    id             VARCHAR(36) PRIMARY KEY DEFAULT uuid_generate_v4(),
+    company_id     VARCHAR(36) NOT NULL,
+    agent_id       VARCHAR(36) NOT NULL,
+    period_start   TIMESTAMPTZ NOT NULL,
+    period_end     TIMESTAMPTZ NOT NULL,
+    content        TEXT NOT NULL,
+    message_count  INT NOT NULL DEFAULT 0,
+    task_count     INT NOT NULL DEFAULT 0,
+    approval_count INT NOT NULL DEFAULT 0,
+    delivery       VARCHAR(20) NOT NULL,
+    message_id     VARCHAR(36),
+    llm_error      TEXT NOT NULL DEFAULT '',
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS digests_agent_idx ON digests(agent_id, created_at DESC);
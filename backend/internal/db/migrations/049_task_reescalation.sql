@@ -0,0 +1,9 @@
+-- 049: 逾期 / SLA 超时未处理时沿汇报链继续升级
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS escalated_at TIMESTAMPTZ;
+
+UPDATE tasks SET escalated_at = GREATEST(overdue_at, sla_breached_at)
+WHERE overdue_at IS NOT NULL OR sla_breached_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS tasks_escalated_at_idx
+    ON tasks(escalated_at) WHERE escalated_at IS NOT NULL;
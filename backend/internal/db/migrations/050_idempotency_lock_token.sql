@@ -0,0 +1,3 @@
+-- 050: 幂等键占用者标识，被接管的旧请求不能再写入或释放新占用者的键
+
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS lock_token VARCHAR(36) NOT NULL DEFAULT '';
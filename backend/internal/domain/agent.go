@@ -62,9 +62,13 @@ type AgentStatus string
 const (
 	StatusOnline  AgentStatus = "online"
 	StatusBusy    AgentStatus = "busy"
+	StatusIdle    AgentStatus = "idle" // 仍连接但长时间无操作
 	StatusOffline AgentStatus = "offline"
 )
 
+// PermTaskManage 管理任意任务（改派、修改、验收等）的权限，服务层与 MCP 共用
+const PermTaskManage = "task:manage"
+
 // PositionMeta 职位元信息，用于初始化和 UI 展示
 type PositionMeta struct {
 	Position       Position
@@ -183,6 +187,16 @@ var departmentSlugAliases = map[string]string{
 	"财务":   "finance",
 }
 
+// IsDepartmentSlug 是否为预设部门 slug（如 engineering）
+func IsDepartmentSlug(slug string) bool {
+	for _, v := range departmentSlugAliases {
+		if v == slug {
+			return true
+		}
+	}
+	return false
+}
+
 func normalizeDepartmentSlug(dept string) string {
 	if slug, ok := departmentSlugAliases[dept]; ok {
 		return slug
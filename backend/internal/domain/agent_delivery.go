@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AgentDelivery Agent 投递日志中的一条事件：seq 按 agent 单调递增（可能有空洞），
+// 客户端 ack 之前按退避重投，断线重连时从已确认位置续传
+type AgentDelivery struct {
+	AgentID       string          `gorm:"column:agent_id"        json:"-"`
+	Seq           int64           `gorm:"column:seq"             json:"seq"`
+	CompanyID     string          `gorm:"column:company_id"      json:"-"`
+	EventType     string          `gorm:"column:event_type"      json:"type"`
+	Payload       json.RawMessage `gorm:"column:payload"         json:"data"`
+	MessageID     *string         `gorm:"column:message_id"      json:"-"` // 仅 message.new，用于标记已读时一并确认
+	Attempts      int             `gorm:"column:attempts"        json:"attempts"`
+	NextAttemptAt time.Time       `gorm:"column:next_attempt_at" json:"-"`
+	AckedAt       *time.Time      `gorm:"column:acked_at"        json:"-"`
+	CreatedAt     time.Time       `gorm:"column:created_at"      json:"created_at"`
+}
+
+// AgentDeliveryCursor Agent 投递游标：已分配的最大 seq 与客户端累计确认到的 seq
+type AgentDeliveryCursor struct {
+	AgentID   string     `gorm:"column:agent_id"   json:"agent_id"`
+	LastSeq   int64      `gorm:"column:last_seq"   json:"last_seq"`
+	AckedSeq  int64      `gorm:"column:acked_seq"  json:"acked_seq"`
+	ResumedAt *time.Time `gorm:"column:resumed_at" json:"resumed_at,omitempty"`
+	UpdatedAt time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// AgentDeliveryBacklog 单个 agent 的待确认积压
+type AgentDeliveryBacklog struct {
+	AgentID       string     `gorm:"column:agent_id"        json:"agent_id"`
+	Pending       int        `gorm:"column:pending"         json:"pending"`
+	Exhausted     int        `gorm:"column:exhausted"       json:"exhausted"` // 已达最大重投次数，只在重连续传时再推送
+	OldestPending *time.Time `gorm:"column:oldest_pending"  json:"oldest_pending,omitempty"`
+	LastSeq       int64      `gorm:"column:last_seq"        json:"last_seq"`
+	AckedSeq      int64      `gorm:"column:acked_seq"       json:"acked_seq"`
+}
@@ -0,0 +1,45 @@
+package domain
+
+import "time"
+
+// 状态变更原因
+const (
+	PresenceReasonConnect    = "connect"    // 建立第一个连接
+	PresenceReasonDisconnect = "disconnect" // 最后一个连接关闭
+	PresenceReasonExpired    = "expired"    // 心跳过期（进程崩溃、网络中断）
+	PresenceReasonIdle       = "idle"       // 长时间无操作
+	PresenceReasonActive     = "active"     // 空闲后恢复操作
+	PresenceReasonManual     = "manual"     // 本人主动设置
+)
+
+// AgentStatusPeriod 一段状态区间；EndedAt 为空表示当前状态
+type AgentStatusPeriod struct {
+	ID         int64       `gorm:"column:id"          json:"id"`
+	CompanyID  string      `gorm:"column:company_id"  json:"company_id"`
+	AgentID    string      `gorm:"column:agent_id"    json:"agent_id"`
+	Status     AgentStatus `gorm:"column:status"      json:"status"`
+	PrevStatus AgentStatus `gorm:"column:prev_status" json:"prev_status"`
+	Reason     string      `gorm:"column:reason"      json:"reason"`
+	StartedAt  time.Time   `gorm:"column:started_at"  json:"started_at"`
+	EndedAt    *time.Time  `gorm:"column:ended_at"    json:"ended_at"`
+}
+
+// AgentStatusDuration 统计区间内某 agent 处于某状态的总秒数
+type AgentStatusDuration struct {
+	AgentID string      `gorm:"column:agent_id"`
+	Status  AgentStatus `gorm:"column:status"`
+	Seconds int64       `gorm:"column:seconds"`
+}
+
+// AgentUtilization 统计区间内的在线利用率；无记录的时间计为离线
+type AgentUtilization struct {
+	AgentID        string  `json:"agent_id"`
+	Name           string  `json:"name"`
+	IsHuman        bool    `json:"is_human"`
+	OnlineSeconds  int64   `json:"online_seconds"`
+	BusySeconds    int64   `json:"busy_seconds"`
+	IdleSeconds    int64   `json:"idle_seconds"`
+	OfflineSeconds int64   `json:"offline_seconds"`
+	Availability   float64 `json:"availability"` // 在线（含忙碌、空闲）时长占统计区间的比例
+	ActiveRatio    float64 `json:"active_ratio"` // 在线时长中非空闲的比例
+}
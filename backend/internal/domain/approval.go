@@ -13,6 +13,7 @@ const (
 	ApprovalBudgetOverride ApprovalRequestType = "budget_override"
 	ApprovalTaskEscalation ApprovalRequestType = "task_escalation"
 	ApprovalCustom         ApprovalRequestType = "custom"
+	ApprovalToolCall       ApprovalRequestType = "tool_call" // 工具策略 require_approval 触发
 )
 
 type ApprovalStatus string
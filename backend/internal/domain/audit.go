@@ -16,6 +16,7 @@ const (
 	AuditActionLogin          AuditAction = "login"
 	AuditActionLogout         AuditAction = "logout"
 	AuditActionPartnerAPICall AuditAction = "partner_api_call"
+	AuditActionMCPToolCall    AuditAction = "mcp_tool_call"
 )
 
 type AuditLog struct {
@@ -37,12 +37,45 @@ type CompanySettings struct {
 }
 
 type Channel struct {
-	ID          string    `gorm:"column:id"          json:"id"`
-	CompanyID   string    `gorm:"column:company_id"  json:"company_id"`
-	Name        string    `gorm:"column:name"        json:"name"`
-	Description string    `gorm:"column:description" json:"description"`
-	IsDefault   bool      `gorm:"column:is_default"  json:"is_default"`
-	CreatedAt   time.Time `gorm:"column:created_at"  json:"created_at"`
+	ID          string     `gorm:"column:id"          json:"id"`
+	CompanyID   string     `gorm:"column:company_id"  json:"company_id"`
+	Name        string     `gorm:"column:name"        json:"name"`
+	Description string     `gorm:"column:description" json:"description"`
+	IsDefault   bool       `gorm:"column:is_default"  json:"is_default"`
+	IsPrivate   bool       `gorm:"column:is_private"  json:"is_private"`
+	CreatedBy   *string    `gorm:"column:created_by"  json:"created_by"`
+	ArchivedAt  *time.Time `gorm:"column:archived_at" json:"archived_at"`
+	CreatedAt   time.Time  `gorm:"column:created_at"  json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"column:updated_at"  json:"updated_at"`
+}
+
+// IsArchived 已归档的频道只读，不能再发消息
+func (c *Channel) IsArchived() bool {
+	return c.ArchivedAt != nil
+}
+
+// ChannelRole 频道成员角色
+type ChannelRole string
+
+const (
+	ChannelRoleOwner  ChannelRole = "owner"
+	ChannelRoleAdmin  ChannelRole = "admin"
+	ChannelRoleMember ChannelRole = "member"
+)
+
+// CanManage owner / admin 可修改频道、邀请与移除成员
+func (r ChannelRole) CanManage() bool {
+	return r == ChannelRoleOwner || r == ChannelRoleAdmin
+}
+
+// ChannelMember 频道成员；私有频道仅成员可见，公开频道的成员记录只用于角色
+type ChannelMember struct {
+	ChannelID string      `gorm:"column:channel_id" json:"channel_id"`
+	AgentID   string      `gorm:"column:agent_id"   json:"agent_id"`
+	CompanyID string      `gorm:"column:company_id" json:"company_id"`
+	Role      ChannelRole `gorm:"column:role"       json:"role"`
+	AddedBy   *string     `gorm:"column:added_by"   json:"added_by"`
+	JoinedAt  time.Time   `gorm:"column:joined_at"  json:"joined_at"`
 }
 
 // PartnerApiKey 公司间配对 API 密钥
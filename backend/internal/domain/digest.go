@@ -0,0 +1,47 @@
+package domain
+
+import "time"
+
+// DigestDelivery 摘要投递方式
+type DigestDelivery string
+
+const (
+	DigestDeliveryDM      DigestDelivery = "dm"      // 以系统私信发给本人
+	DigestDeliveryWebhook DigestDelivery = "webhook" // 触发 digest.created webhook，由外部转发邮件等
+)
+
+// DigestSettings 用户的摘要订阅；ChannelIDs 为空表示所有可见频道
+type DigestSettings struct {
+	AgentID          string         `gorm:"column:agent_id"          json:"agent_id"`
+	CompanyID        string         `gorm:"column:company_id"        json:"company_id"`
+	Enabled          bool           `gorm:"column:enabled"           json:"enabled"`
+	Cron             string         `gorm:"column:cron"              json:"cron"`
+	Timezone         string         `gorm:"column:timezone"          json:"timezone"`
+	Delivery         DigestDelivery `gorm:"column:delivery"          json:"delivery"`
+	ChannelIDs       StringList     `gorm:"column:channel_ids"       json:"channel_ids"`
+	IncludeDMs       bool           `gorm:"column:include_dms"       json:"include_dms"`
+	IncludeTasks     bool           `gorm:"column:include_tasks"     json:"include_tasks"`
+	IncludeApprovals bool           `gorm:"column:include_approvals" json:"include_approvals"`
+	NextRunAt        *time.Time     `gorm:"column:next_run_at"       json:"next_run_at"`
+	LastRunAt        *time.Time     `gorm:"column:last_run_at"       json:"last_run_at"` // 下一次摘要从这里开始统计
+	LastError        string         `gorm:"column:last_error"        json:"last_error"`
+	CreatedAt        time.Time      `gorm:"column:created_at"        json:"created_at"`
+	UpdatedAt        time.Time      `gorm:"column:updated_at"        json:"updated_at"`
+}
+
+// Digest 一次已生成的摘要
+type Digest struct {
+	ID            string         `gorm:"column:id"             json:"id"`
+	CompanyID     string         `gorm:"column:company_id"     json:"company_id"`
+	AgentID       string         `gorm:"column:agent_id"       json:"agent_id"`
+	PeriodStart   time.Time      `gorm:"column:period_start"   json:"period_start"`
+	PeriodEnd     time.Time      `gorm:"column:period_end"     json:"period_end"`
+	Content       string         `gorm:"column:content"        json:"content"`
+	MessageCount  int            `gorm:"column:message_count"  json:"message_count"`
+	TaskCount     int            `gorm:"column:task_count"     json:"task_count"`
+	ApprovalCount int            `gorm:"column:approval_count" json:"approval_count"`
+	Delivery      DigestDelivery `gorm:"column:delivery"       json:"delivery"`
+	MessageID     *string        `gorm:"column:message_id"     json:"message_id"`
+	LLMError      string         `gorm:"column:llm_error"      json:"llm_error,omitempty"` // LLM 不可用时退化为统计摘要
+	CreatedAt     time.Time      `gorm:"column:created_at"     json:"created_at"`
+}
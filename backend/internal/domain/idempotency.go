@@ -0,0 +1,33 @@
+package domain
+
+import "time"
+
+// IdempotencyScope 幂等键作用域
+type IdempotencyScope string
+
+const (
+	IdempotencyScopeMCP  IdempotencyScope = "mcp"  // MCP 工具调用（_meta.idempotencyKey）
+	IdempotencyScopeREST IdempotencyScope = "rest" // REST POST（Idempotency-Key 请求头）
+)
+
+// IdempotencyStatus 幂等记录状态
+type IdempotencyStatus string
+
+const (
+	IdempotencyPending IdempotencyStatus = "pending" // 首次请求执行中
+	IdempotencyDone    IdempotencyStatus = "done"    // 已完成，重试直接返回 Response
+)
+
+// IdempotencyRecord 同一 Agent 在同一作用域下的一个幂等键及其首次结果
+type IdempotencyRecord struct {
+	AgentID     string            `gorm:"column:agent_id"`
+	Scope       IdempotencyScope  `gorm:"column:scope"`
+	Key         string            `gorm:"column:key"`
+	RequestHash string            `gorm:"column:request_hash"` // 请求内容摘要，同键不同内容视为误用
+	Status      IdempotencyStatus `gorm:"column:status"`
+	StatusCode  int               `gorm:"column:status_code"` // REST 响应码
+	Response    string            `gorm:"column:response"`
+	LockToken   string            `gorm:"column:lock_token"` // 每次占用（含接管）生成的新值，仅持有者可完成或释放
+	CreatedAt   time.Time         `gorm:"column:created_at"`
+	ExpiresAt   time.Time         `gorm:"column:expires_at"`
+}
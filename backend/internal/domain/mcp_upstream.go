@@ -0,0 +1,45 @@
+package domain
+
+import "time"
+
+// McpUpstreamTransport 上游 MCP Server 连接方式
+type McpUpstreamTransport string
+
+const (
+	McpTransportHTTP  McpUpstreamTransport = "http"  // Streamable HTTP
+	McpTransportStdio McpUpstreamTransport = "stdio" // 容器内 stdio（docker run -i --rm <image>）
+)
+
+// McpToolSeparator 联邦工具命名空间分隔符：<upstream>__<tool>
+const McpToolSeparator = "__"
+
+// McpUpstream 公司接入的外部 MCP Server，工具以 <name>__<tool> 形式合并进工具列表
+type McpUpstream struct {
+	ID           string               `gorm:"column:id"            json:"id"`
+	CompanyID    string               `gorm:"column:company_id"    json:"company_id"`
+	Name         string               `gorm:"column:name"          json:"name"` // 命名空间前缀，小写字母/数字/连字符
+	Description  string               `gorm:"column:description"   json:"description"`
+	Transport    McpUpstreamTransport `gorm:"column:transport"     json:"transport"`
+	URL          string               `gorm:"column:url"           json:"url,omitempty"`     // http
+	Image        string               `gorm:"column:image"         json:"image,omitempty"`   // stdio：docker 镜像
+	Command      string               `gorm:"column:command"       json:"command,omitempty"` // stdio：镜像内启动命令（可选）
+	Args         StringList           `gorm:"column:args"          json:"args"`
+	AuthHeader   string               `gorm:"column:auth_header"   json:"auth_header,omitempty"` // http：凭证写入的请求头（默认 Authorization）
+	AuthScheme   string               `gorm:"column:auth_scheme"   json:"auth_scheme,omitempty"` // http：凭证前缀（如 Bearer）
+	AuthEnv      string               `gorm:"column:auth_env"      json:"auth_env,omitempty"`    // stdio：凭证注入的环境变量名
+	RequiredPerm string               `gorm:"column:required_perm" json:"required_perm,omitempty"`
+	IsActive     bool                 `gorm:"column:is_active"     json:"is_active"`
+	CreatedAt    time.Time            `gorm:"column:created_at"    json:"created_at"`
+	UpdatedAt    time.Time            `gorm:"column:updated_at"    json:"updated_at"`
+}
+
+// McpUpstreamCredential 上游凭证（AES-GCM 加密存储）；AgentID 为空表示公司级凭证
+type McpUpstreamCredential struct {
+	ID         string    `gorm:"column:id"          json:"id"`
+	CompanyID  string    `gorm:"column:company_id"  json:"company_id"`
+	UpstreamID string    `gorm:"column:upstream_id" json:"upstream_id"`
+	AgentID    *string   `gorm:"column:agent_id"    json:"agent_id,omitempty"`
+	SecretEnc  string    `gorm:"column:secret_enc"  json:"-"`
+	CreatedAt  time.Time `gorm:"column:created_at"  json:"created_at"`
+	UpdatedAt  time.Time `gorm:"column:updated_at"  json:"updated_at"`
+}
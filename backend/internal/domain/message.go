@@ -11,8 +11,21 @@ const (
 	MsgTypeText       MsgType = "text"
 	MsgTypeSystem     MsgType = "system"
 	MsgTypeTaskUpdate MsgType = "task_update"
+	MsgTypeRich       MsgType = "rich" // 带结构化消息块，Content 为纯文本摘要
 )
 
+// MentionGroup 群组提及：@channel 频道全员、@here 在线成员、@department 发送者所在部门
+type MentionGroup string
+
+const (
+	MentionChannel    MentionGroup = "channel"
+	MentionHere       MentionGroup = "here"
+	MentionDepartment MentionGroup = "department"
+)
+
+// MentionGroups 支持的群组提及，按匹配优先级排列
+var MentionGroups = []MentionGroup{MentionChannel, MentionHere, MentionDepartment}
+
 // TaskMeta 嵌入 task_update 消息，供前端渲染进度卡片
 type TaskMeta struct {
 	TaskID     string       `json:"task_id"`
@@ -25,18 +38,72 @@ type TaskMeta struct {
 }
 
 type Message struct {
-	ID         string          `gorm:"column:id"          json:"id"`
-	CompanyID  string          `gorm:"column:company_id"  json:"company_id"`
-	SenderID   *string         `gorm:"column:sender_id"   json:"sender_id"`
-	ChannelID  *string         `gorm:"column:channel_id"  json:"channel_id"`
-	ReceiverID *string         `gorm:"column:receiver_id" json:"receiver_id"`
-	Content    string          `gorm:"column:content"     json:"content"`
-	MsgType    MsgType         `gorm:"column:msg_type"    json:"msg_type"`
-	TaskMeta   json.RawMessage `gorm:"column:task_meta"   json:"task_meta"`
-	CreatedAt  time.Time       `gorm:"column:created_at"  json:"created_at"`
+	ID            string          `gorm:"column:id"             json:"id"`
+	CompanyID     string          `gorm:"column:company_id"     json:"company_id"`
+	SenderID      *string         `gorm:"column:sender_id"      json:"sender_id"`
+	ChannelID     *string         `gorm:"column:channel_id"     json:"channel_id"`
+	ReceiverID    *string         `gorm:"column:receiver_id"    json:"receiver_id"`
+	ThreadRootID  *string         `gorm:"column:thread_root_id" json:"thread_root_id"`
+	Content       string          `gorm:"column:content"        json:"content"`
+	MsgType       MsgType         `gorm:"column:msg_type"       json:"msg_type"`
+	TaskMeta      json.RawMessage `gorm:"column:task_meta"      json:"task_meta"`
+	Blocks        json.RawMessage `gorm:"column:blocks"         json:"blocks"`         // []*MessageBlock，仅 rich 消息
+	Mentions      StringList      `gorm:"column:mentions"       json:"mentions"`       // 被提及的 agent ID，群组提及已展开，不含发送者
+	MentionGroups StringList      `gorm:"column:mention_groups" json:"mention_groups"` // 原始群组提及（channel / here / department）
+	ReplyCount    int             `gorm:"column:reply_count"    json:"reply_count"`
+	LastReplyAt   *time.Time      `gorm:"column:last_reply_at"  json:"last_reply_at"`
+	EditedAt      *time.Time      `gorm:"column:edited_at"      json:"edited_at"`
+	DeletedAt     *time.Time      `gorm:"column:deleted_at"     json:"deleted_at"`
+	DeletedBy     *string         `gorm:"column:deleted_by"     json:"deleted_by"`
+	PinnedAt      *time.Time      `gorm:"column:pinned_at"      json:"pinned_at"`
+	PinnedBy      *string         `gorm:"column:pinned_by"      json:"pinned_by"`
+	CreatedAt     time.Time       `gorm:"column:created_at"     json:"created_at"`
+
+	Reactions []*MessageReactionCount `gorm:"-" json:"reactions,omitempty"`
 }
 
 // IsDM 判断是否为私信
 func (m *Message) IsDM() bool {
 	return m.ReceiverID != nil && m.ChannelID == nil
 }
+
+// Mentioned agentID 是否被该消息提及（含群组提及）
+func (m *Message) Mentioned(agentID string) bool {
+	for _, id := range m.Mentions {
+		if id == agentID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDeleted 已软删除的消息内容为空，仅保留占位
+func (m *Message) IsDeleted() bool {
+	return m.DeletedAt != nil
+}
+
+// MessageEdit 消息编辑历史，保存编辑前的原文
+type MessageEdit struct {
+	ID         string    `gorm:"column:id"          json:"id"`
+	MessageID  string    `gorm:"column:message_id"  json:"message_id"`
+	CompanyID  string    `gorm:"column:company_id"  json:"company_id"`
+	EditorID   string    `gorm:"column:editor_id"   json:"editor_id"`
+	OldContent string    `gorm:"column:old_content" json:"old_content"`
+	CreatedAt  time.Time `gorm:"column:created_at"  json:"created_at"`
+}
+
+// MessageReaction 单个 Agent 对消息的一个表情回应
+type MessageReaction struct {
+	MessageID string    `gorm:"column:message_id" json:"message_id"`
+	AgentID   string    `gorm:"column:agent_id"   json:"agent_id"`
+	Emoji     string    `gorm:"column:emoji"      json:"emoji"`
+	CompanyID string    `gorm:"column:company_id" json:"company_id"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+// MessageReactionCount 按表情聚合的回应，随消息列表返回
+type MessageReactionCount struct {
+	Emoji    string   `json:"emoji"`
+	Count    int      `json:"count"`
+	AgentIDs []string `json:"agent_ids"`
+}
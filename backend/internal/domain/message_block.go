@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// MessageBlockType 富消息块类型
+type MessageBlockType string
+
+const (
+	BlockText      MessageBlockType = "text"      // Markdown 段落
+	BlockCode      MessageBlockType = "code"      // 代码块
+	BlockFile      MessageBlockType = "file"      // 文件附件
+	BlockTask      MessageBlockType = "task"      // 任务卡片
+	BlockApproval  MessageBlockType = "approval"  // 审批卡片（带批准 / 驳回操作）
+	BlockKnowledge MessageBlockType = "knowledge" // 知识库文档链接卡片
+	BlockActions   MessageBlockType = "actions"   // 交互按钮，点击回传给发送者
+)
+
+// 审批卡片的内置操作；value 为审批请求 ID
+const (
+	ActionApprove = "approval.approve"
+	ActionReject  = "approval.reject"
+)
+
+// MessageBlock 富消息中的一个块；按 Type 只填写对应字段。
+// 卡片类字段在发送时由服务端按 ID 回填快照
+type MessageBlock struct {
+	Type      MessageBlockType `json:"type"`
+	Text      string           `json:"text,omitempty"`
+	Language  string           `json:"language,omitempty"` // code
+	Code      string           `json:"code,omitempty"`     // code
+	File      *MessageFile     `json:"file,omitempty"`
+	Task      *TaskMeta        `json:"task,omitempty"`
+	Approval  *ApprovalCard    `json:"approval,omitempty"`
+	Knowledge *KnowledgeCard   `json:"knowledge,omitempty"`
+	Actions   []*MessageAction `json:"actions,omitempty"`
+}
+
+// MessageFile 文件块，引用已上传的消息附件
+type MessageFile struct {
+	AttachmentID string `json:"attachment_id"`
+	Filename     string `json:"filename"`
+	FileSize     int64  `json:"file_size"`
+	MimeType     string `json:"mime_type"`
+}
+
+// ApprovalCard 审批请求快照；通过卡片处理后更新状态
+type ApprovalCard struct {
+	ID          string              `json:"id"`
+	RequestType ApprovalRequestType `json:"request_type"`
+	Status      ApprovalStatus      `json:"status"`
+	Reason      string              `json:"reason"`
+	RequesterID string              `json:"requester_id"`
+	ApproverID  *string             `json:"approver_id,omitempty"`
+	DecidedBy   *string             `json:"decided_by,omitempty"`
+	DecidedAt   *time.Time          `json:"decided_at,omitempty"`
+}
+
+// KnowledgeCard 知识库文档链接
+type KnowledgeCard struct {
+	DocID   string     `json:"doc_id"`
+	Title   string     `json:"title"`
+	Excerpt string     `json:"excerpt"`
+	Tags    StringList `json:"tags"`
+}
+
+// MessageAction 交互按钮；点击后以 message.action 事件回传给消息发送者
+type MessageAction struct {
+	ActionID string `json:"action_id"`
+	Label    string `json:"label"`
+	Style    string `json:"style,omitempty"` // primary / danger，空为默认样式
+	Value    string `json:"value,omitempty"`
+}
+
+// ParseBlocks 解析消息块；非 rich 消息返回 nil
+func (m *Message) ParseBlocks() ([]*MessageBlock, error) {
+	if len(m.Blocks) == 0 || string(m.Blocks) == "null" {
+		return nil, nil
+	}
+	var blocks []*MessageBlock
+	if err := json.Unmarshal(m.Blocks, &blocks); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// MessageAttachment 消息附件；上传时 MessageID 为空，随消息发送后关联
+type MessageAttachment struct {
+	ID               string    `gorm:"column:id"                json:"id"`
+	CompanyID        string    `gorm:"column:company_id"        json:"company_id"`
+	MessageID        *string   `gorm:"column:message_id"        json:"message_id"`
+	Filename         string    `gorm:"column:filename"          json:"filename"`
+	OriginalFilename string    `gorm:"column:original_filename" json:"original_filename"`
+	FileSize         int64     `gorm:"column:file_size"         json:"file_size"`
+	MimeType         string    `gorm:"column:mime_type"         json:"mime_type"`
+	StoragePath      string    `gorm:"column:storage_path"      json:"storage_path"` // 存储后端中的 key
+	SHA256           string    `gorm:"column:sha256"            json:"sha256"`
+	UploadedBy       string    `gorm:"column:uploaded_by"       json:"uploaded_by"`
+	CreatedAt        time.Time `gorm:"column:created_at"        json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEvent 与业务数据在同一事务内写入的待发布事件，提交后由 relay 发布到事件总线
+type OutboxEvent struct {
+	ID          string          `gorm:"column:id"`
+	EventType   string          `gorm:"column:event_type"`
+	Payload     json.RawMessage `gorm:"column:payload"`
+	Attempts    int             `gorm:"column:attempts"`
+	CreatedAt   time.Time       `gorm:"column:created_at"`
+	PublishedAt *time.Time      `gorm:"column:published_at"`
+}
@@ -0,0 +1,43 @@
+package domain
+
+import "time"
+
+// ScheduledMessageKind 定时消息类型
+type ScheduledMessageKind string
+
+const (
+	ScheduleKindMessage  ScheduledMessageKind = "message"  // 以创建者身份发到频道或私信
+	ScheduleKindReminder ScheduledMessageKind = "reminder" // 以系统私信发给创建者本人，用于唤醒 Agent 跟进
+)
+
+// ScheduledMessageStatus 定时消息状态；周期规则在取消前一直为 scheduled
+type ScheduledMessageStatus string
+
+const (
+	ScheduleStatusScheduled ScheduledMessageStatus = "scheduled"
+	ScheduleStatusSent      ScheduledMessageStatus = "sent"
+	ScheduleStatusCancelled ScheduledMessageStatus = "cancelled"
+	ScheduleStatusFailed    ScheduledMessageStatus = "failed"
+)
+
+// ScheduledMessage 一次性（Cron 为空）或按 cron 周期发送的消息
+type ScheduledMessage struct {
+	ID            string                 `gorm:"column:id"              json:"id"`
+	CompanyID     string                 `gorm:"column:company_id"      json:"company_id"`
+	CreatedBy     string                 `gorm:"column:created_by"      json:"created_by"`
+	Kind          ScheduledMessageKind   `gorm:"column:kind"            json:"kind"`
+	ChannelID     *string                `gorm:"column:channel_id"      json:"channel_id,omitempty"`
+	ReceiverID    *string                `gorm:"column:receiver_id"     json:"receiver_id,omitempty"`
+	TaskID        *string                `gorm:"column:task_id"         json:"task_id,omitempty"` // 提醒关联的任务，触发时附上任务当前状态
+	Content       string                 `gorm:"column:content"         json:"content"`
+	Cron          string                 `gorm:"column:cron"            json:"cron"`     // 5 段 cron 或 @daily 等，空表示一次性
+	Timezone      string                 `gorm:"column:timezone"        json:"timezone"` // IANA 时区，默认 UTC
+	Status        ScheduledMessageStatus `gorm:"column:status"          json:"status"`
+	NextRunAt     *time.Time             `gorm:"column:next_run_at"     json:"next_run_at"`
+	LastRunAt     *time.Time             `gorm:"column:last_run_at"     json:"last_run_at"`
+	LastMessageID *string                `gorm:"column:last_message_id" json:"last_message_id"`
+	LastError     string                 `gorm:"column:last_error"      json:"last_error"`
+	RunCount      int                    `gorm:"column:run_count"       json:"run_count"`
+	CreatedAt     time.Time              `gorm:"column:created_at"      json:"created_at"`
+	UpdatedAt     time.Time              `gorm:"column:updated_at"      json:"updated_at"`
+}
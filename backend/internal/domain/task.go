@@ -8,6 +8,7 @@ const (
 	TaskStatusPending    TaskStatus = "pending"
 	TaskStatusAssigned   TaskStatus = "assigned"
 	TaskStatusInProgress TaskStatus = "in_progress"
+	TaskStatusInReview   TaskStatus = "in_review"
 	TaskStatusDone       TaskStatus = "done"
 	TaskStatusFailed     TaskStatus = "failed"
 	TaskStatusCancelled  TaskStatus = "cancelled"
@@ -26,7 +27,26 @@ const (
 var ValidTransitions = map[TaskStatus][]TaskStatus{
 	TaskStatusPending:    {TaskStatusAssigned, TaskStatusCancelled},
 	TaskStatusAssigned:   {TaskStatusInProgress, TaskStatusCancelled},
-	TaskStatusInProgress: {TaskStatusDone, TaskStatusFailed, TaskStatusCancelled},
+	TaskStatusInProgress: {TaskStatusInReview, TaskStatusDone, TaskStatusFailed, TaskStatusCancelled},
+	TaskStatusInReview:   {TaskStatusDone, TaskStatusInProgress, TaskStatusCancelled},
+}
+
+// TaskReviewPolicy 任务验收人来源；为空表示提交即完成
+type TaskReviewPolicy string
+
+const (
+	TaskReviewNone    TaskReviewPolicy = ""
+	TaskReviewCreator TaskReviewPolicy = "creator" // 任务创建者
+	TaskReviewManager TaskReviewPolicy = "manager" // 负责人的上级
+	TaskReviewQA      TaskReviewPolicy = "qa"      // QA 职位的 Agent
+)
+
+func (p TaskReviewPolicy) Valid() bool {
+	switch p {
+	case TaskReviewNone, TaskReviewCreator, TaskReviewManager, TaskReviewQA:
+		return true
+	}
+	return false
 }
 
 func (s TaskStatus) CanTransitionTo(next TaskStatus) bool {
@@ -52,12 +72,26 @@ type Task struct {
 	Result      *string      `gorm:"column:result"      json:"result"`
 	FailReason  *string      `gorm:"column:fail_reason" json:"fail_reason"`
 	Tags        StringList   `gorm:"column:tags"        json:"tags"`
+	Blocked     bool         `gorm:"column:blocked"     json:"blocked"` // 派生：存在未完成的前置依赖（不落库）
+	Version     int          `gorm:"column:version"     json:"version"` // 乐观锁版本，每次修改自增
+
+	ReviewPolicy TaskReviewPolicy `gorm:"column:review_policy" json:"review_policy"`
+	ReviewerID   *string          `gorm:"column:reviewer_id"   json:"reviewer_id"`
+
+	StatusChangedAt time.Time  `gorm:"column:status_changed_at" json:"status_changed_at"`
+	DueWarnedAt     *time.Time `gorm:"column:due_warned_at"     json:"-"`
+	OverdueAt       *time.Time `gorm:"column:overdue_at"        json:"overdue_at"`      // 首次逾期时间（由 SLA 调度器标记）
+	SLABreachedAt   *time.Time `gorm:"column:sla_breached_at"   json:"sla_breached_at"` // 在当前状态停留超过优先级 SLA 的时间
+	EscalationLevel int        `gorm:"column:escalation_level"  json:"escalation_level"`
+	EscalatedAt     *time.Time `gorm:"column:escalated_at"      json:"escalated_at"` // 最近一次升级时间；链路到顶后清空，不再继续升级
 
 	Subtasks     []*Task           `gorm:"-" json:"subtasks"`
 	Comments     []*TaskComment    `gorm:"-" json:"comments,omitempty"`
 	Dependencies []*TaskDependency `gorm:"-" json:"dependencies,omitempty"`
 	Watchers     []*TaskWatcher    `gorm:"-" json:"watchers,omitempty"`
 	Attachments  []*TaskAttachment `gorm:"-" json:"attachments,omitempty"`
+	Reviews      []*TaskReview     `gorm:"-" json:"reviews,omitempty"`
+	Activity     []*TaskEvent      `gorm:"-" json:"activity,omitempty"`
 
 	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
@@ -71,7 +105,8 @@ type TaskAttachment struct {
 	OriginalFilename string    `gorm:"column:original_filename" json:"original_filename"`
 	FileSize         int64     `gorm:"column:file_size"         json:"file_size"`
 	MimeType         string    `gorm:"column:mime_type"         json:"mime_type"`
-	StoragePath      string    `gorm:"column:storage_path"      json:"storage_path"`
+	StoragePath      string    `gorm:"column:storage_path"      json:"storage_path"` // 存储后端中的 key
+	SHA256           string    `gorm:"column:sha256"            json:"sha256"`
 	UploadedBy       *string   `gorm:"column:uploaded_by"       json:"uploaded_by"`
 	CreatedAt        time.Time `gorm:"column:created_at"        json:"created_at"`
 }
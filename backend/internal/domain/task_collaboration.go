@@ -3,12 +3,25 @@ package domain
 import "time"
 
 type TaskComment struct {
-	ID        string    `gorm:"column:id"         json:"id"`
-	TaskID    string    `gorm:"column:task_id"    json:"task_id"`
-	CompanyID string    `gorm:"column:company_id" json:"company_id"`
-	AgentID   string    `gorm:"column:agent_id"   json:"agent_id"`
-	Content   string    `gorm:"column:content"    json:"content"`
-	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+	ID              string     `gorm:"column:id"                json:"id"`
+	TaskID          string     `gorm:"column:task_id"           json:"task_id"`
+	CompanyID       string     `gorm:"column:company_id"        json:"company_id"`
+	AgentID         string     `gorm:"column:agent_id"          json:"agent_id"`
+	ParentCommentID *string    `gorm:"column:parent_comment_id" json:"parent_comment_id,omitempty"` // 回复的评论
+	Content         string     `gorm:"column:content"           json:"content"`
+	Mentions        StringList `gorm:"column:mentions"          json:"mentions"` // 被 @ 的 Agent ID
+	EditedAt        *time.Time `gorm:"column:edited_at"         json:"edited_at,omitempty"`
+	CreatedAt       time.Time  `gorm:"column:created_at"        json:"created_at"`
+}
+
+// TaskCommentEdit 评论编辑历史：每次编辑前的原文
+type TaskCommentEdit struct {
+	ID         string    `gorm:"column:id"          json:"id"`
+	CommentID  string    `gorm:"column:comment_id"  json:"comment_id"`
+	CompanyID  string    `gorm:"column:company_id"  json:"company_id"`
+	EditorID   string    `gorm:"column:editor_id"   json:"editor_id"`
+	OldContent string    `gorm:"column:old_content" json:"old_content"`
+	CreatedAt  time.Time `gorm:"column:created_at"  json:"created_at"`
 }
 
 type TaskDependency struct {
@@ -24,3 +37,41 @@ type TaskWatcher struct {
 	AgentID   string    `gorm:"column:agent_id"   json:"agent_id"`
 	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
 }
+
+// TaskReviewOutcome 验收结论
+type TaskReviewOutcome string
+
+const (
+	TaskReviewApproved         TaskReviewOutcome = "approved"
+	TaskReviewChangesRequested TaskReviewOutcome = "changes_requested"
+)
+
+// TaskReview 一次验收记录（用于返工追踪和 Agent 成功率统计）
+type TaskReview struct {
+	ID         string            `gorm:"column:id"          json:"id"`
+	TaskID     string            `gorm:"column:task_id"     json:"task_id"`
+	CompanyID  string            `gorm:"column:company_id"  json:"company_id"`
+	ReviewerID string            `gorm:"column:reviewer_id" json:"reviewer_id"`
+	AssigneeID string            `gorm:"column:assignee_id" json:"assignee_id"`
+	Outcome    TaskReviewOutcome `gorm:"column:outcome"     json:"outcome"`
+	Comment    string            `gorm:"column:comment"     json:"comment"`
+	CreatedAt  time.Time         `gorm:"column:created_at"  json:"created_at"`
+}
+
+// TaskReviewStats Agent 被验收的结果统计
+type TaskReviewStats struct {
+	Approved         int `gorm:"column:approved"          json:"approved"`
+	ChangesRequested int `gorm:"column:changes_requested" json:"changes_requested"`
+}
+
+// TaskEvent 任务字段变更记录：谁在何时把哪个字段从什么改成了什么（ActorID 为空表示系统）
+type TaskEvent struct {
+	ID        string    `gorm:"column:id"         json:"id"`
+	TaskID    string    `gorm:"column:task_id"    json:"task_id"`
+	CompanyID string    `gorm:"column:company_id" json:"company_id"`
+	ActorID   *string   `gorm:"column:actor_id"   json:"actor_id"`
+	Field     string    `gorm:"column:field"      json:"field"`
+	OldValue  string    `gorm:"column:old_value"  json:"old_value"`
+	NewValue  string    `gorm:"column:new_value"  json:"new_value"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
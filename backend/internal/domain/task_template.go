@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// TaskTemplateParam 模板参数，在标题、描述和标签中以 {{name}} 引用
+type TaskTemplateParam struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Default     string `json:"default,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// TaskTemplateParams 参数列表，以 JSONB 存储
+type TaskTemplateParams []TaskTemplateParam
+
+// Scan 从数据库读取
+func (p *TaskTemplateParams) Scan(value any) error {
+	*p = TaskTemplateParams{}
+	return scanJSON(value, p, "TaskTemplateParams")
+}
+
+// Value 序列化为 JSON 写入数据库
+func (p TaskTemplateParams) Value() (driver.Value, error) {
+	if len(p) == 0 {
+		return "[]", nil
+	}
+	data, err := json.Marshal([]TaskTemplateParam(p))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// 模板节点负责人写法（Assignee）：
+//   - ""              不分配
+//   - "creator"       实例化者本人
+//   - "manager"       实例化者的直属上级（无则部门总监）
+//   - "auto"          按职位/部门/负载/历史成功率自动分配
+//   - "agent:<id>"    指定 Agent
+//   - "<position>"    团队中该职位的同事（如 qa_engineer），同部门优先、负载最低者
+const (
+	TemplateAssigneeCreator     = "creator"
+	TemplateAssigneeManager     = "manager"
+	TemplateAssigneeAuto        = "auto"
+	TemplateAssigneeAgentPrefix = "agent:"
+)
+
+// TaskTemplateNode 模板中的一个任务节点；根节点即模板实例化后的父任务
+type TaskTemplateNode struct {
+	Key         string             `json:"key,omitempty"` // 模板内唯一，供 depends_on 引用
+	Title       string             `json:"title"`
+	Description string             `json:"description,omitempty"`
+	Priority    TaskPriority       `json:"priority,omitempty"`
+	Tags        []string           `json:"tags,omitempty"`
+	Assignee    string             `json:"assignee,omitempty"`
+	Review      TaskReviewPolicy   `json:"review,omitempty"`
+	DueInHours  int                `json:"due_in_hours,omitempty"` // 相对实例化时间的截止时长
+	DependsOn   []string           `json:"depends_on,omitempty"`   // 依赖的节点 key
+	Subtasks    []TaskTemplateNode `json:"subtasks,omitempty"`
+}
+
+// Scan 从数据库读取
+func (n *TaskTemplateNode) Scan(value any) error {
+	*n = TaskTemplateNode{}
+	return scanJSON(value, n, "TaskTemplateNode")
+}
+
+// Value 序列化为 JSON 写入数据库
+func (n TaskTemplateNode) Value() (driver.Value, error) {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// TaskTemplate 可复用的参数化任务树（周报、发版检查单、入职流程等）
+type TaskTemplate struct {
+	ID          string             `gorm:"column:id"               json:"id"`
+	CompanyID   string             `gorm:"column:company_id"       json:"company_id"`
+	Name        string             `gorm:"column:name"             json:"name"`
+	Description string             `gorm:"column:description"      json:"description"`
+	Params      TaskTemplateParams `gorm:"column:params;type:jsonb" json:"params"`
+	Spec        TaskTemplateNode   `gorm:"column:spec;type:jsonb"   json:"spec"`
+	CreatedBy   *string            `gorm:"column:created_by"       json:"created_by,omitempty"`
+	CreatedAt   time.Time          `gorm:"column:created_at"       json:"created_at"`
+	UpdatedAt   time.Time          `gorm:"column:updated_at"       json:"updated_at"`
+}
+
+// TaskRecurrence 按 cron 表达式周期性实例化模板的规则，以创建者身份创建任务
+type TaskRecurrence struct {
+	ID         string     `gorm:"column:id"               json:"id"`
+	CompanyID  string     `gorm:"column:company_id"       json:"company_id"`
+	TemplateID string     `gorm:"column:template_id"      json:"template_id"`
+	Cron       string     `gorm:"column:cron"             json:"cron"`     // 5 段 cron（分 时 日 月 周）或 @daily 等
+	Timezone   string     `gorm:"column:timezone"         json:"timezone"` // IANA 时区，默认 UTC
+	Params     JSONMap    `gorm:"column:params;type:jsonb" json:"params"`
+	Enabled    bool       `gorm:"column:enabled"          json:"enabled"`
+	NextRunAt  *time.Time `gorm:"column:next_run_at"      json:"next_run_at"`
+	LastRunAt  *time.Time `gorm:"column:last_run_at"      json:"last_run_at"`
+	LastTaskID *string    `gorm:"column:last_task_id"     json:"last_task_id"`
+	LastError  string     `gorm:"column:last_error"       json:"last_error"`
+	CreatedBy  string     `gorm:"column:created_by"       json:"created_by"`
+	CreatedAt  time.Time  `gorm:"column:created_at"       json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"column:updated_at"       json:"updated_at"`
+}
+
+func scanJSON(value any, dst any, name string) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return errors.New(name + ".Scan: unsupported type")
+	}
+	if len(b) == 0 || string(b) == "null" {
+		return nil
+	}
+	return json.Unmarshal(b, dst)
+}
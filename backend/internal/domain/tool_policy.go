@@ -0,0 +1,212 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ToolPolicySubjectType 策略作用对象类型
+type ToolPolicySubjectType string
+
+const (
+	ToolPolicySubjectCompany    ToolPolicySubjectType = "company"    // 全公司
+	ToolPolicySubjectRoleType   ToolPolicySubjectType = "role_type"  // 角色类型（chairman/hr/employee）
+	ToolPolicySubjectDepartment ToolPolicySubjectType = "department" // 部门（ID 或 slug）
+	ToolPolicySubjectPosition   ToolPolicySubjectType = "position"   // 职位
+	ToolPolicySubjectAgent      ToolPolicySubjectType = "agent"      // 单个 Agent
+)
+
+// ToolPolicySubjectOrder 评估顺序：越具体越优先
+var ToolPolicySubjectOrder = []ToolPolicySubjectType{
+	ToolPolicySubjectAgent,
+	ToolPolicySubjectPosition,
+	ToolPolicySubjectDepartment,
+	ToolPolicySubjectRoleType,
+	ToolPolicySubjectCompany,
+}
+
+// ToolPolicyEffect 策略效果
+type ToolPolicyEffect string
+
+const (
+	ToolPolicyAllow           ToolPolicyEffect = "allow"
+	ToolPolicyDeny            ToolPolicyEffect = "deny"
+	ToolPolicyRequireApproval ToolPolicyEffect = "require_approval" // 允许，但每次调用需经上级审批后执行
+)
+
+// ToolArgOp 参数条件运算符
+type ToolArgOp string
+
+const (
+	ToolArgIn     ToolArgOp = "in"     // 参数值在列表内
+	ToolArgNotIn  ToolArgOp = "not_in" // 参数值不在列表内
+	ToolArgPrefix ToolArgOp = "prefix" // 参数值以任一前缀开头
+	ToolArgGlob   ToolArgOp = "glob"   // 参数值匹配任一 glob
+	ToolArgRegex  ToolArgOp = "regex"  // 参数值匹配任一正则
+
+	// ToolArgInvalid 数据库中的条件无法解析时由 Scan 写入，策略按 deny 处理
+	ToolArgInvalid ToolArgOp = "invalid"
+)
+
+// ToolArgCondition 单个参数条件，例如 send_message 仅允许 channel in [general]
+type ToolArgCondition struct {
+	Arg    string    `json:"arg"` // 参数名，支持 a.b 访问嵌套字段
+	Op     ToolArgOp `json:"op"`
+	Values []string  `json:"values"`
+
+	regexps []*regexp.Regexp // Compile 预编译的正则
+}
+
+// Regexps 预编译的正则（regex 条件经 Compile 后可用）
+func (c ToolArgCondition) Regexps() []*regexp.Regexp {
+	return c.regexps
+}
+
+// ToolArgConditions 参数条件列表（全部满足才算满足），以 JSONB 存储
+type ToolArgConditions []ToolArgCondition
+
+// Compile 预编译 regex 条件，评估时不再逐次编译
+func (c ToolArgConditions) Compile() error {
+	for i := range c {
+		if c[i].Op != ToolArgRegex {
+			continue
+		}
+		res := make([]*regexp.Regexp, 0, len(c[i].Values))
+		for _, v := range c[i].Values {
+			re, err := regexp.Compile(v)
+			if err != nil {
+				return fmt.Errorf("invalid regex %q: %w", v, err)
+			}
+			res = append(res, re)
+		}
+		c[i].regexps = res
+	}
+	return nil
+}
+
+// Invalid 条件无法解析（见 Scan）
+func (c ToolArgConditions) Invalid() bool {
+	for _, cond := range c {
+		if cond.Op == ToolArgInvalid {
+			return true
+		}
+	}
+	return false
+}
+
+// Scan 从数据库读取；JSON 或正则无法解析时不报错（否则整个公司的策略都无法加载），
+// 而是标记为 ToolArgInvalid，使该策略按 deny 处理而不是变成无条件策略
+func (c *ToolArgConditions) Scan(value any) error {
+	if value == nil {
+		*c = ToolArgConditions{}
+		return nil
+	}
+	var b []byte
+	switch v := value.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return errors.New("ToolArgConditions.Scan: unsupported type")
+	}
+	str := strings.TrimSpace(string(b))
+	if str == "" || str == "null" {
+		*c = ToolArgConditions{}
+		return nil
+	}
+	var result ToolArgConditions
+	if err := json.Unmarshal(b, &result); err != nil {
+		*c = ToolArgConditions{{Op: ToolArgInvalid}}
+		return nil
+	}
+	if err := result.Compile(); err != nil {
+		*c = ToolArgConditions{{Op: ToolArgInvalid}}
+		return nil
+	}
+	*c = result
+	return nil
+}
+
+// Value 序列化为 JSON 写入数据库
+func (c ToolArgConditions) Value() (driver.Value, error) {
+	if len(c) == 0 {
+		return "[]", nil
+	}
+	data, err := json.Marshal([]ToolArgCondition(c))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// ToolPolicy 声明式工具权限策略
+type ToolPolicy struct {
+	ID          string                `gorm:"column:id"                    json:"id"`
+	CompanyID   string                `gorm:"column:company_id"            json:"company_id"`
+	Name        string                `gorm:"column:name"                  json:"name"`
+	Description string                `gorm:"column:description"           json:"description"`
+	SubjectType ToolPolicySubjectType `gorm:"column:subject_type"          json:"subject_type"`
+	SubjectID   string                `gorm:"column:subject_id"            json:"subject_id"` // company 类型为空
+	Tool        string                `gorm:"column:tool"                  json:"tool"`       // 工具名，支持 glob（如 docker_*、*）
+	Effect      ToolPolicyEffect      `gorm:"column:effect"                json:"effect"`
+	Conditions  ToolArgConditions     `gorm:"column:conditions;type:jsonb" json:"conditions"`
+	IsActive    bool                  `gorm:"column:is_active"             json:"is_active"`
+	CreatedBy   *string               `gorm:"column:created_by"            json:"created_by,omitempty"`
+	CreatedAt   time.Time             `gorm:"column:created_at"            json:"created_at"`
+	UpdatedAt   time.Time             `gorm:"column:updated_at"            json:"updated_at"`
+}
+
+// ToolPolicyChangeAction 策略变更动作
+type ToolPolicyChangeAction string
+
+const (
+	ToolPolicyChangeCreate ToolPolicyChangeAction = "create"
+	ToolPolicyChangeUpdate ToolPolicyChangeAction = "update"
+	ToolPolicyChangeDelete ToolPolicyChangeAction = "delete"
+)
+
+// ToolPolicyChange 策略变更审计记录
+type ToolPolicyChange struct {
+	ID        string                 `gorm:"column:id"                json:"id"`
+	CompanyID string                 `gorm:"column:company_id"        json:"company_id"`
+	PolicyID  string                 `gorm:"column:policy_id"         json:"policy_id"`
+	ActorID   *string                `gorm:"column:actor_id"          json:"actor_id,omitempty"`
+	Action    ToolPolicyChangeAction `gorm:"column:action"            json:"action"`
+	Before    json.RawMessage        `gorm:"column:before;type:jsonb" json:"before,omitempty"`
+	After     json.RawMessage        `gorm:"column:after;type:jsonb"  json:"after,omitempty"`
+	CreatedAt time.Time              `gorm:"column:created_at"        json:"created_at"`
+}
+
+// ToolCallStatus 待审批工具调用状态
+type ToolCallStatus string
+
+const (
+	ToolCallPending   ToolCallStatus = "pending"   // 等待审批
+	ToolCallRunning   ToolCallStatus = "running"   // 已批准，执行中
+	ToolCallSucceeded ToolCallStatus = "succeeded" // 执行成功
+	ToolCallFailed    ToolCallStatus = "failed"    // 执行失败
+	ToolCallRejected  ToolCallStatus = "rejected"  // 审批被拒
+)
+
+// ToolCallRequest 需要审批的工具调用（审批通过后由系统代为执行）
+type ToolCallRequest struct {
+	ID         string          `gorm:"column:id"                   json:"id"`
+	CompanyID  string          `gorm:"column:company_id"           json:"company_id"`
+	AgentID    string          `gorm:"column:agent_id"             json:"agent_id"`
+	Tool       string          `gorm:"column:tool"                 json:"tool"`
+	Arguments  json.RawMessage `gorm:"column:arguments;type:jsonb" json:"arguments"`
+	PolicyID   *string         `gorm:"column:policy_id"            json:"policy_id,omitempty"`
+	ApprovalID *string         `gorm:"column:approval_id"          json:"approval_id,omitempty"`
+	Status     ToolCallStatus  `gorm:"column:status"               json:"status"`
+	Result     json.RawMessage `gorm:"column:result;type:jsonb"    json:"result,omitempty"`
+	IsError    bool            `gorm:"column:is_error"             json:"is_error"`
+	CreatedAt  time.Time       `gorm:"column:created_at"           json:"created_at"`
+	DecidedAt  *time.Time      `gorm:"column:decided_at"           json:"decided_at,omitempty"`
+	FinishedAt *time.Time      `gorm:"column:finished_at"          json:"finished_at,omitempty"`
+}
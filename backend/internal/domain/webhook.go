@@ -16,10 +16,18 @@ const (
 	WebhookEventTaskCreated   WebhookEventType = "task.created"
 	WebhookEventTaskUpdated   WebhookEventType = "task.updated"
 	WebhookEventTaskCompleted WebhookEventType = "task.completed"
+	WebhookEventTaskOverdue   WebhookEventType = "task.overdue"
+	WebhookEventTaskSLABreach WebhookEventType = "task.sla_breached"
+	WebhookEventTaskCommented WebhookEventType = "task.commented"
 	WebhookEventMessageNew    WebhookEventType = "message.new"
+	WebhookEventMessageUpdate WebhookEventType = "message.updated"
+	WebhookEventMessageAction WebhookEventType = "message.action"
+	WebhookEventChannel       WebhookEventType = "channel.event"
+	WebhookEventChannelMember WebhookEventType = "channel.member"
 	WebhookEventApprovalEvent WebhookEventType = "approval.event"
 	WebhookEventBudgetAlert   WebhookEventType = "budget.alert.created"
 	WebhookEventErrorAlert    WebhookEventType = "error_alert.created"
+	WebhookEventDigest        WebhookEventType = "digest.created"
 )
 
 type WebhookSigningKeyType string
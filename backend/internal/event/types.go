@@ -1,6 +1,9 @@
 package event
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Type 事件类型
 type Type string
@@ -11,11 +14,25 @@ const (
 	AgentStatus  Type = "agent.status"
 	TaskCreated  Type = "task.created"
 	TaskUpdated  Type = "task.updated"
+	TaskOverdue        Type = "task.overdue"
+	TaskSLABreached    Type = "task.sla_breached"
+	TaskCommented      Type = "task.commented"
 	MessageNew         Type = "message.new"
+	MessageEdited      Type = "message.edited"
+	MessageDeleted     Type = "message.deleted"
+	MessageReaction    Type = "message.reaction"
+	MessagePinned      Type = "message.pinned"
+	MessageAction      Type = "message.action"
+	ChannelCreated     Type = "channel.created"
+	ChannelUpdated     Type = "channel.updated"
+	ChannelMemberAdded Type = "channel.member_added"
+	ChannelMemberLeft  Type = "channel.member_removed"
 	AgentInitialized   Type = "agent.initialized"
 	BudgetAlertCreated Type = "llm.budget_alert.created"
 	ErrorAlertCreated  Type = "llm.error_alert.created"
 	ApprovalApproved   Type = "approval.approved"
+	ApprovalRejected   Type = "approval.rejected"
+	DigestCreated      Type = "digest.created"
 )
 
 // Event 是平台内部事件的通用结构
@@ -35,6 +52,9 @@ type AgentStatusPayload struct {
 	AgentID   string `json:"agent_id"`
 	CompanyID string `json:"company_id"`
 	Status    string `json:"status"`
+	// PrevStatus / Reason 由 PresenceService 填写，见 domain.PresenceReason*
+	PrevStatus string `json:"prev_status,omitempty"`
+	Reason     string `json:"reason,omitempty"`
 }
 
 // TaskCreatedPayload 任务创建事件 payload
@@ -54,17 +74,99 @@ type TaskUpdatedPayload struct {
 	AssigneeID *string `json:"assignee_id,omitempty"`
 }
 
+// TaskSLAPayload 任务逾期 / SLA 超时事件 payload
+type TaskSLAPayload struct {
+	TaskID          string     `json:"task_id"`
+	CompanyID       string     `json:"company_id"`
+	Title           string     `json:"title"`
+	Status          string     `json:"status"`
+	Priority        string     `json:"priority"`
+	AssigneeID      *string    `json:"assignee_id,omitempty"`
+	DueAt           *time.Time `json:"due_at,omitempty"`
+	StatusSince     time.Time  `json:"status_since"`
+	EscalatedTo     *string    `json:"escalated_to,omitempty"`
+	EscalationLevel int        `json:"escalation_level"`
+}
+
+// TaskCommentedPayload 任务评论新增 / 编辑事件 payload
+type TaskCommentedPayload struct {
+	TaskID          string   `json:"task_id"`
+	CompanyID       string   `json:"company_id"`
+	Title           string   `json:"title"`
+	CommentID       string   `json:"comment_id"`
+	ParentCommentID *string  `json:"parent_comment_id,omitempty"`
+	AgentID         string   `json:"agent_id"`
+	Content         string   `json:"content"`
+	Mentions        []string `json:"mentions"`
+	Edited          bool     `json:"edited"`
+}
+
 // MessageNewPayload 新消息事件 payload（含完整内容，前端无需二次 fetch）
 type MessageNewPayload struct {
 	MessageID   string  `json:"message_id"`
 	CompanyID   string  `json:"company_id"`
 	ChannelID   *string `json:"channel_id,omitempty"`
 	ChannelName *string `json:"channel_name,omitempty"`
+	Private     bool    `json:"private,omitempty"` // 私有频道消息，仅推送给频道成员
 	ReceiverID  *string `json:"receiver_id,omitempty"`
 	SenderID    *string `json:"sender_id,omitempty"`
+	SenderHuman bool    `json:"sender_human,omitempty"` // 人类发送的消息对所有 Agent 可见，AI 之间仅在被提及时可见
 	MsgType     string  `json:"msg_type"`
 	Content     string  `json:"content"`
 	CreatedAt   string  `json:"created_at"`
+	// rich 消息的结构化消息块
+	Blocks json.RawMessage `json:"blocks,omitempty"`
+	// 发送时解析出的被提及 agent ID（群组提及已展开）与原始群组提及
+	Mentions      []string `json:"mentions,omitempty"`
+	MentionGroups []string `json:"mention_groups,omitempty"`
+	// 线程回复：根消息 ID 与根消息发送者（用于通知线程发起人）
+	ThreadRootID       *string `json:"thread_root_id,omitempty"`
+	ThreadRootSenderID *string `json:"thread_root_sender_id,omitempty"`
+	// 由定时消息 / 提醒触发时的规则 ID；无发送者且带此字段的私信即发给收件人本人的提醒
+	ScheduleID *string `json:"schedule_id,omitempty"`
+}
+
+// MessageChangedPayload 消息编辑 / 删除 / 表情回应 / 置顶 / 按钮点击事件 payload
+type MessageChangedPayload struct {
+	MessageID    string   `json:"message_id"`
+	CompanyID    string   `json:"company_id"`
+	ChannelID    *string  `json:"channel_id,omitempty"`
+	Private      bool     `json:"private,omitempty"`
+	ReceiverID   *string  `json:"receiver_id,omitempty"`
+	SenderID     *string  `json:"sender_id,omitempty"`
+	ThreadRootID *string  `json:"thread_root_id,omitempty"`
+	ActorID      string   `json:"actor_id"`
+	Content      string   `json:"content,omitempty"`  // message.edited：新内容
+	Mentions     []string `json:"mentions,omitempty"` // message.edited：重新解析后的提及
+	Emoji        string   `json:"emoji,omitempty"`    // message.reaction
+	Removed      bool     `json:"removed,omitempty"`  // message.reaction：取消回应
+	Pinned       bool     `json:"pinned,omitempty"`   // message.pinned：false 为取消置顶
+	// message.action：被点击的按钮；处理审批卡片时附带更新后的消息块
+	ActionID string          `json:"action_id,omitempty"`
+	Value    string          `json:"value,omitempty"`
+	Blocks   json.RawMessage `json:"blocks,omitempty"`
+}
+
+// ChannelPayload 频道创建 / 修改 / 归档事件 payload
+type ChannelPayload struct {
+	ChannelID   string `json:"channel_id"`
+	CompanyID   string `json:"company_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Private     bool   `json:"private"`
+	Archived    bool   `json:"archived"`
+	ActorID     string `json:"actor_id"`
+}
+
+// ChannelMemberPayload 频道成员加入 / 角色变更 / 移除事件 payload
+type ChannelMemberPayload struct {
+	ChannelID   string `json:"channel_id"`
+	CompanyID   string `json:"company_id"`
+	ChannelName string `json:"channel_name"`
+	Private     bool   `json:"private"`
+	AgentID     string `json:"agent_id"`
+	Role        string `json:"role,omitempty"`
+	ActorID     string `json:"actor_id"`
 }
 
 func NewEvent(t Type, payload interface{}) Event {
@@ -86,6 +188,28 @@ type ApprovalApprovedPayload struct {
 	RequesterID string `json:"requester_id"`
 }
 
+type ApprovalRejectedPayload struct {
+	RequestID   string `json:"request_id"`
+	CompanyID   string `json:"company_id"`
+	RequestType string `json:"request_type"`
+	RequesterID string `json:"requester_id"`
+	Reason      string `json:"reason"`
+}
+
+// DigestCreatedPayload 以 webhook 方式投递的摘要
+type DigestCreatedPayload struct {
+	DigestID      string    `json:"digest_id"`
+	CompanyID     string    `json:"company_id"`
+	AgentID       string    `json:"agent_id"`
+	AgentName     string    `json:"agent_name"`
+	PeriodStart   time.Time `json:"period_start"`
+	PeriodEnd     time.Time `json:"period_end"`
+	Content       string    `json:"content"`
+	MessageCount  int       `json:"message_count"`
+	TaskCount     int       `json:"task_count"`
+	ApprovalCount int       `json:"approval_count"`
+}
+
 type ErrorAlertPayload struct {
 	PolicyID  string  `json:"policy_id"`
 	CompanyID string  `json:"company_id"`
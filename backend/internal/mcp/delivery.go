@@ -0,0 +1,76 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+// SSE 会话可通过 /mcp/sse?events=1&last_seq=N 订阅 Agent 投递日志，
+// 事件以 JSON-RPC 通知推送，客户端处理后调用 linkclaw/ack 确认（与 Agent WebSocket 共用同一 seq）
+const (
+	methodDeliveryEvent = "notifications/linkclaw/event"
+	methodDeliveryAck   = "linkclaw/ack"
+)
+
+type deliveryNotification struct {
+	JSONRPC string                `json:"jsonrpc"`
+	Method  string                `json:"method"`
+	Params  *domain.AgentDelivery `json:"params"`
+}
+
+type deliveryAckParams struct {
+	Seq  int64   `json:"seq"`
+	Seqs []int64 `json:"seqs"`
+}
+
+// Deliver 以 JSON-RPC 通知推送一条投递（实现 service.DeliverySink）
+func (s *Session) Deliver(d *domain.AgentDelivery) bool {
+	b, err := json.Marshal(deliveryNotification{JSONRPC: "2.0", Method: methodDeliveryEvent, Params: d})
+	if err != nil {
+		return false
+	}
+	return s.Send(string(b))
+}
+
+// subscribeDeliveries 为 SSE 会话接入投递日志，返回注销函数；未报到的 Agent 不推送
+func (s *Server) subscribeDeliveries(c *gin.Context, sess *Session) func() {
+	deliverySvc := s.handler.deliverySvc
+	if deliverySvc == nil || c.Query("events") != "1" || !sess.Agent.Initialized {
+		return func() {}
+	}
+	ctx := context.Background()
+	agentID := sess.Agent.ID
+	if lastSeq, _ := strconv.ParseInt(c.Query("last_seq"), 10, 64); lastSeq > 0 {
+		if _, err := deliverySvc.Ack(ctx, agentID, lastSeq, nil); err != nil {
+			log.Printf("[mcp] delivery resume ack for agent %s: %v", agentID, err)
+		}
+	}
+	detach := deliverySvc.Attach(agentID, sess)
+	go func() {
+		if err := deliverySvc.Resume(ctx, sess.Agent, sess); err != nil {
+			log.Printf("[mcp] delivery resume for agent %s: %v", agentID, err)
+		}
+	}()
+	return detach
+}
+
+func (h *Handler) handleDeliveryAck(ctx context.Context, sess *Session, req Request) Response {
+	if h.deliverySvc == nil {
+		return ErrorResp(req.ID, ErrMethodNotFound, "method not found: "+req.Method)
+	}
+	var p deliveryAckParams
+	if err := json.Unmarshal(req.Params, &p); err != nil || (p.Seq <= 0 && len(p.Seqs) == 0) {
+		return ErrorResp(req.ID, ErrInvalidParams, "invalid params: seq or seqs required")
+	}
+	n, err := h.deliverySvc.Ack(ctx, sess.Agent.ID, p.Seq, p.Seqs)
+	if err != nil {
+		return ErrorResp(req.ID, ErrInternal, err.Error())
+	}
+	return OKResp(req.ID, map[string]int64{"acked": n})
+}
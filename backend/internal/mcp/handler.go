@@ -3,6 +3,8 @@ package mcp
 import (
 	"context"
 	"encoding/json"
+	"log"
+	"strings"
 
 	"github.com/linkclaw/backend/internal/domain"
 	"github.com/linkclaw/backend/internal/llm"
@@ -25,6 +27,17 @@ type Handler struct {
 	obsRepo      repository.ObservabilityRepo
 	orgSvc       *service.OrganizationService
 	contextSvc   *service.ContextService
+	policySvc    *service.ToolPolicyService
+	fedSvc       *service.McpFederationService
+	limiter      *service.ToolCallLimiter
+	idemSvc      *service.IdempotencyService
+	templateSvc  *service.TaskTemplateService
+	channelSvc   *service.ChannelService
+	searchSvc    *service.MessageSearchService
+	deliverySvc  *service.AgentDeliveryService
+	scheduleSvc  *service.ScheduledMessageService
+	presenceSvc  *service.PresenceService
+	digestSvc    *service.DigestService
 }
 
 func NewHandler(
@@ -41,6 +54,17 @@ func NewHandler(
 	obsRepo repository.ObservabilityRepo,
 	orgSvc *service.OrganizationService,
 	contextSvc *service.ContextService,
+	policySvc *service.ToolPolicyService,
+	fedSvc *service.McpFederationService,
+	limiter *service.ToolCallLimiter,
+	idemSvc *service.IdempotencyService,
+	templateSvc *service.TaskTemplateService,
+	channelSvc *service.ChannelService,
+	searchSvc *service.MessageSearchService,
+	deliverySvc *service.AgentDeliveryService,
+	scheduleSvc *service.ScheduledMessageService,
+	presenceSvc *service.PresenceService,
+	digestSvc *service.DigestService,
 ) *Handler {
 	return &Handler{
 		agentSvc:     agentSvc,
@@ -56,6 +80,17 @@ func NewHandler(
 		obsRepo:      obsRepo,
 		orgSvc:       orgSvc,
 		contextSvc:   contextSvc,
+		policySvc:    policySvc,
+		fedSvc:       fedSvc,
+		limiter:      limiter,
+		idemSvc:      idemSvc,
+		templateSvc:  templateSvc,
+		channelSvc:   channelSvc,
+		searchSvc:    searchSvc,
+		deliverySvc:  deliverySvc,
+		scheduleSvc:  scheduleSvc,
+		presenceSvc:  presenceSvc,
+		digestSvc:    digestSvc,
 	}
 }
 
@@ -64,11 +99,13 @@ func (h *Handler) Handle(ctx context.Context, sess *Session, req Request) Respon
 	case "initialize":
 		return h.handleInitialize(ctx, sess, req)
 	case "tools/list":
-		return h.handleToolsList(sess, req)
+		return h.handleToolsList(ctx, sess, req)
 	case "tools/call":
 		return h.handleToolsCall(ctx, sess, req)
 	case "ping":
 		return OKResp(req.ID, map[string]string{"status": "pong"})
+	case methodDeliveryAck:
+		return h.handleDeliveryAck(ctx, sess, req)
 	default:
 		return ErrorResp(req.ID, ErrMethodNotFound, "method not found: "+req.Method)
 	}
@@ -90,11 +127,24 @@ func (h *Handler) handleInitialize(ctx context.Context, sess *Session, req Reque
 	})
 }
 
-func (h *Handler) handleToolsList(sess *Session, req Request) Response {
-	tools := ToolsForAgent(sess.Agent)
+func (h *Handler) handleToolsList(ctx context.Context, sess *Session, req Request) Response {
+	tools := ToolsForAgent(sess.Agent, h.toolPolicies(ctx, sess.Agent.CompanyID), h.federatedToolDefs(ctx, sess.Agent))
 	return OKResp(req.ID, ToolsListResult{Tools: tools})
 }
 
+// toolPolicies 加载公司工具策略；加载失败时回退为仅内置权限
+func (h *Handler) toolPolicies(ctx context.Context, companyID string) []*domain.ToolPolicy {
+	if h.policySvc == nil {
+		return nil
+	}
+	policies, err := h.policySvc.PoliciesFor(ctx, companyID)
+	if err != nil {
+		log.Printf("[mcp] load tool policies failed: %v", err)
+		return nil
+	}
+	return policies
+}
+
 func (h *Handler) handleToolsCall(ctx context.Context, sess *Session, req Request) Response {
 	if !sess.Initialized {
 		return ErrorResp(req.ID, ErrInvalidRequest, "session not initialized")
@@ -103,14 +153,35 @@ func (h *Handler) handleToolsCall(ctx context.Context, sess *Session, req Reques
 	if err := json.Unmarshal(req.Params, &params); err != nil {
 		return ErrorResp(req.ID, ErrInvalidParams, "invalid params")
 	}
+	h.presenceSvc.Activity(ctx, sess.Agent)
+
+	// 权限检查（内置权限 + 联邦工具 + 公司工具策略）
+	decision := h.checkToolPermission(ctx, sess.Agent, params.Name, params.Arguments)
+	if !decision.Allowed {
+		msg := "权限不足：你没有权限使用工具 " + params.Name
+		if decision.Source == "policy" {
+			msg += "（" + decision.Reason + "）"
+		}
+		return ErrorResp(req.ID, ErrPermission, msg)
+	}
 
-	// 权限检查
-	if !HasToolPermission(sess.Agent, params.Name) {
-		return ErrorResp(req.ID, ErrPermission, "权限不足：你没有权限使用工具 "+params.Name)
+	// 写操作工具带 _meta.idempotencyKey 时，重试直接返回首次结果
+	if key := idempotencyKeyOf(params.Name, params.Arguments); key != "" && h.idemSvc != nil {
+		return OKResp(req.ID, h.callIdempotent(ctx, sess, key, params.Name, params.Arguments, decision))
 	}
+	return OKResp(req.ID, h.executeToolCall(ctx, sess, params.Name, params.Arguments, decision))
+}
 
-	result := h.dispatchTool(ctx, sess, params.Name, params.Arguments)
-	return OKResp(req.ID, result)
+// executeToolCall 通过权限检查后的执行：限流 → 审批 → 分发
+func (h *Handler) executeToolCall(ctx context.Context, sess *Session, name string, args json.RawMessage, decision service.ToolPolicyDecision) ToolCallResult {
+	// 限流 + 循环检测（被拦截时以工具错误返回，让 Agent 知道原因）
+	if res, ok := h.checkCallLimit(ctx, sess, name, args); !ok {
+		return res
+	}
+	if decision.RequiresApproval {
+		return h.requestToolCallApproval(ctx, sess, name, args, decision)
+	}
+	return h.dispatchTool(ctx, sess, name, args)
 }
 
 func (h *Handler) dispatchTool(ctx context.Context, sess *Session, name string, args json.RawMessage) ToolCallResult {
@@ -133,8 +204,42 @@ func (h *Handler) dispatchTool(ctx context.Context, sess *Session, name string,
 		return h.toolSendMessage(ctx, sess, args)
 	case "get_messages":
 		return h.toolGetMessages(ctx, sess, args)
+	case "search_messages":
+		return h.toolSearchMessages(ctx, sess, args)
+	case "summarize_channel":
+		return h.toolSummarizeChannel(ctx, sess, args)
+	case "schedule_message":
+		return h.toolScheduleMessage(ctx, sess, args)
+	case "list_scheduled_messages":
+		return h.toolListScheduledMessages(ctx, sess, args)
+	case "cancel_scheduled_message":
+		return h.toolCancelScheduledMessage(ctx, sess, args)
+	case "reply_in_thread":
+		return h.toolReplyInThread(ctx, sess, args)
+	case "get_thread":
+		return h.toolGetThread(ctx, sess, args)
+	case "react":
+		return h.toolReact(ctx, sess, args)
+	case "edit_message":
+		return h.toolEditMessage(ctx, sess, args)
+	case "delete_message":
+		return h.toolDeleteMessage(ctx, sess, args)
+	case "pin_message":
+		return h.toolPinMessage(ctx, sess, args)
+	case "list_pinned_messages":
+		return h.toolListPinnedMessages(ctx, sess, args)
 	case "list_channels":
 		return h.toolListChannels(ctx, sess, args)
+	case "create_channel":
+		return h.toolCreateChannel(ctx, sess, args)
+	case "invite_to_channel":
+		return h.toolInviteToChannel(ctx, sess, args)
+	case "leave_channel":
+		return h.toolLeaveChannel(ctx, sess, args)
+	case "list_channel_members":
+		return h.toolListChannelMembers(ctx, sess, args)
+	case "archive_channel":
+		return h.toolArchiveChannel(ctx, sess, args)
 	case "mark_messages_read":
 		return h.toolMarkMessagesRead(ctx, sess, args)
 	// 任务
@@ -146,20 +251,46 @@ func (h *Handler) dispatchTool(ctx context.Context, sess *Session, name string,
 		return h.toolGetTaskDetail(ctx, sess, args)
 	case "add_task_comment":
 		return h.toolAddTaskComment(ctx, sess, args)
+	case "edit_task_comment":
+		return h.toolEditTaskComment(ctx, sess, args)
 	case "add_task_dependency":
 		return h.toolAddTaskDependency(ctx, sess, args)
 	case "watch_task":
 		return h.toolWatchTask(ctx, sess, args)
 	case "accept_task":
 		return h.toolAcceptTask(ctx, sess, args)
+	case "claim_task":
+		return h.toolClaimTask(ctx, sess, args)
 	case "submit_task_result":
 		return h.toolSubmitTaskResult(ctx, sess, args)
 	case "fail_task":
 		return h.toolFailTask(ctx, sess, args)
+	case "approve_task":
+		return h.toolApproveTask(ctx, sess, args)
+	case "request_task_changes":
+		return h.toolRequestTaskChanges(ctx, sess, args)
 	case "create_task":
 		return h.toolCreateTask(ctx, sess, args)
 	case "create_subtask":
 		return h.toolCreateSubtask(ctx, sess, args)
+	case "reassign_task":
+		return h.toolReassignTask(ctx, sess, args)
+	case "update_task":
+		return h.toolUpdateTask(ctx, sess, args)
+	case "set_task_due":
+		return h.toolSetTaskDue(ctx, sess, args)
+	case "attach_task_file":
+		return h.toolAttachTaskFile(ctx, sess, args)
+	case "list_task_attachments":
+		return h.toolListTaskAttachments(ctx, sess, args)
+	case "read_task_attachment":
+		return h.toolReadTaskAttachment(ctx, sess, args)
+	case "delete_task_attachment":
+		return h.toolDeleteTaskAttachment(ctx, sess, args)
+	case "list_task_templates":
+		return h.toolListTaskTemplates(ctx, sess, args)
+	case "instantiate_task_template":
+		return h.toolInstantiateTaskTemplate(ctx, sess, args)
 	case "update_persona":
 		return h.toolUpdatePersona(ctx, sess, args)
 	// 记忆
@@ -220,6 +351,8 @@ func (h *Handler) dispatchTool(ctx context.Context, sess *Session, name string,
 		return h.toolSubmitApproval(ctx, sess, args)
 	case "view_department":
 		return h.toolViewDepartment(ctx, sess, args)
+	case "get_tool_call_result":
+		return h.toolGetToolCallResult(ctx, sess, args)
 	// Context search
 	case "search_context":
 		return h.toolSearchContext(ctx, sess, args)
@@ -231,6 +364,9 @@ func (h *Handler) dispatchTool(ctx context.Context, sess *Session, name string,
 	case "agent_list_symbols":
 		return h.toolAgentListSymbols(ctx, sess, args)
 	default:
+		if strings.Contains(name, domain.McpToolSeparator) {
+			return h.callUpstreamTool(ctx, sess, name, args)
+		}
 		return ErrorResult("未知工具：" + name)
 	}
 }
@@ -1,6 +1,7 @@
 package mcp
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/linkclaw/backend/internal/domain"
 	"github.com/linkclaw/backend/internal/repository"
+	"github.com/linkclaw/backend/internal/service"
 )
 
 const (
@@ -27,18 +29,21 @@ type Server struct {
 	handler   *Handler
 	sessions  *SessionStore
 	rdb       *redis.Client
+	presence  *service.PresenceService
 }
 
 func NewServer(
 	agentRepo repository.AgentRepo,
 	handler *Handler,
 	rdb *redis.Client,
+	presence *service.PresenceService,
 ) *Server {
 	return &Server{
 		agentRepo: agentRepo,
 		handler:   handler,
 		sessions:  newSessionStore(),
 		rdb:       rdb,
+		presence:  presence,
 	}
 }
 
@@ -53,7 +58,7 @@ func (s *Server) RegisterRoutes(r gin.IRouter) {
 }
 
 // handleSSE 建立 SSE 连接
-// GET /mcp/sse  Authorization: Bearer <api_key>
+// GET /mcp/sse[?events=1&last_seq=N]  Authorization: Bearer <api_key>
 func (s *Server) handleSSE(c *gin.Context) {
 	// 1. 验证 API Key
 	agent, err := s.authenticateBearer(c)
@@ -71,9 +76,8 @@ func (s *Server) handleSSE(c *gin.Context) {
 	s.rdb.Set(c.Request.Context(),
 		fmt.Sprintf("mcp:session:%s", sessID), agent.ID, 24*time.Hour)
 
-	// 更新 agent 状态为 online
-	s.agentRepo.UpdateStatus(c.Request.Context(), agent.ID, domain.StatusOnline)
-	s.agentRepo.UpdateLastSeen(c.Request.Context(), agent.ID)
+	// 登记连接（agent 离线时转为在线）
+	s.presence.Connect(c.Request.Context(), agent, sessID)
 
 	// 3. SSE 响应头
 	c.Writer.Header().Set("Content-Type", "text/event-stream")
@@ -86,14 +90,19 @@ func (s *Server) handleSSE(c *gin.Context) {
 	fmt.Fprint(c.Writer, endpointEvent)
 	c.Writer.Flush()
 
-	// 5. 进入事件循环
+	// 5. 可选：订阅 Agent 投递日志（事件通知）
+	detach := s.subscribeDeliveries(c, sess)
+
+	// 6. 进入事件循环
 	ticker := time.NewTicker(sseKeepAlive)
 	defer func() {
 		ticker.Stop()
+		detach()
 		sess.Close()
 		s.sessions.Delete(sessID)
 		s.rdb.Del(c.Request.Context(), fmt.Sprintf("mcp:session:%s", sessID))
-		s.agentRepo.UpdateStatus(c.Request.Context(), agent.ID, domain.StatusOffline)
+		// 请求 ctx 此时已取消
+		s.presence.Disconnect(context.Background(), agent, sessID)
 	}()
 
 	for {
@@ -105,6 +114,7 @@ func (s *Server) handleSSE(c *gin.Context) {
 		case <-ticker.C:
 			fmt.Fprint(c.Writer, ": keepalive\n\n")
 			c.Writer.Flush()
+			s.presence.Heartbeat(c.Request.Context(), agent, sessID)
 
 		case <-c.Request.Context().Done():
 			return
@@ -131,6 +141,7 @@ func (s *Server) handleMessage(c *gin.Context) {
 		return
 	}
 
+	s.presence.Heartbeat(c.Request.Context(), sess.Agent, sessID)
 	resp := s.handler.Handle(c.Request.Context(), sess, req)
 
 	// 将响应通过 SSE 推回
@@ -173,8 +184,7 @@ func (s *Server) handleHTTP(c *gin.Context) {
 		s.sessions.Set(sessID, sess)
 		s.rdb.Set(c.Request.Context(),
 			fmt.Sprintf("mcp:session:%s", sessID), agent.ID, 24*time.Hour)
-		s.agentRepo.UpdateStatus(c.Request.Context(), agent.ID, domain.StatusOnline)
-		s.agentRepo.UpdateLastSeen(c.Request.Context(), agent.ID)
+		s.presence.Connect(c.Request.Context(), agent, sessID)
 	}
 
 	// session 丢失（后端重启等）时，若 Redis 仍有旧会话记录则要求客户端重新 initialize
@@ -193,9 +203,12 @@ func (s *Server) handleHTTP(c *gin.Context) {
 		s.sessions.Set(sessID, sess)
 		s.rdb.Set(c.Request.Context(),
 			fmt.Sprintf("mcp:session:%s", sessID), agent.ID, 24*time.Hour)
-		s.agentRepo.UpdateLastSeen(c.Request.Context(), agent.ID)
+		s.presence.Connect(c.Request.Context(), agent, sessID)
 	}
 
+	// HTTP 传输没有长连接，每个请求即一次心跳，客户端停止请求后由心跳过期判定离线
+	s.presence.Heartbeat(c.Request.Context(), agent, sessID)
+
 	// 通知（无 id）：仅确认
 	if req.ID == nil {
 		c.Writer.Header().Set("Mcp-Session-Id", sessID)
@@ -213,7 +226,7 @@ func (s *Server) handleHTTP(c *gin.Context) {
 func (s *Server) handleHTTPDelete(c *gin.Context) {
 	sessID := c.GetHeader("Mcp-Session-Id")
 	if sess, ok := s.sessions.Get(sessID); ok {
-		s.agentRepo.UpdateStatus(c.Request.Context(), sess.Agent.ID, domain.StatusOffline)
+		s.presence.Disconnect(c.Request.Context(), sess.Agent, sessID)
 		sess.Close()
 		s.sessions.Delete(sessID)
 		s.rdb.Del(c.Request.Context(), fmt.Sprintf("mcp:session:%s", sessID))
@@ -0,0 +1,195 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/event"
+	"github.com/linkclaw/backend/internal/service"
+)
+
+// approvedCallTimeout 审批通过后代执行工具调用的超时
+const approvedCallTimeout = 5 * time.Minute
+
+// requestToolCallApproval 命中 require_approval 策略：创建审批并返回待审批句柄
+func (h *Handler) requestToolCallApproval(ctx context.Context, sess *Session, name string, args json.RawMessage, decision service.ToolPolicyDecision) ToolCallResult {
+	if h.policySvc == nil {
+		return ErrorResult("审批服务不可用")
+	}
+	reason := fmt.Sprintf("%s 请求调用工具 %s（%s）", sess.Agent.Name, name, decision.Reason)
+	call, err := h.policySvc.RequestApproval(ctx, sess.Agent, name, args, decision, reason)
+	if err != nil {
+		return ErrorResult("提交工具调用审批失败：" + err.Error())
+	}
+	approvalID := ""
+	if call.ApprovalID != nil {
+		approvalID = *call.ApprovalID
+	}
+	return okResult(map[string]any{
+		"status":      domain.ToolCallPending,
+		"call_id":     call.ID,
+		"approval_id": approvalID,
+		"message":     fmt.Sprintf("工具 %s 需要审批，已提交给上级。审批通过后系统会自动执行并私信通知你，也可以用 get_tool_call_result 查询结果。", name),
+	})
+}
+
+// StartToolCallExecutor 订阅审批事件：通过则代执行工具调用，拒绝则关闭调用。返回取消订阅函数。
+func (h *Handler) StartToolCallExecutor() func() {
+	unsubApproved := event.Global.Subscribe(event.ApprovalApproved, func(e event.Event) {
+		var p event.ApprovalApprovedPayload
+		if err := json.Unmarshal(e.Payload, &p); err != nil || p.RequestType != string(domain.ApprovalToolCall) {
+			return
+		}
+		go h.executeApprovedCall(p.RequestID)
+	})
+	unsubRejected := event.Global.Subscribe(event.ApprovalRejected, func(e event.Event) {
+		var p event.ApprovalRejectedPayload
+		if err := json.Unmarshal(e.Payload, &p); err != nil || p.RequestType != string(domain.ApprovalToolCall) {
+			return
+		}
+		go h.closeRejectedCall(p.RequestID, p.Reason)
+	})
+	return func() {
+		unsubApproved()
+		unsubRejected()
+	}
+}
+
+func (h *Handler) executeApprovedCall(approvalID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), approvedCallTimeout)
+	defer cancel()
+
+	call, err := h.policySvc.BeginApprovedCall(ctx, approvalID)
+	if err != nil {
+		log.Printf("[mcp] begin approved tool call %s: %v", approvalID, err)
+		return
+	}
+	if call == nil {
+		return // 已被处理
+	}
+
+	result := h.runApprovedCall(ctx, call)
+	data, _ := json.Marshal(result)
+	if err := h.policySvc.FinishCall(ctx, call.ID, data, result.IsError); err != nil {
+		log.Printf("[mcp] finish tool call %s: %v", call.ID, err)
+	}
+
+	status := "执行成功"
+	if result.IsError {
+		status = "执行失败"
+	}
+	h.notifyToolCallResult(ctx, call, fmt.Sprintf("🔓 你申请的工具调用 %s 已审批通过，%s（call_id: %s）。\n\n%s",
+		call.Tool, status, call.ID, truncate(resultText(result), 2000)))
+}
+
+// runApprovedCall 以申请人身份执行调用；执行前按最新策略复核，期间若改为 deny 则不执行
+func (h *Handler) runApprovedCall(ctx context.Context, call *domain.ToolCallRequest) ToolCallResult {
+	agent, err := h.agentSvc.GetByID(ctx, call.AgentID)
+	if err != nil || agent == nil {
+		return ErrorResult("申请人不存在，调用未执行")
+	}
+	decision := h.checkToolPermission(ctx, agent, call.Tool, call.Arguments)
+	if !decision.Allowed {
+		return ErrorResult("权限已变更，调用未执行：" + decision.Reason)
+	}
+	sess := &Session{ID: "approved-call-" + call.ID, Agent: agent, Initialized: true, ConnectedAt: time.Now()}
+	return h.dispatchTool(ctx, sess, call.Tool, call.Arguments)
+}
+
+func (h *Handler) closeRejectedCall(approvalID, reason string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	call, err := h.policySvc.RejectCall(ctx, approvalID)
+	if err != nil {
+		log.Printf("[mcp] reject tool call %s: %v", approvalID, err)
+		return
+	}
+	if call == nil {
+		return
+	}
+	msg := fmt.Sprintf("🔒 你申请的工具调用 %s 未通过审批，未执行（call_id: %s）。", call.Tool, call.ID)
+	if strings.TrimSpace(reason) != "" {
+		msg += "\n审批意见：" + reason
+	}
+	h.notifyToolCallResult(ctx, call, msg)
+}
+
+// notifyToolCallResult 以系统私信通知申请人（Agent WS / 未读推送会送达）
+func (h *Handler) notifyToolCallResult(ctx context.Context, call *domain.ToolCallRequest, content string) {
+	if h.messageSvc == nil {
+		return
+	}
+	receiverID := call.AgentID
+	msg := &domain.Message{
+		ID:         uuid.New().String(),
+		CompanyID:  call.CompanyID,
+		ReceiverID: &receiverID,
+		Content:    content,
+		MsgType:    domain.MsgTypeSystem,
+		CreatedAt:  time.Now(),
+	}
+	if err := h.messageSvc.SendRaw(ctx, msg); err != nil {
+		log.Printf("[mcp] notify tool call result %s: %v", call.ID, err)
+	}
+}
+
+// toolGetToolCallResult 查询需审批工具调用的状态与结果
+func (h *Handler) toolGetToolCallResult(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		CallID string `json:"call_id"`
+	}
+	_ = json.Unmarshal(args, &p)
+	if h.policySvc == nil {
+		return ErrorResult("审批服务不可用")
+	}
+
+	if p.CallID == "" {
+		calls, err := h.policySvc.ListCalls(ctx, sess.Agent.ID, 10)
+		if err != nil {
+			return ErrorResult("查询失败：" + err.Error())
+		}
+		if len(calls) == 0 {
+			return TextResult("暂无需要审批的工具调用。")
+		}
+		var sb strings.Builder
+		sb.WriteString("最近的审批工具调用：\n")
+		for _, c := range calls {
+			fmt.Fprintf(&sb, "- %s  %s  [%s]  %s\n", c.ID, c.Tool, c.Status, c.CreatedAt.Format("01-02 15:04"))
+		}
+		return TextResult(sb.String())
+	}
+
+	call, err := h.policySvc.GetCall(ctx, sess.Agent.ID, p.CallID)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	out := map[string]any{
+		"call_id": call.ID,
+		"tool":    call.Tool,
+		"status":  call.Status,
+	}
+	if len(call.Result) > 0 {
+		var r ToolCallResult
+		if err := json.Unmarshal(call.Result, &r); err == nil {
+			out["result"] = resultText(r)
+			out["is_error"] = r.IsError
+		}
+	}
+	return okResult(out)
+}
+
+func resultText(r ToolCallResult) string {
+	parts := make([]string, 0, len(r.Content))
+	for _, c := range r.Content {
+		parts = append(parts, c.Text)
+	}
+	return strings.Join(parts, "\n")
+}
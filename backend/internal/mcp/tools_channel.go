@@ -0,0 +1,186 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/service"
+)
+
+func channelLabel(ch *domain.Channel) string {
+	label := "#" + ch.Name
+	if ch.IsPrivate {
+		label += "（私有）"
+	}
+	if ch.IsArchived() {
+		label += "（已归档）"
+	}
+	return label
+}
+
+func (h *Handler) toolListChannels(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		IncludeArchived bool `json:"include_archived"`
+	}
+	json.Unmarshal(args, &p) //nolint:errcheck
+
+	channels, err := h.channelSvc.List(ctx, sess.Agent, false, p.IncludeArchived)
+	if err != nil {
+		return ErrorResult("获取频道列表失败: " + err.Error())
+	}
+	if len(channels) == 0 {
+		return TextResult("暂无频道")
+	}
+	var lines []string
+	for _, ch := range channels {
+		defaultMark := ""
+		if ch.IsDefault {
+			defaultMark = "（默认）"
+		}
+		lines = append(lines, fmt.Sprintf("  %s%s — %s", channelLabel(ch), defaultMark, ch.Description))
+	}
+	return TextResult("频道列表：\n" + strings.Join(lines, "\n"))
+}
+
+func (h *Handler) toolCreateChannel(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Private     bool     `json:"private"`
+		Members     []string `json:"members"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.Name == "" {
+		return ErrorResult("参数错误：需要 name")
+	}
+	dir := h.buildDirectory(ctx, sess.Agent.CompanyID)
+	memberIDs := make([]string, 0, len(p.Members))
+	for _, m := range p.Members {
+		memberIDs = append(memberIDs, dir.resolve(strings.TrimSpace(m)))
+	}
+
+	ch, members, err := h.channelSvc.Create(ctx, sess.Agent, service.CreateChannelInput{
+		Name:        p.Name,
+		Description: p.Description,
+		Private:     p.Private,
+		MemberIDs:   memberIDs,
+	})
+	if err != nil {
+		return ErrorResult("创建频道失败: " + err.Error())
+	}
+	result := fmt.Sprintf("已创建频道 %s（ID：%s），你是频道 owner", channelLabel(ch), ch.ID)
+	if len(members) > 1 {
+		names := make([]string, 0, len(members)-1)
+		for _, m := range members[1:] {
+			names = append(names, dir.label(m.AgentID))
+		}
+		result += "，已邀请：" + strings.Join(names, "、")
+	}
+	return TextResult(result)
+}
+
+func (h *Handler) toolInviteToChannel(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		Channel string   `json:"channel"`
+		Members []string `json:"members"`
+		Role    string   `json:"role"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.Channel == "" || len(p.Members) == 0 {
+		return ErrorResult("参数错误：需要 channel 和 members")
+	}
+	ch, err := h.channelSvc.Find(ctx, sess.Agent, p.Channel)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	dir := h.buildDirectory(ctx, sess.Agent.CompanyID)
+	var added, failed []string
+	for _, m := range p.Members {
+		id := dir.resolve(strings.TrimSpace(m))
+		if _, err := h.channelSvc.AddMember(ctx, sess.Agent, ch.ID, id, domain.ChannelRole(p.Role)); err != nil {
+			failed = append(failed, fmt.Sprintf("%s（%s）", m, err.Error()))
+			continue
+		}
+		added = append(added, dir.label(id))
+	}
+	var lines []string
+	if len(added) > 0 {
+		lines = append(lines, fmt.Sprintf("已将 %s 加入 %s", strings.Join(added, "、"), channelLabel(ch)))
+	}
+	if len(failed) > 0 {
+		lines = append(lines, "以下成员未能加入："+strings.Join(failed, "；"))
+	}
+	if len(added) == 0 {
+		return ErrorResult(strings.Join(lines, "\n"))
+	}
+	return TextResult(strings.Join(lines, "\n"))
+}
+
+func (h *Handler) toolLeaveChannel(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		Channel string `json:"channel"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.Channel == "" {
+		return ErrorResult("参数错误：需要 channel")
+	}
+	ch, err := h.channelSvc.Find(ctx, sess.Agent, p.Channel)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	if err := h.channelSvc.RemoveMember(ctx, sess.Agent, ch.ID, sess.Agent.ID); err != nil {
+		return ErrorResult("退出频道失败: " + err.Error())
+	}
+	return TextResult(fmt.Sprintf("已退出 %s", channelLabel(ch)))
+}
+
+func (h *Handler) toolListChannelMembers(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		Channel string `json:"channel"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.Channel == "" {
+		return ErrorResult("参数错误：需要 channel")
+	}
+	ch, err := h.channelSvc.Find(ctx, sess.Agent, p.Channel)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	members, err := h.channelSvc.ListMembers(ctx, sess.Agent, ch.ID)
+	if err != nil {
+		return ErrorResult("获取频道成员失败: " + err.Error())
+	}
+	if len(members) == 0 {
+		if ch.IsPrivate {
+			return TextResult(fmt.Sprintf("%s 暂无成员", channelLabel(ch)))
+		}
+		return TextResult(fmt.Sprintf("%s 是公开频道，全员可见，暂无指定角色的成员", channelLabel(ch)))
+	}
+	dir := h.buildDirectory(ctx, sess.Agent.CompanyID)
+	lines := []string{fmt.Sprintf("%s 成员（%d）：", channelLabel(ch), len(members))}
+	for _, m := range members {
+		lines = append(lines, fmt.Sprintf("  - %s [%s]", dir.label(m.AgentID), m.Role))
+	}
+	return TextResult(strings.Join(lines, "\n"))
+}
+
+func (h *Handler) toolArchiveChannel(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		Channel   string `json:"channel"`
+		Unarchive bool   `json:"unarchive"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.Channel == "" {
+		return ErrorResult("参数错误：需要 channel")
+	}
+	ch, err := h.channelSvc.Find(ctx, sess.Agent, p.Channel)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	ch, err = h.channelSvc.SetArchived(ctx, sess.Agent, ch.ID, !p.Unarchive)
+	if err != nil {
+		return ErrorResult("操作失败: " + err.Error())
+	}
+	if p.Unarchive {
+		return TextResult(fmt.Sprintf("已恢复频道 #%s", ch.Name))
+	}
+	return TextResult(fmt.Sprintf("已归档频道 #%s，频道内容仍可查看但不能再发消息", ch.Name))
+}
@@ -0,0 +1,30 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+func (h *Handler) toolSummarizeChannel(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		Channel string `json:"channel"`
+		Hours   int    `json:"hours"`
+		Focus   string `json:"focus"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || strings.TrimSpace(p.Channel) == "" {
+		return ErrorResult("参数错误：需要 channel")
+	}
+
+	sum, err := h.digestSvc.SummarizeChannel(ctx, sess.Agent, p.Channel, p.Hours, p.Focus)
+	if err != nil {
+		return ErrorResult("频道摘要失败: " + err.Error())
+	}
+	if sum.MessageCount == 0 {
+		return TextResult(fmt.Sprintf("#%s 自 %s 以来没有新消息", sum.Channel, sum.Since.Format("2006-01-02 15:04")))
+	}
+	result := fmt.Sprintf("#%s 自 %s 以来共 %d 条消息，摘要如下：\n\n%s",
+		sum.Channel, sum.Since.Format("2006-01-02 15:04"), sum.MessageCount, sum.Summary)
+	return TextResult(result)
+}
@@ -0,0 +1,87 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/service"
+)
+
+// UpstreamToolDef 联邦工具的权限定义（Perm 取上游的 required_perm）
+func UpstreamToolDef(u *domain.McpUpstream, name string) ToolDef {
+	return ToolDef{Tool: Tool{Name: name}, Perm: u.RequiredPerm}
+}
+
+// federatedToolDef 将上游工具转换为本地工具定义；无法识别的 schema 退化为 {type: object}
+func federatedToolDef(ft service.FederatedTool) ToolDef {
+	schema := InputSchema{Type: "object"}
+	if len(ft.InputSchema) > 0 {
+		var parsed InputSchema
+		if err := json.Unmarshal(ft.InputSchema, &parsed); err == nil && parsed.Type == "object" {
+			schema = parsed
+		}
+	}
+	return ToolDef{
+		Tool: Tool{Name: ft.Name, Description: ft.Description, InputSchema: schema},
+		Perm: ft.RequiredPerm,
+	}
+}
+
+// federatedToolDefs Agent 可见的全部联邦工具定义（未配置联邦时为空）
+func (h *Handler) federatedToolDefs(ctx context.Context, agent *domain.Agent) []ToolDef {
+	if h.fedSvc == nil {
+		return nil
+	}
+	tools := h.fedSvc.ListTools(ctx, agent)
+	defs := make([]ToolDef, 0, len(tools))
+	for _, ft := range tools {
+		defs = append(defs, federatedToolDef(ft))
+	}
+	return defs
+}
+
+// federatedToolDefFor 按工具名解析单个联邦工具的权限定义，调用时无需拉取上游工具列表
+func (h *Handler) federatedToolDefFor(ctx context.Context, companyID, name string) []ToolDef {
+	if h.fedSvc == nil || !strings.Contains(name, domain.McpToolSeparator) {
+		return nil
+	}
+	u, _, err := h.fedSvc.Resolve(ctx, companyID, name)
+	if err != nil || u == nil {
+		return nil
+	}
+	return []ToolDef{UpstreamToolDef(u, name)}
+}
+
+// checkToolPermission 内置工具 + 联邦工具的统一权限检查
+func (h *Handler) checkToolPermission(ctx context.Context, agent *domain.Agent, name string, args json.RawMessage) service.ToolPolicyDecision {
+	return CheckToolPermission(agent, h.toolPolicies(ctx, agent.CompanyID), name, args,
+		h.federatedToolDefFor(ctx, agent.CompanyID, name))
+}
+
+// callUpstreamTool 代理调用联邦工具
+func (h *Handler) callUpstreamTool(ctx context.Context, sess *Session, name string, args json.RawMessage) ToolCallResult {
+	if h.fedSvc == nil {
+		return ErrorResult("未知工具：" + name)
+	}
+	u, tool, err := h.fedSvc.Resolve(ctx, sess.Agent.CompanyID, name)
+	if err != nil {
+		return ErrorResult("解析上游工具失败：" + err.Error())
+	}
+	if u == nil {
+		return ErrorResult("未知工具：" + name)
+	}
+	res, err := h.fedSvc.CallTool(ctx, sess.Agent, u, tool, args)
+	if err != nil {
+		return ErrorResult("上游 " + u.Name + " 调用失败：" + err.Error())
+	}
+	out := ToolCallResult{IsError: res.IsError}
+	for _, c := range res.Content {
+		out.Content = append(out.Content, ContentBlock{Type: "text", Text: c.Text})
+	}
+	if len(out.Content) == 0 {
+		out.Content = []ContentBlock{{Type: "text", Text: ""}}
+	}
+	return out
+}
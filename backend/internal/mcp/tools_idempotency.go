@@ -0,0 +1,79 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/service"
+)
+
+// idempotentTools 支持幂等键的写操作工具
+var idempotentTools = map[string]bool{
+	"create_task":      true,
+	"create_subtask":   true,
+	"attach_task_file": true,
+	"send_message":     true,
+	"reply_in_thread":  true,
+	"create_channel":   true,
+	"write_document":   true,
+	"remember":         true,
+	"hire":             true,
+}
+
+// idempotencyKeyOf 读取参数中的 _meta.idempotencyKey；非幂等工具返回空
+func idempotencyKeyOf(tool string, args json.RawMessage) string {
+	if !idempotentTools[tool] || len(args) == 0 {
+		return ""
+	}
+	var p struct {
+		Meta struct {
+			IdempotencyKey string `json:"idempotencyKey"`
+		} `json:"_meta"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil {
+		return ""
+	}
+	return p.Meta.IdempotencyKey
+}
+
+// idempotencyHash 工具名 + 去掉 _meta 后的规范化参数
+func idempotencyHash(tool string, args json.RawMessage) string {
+	var m map[string]any
+	if err := json.Unmarshal(args, &m); err != nil {
+		return service.HashIdempotentRequest(tool, string(args))
+	}
+	delete(m, "_meta")
+	canonical, _ := json.Marshal(m)
+	return service.HashIdempotentRequest(tool, string(canonical))
+}
+
+// callIdempotent 以幂等方式执行工具：失败结果不保存，允许以同一键重试
+func (h *Handler) callIdempotent(ctx context.Context, sess *Session, key, name string, args json.RawMessage, decision service.ToolPolicyDecision) ToolCallResult {
+	scope := domain.IdempotencyScopeMCP
+	rec, lockToken, err := h.idemSvc.Begin(ctx, sess.Agent.ID, scope, key, idempotencyHash(name, args))
+	switch {
+	case errors.Is(err, service.ErrIdempotencyInProgress):
+		return ErrorResult("相同 idempotencyKey 的调用仍在执行中，请稍后重试")
+	case errors.Is(err, service.ErrIdempotencyMismatch):
+		return ErrorResult("idempotencyKey 已用于参数不同的调用，请为新操作使用新的 key")
+	case err != nil:
+		return ErrorResult(err.Error())
+	case rec != nil:
+		var stored ToolCallResult
+		if err := json.Unmarshal([]byte(rec.Response), &stored); err == nil {
+			return stored
+		}
+		return ErrorResult("读取幂等结果失败")
+	}
+
+	result := h.executeToolCall(ctx, sess, name, args, decision)
+	if result.IsError {
+		h.idemSvc.Release(context.WithoutCancel(ctx), sess.Agent.ID, scope, key, lockToken)
+		return result
+	}
+	data, _ := json.Marshal(result)
+	h.idemSvc.Complete(context.WithoutCancel(ctx), sess.Agent.ID, scope, key, lockToken, 0, string(data))
+	return result
+}
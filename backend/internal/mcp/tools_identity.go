@@ -45,7 +45,7 @@ func (h *Handler) toolGetIdentity(ctx context.Context, sess *Session, args json.
 	meta := domain.PositionMetaByPosition[agent.Position]
 
 	// 工具说明（根据权限裁剪）
-	agentTools := ToolsForAgent(agent)
+	agentTools := ToolsForAgent(agent, h.toolPolicies(ctx, agent.CompanyID), h.federatedToolDefs(ctx, agent))
 	toolNames := make([]string, 0, len(agentTools))
 	for _, t := range agentTools {
 		toolNames = append(toolNames, t.Name)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"strconv"
 	"strings"
 
@@ -13,16 +14,28 @@ import (
 
 func (h *Handler) toolSendMessage(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
 	var p struct {
-		Channel    string `json:"channel"`
-		ReceiverID string `json:"receiver_id"`
-		Content    string `json:"content"`
+		Channel    string          `json:"channel"`
+		ReceiverID string          `json:"receiver_id"`
+		Content    string          `json:"content"`
+		Blocks     json.RawMessage `json:"blocks"`
 	}
-	if err := json.Unmarshal(args, &p); err != nil || p.Content == "" {
+	if err := json.Unmarshal(args, &p); err != nil {
 		return ErrorResult("参数错误：需要 content")
 	}
+	blockArgs, err := blockArgsFrom(p.Blocks)
+	if err != nil {
+		return ErrorResult("参数错误：blocks 必须是数组")
+	}
+	if p.Content == "" && len(blockArgs) == 0 {
+		return ErrorResult("参数错误：需要 content 或 blocks")
+	}
 	if p.Channel == "" && p.ReceiverID == "" {
 		return ErrorResult("参数错误：需要指定 channel 或 receiver_id")
 	}
+	blocks, err := h.buildMessageBlocks(ctx, sess, blockArgs)
+	if err != nil {
+		return ErrorResult("参数错误：" + err.Error())
+	}
 
 	// receiver_id 支持传名字，自动解析为 ID
 	dir := h.buildDirectory(ctx, sess.Agent.CompanyID)
@@ -31,12 +44,13 @@ func (h *Handler) toolSendMessage(ctx context.Context, sess *Session, args json.
 		receiverID = dir.resolve(receiverID)
 	}
 
-	_, err := h.messageSvc.Send(ctx, service.SendMessageInput{
+	msg, err := h.messageSvc.Send(ctx, service.SendMessageInput{
 		CompanyID:  sess.Agent.CompanyID,
 		SenderID:   sess.Agent.ID,
 		Channel:    p.Channel,
 		ReceiverID: receiverID,
 		Content:    p.Content,
+		Blocks:     blocks,
 	})
 	if err != nil {
 		return ErrorResult("发送消息失败: " + err.Error())
@@ -50,7 +64,21 @@ func (h *Handler) toolSendMessage(ctx context.Context, sess *Session, args json.
 			target = "私信 " + receiverID
 		}
 	}
-	return TextResult(fmt.Sprintf("消息已发送到 %s", target))
+	result := fmt.Sprintf("消息已发送到 %s", target)
+	if len(msg.MentionGroups) > 0 {
+		result += "，群组提及：@" + strings.Join(msg.MentionGroups, " @")
+	}
+	if n := len(msg.Mentions); n > 0 {
+		names := make([]string, 0, n)
+		for _, id := range msg.Mentions {
+			names = append(names, dir.label(id))
+		}
+		if n > 10 {
+			names = append(names[:10], fmt.Sprintf("等 %d 人", n))
+		}
+		result += "，已提及：" + strings.Join(names, "、")
+	}
+	return TextResult(result)
 }
 
 func (h *Handler) toolGetMessages(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
@@ -80,21 +108,12 @@ func (h *Handler) toolGetMessages(ctx context.Context, sess *Session, args json.
 
 	var msgs []string
 	if p.Channel != "" {
-		ms, err := h.messageSvc.GetChannelMessages(ctx, sess.Agent.CompanyID, p.Channel, limit, p.BeforeID)
+		ms, err := h.messageSvc.GetChannelMessages(ctx, sess.Agent.CompanyID, sess.Agent.ID, p.Channel, limit, p.BeforeID)
 		if err != nil {
 			return ErrorResult(err.Error())
 		}
 		for _, m := range ms {
-			sender := "系统"
-			if m.SenderID != nil {
-				if label, ok := dir.labels[*m.SenderID]; ok {
-					sender = label
-				} else {
-					sender = *m.SenderID
-				}
-			}
-			msgs = append(msgs, fmt.Sprintf("[%s] %s: %s",
-				m.CreatedAt.Format("15:04"), sender, m.Content))
+			msgs = append(msgs, formatMessageLine(m, senderLabel(dir, m, sess.Agent.ID), sess.Agent.ID))
 		}
 	} else if receiverID != "" {
 		ms, err := h.messageSvc.GetDMMessages(ctx, sess.Agent.ID, receiverID, limit, p.BeforeID)
@@ -102,16 +121,7 @@ func (h *Handler) toolGetMessages(ctx context.Context, sess *Session, args json.
 			return ErrorResult(err.Error())
 		}
 		for _, m := range ms {
-			sender := "对方"
-			if m.SenderID != nil {
-				if *m.SenderID == sess.Agent.ID {
-					sender = "你"
-				} else if label, ok := dir.labels[*m.SenderID]; ok {
-					sender = label
-				}
-			}
-			msgs = append(msgs, fmt.Sprintf("[%s] %s: %s",
-				m.CreatedAt.Format("15:04"), sender, m.Content))
+			msgs = append(msgs, formatMessageLine(m, senderLabel(dir, m, sess.Agent.ID), sess.Agent.ID))
 		}
 	} else {
 		return ErrorResult("需要指定 channel 或 receiver_id")
@@ -120,6 +130,7 @@ func (h *Handler) toolGetMessages(ctx context.Context, sess *Session, args json.
 	if len(msgs) == 0 {
 		return TextResult("暂无消息")
 	}
+	// 只列出顶层消息，线程回复用 get_thread 查看
 	return TextResult(strings.Join(msgs, "\n"))
 }
 
@@ -144,6 +155,12 @@ func (h *Handler) toolMarkMessagesRead(ctx context.Context, sess *Session, args
 	if err := h.messageSvc.MarkRead(ctx, sess.Agent.ID, ids); err != nil {
 		return ErrorResult("标记已读失败: " + err.Error())
 	}
+	// 已读即确认，对应的投递不再重推
+	if h.deliverySvc != nil {
+		if err := h.deliverySvc.AckMessages(ctx, sess.Agent.ID, ids); err != nil {
+			log.Printf("[mcp] ack deliveries for agent %s: %v", sess.Agent.ID, err)
+		}
+	}
 	return TextResult(fmt.Sprintf("已标记 %d 条消息为已读", len(ids)))
 }
 
@@ -193,21 +210,10 @@ func (d *agentDirectory) resolve(receiver string) string {
 	return receiver // 原样返回，让下游报错
 }
 
-func (h *Handler) toolListChannels(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
-	channels, err := h.messageSvc.ListChannels(ctx, sess.Agent.CompanyID)
-	if err != nil {
-		return ErrorResult("获取频道列表失败: " + err.Error())
-	}
-	if len(channels) == 0 {
-		return TextResult("暂无频道")
-	}
-	var lines []string
-	for _, ch := range channels {
-		defaultMark := ""
-		if ch.IsDefault {
-			defaultMark = "（默认）"
-		}
-		lines = append(lines, fmt.Sprintf("  #%s%s — %s", ch.Name, defaultMark, ch.Description))
+// label 返回 agent 的"职位-名字"，未知 ID 原样返回
+func (d *agentDirectory) label(id string) string {
+	if l, ok := d.labels[id]; ok {
+		return l
 	}
-	return TextResult("频道列表：\n" + strings.Join(lines, "\n"))
+	return id
 }
@@ -0,0 +1,94 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/service"
+)
+
+// blockArg send_message 的 blocks 参数：按 type 填写对应字段（扁平结构，便于模型生成）
+type blockArg struct {
+	Type     domain.MessageBlockType `json:"type"`
+	Text     string                  `json:"text"`
+	Language string                  `json:"language"`
+	Code     string                  `json:"code"`
+	// file：已上传的 attachment_id，或直接提供 filename + content_base64
+	AttachmentID  string                  `json:"attachment_id"`
+	Filename      string                  `json:"filename"`
+	ContentBase64 string                  `json:"content_base64"`
+	MimeType      string                  `json:"mime_type"`
+	TaskID        string                  `json:"task_id"`
+	ApprovalID    string                  `json:"approval_id"`
+	DocID         string                  `json:"doc_id"`
+	Actions       []*domain.MessageAction `json:"actions"`
+}
+
+// buildMessageBlocks 把工具参数转换为消息块；内联文件先上传为消息附件
+func (h *Handler) buildMessageBlocks(ctx context.Context, sess *Session, args []blockArg) ([]*domain.MessageBlock, error) {
+	blocks := make([]*domain.MessageBlock, 0, len(args))
+	for i, a := range args {
+		b := &domain.MessageBlock{Type: a.Type}
+		switch a.Type {
+		case domain.BlockText:
+			b.Text = a.Text
+		case domain.BlockCode:
+			b.Language, b.Code = a.Language, a.Code
+		case domain.BlockFile:
+			id := a.AttachmentID
+			if id == "" {
+				if a.Filename == "" || a.ContentBase64 == "" {
+					return nil, fmt.Errorf("第 %d 个块：file 需要 attachment_id，或 filename 与 content_base64", i+1)
+				}
+				data, err := base64.StdEncoding.DecodeString(a.ContentBase64)
+				if err != nil {
+					return nil, fmt.Errorf("第 %d 个块：content_base64 不是有效的 base64", i+1)
+				}
+				att, err := h.messageSvc.UploadAttachment(ctx, sess.Agent, service.TaskUploadFile{
+					OriginalFilename: a.Filename,
+					MimeType:         a.MimeType,
+					Content:          data,
+				})
+				if err != nil {
+					return nil, fmt.Errorf("第 %d 个块：上传文件失败: %w", i+1, err)
+				}
+				id = att.ID
+			}
+			b.File = &domain.MessageFile{AttachmentID: id}
+		case domain.BlockTask:
+			b.Task = &domain.TaskMeta{TaskID: a.TaskID}
+		case domain.BlockApproval:
+			b.Approval = &domain.ApprovalCard{ID: a.ApprovalID}
+		case domain.BlockKnowledge:
+			b.Knowledge = &domain.KnowledgeCard{DocID: a.DocID}
+		case domain.BlockActions:
+			b.Actions = a.Actions
+		default:
+			return nil, fmt.Errorf("第 %d 个块：未知类型 %q", i+1, a.Type)
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks, nil
+}
+
+// blockArgsFrom 兼容 blocks 以 JSON 字符串传入的客户端
+func blockArgsFrom(raw json.RawMessage) ([]blockArg, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var args []blockArg
+	if err := json.Unmarshal(raw, &args); err == nil {
+		return args, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(s), &args); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
@@ -0,0 +1,146 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/service"
+)
+
+func (h *Handler) toolScheduleMessage(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		Content    string `json:"content"`
+		Channel    string `json:"channel"`
+		ReceiverID string `json:"receiver_id"`
+		RemindMe   bool   `json:"remind_me"`
+		TaskID     string `json:"task_id"`
+		SendAt     string `json:"send_at"`
+		InMinutes  int    `json:"in_minutes"`
+		Cron       string `json:"cron"`
+		Timezone   string `json:"timezone"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil {
+		return ErrorResult("参数错误：" + err.Error())
+	}
+	if strings.TrimSpace(p.Content) == "" {
+		return ErrorResult("参数错误：需要 content")
+	}
+	n := 0
+	for _, set := range []bool{p.SendAt != "", p.InMinutes > 0, p.Cron != ""} {
+		if set {
+			n++
+		}
+	}
+	if n != 1 {
+		return ErrorResult("参数错误：send_at、in_minutes、cron 需且只能提供一个")
+	}
+
+	in := service.ScheduleMessageInput{
+		Kind:     domain.ScheduleKindMessage,
+		Channel:  p.Channel,
+		TaskID:   p.TaskID,
+		Content:  p.Content,
+		Cron:     p.Cron,
+		Timezone: p.Timezone,
+	}
+	if p.RemindMe {
+		in.Kind = domain.ScheduleKindReminder
+	}
+	if p.ReceiverID != "" {
+		in.ReceiverID = h.buildDirectory(ctx, sess.Agent.CompanyID).resolve(p.ReceiverID)
+	}
+	switch {
+	case p.SendAt != "":
+		at, err := time.Parse(time.RFC3339, strings.TrimSpace(p.SendAt))
+		if err != nil {
+			return ErrorResult("参数错误：send_at 需为 RFC3339 格式，如 2026-01-02T09:00:00+08:00")
+		}
+		in.SendAt = &at
+	case p.InMinutes > 0:
+		at := time.Now().Add(time.Duration(p.InMinutes) * time.Minute)
+		in.SendAt = &at
+	}
+
+	m, err := h.scheduleSvc.Create(ctx, sess.Agent, in)
+	if err != nil {
+		return ErrorResult("创建定时消息失败: " + err.Error())
+	}
+	what := "定时消息"
+	if m.Kind == domain.ScheduleKindReminder {
+		what = "提醒"
+	}
+	result := fmt.Sprintf("%s已创建（ID: %s），%s", what, m.ID, h.describeSchedule(ctx, sess, m))
+	return TextResult(result)
+}
+
+func (h *Handler) toolListScheduledMessages(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		IncludeDone bool `json:"include_done"`
+	}
+	_ = json.Unmarshal(args, &p)
+
+	list, err := h.scheduleSvc.List(ctx, sess.Agent, false, !p.IncludeDone)
+	if err != nil {
+		return ErrorResult("查询定时消息失败: " + err.Error())
+	}
+	if len(list) == 0 {
+		return TextResult("暂无定时消息或提醒")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "共 %d 条：\n", len(list))
+	for _, m := range list {
+		fmt.Fprintf(&b, "- [%s] %s，%s：%s", m.Status, m.ID, h.describeSchedule(ctx, sess, m), truncate(m.Content, 80))
+		if m.LastError != "" {
+			fmt.Fprintf(&b, "（上次失败：%s）", m.LastError)
+		}
+		b.WriteString("\n")
+	}
+	return TextResult(b.String())
+}
+
+func (h *Handler) toolCancelScheduledMessage(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		ScheduleID string `json:"schedule_id"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.ScheduleID == "" {
+		return ErrorResult("参数错误：需要 schedule_id")
+	}
+	if _, err := h.scheduleSvc.Cancel(ctx, sess.Agent, p.ScheduleID); err != nil {
+		return ErrorResult("取消失败: " + err.Error())
+	}
+	return TextResult("已取消定时消息 " + p.ScheduleID)
+}
+
+// describeSchedule 描述发送目标与时间，如 "发到 #general，cron 0 9 * * 1-5（Asia/Shanghai），下次 2026-01-02T09:00:00+08:00"
+func (h *Handler) describeSchedule(ctx context.Context, sess *Session, m *domain.ScheduledMessage) string {
+	var b strings.Builder
+	switch {
+	case m.Kind == domain.ScheduleKindReminder:
+		b.WriteString("提醒你自己")
+	case m.ChannelID != nil:
+		name := *m.ChannelID
+		if ch, err := h.channelSvc.Get(ctx, sess.Agent, *m.ChannelID); err == nil {
+			name = ch.Name
+		}
+		b.WriteString("发到 #" + name)
+	case m.ReceiverID != nil:
+		b.WriteString("私信 " + h.buildDirectory(ctx, sess.Agent.CompanyID).label(*m.ReceiverID))
+	}
+	if m.Cron != "" {
+		fmt.Fprintf(&b, "，cron %s（%s）", m.Cron, m.Timezone)
+	}
+	if m.NextRunAt != nil {
+		loc, err := time.LoadLocation(m.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		b.WriteString("，下次发送 " + m.NextRunAt.In(loc).Format(time.RFC3339))
+	} else if m.LastRunAt != nil {
+		b.WriteString("，上次发送 " + m.LastRunAt.Format(time.RFC3339))
+	}
+	return b.String()
+}
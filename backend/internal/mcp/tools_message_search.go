@@ -0,0 +1,100 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/linkclaw/backend/internal/service"
+)
+
+func (h *Handler) toolSearchMessages(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		Query    string `json:"query"`
+		Channel  string `json:"channel"`
+		Sender   string `json:"sender"`
+		Mention  string `json:"mention"`
+		After    string `json:"after"`
+		Before   string `json:"before"`
+		Semantic bool   `json:"semantic"`
+		Limit    int    `json:"limit"`
+		Offset   int    `json:"offset"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil {
+		return ErrorResult("参数错误：" + err.Error())
+	}
+	if strings.TrimSpace(p.Query) == "" && p.Channel == "" && p.Sender == "" && p.Mention == "" {
+		return ErrorResult("参数错误：至少需要 query、channel、sender、mention 之一")
+	}
+	after, err := parseTaskTime(p.After)
+	if err != nil {
+		return ErrorResult("参数错误：after 需为 RFC3339 或 YYYY-MM-DD 格式")
+	}
+	before, err := parseTaskTime(p.Before)
+	if err != nil {
+		return ErrorResult("参数错误：before 需为 RFC3339 或 YYYY-MM-DD 格式")
+	}
+
+	dir := h.buildDirectory(ctx, sess.Agent.CompanyID)
+	in := service.SearchMessagesInput{
+		Query:    p.Query,
+		Channel:  strings.TrimPrefix(p.Channel, "#"),
+		After:    after,
+		Before:   before,
+		Semantic: p.Semantic,
+		Limit:    p.Limit,
+		Offset:   p.Offset,
+	}
+	if p.Sender != "" {
+		in.SenderID = dir.resolve(strings.TrimPrefix(p.Sender, "@"))
+	}
+	switch m := strings.TrimPrefix(p.Mention, "@"); m {
+	case "":
+	case "me", "我":
+		in.MentionID = sess.Agent.ID
+	default:
+		in.MentionID = dir.resolve(m)
+	}
+
+	res, err := h.searchSvc.Search(ctx, sess.Agent, in)
+	if err != nil {
+		return ErrorResult("搜索消息失败: " + err.Error())
+	}
+	if len(res.Messages) == 0 {
+		return TextResult("没有找到匹配的消息")
+	}
+
+	channelNames := map[string]string{}
+	if channels, err := h.channelSvc.List(ctx, sess.Agent, false, true); err == nil {
+		for _, ch := range channels {
+			channelNames[ch.ID] = "#" + ch.Name
+		}
+	}
+	mode := "全文搜索"
+	if res.Mode == service.MessageSearchSemantic {
+		mode = "语义搜索"
+	} else if p.Semantic {
+		mode = "全文搜索（语义搜索不可用，已降级）"
+	}
+	lines := []string{fmt.Sprintf("%s结果（%d 条）：", mode, len(res.Messages))}
+	for _, m := range res.Messages {
+		where := "私信"
+		if m.ChannelID != nil {
+			where = channelNames[*m.ChannelID]
+		}
+		if m.ThreadRootID != nil {
+			where += " 线程 " + *m.ThreadRootID
+		}
+		lines = append(lines, fmt.Sprintf("[%s] %s %s: %s  #%s",
+			m.CreatedAt.Format("2006-01-02 15:04"), where, senderLabel(dir, m, sess.Agent.ID), m.Content, m.ID))
+	}
+	if res.HasMore {
+		offset := p.Offset
+		if offset < 0 {
+			offset = 0
+		}
+		lines = append(lines, fmt.Sprintf("还有更多结果，可用 offset=%d 继续查看", offset+len(res.Messages)))
+	}
+	return TextResult(strings.Join(lines, "\n"))
+}
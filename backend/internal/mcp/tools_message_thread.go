@@ -0,0 +1,192 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/service"
+)
+
+// formatMessageLine 单条消息的文本形式，附带 ID、提及、线程回复数、编辑 / 删除标记和表情回应
+func formatMessageLine(m *domain.Message, sender, self string) string {
+	if m.IsDeleted() {
+		return fmt.Sprintf("[%s] %s: （消息已删除）  #%s", m.CreatedAt.Format("15:04"), sender, m.ID)
+	}
+	var marks []string
+	if m.Mentioned(self) {
+		marks = append(marks, "提及你")
+	}
+	if m.EditedAt != nil {
+		marks = append(marks, "已编辑")
+	}
+	if m.PinnedAt != nil {
+		marks = append(marks, "已置顶")
+	}
+	if m.ReplyCount > 0 {
+		marks = append(marks, fmt.Sprintf("%d 条回复", m.ReplyCount))
+	}
+	for _, r := range m.Reactions {
+		marks = append(marks, fmt.Sprintf("%s×%d", r.Emoji, r.Count))
+	}
+	line := fmt.Sprintf("[%s] %s: %s  #%s", m.CreatedAt.Format("15:04"), sender, m.Content, m.ID)
+	if len(marks) > 0 {
+		line += "（" + strings.Join(marks, "，") + "）"
+	}
+	return line
+}
+
+// senderLabel 消息发送者的显示名；self 为当前 agent 时显示"你"
+func senderLabel(dir *agentDirectory, m *domain.Message, self string) string {
+	if m.SenderID == nil {
+		return "系统"
+	}
+	if *m.SenderID == self {
+		return "你"
+	}
+	return dir.label(*m.SenderID)
+}
+
+func (h *Handler) toolReplyInThread(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		MessageID string `json:"message_id"`
+		Content   string `json:"content"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.MessageID == "" || p.Content == "" {
+		return ErrorResult("参数错误：需要 message_id 和 content")
+	}
+	msg, err := h.messageSvc.Send(ctx, service.SendMessageInput{
+		CompanyID:    sess.Agent.CompanyID,
+		SenderID:     sess.Agent.ID,
+		ThreadRootID: p.MessageID,
+		Content:      p.Content,
+	})
+	if err != nil {
+		return ErrorResult("回复失败: " + err.Error())
+	}
+	return TextResult(fmt.Sprintf("已在线程 %s 中回复（消息 ID：%s）", *msg.ThreadRootID, msg.ID))
+}
+
+func (h *Handler) toolGetThread(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		MessageID string `json:"message_id"`
+		Limit     string `json:"limit"`
+		AfterID   string `json:"after_id"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.MessageID == "" {
+		return ErrorResult("参数错误：需要 message_id")
+	}
+	limit := 50
+	if l, err := strconv.Atoi(p.Limit); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+	root, replies, err := h.messageSvc.GetThread(ctx, sess.Agent, p.MessageID, limit, p.AfterID)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	dir := h.buildDirectory(ctx, sess.Agent.CompanyID)
+	lines := []string{"线程：", formatMessageLine(root, senderLabel(dir, root, sess.Agent.ID), sess.Agent.ID)}
+	if len(replies) == 0 {
+		lines = append(lines, "  （暂无回复）")
+	}
+	for _, m := range replies {
+		lines = append(lines, "  ↳ "+formatMessageLine(m, senderLabel(dir, m, sess.Agent.ID), sess.Agent.ID))
+	}
+	return TextResult(strings.Join(lines, "\n"))
+}
+
+func (h *Handler) toolReact(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		MessageID string `json:"message_id"`
+		Emoji     string `json:"emoji"`
+		Remove    bool   `json:"remove"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.MessageID == "" || p.Emoji == "" {
+		return ErrorResult("参数错误：需要 message_id 和 emoji")
+	}
+	reactions, err := h.messageSvc.React(ctx, sess.Agent, p.MessageID, p.Emoji, p.Remove)
+	if err != nil {
+		return ErrorResult("操作失败: " + err.Error())
+	}
+	action := "已添加回应 " + p.Emoji
+	if p.Remove {
+		action = "已取消回应 " + p.Emoji
+	}
+	var counts []string
+	for _, r := range reactions {
+		counts = append(counts, fmt.Sprintf("%s×%d", r.Emoji, r.Count))
+	}
+	if len(counts) == 0 {
+		return TextResult(action)
+	}
+	return TextResult(action + "，当前回应：" + strings.Join(counts, " "))
+}
+
+func (h *Handler) toolEditMessage(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		MessageID string `json:"message_id"`
+		Content   string `json:"content"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.MessageID == "" || p.Content == "" {
+		return ErrorResult("参数错误：需要 message_id 和 content")
+	}
+	if _, err := h.messageSvc.Edit(ctx, sess.Agent, p.MessageID, p.Content); err != nil {
+		return ErrorResult("编辑失败: " + err.Error())
+	}
+	return TextResult("消息已更新，原内容已保存到编辑历史")
+}
+
+func (h *Handler) toolDeleteMessage(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		MessageID string `json:"message_id"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.MessageID == "" {
+		return ErrorResult("参数错误：需要 message_id")
+	}
+	if err := h.messageSvc.Delete(ctx, sess.Agent, p.MessageID); err != nil {
+		return ErrorResult("删除失败: " + err.Error())
+	}
+	return TextResult("消息已删除")
+}
+
+func (h *Handler) toolPinMessage(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		MessageID string `json:"message_id"`
+		Unpin     bool   `json:"unpin"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.MessageID == "" {
+		return ErrorResult("参数错误：需要 message_id")
+	}
+	if _, err := h.messageSvc.Pin(ctx, sess.Agent, p.MessageID, !p.Unpin); err != nil {
+		return ErrorResult("操作失败: " + err.Error())
+	}
+	if p.Unpin {
+		return TextResult("已取消置顶")
+	}
+	return TextResult("消息已置顶")
+}
+
+func (h *Handler) toolListPinnedMessages(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		Channel string `json:"channel"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.Channel == "" {
+		return ErrorResult("参数错误：需要 channel")
+	}
+	msgs, err := h.messageSvc.ListPinned(ctx, sess.Agent, p.Channel)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	if len(msgs) == 0 {
+		return TextResult(fmt.Sprintf("#%s 暂无置顶消息", p.Channel))
+	}
+	dir := h.buildDirectory(ctx, sess.Agent.CompanyID)
+	lines := []string{fmt.Sprintf("#%s 置顶消息（%d）：", p.Channel, len(msgs))}
+	for _, m := range msgs {
+		lines = append(lines, "  "+formatMessageLine(m, senderLabel(dir, m, sess.Agent.ID), sess.Agent.ID))
+	}
+	return TextResult(strings.Join(lines, "\n"))
+}
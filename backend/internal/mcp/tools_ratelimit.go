@@ -0,0 +1,63 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+// checkCallLimit 限流与循环检测；拦截时返回给 Agent 的说明
+func (h *Handler) checkCallLimit(ctx context.Context, sess *Session, name string, args json.RawMessage) (ToolCallResult, bool) {
+	if h.limiter == nil {
+		return ToolCallResult{}, true
+	}
+	v := h.limiter.Check(ctx, sess.Agent.ID, name, args)
+	if v.Allowed {
+		return ToolCallResult{}, true
+	}
+	retry := v.RetryAfter.Round(time.Second)
+	if retry < time.Second {
+		retry = time.Second
+	}
+	msg := fmt.Sprintf("⏸ %s，请 %s 后再试。", v.Reason, retry)
+	if v.LoopDetected {
+		msg += "如果你在等待某个结果，请换一种方式确认，而不是反复调用同一工具；你的上级已收到通知。"
+		go h.alertManagerOfLoop(sess.Agent, name, args, v.Repeats)
+	}
+	log.Printf("[mcp] throttled agent %s tool %s: %s", sess.Agent.ID, name, v.Reason)
+	return ErrorResult(msg), false
+}
+
+// alertManagerOfLoop 通知上级：下属 Agent 疑似陷入工具调用循环
+func (h *Handler) alertManagerOfLoop(agent *domain.Agent, tool string, args json.RawMessage, repeats int) {
+	if h.messageSvc == nil || h.orgSvc == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	managerID, err := h.orgSvc.ResolveManagerID(ctx, agent)
+	if err != nil || managerID == nil {
+		log.Printf("[mcp] loop alert: no manager for agent %s: %v", agent.ID, err)
+		return
+	}
+	content := fmt.Sprintf("⚠️ 员工 %s 疑似陷入循环：短时间内以相同参数调用 `%s` %d 次，该工具已被暂时限流。\n参数：%s",
+		agent.Name, tool, repeats, truncate(string(args), 300))
+	msg := &domain.Message{
+		ID:         uuid.New().String(),
+		CompanyID:  agent.CompanyID,
+		ReceiverID: managerID,
+		Content:    content,
+		MsgType:    domain.MsgTypeSystem,
+		CreatedAt:  time.Now(),
+	}
+	if err := h.messageSvc.SendRaw(ctx, msg); err != nil {
+		log.Printf("[mcp] loop alert for agent %s: %v", agent.ID, err)
+	}
+}
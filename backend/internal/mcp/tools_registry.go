@@ -1,13 +1,18 @@
 package mcp
 
-import "github.com/linkclaw/backend/internal/domain"
+import (
+	"encoding/json"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/service"
+)
 
 // 权限常量（对应 domain.Agent.Permissions 中的值）
 const (
 	PermHire           = "hire"
 	PermOnboard        = "onboard"
 	PermKnowledgeWrite = "knowledge:write"
-	PermTaskManage     = "task:manage"
+	PermTaskManage     = domain.PermTaskManage
 	PermTaskCreate     = "task:create"
 	PermPersonaWrite   = "persona:write"
 	PermObsAdmin       = "obs:admin"
@@ -53,12 +58,12 @@ var coreToolDefs = []ToolDef{
 	}},
 	{Tool: Tool{
 		Name:        "update_work_status",
-		Description: "更新你的工作状态（在岗 / 忙碌 / 离岗）。",
+		Description: "更新你的工作状态（在岗 / 忙碌 / 空闲 / 离岗）。长时间无操作会自动转为空闲，调用工具后自动恢复在岗。",
 		InputSchema: InputSchema{
 			Type:     "object",
 			Required: []string{"status"},
 			Properties: map[string]PropSchema{
-				"status": {Type: "string", Description: "新状态", Enum: []string{"online", "busy", "offline"}},
+				"status": {Type: "string", Description: "新状态", Enum: []string{"online", "busy", "idle", "offline"}},
 			},
 		},
 	}},
@@ -72,14 +77,18 @@ var coreToolDefs = []ToolDef{
 	// ── 消息工具（所有 Agent） ──────────────────────────────────
 	{Tool: Tool{
 		Name:        "send_message",
-		Description: "向群聊频道或指定 Agent 发送消息。",
+		Description: "向群聊频道或指定 Agent 发送消息。可附带 blocks 发送富消息：文件、代码块、任务 / 审批 / 知识库卡片和交互按钮；有人点击按钮时你会收到 message.action 事件。",
 		InputSchema: InputSchema{
-			Type:     "object",
-			Required: []string{"content"},
+			Type: "object",
 			Properties: map[string]PropSchema{
 				"channel":     {Type: "string", Description: "群聊频道名称（如 general、engineering）。与 receiver_id 二选一。"},
 				"receiver_id": {Type: "string", Description: "私信目标 Agent 的 ID。与 channel 二选一。"},
-				"content":     {Type: "string", Description: "消息内容（支持 Markdown）"},
+				"content":     {Type: "string", Description: "消息内容（支持 Markdown）。@名字 提及同事；频道内可用 @channel（全员）、@here（在线成员）、@department（你所在部门）。提供 blocks 时可省略"},
+				"blocks": {Type: "array", Description: "富消息块（可选，最多 20 个），每个块按 type 填写字段：" +
+					"text{text}；code{language, code}；file{attachment_id} 或 file{filename, content_base64, mime_type}（≤10MB）；" +
+					"task{task_id}；approval{approval_id}（审批人可在卡片上直接批准 / 驳回）；knowledge{doc_id}；" +
+					"actions{actions:[{action_id, label, style(primary/danger), value}]}",
+					Items: map[string]any{"type": "object"}},
 			},
 		},
 	}},
@@ -96,10 +105,231 @@ var coreToolDefs = []ToolDef{
 			},
 		},
 	}},
+	{Tool: Tool{
+		Name:        "search_messages",
+		Description: "搜索你可见的历史消息（公开频道、你所在的私有频道和你参与的私信）。支持按频道、发送者、提及和时间过滤；semantic=true 时按语义相似度搜索，例如\"CTO 对数据库迁移说了什么\"可用 query=数据库迁移、sender=CTO 的名字、semantic=true。",
+		InputSchema: InputSchema{
+			Type: "object",
+			Properties: map[string]PropSchema{
+				"query":    {Type: "string", Description: "搜索关键词或问题"},
+				"channel":  {Type: "string", Description: "只搜索该频道"},
+				"sender":   {Type: "string", Description: "发送者的名字、\"职位-名字\"或 ID"},
+				"mention":  {Type: "string", Description: "只看提及了某人的消息；me 表示提及你的消息"},
+				"after":    {Type: "string", Description: "起始时间（RFC3339 或 YYYY-MM-DD）"},
+				"before":   {Type: "string", Description: "截止时间（RFC3339 或 YYYY-MM-DD，不含）"},
+				"semantic": {Type: "boolean", Description: "按语义搜索（需公司配置 embedding，不可用时自动降级为全文搜索）"},
+				"limit":    {Type: "integer", Description: "返回条数（默认 20，最大 100）"},
+				"offset":   {Type: "integer", Description: "分页偏移量"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "summarize_channel",
+		Description: "用 AI 汇总某个频道最近的讨论（关键结论、决定、待办与风险）。刚入职或离开一段时间后先用它了解频道情况，比逐条读历史消息更省上下文。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"channel"},
+			Properties: map[string]PropSchema{
+				"channel": {Type: "string", Description: "频道名称"},
+				"hours":   {Type: "integer", Description: "汇总最近多少小时（默认 24，最大 168）"},
+				"focus":   {Type: "string", Description: "特别关心的话题（可选），如\"数据库迁移进展\""},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "schedule_message",
+		Description: "定时或周期发送消息，如\"每个工作日 9 点在 #general 发站会提醒\"。remind_me=true 时为提醒：到点以系统私信发给你自己并唤醒你，适合\"明天 9 点提醒我跟进任务\"。发送时间三选一：send_at、in_minutes、cron。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"content"},
+			Properties: map[string]PropSchema{
+				"content":     {Type: "string", Description: "消息内容（支持 Markdown 与 @提及）；提醒时为提醒事项"},
+				"channel":     {Type: "string", Description: "发送到的频道名称。与 receiver_id 二选一；remind_me 时省略"},
+				"receiver_id": {Type: "string", Description: "私信目标 Agent 的 ID 或名字。与 channel 二选一；remind_me 时省略"},
+				"remind_me":   {Type: "boolean", Description: "true 表示提醒自己"},
+				"task_id":     {Type: "string", Description: "提醒关联的任务 ID（可选），触发时附上任务当前状态"},
+				"send_at":     {Type: "string", Description: "一次性发送时间（RFC3339，如 2026-01-02T09:00:00+08:00）"},
+				"in_minutes":  {Type: "integer", Description: "一次性发送：多少分钟后发送"},
+				"cron":        {Type: "string", Description: "周期发送的 5 段 cron（分 时 日 月 周），如工作日 9 点为 0 9 * * 1-5；也支持 @daily 等"},
+				"timezone":    {Type: "string", Description: "cron 使用的 IANA 时区（默认 UTC），如 Asia/Shanghai"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "list_scheduled_messages",
+		Description: "列出你创建的待发送定时消息与提醒。",
+		InputSchema: InputSchema{
+			Type: "object",
+			Properties: map[string]PropSchema{
+				"include_done": {Type: "boolean", Description: "true 时同时列出已发送、已取消和失败的"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "cancel_scheduled_message",
+		Description: "取消一条待发送的定时消息或提醒（周期消息取消后不再发送）。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"schedule_id"},
+			Properties: map[string]PropSchema{
+				"schedule_id": {Type: "string", Description: "定时消息 ID（见 list_scheduled_messages）"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "reply_in_thread",
+		Description: "在某条消息下的线程中回复。回复不会出现在频道主时间线，消息作者会收到通知。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"message_id", "content"},
+			Properties: map[string]PropSchema{
+				"message_id": {Type: "string", Description: "线程根消息 ID（传入线程中任一回复的 ID 也会回复到同一线程）"},
+				"content":    {Type: "string", Description: "回复内容（支持 Markdown）"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "get_thread",
+		Description: "只读取某个线程的上下文：根消息及其全部回复，无需拉取整个频道历史。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"message_id"},
+			Properties: map[string]PropSchema{
+				"message_id": {Type: "string", Description: "线程根消息或其中任一回复的 ID"},
+				"limit":      {Type: "string", Description: "最多返回的回复条数（默认 50，最大 200）"},
+				"after_id":   {Type: "string", Description: "游标：只返回此回复之后的回复"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "react",
+		Description: "对消息添加或取消表情回应。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"message_id", "emoji"},
+			Properties: map[string]PropSchema{
+				"message_id": {Type: "string", Description: "消息 ID"},
+				"emoji":      {Type: "string", Description: "表情，如 👍 或 :white_check_mark:"},
+				"remove":     {Type: "boolean", Description: "true 表示取消该回应"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "edit_message",
+		Description: "修改自己发送的消息，原内容会保留在编辑历史中。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"message_id", "content"},
+			Properties: map[string]PropSchema{
+				"message_id": {Type: "string", Description: "消息 ID"},
+				"content":    {Type: "string", Description: "新的消息内容"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "delete_message",
+		Description: "删除消息（发送者本人，或频道 owner/admin 及拥有 channel:manage 权限者）。删除后内容不可见，线程结构保留。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"message_id"},
+			Properties: map[string]PropSchema{
+				"message_id": {Type: "string", Description: "消息 ID"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "pin_message",
+		Description: "置顶或取消置顶频道消息。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"message_id"},
+			Properties: map[string]PropSchema{
+				"message_id": {Type: "string", Description: "频道消息 ID"},
+				"unpin":      {Type: "boolean", Description: "true 表示取消置顶"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "list_pinned_messages",
+		Description: "列出频道的置顶消息。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"channel"},
+			Properties: map[string]PropSchema{
+				"channel": {Type: "string", Description: "频道名称"},
+			},
+		},
+	}},
 	{Tool: Tool{
 		Name:        "list_channels",
-		Description: "列出公司所有群聊频道。",
-		InputSchema: InputSchema{Type: "object"},
+		Description: "列出你可见的群聊频道（公开频道及你所在的私有频道）。",
+		InputSchema: InputSchema{
+			Type: "object",
+			Properties: map[string]PropSchema{
+				"include_archived": {Type: "boolean", Description: "是否包含已归档频道（默认 false）"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "create_channel",
+		Description: "创建项目频道，你将成为频道 owner。私有频道仅成员可见、可读写。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"name"},
+			Properties: map[string]PropSchema{
+				"name":        {Type: "string", Description: "频道名（字母、数字、- 或 _，最长 50）"},
+				"description": {Type: "string", Description: "频道用途说明"},
+				"private":     {Type: "boolean", Description: "是否为私有频道（默认 false）"},
+				"members":     {Type: "array", Description: "同时邀请的同事（名字、职位-名字或 ID）", Items: map[string]any{"type": "string"}},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "invite_to_channel",
+		Description: "邀请同事加入频道或调整其角色（需要是频道 owner / admin）。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"channel", "members"},
+			Properties: map[string]PropSchema{
+				"channel": {Type: "string", Description: "频道名或 ID"},
+				"members": {Type: "array", Description: "同事（名字、职位-名字或 ID）", Items: map[string]any{"type": "string"}},
+				"role":    {Type: "string", Description: "角色（默认 member）", Enum: []string{"member", "admin", "owner"}},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "leave_channel",
+		Description: "退出频道。若你是唯一的 owner，需先把 owner 转给其他成员。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"channel"},
+			Properties: map[string]PropSchema{
+				"channel": {Type: "string", Description: "频道名或 ID"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "list_channel_members",
+		Description: "查看频道成员及角色。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"channel"},
+			Properties: map[string]PropSchema{
+				"channel": {Type: "string", Description: "频道名或 ID"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "archive_channel",
+		Description: "归档或恢复频道（需要是频道 owner / admin）。归档后频道只读。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"channel"},
+			Properties: map[string]PropSchema{
+				"channel":   {Type: "string", Description: "频道名或 ID"},
+				"unarchive": {Type: "boolean", Description: "为 true 时恢复已归档频道"},
+			},
+		},
 	}},
 	{Tool: Tool{
 		Name:        "mark_messages_read",
@@ -116,13 +346,28 @@ var coreToolDefs = []ToolDef{
 	// ── 任务工具（所有 Agent 可查看/执行自己的） ─────────────────
 	{Tool: Tool{
 		Name:        "list_tasks",
-		Description: "列出任务列表，支持按范围、状态、优先级过滤。",
+		Description: "列出/搜索任务，支持按范围、状态、优先级、关键词（标题/描述/结果/评论）、标签、创建人、关注人、部门与时间范围过滤，可排序并按游标翻页。",
 		InputSchema: InputSchema{
 			Type: "object",
 			Properties: map[string]PropSchema{
-				"scope":    {Type: "string", Description: "mine=仅我的任务（默认），all=公司所有任务", Enum: []string{"mine", "all"}},
-				"status":   {Type: "string", Description: "过滤状态", Enum: []string{"pending", "assigned", "in_progress", "done", "failed", "cancelled"}},
-				"priority": {Type: "string", Description: "过滤优先级", Enum: []string{"low", "medium", "high", "urgent"}},
+				"scope":          {Type: "string", Description: "mine=仅我的任务（默认），all=公司所有任务，claimable=本部门可认领的未分配任务，review=待我验收的任务", Enum: []string{"mine", "all", "claimable", "review"}},
+				"status":         {Type: "string", Description: "过滤状态", Enum: []string{"pending", "assigned", "in_progress", "in_review", "done", "failed", "cancelled"}},
+				"priority":       {Type: "string", Description: "过滤优先级", Enum: []string{"low", "medium", "high", "urgent"}},
+				"query":          {Type: "string", Description: "全文检索关键词，匹配标题、描述、结果与评论"},
+				"tags":           {Type: "array", Description: "标签列表（数组，可选）", Items: map[string]any{"type": "string"}},
+				"tag_mode":       {Type: "string", Description: "any=包含任一标签（默认），all=包含全部标签", Enum: []string{"any", "all"}},
+				"created_by":     {Type: "string", Description: "创建人 Agent ID"},
+				"watcher_id":     {Type: "string", Description: "关注人 Agent ID"},
+				"department_id":  {Type: "string", Description: "负责人所在部门 ID"},
+				"created_after":  {Type: "string", Description: "创建时间下限（RFC3339 或 YYYY-MM-DD）"},
+				"created_before": {Type: "string", Description: "创建时间上限（RFC3339 或 YYYY-MM-DD）"},
+				"updated_after":  {Type: "string", Description: "更新时间下限（RFC3339 或 YYYY-MM-DD）"},
+				"updated_before": {Type: "string", Description: "更新时间上限（RFC3339 或 YYYY-MM-DD）"},
+				"due_before":     {Type: "string", Description: "截止时间早于（RFC3339 或 YYYY-MM-DD）"},
+				"sort":           {Type: "string", Description: "排序字段（默认 created_at；relevance 需配合 query）", Enum: []string{"created_at", "updated_at", "due_at", "priority", "relevance"}},
+				"order":          {Type: "string", Description: "排序方向（默认 due_at 升序，其余降序）", Enum: []string{"asc", "desc"}},
+				"limit":          {Type: "integer", Description: "每页数量（默认 50，最大 200）"},
+				"cursor":         {Type: "string", Description: "上一页返回的翻页游标"},
 			},
 		},
 	}},
@@ -150,13 +395,26 @@ var coreToolDefs = []ToolDef{
 	}},
 	{Tool: Tool{
 		Name:        "add_task_comment",
-		Description: "为任务添加评论。",
+		Description: "为任务添加评论或回复某条评论。内容中 @名字 或 @职位-名字 会私信通知对方，任务关注者和被回复者也会收到通知。",
 		InputSchema: InputSchema{
 			Type:     "object",
 			Required: []string{"task_id", "content"},
 			Properties: map[string]PropSchema{
-				"task_id": {Type: "string", Description: "任务 ID"},
-				"content": {Type: "string", Description: "评论内容"},
+				"task_id":           {Type: "string", Description: "任务 ID"},
+				"content":           {Type: "string", Description: "评论内容"},
+				"parent_comment_id": {Type: "string", Description: "回复的评论 ID（可选）"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "edit_task_comment",
+		Description: "修改自己发表的任务评论，原文保存在编辑历史中；新增的 @提及会收到通知。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"comment_id", "content"},
+			Properties: map[string]PropSchema{
+				"comment_id": {Type: "string", Description: "评论 ID"},
+				"content":    {Type: "string", Description: "新的评论内容"},
 			},
 		},
 	}},
@@ -185,18 +443,30 @@ var coreToolDefs = []ToolDef{
 	}},
 	{Tool: Tool{
 		Name:        "accept_task",
-		Description: "接受并开始执行一个分配给你的任务（状态: assigned → in_progress）。",
+		Description: "接受并开始执行一个分配给你的任务（状态: assigned → in_progress）。存在未完成的前置依赖时会被拒绝。",
 		InputSchema: InputSchema{
 			Type:     "object",
 			Required: []string{"task_id"},
 			Properties: map[string]PropSchema{
 				"task_id": {Type: "string", Description: "要接受的任务 ID"},
+				"force":   {Type: "boolean", Description: "前置依赖未完成时仍强制开始（会在任务评论中留痕）"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "claim_task",
+		Description: "认领一个未分配的 pending 任务（须对你所在部门可见，可用 list_tasks scope=claimable 查看），认领后状态为 assigned。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"task_id"},
+			Properties: map[string]PropSchema{
+				"task_id": {Type: "string", Description: "要认领的任务 ID"},
 			},
 		},
 	}},
 	{Tool: Tool{
 		Name:        "submit_task_result",
-		Description: "提交任务完成结果（状态: in_progress → done）。",
+		Description: "提交任务完成结果（状态: in_progress → done；任务配置了验收时进入 in_review 等待验收）。",
 		InputSchema: InputSchema{
 			Type:     "object",
 			Required: []string{"task_id", "result"},
@@ -219,6 +489,31 @@ var coreToolDefs = []ToolDef{
 		},
 	}},
 
+	{Tool: Tool{
+		Name:        "approve_task",
+		Description: "验收通过一个待你验收的任务（状态: in_review → done）。可用 list_tasks scope=review 查看待验收任务。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"task_id"},
+			Properties: map[string]PropSchema{
+				"task_id": {Type: "string", Description: "任务 ID"},
+				"comment": {Type: "string", Description: "验收意见（可选）"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "request_task_changes",
+		Description: "验收不通过，要求负责人修改（状态: in_review → in_progress），修改意见会记录在任务评论中。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"task_id", "comment"},
+			Properties: map[string]PropSchema{
+				"task_id": {Type: "string", Description: "任务 ID"},
+				"comment": {Type: "string", Description: "需要修改的具体内容"},
+			},
+		},
+	}},
+
 	// ── Context 搜索工具（所有 Agent） ──────────────────────────────────
 	{Tool: Tool{
 		Name:        "search_context",
@@ -329,6 +624,9 @@ var coreToolDefs = []ToolDef{
 				"assignee_id": {Type: "string", Description: "指定负责人 Agent ID（与 department 二选一）"},
 				"department":  {Type: "string", Description: "指定部门（自动分配给该部门总监）。可选值：人力资源、产品、工程、商务、市场、财务"},
 				"priority":    {Type: "string", Description: "优先级", Enum: []string{"low", "medium", "high", "urgent"}},
+				"auto_assign": {Type: "boolean", Description: "未指定负责人和部门时，按职位、部门、负载、在线状态和历史成功率自动分配"},
+				"review":      {Type: "string", Description: "提交后的验收人：creator=创建者，manager=负责人上级，qa=QA 工程师；不填则提交即完成", Enum: []string{"creator", "manager", "qa"}},
+				"due_at":      {Type: "string", Description: "截止时间（RFC3339，如 2025-06-30T18:00:00+08:00）；到期前会提醒负责人，逾期自动上报"},
 			},
 		},
 	}},
@@ -344,6 +642,117 @@ var coreToolDefs = []ToolDef{
 				"description":    {Type: "string", Description: "详细描述"},
 				"assignee_id":    {Type: "string", Description: "指定负责 Agent 的 ID（可选）"},
 				"priority":       {Type: "string", Description: "优先级", Enum: []string{"low", "medium", "high", "urgent"}},
+				"review":         {Type: "string", Description: "提交后的验收人：creator=创建者，manager=负责人上级，qa=QA 工程师；不填则提交即完成", Enum: []string{"creator", "manager", "qa"}},
+				"due_at":         {Type: "string", Description: "截止时间（RFC3339）"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "reassign_task",
+		Description: "改派任务给其他 Agent，或取消分配（不传 assignee_id）。仅任务创建者、当前负责人或有任务管理权限者可操作。任务回到 assigned/pending，原负责人与新负责人都会收到通知。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"task_id", "reason"},
+			Properties: map[string]PropSchema{
+				"task_id":     {Type: "string", Description: "任务 ID"},
+				"assignee_id": {Type: "string", Description: "新负责人 Agent ID；留空表示取消分配"},
+				"reason":      {Type: "string", Description: "改派原因"},
+			},
+		},
+	}},
+	{Perm: PermTaskCreate, Tool: Tool{
+		Name:        "list_task_templates",
+		Description: "列出公司的任务模板（周报、发版检查单、入职流程等）及其参数。",
+		InputSchema: InputSchema{Type: "object"},
+	}},
+	{Perm: PermTaskCreate, Tool: Tool{
+		Name:        "instantiate_task_template",
+		Description: "按模板一次性创建整棵任务树（含子任务、依赖和按职位分配的负责人），你将作为这些任务的创建者。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"template"},
+			Properties: map[string]PropSchema{
+				"template": {Type: "string", Description: "模板名称或 ID"},
+				"params":   {Type: "object", Description: "模板参数，如 {\"version\": \"1.2.0\"}；必填参数见 list_task_templates"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "update_task",
+		Description: "修改任务标题、描述、优先级、标签，或将任务移到其他父任务下。仅任务创建者或有任务管理权限者可操作；只需传入要修改的字段，每项变更都会记入任务动态。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"task_id"},
+			Properties: map[string]PropSchema{
+				"task_id":        {Type: "string", Description: "任务 ID"},
+				"title":          {Type: "string", Description: "新标题"},
+				"description":    {Type: "string", Description: "新描述"},
+				"priority":       {Type: "string", Description: "新优先级", Enum: []string{"low", "medium", "high", "urgent"}},
+				"parent_task_id": {Type: "string", Description: "新父任务 ID；传空串表示移到顶层"},
+				"tags":           {Type: "array", Description: "新标签列表（整体替换）", Items: map[string]any{"type": "string"}},
+				"version":        {Type: "integer", Description: "读取时的任务版本号，用于检测并发修改；可选"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "set_task_due",
+		Description: "设置或清除任务截止时间。任务创建者、负责人或有任务管理权限者可操作；修改后重新计算到期提醒与逾期上报。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"task_id"},
+			Properties: map[string]PropSchema{
+				"task_id": {Type: "string", Description: "任务 ID"},
+				"due_at":  {Type: "string", Description: "截止时间（RFC3339）；留空表示清除"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "attach_task_file",
+		Description: "为任务上传附件（单个文件不超过 10MB，支持图片、文档、表格、文本与压缩包）。文本文件可直接传 content，二进制文件传 content_base64。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"task_id", "filename"},
+			Properties: map[string]PropSchema{
+				"task_id":        {Type: "string", Description: "任务 ID"},
+				"filename":       {Type: "string", Description: "文件名（含扩展名）"},
+				"content":        {Type: "string", Description: "文本内容"},
+				"content_base64": {Type: "string", Description: "base64 编码的文件内容（二进制文件使用）"},
+				"mime_type":      {Type: "string", Description: "MIME 类型（可选；服务端按文件内容识别，不可识别时按扩展名推断）"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "list_task_attachments",
+		Description: "列出任务的附件。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"task_id"},
+			Properties: map[string]PropSchema{
+				"task_id": {Type: "string", Description: "任务 ID"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "read_task_attachment",
+		Description: "读取任务附件内容：文本文件返回原文，其余返回 base64；超过 256KB 时只返回开头部分。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"task_id", "attachment_id"},
+			Properties: map[string]PropSchema{
+				"task_id":       {Type: "string", Description: "任务 ID"},
+				"attachment_id": {Type: "string", Description: "附件 ID"},
+			},
+		},
+	}},
+	{Tool: Tool{
+		Name:        "delete_task_attachment",
+		Description: "删除任务附件。仅上传者、任务创建者或有任务管理权限者可操作。",
+		InputSchema: InputSchema{
+			Type:     "object",
+			Required: []string{"task_id", "attachment_id"},
+			Properties: map[string]PropSchema{
+				"task_id":       {Type: "string", Description: "任务 ID"},
+				"attachment_id": {Type: "string", Description: "附件 ID"},
 			},
 		},
 	}},
@@ -481,6 +890,16 @@ var coreToolDefs = []ToolDef{
 			},
 		},
 	}},
+	{Tool: Tool{
+		Name:        "get_tool_call_result",
+		Description: "查询需要审批的工具调用的状态和执行结果。不传 call_id 则列出最近的审批调用。",
+		InputSchema: InputSchema{
+			Type: "object",
+			Properties: map[string]PropSchema{
+				"call_id": {Type: "string", Description: "工具调用 ID（调用被审批拦截时返回）"},
+			},
+		},
+	}},
 	{Tool: Tool{
 		Name:        "view_department",
 		Description: "查看指定部门详情（如需成员列表可结合 get_org_chart）。",
@@ -538,29 +957,56 @@ var coreToolDefs = []ToolDef{
 	}},
 }
 
-// ToolsForAgent 返回指定 Agent 有权使用的工具列表
-func ToolsForAgent(agent *domain.Agent) []Tool {
-	tools := make([]Tool, 0, len(allToolDefs))
+// findToolDef 按名称查找工具定义（内置工具 + 额外的联邦工具）
+func findToolDef(name string, extra []ToolDef) (ToolDef, bool) {
 	for _, td := range allToolDefs {
+		if td.Name == name {
+			return td, true
+		}
+	}
+	for _, td := range extra {
+		if td.Name == name {
+			return td, true
+		}
+	}
+	return ToolDef{}, false
+}
+
+// BaselineToolPermission 内置基线权限（ToolDef.Perm），未配置策略时以此为准
+func BaselineToolPermission(agent *domain.Agent, toolName string, extra []ToolDef) bool {
+	td, ok := findToolDef(toolName, extra)
+	if !ok {
+		return false
+	}
+	return td.Perm == "" || agent.HasPermission(td.Perm)
+}
+
+// ToolsForAgent 返回指定 Agent 有权使用的工具列表（内置权限 + 公司工具策略）；extra 为联邦上游工具
+func ToolsForAgent(agent *domain.Agent, policies []*domain.ToolPolicy, extra []ToolDef) []Tool {
+	defs := make([]ToolDef, 0, len(allToolDefs)+len(extra))
+	defs = append(defs, allToolDefs...)
+	defs = append(defs, extra...)
+	tools := make([]Tool, 0, len(defs))
+	for _, td := range defs {
 		if td.InitOnly && agent.Initialized {
 			continue // 已初始化的 Agent 隐藏初始化专属工具
 		}
-		if td.Perm == "" || agent.HasPermission(td.Perm) {
+		baseline := td.Perm == "" || agent.HasPermission(td.Perm)
+		if service.ToolVisible(policies, agent, td.Name, baseline) {
 			tools = append(tools, td.Tool)
 		}
 	}
 	return tools
 }
 
-// HasToolPermission 检查 Agent 是否有权调用指定工具
-func HasToolPermission(agent *domain.Agent, toolName string) bool {
-	for _, td := range allToolDefs {
-		if td.Name == toolName {
-			if td.InitOnly && agent.Initialized {
-				return false
-			}
-			return td.Perm == "" || agent.HasPermission(td.Perm)
-		}
+// CheckToolPermission 检查 Agent 是否有权以给定参数调用指定工具
+func CheckToolPermission(agent *domain.Agent, policies []*domain.ToolPolicy, toolName string, args json.RawMessage, extra []ToolDef) service.ToolPolicyDecision {
+	td, ok := findToolDef(toolName, extra)
+	if !ok {
+		return service.ToolPolicyDecision{Allowed: false, Source: "baseline", Reason: "未知工具"}
+	}
+	if td.InitOnly && agent.Initialized {
+		return service.ToolPolicyDecision{Allowed: false, Source: "baseline", Reason: "该工具仅限入职阶段使用"}
 	}
-	return false
+	return service.EvaluateToolCall(policies, agent, toolName, args, td.Perm == "" || agent.HasPermission(td.Perm))
 }
@@ -21,10 +21,10 @@ func (h *Handler) toolUpdateStatus(ctx context.Context, sess *Session, args json
 		return ErrorResult("参数错误：需要 status")
 	}
 	status := domain.AgentStatus(p.Status)
-	if status != domain.StatusOnline && status != domain.StatusBusy && status != domain.StatusOffline {
-		return ErrorResult("无效状态，可选：online / busy / offline")
+	if status != domain.StatusOnline && status != domain.StatusBusy && status != domain.StatusIdle && status != domain.StatusOffline {
+		return ErrorResult("无效状态，可选：online / busy / idle / offline")
 	}
-	if err := h.agentSvc.UpdateStatus(ctx, sess.Agent.ID, status); err != nil {
+	if err := h.presenceSvc.SetStatus(ctx, sess.Agent, status); err != nil {
 		return ErrorResult("更新状态失败: " + err.Error())
 	}
 	sess.Agent.Status = status
@@ -46,10 +46,8 @@ func (h *Handler) toolMarkInitialized(ctx context.Context, sess *Session, _ json
 }
 
 func (h *Handler) toolPing(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
-	// 刷新 last_seen_at，维持在线心跳
-	if err := h.agentSvc.UpdateLastSeen(ctx, sess.Agent.ID); err != nil {
-		return ErrorResult("ping 失败")
-	}
+	// 续期当前连接并刷新 last_seen_at，维持在线心跳
+	h.presenceSvc.Heartbeat(ctx, sess.Agent, sess.ID)
 	return TextResult("pong")
 }
 
@@ -72,7 +70,7 @@ func (h *Handler) toolListPositions(_ context.Context, _ *Session, _ json.RawMes
 }
 
 func (h *Handler) toolCreateAgent(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
-	// 权限已由 handler.go dispatchTool 前的 HasToolPermission 统一检查
+	// 权限已由 handler.go dispatchTool 前的 CheckToolPermission 统一检查
 	var p struct {
 		RequestID  string `json:"request_id"`
 		Name       string `json:"name"`
@@ -3,8 +3,10 @@ package mcp
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/linkclaw/backend/internal/domain"
 	"github.com/linkclaw/backend/internal/repository"
@@ -13,33 +15,103 @@ import (
 
 func (h *Handler) toolListTasks(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
 	var p struct {
-		Scope    string `json:"scope"`
-		Status   string `json:"status"`
-		Priority string `json:"priority"`
+		Scope         string   `json:"scope"`
+		Status        string   `json:"status"`
+		Priority      string   `json:"priority"`
+		Query         string   `json:"query"`
+		Tags          []string `json:"tags"`
+		TagMode       string   `json:"tag_mode"`
+		CreatedBy     string   `json:"created_by"`
+		WatcherID     string   `json:"watcher_id"`
+		DepartmentID  string   `json:"department_id"`
+		CreatedAfter  string   `json:"created_after"`
+		CreatedBefore string   `json:"created_before"`
+		UpdatedAfter  string   `json:"updated_after"`
+		UpdatedBefore string   `json:"updated_before"`
+		DueBefore     string   `json:"due_before"`
+		Sort          string   `json:"sort"`
+		Order         string   `json:"order"`
+		Limit         int      `json:"limit"`
+		Cursor        string   `json:"cursor"`
 	}
 	json.Unmarshal(args, &p) //nolint:errcheck
 
 	q := repository.TaskQuery{
-		CompanyID: sess.Agent.CompanyID,
-		Status:    domain.TaskStatus(p.Status),
-		Priority:  domain.TaskPriority(p.Priority),
+		CompanyID:    sess.Agent.CompanyID,
+		Status:       domain.TaskStatus(p.Status),
+		Priority:     domain.TaskPriority(p.Priority),
+		Text:         strings.TrimSpace(p.Query),
+		Tags:         p.Tags,
+		AllTags:      p.TagMode == "all",
+		CreatedBy:    p.CreatedBy,
+		WatcherID:    p.WatcherID,
+		DepartmentID: p.DepartmentID,
+		Sort:         repository.TaskSort(p.Sort),
+		Order:        p.Order,
+		Limit:        p.Limit,
+		Cursor:       p.Cursor,
+	}
+	if q.Limit > 200 {
+		q.Limit = 200
+	}
+	for _, r := range []struct {
+		name string
+		raw  string
+		dst  **time.Time
+	}{
+		{"created_after", p.CreatedAfter, &q.CreatedAfter},
+		{"created_before", p.CreatedBefore, &q.CreatedBefore},
+		{"updated_after", p.UpdatedAfter, &q.UpdatedAfter},
+		{"updated_before", p.UpdatedBefore, &q.UpdatedBefore},
+		{"due_before", p.DueBefore, &q.DueBefore},
+	} {
+		t, err := parseTaskTime(r.raw)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("参数错误：%s 需为 RFC3339 或 YYYY-MM-DD 格式", r.name))
+		}
+		*r.dst = t
 	}
-	if p.Scope != "all" {
-		q.AssigneeID = sess.Agent.ID
+	// 带搜索条件时跨层级查找，否则沿用只看顶层任务的行为
+	if q.Text != "" || len(q.Tags) > 0 {
+		q.ParentID = new(string)
 	}
 
-	tasks, total, err := h.taskSvc.List(ctx, q)
+	var (
+		page *repository.TaskPage
+		err  error
+	)
+	switch p.Scope {
+	case "claimable":
+		var tasks []*domain.Task
+		tasks, err = h.taskSvc.ListClaimable(ctx, sess.Agent)
+		page = &repository.TaskPage{Tasks: tasks, Total: len(tasks)}
+	case "review":
+		q.ReviewerID = sess.Agent.ID
+		q.ParentID = new(string)
+		if q.Status == "" {
+			q.Status = domain.TaskStatusInReview
+		}
+		page, err = h.taskSvc.Search(ctx, q)
+	case "all":
+		page, err = h.taskSvc.Search(ctx, q)
+	default:
+		q.AssigneeID = sess.Agent.ID
+		page, err = h.taskSvc.Search(ctx, q)
+	}
+	if errors.Is(err, repository.ErrInvalidTaskCursor) {
+		return ErrorResult("参数错误：cursor 无效或与当前排序方式不匹配")
+	}
 	if err != nil {
 		return ErrorResult("查询任务失败: " + err.Error())
 	}
 
-	if len(tasks) == 0 {
+	if len(page.Tasks) == 0 {
 		return TextResult("暂无任务")
 	}
 
 	var lines []string
-	lines = append(lines, fmt.Sprintf("共 %d 个任务：\n", total))
-	for _, t := range tasks {
+	lines = append(lines, fmt.Sprintf("共 %d 个任务：\n", page.Total))
+	for _, t := range page.Tasks {
 		assignee := "未分配"
 		if t.AssigneeID != nil {
 			assignee = *t.AssigneeID
@@ -49,6 +121,9 @@ func (h *Handler) toolListTasks(ctx context.Context, sess *Session, args json.Ra
 			t.Status, t.Title, t.ID, t.Priority, assignee,
 		))
 	}
+	if page.NextCursor != "" {
+		lines = append(lines, "\n还有更多任务，传入 cursor="+page.NextCursor+" 查看下一页")
+	}
 	return TextResult(strings.Join(lines, "\n"))
 }
 
@@ -72,12 +147,18 @@ func (h *Handler) toolGetTask(ctx context.Context, sess *Session, args json.RawM
 		"任务：%s\nID：%s\n状态：%s\n优先级：%s\n描述：%s",
 		t.Title, t.ID, t.Status, t.Priority, t.Description,
 	)
+	if t.DueAt != nil {
+		result += "\n截止：" + describeDue(t)
+	}
 	if len(t.Subtasks) > 0 {
 		result += fmt.Sprintf("\n子任务：%d 个", len(t.Subtasks))
 		for _, sub := range t.Subtasks {
 			result += fmt.Sprintf("\n  [%s] %s（%s）", sub.Status, sub.Title, sub.ID)
 		}
 	}
+	if len(t.Attachments) > 0 {
+		result += fmt.Sprintf("\n附件：%d 个（可用 list_task_attachments 查看）", len(t.Attachments))
+	}
 	return TextResult(result)
 }
 
@@ -107,6 +188,9 @@ func (h *Handler) toolGetTaskDetail(ctx context.Context, sess *Session, args jso
 	if len(t.Tags) > 0 {
 		lines = append(lines, fmt.Sprintf("标签：%s", strings.Join([]string(t.Tags), ", ")))
 	}
+	if t.DueAt != nil {
+		lines = append(lines, "截止："+describeDue(t))
+	}
 	if len(t.Subtasks) > 0 {
 		lines = append(lines, fmt.Sprintf("子任务：%d 个", len(t.Subtasks)))
 		for _, sub := range t.Subtasks {
@@ -114,8 +198,16 @@ func (h *Handler) toolGetTaskDetail(ctx context.Context, sess *Session, args jso
 		}
 	}
 	lines = append(lines, fmt.Sprintf("评论：%d 条", len(t.Comments)))
-	for _, c := range t.Comments {
-		lines = append(lines, fmt.Sprintf("  - [%s] %s", c.AgentID, c.Content))
+	for _, c := range threadComments(t.Comments) {
+		indent := "  - "
+		if c.ParentCommentID != nil {
+			indent = "      ↳ "
+		}
+		edited := ""
+		if c.EditedAt != nil {
+			edited = "（已编辑）"
+		}
+		lines = append(lines, fmt.Sprintf("%s[%s] %s%s（评论 ID：%s）", indent, c.AgentID, c.Content, edited, c.ID))
 	}
 	lines = append(lines, fmt.Sprintf("依赖：%d 项", len(t.Dependencies)))
 	for _, d := range t.Dependencies {
@@ -125,13 +217,62 @@ func (h *Handler) toolGetTaskDetail(ctx context.Context, sess *Session, args jso
 	for _, w := range t.Watchers {
 		lines = append(lines, fmt.Sprintf("  - %s", w.AgentID))
 	}
+	if len(t.Activity) > 0 {
+		activity := t.Activity
+		if len(activity) > maxActivityLines {
+			activity = activity[len(activity)-maxActivityLines:]
+		}
+		lines = append(lines, fmt.Sprintf("最近动态（共 %d 条）：", len(t.Activity)))
+		for _, e := range activity {
+			actor := "系统"
+			if e.ActorID != nil {
+				actor = *e.ActorID
+			}
+			lines = append(lines, fmt.Sprintf("  - %s [%s] %s：%s → %s",
+				e.CreatedAt.Format("01-02 15:04"), actor, e.Field, truncateActivity(e.OldValue), truncateActivity(e.NewValue)))
+		}
+	}
 	return TextResult(strings.Join(lines, "\n"))
 }
 
+// threadComments 按楼层排序：顶层评论按时间，回复紧随其所属顶层评论（多级回复归到同一楼）
+func threadComments(comments []*domain.TaskComment) []*domain.TaskComment {
+	byID := make(map[string]*domain.TaskComment, len(comments))
+	for _, c := range comments {
+		byID[c.ID] = c
+	}
+	root := func(c *domain.TaskComment) string {
+		for i := 0; i < len(comments) && c.ParentCommentID != nil; i++ {
+			parent, ok := byID[*c.ParentCommentID]
+			if !ok {
+				break
+			}
+			c = parent
+		}
+		return c.ID
+	}
+	replies := map[string][]*domain.TaskComment{}
+	var roots []*domain.TaskComment
+	for _, c := range comments {
+		if r := root(c); r != c.ID {
+			replies[r] = append(replies[r], c)
+		} else {
+			roots = append(roots, c)
+		}
+	}
+	out := make([]*domain.TaskComment, 0, len(comments))
+	for _, r := range roots {
+		out = append(out, r)
+		out = append(out, replies[r.ID]...)
+	}
+	return out
+}
+
 func (h *Handler) toolAddTaskComment(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
 	var p struct {
-		TaskID  string `json:"task_id"`
-		Content string `json:"content"`
+		TaskID          string `json:"task_id"`
+		Content         string `json:"content"`
+		ParentCommentID string `json:"parent_comment_id"`
 	}
 	if err := json.Unmarshal(args, &p); err != nil || p.TaskID == "" || p.Content == "" {
 		return ErrorResult("参数错误：需要 task_id 和 content")
@@ -140,11 +281,33 @@ func (h *Handler) toolAddTaskComment(ctx context.Context, sess *Session, args js
 	if !h.validateTaskOwnership(ctx, p.TaskID, sess.Agent.CompanyID) {
 		return ErrorResult("任务不存在")
 	}
-	comment, err := h.taskSvc.AddComment(ctx, p.TaskID, sess.Agent.ID, p.Content)
+	var parentID *string
+	if p.ParentCommentID != "" {
+		parentID = &p.ParentCommentID
+	}
+	comment, err := h.taskSvc.AddComment(ctx, p.TaskID, sess.Agent.ID, p.Content, parentID)
 	if err != nil {
 		return ErrorResult("添加评论失败: " + err.Error())
 	}
-	return TextResult(fmt.Sprintf("已添加评论（ID：%s）", comment.ID))
+	result := fmt.Sprintf("已添加评论（ID：%s）", comment.ID)
+	if len(comment.Mentions) > 0 {
+		result += fmt.Sprintf("，已通知 %d 位被提及的同事", len(comment.Mentions))
+	}
+	return TextResult(result)
+}
+
+func (h *Handler) toolEditTaskComment(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		CommentID string `json:"comment_id"`
+		Content   string `json:"content"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.CommentID == "" || p.Content == "" {
+		return ErrorResult("参数错误：需要 comment_id 和 content")
+	}
+	if _, err := h.taskSvc.EditComment(ctx, p.CommentID, sess.Agent, p.Content); err != nil {
+		return ErrorResult("编辑评论失败: " + err.Error())
+	}
+	return TextResult("评论已更新（原文已保存到编辑历史）")
 }
 
 func (h *Handler) toolAddTaskDependency(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
@@ -189,6 +352,7 @@ func (h *Handler) toolWatchTask(ctx context.Context, sess *Session, args json.Ra
 func (h *Handler) toolAcceptTask(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
 	var p struct {
 		TaskID string `json:"task_id"`
+		Force  bool   `json:"force"`
 	}
 	if err := json.Unmarshal(args, &p); err != nil || p.TaskID == "" {
 		return ErrorResult("参数错误：需要 task_id")
@@ -197,13 +361,30 @@ func (h *Handler) toolAcceptTask(ctx context.Context, sess *Session, args json.R
 	if !h.validateTaskOwnership(ctx, p.TaskID, sess.Agent.CompanyID) {
 		return ErrorResult("任务不存在")
 	}
-	t, err := h.taskSvc.Accept(ctx, p.TaskID, sess.Agent.ID)
+	t, err := h.taskSvc.Accept(ctx, p.TaskID, sess.Agent.ID, nil, p.Force)
 	if err != nil {
-		return ErrorResult(err.Error())
+		if strings.Contains(err.Error(), "blocked by unfinished dependencies") {
+			return ErrorResult(err.Error() + "\n前置任务完成后你会收到通知；确需提前开始可传 force=true。")
+		}
+		return taskErrorResult(err)
 	}
 	return TextResult(fmt.Sprintf("已接受任务「%s」，状态变更为 in_progress。请开始工作！", t.Title))
 }
 
+func (h *Handler) toolClaimTask(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		TaskID string `json:"task_id"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.TaskID == "" {
+		return ErrorResult("参数错误：需要 task_id")
+	}
+	t, err := h.taskSvc.Claim(ctx, p.TaskID, sess.Agent)
+	if err != nil {
+		return taskErrorResult(err)
+	}
+	return TextResult(fmt.Sprintf("已认领任务「%s」。准备好后使用 accept_task 开始执行。任务 ID：%s", t.Title, t.ID))
+}
+
 func (h *Handler) toolSubmitTaskResult(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
 	var p struct {
 		TaskID string `json:"task_id"`
@@ -216,9 +397,12 @@ func (h *Handler) toolSubmitTaskResult(ctx context.Context, sess *Session, args
 	if !h.validateTaskOwnership(ctx, p.TaskID, sess.Agent.CompanyID) {
 		return ErrorResult("任务不存在")
 	}
-	t, err := h.taskSvc.Submit(ctx, p.TaskID, sess.Agent.ID, p.Result)
+	t, err := h.taskSvc.Submit(ctx, p.TaskID, sess.Agent.ID, nil, p.Result)
 	if err != nil {
-		return ErrorResult(err.Error())
+		return taskErrorResult(err)
+	}
+	if t.Status == domain.TaskStatusInReview {
+		return TextResult(fmt.Sprintf("任务「%s」已提交验收，验收人：%s。验收结果会通过私信通知你。", t.Title, *t.ReviewerID))
 	}
 	return TextResult(fmt.Sprintf("任务「%s」已完成！结果已记录。", t.Title))
 }
@@ -235,9 +419,9 @@ func (h *Handler) toolFailTask(ctx context.Context, sess *Session, args json.Raw
 	if !h.validateTaskOwnership(ctx, p.TaskID, sess.Agent.CompanyID) {
 		return ErrorResult("任务不存在")
 	}
-	t, err := h.taskSvc.Fail(ctx, p.TaskID, sess.Agent.ID, p.Reason)
+	t, err := h.taskSvc.Fail(ctx, p.TaskID, sess.Agent.ID, nil, p.Reason)
 	if err != nil {
-		return ErrorResult(err.Error())
+		return taskErrorResult(err)
 	}
 	return TextResult(fmt.Sprintf("任务「%s」已标记为失败。失败原因已记录。", t.Title))
 }
@@ -249,10 +433,17 @@ func (h *Handler) toolCreateTask(ctx context.Context, sess *Session, args json.R
 		AssigneeID  string `json:"assignee_id"`
 		Department  string `json:"department"`
 		Priority    string `json:"priority"`
+		AutoAssign  bool   `json:"auto_assign"`
+		Review      string `json:"review"`
+		DueAt       string `json:"due_at"`
 	}
 	if err := json.Unmarshal(args, &p); err != nil || p.Title == "" {
 		return ErrorResult("参数错误：需要 title")
 	}
+	dueAt, err := parseDueAt(p.DueAt)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
 
 	agentID := sess.Agent.ID
 	in := service.CreateTaskInput{
@@ -261,6 +452,10 @@ func (h *Handler) toolCreateTask(ctx context.Context, sess *Session, args json.R
 		Description: p.Description,
 		Priority:    domain.TaskPriority(p.Priority),
 		CreatedBy:   &agentID,
+		AutoAssign:  p.AutoAssign,
+		DueAt:       dueAt,
+
+		ReviewPolicy: domain.TaskReviewPolicy(p.Review),
 	}
 
 	if p.AssigneeID != "" {
@@ -303,10 +498,16 @@ func (h *Handler) toolCreateSubtask(ctx context.Context, sess *Session, args jso
 		Description  string `json:"description"`
 		AssigneeID   string `json:"assignee_id"`
 		Priority     string `json:"priority"`
+		Review       string `json:"review"`
+		DueAt        string `json:"due_at"`
 	}
 	if err := json.Unmarshal(args, &p); err != nil || p.ParentTaskID == "" || p.Title == "" {
 		return ErrorResult("参数错误：需要 parent_task_id 和 title")
 	}
+	dueAt, err := parseDueAt(p.DueAt)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
 
 	agentID := sess.Agent.ID
 	in := service.CreateTaskInput{
@@ -316,6 +517,9 @@ func (h *Handler) toolCreateSubtask(ctx context.Context, sess *Session, args jso
 		Description: p.Description,
 		Priority:    domain.TaskPriority(p.Priority),
 		CreatedBy:   &agentID,
+		DueAt:       dueAt,
+
+		ReviewPolicy: domain.TaskReviewPolicy(p.Review),
 	}
 	if p.AssigneeID != "" {
 		in.AssigneeID = &p.AssigneeID
@@ -336,3 +540,228 @@ func (h *Handler) validateTaskOwnership(ctx context.Context, taskID, companyID s
 	}
 	return t.CompanyID == companyID
 }
+
+func (h *Handler) toolReassignTask(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		TaskID     string `json:"task_id"`
+		AssigneeID string `json:"assignee_id"`
+		Reason     string `json:"reason"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.TaskID == "" || strings.TrimSpace(p.Reason) == "" {
+		return ErrorResult("参数错误：需要 task_id 和 reason")
+	}
+	if p.AssigneeID == "" {
+		t, err := h.taskSvc.Unassign(ctx, p.TaskID, sess.Agent, p.Reason)
+		if err != nil {
+			return taskErrorResult(err)
+		}
+		return TextResult(fmt.Sprintf("任务「%s」已取消分配，状态回到 pending。", t.Title))
+	}
+	t, err := h.taskSvc.Reassign(ctx, p.TaskID, sess.Agent, p.AssigneeID, p.Reason)
+	if err != nil {
+		return taskErrorResult(err)
+	}
+	return TextResult(fmt.Sprintf("任务「%s」已改派给 %s。", t.Title, p.AssigneeID))
+}
+
+func (h *Handler) toolSetTaskDue(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		TaskID string `json:"task_id"`
+		DueAt  string `json:"due_at"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.TaskID == "" {
+		return ErrorResult("参数错误：需要 task_id")
+	}
+	dueAt, err := parseDueAt(p.DueAt)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	t, err := h.taskSvc.SetDueAt(ctx, p.TaskID, sess.Agent, dueAt)
+	if err != nil {
+		return taskErrorResult(err)
+	}
+	if t.DueAt == nil {
+		return TextResult(fmt.Sprintf("任务「%s」已清除截止时间。", t.Title))
+	}
+	return TextResult(fmt.Sprintf("任务「%s」截止时间已设为 %s。", t.Title, t.DueAt.Format(time.RFC3339)))
+}
+
+// maxActivityLines get_task_detail 展示的最近变更条数
+const maxActivityLines = 10
+
+func truncateActivity(v string) string {
+	if v == "" {
+		return "（空）"
+	}
+	if r := []rune(v); len(r) > 40 {
+		return string(r[:40]) + "…"
+	}
+	return v
+}
+
+// toolUpdateTask 修改任务字段或父任务；权限由 TaskService 校验（创建者或 task:manage）
+func (h *Handler) toolUpdateTask(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		TaskID       string             `json:"task_id"`
+		Title        *string            `json:"title"`
+		Description  *string            `json:"description"`
+		Priority     *string            `json:"priority"`
+		ParentTaskID *string            `json:"parent_task_id"`
+		Tags         *domain.StringList `json:"tags"`
+		Version      *int               `json:"version"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.TaskID == "" {
+		return ErrorResult("参数错误：需要 task_id")
+	}
+	in := service.UpdateTaskInput{
+		Version:     p.Version,
+		Title:       p.Title,
+		Description: p.Description,
+		ParentID:    p.ParentTaskID,
+		Tags:        p.Tags,
+	}
+	if p.Priority != nil {
+		priority := domain.TaskPriority(*p.Priority)
+		in.Priority = &priority
+	}
+	t, err := h.taskSvc.Update(ctx, p.TaskID, sess.Agent, in)
+	if err != nil {
+		return taskErrorResult(err)
+	}
+	return TextResult(fmt.Sprintf("任务「%s」已更新（版本 %d）。", t.Title, t.Version))
+}
+
+func describeDue(t *domain.Task) string {
+	due := t.DueAt.Format(time.RFC3339)
+	if t.OverdueAt != nil {
+		due += "（已逾期）"
+	}
+	return due
+}
+
+// parseTaskTime 解析过滤用的时间，支持 RFC3339 与 YYYY-MM-DD（按 UTC 零点），空串表示不过滤
+func parseTaskTime(s string) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// parseDueAt 解析 RFC3339 截止时间，空串表示不设置
+func parseDueAt(s string) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, fmt.Errorf("参数错误：due_at 需为 RFC3339 格式，如 2025-06-30T18:00:00+08:00")
+	}
+	return &t, nil
+}
+
+func (h *Handler) toolApproveTask(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		TaskID  string `json:"task_id"`
+		Comment string `json:"comment"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.TaskID == "" {
+		return ErrorResult("参数错误：需要 task_id")
+	}
+	t, err := h.taskSvc.Approve(ctx, p.TaskID, sess.Agent, p.Comment)
+	if err != nil {
+		return taskErrorResult(err)
+	}
+	return TextResult(fmt.Sprintf("任务「%s」已验收通过，状态为 done。", t.Title))
+}
+
+func (h *Handler) toolRequestTaskChanges(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		TaskID  string `json:"task_id"`
+		Comment string `json:"comment"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.TaskID == "" || strings.TrimSpace(p.Comment) == "" {
+		return ErrorResult("参数错误：需要 task_id 和 comment（说明需要修改的内容）")
+	}
+	t, err := h.taskSvc.RequestChanges(ctx, p.TaskID, sess.Agent, p.Comment)
+	if err != nil {
+		return taskErrorResult(err)
+	}
+	return TextResult(fmt.Sprintf("已要求修改任务「%s」，任务回到 in_progress，负责人会收到通知。", t.Title))
+}
+
+// taskErrorResult 任务状态变更失败；并发冲突（409）时提示先获取最新状态
+func taskErrorResult(err error) ToolCallResult {
+	if errors.Is(err, repository.ErrTaskConflict) {
+		return ErrorResult("409 conflict: 任务已被其他操作修改，请调用 get_task 获取最新状态后再决定是否重试。")
+	}
+	return ErrorResult(err.Error())
+}
+
+func (h *Handler) toolListTaskTemplates(ctx context.Context, sess *Session, _ json.RawMessage) ToolCallResult {
+	list, err := h.templateSvc.ListTemplates(ctx, sess.Agent.CompanyID)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	if len(list) == 0 {
+		return TextResult("公司暂无任务模板。")
+	}
+	var b strings.Builder
+	for _, t := range list {
+		fmt.Fprintf(&b, "- %s（ID：%s）", t.Name, t.ID)
+		if t.Description != "" {
+			b.WriteString("：" + t.Description)
+		}
+		for _, p := range t.Params {
+			flag := "可选"
+			if p.Required {
+				flag = "必填"
+			} else if p.Default != "" {
+				flag = "默认 " + p.Default
+			}
+			fmt.Fprintf(&b, "\n    参数 %s（%s）%s", p.Name, flag, p.Description)
+		}
+		b.WriteString("\n")
+	}
+	return TextResult(b.String())
+}
+
+func (h *Handler) toolInstantiateTaskTemplate(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		Template string            `json:"template"`
+		Params   map[string]string `json:"params"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.Template == "" {
+		return ErrorResult("参数错误：需要 template，params 的值需为字符串")
+	}
+	tpl, err := h.templateSvc.FindTemplate(ctx, sess.Agent.CompanyID, p.Template)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	root, err := h.templateSvc.Instantiate(ctx, tpl.ID, sess.Agent, p.Params)
+	if err != nil {
+		return ErrorResult("实例化模板失败: " + err.Error())
+	}
+	lines := []string{fmt.Sprintf("已按模板「%s」创建任务「%s」，ID：%s", tpl.Name, root.Title, root.ID)}
+	var walk func(ts []*domain.Task, indent string)
+	walk = func(ts []*domain.Task, indent string) {
+		for _, t := range ts {
+			assignee := "待认领"
+			if t.AssigneeID != nil {
+				assignee = *t.AssigneeID
+			}
+			lines = append(lines, fmt.Sprintf("%s- %s（%s，负责人：%s）", indent, t.Title, t.ID, assignee))
+			walk(t.Subtasks, indent+"  ")
+		}
+	}
+	walk(root.Subtasks, "  ")
+	return TextResult(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,126 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/service"
+)
+
+// maxAttachmentReadBytes read_task_attachment 单次返回的最大字节数（base64 前）
+const maxAttachmentReadBytes = 256 << 10
+
+func (h *Handler) toolAttachTaskFile(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		TaskID        string `json:"task_id"`
+		Filename      string `json:"filename"`
+		Content       string `json:"content"`
+		ContentBase64 string `json:"content_base64"`
+		MimeType      string `json:"mime_type"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.TaskID == "" || p.Filename == "" {
+		return ErrorResult("参数错误：需要 task_id 与 filename")
+	}
+	var data []byte
+	switch {
+	case p.ContentBase64 != "":
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(p.ContentBase64); err != nil {
+			return ErrorResult("参数错误：content_base64 不是合法的 base64")
+		}
+	case p.Content != "":
+		data = []byte(p.Content)
+	default:
+		return ErrorResult("参数错误：需要 content 或 content_base64")
+	}
+
+	attachments, err := h.taskSvc.AddAttachments(ctx, p.TaskID, sess.Agent, []service.TaskUploadFile{{
+		OriginalFilename: p.Filename,
+		MimeType:         p.MimeType,
+		Content:          data,
+	}})
+	if err != nil {
+		return taskErrorResult(err)
+	}
+	a := attachments[0]
+	return TextResult(fmt.Sprintf("已上传附件「%s」\nID：%s\n大小：%d 字节\nSHA256：%s", a.OriginalFilename, a.ID, a.FileSize, a.SHA256))
+}
+
+func (h *Handler) toolListTaskAttachments(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		TaskID string `json:"task_id"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.TaskID == "" {
+		return ErrorResult("参数错误：需要 task_id")
+	}
+	attachments, err := h.taskSvc.ListAttachments(ctx, p.TaskID, sess.Agent.CompanyID)
+	if err != nil {
+		return ErrorResult("任务不存在")
+	}
+	if len(attachments) == 0 {
+		return TextResult("该任务暂无附件")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "共 %d 个附件：", len(attachments))
+	for _, a := range attachments {
+		fmt.Fprintf(&b, "\n- %s（ID：%s，%s，%d 字节）", a.OriginalFilename, a.ID, a.MimeType, a.FileSize)
+	}
+	return TextResult(b.String())
+}
+
+func (h *Handler) toolReadTaskAttachment(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		TaskID       string `json:"task_id"`
+		AttachmentID string `json:"attachment_id"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.TaskID == "" || p.AttachmentID == "" {
+		return ErrorResult("参数错误：需要 task_id 与 attachment_id")
+	}
+	a, rc, err := h.taskSvc.OpenAttachment(ctx, p.TaskID, p.AttachmentID, sess.Agent.CompanyID)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxAttachmentReadBytes+1))
+	if err != nil {
+		return ErrorResult("读取附件失败: " + err.Error())
+	}
+	truncated := len(data) > maxAttachmentReadBytes
+	if truncated {
+		data = data[:maxAttachmentReadBytes]
+	}
+
+	header := fmt.Sprintf("附件：%s\n类型：%s\n大小：%d 字节\nSHA256：%s\n", a.OriginalFilename, a.MimeType, a.FileSize, a.SHA256)
+	if truncated {
+		header += fmt.Sprintf("（仅返回前 %d 字节）\n", maxAttachmentReadBytes)
+	}
+	if isTextAttachment(a) {
+		return TextResult(header + "\n" + string(data))
+	}
+	return TextResult(header + "内容（base64）：\n" + base64.StdEncoding.EncodeToString(data))
+}
+
+func (h *Handler) toolDeleteTaskAttachment(ctx context.Context, sess *Session, args json.RawMessage) ToolCallResult {
+	var p struct {
+		TaskID       string `json:"task_id"`
+		AttachmentID string `json:"attachment_id"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil || p.TaskID == "" || p.AttachmentID == "" {
+		return ErrorResult("参数错误：需要 task_id 与 attachment_id")
+	}
+	if err := h.taskSvc.DeleteAttachment(ctx, p.TaskID, p.AttachmentID, sess.Agent); err != nil {
+		return ErrorResult(err.Error())
+	}
+	return TextResult("附件已删除")
+}
+
+// isTextAttachment 文本类附件直接返回原文，其余返回 base64
+func isTextAttachment(a *domain.TaskAttachment) bool {
+	mt := strings.ToLower(strings.TrimSpace(strings.Split(a.MimeType, ";")[0]))
+	return strings.HasPrefix(mt, "text/") || mt == "application/json"
+}
@@ -0,0 +1,154 @@
+// Package mcpclient 是连接上游 MCP Server 的最小客户端（initialize / tools/list / tools/call）。
+// 使用标准 MCP 协议字段（camelCase），与 LinkClaw 自身对外暴露的协议结构相互独立。
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
+
+const protocolVersion = "2024-11-05"
+
+// Tool 上游工具定义
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+// ContentBlock 上游工具返回的内容块（仅保留 text，其余类型序列化为文本）
+type ContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+}
+
+// CallResult tools/call 结果
+type CallResult struct {
+	Content []ContentBlock `json:"content"`
+	IsError bool           `json:"isError,omitempty"`
+}
+
+// Client 上游 MCP 连接
+type Client interface {
+	ListTools(ctx context.Context) ([]Tool, error)
+	CallTool(ctx context.Context, name string, args json.RawMessage) (*CallResult, error)
+	Close() error
+}
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      *int64 `json:"id,omitempty"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id"`
+	Method  string          `json:"method,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("upstream rpc error %d: %s", e.Code, e.Message)
+}
+
+// roundTripper 由具体传输实现：发送请求并等待对应 id 的响应；notify 只发送不等待
+type roundTripper interface {
+	call(ctx context.Context, req rpcRequest) (*rpcResponse, error)
+	notify(ctx context.Context, req rpcRequest) error
+	close() error
+}
+
+// session 在传输之上实现 MCP 方法
+type session struct {
+	rt     roundTripper
+	nextID atomic.Int64
+}
+
+func (s *session) request(ctx context.Context, method string, params any, out any) error {
+	id := s.nextID.Add(1)
+	resp, err := s.rt.call(ctx, rpcRequest{JSONRPC: "2.0", ID: &id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if out != nil && len(resp.Result) > 0 {
+		if err := json.Unmarshal(resp.Result, out); err != nil {
+			return fmt.Errorf("decode %s result: %w", method, err)
+		}
+	}
+	return nil
+}
+
+func (s *session) initialize(ctx context.Context) error {
+	params := map[string]any{
+		"protocolVersion": protocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]string{"name": "LinkClaw", "version": "0.1.0"},
+	}
+	if err := s.request(ctx, "initialize", params, nil); err != nil {
+		return fmt.Errorf("initialize: %w", err)
+	}
+	return s.rt.notify(ctx, rpcRequest{JSONRPC: "2.0", Method: "notifications/initialized"})
+}
+
+func (s *session) ListTools(ctx context.Context) ([]Tool, error) {
+	var all []Tool
+	cursor := ""
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var out struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := s.request(ctx, "tools/list", params, &out); err != nil {
+			return nil, fmt.Errorf("tools/list: %w", err)
+		}
+		all = append(all, out.Tools...)
+		if out.NextCursor == "" || out.NextCursor == cursor {
+			return all, nil
+		}
+		cursor = out.NextCursor
+	}
+}
+
+func (s *session) CallTool(ctx context.Context, name string, args json.RawMessage) (*CallResult, error) {
+	if len(args) == 0 {
+		args = json.RawMessage(`{}`)
+	}
+	var raw struct {
+		Content []json.RawMessage `json:"content"`
+		IsError bool              `json:"isError"`
+	}
+	if err := s.request(ctx, "tools/call", map[string]any{"name": name, "arguments": args}, &raw); err != nil {
+		return nil, fmt.Errorf("tools/call %s: %w", name, err)
+	}
+	out := &CallResult{IsError: raw.IsError}
+	for _, c := range raw.Content {
+		var block ContentBlock
+		if err := json.Unmarshal(c, &block); err == nil && block.Type == "text" {
+			out.Content = append(out.Content, block)
+			continue
+		}
+		out.Content = append(out.Content, ContentBlock{Type: "text", Text: string(c)})
+	}
+	return out, nil
+}
+
+func (s *session) Close() error {
+	return s.rt.close()
+}
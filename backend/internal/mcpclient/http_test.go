@@ -0,0 +1,86 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeUpstream 模拟一个 Streamable HTTP MCP Server：tools/call 以 SSE 返回，其余返回 JSON
+func fakeUpstream(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer s3cret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+			return
+		}
+		if req.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		var result any
+		switch req.Method {
+		case "initialize":
+			w.Header().Set("Mcp-Session-Id", "sess-1")
+			result = map[string]any{"protocolVersion": protocolVersion}
+		case "tools/list":
+			if r.Header.Get("Mcp-Session-Id") != "sess-1" {
+				t.Errorf("missing session id")
+			}
+			result = map[string]any{"tools": []map[string]any{
+				{"name": "create_issue", "description": "Create an issue", "inputSchema": map[string]any{"type": "object"}},
+			}}
+		case "tools/call":
+			data, _ := json.Marshal(rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage(
+				`{"content":[{"type":"text","text":"issue #1"},{"type":"image","data":"xx"}],"isError":false}`)})
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			return
+		}
+		raw, _ := json.Marshal(result)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: raw})
+	}))
+}
+
+func TestDialHTTP_ListAndCall(t *testing.T) {
+	srv := fakeUpstream(t)
+	defer srv.Close()
+	ctx := context.Background()
+
+	if _, err := DialHTTP(ctx, srv.URL, nil); err == nil {
+		t.Fatal("expected auth error without credential")
+	}
+
+	c, err := DialHTTP(ctx, srv.URL, map[string]string{"Authorization": "Bearer s3cret"})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+
+	tools, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("list tools: %v", err)
+	}
+	if len(tools) != 1 || tools[0].Name != "create_issue" {
+		t.Fatalf("unexpected tools: %+v", tools)
+	}
+
+	res, err := c.CallTool(ctx, "create_issue", json.RawMessage(`{"title":"x"}`))
+	if err != nil {
+		t.Fatalf("call tool: %v", err)
+	}
+	if len(res.Content) != 2 || res.Content[0].Text != "issue #1" || res.Content[1].Type != "text" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
@@ -0,0 +1,121 @@
+package mcpclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+)
+
+// DialStdio 启动子进程（通常是 docker run -i --rm <image> ...），通过 stdin/stdout 行分隔 JSON-RPC 通信
+func DialStdio(ctx context.Context, name string, args []string, env []string) (Client, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Env = env
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start upstream process: %w", err)
+	}
+
+	rt := &stdioTransport{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[int64]chan *rpcResponse),
+		done:    make(chan struct{}),
+	}
+	go rt.readLoop(stdout)
+
+	s := &session{rt: rt}
+	if err := s.initialize(ctx); err != nil {
+		rt.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+type stdioTransport struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	pending map[int64]chan *rpcResponse
+	done    chan struct{}
+	once    sync.Once
+}
+
+func (t *stdioTransport) readLoop(r io.Reader) {
+	defer t.once.Do(func() { close(t.done) })
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 8*1024*1024)
+	for scanner.Scan() {
+		var msg rpcResponse
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil || msg.ID == nil || msg.Method != "" {
+			continue // 忽略日志行、通知和服务端发起的请求
+		}
+		t.mu.Lock()
+		ch, ok := t.pending[*msg.ID]
+		delete(t.pending, *msg.ID)
+		t.mu.Unlock()
+		if ok {
+			ch <- &msg
+		}
+	}
+}
+
+func (t *stdioTransport) write(req rpcRequest) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err = t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (t *stdioTransport) call(ctx context.Context, req rpcRequest) (*rpcResponse, error) {
+	ch := make(chan *rpcResponse, 1)
+	t.mu.Lock()
+	t.pending[*req.ID] = ch
+	t.mu.Unlock()
+
+	if err := t.write(req); err != nil {
+		t.mu.Lock()
+		delete(t.pending, *req.ID)
+		t.mu.Unlock()
+		return nil, err
+	}
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-t.done:
+		return nil, fmt.Errorf("upstream process exited")
+	case <-ctx.Done():
+		t.mu.Lock()
+		delete(t.pending, *req.ID)
+		t.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) notify(_ context.Context, req rpcRequest) error {
+	return t.write(req)
+}
+
+func (t *stdioTransport) close() error {
+	t.stdin.Close()
+	if t.cmd.Process != nil {
+		_ = t.cmd.Process.Kill()
+	}
+	return t.cmd.Wait()
+}
@@ -0,0 +1,266 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+type agentDeliveryRepo struct {
+	db *gorm.DB
+}
+
+func NewAgentDeliveryRepo(db *gorm.DB) AgentDeliveryRepo {
+	return &agentDeliveryRepo{db: db}
+}
+
+// appendArgs 追加参数并返回它们的占位符列表（如 "$3, $4"）
+func appendArgs(args []any, values ...any) (string, []any) {
+	ph := make([]string, len(values))
+	for i, v := range values {
+		args = append(args, v)
+		ph[i] = fmt.Sprintf("$%d", len(args))
+	}
+	return strings.Join(ph, ", "), args
+}
+
+// Enqueue 在同一语句内递增游标分配 seq；消息已在日志中时不分配，避免 seq 出现无谓的空洞
+func (r *agentDeliveryRepo) Enqueue(ctx context.Context, d *domain.AgentDelivery) (bool, error) {
+	var row struct {
+		Seq           int64     `gorm:"column:seq"`
+		NextAttemptAt time.Time `gorm:"column:next_attempt_at"`
+		CreatedAt     time.Time `gorm:"column:created_at"`
+	}
+	if err := conn(ctx, r.db).Raw(
+		`WITH cur AS (
+			INSERT INTO agent_delivery_cursors (agent_id, last_seq)
+			SELECT $1, 1 WHERE $5::varchar IS NULL
+			   OR NOT EXISTS (SELECT 1 FROM agent_deliveries WHERE agent_id = $1 AND message_id = $5)
+			ON CONFLICT (agent_id) DO UPDATE
+			SET last_seq = agent_delivery_cursors.last_seq + 1, updated_at = NOW()
+			RETURNING last_seq
+		)
+		INSERT INTO agent_deliveries (agent_id, seq, company_id, event_type, payload, message_id)
+		SELECT $1, cur.last_seq, $2, $3, $4, $5 FROM cur
+		ON CONFLICT DO NOTHING
+		RETURNING seq, next_attempt_at, created_at`,
+		d.AgentID, d.CompanyID, d.EventType, string(d.Payload), d.MessageID,
+	).Scan(&row).Error; err != nil {
+		return false, fmt.Errorf("agent delivery enqueue: %w", err)
+	}
+	if row.Seq == 0 {
+		return false, nil
+	}
+	d.Seq, d.NextAttemptAt, d.CreatedAt = row.Seq, row.NextAttemptAt, row.CreatedAt
+	return true, nil
+}
+
+func (r *agentDeliveryRepo) EnqueueMany(ctx context.Context, agentIDs []string, d *domain.AgentDelivery) ([]*domain.AgentDelivery, error) {
+	if len(agentIDs) == 0 {
+		return nil, nil
+	}
+	var rows []struct {
+		AgentID       string    `gorm:"column:agent_id"`
+		Seq           int64     `gorm:"column:seq"`
+		NextAttemptAt time.Time `gorm:"column:next_attempt_at"`
+		CreatedAt     time.Time `gorm:"column:created_at"`
+	}
+	if err := conn(ctx, r.db).Raw(
+		`WITH cur AS (
+			INSERT INTO agent_delivery_cursors (agent_id, last_seq)
+			SELECT a.agent_id, 1 FROM unnest($1::varchar[]) AS a(agent_id)
+			WHERE $5::varchar IS NULL
+			   OR NOT EXISTS (SELECT 1 FROM agent_deliveries WHERE agent_id = a.agent_id AND message_id = $5)
+			ON CONFLICT (agent_id) DO UPDATE
+			SET last_seq = agent_delivery_cursors.last_seq + 1, updated_at = NOW()
+			RETURNING agent_id, last_seq
+		)
+		INSERT INTO agent_deliveries (agent_id, seq, company_id, event_type, payload, message_id)
+		SELECT cur.agent_id, cur.last_seq, $2, $3, $4, $5 FROM cur
+		ON CONFLICT DO NOTHING
+		RETURNING agent_id, seq, next_attempt_at, created_at`,
+		agentIDs, d.CompanyID, d.EventType, string(d.Payload), d.MessageID,
+	).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("agent delivery enqueue many: %w", err)
+	}
+	out := make([]*domain.AgentDelivery, len(rows))
+	for i, row := range rows {
+		cp := *d
+		cp.AgentID, cp.Seq, cp.NextAttemptAt, cp.CreatedAt = row.AgentID, row.Seq, row.NextAttemptAt, row.CreatedAt
+		out[i] = &cp
+	}
+	return out, nil
+}
+
+func (r *agentDeliveryRepo) Ack(ctx context.Context, agentID string, upTo int64, seqs []int64) (int64, error) {
+	args := []any{agentID}
+	var match []string
+	if upTo > 0 {
+		var ph string
+		ph, args = appendArgs(args, upTo)
+		match = append(match, "seq <= "+ph)
+	}
+	if len(seqs) > 0 {
+		values := make([]any, len(seqs))
+		for i, seq := range seqs {
+			values[i] = seq
+		}
+		var in string
+		in, args = appendArgs(args, values...)
+		match = append(match, "seq IN ("+in+")")
+	}
+	if len(match) == 0 {
+		return 0, nil
+	}
+	return r.ack(ctx, "("+strings.Join(match, " OR ")+")", args)
+}
+
+func (r *agentDeliveryRepo) AckMessages(ctx context.Context, agentID string, messageIDs []string) (int64, error) {
+	if len(messageIDs) == 0 {
+		return 0, nil
+	}
+	values := make([]any, len(messageIDs))
+	for i, id := range messageIDs {
+		values[i] = id
+	}
+	in, args := appendArgs([]any{agentID}, values...)
+	return r.ack(ctx, "message_id IN ("+in+")", args)
+}
+
+// ack 确认 $1 agent 下满足 match 的未确认投递，并把游标的 acked_seq 推进到
+// 最小未确认 seq 之前（连续确认水位），重连时从该位置之后续传
+func (r *agentDeliveryRepo) ack(ctx context.Context, match string, args []any) (int64, error) {
+	var n int64
+	if err := conn(ctx, r.db).Raw(
+		`WITH acked AS (
+			UPDATE agent_deliveries SET acked_at = NOW()
+			WHERE agent_id = $1 AND acked_at IS NULL AND `+match+`
+			RETURNING seq
+		), cur AS (
+			UPDATE agent_delivery_cursors c
+			SET acked_seq = GREATEST(c.acked_seq, COALESCE((
+				SELECT MIN(d.seq) - 1 FROM agent_deliveries d
+				WHERE d.agent_id = $1 AND d.acked_at IS NULL
+				  AND d.seq NOT IN (SELECT seq FROM acked)
+			), c.last_seq)), updated_at = NOW()
+			WHERE c.agent_id = $1 AND EXISTS (SELECT 1 FROM acked)
+		)
+		SELECT COUNT(*) FROM acked`,
+		args...,
+	).Scan(&n).Error; err != nil {
+		return 0, fmt.Errorf("agent delivery ack: %w", err)
+	}
+	return n, nil
+}
+
+func (r *agentDeliveryRepo) ListPending(ctx context.Context, agentID string, limit int) ([]*domain.AgentDelivery, error) {
+	var list []*domain.AgentDelivery
+	if err := conn(ctx, r.db).Raw(
+		`SELECT * FROM agent_deliveries WHERE agent_id = $1 AND acked_at IS NULL ORDER BY seq LIMIT $2`,
+		agentID, limit,
+	).Scan(&list).Error; err != nil {
+		return nil, fmt.Errorf("agent delivery list pending: %w", err)
+	}
+	return list, nil
+}
+
+func (r *agentDeliveryRepo) ListDue(ctx context.Context, agentIDs []string, maxAttempts, limit int) ([]*domain.AgentDelivery, error) {
+	if len(agentIDs) == 0 {
+		return nil, nil
+	}
+	values := make([]any, len(agentIDs))
+	for i, id := range agentIDs {
+		values[i] = id
+	}
+	in, args := appendArgs([]any{maxAttempts, limit}, values...)
+	var list []*domain.AgentDelivery
+	if err := conn(ctx, r.db).Raw(
+		`SELECT * FROM agent_deliveries
+		WHERE agent_id IN (`+in+`) AND acked_at IS NULL
+		  AND attempts < $1 AND next_attempt_at <= NOW()
+		ORDER BY agent_id, seq LIMIT $2`,
+		args...,
+	).Scan(&list).Error; err != nil {
+		return nil, fmt.Errorf("agent delivery list due: %w", err)
+	}
+	return list, nil
+}
+
+func (r *agentDeliveryRepo) MarkAttempted(ctx context.Context, agentID string, seq int64, next time.Time) error {
+	if err := conn(ctx, r.db).Exec(
+		`UPDATE agent_deliveries SET attempts = attempts + 1, next_attempt_at = $3
+		WHERE agent_id = $1 AND seq = $2 AND acked_at IS NULL`,
+		agentID, seq, next,
+	).Error; err != nil {
+		return fmt.Errorf("agent delivery mark attempted: %w", err)
+	}
+	return nil
+}
+
+func (r *agentDeliveryRepo) GetCursor(ctx context.Context, agentID string) (*domain.AgentDeliveryCursor, error) {
+	var c domain.AgentDeliveryCursor
+	res := conn(ctx, r.db).Raw(`SELECT * FROM agent_delivery_cursors WHERE agent_id = $1`, agentID).Scan(&c)
+	if res.Error != nil {
+		return nil, fmt.Errorf("agent delivery get cursor: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+func (r *agentDeliveryRepo) MarkResumed(ctx context.Context, agentID string) (bool, error) {
+	var first bool
+	// prev 与 upsert 读取同一快照，得到的是更新前的 resumed_at
+	if err := conn(ctx, r.db).Raw(
+		`WITH prev AS (
+			SELECT resumed_at FROM agent_delivery_cursors WHERE agent_id = $1
+		), up AS (
+			INSERT INTO agent_delivery_cursors (agent_id, resumed_at) VALUES ($1, NOW())
+			ON CONFLICT (agent_id) DO UPDATE SET resumed_at = NOW(), updated_at = NOW()
+		)
+		SELECT NOT EXISTS (SELECT 1 FROM prev WHERE resumed_at IS NOT NULL)`,
+		agentID,
+	).Scan(&first).Error; err != nil {
+		return false, fmt.Errorf("agent delivery mark resumed: %w", err)
+	}
+	return first, nil
+}
+
+func (r *agentDeliveryRepo) Backlog(ctx context.Context, companyID string, maxAttempts int) ([]*domain.AgentDeliveryBacklog, error) {
+	var list []*domain.AgentDeliveryBacklog
+	if err := conn(ctx, r.db).Raw(
+		`SELECT d.agent_id,
+			COUNT(*) FILTER (WHERE d.attempts < $2) AS pending,
+			COUNT(*) FILTER (WHERE d.attempts >= $2) AS exhausted,
+			MIN(d.created_at) AS oldest_pending,
+			COALESCE(MAX(c.last_seq), 0) AS last_seq,
+			COALESCE(MAX(c.acked_seq), 0) AS acked_seq
+		FROM agent_deliveries d
+		LEFT JOIN agent_delivery_cursors c ON c.agent_id = d.agent_id
+		WHERE d.company_id = $1 AND d.acked_at IS NULL
+		GROUP BY d.agent_id
+		ORDER BY COUNT(*) DESC`,
+		companyID, maxAttempts,
+	).Scan(&list).Error; err != nil {
+		return nil, fmt.Errorf("agent delivery backlog: %w", err)
+	}
+	return list, nil
+}
+
+func (r *agentDeliveryRepo) DeleteBefore(ctx context.Context, ackedDays, maxDays int) error {
+	if err := conn(ctx, r.db).Exec(
+		`DELETE FROM agent_deliveries
+		WHERE (acked_at IS NOT NULL AND acked_at < NOW() - make_interval(days => $1))
+		   OR created_at < NOW() - make_interval(days => $2)`,
+		ackedDays, maxDays,
+	).Error; err != nil {
+		return fmt.Errorf("agent delivery cleanup: %w", err)
+	}
+	return nil
+}
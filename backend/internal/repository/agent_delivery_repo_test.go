@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+func TestAgentDeliveryEnqueueManyBindsAllArgs(t *testing.T) {
+	db, pool := newRecordingDB(t)
+	msgID := "m1"
+	_, _ = NewAgentDeliveryRepo(db).EnqueueMany(context.Background(), []string{"a", "b"}, &domain.AgentDelivery{
+		CompanyID: "c", EventType: "message.new", Payload: []byte(`{}`), MessageID: &msgID,
+	})
+	assertBound(t, pool)
+}
+
+func TestAgentDeliveryAckBindsAllArgs(t *testing.T) {
+	db, pool := newRecordingDB(t)
+	repo := NewAgentDeliveryRepo(db)
+	_, _ = repo.Ack(context.Background(), "a", 3, []int64{5, 7})
+	_, _ = repo.AckMessages(context.Background(), "a", []string{"m1", "m2"})
+	assertBound(t, pool)
+}
+
+// errRollback 让测试事务回滚，不在开发库中留下数据
+var errRollback = errors.New("rollback")
+
+func TestAgentDeliveryAckWatermarkOnPostgres(t *testing.T) {
+	db := openDevDB(t)
+	repo := NewAgentDeliveryRepo(db)
+	agentID := uuid.New().String()
+
+	err := NewTransactor(db).WithinTx(context.Background(), func(ctx context.Context) error {
+		for i := 0; i < 3; i++ {
+			if _, err := repo.Enqueue(ctx, &domain.AgentDelivery{
+				AgentID: agentID, CompanyID: "c", EventType: "task.updated", Payload: []byte(`{}`),
+			}); err != nil {
+				return err
+			}
+		}
+		steps := []struct {
+			seqs []int64
+			want int64
+		}{
+			{[]int64{2}, 0}, // seq 1 未确认，水位不能越过它
+			{[]int64{1}, 2},
+			{[]int64{2}, 2}, // 重复确认不影响水位
+			{[]int64{3}, 3},
+		}
+		for _, s := range steps {
+			if _, err := repo.Ack(ctx, agentID, 0, s.seqs); err != nil {
+				return err
+			}
+			cur, err := repo.GetCursor(ctx, agentID)
+			if err != nil {
+				return err
+			}
+			if cur.AckedSeq != s.want {
+				t.Errorf("after ack %v: acked_seq = %d, want %d", s.seqs, cur.AckedSeq, s.want)
+			}
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatal(err)
+	}
+}
@@ -82,15 +82,47 @@ func (r *agentRepo) GetByCompany(ctx context.Context, companyID string) ([]*doma
 	return agents, nil
 }
 
+func (r *agentRepo) ListMentioned(ctx context.Context, companyID, text string) ([]*domain.Agent, error) {
+	var agents []*domain.Agent
+	result := r.db.WithContext(ctx).Raw(
+		`SELECT * FROM agents
+		WHERE company_id = $1 AND name <> '' AND (strpos($2, name) > 0 OR strpos($2, id::text) > 0)
+		ORDER BY role_type, position, name`, companyID, text,
+	).Scan(&agents)
+	if result.Error != nil {
+		return nil, fmt.Errorf("agent list mentioned: %w", result.Error)
+	}
+	return agents, nil
+}
+
 func (r *agentRepo) UpdateStatus(ctx context.Context, id string, status domain.AgentStatus) error {
 	result := r.db.WithContext(ctx).Exec(
 		`UPDATE agents SET status = $1, updated_at = NOW() WHERE id = $2`, status, id)
 	return result.Error
 }
 
+func (r *agentRepo) SetStatusIf(ctx context.Context, id string, from, to domain.AgentStatus) (bool, error) {
+	result := r.db.WithContext(ctx).Exec(
+		`UPDATE agents SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3`, to, id, from)
+	if result.Error != nil {
+		return false, fmt.Errorf("agent set status: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *agentRepo) ListPresent(ctx context.Context) ([]*domain.Agent, error) {
+	var agents []*domain.Agent
+	result := r.db.WithContext(ctx).Raw(`SELECT * FROM agents WHERE status <> 'offline'`).Scan(&agents)
+	if result.Error != nil {
+		return nil, fmt.Errorf("agent list present: %w", result.Error)
+	}
+	return agents, nil
+}
+
+// UpdateLastSeen 只刷新最近活跃时间；在线状态由 PresenceService 维护
 func (r *agentRepo) UpdateLastSeen(ctx context.Context, id string) error {
 	result := r.db.WithContext(ctx).Exec(
-		`UPDATE agents SET last_seen_at = $1, status = 'online', updated_at = NOW() WHERE id = $2`,
+		`UPDATE agents SET last_seen_at = $1 WHERE id = $2`,
 		time.Now(), id)
 	return result.Error
 }
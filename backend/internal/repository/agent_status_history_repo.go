@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+type agentStatusHistoryRepo struct {
+	db *gorm.DB
+}
+
+func NewAgentStatusHistoryRepo(db *gorm.DB) AgentStatusHistoryRepo {
+	return &agentStatusHistoryRepo{db: db}
+}
+
+func (r *agentStatusHistoryRepo) Record(ctx context.Context, p *domain.AgentStatusPeriod) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(
+			`UPDATE agent_status_history SET ended_at = $1 WHERE agent_id = $2 AND ended_at IS NULL`,
+			p.StartedAt, p.AgentID,
+		).Error; err != nil {
+			return fmt.Errorf("agent status history close: %w", err)
+		}
+		if err := tx.Raw(
+			`INSERT INTO agent_status_history (company_id, agent_id, status, prev_status, reason, started_at)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+			p.CompanyID, p.AgentID, p.Status, p.PrevStatus, p.Reason, p.StartedAt,
+		).Scan(&p.ID).Error; err != nil {
+			return fmt.Errorf("agent status history insert: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *agentStatusHistoryRepo) List(ctx context.Context, agentID string, from, to time.Time, limit int) ([]*domain.AgentStatusPeriod, error) {
+	var list []*domain.AgentStatusPeriod
+	if err := r.db.WithContext(ctx).Raw(
+		`SELECT * FROM agent_status_history
+		WHERE agent_id = $1 AND started_at < $3 AND (ended_at IS NULL OR ended_at > $2)
+		ORDER BY started_at DESC LIMIT $4`,
+		agentID, from, to, limit,
+	).Scan(&list).Error; err != nil {
+		return nil, fmt.Errorf("agent status history list: %w", err)
+	}
+	return list, nil
+}
+
+func (r *agentStatusHistoryRepo) Durations(ctx context.Context, companyID string, from, to time.Time) ([]*domain.AgentStatusDuration, error) {
+	var list []*domain.AgentStatusDuration
+	if err := r.db.WithContext(ctx).Raw(
+		`SELECT agent_id, status,
+			SUM(EXTRACT(EPOCH FROM LEAST(COALESCE(ended_at, NOW()), $3) - GREATEST(started_at, $2)))::BIGINT AS seconds
+		FROM agent_status_history
+		WHERE company_id = $1 AND started_at < $3 AND COALESCE(ended_at, NOW()) > $2
+		GROUP BY agent_id, status`,
+		companyID, from, to,
+	).Scan(&list).Error; err != nil {
+		return nil, fmt.Errorf("agent status history durations: %w", err)
+	}
+	return list, nil
+}
+
+func (r *agentStatusHistoryRepo) DeleteByAgent(ctx context.Context, agentID string) error {
+	if err := r.db.WithContext(ctx).Exec(
+		`DELETE FROM agent_status_history WHERE agent_id = $1`, agentID,
+	).Error; err != nil {
+		return fmt.Errorf("agent status history delete: %w", err)
+	}
+	return nil
+}
@@ -23,7 +23,7 @@ func (r *approvalRepo) Create(ctx context.Context, req *domain.ApprovalRequest)
 	q := `INSERT INTO approval_requests
 		(id, company_id, requester_id, approver_id, request_type, status, payload, reason, decision_reason, created_at, decided_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
-	result := r.db.WithContext(ctx).Exec(q,
+	result := conn(ctx, r.db).Exec(q,
 		req.ID, req.CompanyID, req.RequesterID, req.ApproverID, string(req.RequestType),
 		string(req.Status), req.Payload, req.Reason, req.DecisionReason, req.CreatedAt, req.DecidedAt,
 	)
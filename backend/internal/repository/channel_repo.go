@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+type channelRepo struct {
+	db *gorm.DB
+}
+
+func NewChannelRepo(db *gorm.DB) ChannelRepo {
+	return &channelRepo{db: db}
+}
+
+func (r *channelRepo) Create(ctx context.Context, ch *domain.Channel) error {
+	res := conn(ctx, r.db).Raw(
+		`INSERT INTO channels (id, company_id, name, description, is_default, is_private, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at, updated_at`,
+		ch.ID, ch.CompanyID, ch.Name, ch.Description, ch.IsDefault, ch.IsPrivate, ch.CreatedBy,
+	).Row()
+	if err := res.Scan(&ch.CreatedAt, &ch.UpdatedAt); err != nil {
+		return fmt.Errorf("channel create: %w", err)
+	}
+	return nil
+}
+
+func (r *channelRepo) getOne(ctx context.Context, query string, args ...any) (*domain.Channel, error) {
+	var ch domain.Channel
+	res := conn(ctx, r.db).Raw(query, args...).Scan(&ch)
+	if res.Error != nil {
+		return nil, fmt.Errorf("channel get: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &ch, nil
+}
+
+func (r *channelRepo) GetByID(ctx context.Context, id string) (*domain.Channel, error) {
+	return r.getOne(ctx, `SELECT * FROM channels WHERE id = $1`, id)
+}
+
+func (r *channelRepo) GetByName(ctx context.Context, companyID, name string) (*domain.Channel, error) {
+	return r.getOne(ctx, `SELECT * FROM channels WHERE company_id = $1 AND name = $2`, companyID, name)
+}
+
+func (r *channelRepo) List(ctx context.Context, companyID, visibleTo string, includeArchived bool) ([]*domain.Channel, error) {
+	query := `SELECT * FROM channels c WHERE c.company_id = $1`
+	args := []any{companyID}
+	if visibleTo != "" {
+		args = append(args, visibleTo)
+		query += ` AND (NOT c.is_private OR EXISTS (
+			SELECT 1 FROM channel_members cm WHERE cm.channel_id = c.id AND cm.agent_id = $2))`
+	}
+	if !includeArchived {
+		query += ` AND c.archived_at IS NULL`
+	}
+	query += ` ORDER BY c.is_default DESC, c.archived_at NULLS FIRST, c.name`
+
+	var channels []*domain.Channel
+	if err := conn(ctx, r.db).Raw(query, args...).Scan(&channels).Error; err != nil {
+		return nil, fmt.Errorf("channel list: %w", err)
+	}
+	return channels, nil
+}
+
+func (r *channelRepo) Update(ctx context.Context, ch *domain.Channel) error {
+	res := conn(ctx, r.db).Exec(
+		`UPDATE channels SET name = $1, description = $2, is_private = $3, archived_at = $4, updated_at = NOW()
+		WHERE id = $5`,
+		ch.Name, ch.Description, ch.IsPrivate, ch.ArchivedAt, ch.ID,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("channel update: %w", res.Error)
+	}
+	return nil
+}
+
+func (r *channelRepo) UpsertMember(ctx context.Context, m *domain.ChannelMember) error {
+	res := conn(ctx, r.db).Raw(
+		`INSERT INTO channel_members (channel_id, agent_id, company_id, role, added_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (channel_id, agent_id) DO UPDATE SET role = EXCLUDED.role
+		RETURNING joined_at`,
+		m.ChannelID, m.AgentID, m.CompanyID, string(m.Role), m.AddedBy,
+	).Row()
+	if err := res.Scan(&m.JoinedAt); err != nil {
+		return fmt.Errorf("channel member upsert: %w", err)
+	}
+	return nil
+}
+
+func (r *channelRepo) GetMember(ctx context.Context, channelID, agentID string) (*domain.ChannelMember, error) {
+	var m domain.ChannelMember
+	res := conn(ctx, r.db).Raw(
+		`SELECT * FROM channel_members WHERE channel_id = $1 AND agent_id = $2`, channelID, agentID,
+	).Scan(&m)
+	if res.Error != nil {
+		return nil, fmt.Errorf("channel member get: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &m, nil
+}
+
+func (r *channelRepo) ListMembers(ctx context.Context, channelID string) ([]*domain.ChannelMember, error) {
+	var members []*domain.ChannelMember
+	if err := conn(ctx, r.db).Raw(
+		`SELECT * FROM channel_members WHERE channel_id = $1
+		ORDER BY CASE role WHEN 'owner' THEN 0 WHEN 'admin' THEN 1 ELSE 2 END, joined_at`, channelID,
+	).Scan(&members).Error; err != nil {
+		return nil, fmt.Errorf("channel member list: %w", err)
+	}
+	return members, nil
+}
+
+func (r *channelRepo) RemoveMember(ctx context.Context, channelID, agentID string) error {
+	if err := conn(ctx, r.db).Exec(
+		`DELETE FROM channel_members WHERE channel_id = $1 AND agent_id = $2`, channelID, agentID,
+	).Error; err != nil {
+		return fmt.Errorf("channel member remove: %w", err)
+	}
+	return nil
+}
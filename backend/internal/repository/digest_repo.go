@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+type digestRepo struct {
+	db *gorm.DB
+}
+
+func NewDigestRepo(db *gorm.DB) DigestRepo {
+	return &digestRepo{db: db}
+}
+
+func (r *digestRepo) GetSettings(ctx context.Context, agentID string) (*domain.DigestSettings, error) {
+	var s domain.DigestSettings
+	res := r.db.WithContext(ctx).Raw(`SELECT * FROM digest_settings WHERE agent_id = $1`, agentID).Scan(&s)
+	if res.Error != nil {
+		return nil, fmt.Errorf("digest settings get: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+func (r *digestRepo) UpsertSettings(ctx context.Context, s *domain.DigestSettings) error {
+	if s.ChannelIDs == nil {
+		s.ChannelIDs = domain.StringList{}
+	}
+	if err := r.db.WithContext(ctx).Exec(
+		`INSERT INTO digest_settings
+			(agent_id, company_id, enabled, cron, timezone, delivery, channel_ids,
+			 include_dms, include_tasks, include_approvals, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (agent_id) DO UPDATE SET
+			enabled = EXCLUDED.enabled, cron = EXCLUDED.cron, timezone = EXCLUDED.timezone,
+			delivery = EXCLUDED.delivery, channel_ids = EXCLUDED.channel_ids,
+			include_dms = EXCLUDED.include_dms, include_tasks = EXCLUDED.include_tasks,
+			include_approvals = EXCLUDED.include_approvals, next_run_at = EXCLUDED.next_run_at,
+			updated_at = NOW()`,
+		s.AgentID, s.CompanyID, s.Enabled, s.Cron, s.Timezone, s.Delivery, s.ChannelIDs,
+		s.IncludeDMs, s.IncludeTasks, s.IncludeApprovals, s.NextRunAt,
+	).Error; err != nil {
+		return fmt.Errorf("digest settings upsert: %w", err)
+	}
+	return nil
+}
+
+func (r *digestRepo) EnrollHumans(ctx context.Context, cron, timezone string, next time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Exec(
+		`INSERT INTO digest_settings (agent_id, company_id, cron, timezone, next_run_at)
+		SELECT a.id, a.company_id, $1, $2, $3 FROM agents a
+		WHERE a.is_human AND NOT EXISTS (SELECT 1 FROM digest_settings ds WHERE ds.agent_id = a.id)
+		ON CONFLICT (agent_id) DO NOTHING`,
+		cron, timezone, next,
+	)
+	if res.Error != nil {
+		return 0, fmt.Errorf("digest enroll humans: %w", res.Error)
+	}
+	return res.RowsAffected, nil
+}
+
+func (r *digestRepo) ListDue(ctx context.Context, now time.Time, limit int) ([]*domain.DigestSettings, error) {
+	var list []*domain.DigestSettings
+	if err := r.db.WithContext(ctx).Raw(
+		`SELECT * FROM digest_settings WHERE enabled AND next_run_at <= $1 ORDER BY next_run_at LIMIT $2`, now, limit,
+	).Scan(&list).Error; err != nil {
+		return nil, fmt.Errorf("digest list due: %w", err)
+	}
+	return list, nil
+}
+
+func (r *digestRepo) Advance(ctx context.Context, agentID string, prev time.Time, next *time.Time) (bool, error) {
+	res := r.db.WithContext(ctx).Exec(
+		`UPDATE digest_settings SET next_run_at = $1, updated_at = NOW()
+		WHERE agent_id = $2 AND enabled AND next_run_at = $3`,
+		next, agentID, prev,
+	)
+	if res.Error != nil {
+		return false, fmt.Errorf("digest advance: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *digestRepo) SetResult(ctx context.Context, agentID string, lastRunAt *time.Time, lastError string) error {
+	if err := r.db.WithContext(ctx).Exec(
+		`UPDATE digest_settings SET last_run_at = COALESCE($1, last_run_at), last_error = $2 WHERE agent_id = $3`,
+		lastRunAt, lastError, agentID,
+	).Error; err != nil {
+		return fmt.Errorf("digest set result: %w", err)
+	}
+	return nil
+}
+
+func (r *digestRepo) Create(ctx context.Context, d *domain.Digest) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	if err := r.db.WithContext(ctx).Raw(
+		`INSERT INTO digests
+			(id, company_id, agent_id, period_start, period_end, content,
+			 message_count, task_count, approval_count, delivery, message_id, llm_error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING created_at`,
+		d.ID, d.CompanyID, d.AgentID, d.PeriodStart, d.PeriodEnd, d.Content,
+		d.MessageCount, d.TaskCount, d.ApprovalCount, d.Delivery, d.MessageID, d.LLMError,
+	).Scan(&d.CreatedAt).Error; err != nil {
+		return fmt.Errorf("digest create: %w", err)
+	}
+	return nil
+}
+
+func (r *digestRepo) GetByID(ctx context.Context, id string) (*domain.Digest, error) {
+	var d domain.Digest
+	res := r.db.WithContext(ctx).Raw(`SELECT * FROM digests WHERE id = $1`, id).Scan(&d)
+	if res.Error != nil {
+		return nil, fmt.Errorf("digest get: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &d, nil
+}
+
+func (r *digestRepo) List(ctx context.Context, agentID string, limit int) ([]*domain.Digest, error) {
+	var list []*domain.Digest
+	if err := r.db.WithContext(ctx).Raw(
+		`SELECT * FROM digests WHERE agent_id = $1 ORDER BY created_at DESC LIMIT $2`, agentID, limit,
+	).Scan(&list).Error; err != nil {
+		return nil, fmt.Errorf("digest list: %w", err)
+	}
+	return list, nil
+}
+
+func (r *digestRepo) DeleteByAgent(ctx context.Context, agentID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`DELETE FROM digests WHERE agent_id = $1`, agentID).Error; err != nil {
+			return fmt.Errorf("digest delete: %w", err)
+		}
+		if err := tx.Exec(`DELETE FROM digest_settings WHERE agent_id = $1`, agentID).Error; err != nil {
+			return fmt.Errorf("digest settings delete: %w", err)
+		}
+		return nil
+	})
+}
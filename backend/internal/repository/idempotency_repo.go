@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+// ErrIdempotencyLockLost pending 键已被同一请求的重试接管，原持有者不能再写入结果
+var ErrIdempotencyLockLost = errors.New("idempotency key was taken over by another attempt")
+
+type idempotencyRepo struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepo(db *gorm.DB) IdempotencyRepo {
+	return &idempotencyRepo{db: db}
+}
+
+func (r *idempotencyRepo) Reserve(ctx context.Context, rec *domain.IdempotencyRecord, staleBefore time.Time) (bool, error) {
+	// 已过期的旧记录视为不存在，直接覆盖；同一请求长时间未完成的 pending 记录同样可被接管
+	res := r.db.WithContext(ctx).Exec(
+		`INSERT INTO idempotency_keys (agent_id, scope, key, request_hash, status, lock_token, expires_at)
+		VALUES ($1, $2, $3, $4, 'pending', $5, $6)
+		ON CONFLICT (agent_id, scope, key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash, status = 'pending', status_code = 0, response = '',
+			lock_token = EXCLUDED.lock_token, created_at = NOW(), expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < NOW()
+		   OR (idempotency_keys.status = 'pending' AND idempotency_keys.created_at < $7
+		       AND idempotency_keys.request_hash = EXCLUDED.request_hash)`,
+		rec.AgentID, rec.Scope, rec.Key, rec.RequestHash, rec.LockToken, rec.ExpiresAt, staleBefore,
+	)
+	if res.Error != nil {
+		return false, fmt.Errorf("idempotency reserve: %w", res.Error)
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *idempotencyRepo) Get(ctx context.Context, agentID string, scope domain.IdempotencyScope, key string) (*domain.IdempotencyRecord, error) {
+	var rec domain.IdempotencyRecord
+	res := r.db.WithContext(ctx).Raw(
+		`SELECT * FROM idempotency_keys WHERE agent_id = $1 AND scope = $2 AND key = $3`,
+		agentID, scope, key,
+	).Scan(&rec)
+	if res.Error != nil {
+		return nil, fmt.Errorf("idempotency get: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &rec, nil
+}
+
+func (r *idempotencyRepo) Complete(ctx context.Context, agentID string, scope domain.IdempotencyScope, key, lockToken string, statusCode int, response string) error {
+	res := r.db.WithContext(ctx).Exec(
+		`UPDATE idempotency_keys SET status = 'done', status_code = $1, response = $2
+		WHERE agent_id = $3 AND scope = $4 AND key = $5 AND status = 'pending' AND lock_token = $6`,
+		statusCode, response, agentID, scope, key, lockToken,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("idempotency complete: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrIdempotencyLockLost
+	}
+	return nil
+}
+
+func (r *idempotencyRepo) Release(ctx context.Context, agentID string, scope domain.IdempotencyScope, key, lockToken string) error {
+	if err := r.db.WithContext(ctx).Exec(
+		`DELETE FROM idempotency_keys
+		WHERE agent_id = $1 AND scope = $2 AND key = $3 AND status = 'pending' AND lock_token = $4`,
+		agentID, scope, key, lockToken,
+	).Error; err != nil {
+		return fmt.Errorf("idempotency release: %w", err)
+	}
+	return nil
+}
+
+func (r *idempotencyRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	res := r.db.WithContext(ctx).Exec(`DELETE FROM idempotency_keys WHERE expires_at < NOW()`)
+	if res.Error != nil {
+		return 0, fmt.Errorf("idempotency delete expired: %w", res.Error)
+	}
+	return res.RowsAffected, nil
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+func TestIdempotencyReserveBindsAllArgs(t *testing.T) {
+	db, pool := newRecordingDB(t)
+	_, _ = NewIdempotencyRepo(db).Reserve(context.Background(), &domain.IdempotencyRecord{
+		AgentID: "a", Scope: domain.IdempotencyScopeREST, Key: "k", RequestHash: "h", ExpiresAt: time.Now(),
+	}, time.Now())
+	assertBound(t, pool)
+}
+
+func TestIdempotencyCompleteBindsAllArgs(t *testing.T) {
+	db, pool := newRecordingDB(t)
+	_ = NewIdempotencyRepo(db).Complete(context.Background(), "a", domain.IdempotencyScopeREST, "k", "t", 200, "{}")
+	assertBound(t, pool)
+}
+
+func TestIdempotencyReserveTakesOverStalePendingOnPostgres(t *testing.T) {
+	repo := NewIdempotencyRepo(openDevDB(t))
+	ctx := context.Background()
+	first := &domain.IdempotencyRecord{
+		AgentID: uuid.New().String(), Scope: domain.IdempotencyScopeREST, Key: "k", RequestHash: "h1",
+		LockToken: "first", ExpiresAt: time.Now().Add(time.Hour),
+	}
+	defer func() { _ = repo.Release(ctx, first.AgentID, first.Scope, first.Key, first.LockToken) }()
+	if ok, err := repo.Reserve(ctx, first, time.Now().Add(-time.Minute)); err != nil || !ok {
+		t.Fatalf("first reserve = %v, %v", ok, err)
+	}
+
+	retry := *first
+	if ok, err := repo.Reserve(ctx, &retry, time.Now().Add(-time.Minute)); err != nil || ok {
+		t.Fatalf("fresh pending must not be taken over: %v, %v", ok, err)
+	}
+	other := *first
+	other.RequestHash = "h2"
+	if ok, err := repo.Reserve(ctx, &other, time.Now().Add(time.Minute)); err != nil || ok {
+		t.Fatalf("stale pending of another request must not be taken over: %v, %v", ok, err)
+	}
+	retry.LockToken = "retry"
+	if ok, err := repo.Reserve(ctx, &retry, time.Now().Add(time.Minute)); err != nil || !ok {
+		t.Fatalf("stale pending of the same request should be taken over: %v, %v", ok, err)
+	}
+	defer func() { _ = repo.Release(ctx, retry.AgentID, retry.Scope, retry.Key, retry.LockToken) }()
+
+	// 被接管的原请求既不能写入结果，也不能释放新持有者的键
+	if err := repo.Complete(ctx, first.AgentID, first.Scope, first.Key, first.LockToken, 200, "stale"); !errors.Is(err, ErrIdempotencyLockLost) {
+		t.Fatalf("stale owner complete err = %v, want ErrIdempotencyLockLost", err)
+	}
+	if err := repo.Release(ctx, first.AgentID, first.Scope, first.Key, first.LockToken); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Complete(ctx, retry.AgentID, retry.Scope, retry.Key, retry.LockToken, 201, "fresh"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.Get(ctx, first.AgentID, first.Scope, first.Key)
+	if err != nil || got == nil || got.StatusCode != 201 || got.Response != "fresh" {
+		t.Fatalf("stored record = %+v, %v; want the new owner's result", got, err)
+	}
+}
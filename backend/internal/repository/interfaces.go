@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"github.com/linkclaw/backend/internal/domain"
@@ -13,8 +14,14 @@ type AgentRepo interface {
 	GetByAPIKeyHash(ctx context.Context, hash string) (*domain.Agent, error)
 	GetByCompany(ctx context.Context, companyID string) ([]*domain.Agent, error)
 	GetByName(ctx context.Context, companyID, name string) (*domain.Agent, error)
+	// ListMentioned 返回名称或 ID 出现在 text 中的公司成员，用于解析 @ 提及时缩小候选范围
+	ListMentioned(ctx context.Context, companyID, text string) ([]*domain.Agent, error)
 	GetByHireRequestID(ctx context.Context, requestID string) (*domain.Agent, error)
 	UpdateStatus(ctx context.Context, id string, status domain.AgentStatus) error
+	// SetStatusIf 仅当当前状态为 from 时改为 to，返回是否生效
+	SetStatusIf(ctx context.Context, id string, from, to domain.AgentStatus) (bool, error)
+	// ListPresent 跨公司列出状态不是 offline 的 agent
+	ListPresent(ctx context.Context) ([]*domain.Agent, error)
 	UpdateLastSeen(ctx context.Context, id string) error
 	UpdateName(ctx context.Context, id, name string) error
 	UpdateModel(ctx context.Context, id, model string) error
@@ -57,15 +64,59 @@ type CompanyRepo interface {
 	GetChannelByName(ctx context.Context, companyID, name string) (*domain.Channel, error)
 }
 
+type ChannelRepo interface {
+	Create(ctx context.Context, ch *domain.Channel) error
+	GetByID(ctx context.Context, id string) (*domain.Channel, error)
+	GetByName(ctx context.Context, companyID, name string) (*domain.Channel, error)
+	// List 列出公司频道；visibleTo 非空时只返回公开频道与其所在的私有频道
+	List(ctx context.Context, companyID, visibleTo string, includeArchived bool) ([]*domain.Channel, error)
+	Update(ctx context.Context, ch *domain.Channel) error
+
+	// 成员
+	UpsertMember(ctx context.Context, m *domain.ChannelMember) error
+	GetMember(ctx context.Context, channelID, agentID string) (*domain.ChannelMember, error)
+	ListMembers(ctx context.Context, channelID string) ([]*domain.ChannelMember, error)
+	RemoveMember(ctx context.Context, channelID, agentID string) error
+}
+
 type TaskRepo interface {
 	Create(ctx context.Context, t *domain.Task) error
 	CreateAttachments(ctx context.Context, attachments []*domain.TaskAttachment) error
+	GetAttachment(ctx context.Context, id string) (*domain.TaskAttachment, error)
+	DeleteAttachment(ctx context.Context, id string) error
 	GetByID(ctx context.Context, id string) (*domain.Task, error)
 	List(ctx context.Context, q TaskQuery) ([]*domain.Task, int, error)
-	UpdateStatus(ctx context.Context, id string, status domain.TaskStatus, result, failReason *string) error
-	UpdateAssignee(ctx context.Context, id, assigneeID string, status domain.TaskStatus) error
+	// Search 与 List 相同的过滤条件，额外支持排序与游标分页，返回下一页游标
+	Search(ctx context.Context, q TaskQuery) (*TaskPage, error)
+	// TransitionStatus 条件更新状态（status + version 均匹配才生效，version 自增），否则返回 ErrTaskConflict；reviewerID 非 nil 时一并写入审核人
+	TransitionStatus(ctx context.Context, id string, from domain.TaskStatus, version int, to domain.TaskStatus, result, failReason, reviewerID *string) error
+	// UpdateAssignee 以乐观锁更新负责人与状态（assigneeID 为 nil 表示取消分配），版本不符返回 ErrTaskConflict
+	UpdateAssignee(ctx context.Context, id string, version int, assigneeID *string, status domain.TaskStatus) error
+	// Update 按版本号更新可编辑字段（标题、描述、优先级、父任务、标签），版本不符返回 ErrTaskConflict
+	Update(ctx context.Context, t *domain.Task, version int) error
+	// UpdateDueAt 以乐观锁修改截止时间并清除提醒/逾期标记，版本不符返回 ErrTaskConflict
+	UpdateDueAt(ctx context.Context, id string, version int, dueAt *time.Time) error
+	ListDueSoon(ctx context.Context, until time.Time) ([]*domain.Task, error)
+	ListNewlyOverdue(ctx context.Context) ([]*domain.Task, error)
+	ListSLACandidates(ctx context.Context, before time.Time) ([]*domain.Task, error)
+	MarkDueWarned(ctx context.Context, id string) (bool, error)
+	MarkOverdue(ctx context.Context, id string, escalationLevel int) (bool, error)
+	MarkSLABreached(ctx context.Context, id string, escalationLevel int) (bool, error)
+	// ListEscalationDue 上次升级早于 before 且仍逾期或仍处于超时状态的任务
+	ListEscalationDue(ctx context.Context, before time.Time) ([]*domain.Task, error)
+	// MarkEscalated 将升级层级从 fromLevel 推进到 toLevel；已被其他实例推进时返回 false
+	MarkEscalated(ctx context.Context, id string, fromLevel, toLevel int) (bool, error)
+	// ClearEscalation 汇报链已到顶，停止继续升级
+	ClearEscalation(ctx context.Context, id string) error
+	CountOpenByAssignee(ctx context.Context, companyID string) (map[string]int, error)
+	ListFinishedTagged(ctx context.Context, companyID string, limit int) ([]*domain.Task, error)
 	UpdateTags(ctx context.Context, id string, tags domain.StringList) error
-	Delete(ctx context.Context, id, companyID string) error
+	// Delete 删除 ids 中属于 companyID 的任务及其附件记录，返回被删附件的存储路径（文件由调用方在提交后清理）
+	Delete(ctx context.Context, companyID string, ids []string) ([]string, error)
+	// ListDescendants 返回任务的全部后代子任务（递归）
+	ListDescendants(ctx context.Context, rootID string) ([]*domain.Task, error)
+	// LockHierarchy 在当前事务内串行化同一公司的父任务变更，事务结束时自动释放
+	LockHierarchy(ctx context.Context, companyID string) error
 }
 
 type TaskQuery struct {
@@ -73,21 +124,143 @@ type TaskQuery struct {
 	AssigneeID string
 	Status     domain.TaskStatus
 	Priority   domain.TaskPriority
+	ReviewerID string
+	Unassigned bool    // 仅未分配负责人的任务
 	ParentID   *string // nil = 顶层任务，"" = 所有
 	Limit      int
 	Offset     int
+
+	Text          string   // 全文检索：标题、描述、结果与评论
+	Tags          []string // 标签过滤
+	AllTags       bool     // true 时须包含全部 Tags，否则包含任一即可
+	CreatedBy     string
+	WatcherID     string
+	DepartmentID  string // 负责人所在部门
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	DueBefore     *time.Time
+	Sort          TaskSort
+	Order         string // "asc" / "desc"；为空时 due_at 升序，其余降序
+	Cursor        string // 上一页返回的 NextCursor，设置后忽略 Offset
+}
+
+// TaskSort 任务列表排序字段
+type TaskSort string
+
+const (
+	TaskSortCreated   TaskSort = "created_at"
+	TaskSortUpdated   TaskSort = "updated_at"
+	TaskSortDue       TaskSort = "due_at"
+	TaskSortPriority  TaskSort = "priority"
+	TaskSortRelevance TaskSort = "relevance" // 仅在 Text 非空时有效
+)
+
+// TaskPage Search 的一页结果；NextCursor 为空表示没有更多
+type TaskPage struct {
+	Tasks      []*domain.Task
+	Total      int
+	NextCursor string
 }
 
 type TaskCollabRepo interface {
 	AddComment(ctx context.Context, c *domain.TaskComment) error
 	ListComments(ctx context.Context, taskID string) ([]*domain.TaskComment, error)
+	GetComment(ctx context.Context, id string) (*domain.TaskComment, error)
+	// UpdateComment 修改评论内容并记录编辑前的原文
+	UpdateComment(ctx context.Context, id, editorID, content string, mentions domain.StringList) error
+	ListCommentEdits(ctx context.Context, commentID string) ([]*domain.TaskCommentEdit, error)
+	// DeleteComment 删除作者本人的评论，其回复改挂到被删评论的上一级，避免成为孤儿
 	DeleteComment(ctx context.Context, id, agentID, companyID string) error
 	AddDependency(ctx context.Context, d *domain.TaskDependency) error
 	ListDependencies(ctx context.Context, taskID string) ([]*domain.TaskDependency, error)
 	DeleteDependency(ctx context.Context, taskID, dependsOnID string) error
+	// DependencyReachable fromID 是否（直接或间接）依赖 toID
+	DependencyReachable(ctx context.Context, fromID, toID string) (bool, error)
+	// ListOpenBlockers 任务依赖的、尚未完成（非 done/cancelled）的任务
+	ListOpenBlockers(ctx context.Context, taskID string) ([]*domain.Task, error)
+	// ListDependents 依赖该任务的任务
+	ListDependents(ctx context.Context, taskID string) ([]*domain.Task, error)
+	ListDependenciesAmong(ctx context.Context, taskIDs []string) ([]*domain.TaskDependency, error)
 	AddWatcher(ctx context.Context, w *domain.TaskWatcher) error
 	ListWatchers(ctx context.Context, taskID string) ([]*domain.TaskWatcher, error)
 	RemoveWatcher(ctx context.Context, taskID, agentID string) error
+	AddReview(ctx context.Context, r *domain.TaskReview) error
+	ListReviews(ctx context.Context, taskID string) ([]*domain.TaskReview, error)
+	ReviewStats(ctx context.Context, companyID, assigneeID string) (*domain.TaskReviewStats, error)
+	AddEvents(ctx context.Context, events []*domain.TaskEvent) error
+	ListEvents(ctx context.Context, taskID string) ([]*domain.TaskEvent, error)
+}
+
+type TaskTemplateRepo interface {
+	Create(ctx context.Context, t *domain.TaskTemplate) error
+	GetByID(ctx context.Context, id string) (*domain.TaskTemplate, error)
+	GetByName(ctx context.Context, companyID, name string) (*domain.TaskTemplate, error)
+	ListByCompany(ctx context.Context, companyID string) ([]*domain.TaskTemplate, error)
+	Update(ctx context.Context, t *domain.TaskTemplate) error
+	Delete(ctx context.Context, id string) error
+
+	// 周期规则
+	CreateRecurrence(ctx context.Context, r *domain.TaskRecurrence) error
+	GetRecurrence(ctx context.Context, id string) (*domain.TaskRecurrence, error)
+	ListRecurrences(ctx context.Context, companyID, templateID string) ([]*domain.TaskRecurrence, error)
+	UpdateRecurrence(ctx context.Context, r *domain.TaskRecurrence) error
+	DeleteRecurrence(ctx context.Context, id string) error
+	// DeleteRecurrencesByTemplate 删除模板下的全部周期规则（无外键级联，删除模板时由服务层调用）
+	DeleteRecurrencesByTemplate(ctx context.Context, templateID string) error
+	// ListDueRecurrences 跨公司列出已到触发时间的启用规则
+	ListDueRecurrences(ctx context.Context, now time.Time, limit int) ([]*domain.TaskRecurrence, error)
+	// AdvanceRecurrence 条件更新下次触发时间（仅当 next_run_at 仍为 prev 时生效），用于多实例间抢占本次触发；next 为 nil 表示不再触发
+	AdvanceRecurrence(ctx context.Context, id string, prev time.Time, next *time.Time) (bool, error)
+	SetRecurrenceResult(ctx context.Context, id string, taskID *string, lastError string) error
+}
+
+type ScheduledMessageRepo interface {
+	Create(ctx context.Context, m *domain.ScheduledMessage) error
+	GetByID(ctx context.Context, id string) (*domain.ScheduledMessage, error)
+	// List 列出公司内的定时消息；createdBy 非空时只看该创建者，activeOnly 时只看仍待发送的
+	List(ctx context.Context, companyID, createdBy string, activeOnly bool) ([]*domain.ScheduledMessage, error)
+	CountActive(ctx context.Context, createdBy string) (int64, error)
+	// Cancel 取消仍待发送的定时消息，返回是否生效
+	Cancel(ctx context.Context, id string) (bool, error)
+	// ListDue 跨公司列出已到发送时间的定时消息
+	ListDue(ctx context.Context, now time.Time, limit int) ([]*domain.ScheduledMessage, error)
+	// Advance 条件推进下次发送时间（仅当 next_run_at 仍为 prev 时生效），用于多实例间抢占本次发送
+	Advance(ctx context.Context, id string, prev, next time.Time) (bool, error)
+	// SetResult 记录本次发送结果；status 为空时保持不变，sent / failed 时清空下次发送时间
+	SetResult(ctx context.Context, id string, messageID *string, lastError string, status domain.ScheduledMessageStatus) error
+}
+
+type DigestRepo interface {
+	// GetSettings 返回用户的摘要设置；未订阅时返回 nil
+	GetSettings(ctx context.Context, agentID string) (*domain.DigestSettings, error)
+	UpsertSettings(ctx context.Context, s *domain.DigestSettings) error
+	// EnrollHumans 为尚无设置的人类用户按默认值创建订阅，返回新建数量
+	EnrollHumans(ctx context.Context, cron, timezone string, next time.Time) (int64, error)
+	// ListDue 跨公司列出已到发送时间的订阅
+	ListDue(ctx context.Context, now time.Time, limit int) ([]*domain.DigestSettings, error)
+	// Advance 条件推进下次发送时间（仅当 next_run_at 仍为 prev 时生效），用于多实例间抢占
+	Advance(ctx context.Context, agentID string, prev time.Time, next *time.Time) (bool, error)
+	// SetResult 记录本次结果；lastRunAt 为 nil 时保持统计起点不变
+	SetResult(ctx context.Context, agentID string, lastRunAt *time.Time, lastError string) error
+
+	Create(ctx context.Context, d *domain.Digest) error
+	GetByID(ctx context.Context, id string) (*domain.Digest, error)
+	List(ctx context.Context, agentID string, limit int) ([]*domain.Digest, error)
+	// DeleteByAgent 删除用户的订阅设置与历史摘要（agent 被删除时调用）
+	DeleteByAgent(ctx context.Context, agentID string) error
+}
+
+type AgentStatusHistoryRepo interface {
+	// Record 结束 agent 当前的状态区间并开始新区间
+	Record(ctx context.Context, p *domain.AgentStatusPeriod) error
+	// List 列出与 [from, to) 有交集的状态区间，按开始时间倒序
+	List(ctx context.Context, agentID string, from, to time.Time, limit int) ([]*domain.AgentStatusPeriod, error)
+	// Durations 统计公司内各 agent 在 [from, to) 内处于各状态的秒数
+	Durations(ctx context.Context, companyID string, from, to time.Time) ([]*domain.AgentStatusDuration, error)
+	// DeleteByAgent 删除 agent 的全部状态区间（agent 被删除时调用）
+	DeleteByAgent(ctx context.Context, agentID string) error
 }
 
 type PersonaOptimizationRepo interface {
@@ -134,6 +307,48 @@ type MessageRepo interface {
 	ListDM(ctx context.Context, agentA, agentB string, limit int, beforeID string) ([]*domain.Message, error)
 	MarkRead(ctx context.Context, agentID string, messageIDs []string) error
 	ListUnreadForAgent(ctx context.Context, agentID, companyID string) ([]*domain.Message, error)
+
+	GetByID(ctx context.Context, id string) (*domain.Message, error)
+	ListThread(ctx context.Context, rootID string, limit int, afterID string) ([]*domain.Message, error)
+	UpdateContent(ctx context.Context, id, editorID, content string, mentions, groups domain.StringList) error
+	ListEdits(ctx context.Context, messageID string) ([]*domain.MessageEdit, error)
+	SoftDelete(ctx context.Context, id, actorID string) error
+	AddReaction(ctx context.Context, r *domain.MessageReaction) error
+	RemoveReaction(ctx context.Context, messageID, agentID, emoji string) error
+	ListReactions(ctx context.Context, messageIDs []string) ([]*domain.MessageReaction, error)
+	SetPinned(ctx context.Context, id string, pinnedBy *string) error
+	ListPinned(ctx context.Context, channelID string) ([]*domain.Message, error)
+	UpdateBlocks(ctx context.Context, id string, blocks json.RawMessage) error
+
+	CreateAttachment(ctx context.Context, a *domain.MessageAttachment) error
+	GetAttachment(ctx context.Context, id string) (*domain.MessageAttachment, error)
+	LinkAttachments(ctx context.Context, messageID string, ids []string) (int64, error)
+	DeleteUnlinkedAttachments(ctx context.Context, before time.Time) ([]string, error)
+
+	Search(ctx context.Context, q MessageQuery) ([]*domain.Message, error)
+	SemanticSearch(ctx context.Context, q MessageQuery, embedding []float32) ([]*domain.Message, error)
+	ListPendingEmbedding(ctx context.Context, limit int) ([]*domain.Message, error)
+	UpdateEmbedding(ctx context.Context, id string, embedding []float32) error
+	MarkEmbeddingFailed(ctx context.Context, id string) error
+	VectorEmbeddingSupported(ctx context.Context) (bool, error)
+}
+
+// MessageQuery 消息搜索条件
+type MessageQuery struct {
+	CompanyID string
+	ViewerID  string // 只返回该 agent 可见的消息：本人参与的私信、公开频道与所在的私有频道
+	Text      string // 全文检索；语义搜索时忽略
+	ChannelID string
+	SenderID  string
+	MentionID string // 提及了该 agent 的消息（含群组提及）
+	After     *time.Time
+	Before    *time.Time
+	Limit     int
+	Offset    int
+
+	ChannelIDs []string // 限定在这些频道内（私信不受影响）
+	ExcludeDMs bool
+	UnreadOnly bool // 排除 ViewerID 已读或本人发送的消息
 }
 
 type DeploymentRepo interface {
@@ -188,6 +403,89 @@ type WebhookRepo interface {
 	ListPendingDeliveries(ctx context.Context, limit int) ([]*domain.WebhookDelivery, error)
 }
 
+// ToolPolicyRepo MCP 工具权限策略仓库
+type ToolPolicyRepo interface {
+	Create(ctx context.Context, p *domain.ToolPolicy) error
+	GetByID(ctx context.Context, id string) (*domain.ToolPolicy, error)
+	ListByCompany(ctx context.Context, companyID string) ([]*domain.ToolPolicy, error)
+	Update(ctx context.Context, p *domain.ToolPolicy) error
+	Delete(ctx context.Context, id string) error
+
+	// 变更审计
+	CreateChange(ctx context.Context, c *domain.ToolPolicyChange) error
+	ListChanges(ctx context.Context, companyID, policyID string, limit, offset int) ([]*domain.ToolPolicyChange, int, error)
+
+	// 待审批工具调用
+	CreateCall(ctx context.Context, c *domain.ToolCallRequest) error
+	GetCall(ctx context.Context, id string) (*domain.ToolCallRequest, error)
+	GetCallByApprovalID(ctx context.Context, approvalID string) (*domain.ToolCallRequest, error)
+	ListCallsByAgent(ctx context.Context, agentID string, limit int) ([]*domain.ToolCallRequest, error)
+	// TransitionCall 条件更新状态（仅当当前状态为 from 时生效），返回是否更新成功
+	TransitionCall(ctx context.Context, id string, from, to domain.ToolCallStatus) (bool, error)
+	FinishCall(ctx context.Context, id string, status domain.ToolCallStatus, result []byte, isError bool) error
+}
+
+// McpUpstreamRepo 外部 MCP Server 注册与凭证
+type McpUpstreamRepo interface {
+	Create(ctx context.Context, u *domain.McpUpstream) error
+	GetByID(ctx context.Context, id string) (*domain.McpUpstream, error)
+	GetByName(ctx context.Context, companyID, name string) (*domain.McpUpstream, error)
+	ListByCompany(ctx context.Context, companyID string) ([]*domain.McpUpstream, error)
+	Update(ctx context.Context, u *domain.McpUpstream) error
+	Delete(ctx context.Context, id string) error
+
+	UpsertCredential(ctx context.Context, c *domain.McpUpstreamCredential) error
+	// GetCredential 查找凭证：agentID 为空时取公司级凭证
+	GetCredential(ctx context.Context, upstreamID string, agentID *string) (*domain.McpUpstreamCredential, error)
+	ListCredentials(ctx context.Context, upstreamID string) ([]*domain.McpUpstreamCredential, error)
+	DeleteCredential(ctx context.Context, id string) error
+	DeleteCredentialsByUpstream(ctx context.Context, upstreamID string) error
+}
+
+// OutboxRepo 事件 outbox：与业务写入同事务入队，提交后发布
+type OutboxRepo interface {
+	Enqueue(ctx context.Context, e *domain.OutboxEvent) error
+	ListPending(ctx context.Context, limit int) ([]*domain.OutboxEvent, error)
+	MarkPublished(ctx context.Context, id string) error
+	DeletePublishedBefore(ctx context.Context, days int) error
+}
+
+// AgentDeliveryRepo Agent 投递日志
+type AgentDeliveryRepo interface {
+	// Enqueue 分配 seq 并写入；同一条新消息已在日志中时返回 false
+	Enqueue(ctx context.Context, d *domain.AgentDelivery) (bool, error)
+	// EnqueueMany 为多个 agent 写入同一事件，返回实际写入的投递（已在日志中的消息跳过）
+	EnqueueMany(ctx context.Context, agentIDs []string, d *domain.AgentDelivery) ([]*domain.AgentDelivery, error)
+	// Ack 确认 seq <= upTo 的全部投递以及 seqs 中的投递，返回新确认的条数
+	Ack(ctx context.Context, agentID string, upTo int64, seqs []int64) (int64, error)
+	// AckMessages 按消息 ID 确认 message.new 投递（标记已读时调用）
+	AckMessages(ctx context.Context, agentID string, messageIDs []string) (int64, error)
+	// ListPending 未确认的投递，按 seq 升序
+	ListPending(ctx context.Context, agentID string, limit int) ([]*domain.AgentDelivery, error)
+	// ListDue 指定 agent 中已到重投时间且未超过最大重投次数的投递
+	ListDue(ctx context.Context, agentIDs []string, maxAttempts, limit int) ([]*domain.AgentDelivery, error)
+	MarkAttempted(ctx context.Context, agentID string, seq int64, next time.Time) error
+	GetCursor(ctx context.Context, agentID string) (*domain.AgentDeliveryCursor, error)
+	// MarkResumed 记录一次续传，返回是否为该 agent 的首次续传
+	MarkResumed(ctx context.Context, agentID string) (bool, error)
+	Backlog(ctx context.Context, companyID string, maxAttempts int) ([]*domain.AgentDeliveryBacklog, error)
+	// DeleteBefore 清理 ackedDays 天前已确认、以及 maxDays 天前创建的投递
+	DeleteBefore(ctx context.Context, ackedDays, maxDays int) error
+}
+
+// IdempotencyRepo 幂等键仓库
+type IdempotencyRepo interface {
+	// Reserve 占用幂等键；键已存在（且未过期）时返回 false。
+	// 同一请求的 pending 记录创建于 staleBefore 之前时视为首次请求已中断，由本次接管
+	Reserve(ctx context.Context, r *domain.IdempotencyRecord, staleBefore time.Time) (bool, error)
+	Get(ctx context.Context, agentID string, scope domain.IdempotencyScope, key string) (*domain.IdempotencyRecord, error)
+	// Complete 保存结果；键已被其他请求接管（lockToken 不符或已非 pending）时返回 ErrIdempotencyLockLost
+	Complete(ctx context.Context, agentID string, scope domain.IdempotencyScope, key, lockToken string, statusCode int, response string) error
+	// Release 删除仍由 lockToken 持有的 pending 键
+	Release(ctx context.Context, agentID string, scope domain.IdempotencyScope, key, lockToken string) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
 type PromptLayerRepo interface {
 	Upsert(ctx context.Context, layer *domain.PromptLayer) error
 	Delete(ctx context.Context, companyID, layerType, key string) error
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+type mcpUpstreamRepo struct {
+	db *gorm.DB
+}
+
+func NewMcpUpstreamRepo(db *gorm.DB) McpUpstreamRepo {
+	return &mcpUpstreamRepo{db: db}
+}
+
+func (r *mcpUpstreamRepo) Create(ctx context.Context, u *domain.McpUpstream) error {
+	if u.ID == "" {
+		u.ID = uuid.New().String()
+	}
+	res := r.db.WithContext(ctx).Exec(
+		`INSERT INTO mcp_upstreams
+		(id, company_id, name, description, transport, url, image, command, args,
+		 auth_header, auth_scheme, auth_env, required_perm, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+		u.ID, u.CompanyID, u.Name, u.Description, u.Transport, u.URL, u.Image, u.Command, u.Args,
+		u.AuthHeader, u.AuthScheme, u.AuthEnv, u.RequiredPerm, u.IsActive,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("mcp upstream create: %w", res.Error)
+	}
+	return nil
+}
+
+func (r *mcpUpstreamRepo) GetByID(ctx context.Context, id string) (*domain.McpUpstream, error) {
+	var u domain.McpUpstream
+	res := r.db.WithContext(ctx).Raw(`SELECT * FROM mcp_upstreams WHERE id = $1`, id).Scan(&u)
+	if res.Error != nil {
+		return nil, fmt.Errorf("mcp upstream get: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &u, nil
+}
+
+func (r *mcpUpstreamRepo) GetByName(ctx context.Context, companyID, name string) (*domain.McpUpstream, error) {
+	var u domain.McpUpstream
+	res := r.db.WithContext(ctx).Raw(
+		`SELECT * FROM mcp_upstreams WHERE company_id = $1 AND name = $2`, companyID, name,
+	).Scan(&u)
+	if res.Error != nil {
+		return nil, fmt.Errorf("mcp upstream get by name: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &u, nil
+}
+
+func (r *mcpUpstreamRepo) ListByCompany(ctx context.Context, companyID string) ([]*domain.McpUpstream, error) {
+	var list []*domain.McpUpstream
+	if err := r.db.WithContext(ctx).Raw(
+		`SELECT * FROM mcp_upstreams WHERE company_id = $1 ORDER BY name`, companyID,
+	).Scan(&list).Error; err != nil {
+		return nil, fmt.Errorf("mcp upstream list: %w", err)
+	}
+	return list, nil
+}
+
+func (r *mcpUpstreamRepo) Update(ctx context.Context, u *domain.McpUpstream) error {
+	res := r.db.WithContext(ctx).Exec(
+		`UPDATE mcp_upstreams SET
+		description = $1, transport = $2, url = $3, image = $4, command = $5, args = $6,
+		auth_header = $7, auth_scheme = $8, auth_env = $9, required_perm = $10, is_active = $11,
+		updated_at = NOW()
+		WHERE id = $12`,
+		u.Description, u.Transport, u.URL, u.Image, u.Command, u.Args,
+		u.AuthHeader, u.AuthScheme, u.AuthEnv, u.RequiredPerm, u.IsActive, u.ID,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("mcp upstream update: %w", res.Error)
+	}
+	return nil
+}
+
+func (r *mcpUpstreamRepo) Delete(ctx context.Context, id string) error {
+	if err := r.db.WithContext(ctx).Exec(`DELETE FROM mcp_upstreams WHERE id = $1`, id).Error; err != nil {
+		return fmt.Errorf("mcp upstream delete: %w", err)
+	}
+	return nil
+}
+
+func (r *mcpUpstreamRepo) UpsertCredential(ctx context.Context, c *domain.McpUpstreamCredential) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	res := r.db.WithContext(ctx).Exec(
+		`INSERT INTO mcp_upstream_credentials (id, company_id, upstream_id, agent_id, secret_enc)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (upstream_id, COALESCE(agent_id, ''))
+		DO UPDATE SET secret_enc = EXCLUDED.secret_enc, updated_at = NOW()`,
+		c.ID, c.CompanyID, c.UpstreamID, c.AgentID, c.SecretEnc,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("mcp upstream credential upsert: %w", res.Error)
+	}
+	return nil
+}
+
+func (r *mcpUpstreamRepo) GetCredential(ctx context.Context, upstreamID string, agentID *string) (*domain.McpUpstreamCredential, error) {
+	var c domain.McpUpstreamCredential
+	var res *gorm.DB
+	if agentID == nil {
+		res = r.db.WithContext(ctx).Raw(
+			`SELECT * FROM mcp_upstream_credentials WHERE upstream_id = $1 AND agent_id IS NULL`, upstreamID,
+		).Scan(&c)
+	} else {
+		res = r.db.WithContext(ctx).Raw(
+			`SELECT * FROM mcp_upstream_credentials WHERE upstream_id = $1 AND agent_id = $2`, upstreamID, *agentID,
+		).Scan(&c)
+	}
+	if res.Error != nil {
+		return nil, fmt.Errorf("mcp upstream credential get: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+func (r *mcpUpstreamRepo) ListCredentials(ctx context.Context, upstreamID string) ([]*domain.McpUpstreamCredential, error) {
+	var list []*domain.McpUpstreamCredential
+	if err := r.db.WithContext(ctx).Raw(
+		`SELECT * FROM mcp_upstream_credentials WHERE upstream_id = $1 ORDER BY created_at`, upstreamID,
+	).Scan(&list).Error; err != nil {
+		return nil, fmt.Errorf("mcp upstream credential list: %w", err)
+	}
+	return list, nil
+}
+
+func (r *mcpUpstreamRepo) DeleteCredential(ctx context.Context, id string) error {
+	if err := r.db.WithContext(ctx).Exec(`DELETE FROM mcp_upstream_credentials WHERE id = $1`, id).Error; err != nil {
+		return fmt.Errorf("mcp upstream credential delete: %w", err)
+	}
+	return nil
+}
+
+func (r *mcpUpstreamRepo) DeleteCredentialsByUpstream(ctx context.Context, upstreamID string) error {
+	if err := r.db.WithContext(ctx).Exec(
+		`DELETE FROM mcp_upstream_credentials WHERE upstream_id = $1`, upstreamID,
+	).Error; err != nil {
+		return fmt.Errorf("mcp upstream credential delete by upstream: %w", err)
+	}
+	return nil
+}
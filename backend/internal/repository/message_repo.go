@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -11,8 +12,17 @@ import (
 	"github.com/linkclaw/backend/internal/domain"
 )
 
-const msgColumns = `id, company_id, sender_id, channel_id, receiver_id, content, msg_type,
-	COALESCE(task_meta::text, 'null')::json as task_meta, created_at`
+// msgColumnsOf 以 prefix（表别名，如 "m."）生成消息查询列；已删除消息不返回原文
+func msgColumnsOf(p string) string {
+	return strings.NewReplacer("{p}", p).Replace(`{p}id, {p}company_id, {p}sender_id, {p}channel_id, {p}receiver_id,
+	{p}thread_root_id, CASE WHEN {p}deleted_at IS NULL THEN {p}content ELSE '' END AS content, {p}msg_type,
+	COALESCE({p}task_meta::text, 'null')::json as task_meta,
+	COALESCE(CASE WHEN {p}deleted_at IS NULL THEN {p}blocks END::text, 'null')::json AS blocks, {p}mentions, {p}mention_groups,
+	{p}reply_count, {p}last_reply_at, {p}edited_at, {p}deleted_at, {p}deleted_by, {p}pinned_at, {p}pinned_by,
+	{p}created_at`)
+}
+
+var msgColumns = msgColumnsOf("")
 
 type messageRepo struct {
 	db *gorm.DB
@@ -24,14 +34,23 @@ func NewMessageRepo(db *gorm.DB) MessageRepo {
 
 func (r *messageRepo) Create(ctx context.Context, m *domain.Message) error {
 	var createdAt time.Time
-	result := r.db.WithContext(ctx).Raw(
-		`INSERT INTO messages
-		(id, company_id, sender_id, channel_id, receiver_id, content, msg_type, task_meta)
-		VALUES
-		($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING created_at`,
+	// 线程回复同时累加根消息的回复数
+	result := conn(ctx, r.db).Raw(
+		`WITH ins AS (
+			INSERT INTO messages
+			(id, company_id, sender_id, channel_id, receiver_id, content, msg_type, task_meta, thread_root_id,
+			 mentions, mention_groups, blocks)
+			VALUES
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			RETURNING created_at
+		), root AS (
+			UPDATE messages SET reply_count = reply_count + 1, last_reply_at = (SELECT created_at FROM ins)
+			WHERE id = $9
+		)
+		SELECT created_at FROM ins`,
 		m.ID, m.CompanyID, m.SenderID, m.ChannelID, m.ReceiverID,
-		m.Content, string(m.MsgType), m.TaskMeta).Scan(&createdAt)
+		m.Content, string(m.MsgType), m.TaskMeta, m.ThreadRootID,
+		m.Mentions, m.MentionGroups, m.Blocks).Scan(&createdAt)
 	if result.Error != nil {
 		return fmt.Errorf("message create: %w", result.Error)
 	}
@@ -46,16 +65,16 @@ func (r *messageRepo) ListByChannel(ctx context.Context, channelID string, limit
 	var msgs []*domain.Message
 	var result *gorm.DB
 	if beforeID != "" {
-		result = r.db.WithContext(ctx).Raw(
+		result = conn(ctx, r.db).Raw(
 			`SELECT `+msgColumns+` FROM messages
-			WHERE channel_id = $1
+			WHERE channel_id = $1 AND thread_root_id IS NULL
 			  AND created_at < (SELECT created_at FROM messages WHERE id = $2)
 			ORDER BY created_at DESC LIMIT $3`,
 			channelID, beforeID, limit,
 		).Scan(&msgs)
 	} else {
-		result = r.db.WithContext(ctx).Raw(
-			`SELECT `+msgColumns+` FROM messages WHERE channel_id = $1 ORDER BY created_at DESC LIMIT $2`,
+		result = conn(ctx, r.db).Raw(
+			`SELECT `+msgColumns+` FROM messages WHERE channel_id = $1 AND thread_root_id IS NULL ORDER BY created_at DESC LIMIT $2`,
 			channelID, limit,
 		).Scan(&msgs)
 	}
@@ -72,18 +91,18 @@ func (r *messageRepo) ListDM(ctx context.Context, agentA, agentB string, limit i
 	var msgs []*domain.Message
 	var result *gorm.DB
 	if beforeID != "" {
-		result = r.db.WithContext(ctx).Raw(
+		result = conn(ctx, r.db).Raw(
 			`SELECT `+msgColumns+` FROM messages
-			WHERE channel_id IS NULL
+			WHERE channel_id IS NULL AND thread_root_id IS NULL
 			  AND ((sender_id = $1 AND receiver_id = $2) OR (sender_id = $2 AND receiver_id = $1))
 			  AND created_at < (SELECT created_at FROM messages WHERE id = $3)
 			ORDER BY created_at DESC LIMIT $4`,
 			agentA, agentB, beforeID, limit,
 		).Scan(&msgs)
 	} else {
-		result = r.db.WithContext(ctx).Raw(
+		result = conn(ctx, r.db).Raw(
 			`SELECT `+msgColumns+` FROM messages
-			WHERE channel_id IS NULL
+			WHERE channel_id IS NULL AND thread_root_id IS NULL
 			  AND ((sender_id = $1 AND receiver_id = $2) OR (sender_id = $2 AND receiver_id = $1))
 			ORDER BY created_at DESC LIMIT $3`,
 			agentA, agentB, limit,
@@ -112,7 +131,7 @@ func (r *messageRepo) MarkRead(ctx context.Context, agentID string, messageIDs [
 		args = append(args, strings.TrimSpace(id))
 	}
 	sb.WriteString(" ON CONFLICT DO NOTHING")
-	result := r.db.WithContext(ctx).Exec(sb.String(), args...)
+	result := conn(ctx, r.db).Exec(sb.String(), args...)
 	if result.Error != nil {
 		return fmt.Errorf("message mark read: %w", result.Error)
 	}
@@ -121,35 +140,238 @@ func (r *messageRepo) MarkRead(ctx context.Context, agentID string, messageIDs [
 
 func (r *messageRepo) ListUnreadForAgent(ctx context.Context, agentID, companyID string) ([]*domain.Message, error) {
 	var msgs []*domain.Message
-	result := r.db.WithContext(ctx).Raw(
+	result := conn(ctx, r.db).Raw(
 		`WITH target AS (
-			SELECT ?::uuid AS aid, ?::uuid AS cid,
-			       (SELECT name FROM agents WHERE id = ?::uuid) AS aname
+			SELECT ?::uuid AS aid, ?::uuid AS cid
 		)
-		SELECT m.id, m.company_id, m.sender_id, m.channel_id, m.receiver_id,
-		       m.content, m.msg_type,
-		       COALESCE(m.task_meta::text, 'null')::json as task_meta,
-		       m.created_at
+		SELECT `+msgColumnsOf("m.")+`
 		FROM messages m
 		CROSS JOIN target t
 		LEFT JOIN message_reads mr ON m.id = mr.message_id AND mr.agent_id = t.aid
 		LEFT JOIN agents sender ON m.sender_id = sender.id
+		LEFT JOIN channels ch ON m.channel_id = ch.id
+		LEFT JOIN messages root ON m.thread_root_id = root.id
 		WHERE m.company_id = t.cid
 		  AND mr.message_id IS NULL
+		  AND m.deleted_at IS NULL
 		  AND (
 		      m.receiver_id = t.aid
 		      OR (m.channel_id IS NOT NULL AND (
 		          sender.id IS NULL
 		          OR sender.is_human = true
-		          OR m.content LIKE '%@' || t.aname || '%'
+		          OR m.mentions::jsonb @> jsonb_build_array(t.aid::text)
+		          OR root.sender_id = t.aid
+		      ) AND (
+		          NOT COALESCE(ch.is_private, false)
+		          OR EXISTS (SELECT 1 FROM channel_members cm WHERE cm.channel_id = m.channel_id AND cm.agent_id = t.aid)
 		      ))
 		  )
 		  AND (m.sender_id IS NULL OR m.sender_id != t.aid)
 		ORDER BY m.created_at ASC`,
-		agentID, companyID, agentID,
+		agentID, companyID,
 	).Scan(&msgs)
 	if result.Error != nil {
 		return nil, fmt.Errorf("message list unread: %w", result.Error)
 	}
 	return msgs, nil
 }
+
+func (r *messageRepo) GetByID(ctx context.Context, id string) (*domain.Message, error) {
+	var m domain.Message
+	res := conn(ctx, r.db).Raw(`SELECT `+msgColumns+` FROM messages WHERE id = $1`, id).Scan(&m)
+	if res.Error != nil {
+		return nil, fmt.Errorf("message get: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &m, nil
+}
+
+// ListThread 按时间正序列出线程回复（不含根消息）；afterID 非空时从该回复之后开始
+func (r *messageRepo) ListThread(ctx context.Context, rootID string, limit int, afterID string) ([]*domain.Message, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	query := `SELECT ` + msgColumns + ` FROM messages WHERE thread_root_id = $1`
+	args := []any{rootID}
+	if afterID != "" {
+		query += ` AND created_at > (SELECT created_at FROM messages WHERE id = $2)`
+		args = append(args, afterID)
+	}
+	query += fmt.Sprintf(` ORDER BY created_at ASC LIMIT $%d`, len(args)+1)
+	args = append(args, limit)
+
+	var msgs []*domain.Message
+	if err := conn(ctx, r.db).Raw(query, args...).Scan(&msgs).Error; err != nil {
+		return nil, fmt.Errorf("message list thread: %w", err)
+	}
+	return msgs, nil
+}
+
+// UpdateContent 修改消息内容与重新解析的提及，并把修改前的原文写入编辑历史
+func (r *messageRepo) UpdateContent(ctx context.Context, id, editorID, content string, mentions, groups domain.StringList) error {
+	res := conn(ctx, r.db).Exec(
+		`WITH old AS (
+			SELECT id, company_id, content FROM messages WHERE id = $1 AND deleted_at IS NULL
+		), hist AS (
+			INSERT INTO message_edits (message_id, company_id, editor_id, old_content)
+			SELECT id, company_id, $2, content FROM old
+		)
+		UPDATE messages SET content = $3, mentions = $4, mention_groups = $5, edited_at = NOW(),
+		       embedding = NULL, embed_attempts = 0
+		WHERE id = (SELECT id FROM old)`,
+		id, editorID, content, mentions, groups,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("message update: %w", res.Error)
+	}
+	return nil
+}
+
+func (r *messageRepo) ListEdits(ctx context.Context, messageID string) ([]*domain.MessageEdit, error) {
+	var edits []*domain.MessageEdit
+	if err := conn(ctx, r.db).Raw(
+		`SELECT * FROM message_edits WHERE message_id = $1 ORDER BY created_at`, messageID,
+	).Scan(&edits).Error; err != nil {
+		return nil, fmt.Errorf("message list edits: %w", err)
+	}
+	return edits, nil
+}
+
+// SoftDelete 软删除消息；线程回复被删除时同步扣减根消息的回复数
+func (r *messageRepo) SoftDelete(ctx context.Context, id, actorID string) error {
+	res := conn(ctx, r.db).Exec(
+		`WITH del AS (
+			UPDATE messages SET deleted_at = NOW(), deleted_by = $2, pinned_at = NULL, pinned_by = NULL
+			WHERE id = $1 AND deleted_at IS NULL
+			RETURNING thread_root_id
+		)
+		UPDATE messages SET reply_count = GREATEST(reply_count - 1, 0)
+		WHERE id = (SELECT thread_root_id FROM del)`,
+		id, actorID,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("message delete: %w", res.Error)
+	}
+	return nil
+}
+
+func (r *messageRepo) AddReaction(ctx context.Context, re *domain.MessageReaction) error {
+	if err := conn(ctx, r.db).Exec(
+		`INSERT INTO message_reactions (message_id, agent_id, emoji, company_id)
+		VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`,
+		re.MessageID, re.AgentID, re.Emoji, re.CompanyID,
+	).Error; err != nil {
+		return fmt.Errorf("message add reaction: %w", err)
+	}
+	return nil
+}
+
+func (r *messageRepo) RemoveReaction(ctx context.Context, messageID, agentID, emoji string) error {
+	if err := conn(ctx, r.db).Exec(
+		`DELETE FROM message_reactions WHERE message_id = $1 AND agent_id = $2 AND emoji = $3`,
+		messageID, agentID, emoji,
+	).Error; err != nil {
+		return fmt.Errorf("message remove reaction: %w", err)
+	}
+	return nil
+}
+
+func (r *messageRepo) ListReactions(ctx context.Context, messageIDs []string) ([]*domain.MessageReaction, error) {
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+	var list []*domain.MessageReaction
+	if err := conn(ctx, r.db).Raw(
+		`SELECT * FROM message_reactions WHERE message_id IN ? ORDER BY created_at`, messageIDs,
+	).Scan(&list).Error; err != nil {
+		return nil, fmt.Errorf("message list reactions: %w", err)
+	}
+	return list, nil
+}
+
+// UpdateBlocks 更新消息块快照（如审批卡片处理后的状态），不计入编辑历史
+func (r *messageRepo) UpdateBlocks(ctx context.Context, id string, blocks json.RawMessage) error {
+	if err := conn(ctx, r.db).Exec(
+		`UPDATE messages SET blocks = $2 WHERE id = $1 AND deleted_at IS NULL`, id, blocks,
+	).Error; err != nil {
+		return fmt.Errorf("message update blocks: %w", err)
+	}
+	return nil
+}
+
+func (r *messageRepo) CreateAttachment(ctx context.Context, a *domain.MessageAttachment) error {
+	if err := conn(ctx, r.db).Exec(
+		`INSERT INTO message_attachments
+		(id, company_id, filename, original_filename, file_size, mime_type, storage_path, sha256, uploaded_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		a.ID, a.CompanyID, a.Filename, a.OriginalFilename, a.FileSize, a.MimeType,
+		a.StoragePath, a.SHA256, a.UploadedBy, a.CreatedAt,
+	).Error; err != nil {
+		return fmt.Errorf("message attachment create: %w", err)
+	}
+	return nil
+}
+
+func (r *messageRepo) GetAttachment(ctx context.Context, id string) (*domain.MessageAttachment, error) {
+	var a domain.MessageAttachment
+	res := conn(ctx, r.db).Raw(`SELECT * FROM message_attachments WHERE id = $1`, id).Scan(&a)
+	if res.Error != nil {
+		return nil, fmt.Errorf("message attachment get: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &a, nil
+}
+
+// LinkAttachments 把尚未关联的附件关联到消息，返回实际关联的数量
+func (r *messageRepo) LinkAttachments(ctx context.Context, messageID string, ids []string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	res := conn(ctx, r.db).Exec(
+		`UPDATE message_attachments SET message_id = ? WHERE id IN ? AND message_id IS NULL`, messageID, ids,
+	)
+	if res.Error != nil {
+		return 0, fmt.Errorf("message attachment link: %w", res.Error)
+	}
+	return res.RowsAffected, nil
+}
+
+// DeleteUnlinkedAttachments 删除上传后一直未随消息发送的附件记录，返回其存储 key
+func (r *messageRepo) DeleteUnlinkedAttachments(ctx context.Context, before time.Time) ([]string, error) {
+	var keys []string
+	if err := conn(ctx, r.db).Raw(
+		`DELETE FROM message_attachments WHERE message_id IS NULL AND created_at < $1 RETURNING storage_path`, before,
+	).Scan(&keys).Error; err != nil {
+		return nil, fmt.Errorf("message attachment cleanup: %w", err)
+	}
+	return keys, nil
+}
+
+// SetPinned pinnedBy 为 nil 时取消置顶
+func (r *messageRepo) SetPinned(ctx context.Context, id string, pinnedBy *string) error {
+	res := conn(ctx, r.db).Exec(
+		`UPDATE messages SET pinned_by = $1::uuid, pinned_at = CASE WHEN $1::uuid IS NULL THEN NULL ELSE NOW() END
+		WHERE id = $2`,
+		pinnedBy, id,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("message pin: %w", res.Error)
+	}
+	return nil
+}
+
+func (r *messageRepo) ListPinned(ctx context.Context, channelID string) ([]*domain.Message, error) {
+	var msgs []*domain.Message
+	if err := conn(ctx, r.db).Raw(
+		`SELECT `+msgColumns+` FROM messages
+		WHERE channel_id = $1 AND pinned_at IS NOT NULL AND deleted_at IS NULL
+		ORDER BY pinned_at DESC`, channelID,
+	).Scan(&msgs).Error; err != nil {
+		return nil, fmt.Errorf("message list pinned: %w", err)
+	}
+	return msgs, nil
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+// messageEmbedMaxAttempts embedding 生成失败达到该次数后不再重试，避免坏数据堵住队列
+const messageEmbedMaxAttempts = 3
+
+// messageFilter 按 MessageQuery 拼接的 WHERE 条件及其位置参数。
+// SQL 中不能出现 @：gorm 见到 @ 会按命名参数解析并丢弃全部 $n 参数，
+// 因此 @@ / @> 一律写成 ts_match_vq / jsonb_contains 函数形式
+type messageFilter struct {
+	where []string
+	args  []interface{}
+}
+
+// arg 追加一个参数并返回其占位符
+func (f *messageFilter) arg(v interface{}) string {
+	f.args = append(f.args, v)
+	return fmt.Sprintf("$%d", len(f.args))
+}
+
+func (f *messageFilter) clause() string {
+	return strings.Join(f.where, " AND ")
+}
+
+// buildMessageFilter 公共过滤条件（不含全文检索）；查询需 LEFT JOIN channels ch
+func buildMessageFilter(q MessageQuery) *messageFilter {
+	f := &messageFilter{}
+	f.where = append(f.where, "m.company_id = "+f.arg(q.CompanyID), "m.deleted_at IS NULL")
+
+	// 可见性：本人参与的私信、公开频道、所在的私有频道
+	v := f.arg(q.ViewerID)
+	f.where = append(f.where, fmt.Sprintf(`(
+		(m.channel_id IS NULL AND (m.sender_id = %[1]s OR m.receiver_id = %[1]s))
+		OR (m.channel_id IS NOT NULL AND (NOT COALESCE(ch.is_private, false)
+		    OR EXISTS (SELECT 1 FROM channel_members cm WHERE cm.channel_id = m.channel_id AND cm.agent_id = %[1]s)))
+	)`, v))
+
+	if q.ChannelID != "" {
+		f.where = append(f.where, "m.channel_id = "+f.arg(q.ChannelID))
+	}
+	if q.SenderID != "" {
+		f.where = append(f.where, "m.sender_id = "+f.arg(q.SenderID))
+	}
+	if q.MentionID != "" {
+		f.where = append(f.where, "jsonb_contains(m.mentions::jsonb, jsonb_build_array("+f.arg(q.MentionID)+"::text))")
+	}
+	if len(q.ChannelIDs) > 0 {
+		ids := make([]string, len(q.ChannelIDs))
+		for i, id := range q.ChannelIDs {
+			ids[i] = f.arg(id)
+		}
+		f.where = append(f.where, "(m.channel_id IS NULL OR m.channel_id IN ("+strings.Join(ids, ", ")+"))")
+	}
+	if q.ExcludeDMs {
+		f.where = append(f.where, "m.channel_id IS NOT NULL")
+	}
+	if q.UnreadOnly {
+		f.where = append(f.where, fmt.Sprintf(`(m.sender_id IS NULL OR m.sender_id <> %[1]s)
+		AND NOT EXISTS (SELECT 1 FROM message_reads mr WHERE mr.message_id = m.id AND mr.agent_id = %[1]s)`, v))
+	}
+	if q.After != nil {
+		f.where = append(f.where, "m.created_at >= "+f.arg(*q.After))
+	}
+	if q.Before != nil {
+		f.where = append(f.where, "m.created_at < "+f.arg(*q.Before))
+	}
+	return f
+}
+
+// escapeLike 转义 LIKE 模式中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// Search 全文搜索消息；Text 为空时按过滤条件列出。
+// 'simple' 分词无法切分中文，因此同时按子串匹配兜底，命中分词的结果排在前面。
+func (r *messageRepo) Search(ctx context.Context, q MessageQuery) ([]*domain.Message, error) {
+	f := buildMessageFilter(q)
+	orderBy := "m.created_at DESC, m.id DESC"
+	if q.Text != "" {
+		tsq := "plainto_tsquery('simple', " + f.arg(q.Text) + ")"
+		like := f.arg("%" + escapeLike(q.Text) + "%")
+		f.where = append(f.where, fmt.Sprintf("(ts_match_vq(m.search_vec, %s) OR m.content ILIKE %s)", tsq, like))
+		orderBy = fmt.Sprintf("ts_rank(m.search_vec, %s) DESC, ", tsq) + orderBy
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+
+	var msgs []*domain.Message
+	if err := conn(ctx, r.db).Raw(
+		fmt.Sprintf(`SELECT %s FROM messages m LEFT JOIN channels ch ON ch.id = m.channel_id
+		WHERE %s ORDER BY %s LIMIT %s OFFSET %s`,
+			msgColumnsOf("m."), f.clause(), orderBy, f.arg(limit), f.arg(q.Offset)),
+		f.args...,
+	).Scan(&msgs).Error; err != nil {
+		return nil, fmt.Errorf("message search: %w", err)
+	}
+	return msgs, nil
+}
+
+// SemanticSearch 按 embedding 余弦相似度排序，q.Text 被忽略；需要 pgvector
+func (r *messageRepo) SemanticSearch(ctx context.Context, q MessageQuery, embedding []float32) ([]*domain.Message, error) {
+	f := buildMessageFilter(q)
+	f.where = append(f.where, "m.embedding IS NOT NULL")
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+
+	var msgs []*domain.Message
+	if err := conn(ctx, r.db).Raw(
+		fmt.Sprintf(`SELECT %s FROM messages m LEFT JOIN channels ch ON ch.id = m.channel_id
+		WHERE %s ORDER BY m.embedding <=> %s::vector LIMIT %s OFFSET %s`,
+			msgColumnsOf("m."), f.clause(), f.arg(float32SliceToVec(embedding)), f.arg(limit), f.arg(q.Offset)),
+		f.args...,
+	).Scan(&msgs).Error; err != nil {
+		return nil, fmt.Errorf("message semantic search: %w", err)
+	}
+	return msgs, nil
+}
+
+// ListPendingEmbedding 待生成 embedding 的消息：仅限已配置 embedding 的公司，最新的优先
+func (r *messageRepo) ListPendingEmbedding(ctx context.Context, limit int) ([]*domain.Message, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	var msgs []*domain.Message
+	if err := conn(ctx, r.db).Raw(
+		`SELECT `+msgColumnsOf("m.")+` FROM messages m
+		JOIN companies c ON c.id = m.company_id
+		WHERE m.embedding IS NULL AND m.deleted_at IS NULL
+		  AND m.msg_type = 'text' AND m.content <> ''
+		  AND m.embed_attempts < $1
+		  AND COALESCE(c.embedding_base_url, '') <> ''
+		ORDER BY m.created_at DESC LIMIT $2`,
+		messageEmbedMaxAttempts, limit,
+	).Scan(&msgs).Error; err != nil {
+		return nil, fmt.Errorf("message list pending embedding: %w", err)
+	}
+	return msgs, nil
+}
+
+func (r *messageRepo) UpdateEmbedding(ctx context.Context, id string, embedding []float32) error {
+	if err := conn(ctx, r.db).Exec(
+		`UPDATE messages SET embedding = $1::vector WHERE id = $2`,
+		float32SliceToVec(embedding), id,
+	).Error; err != nil {
+		return fmt.Errorf("message update embedding: %w", err)
+	}
+	return nil
+}
+
+// VectorEmbeddingSupported messages.embedding 是否为 pgvector 类型；
+// pgvector 不可用时迁移把该列建为 TEXT，写入 embedding 必然失败
+func (r *messageRepo) VectorEmbeddingSupported(ctx context.Context) (bool, error) {
+	var ok bool
+	if err := conn(ctx, r.db).Raw(
+		`SELECT EXISTS (SELECT 1 FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'messages'
+		  AND column_name = 'embedding' AND udt_name = 'vector')`,
+	).Scan(&ok).Error; err != nil {
+		return false, fmt.Errorf("message check vector column: %w", err)
+	}
+	return ok, nil
+}
+
+// MarkEmbeddingFailed 记录一次 embedding 生成失败
+func (r *messageRepo) MarkEmbeddingFailed(ctx context.Context, id string) error {
+	if err := conn(ctx, r.db).Exec(
+		`UPDATE messages SET embed_attempts = embed_attempts + 1 WHERE id = $1`, id,
+	).Error; err != nil {
+		return fmt.Errorf("message mark embedding failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func messageSearchQueries() map[string]MessageQuery {
+	after := time.Now().Add(-time.Hour)
+	base := MessageQuery{CompanyID: "00000000-0000-0000-0000-000000000001", ViewerID: "00000000-0000-0000-0000-000000000002"}
+	text, mention, all := base, base, base
+	text.Text = "数据库 迁移"
+	mention.MentionID = base.ViewerID
+	all.Text = "deploy"
+	all.MentionID = base.ViewerID
+	all.ChannelIDs = []string{"00000000-0000-0000-0000-000000000003"}
+	all.UnreadOnly = true
+	all.After = &after
+	return map[string]MessageQuery{"text": text, "mention": mention, "all filters": all}
+}
+
+func TestMessageSearchBindsAllArgs(t *testing.T) {
+	for name, q := range messageSearchQueries() {
+		t.Run(name, func(t *testing.T) {
+			db, pool := newRecordingDB(t)
+			_, _ = NewMessageRepo(db).Search(context.Background(), q)
+			assertBound(t, pool)
+		})
+	}
+	db, pool := newRecordingDB(t)
+	_, _ = NewMessageRepo(db).SemanticSearch(context.Background(), messageSearchQueries()["mention"], []float32{0.1, 0.2})
+	assertBound(t, pool)
+}
+
+func TestMessageSearchOnPostgres(t *testing.T) {
+	repo := NewMessageRepo(openDevDB(t))
+	for name, q := range messageSearchQueries() {
+		if _, err := repo.Search(context.Background(), q); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
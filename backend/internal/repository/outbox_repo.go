@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+type outboxRepo struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepo(db *gorm.DB) OutboxRepo {
+	return &outboxRepo{db: db}
+}
+
+func (r *outboxRepo) Enqueue(ctx context.Context, e *domain.OutboxEvent) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	if err := conn(ctx, r.db).Exec(
+		`INSERT INTO event_outbox (id, event_type, payload) VALUES ($1, $2, $3)`,
+		e.ID, e.EventType, string(e.Payload),
+	).Error; err != nil {
+		return fmt.Errorf("outbox enqueue: %w", err)
+	}
+	return nil
+}
+
+func (r *outboxRepo) ListPending(ctx context.Context, limit int) ([]*domain.OutboxEvent, error) {
+	var list []*domain.OutboxEvent
+	if err := conn(ctx, r.db).Raw(
+		`SELECT * FROM event_outbox WHERE published_at IS NULL ORDER BY created_at LIMIT $1`, limit,
+	).Scan(&list).Error; err != nil {
+		return nil, fmt.Errorf("outbox list pending: %w", err)
+	}
+	return list, nil
+}
+
+func (r *outboxRepo) MarkPublished(ctx context.Context, id string) error {
+	if err := conn(ctx, r.db).Exec(
+		`UPDATE event_outbox SET published_at = NOW(), attempts = attempts + 1 WHERE id = $1`, id,
+	).Error; err != nil {
+		return fmt.Errorf("outbox mark published: %w", err)
+	}
+	return nil
+}
+
+func (r *outboxRepo) DeletePublishedBefore(ctx context.Context, days int) error {
+	if err := conn(ctx, r.db).Exec(
+		`DELETE FROM event_outbox WHERE published_at IS NOT NULL AND published_at < NOW() - make_interval(days => $1)`, days,
+	).Error; err != nil {
+		return fmt.Errorf("outbox cleanup: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+type scheduledMessageRepo struct {
+	db *gorm.DB
+}
+
+func NewScheduledMessageRepo(db *gorm.DB) ScheduledMessageRepo {
+	return &scheduledMessageRepo{db: db}
+}
+
+func (r *scheduledMessageRepo) Create(ctx context.Context, m *domain.ScheduledMessage) error {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	res := r.db.WithContext(ctx).Exec(
+		`INSERT INTO scheduled_messages
+			(id, company_id, created_by, kind, channel_id, receiver_id, task_id, content, cron, timezone, status, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		m.ID, m.CompanyID, m.CreatedBy, m.Kind, m.ChannelID, m.ReceiverID, m.TaskID, m.Content, m.Cron, m.Timezone, m.Status, m.NextRunAt,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("scheduled message create: %w", res.Error)
+	}
+	return nil
+}
+
+func (r *scheduledMessageRepo) GetByID(ctx context.Context, id string) (*domain.ScheduledMessage, error) {
+	var m domain.ScheduledMessage
+	res := r.db.WithContext(ctx).Raw(`SELECT * FROM scheduled_messages WHERE id = $1`, id).Scan(&m)
+	if res.Error != nil {
+		return nil, fmt.Errorf("scheduled message get: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &m, nil
+}
+
+func (r *scheduledMessageRepo) List(ctx context.Context, companyID, createdBy string, activeOnly bool) ([]*domain.ScheduledMessage, error) {
+	where := `company_id = $1`
+	args := []any{companyID}
+	if createdBy != "" {
+		args = append(args, createdBy)
+		where += fmt.Sprintf(` AND created_by = $%d`, len(args))
+	}
+	if activeOnly {
+		where += ` AND status = 'scheduled'`
+	}
+	var list []*domain.ScheduledMessage
+	if err := r.db.WithContext(ctx).Raw(
+		`SELECT * FROM scheduled_messages WHERE `+where+` ORDER BY next_run_at NULLS LAST, created_at DESC LIMIT 200`, args...,
+	).Scan(&list).Error; err != nil {
+		return nil, fmt.Errorf("scheduled message list: %w", err)
+	}
+	return list, nil
+}
+
+func (r *scheduledMessageRepo) CountActive(ctx context.Context, createdBy string) (int64, error) {
+	var n int64
+	if err := r.db.WithContext(ctx).Raw(
+		`SELECT COUNT(*) FROM scheduled_messages WHERE created_by = $1 AND status = 'scheduled'`, createdBy,
+	).Scan(&n).Error; err != nil {
+		return 0, fmt.Errorf("scheduled message count: %w", err)
+	}
+	return n, nil
+}
+
+func (r *scheduledMessageRepo) Cancel(ctx context.Context, id string) (bool, error) {
+	res := r.db.WithContext(ctx).Exec(
+		`UPDATE scheduled_messages SET status = 'cancelled', next_run_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'scheduled'`, id,
+	)
+	if res.Error != nil {
+		return false, fmt.Errorf("scheduled message cancel: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *scheduledMessageRepo) ListDue(ctx context.Context, now time.Time, limit int) ([]*domain.ScheduledMessage, error) {
+	var list []*domain.ScheduledMessage
+	if err := r.db.WithContext(ctx).Raw(
+		`SELECT * FROM scheduled_messages WHERE status = 'scheduled' AND next_run_at <= $1 ORDER BY next_run_at LIMIT $2`, now, limit,
+	).Scan(&list).Error; err != nil {
+		return nil, fmt.Errorf("scheduled message list due: %w", err)
+	}
+	return list, nil
+}
+
+func (r *scheduledMessageRepo) Advance(ctx context.Context, id string, prev, next time.Time) (bool, error) {
+	res := r.db.WithContext(ctx).Exec(
+		`UPDATE scheduled_messages
+		SET next_run_at = $1, last_run_at = NOW(), run_count = run_count + 1, updated_at = NOW()
+		WHERE id = $2 AND status = 'scheduled' AND next_run_at = $3`,
+		next, id, prev,
+	)
+	if res.Error != nil {
+		return false, fmt.Errorf("scheduled message advance: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *scheduledMessageRepo) SetResult(ctx context.Context, id string, messageID *string, lastError string, status domain.ScheduledMessageStatus) error {
+	if err := r.db.WithContext(ctx).Exec(
+		`UPDATE scheduled_messages
+		SET last_message_id = COALESCE($1, last_message_id), last_error = $2,
+			status = COALESCE(NULLIF($3, ''), status),
+			next_run_at = CASE WHEN $3 IN ('sent', 'failed') THEN NULL ELSE next_run_at END
+		WHERE id = $4`,
+		messageID, lastError, string(status), id,
+	).Error; err != nil {
+		return fmt.Errorf("scheduled message set result: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var errRecorded = errors.New("recorded")
+
+type recordedQuery struct {
+	SQL  string
+	Args []interface{}
+}
+
+// recordingPool 记录 gorm 交给驱动的最终 SQL 与参数，不连接数据库
+type recordingPool struct {
+	queries []recordedQuery
+}
+
+func (p *recordingPool) record(query string, args []interface{}) {
+	p.queries = append(p.queries, recordedQuery{SQL: query, Args: args})
+}
+
+func (p *recordingPool) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, errRecorded
+}
+
+func (p *recordingPool) ExecContext(_ context.Context, query string, args ...interface{}) (sql.Result, error) {
+	p.record(query, args)
+	return nil, errRecorded
+}
+
+func (p *recordingPool) QueryContext(_ context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	p.record(query, args)
+	return nil, errRecorded
+}
+
+func (p *recordingPool) QueryRowContext(_ context.Context, query string, args ...interface{}) *sql.Row {
+	p.record(query, args)
+	return nil
+}
+
+func newRecordingDB(t *testing.T) (*gorm.DB, *recordingPool) {
+	t.Helper()
+	pool := &recordingPool{}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: pool}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("open recording db: %v", err)
+	}
+	return db, pool
+}
+
+var bindVarRe = regexp.MustCompile(`\$(\d+)`)
+
+// assertBound 检查每条 SQL 的 $n 占位符都有对应参数（gorm 把含 @ 的 SQL 当作命名参数时会丢参数）
+func assertBound(t *testing.T, pool *recordingPool) {
+	t.Helper()
+	if len(pool.queries) == 0 {
+		t.Fatal("no query reached the driver")
+	}
+	for _, q := range pool.queries {
+		max := 0
+		for _, m := range bindVarRe.FindAllStringSubmatch(q.SQL, -1) {
+			if n, _ := strconv.Atoi(m[1]); n > max {
+				max = n
+			}
+		}
+		if max != len(q.Args) {
+			t.Errorf("query uses $%d but got %d args:\n%s", max, len(q.Args), q.SQL)
+		}
+	}
+}
+
+// openDevDB 连接已执行迁移的本地开发数据库（TEST_DATABASE_URL 可覆盖），不可用时跳过
+func openDevDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		dsn = "host=localhost port=5432 user=linkclaw password=linkclaw_dev_pass dbname=linkclaw sslmode=disable"
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Skipf("无法连接测试数据库 (dev DB): %v", err)
+	}
+	return db
+}
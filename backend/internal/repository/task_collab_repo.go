@@ -18,9 +18,10 @@ func NewTaskCollabRepo(db *gorm.DB) TaskCollabRepo {
 }
 
 func (r *taskCollabRepo) AddComment(ctx context.Context, c *domain.TaskComment) error {
-	res := r.db.WithContext(ctx).Exec(
-		`INSERT INTO task_comments (id, task_id, company_id, agent_id, content) VALUES ($1, $2, $3, $4, $5)`,
-		c.ID, c.TaskID, c.CompanyID, c.AgentID, c.Content,
+	res := conn(ctx, r.db).Exec(
+		`INSERT INTO task_comments (id, task_id, company_id, agent_id, parent_comment_id, content, mentions)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		c.ID, c.TaskID, c.CompanyID, c.AgentID, c.ParentCommentID, c.Content, c.Mentions,
 	)
 	if res.Error != nil {
 		return fmt.Errorf("task comment add: %w", res.Error)
@@ -30,7 +31,7 @@ func (r *taskCollabRepo) AddComment(ctx context.Context, c *domain.TaskComment)
 
 func (r *taskCollabRepo) ListComments(ctx context.Context, taskID string) ([]*domain.TaskComment, error) {
 	var comments []*domain.TaskComment
-	if err := r.db.WithContext(ctx).Raw(
+	if err := conn(ctx, r.db).Raw(
 		`SELECT * FROM task_comments WHERE task_id = $1 ORDER BY created_at ASC`, taskID,
 	).Scan(&comments).Error; err != nil {
 		return nil, fmt.Errorf("task comment list: %w", err)
@@ -38,9 +39,54 @@ func (r *taskCollabRepo) ListComments(ctx context.Context, taskID string) ([]*do
 	return comments, nil
 }
 
+func (r *taskCollabRepo) GetComment(ctx context.Context, id string) (*domain.TaskComment, error) {
+	var c domain.TaskComment
+	res := conn(ctx, r.db).Raw(`SELECT * FROM task_comments WHERE id = $1`, id).Scan(&c)
+	if res.Error != nil {
+		return nil, fmt.Errorf("task comment get: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+// UpdateComment 更新评论内容与提及列表，并在同一语句中写入编辑前的原文
+func (r *taskCollabRepo) UpdateComment(ctx context.Context, id, editorID, content string, mentions domain.StringList) error {
+	res := conn(ctx, r.db).Exec(
+		`WITH old AS (
+			SELECT id, company_id, content FROM task_comments WHERE id = $1 FOR UPDATE
+		), edit AS (
+			INSERT INTO task_comment_edits (comment_id, company_id, editor_id, old_content)
+			SELECT id, company_id, $2, content FROM old
+		)
+		UPDATE task_comments SET content = $3, mentions = $4, edited_at = NOW() WHERE id = $1`,
+		id, editorID, content, mentions,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("task comment update: %w", res.Error)
+	}
+	return nil
+}
+
+func (r *taskCollabRepo) ListCommentEdits(ctx context.Context, commentID string) ([]*domain.TaskCommentEdit, error) {
+	var edits []*domain.TaskCommentEdit
+	if err := conn(ctx, r.db).Raw(
+		`SELECT * FROM task_comment_edits WHERE comment_id = $1 ORDER BY created_at ASC`, commentID,
+	).Scan(&edits).Error; err != nil {
+		return nil, fmt.Errorf("task comment edit list: %w", err)
+	}
+	return edits, nil
+}
+
 func (r *taskCollabRepo) DeleteComment(ctx context.Context, id, agentID, companyID string) error {
-	res := r.db.WithContext(ctx).Exec(
-		`DELETE FROM task_comments WHERE id = $1 AND agent_id = $2 AND company_id = $3`,
+	res := conn(ctx, r.db).Exec(
+		`WITH del AS (
+			DELETE FROM task_comments WHERE id = $1 AND agent_id = $2 AND company_id = $3
+			RETURNING id, parent_comment_id
+		)
+		UPDATE task_comments c SET parent_comment_id = del.parent_comment_id
+		FROM del WHERE c.parent_comment_id = del.id`,
 		id, agentID, companyID,
 	)
 	if res.Error != nil {
@@ -50,7 +96,7 @@ func (r *taskCollabRepo) DeleteComment(ctx context.Context, id, agentID, company
 }
 
 func (r *taskCollabRepo) AddDependency(ctx context.Context, d *domain.TaskDependency) error {
-	res := r.db.WithContext(ctx).Exec(
+	res := conn(ctx, r.db).Exec(
 		`INSERT INTO task_dependencies (id, task_id, depends_on_id, company_id) VALUES ($1, $2, $3, $4)`,
 		d.ID, d.TaskID, d.DependsOnID, d.CompanyID,
 	)
@@ -62,7 +108,7 @@ func (r *taskCollabRepo) AddDependency(ctx context.Context, d *domain.TaskDepend
 
 func (r *taskCollabRepo) ListDependencies(ctx context.Context, taskID string) ([]*domain.TaskDependency, error) {
 	var deps []*domain.TaskDependency
-	if err := r.db.WithContext(ctx).Raw(
+	if err := conn(ctx, r.db).Raw(
 		`SELECT * FROM task_dependencies WHERE task_id = $1 ORDER BY created_at ASC`, taskID,
 	).Scan(&deps).Error; err != nil {
 		return nil, fmt.Errorf("task dependency list: %w", err)
@@ -71,7 +117,7 @@ func (r *taskCollabRepo) ListDependencies(ctx context.Context, taskID string) ([
 }
 
 func (r *taskCollabRepo) DeleteDependency(ctx context.Context, taskID, dependsOnID string) error {
-	res := r.db.WithContext(ctx).Exec(
+	res := conn(ctx, r.db).Exec(
 		`DELETE FROM task_dependencies WHERE task_id = $1 AND depends_on_id = $2`, taskID, dependsOnID,
 	)
 	if res.Error != nil {
@@ -80,8 +126,59 @@ func (r *taskCollabRepo) DeleteDependency(ctx context.Context, taskID, dependsOn
 	return nil
 }
 
+func (r *taskCollabRepo) DependencyReachable(ctx context.Context, fromID, toID string) (bool, error) {
+	var found bool
+	if err := conn(ctx, r.db).Raw(
+		`WITH RECURSIVE reach(id) AS (
+			SELECT depends_on_id FROM task_dependencies WHERE task_id = $1
+			UNION
+			SELECT d.depends_on_id FROM task_dependencies d JOIN reach ON d.task_id = reach.id
+		)
+		SELECT EXISTS (SELECT 1 FROM reach WHERE id = $2)`, fromID, toID,
+	).Scan(&found).Error; err != nil {
+		return false, fmt.Errorf("task dependency reachable: %w", err)
+	}
+	return found, nil
+}
+
+func (r *taskCollabRepo) ListOpenBlockers(ctx context.Context, taskID string) ([]*domain.Task, error) {
+	var tasks []*domain.Task
+	if err := conn(ctx, r.db).Raw(
+		`SELECT t.* FROM task_dependencies d JOIN tasks t ON t.id = d.depends_on_id
+		WHERE d.task_id = $1 AND t.status NOT IN ('done','cancelled')
+		ORDER BY d.created_at`, taskID,
+	).Scan(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("task open blockers: %w", err)
+	}
+	return tasks, nil
+}
+
+func (r *taskCollabRepo) ListDependents(ctx context.Context, taskID string) ([]*domain.Task, error) {
+	var tasks []*domain.Task
+	if err := conn(ctx, r.db).Raw(
+		`SELECT t.* FROM task_dependencies d JOIN tasks t ON t.id = d.task_id
+		WHERE d.depends_on_id = $1 ORDER BY d.created_at`, taskID,
+	).Scan(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("task dependents: %w", err)
+	}
+	return tasks, nil
+}
+
+func (r *taskCollabRepo) ListDependenciesAmong(ctx context.Context, taskIDs []string) ([]*domain.TaskDependency, error) {
+	if len(taskIDs) == 0 {
+		return nil, nil
+	}
+	var deps []*domain.TaskDependency
+	if err := conn(ctx, r.db).Raw(
+		`SELECT * FROM task_dependencies WHERE task_id IN ? ORDER BY created_at`, taskIDs,
+	).Scan(&deps).Error; err != nil {
+		return nil, fmt.Errorf("task dependency list among: %w", err)
+	}
+	return deps, nil
+}
+
 func (r *taskCollabRepo) AddWatcher(ctx context.Context, w *domain.TaskWatcher) error {
-	res := r.db.WithContext(ctx).Exec(
+	res := conn(ctx, r.db).Exec(
 		`INSERT INTO task_watchers (task_id, agent_id) VALUES ($1, $2)
 		 ON CONFLICT (task_id, agent_id) DO NOTHING`,
 		w.TaskID, w.AgentID,
@@ -94,7 +191,7 @@ func (r *taskCollabRepo) AddWatcher(ctx context.Context, w *domain.TaskWatcher)
 
 func (r *taskCollabRepo) ListWatchers(ctx context.Context, taskID string) ([]*domain.TaskWatcher, error) {
 	var watchers []*domain.TaskWatcher
-	if err := r.db.WithContext(ctx).Raw(
+	if err := conn(ctx, r.db).Raw(
 		`SELECT * FROM task_watchers WHERE task_id = $1 ORDER BY created_at ASC`, taskID,
 	).Scan(&watchers).Error; err != nil {
 		return nil, fmt.Errorf("task watcher list: %w", err)
@@ -103,7 +200,7 @@ func (r *taskCollabRepo) ListWatchers(ctx context.Context, taskID string) ([]*do
 }
 
 func (r *taskCollabRepo) RemoveWatcher(ctx context.Context, taskID, agentID string) error {
-	res := r.db.WithContext(ctx).Exec(
+	res := conn(ctx, r.db).Exec(
 		`DELETE FROM task_watchers WHERE task_id = $1 AND agent_id = $2`, taskID, agentID,
 	)
 	if res.Error != nil {
@@ -111,3 +208,59 @@ func (r *taskCollabRepo) RemoveWatcher(ctx context.Context, taskID, agentID stri
 	}
 	return nil
 }
+
+func (r *taskCollabRepo) AddReview(ctx context.Context, rv *domain.TaskReview) error {
+	if err := conn(ctx, r.db).Exec(
+		`INSERT INTO task_reviews (id, task_id, company_id, reviewer_id, assignee_id, outcome, comment)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		rv.ID, rv.TaskID, rv.CompanyID, rv.ReviewerID, rv.AssigneeID, string(rv.Outcome), rv.Comment,
+	).Error; err != nil {
+		return fmt.Errorf("task review add: %w", err)
+	}
+	return nil
+}
+
+func (r *taskCollabRepo) ListReviews(ctx context.Context, taskID string) ([]*domain.TaskReview, error) {
+	var reviews []*domain.TaskReview
+	if err := conn(ctx, r.db).Raw(
+		`SELECT * FROM task_reviews WHERE task_id = $1 ORDER BY created_at ASC`, taskID,
+	).Scan(&reviews).Error; err != nil {
+		return nil, fmt.Errorf("task review list: %w", err)
+	}
+	return reviews, nil
+}
+
+func (r *taskCollabRepo) ReviewStats(ctx context.Context, companyID, assigneeID string) (*domain.TaskReviewStats, error) {
+	var stats domain.TaskReviewStats
+	if err := conn(ctx, r.db).Raw(
+		`SELECT COUNT(*) FILTER (WHERE outcome = 'approved') AS approved,
+		        COUNT(*) FILTER (WHERE outcome = 'changes_requested') AS changes_requested
+		FROM task_reviews WHERE company_id = $1 AND assignee_id = $2`, companyID, assigneeID,
+	).Scan(&stats).Error; err != nil {
+		return nil, fmt.Errorf("task review stats: %w", err)
+	}
+	return &stats, nil
+}
+
+func (r *taskCollabRepo) AddEvents(ctx context.Context, events []*domain.TaskEvent) error {
+	for _, e := range events {
+		if err := conn(ctx, r.db).Exec(
+			`INSERT INTO task_events (id, task_id, company_id, actor_id, field, old_value, new_value)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			e.ID, e.TaskID, e.CompanyID, e.ActorID, e.Field, e.OldValue, e.NewValue,
+		).Error; err != nil {
+			return fmt.Errorf("task event add: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *taskCollabRepo) ListEvents(ctx context.Context, taskID string) ([]*domain.TaskEvent, error) {
+	var events []*domain.TaskEvent
+	if err := conn(ctx, r.db).Raw(
+		`SELECT * FROM task_events WHERE task_id = $1 ORDER BY created_at ASC`, taskID,
+	).Scan(&events).Error; err != nil {
+		return nil, fmt.Errorf("task event list: %w", err)
+	}
+	return events, nil
+}
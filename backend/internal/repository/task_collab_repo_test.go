@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+func TestTaskCommentDeleteBindsAllArgs(t *testing.T) {
+	db, pool := newRecordingDB(t)
+	_ = NewTaskCollabRepo(db).DeleteComment(context.Background(), "c", "a", "co")
+	assertBound(t, pool)
+}
+
+func TestTaskCommentDeleteKeepsRepliesOnPostgres(t *testing.T) {
+	repo := NewTaskCollabRepo(openDevDB(t))
+	ctx := context.Background()
+	taskID, companyID, author := uuid.New().String(), uuid.New().String(), uuid.New().String()
+	comment := func(parent *string) *domain.TaskComment {
+		c := &domain.TaskComment{
+			ID: uuid.New().String(), TaskID: taskID, CompanyID: companyID, AgentID: author,
+			ParentCommentID: parent, Content: "x", Mentions: domain.StringList{},
+		}
+		if err := repo.AddComment(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	root := comment(nil)
+	middle := comment(&root.ID)
+	reply := comment(&middle.ID)
+	defer func() {
+		for _, c := range []*domain.TaskComment{root, reply} {
+			_ = repo.DeleteComment(ctx, c.ID, author, companyID)
+		}
+	}()
+
+	if err := repo.DeleteComment(ctx, middle.ID, author, companyID); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetComment(ctx, reply.ID)
+	if err != nil || got == nil {
+		t.Fatalf("reply lost after parent deleted: %v", err)
+	}
+	if got.ParentCommentID == nil || *got.ParentCommentID != root.ID {
+		t.Errorf("reply parent = %v, want %s", got.ParentCommentID, root.ID)
+	}
+}
@@ -2,14 +2,24 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
 
 	"github.com/linkclaw/backend/internal/domain"
 )
 
+// taskBlockedColumn 派生列 blocked：未结束的任务存在未完成（非 done/cancelled）的前置依赖
+const taskBlockedColumn = `(tasks.status NOT IN ('done','failed','cancelled') AND EXISTS (
+	SELECT 1 FROM task_dependencies d JOIN tasks b ON b.id = d.depends_on_id
+	WHERE d.task_id = tasks.id AND b.status NOT IN ('done','cancelled'))) AS blocked`
+
+// ErrTaskConflict 任务已被并发修改（状态或版本与预期不符）
+var ErrTaskConflict = errors.New("task was modified concurrently, reload and retry")
+
 type taskRepo struct {
 	db *gorm.DB
 }
@@ -20,12 +30,12 @@ func NewTaskRepo(db *gorm.DB) TaskRepo {
 
 func (r *taskRepo) Create(ctx context.Context, t *domain.Task) error {
 	q := `INSERT INTO tasks
-		(id, company_id, parent_id, title, description, priority, status, assignee_id, created_by, due_at, tags)
+		(id, company_id, parent_id, title, description, priority, status, assignee_id, created_by, due_at, tags, review_policy)
 		VALUES
-		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
-	result := r.db.WithContext(ctx).Exec(q,
+		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+	result := conn(ctx, r.db).Exec(q,
 		t.ID, t.CompanyID, t.ParentID, t.Title, t.Description,
-		string(t.Priority), string(t.Status), t.AssigneeID, t.CreatedBy, t.DueAt, t.Tags)
+		string(t.Priority), string(t.Status), t.AssigneeID, t.CreatedBy, t.DueAt, t.Tags, string(t.ReviewPolicy))
 	if result.Error != nil {
 		return fmt.Errorf("task create: %w", result.Error)
 	}
@@ -38,12 +48,12 @@ func (r *taskRepo) CreateAttachments(ctx context.Context, attachments []*domain.
 	}
 
 	q := `INSERT INTO task_attachments
-		(id, task_id, company_id, filename, original_filename, file_size, mime_type, storage_path, uploaded_by, created_at)
+		(id, task_id, company_id, filename, original_filename, file_size, mime_type, storage_path, sha256, uploaded_by, created_at)
 		VALUES
-		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
 
 	for _, a := range attachments {
-		if err := r.db.WithContext(ctx).Exec(
+		if err := conn(ctx, r.db).Exec(
 			q,
 			a.ID,
 			a.TaskID,
@@ -53,6 +63,7 @@ func (r *taskRepo) CreateAttachments(ctx context.Context, attachments []*domain.
 			a.FileSize,
 			a.MimeType,
 			a.StoragePath,
+			a.SHA256,
 			a.UploadedBy,
 			a.CreatedAt,
 		).Error; err != nil {
@@ -63,9 +74,28 @@ func (r *taskRepo) CreateAttachments(ctx context.Context, attachments []*domain.
 	return nil
 }
 
+func (r *taskRepo) GetAttachment(ctx context.Context, id string) (*domain.TaskAttachment, error) {
+	var a domain.TaskAttachment
+	result := conn(ctx, r.db).Raw(`SELECT * FROM task_attachments WHERE id = $1`, id).Scan(&a)
+	if result.Error != nil {
+		return nil, fmt.Errorf("task attachment get: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &a, nil
+}
+
+func (r *taskRepo) DeleteAttachment(ctx context.Context, id string) error {
+	if err := conn(ctx, r.db).Exec(`DELETE FROM task_attachments WHERE id = $1`, id).Error; err != nil {
+		return fmt.Errorf("task attachment delete: %w", err)
+	}
+	return nil
+}
+
 func (r *taskRepo) GetByID(ctx context.Context, id string) (*domain.Task, error) {
 	var t domain.Task
-	result := r.db.WithContext(ctx).Raw(`SELECT * FROM tasks WHERE id = $1`, id).Scan(&t)
+	result := conn(ctx, r.db).Raw(`SELECT tasks.*, `+taskBlockedColumn+` FROM tasks WHERE id = $1`, id).Scan(&t)
 	if result.Error != nil {
 		return nil, fmt.Errorf("task get: %w", result.Error)
 	}
@@ -73,13 +103,13 @@ func (r *taskRepo) GetByID(ctx context.Context, id string) (*domain.Task, error)
 		return nil, nil
 	}
 	var subtasks []*domain.Task
-	r.db.WithContext(ctx).Raw(
-		`SELECT * FROM tasks WHERE parent_id = $1 ORDER BY created_at`, id,
+	conn(ctx, r.db).Raw(
+		`SELECT tasks.*, `+taskBlockedColumn+` FROM tasks WHERE parent_id = $1 ORDER BY created_at`, id,
 	).Scan(&subtasks)
 	t.Subtasks = subtasks
 
 	var attachments []*domain.TaskAttachment
-	if err := r.db.WithContext(ctx).Raw(
+	if err := conn(ctx, r.db).Raw(
 		`SELECT * FROM task_attachments WHERE task_id = $1 ORDER BY created_at`, id,
 	).Scan(&attachments).Error; err != nil {
 		return nil, fmt.Errorf("task attachments get: %w", err)
@@ -90,33 +120,10 @@ func (r *taskRepo) GetByID(ctx context.Context, id string) (*domain.Task, error)
 }
 
 func (r *taskRepo) List(ctx context.Context, q TaskQuery) ([]*domain.Task, int, error) {
-	where := []string{"company_id = $1"}
-	args := []interface{}{q.CompanyID}
-	idx := 2
-
-	if q.AssigneeID != "" {
-		where = append(where, fmt.Sprintf("assignee_id = $%d", idx))
-		args = append(args, q.AssigneeID)
-		idx++
-	}
-	if q.Status != "" {
-		where = append(where, fmt.Sprintf("status = $%d", idx))
-		args = append(args, string(q.Status))
-		idx++
-	}
-	if q.Priority != "" {
-		where = append(where, fmt.Sprintf("priority = $%d", idx))
-		args = append(args, string(q.Priority))
-		idx++
-	}
-	if q.ParentID == nil {
-		where = append(where, "parent_id IS NULL")
-	}
-
-	whereClause := strings.Join(where, " AND ")
+	f := buildTaskFilter(q)
 	var total int64
-	if err := r.db.WithContext(ctx).Raw(
-		fmt.Sprintf("SELECT COUNT(*) FROM tasks WHERE %s", whereClause), args...,
+	if err := conn(ctx, r.db).Raw(
+		fmt.Sprintf("SELECT COUNT(*) FROM tasks WHERE %s", f.clause()), f.args...,
 	).Scan(&total).Error; err != nil {
 		return nil, 0, err
 	}
@@ -125,40 +132,272 @@ func (r *taskRepo) List(ctx context.Context, q TaskQuery) ([]*domain.Task, int,
 	if limit <= 0 {
 		limit = 50
 	}
-	listArgs := append(args, limit, q.Offset)
 	listQ := fmt.Sprintf(
-		"SELECT * FROM tasks WHERE %s ORDER BY created_at DESC LIMIT $%d OFFSET $%d",
-		whereClause, idx, idx+1,
+		"SELECT tasks.*, %s FROM tasks WHERE %s ORDER BY created_at DESC LIMIT %s OFFSET %s",
+		taskBlockedColumn, f.clause(), f.arg(limit), f.arg(q.Offset),
 	)
 
 	var tasks []*domain.Task
-	if err := r.db.WithContext(ctx).Raw(listQ, listArgs...).Scan(&tasks).Error; err != nil {
+	if err := conn(ctx, r.db).Raw(listQ, f.args...).Scan(&tasks).Error; err != nil {
 		return nil, 0, fmt.Errorf("task list: %w", err)
 	}
 	return tasks, int(total), nil
 }
 
-func (r *taskRepo) UpdateStatus(ctx context.Context, id string, status domain.TaskStatus, result, failReason *string) error {
-	res := r.db.WithContext(ctx).Exec(
-		`UPDATE tasks SET status = $1, result = $2, fail_reason = $3, updated_at = NOW() WHERE id = $4`,
-		status, result, failReason, id)
-	return res.Error
+func (r *taskRepo) TransitionStatus(ctx context.Context, id string, from domain.TaskStatus, version int, to domain.TaskStatus, result, failReason, reviewerID *string) error {
+	res := conn(ctx, r.db).Exec(
+		`UPDATE tasks SET status = $1, result = $2, fail_reason = $3, reviewer_id = COALESCE($4, reviewer_id),
+			version = version + 1, status_changed_at = NOW(), sla_breached_at = NULL, updated_at = NOW()
+		WHERE id = $5 AND status = $6 AND version = $7`,
+		to, result, failReason, reviewerID, id, from, version)
+	if res.Error != nil {
+		return fmt.Errorf("task transition: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrTaskConflict
+	}
+	return nil
 }
 
-func (r *taskRepo) UpdateAssignee(ctx context.Context, id, assigneeID string, status domain.TaskStatus) error {
-	res := r.db.WithContext(ctx).Exec(
-		`UPDATE tasks SET assignee_id = $1, status = $2, updated_at = NOW() WHERE id = $3`,
-		assigneeID, status, id)
-	return res.Error
+func (r *taskRepo) UpdateAssignee(ctx context.Context, id string, version int, assigneeID *string, status domain.TaskStatus) error {
+	res := conn(ctx, r.db).Exec(
+		`UPDATE tasks SET assignee_id = $1, status = $2, version = version + 1,
+			status_changed_at = NOW(), sla_breached_at = NULL, updated_at = NOW()
+		WHERE id = $3 AND version = $4`,
+		assigneeID, status, id, version)
+	if res.Error != nil {
+		return fmt.Errorf("task update assignee: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrTaskConflict
+	}
+	return nil
+}
+
+func (r *taskRepo) Update(ctx context.Context, t *domain.Task, version int) error {
+	res := conn(ctx, r.db).Exec(
+		`UPDATE tasks SET title = $1, description = $2, priority = $3, parent_id = $4, tags = $5,
+			version = version + 1, updated_at = NOW()
+		WHERE id = $6 AND version = $7`,
+		t.Title, t.Description, string(t.Priority), t.ParentID, t.Tags, t.ID, version)
+	if res.Error != nil {
+		return fmt.Errorf("task update: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrTaskConflict
+	}
+	return nil
+}
+
+// UpdateDueAt 修改截止时间并清除提醒/逾期标记
+func (r *taskRepo) UpdateDueAt(ctx context.Context, id string, version int, dueAt *time.Time) error {
+	res := conn(ctx, r.db).Exec(
+		`UPDATE tasks SET due_at = $1, due_warned_at = NULL, overdue_at = NULL, version = version + 1, updated_at = NOW()
+		WHERE id = $2 AND version = $3`, dueAt, id, version,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("task update due_at: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrTaskConflict
+	}
+	return nil
+}
+
+// ListDueSoon 即将到期（until 之前）且尚未提醒的进行中任务
+func (r *taskRepo) ListDueSoon(ctx context.Context, until time.Time) ([]*domain.Task, error) {
+	var tasks []*domain.Task
+	if err := conn(ctx, r.db).Raw(
+		`SELECT * FROM tasks
+		WHERE due_at IS NOT NULL AND due_at > NOW() AND due_at <= $1 AND due_warned_at IS NULL
+		  AND assignee_id IS NOT NULL AND status IN ('assigned','in_progress','in_review')`, until,
+	).Scan(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("task list due soon: %w", err)
+	}
+	return tasks, nil
+}
+
+// ListNewlyOverdue 已过截止时间但尚未标记逾期的未完成任务
+func (r *taskRepo) ListNewlyOverdue(ctx context.Context) ([]*domain.Task, error) {
+	var tasks []*domain.Task
+	if err := conn(ctx, r.db).Raw(
+		`SELECT * FROM tasks
+		WHERE due_at IS NOT NULL AND due_at <= NOW() AND overdue_at IS NULL
+		  AND status NOT IN ('done','failed','cancelled')`,
+	).Scan(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("task list overdue: %w", err)
+	}
+	return tasks, nil
+}
+
+// ListSLACandidates 在 assigned/in_progress 停留超过 before 且尚未判定超时的任务（按优先级的判定由调用方完成）
+func (r *taskRepo) ListSLACandidates(ctx context.Context, before time.Time) ([]*domain.Task, error) {
+	var tasks []*domain.Task
+	if err := conn(ctx, r.db).Raw(
+		`SELECT * FROM tasks
+		WHERE status IN ('assigned','in_progress') AND sla_breached_at IS NULL AND status_changed_at <= $1`, before,
+	).Scan(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("task list sla candidates: %w", err)
+	}
+	return tasks, nil
+}
+
+func (r *taskRepo) MarkDueWarned(ctx context.Context, id string) (bool, error) {
+	res := conn(ctx, r.db).Exec(`UPDATE tasks SET due_warned_at = NOW() WHERE id = $1 AND due_warned_at IS NULL`, id)
+	if res.Error != nil {
+		return false, fmt.Errorf("task mark due warned: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// MarkOverdue 标记逾期并记录升级层级；已被其他实例标记时返回 false
+func (r *taskRepo) MarkOverdue(ctx context.Context, id string, escalationLevel int) (bool, error) {
+	res := conn(ctx, r.db).Exec(
+		`UPDATE tasks SET overdue_at = NOW(), escalation_level = GREATEST(escalation_level, $1), escalated_at = NOW()
+		WHERE id = $2 AND overdue_at IS NULL`, escalationLevel, id)
+	if res.Error != nil {
+		return false, fmt.Errorf("task mark overdue: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// MarkSLABreached 标记当前状态 SLA 超时并记录升级层级；已被其他实例标记时返回 false
+func (r *taskRepo) MarkSLABreached(ctx context.Context, id string, escalationLevel int) (bool, error) {
+	res := conn(ctx, r.db).Exec(
+		`UPDATE tasks SET sla_breached_at = NOW(), escalation_level = GREATEST(escalation_level, $1), escalated_at = NOW()
+		WHERE id = $2 AND sla_breached_at IS NULL`, escalationLevel, id)
+	if res.Error != nil {
+		return false, fmt.Errorf("task mark sla breached: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *taskRepo) ListEscalationDue(ctx context.Context, before time.Time) ([]*domain.Task, error) {
+	var tasks []*domain.Task
+	if err := conn(ctx, r.db).Raw(
+		`SELECT * FROM tasks
+		WHERE escalated_at IS NOT NULL AND escalated_at <= $1
+		  AND ((overdue_at IS NOT NULL AND status NOT IN ('done','failed','cancelled'))
+		    OR (sla_breached_at IS NOT NULL AND status IN ('assigned','in_progress')))`, before,
+	).Scan(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("task list escalation due: %w", err)
+	}
+	return tasks, nil
+}
+
+func (r *taskRepo) MarkEscalated(ctx context.Context, id string, fromLevel, toLevel int) (bool, error) {
+	res := conn(ctx, r.db).Exec(
+		`UPDATE tasks SET escalation_level = $1, escalated_at = NOW() WHERE id = $2 AND escalation_level = $3`,
+		toLevel, id, fromLevel)
+	if res.Error != nil {
+		return false, fmt.Errorf("task mark escalated: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *taskRepo) ClearEscalation(ctx context.Context, id string) error {
+	if err := conn(ctx, r.db).Exec(`UPDATE tasks SET escalated_at = NULL WHERE id = $1`, id).Error; err != nil {
+		return fmt.Errorf("task clear escalation: %w", err)
+	}
+	return nil
+}
+
+// CountOpenByAssignee 各负责人手上未完成（assigned / in_progress）的任务数
+func (r *taskRepo) CountOpenByAssignee(ctx context.Context, companyID string) (map[string]int, error) {
+	var rows []struct {
+		AssigneeID string `gorm:"column:assignee_id"`
+		Count      int    `gorm:"column:count"`
+	}
+	if err := conn(ctx, r.db).Raw(
+		`SELECT assignee_id, COUNT(*) AS count FROM tasks
+		WHERE company_id = $1 AND assignee_id IS NOT NULL AND status IN ('assigned','in_progress')
+		GROUP BY assignee_id`, companyID,
+	).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("task count open by assignee: %w", err)
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.AssigneeID] = row.Count
+	}
+	return counts, nil
+}
+
+// ListFinishedTagged 最近已完成/失败且带标签的任务（用于按标签估计历史成功率）
+func (r *taskRepo) ListFinishedTagged(ctx context.Context, companyID string, limit int) ([]*domain.Task, error) {
+	var tasks []*domain.Task
+	if err := conn(ctx, r.db).Raw(
+		`SELECT * FROM tasks
+		WHERE company_id = $1 AND assignee_id IS NOT NULL AND status IN ('done','failed')
+		  AND tags IS NOT NULL AND tags NOT IN ('', '[]')
+		ORDER BY updated_at DESC LIMIT $2`, companyID, limit,
+	).Scan(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("task list finished tagged: %w", err)
+	}
+	return tasks, nil
 }
 
 func (r *taskRepo) UpdateTags(ctx context.Context, id string, tags domain.StringList) error {
-	res := r.db.WithContext(ctx).Exec(
-		`UPDATE tasks SET tags = $1, updated_at = NOW() WHERE id = $2`, tags, id)
+	res := conn(ctx, r.db).Exec(
+		`UPDATE tasks SET tags = $1, version = version + 1, updated_at = NOW() WHERE id = $2`, tags, id)
 	return res.Error
 }
 
-func (r *taskRepo) Delete(ctx context.Context, id, companyID string) error {
-	res := r.db.WithContext(ctx).Exec(`DELETE FROM tasks WHERE id = $1 AND company_id = $2`, id, companyID)
-	return res.Error
+func (r *taskRepo) Delete(ctx context.Context, companyID string, ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	placeholders := make([]string, len(ids))
+	args := make([]any, 0, len(ids)+1)
+	args = append(args, companyID)
+	for i, id := range ids {
+		placeholders[i] = fmt.Sprintf("$%d", i+2)
+		args = append(args, id)
+	}
+	var deleted []string
+	if err := conn(ctx, r.db).Raw(
+		`DELETE FROM tasks WHERE company_id = $1 AND id IN (`+strings.Join(placeholders, ",")+`) RETURNING id`, args...,
+	).Scan(&deleted).Error; err != nil {
+		return nil, fmt.Errorf("task delete: %w", err)
+	}
+	if len(deleted) == 0 {
+		return nil, nil
+	}
+
+	placeholders = placeholders[:len(deleted)]
+	args = make([]any, len(deleted))
+	for i, id := range deleted {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+	var paths []string
+	if err := conn(ctx, r.db).Raw(
+		`DELETE FROM task_attachments WHERE task_id IN (`+strings.Join(placeholders, ",")+`) RETURNING storage_path`, args...,
+	).Scan(&paths).Error; err != nil {
+		return nil, fmt.Errorf("task attachments delete: %w", err)
+	}
+	return paths, nil
+}
+
+func (r *taskRepo) ListDescendants(ctx context.Context, rootID string) ([]*domain.Task, error) {
+	var tasks []*domain.Task
+	if err := conn(ctx, r.db).Raw(
+		`WITH RECURSIVE sub(id) AS (
+			SELECT id FROM tasks WHERE parent_id = $1
+			UNION
+			SELECT t.id FROM tasks t JOIN sub ON t.parent_id = sub.id
+		)
+		SELECT tasks.*, `+taskBlockedColumn+` FROM tasks WHERE id IN (SELECT id FROM sub) ORDER BY created_at`, rootID,
+	).Scan(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("task list descendants: %w", err)
+	}
+	return tasks, nil
+}
+
+func (r *taskRepo) LockHierarchy(ctx context.Context, companyID string) error {
+	if err := conn(ctx, r.db).Exec(
+		`SELECT pg_advisory_xact_lock(hashtext('task-tree:' || $1))`, companyID,
+	).Error; err != nil {
+		return fmt.Errorf("task lock hierarchy: %w", err)
+	}
+	return nil
 }
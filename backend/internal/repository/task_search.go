@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+// ErrInvalidTaskCursor 游标无法解析或与当前排序方式不匹配
+var ErrInvalidTaskCursor = errors.New("invalid task cursor")
+
+const taskPriorityRank = `(CASE tasks.priority WHEN 'urgent' THEN 4 WHEN 'high' THEN 3 WHEN 'medium' THEN 2 ELSE 1 END)`
+
+// taskTagsJSON tags 列为 JSON 文本，空串视为空数组
+const taskTagsJSON = `COALESCE(NULLIF(tasks.tags, ''), '[]')::jsonb`
+
+// taskCursor 按字段排序时记录最后一行的排序值与 ID（keyset），按相关度排序时记录偏移量
+type taskCursor struct {
+	Sort   TaskSort `json:"s"`
+	Value  string   `json:"v,omitempty"`
+	ID     string   `json:"id,omitempty"`
+	Offset int      `json:"o,omitempty"`
+}
+
+func encodeTaskCursor(c taskCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeTaskCursor(s string) (taskCursor, error) {
+	var c taskCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(b, &c) != nil {
+		return c, ErrInvalidTaskCursor
+	}
+	return c, nil
+}
+
+// taskFilter 按 TaskQuery 拼接的 WHERE 条件及其位置参数。
+// 与 messageFilter 相同，SQL 中不能出现 @ 或 ?（gorm 分别视为命名参数与绑定占位符），
+// 相应运算符一律写成 ts_match_vq / jsonb_contains / jsonb_exists_any 函数形式
+type taskFilter struct {
+	where []string
+	args  []interface{}
+}
+
+// arg 追加一个参数并返回其占位符
+func (f *taskFilter) arg(v interface{}) string {
+	f.args = append(f.args, v)
+	return fmt.Sprintf("$%d", len(f.args))
+}
+
+func (f *taskFilter) clause() string {
+	return strings.Join(f.where, " AND ")
+}
+
+func buildTaskFilter(q TaskQuery) *taskFilter {
+	f := &taskFilter{}
+	f.where = append(f.where, "tasks.company_id = "+f.arg(q.CompanyID))
+
+	if q.AssigneeID != "" {
+		f.where = append(f.where, "tasks.assignee_id = "+f.arg(q.AssigneeID))
+	}
+	if q.Status != "" {
+		f.where = append(f.where, "tasks.status = "+f.arg(string(q.Status)))
+	}
+	if q.Priority != "" {
+		f.where = append(f.where, "tasks.priority = "+f.arg(string(q.Priority)))
+	}
+	if q.ReviewerID != "" {
+		f.where = append(f.where, "tasks.reviewer_id = "+f.arg(q.ReviewerID))
+	}
+	if q.Unassigned {
+		f.where = append(f.where, "tasks.assignee_id IS NULL")
+	}
+	if q.ParentID == nil {
+		f.where = append(f.where, "tasks.parent_id IS NULL")
+	}
+	if q.Text != "" {
+		p := f.arg(q.Text)
+		f.where = append(f.where, fmt.Sprintf(`(ts_match_vq(tasks.search_vec, plainto_tsquery('simple', %s)) OR EXISTS (
+			SELECT 1 FROM task_comments c WHERE c.task_id = tasks.id AND ts_match_vq(c.search_vec, plainto_tsquery('simple', %s))))`, p, p))
+	}
+	if len(q.Tags) > 0 {
+		if q.AllTags {
+			all, _ := json.Marshal(q.Tags)
+			f.where = append(f.where, "jsonb_contains("+taskTagsJSON+", "+f.arg(string(all))+"::jsonb)")
+		} else {
+			f.where = append(f.where, "jsonb_exists_any("+taskTagsJSON+", "+f.arg(q.Tags)+"::text[])")
+		}
+	}
+	if q.CreatedBy != "" {
+		f.where = append(f.where, "tasks.created_by = "+f.arg(q.CreatedBy))
+	}
+	if q.WatcherID != "" {
+		f.where = append(f.where, "EXISTS (SELECT 1 FROM task_watchers w WHERE w.task_id = tasks.id AND w.agent_id = "+f.arg(q.WatcherID)+")")
+	}
+	if q.DepartmentID != "" {
+		f.where = append(f.where, "tasks.assignee_id IN (SELECT a.id FROM agents a WHERE a.department_id = "+f.arg(q.DepartmentID)+")")
+	}
+	if q.CreatedAfter != nil {
+		f.where = append(f.where, "tasks.created_at >= "+f.arg(*q.CreatedAfter))
+	}
+	if q.CreatedBefore != nil {
+		f.where = append(f.where, "tasks.created_at < "+f.arg(*q.CreatedBefore))
+	}
+	if q.UpdatedAfter != nil {
+		f.where = append(f.where, "tasks.updated_at >= "+f.arg(*q.UpdatedAfter))
+	}
+	if q.UpdatedBefore != nil {
+		f.where = append(f.where, "tasks.updated_at < "+f.arg(*q.UpdatedBefore))
+	}
+	if q.DueBefore != nil {
+		f.where = append(f.where, "tasks.due_at IS NOT NULL AND tasks.due_at < "+f.arg(*q.DueBefore))
+	}
+	return f
+}
+
+// taskSortKey 返回排序表达式、游标值的 SQL 类型以及从任务中取排序值的函数
+func taskSortKey(sort TaskSort) (expr, typ string, value func(t *domain.Task) string) {
+	switch sort {
+	case TaskSortUpdated:
+		return "tasks.updated_at", "timestamptz", func(t *domain.Task) string { return t.UpdatedAt.Format(time.RFC3339Nano) }
+	case TaskSortDue:
+		return "COALESCE(tasks.due_at, 'infinity'::timestamptz)", "timestamptz", func(t *domain.Task) string {
+			if t.DueAt == nil {
+				return "infinity"
+			}
+			return t.DueAt.Format(time.RFC3339Nano)
+		}
+	case TaskSortPriority:
+		return taskPriorityRank, "int", func(t *domain.Task) string { return strconv.Itoa(priorityRank(t.Priority)) }
+	default:
+		return "tasks.created_at", "timestamptz", func(t *domain.Task) string { return t.CreatedAt.Format(time.RFC3339Nano) }
+	}
+}
+
+func priorityRank(p domain.TaskPriority) int {
+	switch p {
+	case domain.TaskPriorityUrgent:
+		return 4
+	case domain.TaskPriorityHigh:
+		return 3
+	case domain.TaskPriorityMedium:
+		return 2
+	default:
+		return 1
+	}
+}
+
+func (r *taskRepo) Search(ctx context.Context, q TaskQuery) (*TaskPage, error) {
+	f := buildTaskFilter(q)
+	var total int64
+	if err := conn(ctx, r.db).Raw(
+		fmt.Sprintf("SELECT COUNT(*) FROM tasks WHERE %s", f.clause()), f.args...,
+	).Scan(&total).Error; err != nil {
+		return nil, fmt.Errorf("task count: %w", err)
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	sort := q.Sort
+	switch {
+	case sort == "":
+		sort = TaskSortCreated
+	case sort == TaskSortRelevance && q.Text == "":
+		sort = TaskSortCreated
+	}
+	// due_at 默认升序（最早到期在前），其余默认降序
+	asc := sort == TaskSortDue
+	switch q.Order {
+	case "asc":
+		asc = true
+	case "desc":
+		asc = false
+	}
+	dir, cmp := "DESC", "<"
+	if asc {
+		dir, cmp = "ASC", ">"
+	}
+
+	var cursor *taskCursor
+	if q.Cursor != "" {
+		c, err := decodeTaskCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sort != sort {
+			return nil, ErrInvalidTaskCursor
+		}
+		cursor = &c
+	}
+
+	var (
+		orderBy string
+		value   func(t *domain.Task) string
+		offset  = q.Offset
+	)
+	if sort == TaskSortRelevance {
+		orderBy = fmt.Sprintf("ts_rank(tasks.search_vec, plainto_tsquery('simple', %s)) %s, tasks.id %s", f.arg(q.Text), dir, dir)
+		if cursor != nil {
+			offset = cursor.Offset
+		}
+	} else {
+		expr, typ, v := taskSortKey(sort)
+		value = v
+		if cursor != nil {
+			if cursor.ID == "" {
+				return nil, ErrInvalidTaskCursor
+			}
+			f.where = append(f.where, fmt.Sprintf("(%s, tasks.id) %s (%s::%s, %s)", expr, cmp, f.arg(cursor.Value), typ, f.arg(cursor.ID)))
+			offset = 0
+		}
+		orderBy = fmt.Sprintf("%s %s, tasks.id %s", expr, dir, dir)
+	}
+
+	listQ := fmt.Sprintf(
+		"SELECT tasks.*, %s FROM tasks WHERE %s ORDER BY %s LIMIT %s OFFSET %s",
+		taskBlockedColumn, f.clause(), orderBy, f.arg(limit+1), f.arg(offset),
+	)
+	var tasks []*domain.Task
+	if err := conn(ctx, r.db).Raw(listQ, f.args...).Scan(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("task search: %w", err)
+	}
+
+	page := &TaskPage{Tasks: tasks, Total: int(total)}
+	if len(tasks) > limit {
+		page.Tasks = tasks[:limit]
+		if value == nil {
+			page.NextCursor = encodeTaskCursor(taskCursor{Sort: sort, Offset: offset + limit})
+		} else {
+			last := page.Tasks[limit-1]
+			page.NextCursor = encodeTaskCursor(taskCursor{Sort: sort, Value: value(last), ID: last.ID})
+		}
+	}
+	return page, nil
+}
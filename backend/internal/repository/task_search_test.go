@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"testing"
+)
+
+func taskSearchQueries() map[string]TaskQuery {
+	all := ""
+	base := TaskQuery{CompanyID: "00000000-0000-0000-0000-000000000001", ParentID: &all}
+	text, anyTag, allTags, combined := base, base, base, base
+	text.Text = "数据库迁移"
+	anyTag.Tags = []string{"backend", "urgent"}
+	allTags.Tags = []string{"backend", "urgent"}
+	allTags.AllTags = true
+	combined.Text = "deploy"
+	combined.Tags = []string{"ops"}
+	combined.Sort = TaskSortRelevance
+	return map[string]TaskQuery{"text": text, "any tag": anyTag, "all tags": allTags, "text and tags": combined}
+}
+
+func TestTaskSearchBindsAllArgs(t *testing.T) {
+	for name, q := range taskSearchQueries() {
+		t.Run(name, func(t *testing.T) {
+			db, pool := newRecordingDB(t)
+			_, _ = NewTaskRepo(db).Search(context.Background(), q)
+			assertBound(t, pool)
+		})
+	}
+}
+
+func TestTaskSearchOnPostgres(t *testing.T) {
+	repo := NewTaskRepo(openDevDB(t))
+	for name, q := range taskSearchQueries() {
+		if _, err := repo.Search(context.Background(), q); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
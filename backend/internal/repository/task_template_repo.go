@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+type taskTemplateRepo struct {
+	db *gorm.DB
+}
+
+func NewTaskTemplateRepo(db *gorm.DB) TaskTemplateRepo {
+	return &taskTemplateRepo{db: db}
+}
+
+func (r *taskTemplateRepo) Create(ctx context.Context, t *domain.TaskTemplate) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	res := conn(ctx, r.db).Exec(
+		`INSERT INTO task_templates (id, company_id, name, description, params, spec, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		t.ID, t.CompanyID, t.Name, t.Description, t.Params, t.Spec, t.CreatedBy,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("task template create: %w", res.Error)
+	}
+	return nil
+}
+
+func (r *taskTemplateRepo) GetByID(ctx context.Context, id string) (*domain.TaskTemplate, error) {
+	var t domain.TaskTemplate
+	res := conn(ctx, r.db).Raw(`SELECT * FROM task_templates WHERE id = $1`, id).Scan(&t)
+	if res.Error != nil {
+		return nil, fmt.Errorf("task template get: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+func (r *taskTemplateRepo) GetByName(ctx context.Context, companyID, name string) (*domain.TaskTemplate, error) {
+	var t domain.TaskTemplate
+	res := conn(ctx, r.db).Raw(
+		`SELECT * FROM task_templates WHERE company_id = $1 AND name = $2`, companyID, name,
+	).Scan(&t)
+	if res.Error != nil {
+		return nil, fmt.Errorf("task template get by name: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+func (r *taskTemplateRepo) ListByCompany(ctx context.Context, companyID string) ([]*domain.TaskTemplate, error) {
+	var list []*domain.TaskTemplate
+	if err := conn(ctx, r.db).Raw(
+		`SELECT * FROM task_templates WHERE company_id = $1 ORDER BY name`, companyID,
+	).Scan(&list).Error; err != nil {
+		return nil, fmt.Errorf("task template list: %w", err)
+	}
+	return list, nil
+}
+
+func (r *taskTemplateRepo) Update(ctx context.Context, t *domain.TaskTemplate) error {
+	res := conn(ctx, r.db).Exec(
+		`UPDATE task_templates SET name = $1, description = $2, params = $3, spec = $4, updated_at = NOW()
+		WHERE id = $5`,
+		t.Name, t.Description, t.Params, t.Spec, t.ID,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("task template update: %w", res.Error)
+	}
+	return nil
+}
+
+func (r *taskTemplateRepo) Delete(ctx context.Context, id string) error {
+	if err := conn(ctx, r.db).Exec(`DELETE FROM task_templates WHERE id = $1`, id).Error; err != nil {
+		return fmt.Errorf("task template delete: %w", err)
+	}
+	return nil
+}
+
+func (r *taskTemplateRepo) CreateRecurrence(ctx context.Context, rec *domain.TaskRecurrence) error {
+	if rec.ID == "" {
+		rec.ID = uuid.New().String()
+	}
+	res := conn(ctx, r.db).Exec(
+		`INSERT INTO task_recurrences (id, company_id, template_id, cron, timezone, params, enabled, next_run_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		rec.ID, rec.CompanyID, rec.TemplateID, rec.Cron, rec.Timezone, rec.Params, rec.Enabled, rec.NextRunAt, rec.CreatedBy,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("task recurrence create: %w", res.Error)
+	}
+	return nil
+}
+
+func (r *taskTemplateRepo) GetRecurrence(ctx context.Context, id string) (*domain.TaskRecurrence, error) {
+	var rec domain.TaskRecurrence
+	res := conn(ctx, r.db).Raw(`SELECT * FROM task_recurrences WHERE id = $1`, id).Scan(&rec)
+	if res.Error != nil {
+		return nil, fmt.Errorf("task recurrence get: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &rec, nil
+}
+
+func (r *taskTemplateRepo) ListRecurrences(ctx context.Context, companyID, templateID string) ([]*domain.TaskRecurrence, error) {
+	where := `company_id = $1`
+	args := []any{companyID}
+	if templateID != "" {
+		where += ` AND template_id = $2`
+		args = append(args, templateID)
+	}
+	var list []*domain.TaskRecurrence
+	if err := conn(ctx, r.db).Raw(
+		`SELECT * FROM task_recurrences WHERE `+where+` ORDER BY created_at`, args...,
+	).Scan(&list).Error; err != nil {
+		return nil, fmt.Errorf("task recurrence list: %w", err)
+	}
+	return list, nil
+}
+
+func (r *taskTemplateRepo) UpdateRecurrence(ctx context.Context, rec *domain.TaskRecurrence) error {
+	res := conn(ctx, r.db).Exec(
+		`UPDATE task_recurrences SET cron = $1, timezone = $2, params = $3, enabled = $4, next_run_at = $5, updated_at = NOW()
+		WHERE id = $6`,
+		rec.Cron, rec.Timezone, rec.Params, rec.Enabled, rec.NextRunAt, rec.ID,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("task recurrence update: %w", res.Error)
+	}
+	return nil
+}
+
+func (r *taskTemplateRepo) DeleteRecurrence(ctx context.Context, id string) error {
+	if err := conn(ctx, r.db).Exec(`DELETE FROM task_recurrences WHERE id = $1`, id).Error; err != nil {
+		return fmt.Errorf("task recurrence delete: %w", err)
+	}
+	return nil
+}
+
+func (r *taskTemplateRepo) DeleteRecurrencesByTemplate(ctx context.Context, templateID string) error {
+	if err := conn(ctx, r.db).Exec(`DELETE FROM task_recurrences WHERE template_id = $1`, templateID).Error; err != nil {
+		return fmt.Errorf("task recurrence delete by template: %w", err)
+	}
+	return nil
+}
+
+func (r *taskTemplateRepo) ListDueRecurrences(ctx context.Context, now time.Time, limit int) ([]*domain.TaskRecurrence, error) {
+	var list []*domain.TaskRecurrence
+	if err := conn(ctx, r.db).Raw(
+		`SELECT * FROM task_recurrences WHERE enabled AND next_run_at <= $1 ORDER BY next_run_at LIMIT $2`, now, limit,
+	).Scan(&list).Error; err != nil {
+		return nil, fmt.Errorf("task recurrence list due: %w", err)
+	}
+	return list, nil
+}
+
+func (r *taskTemplateRepo) AdvanceRecurrence(ctx context.Context, id string, prev time.Time, next *time.Time) (bool, error) {
+	res := conn(ctx, r.db).Exec(
+		`UPDATE task_recurrences SET next_run_at = $1, last_run_at = NOW() WHERE id = $2 AND next_run_at = $3`,
+		next, id, prev,
+	)
+	if res.Error != nil {
+		return false, fmt.Errorf("task recurrence advance: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *taskTemplateRepo) SetRecurrenceResult(ctx context.Context, id string, taskID *string, lastError string) error {
+	if err := conn(ctx, r.db).Exec(
+		`UPDATE task_recurrences SET last_task_id = COALESCE($1, last_task_id), last_error = $2 WHERE id = $3`,
+		taskID, lastError, id,
+	).Error; err != nil {
+		return fmt.Errorf("task recurrence set result: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+type toolPolicyRepo struct {
+	db *gorm.DB
+}
+
+func NewToolPolicyRepo(db *gorm.DB) ToolPolicyRepo {
+	return &toolPolicyRepo{db: db}
+}
+
+func (r *toolPolicyRepo) Create(ctx context.Context, p *domain.ToolPolicy) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	res := conn(ctx, r.db).Exec(
+		`INSERT INTO tool_policies
+		(id, company_id, name, description, subject_type, subject_id, tool, effect, conditions, is_active, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		p.ID, p.CompanyID, p.Name, p.Description, p.SubjectType, p.SubjectID, p.Tool, p.Effect, p.Conditions, p.IsActive, p.CreatedBy,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("tool policy create: %w", res.Error)
+	}
+	return nil
+}
+
+func (r *toolPolicyRepo) GetByID(ctx context.Context, id string) (*domain.ToolPolicy, error) {
+	var p domain.ToolPolicy
+	res := conn(ctx, r.db).Raw(`SELECT * FROM tool_policies WHERE id = $1`, id).Scan(&p)
+	if res.Error != nil {
+		return nil, fmt.Errorf("tool policy get: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+func (r *toolPolicyRepo) ListByCompany(ctx context.Context, companyID string) ([]*domain.ToolPolicy, error) {
+	var list []*domain.ToolPolicy
+	if err := r.db.WithContext(ctx).Raw(
+		`SELECT * FROM tool_policies WHERE company_id = $1 ORDER BY subject_type, tool, created_at`, companyID,
+	).Scan(&list).Error; err != nil {
+		return nil, fmt.Errorf("tool policy list: %w", err)
+	}
+	return list, nil
+}
+
+func (r *toolPolicyRepo) Update(ctx context.Context, p *domain.ToolPolicy) error {
+	res := conn(ctx, r.db).Exec(
+		`UPDATE tool_policies SET
+		name = $1, description = $2, subject_type = $3, subject_id = $4, tool = $5,
+		effect = $6, conditions = $7, is_active = $8, updated_at = NOW()
+		WHERE id = $9`,
+		p.Name, p.Description, p.SubjectType, p.SubjectID, p.Tool, p.Effect, p.Conditions, p.IsActive, p.ID,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("tool policy update: %w", res.Error)
+	}
+	return nil
+}
+
+func (r *toolPolicyRepo) Delete(ctx context.Context, id string) error {
+	if err := conn(ctx, r.db).Exec(`DELETE FROM tool_policies WHERE id = $1`, id).Error; err != nil {
+		return fmt.Errorf("tool policy delete: %w", err)
+	}
+	return nil
+}
+
+func (r *toolPolicyRepo) CreateChange(ctx context.Context, c *domain.ToolPolicyChange) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	var before, after any
+	if len(c.Before) > 0 {
+		before = string(c.Before)
+	}
+	if len(c.After) > 0 {
+		after = string(c.After)
+	}
+	res := conn(ctx, r.db).Exec(
+		`INSERT INTO tool_policy_changes (id, company_id, policy_id, actor_id, action, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		c.ID, c.CompanyID, c.PolicyID, c.ActorID, c.Action, before, after,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("tool policy change create: %w", res.Error)
+	}
+	return nil
+}
+
+func (r *toolPolicyRepo) ListChanges(ctx context.Context, companyID, policyID string, limit, offset int) ([]*domain.ToolPolicyChange, int, error) {
+	where := `company_id = $1`
+	args := []any{companyID}
+	if policyID != "" {
+		where += ` AND policy_id = $2`
+		args = append(args, policyID)
+	}
+
+	var total int64
+	if err := r.db.WithContext(ctx).Raw(
+		`SELECT COUNT(*) FROM tool_policy_changes WHERE `+where, args...,
+	).Scan(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("tool policy change count: %w", err)
+	}
+
+	var list []*domain.ToolPolicyChange
+	n := len(args)
+	args = append(args, limit, offset)
+	if err := r.db.WithContext(ctx).Raw(
+		fmt.Sprintf(`SELECT * FROM tool_policy_changes WHERE %s ORDER BY created_at DESC LIMIT $%d OFFSET $%d`, where, n+1, n+2),
+		args...,
+	).Scan(&list).Error; err != nil {
+		return nil, 0, fmt.Errorf("tool policy change list: %w", err)
+	}
+	return list, int(total), nil
+}
+
+func (r *toolPolicyRepo) CreateCall(ctx context.Context, c *domain.ToolCallRequest) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	args := string(c.Arguments)
+	if args == "" {
+		args = "{}"
+	}
+	res := conn(ctx, r.db).Exec(
+		`INSERT INTO tool_call_requests (id, company_id, agent_id, tool, arguments, policy_id, approval_id, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		c.ID, c.CompanyID, c.AgentID, c.Tool, args, c.PolicyID, c.ApprovalID, c.Status,
+	)
+	if res.Error != nil {
+		return fmt.Errorf("tool call create: %w", res.Error)
+	}
+	return nil
+}
+
+func (r *toolPolicyRepo) GetCall(ctx context.Context, id string) (*domain.ToolCallRequest, error) {
+	var c domain.ToolCallRequest
+	res := r.db.WithContext(ctx).Raw(`SELECT * FROM tool_call_requests WHERE id = $1`, id).Scan(&c)
+	if res.Error != nil {
+		return nil, fmt.Errorf("tool call get: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+func (r *toolPolicyRepo) GetCallByApprovalID(ctx context.Context, approvalID string) (*domain.ToolCallRequest, error) {
+	var c domain.ToolCallRequest
+	res := r.db.WithContext(ctx).Raw(`SELECT * FROM tool_call_requests WHERE approval_id = $1`, approvalID).Scan(&c)
+	if res.Error != nil {
+		return nil, fmt.Errorf("tool call get by approval: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+func (r *toolPolicyRepo) ListCallsByAgent(ctx context.Context, agentID string, limit int) ([]*domain.ToolCallRequest, error) {
+	var list []*domain.ToolCallRequest
+	if err := r.db.WithContext(ctx).Raw(
+		`SELECT * FROM tool_call_requests WHERE agent_id = $1 ORDER BY created_at DESC LIMIT $2`, agentID, limit,
+	).Scan(&list).Error; err != nil {
+		return nil, fmt.Errorf("tool call list: %w", err)
+	}
+	return list, nil
+}
+
+func (r *toolPolicyRepo) TransitionCall(ctx context.Context, id string, from, to domain.ToolCallStatus) (bool, error) {
+	res := r.db.WithContext(ctx).Exec(
+		`UPDATE tool_call_requests SET status = $1, decided_at = NOW() WHERE id = $2 AND status = $3`,
+		to, id, from,
+	)
+	if res.Error != nil {
+		return false, fmt.Errorf("tool call transition: %w", res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *toolPolicyRepo) FinishCall(ctx context.Context, id string, status domain.ToolCallStatus, result []byte, isError bool) error {
+	var payload any
+	if len(result) > 0 {
+		payload = string(result)
+	}
+	if err := r.db.WithContext(ctx).Exec(
+		`UPDATE tool_call_requests SET status = $1, result = $2, is_error = $3, finished_at = NOW() WHERE id = $4`,
+		status, payload, isError, id,
+	).Error; err != nil {
+		return fmt.Errorf("tool call finish: %w", err)
+	}
+	return nil
+}
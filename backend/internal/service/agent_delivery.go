@@ -0,0 +1,527 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/event"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+const (
+	agentDeliveryMaxAttempts  = 8                // 达到后不再主动重投，只在重连续传时推送
+	agentDeliveryBaseBackoff  = 30 * time.Second // 首次重投间隔，之后逐次翻倍
+	agentDeliveryMaxBackoff   = 30 * time.Minute
+	agentDeliveryPollInterval = 10 * time.Second
+	agentDeliveryBatchSize    = 200
+	agentDeliveryResumeLimit  = 500
+	agentDeliveryQueueSize    = 1024
+	agentDeliveryRosterTTL    = 30 * time.Second // 公司 agent 名单缓存时间
+
+	agentDeliveryAckedRetentionDays = 3
+	agentDeliveryRetentionDays      = 30
+)
+
+// agentDeliveryTypes 写入投递日志的事件类型
+var agentDeliveryTypes = []event.Type{
+	event.MessageNew,
+	event.MessageEdited,
+	event.MessageDeleted,
+	event.MessageReaction,
+	event.MessagePinned,
+	event.MessageAction,
+	event.TaskCreated,
+	event.TaskUpdated,
+	event.ChannelMemberAdded,
+	event.ChannelMemberLeft,
+}
+
+// DeliverySink 投递目标：Agent WebSocket 连接或订阅了事件通知的 MCP SSE 会话
+type DeliverySink interface {
+	// Deliver 非阻塞推送；发送缓冲区已满时返回 false，该投递按退避稍后重投
+	Deliver(d *domain.AgentDelivery) bool
+}
+
+// AgentDeliveryService Agent 事件的至少一次投递：
+// 相关事件按 agent 写入投递日志并分配递增 seq，在线时立即推送；
+// 客户端 ack 之前按指数退避逐条重投，断线重连后从已确认位置续传。
+type AgentDeliveryService struct {
+	repo       repository.AgentDeliveryRepo
+	agentRepo  repository.AgentRepo
+	messageSvc *MessageService
+	metrics    *DeliveryMetrics
+	queue      chan event.Event
+
+	mu    sync.RWMutex
+	sinks map[string]map[DeliverySink]struct{} // agentID → 在线的投递目标
+
+	rosterMu sync.Mutex
+	rosters  map[string]deliveryRoster // companyID → 非人类 agent
+
+	backfillMu sync.Mutex
+	backfill   map[string]struct{} // 队列溢出、需要按未读消息补投的公司
+}
+
+type deliveryRoster struct {
+	agents []*domain.Agent
+	at     time.Time
+}
+
+func NewAgentDeliveryService(repo repository.AgentDeliveryRepo, agentRepo repository.AgentRepo, messageSvc *MessageService) *AgentDeliveryService {
+	return &AgentDeliveryService{
+		repo:       repo,
+		agentRepo:  agentRepo,
+		messageSvc: messageSvc,
+		metrics:    NewDeliveryMetrics(),
+		queue:      make(chan event.Event, agentDeliveryQueueSize),
+		sinks:      make(map[string]map[DeliverySink]struct{}),
+		rosters:    make(map[string]deliveryRoster),
+		backfill:   make(map[string]struct{}),
+	}
+}
+
+// Start 订阅事件并启动入队与重投循环
+func (s *AgentDeliveryService) Start(ctx context.Context) {
+	for _, t := range agentDeliveryTypes {
+		event.Global.Subscribe(t, s.offer)
+	}
+	go s.consume(ctx)
+	go s.run(ctx)
+}
+
+// offer 非阻塞入队：事件总线同步调用订阅方，阻塞会拖住所有发布事件的请求。
+// 队列满时丢弃该事件并标记公司，由 run 循环按未读消息补投
+func (s *AgentDeliveryService) offer(e event.Event) {
+	select {
+	case s.queue <- e:
+		return
+	default:
+	}
+	s.metrics.RecordOverflow()
+	var head struct {
+		CompanyID string `json:"company_id"`
+	}
+	if json.Unmarshal(e.Payload, &head) != nil || head.CompanyID == "" {
+		return
+	}
+	s.backfillMu.Lock()
+	_, flagged := s.backfill[head.CompanyID]
+	s.backfill[head.CompanyID] = struct{}{}
+	s.backfillMu.Unlock()
+	if !flagged {
+		log.Printf("[delivery] queue full, dropping events for company %s; unread messages will be backfilled", head.CompanyID)
+	}
+}
+
+// backfillOverflow 为溢出过的公司补齐各 agent 的未读消息（Enqueue 按消息去重）
+func (s *AgentDeliveryService) backfillOverflow(ctx context.Context) {
+	s.backfillMu.Lock()
+	companies := s.backfill
+	s.backfill = make(map[string]struct{})
+	s.backfillMu.Unlock()
+
+	for companyID := range companies {
+		agents, err := s.roster(ctx, companyID)
+		if err != nil {
+			log.Printf("[delivery] backfill %s: %v", companyID, err)
+			s.backfillMu.Lock()
+			s.backfill[companyID] = struct{}{}
+			s.backfillMu.Unlock()
+			continue
+		}
+		for _, a := range agents {
+			if err := s.seedUnread(ctx, a); err != nil {
+				log.Printf("[delivery] backfill agent %s: %v", a.ID, err)
+			}
+		}
+	}
+}
+
+// roster 公司内接收投递的（非人类）agent，短时缓存避免每个事件都读全量名单
+func (s *AgentDeliveryService) roster(ctx context.Context, companyID string) ([]*domain.Agent, error) {
+	s.rosterMu.Lock()
+	r, ok := s.rosters[companyID]
+	s.rosterMu.Unlock()
+	if ok && time.Since(r.at) < agentDeliveryRosterTTL {
+		return r.agents, nil
+	}
+
+	all, err := s.agentRepo.GetByCompany(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	// 人类成员通过前端 WebSocket 接收实时推送，不进入投递日志
+	agents := make([]*domain.Agent, 0, len(all))
+	for _, a := range all {
+		if !a.IsHuman {
+			agents = append(agents, a)
+		}
+	}
+	s.rosterMu.Lock()
+	s.rosters[companyID] = deliveryRoster{agents: agents, at: time.Now()}
+	s.rosterMu.Unlock()
+	return agents, nil
+}
+
+// Attach 登记 agent 的在线投递目标，返回注销函数
+func (s *AgentDeliveryService) Attach(agentID string, sink DeliverySink) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sinks[agentID] == nil {
+		s.sinks[agentID] = make(map[DeliverySink]struct{})
+	}
+	s.sinks[agentID][sink] = struct{}{}
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.sinks[agentID], sink)
+		if len(s.sinks[agentID]) == 0 {
+			delete(s.sinks, agentID)
+		}
+	}
+}
+
+func (s *AgentDeliveryService) sinksOf(agentID string) []DeliverySink {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]DeliverySink, 0, len(s.sinks[agentID]))
+	for sink := range s.sinks[agentID] {
+		out = append(out, sink)
+	}
+	return out
+}
+
+func (s *AgentDeliveryService) attachedAgents() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, len(s.sinks))
+	for id := range s.sinks {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Cursor 返回 agent 的投递游标；从未投递过时返回零值游标
+func (s *AgentDeliveryService) Cursor(ctx context.Context, agentID string) (*domain.AgentDeliveryCursor, error) {
+	c, err := s.repo.GetCursor(ctx, agentID)
+	if err != nil || c != nil {
+		return c, err
+	}
+	return &domain.AgentDeliveryCursor{AgentID: agentID}, nil
+}
+
+// Ack 确认 seq <= upTo 的全部投递（累计确认）以及 seqs 中的单条投递
+func (s *AgentDeliveryService) Ack(ctx context.Context, agentID string, upTo int64, seqs []int64) (int64, error) {
+	if upTo <= 0 && len(seqs) == 0 {
+		return 0, nil
+	}
+	n, err := s.repo.Ack(ctx, agentID, upTo, seqs)
+	if err != nil {
+		return 0, err
+	}
+	s.metrics.RecordAcked(n)
+	return n, nil
+}
+
+// AckMessages 标记已读的消息视为已确认，不再重投
+func (s *AgentDeliveryService) AckMessages(ctx context.Context, agentID string, messageIDs []string) error {
+	n, err := s.repo.AckMessages(ctx, agentID, messageIDs)
+	if err != nil {
+		return err
+	}
+	s.metrics.RecordAcked(n)
+	return nil
+}
+
+// Resume 向 sink 推送全部未确认的投递（按 seq 升序）。
+// 首次续传时先用未读消息补齐日志，兼容投递日志启用前积压的未读消息。
+func (s *AgentDeliveryService) Resume(ctx context.Context, agent *domain.Agent, sink DeliverySink) error {
+	first, err := s.repo.MarkResumed(ctx, agent.ID)
+	if err != nil {
+		return err
+	}
+	if first {
+		if err := s.seedUnread(ctx, agent); err != nil {
+			log.Printf("[delivery] seed unread for agent %s: %v", agent.ID, err)
+		}
+	}
+	pending, err := s.repo.ListPending(ctx, agent.ID, agentDeliveryResumeLimit)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		log.Printf("[delivery] resuming %d pending deliveries for agent %s (%s)", len(pending), agent.Name, agent.ID)
+	}
+	for _, d := range pending {
+		s.attempt(ctx, d, []DeliverySink{sink})
+	}
+	return nil
+}
+
+func (s *AgentDeliveryService) seedUnread(ctx context.Context, agent *domain.Agent) error {
+	msgs, err := s.messageSvc.GetUnreadMessages(ctx, agent.ID, agent.CompanyID)
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		payload, err := json.Marshal(event.MessageNewPayload{
+			MessageID:    msg.ID,
+			CompanyID:    msg.CompanyID,
+			ChannelID:    msg.ChannelID,
+			ReceiverID:   msg.ReceiverID,
+			SenderID:     msg.SenderID,
+			MsgType:      string(msg.MsgType),
+			Content:      msg.Content,
+			Mentions:     msg.Mentions,
+			ThreadRootID: msg.ThreadRootID,
+			CreatedAt:    msg.CreatedAt.Format(time.RFC3339),
+		})
+		if err != nil {
+			continue
+		}
+		d := &domain.AgentDelivery{
+			AgentID:   agent.ID,
+			CompanyID: agent.CompanyID,
+			EventType: string(event.MessageNew),
+			Payload:   payload,
+			MessageID: &msg.ID,
+		}
+		ok, err := s.repo.Enqueue(ctx, d)
+		if err != nil {
+			return err
+		}
+		if ok {
+			s.metrics.RecordEnqueued()
+		}
+	}
+	return nil
+}
+
+// AgentDeliveryStats 投递指标（进程级计数，丢弃明细只含本公司 agent）与各 agent 的待确认积压
+type AgentDeliveryStats struct {
+	Metrics *DeliveryMetricsSnapshot       `json:"metrics"`
+	Backlog []*domain.AgentDeliveryBacklog `json:"backlog"`
+}
+
+func (s *AgentDeliveryService) Stats(ctx context.Context, companyID string) (*AgentDeliveryStats, error) {
+	backlog, err := s.repo.Backlog(ctx, companyID, agentDeliveryMaxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	agents, err := s.agentRepo.GetByCompany(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	snap := s.metrics.GetSnapshot()
+	dropped := make(map[string]int64)
+	for _, a := range agents {
+		if n := snap.DroppedByAgent[a.ID]; n > 0 {
+			dropped[a.ID] = n
+		}
+	}
+	snap.DroppedByAgent = dropped
+	return &AgentDeliveryStats{Metrics: snap, Backlog: backlog}, nil
+}
+
+func (s *AgentDeliveryService) consume(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-s.queue:
+			s.fanOut(ctx, e)
+		}
+	}
+}
+
+// fanOut 为事件的每个相关 agent 写入投递日志并立即推送
+func (s *AgentDeliveryService) fanOut(ctx context.Context, e event.Event) {
+	var head struct {
+		CompanyID string `json:"company_id"`
+		MessageID string `json:"message_id"`
+	}
+	if err := json.Unmarshal(e.Payload, &head); err != nil || head.CompanyID == "" {
+		return
+	}
+	agents, err := s.roster(ctx, head.CompanyID)
+	if err != nil {
+		log.Printf("[delivery] list agents for %s: %v", e.Type, err)
+		return
+	}
+	var ids []string
+	for _, a := range agents {
+		if s.relevant(ctx, a, e) {
+			ids = append(ids, a.ID)
+		}
+	}
+	tmpl := &domain.AgentDelivery{
+		CompanyID: head.CompanyID,
+		EventType: string(e.Type),
+		Payload:   e.Payload,
+	}
+	if e.Type == event.MessageNew && head.MessageID != "" {
+		tmpl.MessageID = &head.MessageID
+	}
+	// 一条语句为全部相关 agent 写入日志
+	logged, err := s.repo.EnqueueMany(ctx, ids, tmpl)
+	if err != nil {
+		log.Printf("[delivery] enqueue %s for %d agents: %v", e.Type, len(ids), err)
+		return
+	}
+	for _, d := range logged {
+		s.metrics.RecordEnqueued()
+		if sinks := s.sinksOf(d.AgentID); len(sinks) > 0 {
+			s.attempt(ctx, d, sinks)
+		}
+	}
+}
+
+// attempt 推送一次并安排下次重投；缓冲区满的推送计入丢弃指标，由重投兜底
+func (s *AgentDeliveryService) attempt(ctx context.Context, d *domain.AgentDelivery, sinks []DeliverySink) {
+	delivered := false
+	for _, sink := range sinks {
+		if sink.Deliver(d) {
+			delivered = true
+		} else {
+			s.metrics.RecordDropped(d.AgentID)
+		}
+	}
+	if delivered {
+		s.metrics.RecordDelivered(d.Attempts > 0)
+	}
+	if err := s.repo.MarkAttempted(ctx, d.AgentID, d.Seq, time.Now().Add(deliveryBackoff(d.Attempts))); err != nil {
+		log.Printf("[delivery] mark attempted %s#%d: %v", d.AgentID, d.Seq, err)
+	}
+}
+
+// deliveryBackoff 第 attempts+1 次推送后距下次重投的间隔
+func deliveryBackoff(attempts int) time.Duration {
+	d := agentDeliveryBaseBackoff
+	for i := 0; i < attempts && d < agentDeliveryMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > agentDeliveryMaxBackoff {
+		d = agentDeliveryMaxBackoff
+	}
+	return d
+}
+
+// run 定期重投在线 agent 的到期未确认投递，并清理过期日志
+func (s *AgentDeliveryService) run(ctx context.Context) {
+	ticker := time.NewTicker(agentDeliveryPollInterval)
+	defer ticker.Stop()
+	lastCleanup := time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.backfillOverflow(ctx)
+		s.redeliver(ctx)
+		if time.Since(lastCleanup) > 24*time.Hour {
+			if err := s.repo.DeleteBefore(ctx, agentDeliveryAckedRetentionDays, agentDeliveryRetentionDays); err != nil {
+				log.Printf("[delivery] cleanup: %v", err)
+			}
+			lastCleanup = time.Now()
+		}
+	}
+}
+
+func (s *AgentDeliveryService) redeliver(ctx context.Context) {
+	due, err := s.repo.ListDue(ctx, s.attachedAgents(), agentDeliveryMaxAttempts, agentDeliveryBatchSize)
+	if err != nil {
+		log.Printf("[delivery] list due: %v", err)
+		return
+	}
+	for _, d := range due {
+		if sinks := s.sinksOf(d.AgentID); len(sinks) > 0 {
+			s.attempt(ctx, d, sinks)
+		}
+	}
+}
+
+// relevant 判断事件是否需要投递给该 agent
+func (s *AgentDeliveryService) relevant(ctx context.Context, agent *domain.Agent, e event.Event) bool {
+	switch e.Type {
+	case event.MessageNew:
+		var p event.MessageNewPayload
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return false
+		}
+		if p.CompanyID != agent.CompanyID {
+			return false
+		}
+		// DM：只推给目标 agent
+		if p.ReceiverID != nil {
+			return *p.ReceiverID == agent.ID
+		}
+		// 私有频道：只推给频道成员
+		if p.Private && (p.ChannelID == nil || !s.messageSvc.IsChannelMember(ctx, *p.ChannelID, agent.ID)) {
+			return false
+		}
+		// 线程回复：根消息作者总是收到（自己回复自己的除外）
+		if p.ThreadRootSenderID != nil && *p.ThreadRootSenderID == agent.ID {
+			return p.SenderID == nil || *p.SenderID != agent.ID
+		}
+		// 频道消息：跳过其他 AI Agent 发的（防止 Agent 间无限循环），但 @本人（含 @channel 等群组提及）的除外
+		if p.SenderID != nil && *p.SenderID != agent.ID && !p.SenderHuman {
+			return domain.StringList(p.Mentions).Contains(agent.ID)
+		}
+		return true
+
+	case event.MessageEdited, event.MessageDeleted, event.MessageReaction, event.MessagePinned:
+		// 只通知消息作者与私信对方，自己的操作不回推
+		var p event.MessageChangedPayload
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return false
+		}
+		if p.CompanyID != agent.CompanyID || p.ActorID == agent.ID {
+			return false
+		}
+		if (p.SenderID != nil && *p.SenderID == agent.ID) || (p.ReceiverID != nil && *p.ReceiverID == agent.ID) {
+			return true
+		}
+		// 编辑后被提及的成员（私有频道仍要求是成员）
+		return domain.StringList(p.Mentions).Contains(agent.ID) &&
+			(!p.Private || (p.ChannelID != nil && s.messageSvc.IsChannelMember(ctx, *p.ChannelID, agent.ID)))
+
+	case event.MessageAction:
+		// 按钮点击只回传给消息发送者
+		var p event.MessageChangedPayload
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return false
+		}
+		return p.CompanyID == agent.CompanyID && p.ActorID != agent.ID &&
+			p.SenderID != nil && *p.SenderID == agent.ID
+
+	case event.TaskCreated, event.TaskUpdated:
+		var p struct {
+			CompanyID  string  `json:"company_id"`
+			AssigneeID *string `json:"assignee_id"`
+		}
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return false
+		}
+		if p.CompanyID != agent.CompanyID {
+			return false
+		}
+		if agent.RoleType == domain.RoleHR || agent.RoleType == domain.RoleChairman {
+			return true
+		}
+		return p.AssigneeID != nil && *p.AssigneeID == agent.ID
+
+	case event.ChannelMemberAdded, event.ChannelMemberLeft:
+		// 只通知被邀请 / 被移出的本人（自己主动退出的除外）
+		var p event.ChannelMemberPayload
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return false
+		}
+		return p.CompanyID == agent.CompanyID && p.AgentID == agent.ID && p.ActorID != agent.ID
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/event"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+func TestDeliveryBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, time.Minute},
+		{3, 4 * time.Minute},
+		{6, agentDeliveryMaxBackoff},
+		{100, agentDeliveryMaxBackoff},
+	}
+	for _, tt := range tests {
+		if got := deliveryBackoff(tt.attempts); got != tt.want {
+			t.Errorf("deliveryBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestAgentDeliveryRelevant(t *testing.T) {
+	s := &AgentDeliveryService{}
+	ctx := context.Background()
+	self := &domain.Agent{ID: "a", CompanyID: "c"}
+	other, human := "b", "h"
+
+	tests := []struct {
+		name string
+		e    event.Event
+		want bool
+	}{
+		{"dm to self", event.NewEvent(event.MessageNew, event.MessageNewPayload{
+			CompanyID: "c", SenderID: &other, ReceiverID: &self.ID}), true},
+		{"dm to other", event.NewEvent(event.MessageNew, event.MessageNewPayload{
+			CompanyID: "c", SenderID: &human, ReceiverID: &other}), false},
+		{"other company", event.NewEvent(event.MessageNew, event.MessageNewPayload{
+			CompanyID: "x", SenderID: &human, SenderHuman: true}), false},
+		{"channel message from human", event.NewEvent(event.MessageNew, event.MessageNewPayload{
+			CompanyID: "c", SenderID: &human, SenderHuman: true}), true},
+		{"channel message from agent", event.NewEvent(event.MessageNew, event.MessageNewPayload{
+			CompanyID: "c", SenderID: &other}), false},
+		{"channel message from agent mentioning self", event.NewEvent(event.MessageNew, event.MessageNewPayload{
+			CompanyID: "c", SenderID: &other, Mentions: []string{"a"}}), true},
+		{"thread reply to own root", event.NewEvent(event.MessageNew, event.MessageNewPayload{
+			CompanyID: "c", SenderID: &other, ThreadRootSenderID: &self.ID}), true},
+		{"own edit", event.NewEvent(event.MessageEdited, event.MessageChangedPayload{
+			CompanyID: "c", SenderID: &self.ID, ActorID: "a"}), false},
+		{"reaction on own message", event.NewEvent(event.MessageReaction, event.MessageChangedPayload{
+			CompanyID: "c", SenderID: &self.ID, ActorID: "b"}), true},
+		{"button clicked on own message", event.NewEvent(event.MessageAction, event.MessageChangedPayload{
+			CompanyID: "c", SenderID: &self.ID, ActorID: "h", ActionID: "ok"}), true},
+		{"button clicked in dm to self", event.NewEvent(event.MessageAction, event.MessageChangedPayload{
+			CompanyID: "c", SenderID: &other, ReceiverID: &self.ID, ActorID: "h", ActionID: "ok"}), false},
+		{"task assigned to other", event.NewEvent(event.TaskCreated, event.TaskCreatedPayload{
+			CompanyID: "c", AssigneeID: &other}), false},
+		{"task assigned to self", event.NewEvent(event.TaskUpdated, event.TaskCreatedPayload{
+			CompanyID: "c", AssigneeID: &self.ID}), true},
+		{"added to channel", event.NewEvent(event.ChannelMemberAdded, event.ChannelMemberPayload{
+			CompanyID: "c", AgentID: "a", ActorID: "b"}), true},
+		{"left channel", event.NewEvent(event.ChannelMemberLeft, event.ChannelMemberPayload{
+			CompanyID: "c", AgentID: "a", ActorID: "a"}), false},
+	}
+	for _, tt := range tests {
+		if got := s.relevant(ctx, self, tt.e); got != tt.want {
+			t.Errorf("%s: relevant = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDeliveryMetricsSnapshot(t *testing.T) {
+	m := NewDeliveryMetrics()
+	m.RecordEnqueued()
+	m.RecordDelivered(false)
+	m.RecordDelivered(true)
+	m.RecordDropped("a")
+	m.RecordDropped("a")
+	m.RecordAcked(2)
+
+	snap := m.GetSnapshot()
+	if snap.Enqueued != 1 || snap.Delivered != 2 || snap.Redelivered != 1 || snap.Acked != 2 || snap.Dropped != 2 {
+		t.Fatalf("unexpected snapshot: %+v", snap)
+	}
+	snap.DroppedByAgent["a"] = 0
+	if m.GetSnapshot().DroppedByAgent["a"] != 2 {
+		t.Fatal("snapshot should not alias internal state")
+	}
+}
+
+// memDeliveryRepo 记录批量入队；其余方法未实现
+type memDeliveryRepo struct {
+	repository.AgentDeliveryRepo
+	batches [][]string
+	seq     int64
+}
+
+func (r *memDeliveryRepo) EnqueueMany(_ context.Context, ids []string, d *domain.AgentDelivery) ([]*domain.AgentDelivery, error) {
+	r.batches = append(r.batches, ids)
+	out := make([]*domain.AgentDelivery, len(ids))
+	for i, id := range ids {
+		r.seq++
+		cp := *d
+		cp.AgentID, cp.Seq = id, r.seq
+		out[i] = &cp
+	}
+	return out, nil
+}
+
+type countingAgentRepo struct {
+	repository.AgentRepo
+	agents []*domain.Agent
+	calls  int
+}
+
+func (r *countingAgentRepo) GetByCompany(context.Context, string) ([]*domain.Agent, error) {
+	r.calls++
+	return r.agents, nil
+}
+
+func TestAgentDeliveryFanOutBatchesAndCachesRoster(t *testing.T) {
+	repo := &memDeliveryRepo{}
+	agents := &countingAgentRepo{agents: []*domain.Agent{
+		{ID: "a", CompanyID: "c"},
+		{ID: "b", CompanyID: "c"},
+		{ID: "h", CompanyID: "c", IsHuman: true},
+	}}
+	s := NewAgentDeliveryService(repo, agents, nil)
+	human := "h"
+	e := event.NewEvent(event.MessageNew, event.MessageNewPayload{CompanyID: "c", SenderID: &human, SenderHuman: true})
+
+	s.fanOut(context.Background(), e)
+	s.fanOut(context.Background(), e)
+	if agents.calls != 1 {
+		t.Errorf("GetByCompany called %d times, want 1 (cached)", agents.calls)
+	}
+	if len(repo.batches) != 2 || len(repo.batches[0]) != 2 {
+		t.Fatalf("batches = %v, want two batches of the two non-human agents", repo.batches)
+	}
+}
+
+func TestAgentDeliveryOfferNeverBlocks(t *testing.T) {
+	s := NewAgentDeliveryService(nil, nil, nil)
+	e := event.NewEvent(event.TaskUpdated, event.TaskCreatedPayload{CompanyID: "c"})
+	for i := 0; i < agentDeliveryQueueSize+10; i++ {
+		s.offer(e) // 队列满后不应阻塞
+	}
+	if got := s.metrics.GetSnapshot().Overflowed; got != 10 {
+		t.Errorf("overflowed = %d, want 10", got)
+	}
+	if _, ok := s.backfill["c"]; !ok {
+		t.Error("overflowing company should be flagged for backfill")
+	}
+}
@@ -22,6 +22,7 @@ type AgentService struct {
 	companyRepo repository.CompanyRepo
 	deployRepo  repository.DeploymentRepo
 	taskRepo    repository.TaskRepo
+	onDelete    []func(ctx context.Context, agentID string) error
 }
 
 func NewAgentService(agentRepo repository.AgentRepo, companyRepo repository.CompanyRepo, deployRepo repository.DeploymentRepo, taskRepo repository.TaskRepo) *AgentService {
@@ -164,6 +165,11 @@ func (s *AgentService) MarkInitialized(ctx context.Context, id string) error {
 	return s.agentRepo.MarkInitialized(ctx, id)
 }
 
+// OnDelete 注册 agent 删除后的清理函数，由各自的服务清理其关联数据（库中不设外键级联）
+func (s *AgentService) OnDelete(fn func(ctx context.Context, agentID string) error) {
+	s.onDelete = append(s.onDelete, fn)
+}
+
 func (s *AgentService) Delete(ctx context.Context, id string) error {
 	agent, _ := s.agentRepo.GetByID(ctx, id)
 
@@ -176,7 +182,15 @@ func (s *AgentService) Delete(ctx context.Context, id string) error {
 			}
 		}
 	}
-	return s.agentRepo.Delete(ctx, id)
+	if err := s.agentRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+	for _, fn := range s.onDelete {
+		if err := fn(ctx, id); err != nil {
+			log.Printf("清理 agent %s 关联数据失败: %v", id, err)
+		}
+	}
+	return nil
 }
 
 // cleanupDeployment 根据部署类型清理
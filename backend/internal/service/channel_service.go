@@ -0,0 +1,411 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/event"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+// channelManagePerm 可管理公司内任意频道（含查看全部私有频道）
+const channelManagePerm = "channel:manage"
+
+var channelNamePattern = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N}_-]{0,49}$`)
+
+// ChannelService 频道创建、修改、归档与成员管理
+type ChannelService struct {
+	repo      repository.ChannelRepo
+	agentRepo repository.AgentRepo
+	tx        repository.Transactor
+}
+
+func NewChannelService(repo repository.ChannelRepo, agentRepo repository.AgentRepo, tx repository.Transactor) *ChannelService {
+	return &ChannelService{repo: repo, agentRepo: agentRepo, tx: tx}
+}
+
+type CreateChannelInput struct {
+	Name        string
+	Description string
+	Private     bool
+	MemberIDs   []string // 创建时一并邀请的成员（创建者自动成为 owner）
+}
+
+// UpdateChannelInput 为 nil 的字段保持不变
+type UpdateChannelInput struct {
+	Name        *string
+	Description *string
+	Private     *bool
+}
+
+// normalizeChannelName 去掉前导 #，统一小写
+func normalizeChannelName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "#"))
+	if !channelNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid channel name %q: use letters, digits, - or _ (max 50)", name)
+	}
+	return name, nil
+}
+
+// canAccess 公开频道全员可读写；私有频道仅成员
+func canAccess(ch *domain.Channel, m *domain.ChannelMember) bool {
+	return !ch.IsPrivate || m != nil
+}
+
+// load 加载频道及 actor 的成员记录；对无权访问的私有频道返回 not found，避免泄露其存在
+func (s *ChannelService) load(ctx context.Context, actor *domain.Agent, channelID string) (*domain.Channel, *domain.ChannelMember, error) {
+	ch, err := s.repo.GetByID(ctx, channelID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ch == nil || ch.CompanyID != actor.CompanyID {
+		return nil, nil, fmt.Errorf("channel not found")
+	}
+	m, err := s.repo.GetMember(ctx, ch.ID, actor.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !canAccess(ch, m) && !actor.HasPermission(channelManagePerm) {
+		return nil, nil, fmt.Errorf("channel not found")
+	}
+	return ch, m, nil
+}
+
+// loadManaged 同 load，并要求 actor 为频道 owner / admin 或拥有 channel:manage
+func (s *ChannelService) loadManaged(ctx context.Context, actor *domain.Agent, channelID string) (*domain.Channel, *domain.ChannelMember, error) {
+	ch, m, err := s.load(ctx, actor, channelID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !actor.HasPermission(channelManagePerm) && (m == nil || !m.Role.CanManage()) {
+		return nil, nil, fmt.Errorf("permission denied")
+	}
+	return ch, m, nil
+}
+
+// List 返回 actor 可见的频道；all 仅对 channel:manage 生效，返回包括他人私有频道在内的全部频道
+func (s *ChannelService) List(ctx context.Context, actor *domain.Agent, all, includeArchived bool) ([]*domain.Channel, error) {
+	visibleTo := actor.ID
+	if all && actor.HasPermission(channelManagePerm) {
+		visibleTo = ""
+	}
+	return s.repo.List(ctx, actor.CompanyID, visibleTo, includeArchived)
+}
+
+func (s *ChannelService) Get(ctx context.Context, actor *domain.Agent, channelID string) (*domain.Channel, error) {
+	ch, _, err := s.load(ctx, actor, channelID)
+	return ch, err
+}
+
+// Find 按频道名（可带 #）或 ID 查找 actor 可见的频道，供 MCP 工具使用
+func (s *ChannelService) Find(ctx context.Context, actor *domain.Agent, ref string) (*domain.Channel, error) {
+	ref = strings.TrimPrefix(strings.TrimSpace(ref), "#")
+	if ref == "" {
+		return nil, fmt.Errorf("channel is required")
+	}
+	ch, err := s.repo.GetByName(ctx, actor.CompanyID, strings.ToLower(ref))
+	if err != nil {
+		return nil, err
+	}
+	if ch == nil {
+		if _, perr := uuid.Parse(ref); perr != nil {
+			return nil, fmt.Errorf("channel %q not found", ref)
+		}
+		return s.Get(ctx, actor, ref)
+	}
+	return s.Get(ctx, actor, ch.ID)
+}
+
+func (s *ChannelService) Create(ctx context.Context, actor *domain.Agent, in CreateChannelInput) (*domain.Channel, []*domain.ChannelMember, error) {
+	name, err := normalizeChannelName(in.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if existing, err := s.repo.GetByName(ctx, actor.CompanyID, name); err != nil {
+		return nil, nil, err
+	} else if existing != nil {
+		return nil, nil, fmt.Errorf("channel %q already exists", name)
+	}
+	invitees, err := s.resolveInvitees(ctx, actor, in.MemberIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ch := &domain.Channel{
+		ID:          uuid.New().String(),
+		CompanyID:   actor.CompanyID,
+		Name:        name,
+		Description: strings.TrimSpace(in.Description),
+		IsPrivate:   in.Private,
+		CreatedBy:   &actor.ID,
+	}
+	members := []*domain.ChannelMember{{
+		ChannelID: ch.ID, AgentID: actor.ID, CompanyID: ch.CompanyID, Role: domain.ChannelRoleOwner, AddedBy: &actor.ID,
+	}}
+	for _, id := range invitees {
+		members = append(members, &domain.ChannelMember{
+			ChannelID: ch.ID, AgentID: id, CompanyID: ch.CompanyID, Role: domain.ChannelRoleMember, AddedBy: &actor.ID,
+		})
+	}
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.Create(ctx, ch); err != nil {
+			return err
+		}
+		for _, m := range members {
+			if err := s.repo.UpsertMember(ctx, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.publishChannel(event.ChannelCreated, ch, actor.ID)
+	for _, m := range members[1:] {
+		s.publishMember(event.ChannelMemberAdded, ch, m, actor.ID)
+	}
+	return ch, members, nil
+}
+
+// resolveInvitees 校验被邀请者属于同一公司，去重并排除 actor 本人
+func (s *ChannelService) resolveInvitees(ctx context.Context, actor *domain.Agent, ids []string) ([]string, error) {
+	seen := map[string]bool{actor.ID: true}
+	var out []string
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		if err := s.checkAgent(ctx, actor.CompanyID, id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, nil
+}
+
+func (s *ChannelService) checkAgent(ctx context.Context, companyID, agentID string) error {
+	a, err := s.agentRepo.GetByID(ctx, agentID)
+	if err != nil || a == nil || a.CompanyID != companyID {
+		return fmt.Errorf("agent %s not found", agentID)
+	}
+	return nil
+}
+
+func (s *ChannelService) Update(ctx context.Context, actor *domain.Agent, channelID string, in UpdateChannelInput) (*domain.Channel, error) {
+	ch, _, err := s.loadManaged(ctx, actor, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if in.Name != nil {
+		name, err := normalizeChannelName(*in.Name)
+		if err != nil {
+			return nil, err
+		}
+		if name != ch.Name {
+			if ch.IsDefault {
+				return nil, fmt.Errorf("cannot rename default channel")
+			}
+			if existing, err := s.repo.GetByName(ctx, ch.CompanyID, name); err != nil {
+				return nil, err
+			} else if existing != nil {
+				return nil, fmt.Errorf("channel %q already exists", name)
+			}
+			ch.Name = name
+		}
+	}
+	if in.Description != nil {
+		ch.Description = strings.TrimSpace(*in.Description)
+	}
+	if in.Private != nil && *in.Private != ch.IsPrivate {
+		if ch.IsDefault {
+			return nil, fmt.Errorf("default channel must stay public")
+		}
+		ch.IsPrivate = *in.Private
+	}
+	if err := s.repo.Update(ctx, ch); err != nil {
+		return nil, err
+	}
+	s.publishChannel(event.ChannelUpdated, ch, actor.ID)
+	return ch, nil
+}
+
+// SetArchived 归档或恢复频道；归档后频道只读，默认频道不可归档
+func (s *ChannelService) SetArchived(ctx context.Context, actor *domain.Agent, channelID string, archived bool) (*domain.Channel, error) {
+	ch, _, err := s.loadManaged(ctx, actor, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if ch.IsArchived() == archived {
+		return ch, nil
+	}
+	if archived && ch.IsDefault {
+		return nil, fmt.Errorf("cannot archive default channel")
+	}
+	if archived {
+		now := time.Now()
+		ch.ArchivedAt = &now
+	} else {
+		ch.ArchivedAt = nil
+	}
+	if err := s.repo.Update(ctx, ch); err != nil {
+		return nil, err
+	}
+	s.publishChannel(event.ChannelUpdated, ch, actor.ID)
+	return ch, nil
+}
+
+func (s *ChannelService) ListMembers(ctx context.Context, actor *domain.Agent, channelID string) ([]*domain.ChannelMember, error) {
+	ch, _, err := s.load(ctx, actor, channelID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListMembers(ctx, ch.ID)
+}
+
+// AddMember 邀请成员或修改其角色；授予 owner 需要 actor 本身是 owner 或拥有 channel:manage
+func (s *ChannelService) AddMember(ctx context.Context, actor *domain.Agent, channelID, agentID string, role domain.ChannelRole) (*domain.ChannelMember, error) {
+	if role == "" {
+		role = domain.ChannelRoleMember
+	}
+	switch role {
+	case domain.ChannelRoleOwner, domain.ChannelRoleAdmin, domain.ChannelRoleMember:
+	default:
+		return nil, fmt.Errorf("invalid channel role %q", role)
+	}
+	ch, self, err := s.loadManaged(ctx, actor, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if ch.IsArchived() {
+		return nil, fmt.Errorf("channel is archived")
+	}
+	isOwner := actor.HasPermission(channelManagePerm) || (self != nil && self.Role == domain.ChannelRoleOwner)
+	if role == domain.ChannelRoleOwner && !isOwner {
+		return nil, fmt.Errorf("permission denied")
+	}
+	if err := s.checkAgent(ctx, ch.CompanyID, agentID); err != nil {
+		return nil, err
+	}
+	existing, err := s.repo.GetMember(ctx, ch.ID, agentID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if existing.Role == role {
+			return existing, nil
+		}
+		if existing.Role == domain.ChannelRoleOwner && !isOwner {
+			return nil, fmt.Errorf("permission denied")
+		}
+		if existing.Role == domain.ChannelRoleOwner {
+			if err := s.ensureAnotherOwner(ctx, ch.ID, agentID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	m := &domain.ChannelMember{ChannelID: ch.ID, AgentID: agentID, CompanyID: ch.CompanyID, Role: role, AddedBy: &actor.ID}
+	if err := s.repo.UpsertMember(ctx, m); err != nil {
+		return nil, err
+	}
+	s.publishMember(event.ChannelMemberAdded, ch, m, actor.ID)
+	return m, nil
+}
+
+// RemoveMember 移除成员；agentID 为 actor 本人时即退出频道，无需管理权限
+func (s *ChannelService) RemoveMember(ctx context.Context, actor *domain.Agent, channelID, agentID string) error {
+	var (
+		ch   *domain.Channel
+		self *domain.ChannelMember
+		err  error
+	)
+	if agentID == actor.ID {
+		ch, self, err = s.load(ctx, actor, channelID)
+	} else {
+		ch, self, err = s.loadManaged(ctx, actor, channelID)
+	}
+	if err != nil {
+		return err
+	}
+	target, err := s.repo.GetMember(ctx, ch.ID, agentID)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return fmt.Errorf("member not found")
+	}
+	if target.Role == domain.ChannelRoleOwner {
+		if agentID != actor.ID && !actor.HasPermission(channelManagePerm) && (self == nil || self.Role != domain.ChannelRoleOwner) {
+			return fmt.Errorf("permission denied")
+		}
+		if err := s.ensureAnotherOwner(ctx, ch.ID, agentID); err != nil {
+			return err
+		}
+	}
+	if err := s.repo.RemoveMember(ctx, ch.ID, agentID); err != nil {
+		return err
+	}
+	s.publishMember(event.ChannelMemberLeft, ch, target, actor.ID)
+	return nil
+}
+
+// ensureAnotherOwner 频道至少保留一位 owner，避免无人可管理
+func (s *ChannelService) ensureAnotherOwner(ctx context.Context, channelID, leavingID string) error {
+	members, err := s.repo.ListMembers(ctx, channelID)
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		if m.Role == domain.ChannelRoleOwner && m.AgentID != leavingID {
+			return nil
+		}
+	}
+	return fmt.Errorf("channel must keep at least one owner; transfer ownership first")
+}
+
+// MemberIDs 返回频道成员 ID，供 WS Hub 过滤私有频道推送
+func (s *ChannelService) MemberIDs(ctx context.Context, channelID string) ([]string, error) {
+	members, err := s.repo.ListMembers(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.AgentID)
+	}
+	return ids, nil
+}
+
+func (s *ChannelService) publishChannel(t event.Type, ch *domain.Channel, actorID string) {
+	event.Global.Publish(event.NewEvent(t, event.ChannelPayload{
+		ChannelID:   ch.ID,
+		CompanyID:   ch.CompanyID,
+		Name:        ch.Name,
+		Description: ch.Description,
+		Private:     ch.IsPrivate,
+		Archived:    ch.IsArchived(),
+		ActorID:     actorID,
+	}))
+}
+
+func (s *ChannelService) publishMember(t event.Type, ch *domain.Channel, m *domain.ChannelMember, actorID string) {
+	event.Global.Publish(event.NewEvent(t, event.ChannelMemberPayload{
+		ChannelID:   ch.ID,
+		CompanyID:   ch.CompanyID,
+		ChannelName: ch.Name,
+		Private:     ch.IsPrivate,
+		AgentID:     m.AgentID,
+		Role:        string(m.Role),
+		ActorID:     actorID,
+	}))
+}
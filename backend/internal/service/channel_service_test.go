@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+func TestNormalizeChannelName(t *testing.T) {
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{"#Project-X", "project-x", false},
+		{"  发布_2025 ", "发布_2025", false},
+		{"-leading", "", true},
+		{"has space", "", true},
+		{"", "", true},
+		{strings.Repeat("a", 51), "", true},
+	}
+	for _, tt := range tests {
+		got, err := normalizeChannelName(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("normalizeChannelName(%q) = %q, %v; want %q, err=%v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// memChannelRepo 内存版 ChannelRepo，仅实现成员相关逻辑
+type memChannelRepo struct {
+	repository.ChannelRepo
+	channels map[string]*domain.Channel
+	members  map[string][]*domain.ChannelMember
+}
+
+func (r *memChannelRepo) GetByID(_ context.Context, id string) (*domain.Channel, error) {
+	return r.channels[id], nil
+}
+
+func (r *memChannelRepo) GetMember(_ context.Context, channelID, agentID string) (*domain.ChannelMember, error) {
+	for _, m := range r.members[channelID] {
+		if m.AgentID == agentID {
+			return m, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memChannelRepo) ListMembers(_ context.Context, channelID string) ([]*domain.ChannelMember, error) {
+	return r.members[channelID], nil
+}
+
+func (r *memChannelRepo) RemoveMember(_ context.Context, channelID, agentID string) error {
+	kept := r.members[channelID][:0]
+	for _, m := range r.members[channelID] {
+		if m.AgentID != agentID {
+			kept = append(kept, m)
+		}
+	}
+	r.members[channelID] = kept
+	return nil
+}
+
+func TestChannelServiceMembership(t *testing.T) {
+	newRepo := func() *memChannelRepo {
+		return &memChannelRepo{
+			channels: map[string]*domain.Channel{
+				"ch": {ID: "ch", CompanyID: "c1", Name: "secret", IsPrivate: true},
+			},
+			members: map[string][]*domain.ChannelMember{
+				"ch": {
+					{ChannelID: "ch", AgentID: "owner", Role: domain.ChannelRoleOwner},
+					{ChannelID: "ch", AgentID: "member", Role: domain.ChannelRoleMember},
+				},
+			},
+		}
+	}
+	agent := func(id string, perms ...string) *domain.Agent {
+		return &domain.Agent{ID: id, CompanyID: "c1", RoleType: domain.RoleEmployee, Permissions: perms}
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		actor   *domain.Agent
+		target  string
+		wantErr string
+	}{
+		{"outsider cannot see private channel", agent("outsider"), "member", "channel not found"},
+		{"member cannot remove others", agent("member"), "owner", "permission denied"},
+		{"member can leave", agent("member"), "member", ""},
+		{"last owner cannot leave", agent("owner"), "owner", "at least one owner"},
+		{"owner removes member", agent("owner"), "member", ""},
+		{"channel:manage removes member", agent("admin", channelManagePerm), "member", ""},
+		{"missing member", agent("owner"), "nobody", "member not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepo()
+			svc := NewChannelService(repo, nil, nil)
+			err := svc.RemoveMember(ctx, tt.actor, "ch", tt.target)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if m, _ := repo.GetMember(ctx, "ch", tt.target); m != nil {
+					t.Fatalf("member %s still in channel", tt.target)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
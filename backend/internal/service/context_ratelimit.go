@@ -37,12 +37,7 @@ func (tb *TokenBucket) Allow(ctx context.Context, key string) bool {
 	tb.mu.Lock()
 	defer tb.mu.Unlock()
 
-	// 补充令牌
-	now := time.Now()
-	elapsed := now.Sub(tb.lastRefill).Seconds()
-	tb.tokens = min(tb.maxTokens, tb.tokens+elapsed*tb.refillRate)
-	tb.lastRefill = now
-
+	tb.refillLocked()
 	if tb.tokens >= 1 {
 		tb.tokens--
 		return true
@@ -50,6 +45,39 @@ func (tb *TokenBucket) Allow(ctx context.Context, key string) bool {
 	return false
 }
 
+// Peek 补充令牌后报告是否至少有一个可用令牌，不消耗；与 Take 配合可在多个桶都有余量时才一并扣减
+func (tb *TokenBucket) Peek() bool {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.refillLocked()
+	return tb.tokens >= 1
+}
+
+// Take 消耗一个令牌，调用方应先以 Peek 确认有余量
+func (tb *TokenBucket) Take() {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.tokens--
+}
+
+// LastRefill 最近一次补充令牌（即最近一次使用）的时间
+func (tb *TokenBucket) LastRefill() time.Time {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	return tb.lastRefill
+}
+
+// refillLocked 按距上次补充的时间补充令牌
+func (tb *TokenBucket) refillLocked() {
+	now := time.Now()
+	elapsed := now.Sub(tb.lastRefill).Seconds()
+	tb.tokens = min(tb.maxTokens, tb.tokens+elapsed*tb.refillRate)
+	tb.lastRefill = now
+}
+
 // Wait 等待直到有令牌可用
 func (tb *TokenBucket) Wait(ctx context.Context, key string) error {
 	for {
@@ -323,6 +323,19 @@ func TestTokenBucket_Wait(t *testing.T) {
 	}
 }
 
+func TestTokenBucket_PeekTake(t *testing.T) {
+	bucket := NewTokenBucket(1, 0.001)
+
+	// Peek 不消耗令牌
+	if !bucket.Peek() || !bucket.Peek() {
+		t.Fatal("Peek should report the available token without consuming it")
+	}
+	bucket.Take()
+	if bucket.Peek() {
+		t.Error("Peek should report an empty bucket after Take")
+	}
+}
+
 func TestRateLimiterManager(t *testing.T) {
 	config := DefaultRateLimitConfig()
 	config.GlobalRPS = 10
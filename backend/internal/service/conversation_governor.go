@@ -0,0 +1,272 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ConversationGovernorConfig AI 之间对话的预算与循环熔断配置
+type ConversationGovernorConfig struct {
+	Window          time.Duration // 轮数统计窗口
+	MaxPairTurns    int           // 两个 AI 之间私信在窗口内的最大轮数（0 表示不限）
+	MaxChannelTurns int           // 频道内自上次人类发言以来、窗口内 AI 发言的最大轮数（0 表示不限）
+	PingPongTurns   int           // 两个 AI 连续交替发言达到此轮数视为乒乓循环（0 表示不检测）
+	PingPongGap     time.Duration // 相邻两轮间隔不超过此值才计入乒乓
+	Cooldown        time.Duration // 熔断后的暂停时长；人类在该对话中发言会提前恢复
+}
+
+// DefaultConversationGovernorConfig 默认对话预算
+func DefaultConversationGovernorConfig() ConversationGovernorConfig {
+	return ConversationGovernorConfig{
+		Window:          time.Hour,
+		MaxPairTurns:    20,
+		MaxChannelTurns: 40,
+		PingPongTurns:   10,
+		PingPongGap:     3 * time.Minute,
+		Cooldown:        30 * time.Minute,
+	}
+}
+
+// ConversationVerdict 一次 AI 发言的判定结果
+type ConversationVerdict struct {
+	Allowed    bool
+	Reason     string
+	RetryAfter time.Duration
+	Tripped    bool // 本次发言触发熔断（调用方发布系统消息并通知人类，暂停期内不重复）
+}
+
+// ConversationStat 对话的当前预算使用情况
+type ConversationStat struct {
+	Key          string     `json:"key"`
+	CompanyID    string     `json:"company_id"`
+	ChannelID    string     `json:"channel_id,omitempty"`
+	Participants []string   `json:"participants"` // 窗口内发言的 AI 成员
+	Turns        int        `json:"turns"`        // 窗口内（自上次人类发言以来）的 AI 发言轮数
+	Limit        int        `json:"limit"`
+	PausedUntil  *time.Time `json:"paused_until,omitempty"`
+	Reason       string     `json:"reason,omitempty"`
+	Trips        int        `json:"trips"`
+}
+
+type aiTurn struct {
+	senderID string
+	at       time.Time
+}
+
+type conversationState struct {
+	companyID   string
+	channelID   string
+	pair        [2]string // 私信会话双方；频道会话为空
+	turns       []aiTurn  // 自上次人类发言以来的 AI 发言（按时间顺序，已裁剪到窗口内）
+	pausedUntil time.Time
+	reason      string
+	trips       int
+}
+
+// ConversationGovernor 跟踪 AI 之间的对话速率（按私信双方与频道），
+// 检测乒乓式往复并在超出预算时暂停该对话，直到冷却结束或人类发言（进程内状态）
+type ConversationGovernor struct {
+	cfg ConversationGovernorConfig
+	now func() time.Time
+
+	mu        sync.Mutex
+	convs     map[string]*conversationState
+	lastSweep time.Time
+}
+
+func NewConversationGovernor(cfg ConversationGovernorConfig) *ConversationGovernor {
+	return &ConversationGovernor{cfg: cfg, now: time.Now, convs: make(map[string]*conversationState)}
+}
+
+// pairConversationKey 私信会话键，与发送方向无关
+func pairConversationKey(a, b string) string {
+	if b < a {
+		a, b = b, a
+	}
+	return "dm:" + a + "|" + b
+}
+
+func channelConversationKey(channelID string) string {
+	return "ch:" + channelID
+}
+
+func (g *ConversationGovernor) state(key, companyID string) *conversationState {
+	g.sweep(g.now())
+	st, ok := g.convs[key]
+	if !ok {
+		st = &conversationState{companyID: companyID}
+		g.convs[key] = st
+	}
+	return st
+}
+
+// CheckPair 判定 AI 向另一个 AI 发送私信；放行时计入该会话
+func (g *ConversationGovernor) CheckPair(companyID, senderID, receiverID string) ConversationVerdict {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	st := g.state(pairConversationKey(senderID, receiverID), companyID)
+	st.pair = [2]string{senderID, receiverID}
+	return g.check(st, senderID, g.cfg.MaxPairTurns, "这两位 AI 成员之间的私信")
+}
+
+// CheckChannel 判定 AI 在频道中发言；放行时计入该频道
+func (g *ConversationGovernor) CheckChannel(companyID, channelID, senderID string) ConversationVerdict {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	st := g.state(channelConversationKey(channelID), companyID)
+	st.channelID = channelID
+	return g.check(st, senderID, g.cfg.MaxChannelTurns, "本频道中 AI 成员的发言")
+}
+
+func (g *ConversationGovernor) check(st *conversationState, senderID string, limit int, scope string) ConversationVerdict {
+	now := g.now()
+	if now.Before(st.pausedUntil) {
+		return ConversationVerdict{
+			Reason:     fmt.Sprintf("对话已暂停：%s", st.reason),
+			RetryAfter: st.pausedUntil.Sub(now),
+		}
+	}
+
+	turns := st.turns[:0]
+	for _, t := range st.turns {
+		if now.Sub(t.at) <= g.cfg.Window {
+			turns = append(turns, t)
+		}
+	}
+	st.turns = turns
+
+	var reason string
+	switch {
+	case limit > 0 && len(st.turns) >= limit:
+		reason = fmt.Sprintf("%s在 %d 分钟内已达 %d 轮且没有人类参与", scope, int(g.cfg.Window.Minutes()), limit)
+	case g.cfg.PingPongTurns > 0 && pingPongLength(append(st.turns, aiTurn{senderID, now}), g.cfg.PingPongGap) >= g.cfg.PingPongTurns:
+		reason = fmt.Sprintf("检测到两位 AI 成员连续交替回复 %d 轮，疑似陷入往复循环", g.cfg.PingPongTurns)
+	}
+	if reason != "" {
+		// 冷却结束后预算重新计算
+		st.pausedUntil = now.Add(g.cfg.Cooldown)
+		st.reason = reason
+		st.trips++
+		st.turns = nil
+		return ConversationVerdict{Reason: "对话已暂停：" + reason, RetryAfter: g.cfg.Cooldown, Tripped: true}
+	}
+
+	st.turns = append(st.turns, aiTurn{senderID: senderID, at: now})
+	return ConversationVerdict{Allowed: true}
+}
+
+// pingPongLength 末尾由两个发送者严格交替、且相邻间隔不超过 gap 的连续轮数
+func pingPongLength(turns []aiTurn, gap time.Duration) int {
+	n := len(turns)
+	if n < 2 {
+		return n
+	}
+	a, b := turns[n-1].senderID, turns[n-2].senderID
+	if a == b || turns[n-1].at.Sub(turns[n-2].at) > gap {
+		return 1
+	}
+	length := 2
+	for i := n - 3; i >= 0; i-- {
+		want := a
+		if (n-1-i)%2 == 1 {
+			want = b
+		}
+		if turns[i].senderID != want || turns[i+1].at.Sub(turns[i].at) > gap {
+			break
+		}
+		length++
+	}
+	return length
+}
+
+// sweep 清理窗口外且未暂停的对话，避免状态无限增长；每个窗口最多执行一次
+func (g *ConversationGovernor) sweep(now time.Time) {
+	if now.Sub(g.lastSweep) < g.cfg.Window {
+		return
+	}
+	g.lastSweep = now
+	for key, st := range g.convs {
+		if now.Before(st.pausedUntil) {
+			continue
+		}
+		if n := len(st.turns); n == 0 || now.Sub(st.turns[n-1].at) > g.cfg.Window {
+			delete(g.convs, key)
+		}
+	}
+}
+
+// HumanInChannel 人类在频道中发言：重置该频道的 AI 轮数并解除暂停
+func (g *ConversationGovernor) HumanInChannel(channelID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.convs, channelConversationKey(channelID))
+}
+
+// HumanToAgent 人类私信某个 AI：重置该 AI 参与的全部 AI 私信会话
+func (g *ConversationGovernor) HumanToAgent(agentID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for key, st := range g.convs {
+		if st.pair[0] == agentID || st.pair[1] == agentID {
+			delete(g.convs, key)
+		}
+	}
+}
+
+// Resume 人工恢复被暂停的对话，返回对话是否存在
+func (g *ConversationGovernor) Resume(companyID, key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	st, ok := g.convs[key]
+	if !ok || st.companyID != companyID {
+		return false
+	}
+	delete(g.convs, key)
+	return true
+}
+
+// Stats 公司内仍在窗口内或处于暂停中的对话，暂停的排在前面
+func (g *ConversationGovernor) Stats(companyID string) []*ConversationStat {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	var out []*ConversationStat
+	for key, st := range g.convs {
+		if st.companyID != companyID {
+			continue
+		}
+		stat := &ConversationStat{Key: key, CompanyID: st.companyID, ChannelID: st.channelID, Trips: st.trips, Limit: g.cfg.MaxChannelTurns}
+		if st.channelID == "" {
+			stat.Limit = g.cfg.MaxPairTurns
+		}
+		seen := map[string]bool{}
+		for _, t := range st.turns {
+			if now.Sub(t.at) > g.cfg.Window {
+				continue
+			}
+			stat.Turns++
+			if !seen[t.senderID] {
+				seen[t.senderID] = true
+				stat.Participants = append(stat.Participants, t.senderID)
+			}
+		}
+		if now.Before(st.pausedUntil) {
+			until := st.pausedUntil
+			stat.PausedUntil = &until
+			stat.Reason = st.reason
+		}
+		if stat.Turns == 0 && stat.PausedUntil == nil {
+			continue
+		}
+		out = append(out, stat)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if (out[i].PausedUntil != nil) != (out[j].PausedUntil != nil) {
+			return out[i].PausedUntil != nil
+		}
+		return out[i].Turns > out[j].Turns
+	})
+	return out
+}
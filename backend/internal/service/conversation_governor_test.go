@@ -0,0 +1,117 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestGovernor(cfg ConversationGovernorConfig) (*ConversationGovernor, *time.Time) {
+	g := NewConversationGovernor(cfg)
+	now := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	g.now = func() time.Time { return now }
+	return g, &now
+}
+
+func TestConversationGovernorPairCap(t *testing.T) {
+	cfg := DefaultConversationGovernorConfig()
+	cfg.MaxPairTurns, cfg.PingPongTurns = 3, 0
+	g, now := newTestGovernor(cfg)
+
+	for i := 0; i < 3; i++ {
+		if v := g.CheckPair("c", "a", "b"); !v.Allowed {
+			t.Fatalf("turn %d rejected: %s", i+1, v.Reason)
+		}
+		*now = now.Add(time.Minute)
+	}
+	v := g.CheckPair("c", "b", "a")
+	if v.Allowed || !v.Tripped {
+		t.Fatalf("4th turn should trip the cap, got %+v", v)
+	}
+	if v = g.CheckPair("c", "a", "b"); v.Allowed || v.Tripped {
+		t.Fatalf("paused conversation should reject without re-tripping, got %+v", v)
+	}
+
+	*now = now.Add(cfg.Cooldown)
+	if v = g.CheckPair("c", "a", "b"); !v.Allowed {
+		t.Fatalf("conversation should resume after cooldown, got %+v", v)
+	}
+}
+
+func TestConversationGovernorPingPong(t *testing.T) {
+	cfg := DefaultConversationGovernorConfig()
+	cfg.MaxChannelTurns, cfg.PingPongTurns = 0, 4
+	g, now := newTestGovernor(cfg)
+
+	senders := []string{"a", "b", "a"}
+	for _, s := range senders {
+		if v := g.CheckChannel("c", "ch1", s); !v.Allowed {
+			t.Fatalf("turn by %s rejected: %s", s, v.Reason)
+		}
+		*now = now.Add(10 * time.Second)
+	}
+	if v := g.CheckChannel("c", "ch1", "b"); !v.Tripped {
+		t.Fatalf("4th alternating turn should trip, got %+v", v)
+	}
+}
+
+func TestConversationGovernorHumanResets(t *testing.T) {
+	cfg := DefaultConversationGovernorConfig()
+	cfg.MaxChannelTurns, cfg.MaxPairTurns, cfg.PingPongTurns = 1, 1, 0
+	g, _ := newTestGovernor(cfg)
+
+	g.CheckChannel("c", "ch1", "a")
+	if v := g.CheckChannel("c", "ch1", "a"); !v.Tripped {
+		t.Fatalf("expected channel trip, got %+v", v)
+	}
+	g.HumanInChannel("ch1")
+	if v := g.CheckChannel("c", "ch1", "a"); !v.Allowed {
+		t.Fatalf("human message should reset the channel, got %+v", v)
+	}
+
+	g.CheckPair("c", "a", "b")
+	if v := g.CheckPair("c", "b", "a"); !v.Tripped {
+		t.Fatalf("expected pair trip, got %+v", v)
+	}
+	if stats := g.Stats("c"); len(stats) == 0 || stats[0].PausedUntil == nil || stats[0].Key != pairConversationKey("a", "b") {
+		t.Fatalf("paused pair should be listed first, got %+v", stats)
+	}
+	g.HumanToAgent("b")
+	if v := g.CheckPair("c", "a", "b"); !v.Allowed {
+		t.Fatalf("human DM to a participant should reset the pair, got %+v", v)
+	}
+	if g.Resume("other", channelConversationKey("ch1")) {
+		t.Fatal("resume must not cross companies")
+	}
+}
+
+func TestPingPongLength(t *testing.T) {
+	base := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	turns := func(gaps []time.Duration, senders ...string) []aiTurn {
+		out := make([]aiTurn, len(senders))
+		at := base
+		for i, s := range senders {
+			if i > 0 {
+				at = at.Add(gaps[i-1])
+			}
+			out[i] = aiTurn{senderID: s, at: at}
+		}
+		return out
+	}
+	m := time.Minute
+	tests := []struct {
+		name  string
+		turns []aiTurn
+		want  int
+	}{
+		{"empty", nil, 0},
+		{"alternating", turns([]time.Duration{m, m, m}, "a", "b", "a", "b"), 4},
+		{"third party breaks", turns([]time.Duration{m, m, m}, "c", "b", "a", "b"), 3},
+		{"same sender twice", turns([]time.Duration{m, m}, "a", "b", "b"), 1},
+		{"long gap breaks", turns([]time.Duration{m, 10 * m, m}, "a", "b", "a", "b"), 2},
+	}
+	for _, tt := range tests {
+		if got := pingPongLength(tt.turns, 3*m); got != tt.want {
+			t.Errorf("%s: pingPongLength = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
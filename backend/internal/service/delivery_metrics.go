@@ -0,0 +1,89 @@
+package service
+
+import "sync"
+
+// DeliveryMetrics Agent 投递指标收集器（进程内累计）
+type DeliveryMetrics struct {
+	mu sync.RWMutex
+
+	enqueued    int64
+	delivered   int64
+	redelivered int64
+	acked       int64
+
+	// 发送缓冲区满导致的丢弃次数（丢弃的投递仍在日志中，会按退避重投）
+	dropped        int64
+	droppedByAgent map[string]int64
+
+	// 事件队列满导致未写入投递日志的事件数（消息由未读补投兜底）
+	overflowed int64
+}
+
+// DeliveryMetricsSnapshot 指标快照
+type DeliveryMetricsSnapshot struct {
+	Enqueued       int64            `json:"enqueued"`
+	Delivered      int64            `json:"delivered"`
+	Redelivered    int64            `json:"redelivered"`
+	Acked          int64            `json:"acked"`
+	Dropped        int64            `json:"dropped"`
+	DroppedByAgent map[string]int64 `json:"dropped_by_agent"`
+	Overflowed     int64            `json:"overflowed"`
+}
+
+func NewDeliveryMetrics() *DeliveryMetrics {
+	return &DeliveryMetrics{droppedByAgent: make(map[string]int64)}
+}
+
+func (m *DeliveryMetrics) RecordEnqueued() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enqueued++
+}
+
+// RecordDelivered 记录一次成功推送；redelivery 表示非首次推送
+func (m *DeliveryMetrics) RecordDelivered(redelivery bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.delivered++
+	if redelivery {
+		m.redelivered++
+	}
+}
+
+func (m *DeliveryMetrics) RecordAcked(n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.acked += n
+}
+
+func (m *DeliveryMetrics) RecordDropped(agentID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dropped++
+	m.droppedByAgent[agentID]++
+}
+
+func (m *DeliveryMetrics) RecordOverflow() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.overflowed++
+}
+
+// GetSnapshot 获取指标快照
+func (m *DeliveryMetrics) GetSnapshot() *DeliveryMetricsSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	byAgent := make(map[string]int64, len(m.droppedByAgent))
+	for id, n := range m.droppedByAgent {
+		byAgent[id] = n
+	}
+	return &DeliveryMetricsSnapshot{
+		Enqueued:       m.enqueued,
+		Delivered:      m.delivered,
+		Redelivered:    m.redelivered,
+		Acked:          m.acked,
+		Dropped:        m.dropped,
+		DroppedByAgent: byAgent,
+		Overflowed:     m.overflowed,
+	}
+}
@@ -0,0 +1,721 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/event"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+const (
+	digestBatch           = 20
+	digestDefaultLookback = 24 * time.Hour     // 首次摘要的统计范围
+	digestMaxLookback     = 7 * 24 * time.Hour // 长期未发送时最多回看的范围
+	digestListLimit       = 30
+	digestItemLimit       = 50  // 任务 / 审批每类最多纳入的条数
+	digestMessageRunes    = 500 // 单条消息送入 LLM 的最大长度
+	digestPromptBytes     = 60000
+	maxSummarizeHours     = 7 * 24
+)
+
+// ErrNothingToDigest 统计区间内没有新的消息、任务或审批
+var ErrNothingToDigest = errors.New("nothing to digest")
+
+const digestSystemPrompt = `你是团队协作平台的助理，负责把大量消息整理成简洁的中文摘要，帮助读者快速了解错过的内容。
+要求：
+1. 只依据提供的内容，不要编造或推测
+2. 优先列出需要读者本人处理的事项：@提及、私信、待审批、分配给读者的任务
+3. 其次按频道归纳关键结论、决定、风险与进展，省略寒暄和重复内容
+4. 人名、频道名、任务标题保持原样
+5. 使用 Markdown 小标题和列表，总长度不超过 500 字`
+
+// DigestConfig 摘要任务参数
+type DigestConfig struct {
+	DefaultCron     string
+	DefaultTimezone string
+	MaxMessages     int
+	PollInterval    time.Duration
+}
+
+// digestCompleter 调用公司 LLM 网关生成文本
+type digestCompleter func(ctx context.Context, systemPrompt, userPrompt string) (string, error)
+
+// DigestService 为人类用户定期汇总未读频道消息、任务进展与审批，经 LLM 生成摘要后
+// 以系统私信或 digest.created webhook 投递；同时提供按需的频道摘要
+type DigestService struct {
+	repo         repository.DigestRepo
+	messageSvc   *MessageService
+	approvalRepo repository.ApprovalRepo
+	complete     digestCompleter
+	cfg          DigestConfig
+}
+
+func NewDigestService(repo repository.DigestRepo, messageSvc *MessageService, approvalRepo repository.ApprovalRepo, llmClient *ContextLLMClient, cfg DigestConfig) *DigestService {
+	if cfg.DefaultCron == "" {
+		cfg.DefaultCron = "0 9 * * *"
+	}
+	if cfg.DefaultTimezone == "" {
+		cfg.DefaultTimezone = "UTC"
+	}
+	if cfg.MaxMessages <= 0 {
+		cfg.MaxMessages = 300
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Minute
+	}
+	return &DigestService{
+		repo:         repo,
+		messageSvc:   messageSvc,
+		approvalRepo: approvalRepo,
+		complete: func(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+			return llmClient.callLLM(ctx, systemPrompt, userPrompt, "")
+		},
+		cfg: cfg,
+	}
+}
+
+// ── 订阅设置 ─────────────────────────────────────────────
+
+// GetSettings 返回本人的摘要设置；尚未保存过时返回默认值（人类用户默认订阅）
+func (s *DigestService) GetSettings(ctx context.Context, actor *domain.Agent) (*domain.DigestSettings, error) {
+	st, err := s.repo.GetSettings(ctx, actor.ID)
+	if err != nil {
+		return nil, err
+	}
+	if st == nil {
+		st = s.defaultSettings(actor)
+	}
+	return st, nil
+}
+
+func (s *DigestService) defaultSettings(actor *domain.Agent) *domain.DigestSettings {
+	return &domain.DigestSettings{
+		AgentID:          actor.ID,
+		CompanyID:        actor.CompanyID,
+		Enabled:          actor.IsHuman,
+		Cron:             s.cfg.DefaultCron,
+		Timezone:         s.cfg.DefaultTimezone,
+		Delivery:         domain.DigestDeliveryDM,
+		ChannelIDs:       domain.StringList{},
+		IncludeDMs:       true,
+		IncludeTasks:     true,
+		IncludeApprovals: true,
+	}
+}
+
+// DigestSettingsInput 修改摘要设置；为 nil 的字段保持不变
+type DigestSettingsInput struct {
+	Enabled          *bool                  `json:"enabled"`
+	Cron             *string                `json:"cron"`
+	Timezone         *string                `json:"timezone"`
+	Delivery         *domain.DigestDelivery `json:"delivery"`
+	ChannelIDs       *[]string              `json:"channel_ids"`
+	IncludeDMs       *bool                  `json:"include_dms"`
+	IncludeTasks     *bool                  `json:"include_tasks"`
+	IncludeApprovals *bool                  `json:"include_approvals"`
+}
+
+func (s *DigestService) UpdateSettings(ctx context.Context, actor *domain.Agent, in DigestSettingsInput) (*domain.DigestSettings, error) {
+	st, err := s.GetSettings(ctx, actor)
+	if err != nil {
+		return nil, err
+	}
+	if in.Enabled != nil {
+		st.Enabled = *in.Enabled
+	}
+	if in.Cron != nil {
+		st.Cron = strings.TrimSpace(*in.Cron)
+	}
+	if in.Timezone != nil {
+		st.Timezone = strings.TrimSpace(*in.Timezone)
+	}
+	if in.Delivery != nil {
+		st.Delivery = *in.Delivery
+	}
+	if in.IncludeDMs != nil {
+		st.IncludeDMs = *in.IncludeDMs
+	}
+	if in.IncludeTasks != nil {
+		st.IncludeTasks = *in.IncludeTasks
+	}
+	if in.IncludeApprovals != nil {
+		st.IncludeApprovals = *in.IncludeApprovals
+	}
+	if in.ChannelIDs != nil {
+		ids, err := s.visibleChannelIDs(ctx, actor, *in.ChannelIDs)
+		if err != nil {
+			return nil, err
+		}
+		st.ChannelIDs = ids
+	}
+
+	switch st.Delivery {
+	case domain.DigestDeliveryDM, domain.DigestDeliveryWebhook:
+	default:
+		return nil, fmt.Errorf("invalid delivery %q", st.Delivery)
+	}
+	next, err := nextDigestRun(st.Cron, st.Timezone, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	st.NextRunAt = nil
+	if st.Enabled {
+		st.NextRunAt = &next
+	}
+	if err := s.repo.UpsertSettings(ctx, st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// visibleChannelIDs 校验摘要范围内的频道均对本人可见
+func (s *DigestService) visibleChannelIDs(ctx context.Context, actor *domain.Agent, ids []string) (domain.StringList, error) {
+	channels, err := s.messageSvc.channelRepo.List(ctx, actor.CompanyID, actor.ID, true)
+	if err != nil {
+		return nil, err
+	}
+	visible := make(map[string]bool, len(channels))
+	for _, ch := range channels {
+		visible[ch.ID] = true
+	}
+	out := make(domain.StringList, 0, len(ids))
+	seen := map[string]bool{}
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		if !visible[id] {
+			return nil, fmt.Errorf("channel %s not found", id)
+		}
+		seen[id] = true
+		out = append(out, id)
+	}
+	return out, nil
+}
+
+// nextDigestRun 校验 cron 与时区并计算下次发送时间
+func nextDigestRun(cron, timezone string, now time.Time) (time.Time, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timezone %q", timezone)
+	}
+	sched, err := ParseCron(cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := sched.Next(now.In(loc))
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("cron %q never fires", cron)
+	}
+	return next, nil
+}
+
+// ── 摘要生成 ─────────────────────────────────────────────
+
+// Generate 立即为本人生成并投递一次摘要，统计区间从上次摘要开始
+func (s *DigestService) Generate(ctx context.Context, actor *domain.Agent) (*domain.Digest, error) {
+	st, err := s.GetSettings(ctx, actor)
+	if err != nil {
+		return nil, err
+	}
+	d, err := s.run(ctx, actor, st, time.Now())
+	if errors.Is(err, ErrNothingToDigest) {
+		return nil, fmt.Errorf("nothing new since %s", digestPeriodStart(st.LastRunAt, time.Now()).Format(time.RFC3339))
+	}
+	return d, err
+}
+
+func (s *DigestService) List(ctx context.Context, actor *domain.Agent) ([]*domain.Digest, error) {
+	return s.repo.List(ctx, actor.ID, digestListLimit)
+}
+
+func (s *DigestService) Get(ctx context.Context, actor *domain.Agent, id string) (*domain.Digest, error) {
+	d, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil || d.AgentID != actor.ID {
+		return nil, fmt.Errorf("digest not found")
+	}
+	return d, nil
+}
+
+// DeleteForAgent agent 被删除后清理其订阅设置与历史摘要
+func (s *DigestService) DeleteForAgent(ctx context.Context, agentID string) error {
+	return s.repo.DeleteByAgent(ctx, agentID)
+}
+
+// Run 定期为到期的订阅生成摘要，直到 ctx 结束
+func (s *DigestService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.runDue(ctx, time.Now()); err != nil {
+				log.Printf("[digest] %v", err)
+			}
+		}
+	}
+}
+
+func (s *DigestService) runDue(ctx context.Context, now time.Time) error {
+	// 新加入的人类用户按默认设置订阅
+	if next, err := nextDigestRun(s.cfg.DefaultCron, s.cfg.DefaultTimezone, now); err == nil {
+		if _, err := s.repo.EnrollHumans(ctx, s.cfg.DefaultCron, s.cfg.DefaultTimezone, next); err != nil {
+			log.Printf("[digest] enroll: %v", err)
+		}
+	} else {
+		log.Printf("[digest] default schedule: %v", err)
+	}
+
+	due, err := s.repo.ListDue(ctx, now, digestBatch)
+	if err != nil {
+		return err
+	}
+	for _, st := range due {
+		// 先推进下次发送时间：抢占失败说明其他实例已处理；错过的多次只补一次
+		var next *time.Time
+		if n, err := nextDigestRun(st.Cron, st.Timezone, now); err == nil {
+			next = &n
+		}
+		ok, err := s.repo.Advance(ctx, st.AgentID, *st.NextRunAt, next)
+		if err != nil || !ok {
+			continue
+		}
+
+		owner, err := s.messageSvc.agentRepo.GetByID(ctx, st.AgentID)
+		if err != nil || owner == nil {
+			continue
+		}
+		if _, err := s.run(ctx, owner, st, now); err != nil && !errors.Is(err, ErrNothingToDigest) {
+			log.Printf("[digest] %s: %v", st.AgentID, err)
+		}
+	}
+	return nil
+}
+
+// digestSource 一次摘要的原始素材
+type digestSource struct {
+	messages  []*domain.Message
+	tasks     []*domain.Task
+	approvals []*domain.ApprovalRequest
+	agents    map[string]string // agentID → 名字
+	channels  map[string]string // channelID → 名字
+}
+
+func (src *digestSource) empty() bool {
+	return len(src.messages) == 0 && len(src.tasks) == 0 && len(src.approvals) == 0
+}
+
+// digestPeriodStart 统计起点：上次摘要结束处，首次为最近 24 小时，最多回看 7 天
+func digestPeriodStart(lastRunAt *time.Time, now time.Time) time.Time {
+	if lastRunAt == nil {
+		return now.Add(-digestDefaultLookback)
+	}
+	if earliest := now.Add(-digestMaxLookback); lastRunAt.Before(earliest) {
+		return earliest
+	}
+	return *lastRunAt
+}
+
+// run 收集素材、生成并投递摘要；成功或无内容时推进统计起点
+func (s *DigestService) run(ctx context.Context, owner *domain.Agent, st *domain.DigestSettings, now time.Time) (*domain.Digest, error) {
+	from := digestPeriodStart(st.LastRunAt, now)
+	src, err := s.collect(ctx, owner, st, from, now)
+	if err != nil {
+		_ = s.repo.SetResult(ctx, owner.ID, nil, err.Error())
+		return nil, err
+	}
+	if src.empty() {
+		_ = s.repo.SetResult(ctx, owner.ID, &now, "")
+		return nil, ErrNothingToDigest
+	}
+
+	d := &domain.Digest{
+		CompanyID:     owner.CompanyID,
+		AgentID:       owner.ID,
+		PeriodStart:   from,
+		PeriodEnd:     now,
+		MessageCount:  len(src.messages),
+		TaskCount:     len(src.tasks),
+		ApprovalCount: len(src.approvals),
+		Delivery:      st.Delivery,
+	}
+	summary, err := s.complete(ctx, digestSystemPrompt, s.digestPrompt(owner, src, from, now))
+	summary = strings.TrimSpace(summary)
+	if err != nil || summary == "" {
+		// LLM 不可用时退化为统计摘要，不影响投递
+		if err != nil {
+			d.LLMError = err.Error()
+			log.Printf("[digest] %s: llm: %v", owner.ID, err)
+		}
+		summary = fallbackDigest(owner, src)
+	}
+	d.Content = digestHeader(from, now, st.Timezone) + "\n\n" + summary
+
+	if err := s.deliver(ctx, owner, d); err != nil {
+		_ = s.repo.SetResult(ctx, owner.ID, nil, err.Error())
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, d); err != nil {
+		log.Printf("[digest] %s: save: %v", owner.ID, err)
+	}
+	if err := s.repo.SetResult(ctx, owner.ID, &now, ""); err != nil {
+		log.Printf("[digest] %s: %v", owner.ID, err)
+	}
+	return d, nil
+}
+
+func (s *DigestService) collect(ctx context.Context, owner *domain.Agent, st *domain.DigestSettings, from, to time.Time) (*digestSource, error) {
+	src := &digestSource{agents: map[string]string{}, channels: map[string]string{}}
+
+	msgs, err := s.messageSvc.messageRepo.Search(ctx, repository.MessageQuery{
+		CompanyID:  owner.CompanyID,
+		ViewerID:   owner.ID,
+		ChannelIDs: st.ChannelIDs,
+		ExcludeDMs: !st.IncludeDMs,
+		UnreadOnly: true,
+		After:      &from,
+		Before:     &to,
+		Limit:      s.cfg.MaxMessages,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Search 按时间倒序返回最新的，摘要按时间正序阅读
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+	src.messages = msgs
+
+	if st.IncludeTasks {
+		if src.tasks, err = s.touchedTasks(ctx, owner, from); err != nil {
+			return nil, err
+		}
+	}
+	if st.IncludeApprovals {
+		if src.approvals, err = s.relevantApprovals(ctx, owner, from); err != nil {
+			return nil, err
+		}
+	}
+	if src.empty() {
+		return src, nil
+	}
+
+	if agents, err := s.messageSvc.agentRepo.GetByCompany(ctx, owner.CompanyID); err == nil {
+		for _, a := range agents {
+			src.agents[a.ID] = a.Name
+		}
+	}
+	if channels, err := s.messageSvc.channelRepo.List(ctx, owner.CompanyID, owner.ID, true); err == nil {
+		for _, ch := range channels {
+			src.channels[ch.ID] = ch.Name
+		}
+	}
+	return src, nil
+}
+
+// touchedTasks 区间内有更新、且本人负责 / 创建 / 关注的任务
+func (s *DigestService) touchedTasks(ctx context.Context, owner *domain.Agent, since time.Time) ([]*domain.Task, error) {
+	all := ""
+	base := repository.TaskQuery{CompanyID: owner.CompanyID, ParentID: &all, UpdatedAfter: &since, Limit: digestItemLimit}
+	seen := map[string]bool{}
+	var out []*domain.Task
+	queries := []func(q *repository.TaskQuery){
+		func(q *repository.TaskQuery) { q.AssigneeID = owner.ID },
+		func(q *repository.TaskQuery) { q.CreatedBy = owner.ID },
+		func(q *repository.TaskQuery) { q.WatcherID = owner.ID },
+	}
+	for _, apply := range queries {
+		q := base
+		apply(&q)
+		page, err := s.messageSvc.taskRepo.Search(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range page.Tasks {
+			if !seen[t.ID] {
+				seen[t.ID] = true
+				out = append(out, t)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+	if len(out) > digestItemLimit {
+		out = out[:digestItemLimit]
+	}
+	return out, nil
+}
+
+// relevantApprovals 董事长的待审批事项，以及本人提交、区间内已有结果的申请
+func (s *DigestService) relevantApprovals(ctx context.Context, owner *domain.Agent, since time.Time) ([]*domain.ApprovalRequest, error) {
+	var out []*domain.ApprovalRequest
+	if owner.RoleType == domain.RoleChairman {
+		pending, _, err := s.approvalRepo.List(ctx, repository.ApprovalQuery{
+			CompanyID: owner.CompanyID, Status: domain.ApprovalPending, Limit: digestItemLimit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, pending...)
+	}
+	mine, _, err := s.approvalRepo.List(ctx, repository.ApprovalQuery{
+		CompanyID: owner.CompanyID, RequesterID: owner.ID, Limit: digestItemLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range mine {
+		if r.DecidedAt != nil && !r.DecidedAt.Before(since) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// digestPrompt 把素材整理为送入 LLM 的文本，超长时截断最早的消息
+func (s *DigestService) digestPrompt(owner *domain.Agent, src *digestSource, from, to time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "读者：%s（ID %s）\n统计区间：%s 至 %s\n\n",
+		owner.Name, owner.ID, from.Format(time.RFC3339), to.Format(time.RFC3339))
+
+	if len(src.approvals) > 0 {
+		b.WriteString("## 审批\n")
+		for _, r := range src.approvals {
+			fmt.Fprintf(&b, "- [%s] %s，申请人 %s：%s\n", r.Status, r.RequestType, src.name(r.RequesterID), excerpt(r.Reason, 200))
+		}
+		b.WriteString("\n")
+	}
+	if len(src.tasks) > 0 {
+		b.WriteString("## 任务更新\n")
+		for _, t := range src.tasks {
+			assignee := "未分配"
+			if t.AssigneeID != nil {
+				assignee = src.name(*t.AssigneeID)
+			}
+			fmt.Fprintf(&b, "- 「%s」状态 %s，优先级 %s，负责人 %s\n", t.Title, t.Status, t.Priority, assignee)
+		}
+		b.WriteString("\n")
+	}
+	if len(src.messages) > 0 {
+		fmt.Fprintf(&b, "## 未读消息（%d 条", len(src.messages))
+		if len(src.messages) >= s.cfg.MaxMessages {
+			b.WriteString("，仅最近的部分")
+		}
+		b.WriteString("）\n")
+		b.WriteString(truncateTranscript(src.transcript(owner.ID), digestPromptBytes-b.Len()))
+	}
+	return b.String()
+}
+
+func (src *digestSource) name(agentID string) string {
+	if n, ok := src.agents[agentID]; ok {
+		return n
+	}
+	return agentID
+}
+
+// transcript 每条消息一行："[01-02 15:04] #频道 发送者: 内容"，私信标为「私信」
+func (src *digestSource) transcript(ownerID string) []string {
+	lines := make([]string, 0, len(src.messages))
+	for _, m := range src.messages {
+		where := "私信"
+		if m.ChannelID != nil {
+			where = "#" + src.channels[*m.ChannelID]
+		}
+		sender := "系统"
+		if m.SenderID != nil {
+			sender = src.name(*m.SenderID)
+		}
+		if m.Mentioned(ownerID) {
+			where += "（@你）"
+		}
+		if m.ThreadRootID != nil {
+			where += "（线程回复）"
+		}
+		content := strings.ReplaceAll(excerpt(m.Content, digestMessageRunes), "\n", " ")
+		lines = append(lines, fmt.Sprintf("[%s] %s %s: %s", m.CreatedAt.Format("01-02 15:04"), where, sender, content))
+	}
+	return lines
+}
+
+// truncateTranscript 保留最新的消息，使总长度不超过 maxBytes
+func truncateTranscript(lines []string, maxBytes int) string {
+	total, start := 0, len(lines)
+	for start > 0 && total+len(lines[start-1])+1 <= maxBytes {
+		start--
+		total += len(lines[start]) + 1
+	}
+	var b strings.Builder
+	if start > 0 {
+		fmt.Fprintf(&b, "（更早的 %d 条消息因篇幅省略）\n", start)
+	}
+	for _, l := range lines[start:] {
+		b.WriteString(l)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// fallbackDigest LLM 不可用时的统计摘要
+func fallbackDigest(owner *domain.Agent, src *digestSource) string {
+	var b strings.Builder
+	if len(src.messages) > 0 {
+		perChannel := map[string]int{}
+		dms, mentions := 0, 0
+		for _, m := range src.messages {
+			if m.ChannelID == nil {
+				dms++
+			} else {
+				perChannel[src.channels[*m.ChannelID]]++
+			}
+			if m.Mentioned(owner.ID) {
+				mentions++
+			}
+		}
+		fmt.Fprintf(&b, "**未读消息 %d 条**（私信 %d 条，@你 %d 条）\n", len(src.messages), dms, mentions)
+		names := make([]string, 0, len(perChannel))
+		for n := range perChannel {
+			names = append(names, n)
+		}
+		sort.Slice(names, func(i, j int) bool { return perChannel[names[i]] > perChannel[names[j]] })
+		for _, n := range names {
+			fmt.Fprintf(&b, "- #%s：%d 条\n", n, perChannel[n])
+		}
+		b.WriteString("\n")
+	}
+	if len(src.tasks) > 0 {
+		fmt.Fprintf(&b, "**任务更新 %d 个**\n", len(src.tasks))
+		for _, t := range src.tasks {
+			fmt.Fprintf(&b, "- 「%s」%s\n", t.Title, t.Status)
+		}
+		b.WriteString("\n")
+	}
+	if len(src.approvals) > 0 {
+		fmt.Fprintf(&b, "**审批 %d 项**\n", len(src.approvals))
+		for _, r := range src.approvals {
+			fmt.Fprintf(&b, "- %s（%s）：%s\n", r.RequestType, r.Status, excerpt(r.Reason, 60))
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func digestHeader(from, to time.Time, timezone string) string {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return fmt.Sprintf("📰 **摘要** %s ～ %s", from.In(loc).Format("01-02 15:04"), to.In(loc).Format("01-02 15:04 MST"))
+}
+
+// deliver 私信投递后标记为已读，避免被下一次摘要重复收录
+func (s *DigestService) deliver(ctx context.Context, owner *domain.Agent, d *domain.Digest) error {
+	switch d.Delivery {
+	case domain.DigestDeliveryWebhook:
+		d.ID = uuid.New().String()
+		event.Global.Publish(event.NewEvent(event.DigestCreated, event.DigestCreatedPayload{
+			DigestID:      d.ID,
+			CompanyID:     d.CompanyID,
+			AgentID:       owner.ID,
+			AgentName:     owner.Name,
+			PeriodStart:   d.PeriodStart,
+			PeriodEnd:     d.PeriodEnd,
+			Content:       d.Content,
+			MessageCount:  d.MessageCount,
+			TaskCount:     d.TaskCount,
+			ApprovalCount: d.ApprovalCount,
+		}))
+		return nil
+	default:
+		msg, err := s.messageSvc.Send(ctx, SendInput{CompanyID: owner.CompanyID, ReceiverID: owner.ID, Content: d.Content})
+		if err != nil {
+			return err
+		}
+		d.MessageID = &msg.ID
+		return s.messageSvc.MarkRead(ctx, owner.ID, []string{msg.ID})
+	}
+}
+
+// ── 频道摘要 ─────────────────────────────────────────────
+
+// ChannelSummary 按需生成的频道摘要
+type ChannelSummary struct {
+	Channel      string    `json:"channel"`
+	Since        time.Time `json:"since"`
+	MessageCount int       `json:"message_count"`
+	Summary      string    `json:"summary"`
+}
+
+// SummarizeChannel 汇总频道最近 hours 小时的消息（默认 24，最多 7 天）；focus 为可选的关注点
+func (s *DigestService) SummarizeChannel(ctx context.Context, actor *domain.Agent, channel string, hours int, focus string) (*ChannelSummary, error) {
+	if hours <= 0 {
+		hours = 24
+	}
+	if hours > maxSummarizeHours {
+		hours = maxSummarizeHours
+	}
+	ch, err := s.messageSvc.accessibleChannel(ctx, actor.CompanyID, actor.ID, strings.TrimPrefix(strings.TrimSpace(channel), "#"))
+	if err != nil {
+		return nil, err
+	}
+	since := time.Now().Add(-time.Duration(hours) * time.Hour)
+	msgs, err := s.messageSvc.messageRepo.Search(ctx, repository.MessageQuery{
+		CompanyID: actor.CompanyID,
+		ViewerID:  actor.ID,
+		ChannelID: ch.ID,
+		After:     &since,
+		Limit:     s.cfg.MaxMessages,
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := &ChannelSummary{Channel: ch.Name, Since: since, MessageCount: len(msgs)}
+	if len(msgs) == 0 {
+		return out, nil
+	}
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+
+	src := &digestSource{messages: msgs, agents: map[string]string{}, channels: map[string]string{ch.ID: ch.Name}}
+	if agents, err := s.messageSvc.agentRepo.GetByCompany(ctx, actor.CompanyID); err == nil {
+		for _, a := range agents {
+			src.agents[a.ID] = a.Name
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "读者：%s（ID %s），刚加入或需要补课\n频道：#%s", actor.Name, actor.ID, ch.Name)
+	if ch.Description != "" {
+		fmt.Fprintf(&b, "（简介：%s）", ch.Description)
+	}
+	fmt.Fprintf(&b, "\n最近 %d 小时共 %d 条消息", hours, len(msgs))
+	if len(msgs) >= s.cfg.MaxMessages {
+		b.WriteString("（仅最近的部分）")
+	}
+	b.WriteString("\n")
+	if focus = strings.TrimSpace(focus); focus != "" {
+		fmt.Fprintf(&b, "读者特别关心：%s\n", focus)
+	}
+	b.WriteString("\n")
+	b.WriteString(truncateTranscript(src.transcript(actor.ID), digestPromptBytes-b.Len()))
+
+	summary, err := s.complete(ctx, digestSystemPrompt, b.String())
+	if err != nil {
+		return nil, fmt.Errorf("summarize channel: %w", err)
+	}
+	out.Summary = strings.TrimSpace(summary)
+	return out, nil
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+func TestDigestPeriodStart(t *testing.T) {
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	if got := digestPeriodStart(nil, now); !got.Equal(now.Add(-24 * time.Hour)) {
+		t.Errorf("first digest from = %v, want 24h ago", got)
+	}
+	last := now.Add(-3 * time.Hour)
+	if got := digestPeriodStart(&last, now); !got.Equal(last) {
+		t.Errorf("from = %v, want last run %v", got, last)
+	}
+	// 长期未发送时最多回看 7 天
+	old := now.Add(-30 * 24 * time.Hour)
+	if got := digestPeriodStart(&old, now); !got.Equal(now.Add(-digestMaxLookback)) {
+		t.Errorf("from = %v, want capped at 7 days", got)
+	}
+}
+
+func TestTruncateTranscriptKeepsNewest(t *testing.T) {
+	lines := []string{"aaaa", "bbbb", "cccc"}
+	got := truncateTranscript(lines, 10)
+	if strings.Contains(got, "aaaa") || !strings.Contains(got, "bbbb") || !strings.Contains(got, "cccc") {
+		t.Errorf("got %q, want the two newest lines", got)
+	}
+	if !strings.Contains(got, "更早的 1 条") {
+		t.Errorf("got %q, want omitted count", got)
+	}
+	if got := truncateTranscript(lines, 1000); strings.Contains(got, "省略") {
+		t.Errorf("got %q, want nothing omitted", got)
+	}
+}
+
+func TestFallbackDigest(t *testing.T) {
+	general, random, sender := "ch1", "ch2", "a2"
+	owner := &domain.Agent{ID: "a1", Name: "老板"}
+	src := &digestSource{
+		messages: []*domain.Message{
+			{ChannelID: &general, SenderID: &sender, Content: "x", Mentions: domain.StringList{"a1"}},
+			{ChannelID: &general, SenderID: &sender, Content: "y"},
+			{ChannelID: &random, SenderID: &sender, Content: "z"},
+			{SenderID: &sender, ReceiverID: &owner.ID, Content: "dm"},
+		},
+		tasks:     []*domain.Task{{Title: "上线", Status: domain.TaskStatus("done")}},
+		approvals: []*domain.ApprovalRequest{{RequestType: "hire", Status: domain.ApprovalPending, Reason: "扩招"}},
+		channels:  map[string]string{general: "general", random: "random"},
+	}
+	got := fallbackDigest(owner, src)
+	for _, want := range []string{"未读消息 4 条", "私信 1 条，@你 1 条", "#general：2 条", "「上线」done", "审批 1 项"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("fallback digest missing %q:\n%s", want, got)
+		}
+	}
+	// 频道按消息数降序
+	if strings.Index(got, "#general") > strings.Index(got, "#random") {
+		t.Errorf("channels not sorted by count:\n%s", got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/linkclaw/backend/internal/domain"
 	"github.com/linkclaw/backend/internal/repository"
 )
 
@@ -13,17 +14,21 @@ const (
 	workerBatch    = 10
 )
 
-// EmbeddingWorker 后台异步生成 embedding
+// EmbeddingWorker 后台异步生成 embedding（Agent 记忆与消息历史）
 type EmbeddingWorker struct {
-	memoryRepo    repository.MemoryRepo
-	companyRepo   repository.CompanyRepo
-	embeddingCli  *EmbeddingClient
+	memoryRepo   repository.MemoryRepo
+	messageRepo  repository.MessageRepo
+	companyRepo  repository.CompanyRepo
+	embeddingCli *EmbeddingClient
+
+	messageVectors bool // messages.embedding 为 pgvector 列；否则不为消息生成 embedding
 }
 
 // NewEmbeddingWorker 创建 worker
-func NewEmbeddingWorker(memoryRepo repository.MemoryRepo, companyRepo repository.CompanyRepo, embeddingCli *EmbeddingClient) *EmbeddingWorker {
+func NewEmbeddingWorker(memoryRepo repository.MemoryRepo, messageRepo repository.MessageRepo, companyRepo repository.CompanyRepo, embeddingCli *EmbeddingClient) *EmbeddingWorker {
 	return &EmbeddingWorker{
 		memoryRepo:   memoryRepo,
+		messageRepo:  messageRepo,
 		companyRepo:  companyRepo,
 		embeddingCli: embeddingCli,
 	}
@@ -32,6 +37,15 @@ func NewEmbeddingWorker(memoryRepo repository.MemoryRepo, companyRepo repository
 // Start 启动 worker 循环（在 goroutine 中调用）
 func (w *EmbeddingWorker) Start(ctx context.Context) {
 	log.Println("embedding worker started")
+	if w.messageRepo != nil {
+		ok, err := w.messageRepo.VectorEmbeddingSupported(ctx)
+		if err != nil {
+			log.Printf("embedding worker check vector column error: %v", err)
+		} else if !ok {
+			log.Println("embedding worker: pgvector unavailable, message embeddings disabled")
+		}
+		w.messageVectors = ok
+	}
 	ticker := time.NewTicker(workerInterval)
 	defer ticker.Stop()
 
@@ -42,6 +56,7 @@ func (w *EmbeddingWorker) Start(ctx context.Context) {
 			return
 		case <-ticker.C:
 			w.process(ctx)
+			w.processMessages(ctx)
 		}
 	}
 }
@@ -74,3 +89,40 @@ func (w *EmbeddingWorker) process(ctx context.Context) {
 		}
 	}
 }
+
+// processMessages 为消息生成 embedding；失败计数达到上限后不再重试。
+// pgvector 不可用时跳过，避免对全部历史消息发起注定写入失败的付费调用
+func (w *EmbeddingWorker) processMessages(ctx context.Context) {
+	if w.messageRepo == nil || !w.messageVectors {
+		return
+	}
+	msgs, err := w.messageRepo.ListPendingEmbedding(ctx, workerBatch)
+	if err != nil {
+		log.Printf("embedding worker list messages error: %v", err)
+		return
+	}
+
+	companies := map[string]*domain.Company{}
+	for _, m := range msgs {
+		company, ok := companies[m.CompanyID]
+		if !ok {
+			if company, err = w.companyRepo.GetByID(ctx, m.CompanyID); err != nil {
+				log.Printf("embedding worker get company error (message=%s): %v", m.ID, err)
+				continue
+			}
+			companies[m.CompanyID] = company
+		}
+		if company == nil {
+			continue
+		}
+
+		vec, err := w.embeddingCli.Generate(ctx, company.EmbeddingBaseURL, company.EmbeddingModel, company.EmbeddingApiKey, m.Content)
+		if err == nil {
+			err = w.messageRepo.UpdateEmbedding(ctx, m.ID, vec)
+		}
+		if err != nil {
+			log.Printf("embedding worker message error (id=%s): %v", m.ID, err)
+			_ = w.messageRepo.MarkEmbeddingFailed(ctx, m.ID)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+const (
+	maxIdempotencyKeyLen = 200
+	// idempotencyPendingTimeout 首次请求超过该时间仍未完成（进程崩溃等）视为中断，同一请求的重试可接管该键
+	idempotencyPendingTimeout = 5 * time.Minute
+)
+
+var (
+	// ErrIdempotencyInProgress 同一幂等键的首次请求尚未完成
+	ErrIdempotencyInProgress = errors.New("request with this idempotency key is still in progress")
+	// ErrIdempotencyMismatch 同一幂等键被用于内容不同的请求
+	ErrIdempotencyMismatch = errors.New("idempotency key was already used for a different request")
+)
+
+// IdempotencyService 幂等键：首次请求占用键并在完成后保存结果，TTL 内的重试直接返回保存的结果
+type IdempotencyService struct {
+	repo repository.IdempotencyRepo
+	ttl  time.Duration
+}
+
+func NewIdempotencyService(repo repository.IdempotencyRepo, ttl time.Duration) *IdempotencyService {
+	return &IdempotencyService{repo: repo, ttl: ttl}
+}
+
+// Begin 占用幂等键。
+// 返回非 nil 记录表示这是一次重试，调用方应直接返回记录中的结果；
+// 返回 nil 记录与占用标识 lockToken 表示调用方获得了该键，执行完成后必须以该标识调用 Complete 或 Release。
+func (s *IdempotencyService) Begin(ctx context.Context, agentID string, scope domain.IdempotencyScope, key, requestHash string) (*domain.IdempotencyRecord, string, error) {
+	if key == "" || len(key) > maxIdempotencyKeyLen {
+		return nil, "", fmt.Errorf("invalid idempotency key: must be 1-%d characters", maxIdempotencyKeyLen)
+	}
+	rec := &domain.IdempotencyRecord{
+		AgentID:     agentID,
+		Scope:       scope,
+		Key:         key,
+		RequestHash: requestHash,
+		LockToken:   uuid.New().String(),
+		ExpiresAt:   time.Now().Add(s.ttl),
+	}
+	// 第二次尝试用于覆盖并发下刚好过期/被释放的键
+	for attempt := 0; attempt < 2; attempt++ {
+		ok, err := s.repo.Reserve(ctx, rec, time.Now().Add(-idempotencyPendingTimeout))
+		if err != nil {
+			return nil, "", err
+		}
+		if ok {
+			return nil, rec.LockToken, nil
+		}
+		existing, err := s.repo.Get(ctx, agentID, scope, key)
+		if err != nil {
+			return nil, "", err
+		}
+		if existing == nil {
+			continue
+		}
+		if existing.RequestHash != requestHash {
+			return nil, "", ErrIdempotencyMismatch
+		}
+		if existing.Status != domain.IdempotencyDone {
+			return nil, "", ErrIdempotencyInProgress
+		}
+		return existing, "", nil
+	}
+	return nil, "", ErrIdempotencyInProgress
+}
+
+// Complete 保存首次结果；键已被接管时保留新持有者的结果
+func (s *IdempotencyService) Complete(ctx context.Context, agentID string, scope domain.IdempotencyScope, key, lockToken string, statusCode int, response string) {
+	if err := s.repo.Complete(ctx, agentID, scope, key, lockToken, statusCode, response); err != nil {
+		log.Printf("[idempotency] complete %s/%s: %v", scope, key, err)
+	}
+}
+
+// Release 放弃占用（执行失败时调用，允许客户端用同一键重试）
+func (s *IdempotencyService) Release(ctx context.Context, agentID string, scope domain.IdempotencyScope, key, lockToken string) {
+	if err := s.repo.Release(ctx, agentID, scope, key, lockToken); err != nil {
+		log.Printf("[idempotency] release %s/%s: %v", scope, key, err)
+	}
+}
+
+// PurgeLoop 定期清理过期幂等键
+func (s *IdempotencyService) PurgeLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if n, err := s.repo.DeleteExpired(ctx); err != nil {
+			log.Printf("[idempotency] purge expired: %v", err)
+		} else if n > 0 {
+			log.Printf("[idempotency] purged %d expired keys", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// HashIdempotentRequest 请求内容摘要（用于识别同键不同请求）
+func HashIdempotentRequest(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
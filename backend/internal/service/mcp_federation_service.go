@@ -0,0 +1,666 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/llm"
+	"github.com/linkclaw/backend/internal/mcpclient"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+const (
+	federationToolCacheTTL = 5 * time.Minute
+	federationFailureTTL   = 30 * time.Second // 连接或列举失败后，ListTools 在此期间直接跳过该上游
+	federationIdleTimeout  = 10 * time.Minute
+	federationDialTimeout  = 30 * time.Second
+	federationCallTimeout  = 2 * time.Minute
+)
+
+var upstreamNameRe = regexp.MustCompile(`^[a-z][a-z0-9-]{0,31}$`)
+
+// FederatedTool 上游工具（已加命名空间）
+type FederatedTool struct {
+	Name         string          `json:"name"` // <upstream>__<tool>
+	UpstreamID   string          `json:"upstream_id"`
+	UpstreamName string          `json:"upstream_name"`
+	OriginalName string          `json:"original_name"`
+	Description  string          `json:"description"`
+	InputSchema  json.RawMessage `json:"input_schema"`
+	RequiredPerm string          `json:"required_perm,omitempty"`
+}
+
+// federationConn 一条上游连接（按 上游 + 凭证 复用）；字段均由 mu 保护
+type federationConn struct {
+	mu       sync.Mutex
+	client   mcpclient.Client
+	tools    []mcpclient.Tool
+	toolsAt  time.Time
+	lastUsed time.Time
+	inUse    int   // 正在进行的 tools/list、tools/call 数，归零前不关闭连接
+	retired  bool  // 已移出连接池，最后一个使用者释放后关闭
+	err      error // 拨号失败，条目已移出连接池；等待同一条目的调用直接返回该错误
+}
+
+// release 结束一次使用；连接已被移出连接池且无其他使用者时关闭
+func (c *federationConn) release() {
+	c.mu.Lock()
+	c.inUse--
+	closeNow := c.retired && c.inUse == 0 && c.client != nil
+	c.mu.Unlock()
+	if closeNow {
+		go c.client.Close()
+	}
+}
+
+// retire 标记连接已移出连接池；空闲时立即关闭，否则由最后一个使用者关闭
+func (c *federationConn) retire() {
+	c.mu.Lock()
+	c.retired = true
+	closeNow := c.inUse == 0 && c.client != nil
+	c.mu.Unlock()
+	if closeNow {
+		go c.client.Close()
+	}
+}
+
+// McpFederationService 以 MCP 客户端身份连接外部 MCP Server，合并其工具并代理调用
+type McpFederationService struct {
+	repo       repository.McpUpstreamRepo
+	obsSvc     *ObservabilityService
+	auditRepo  repository.AuditRepo
+	encryptKey string
+
+	mu       sync.Mutex
+	conns    map[string]*federationConn
+	failures map[string]federationFailure // 按连接 key 记录最近一次失败
+}
+
+// federationFailure 上游最近一次连接或列举工具失败
+type federationFailure struct {
+	err error
+	at  time.Time
+}
+
+func NewMcpFederationService(repo repository.McpUpstreamRepo, obsSvc *ObservabilityService, auditRepo repository.AuditRepo, encryptKey string) *McpFederationService {
+	return &McpFederationService{
+		repo:       repo,
+		obsSvc:     obsSvc,
+		auditRepo:  auditRepo,
+		encryptKey: encryptKey,
+		conns:      make(map[string]*federationConn),
+		failures:   make(map[string]federationFailure),
+	}
+}
+
+// McpUpstreamInput 创建/更新上游参数
+type McpUpstreamInput struct {
+	Name         string
+	Description  string
+	Transport    domain.McpUpstreamTransport
+	URL          string
+	Image        string
+	Command      string
+	Args         []string
+	AuthHeader   string
+	AuthScheme   string
+	AuthEnv      string
+	RequiredPerm string
+	IsActive     *bool
+}
+
+// ── 上游管理 ─────────────────────────────────────────────
+
+func (s *McpFederationService) ListUpstreams(ctx context.Context, companyID string) ([]*domain.McpUpstream, error) {
+	return s.repo.ListByCompany(ctx, companyID)
+}
+
+func (s *McpFederationService) GetUpstream(ctx context.Context, companyID, id string) (*domain.McpUpstream, error) {
+	u, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, fmt.Errorf("mcp upstream not found")
+	}
+	if u.CompanyID != companyID {
+		return nil, fmt.Errorf("forbidden")
+	}
+	return u, nil
+}
+
+func (s *McpFederationService) CreateUpstream(ctx context.Context, companyID string, in McpUpstreamInput) (*domain.McpUpstream, error) {
+	in.Name = strings.TrimSpace(in.Name)
+	if !upstreamNameRe.MatchString(in.Name) {
+		return nil, fmt.Errorf("invalid name: must match %s", upstreamNameRe.String())
+	}
+	if existing, err := s.repo.GetByName(ctx, companyID, in.Name); err != nil {
+		return nil, err
+	} else if existing != nil {
+		return nil, fmt.Errorf("upstream %q already exists", in.Name)
+	}
+	u := &domain.McpUpstream{CompanyID: companyID, Name: in.Name, IsActive: true}
+	if err := applyUpstreamInput(u, in); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, u); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(ctx, u.ID)
+}
+
+func (s *McpFederationService) UpdateUpstream(ctx context.Context, companyID, id string, in McpUpstreamInput) (*domain.McpUpstream, error) {
+	u, err := s.GetUpstream(ctx, companyID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyUpstreamInput(u, in); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(ctx, u); err != nil {
+		return nil, err
+	}
+	s.dropConns(id)
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *McpFederationService) DeleteUpstream(ctx context.Context, companyID, id string) error {
+	if _, err := s.GetUpstream(ctx, companyID, id); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteCredentialsByUpstream(ctx, id); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.dropConns(id)
+	return nil
+}
+
+// TestUpstream 使用公司级凭证建立一次性连接并列出工具，用于配置校验
+func (s *McpFederationService) TestUpstream(ctx context.Context, companyID, id string) ([]mcpclient.Tool, error) {
+	u, err := s.GetUpstream(ctx, companyID, id)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := s.repo.GetCredential(ctx, u.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := s.decrypt(cred)
+	if err != nil {
+		return nil, err
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, federationDialTimeout)
+	defer cancel()
+	client, err := dialUpstream(dialCtx, u, secret)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	return client.ListTools(dialCtx)
+}
+
+func applyUpstreamInput(u *domain.McpUpstream, in McpUpstreamInput) error {
+	switch in.Transport {
+	case domain.McpTransportHTTP:
+		parsed, err := url.Parse(strings.TrimSpace(in.URL))
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("http transport requires a valid http(s) url")
+		}
+	case domain.McpTransportStdio:
+		if strings.TrimSpace(in.Image) == "" {
+			return fmt.Errorf("stdio transport requires image")
+		}
+	default:
+		return fmt.Errorf("invalid transport: %s", in.Transport)
+	}
+	u.Description = in.Description
+	u.Transport = in.Transport
+	u.URL = strings.TrimSpace(in.URL)
+	u.Image = strings.TrimSpace(in.Image)
+	u.Command = strings.TrimSpace(in.Command)
+	u.Args = domain.StringList(in.Args)
+	u.AuthHeader = strings.TrimSpace(in.AuthHeader)
+	u.AuthScheme = strings.TrimSpace(in.AuthScheme)
+	u.AuthEnv = strings.TrimSpace(in.AuthEnv)
+	u.RequiredPerm = strings.TrimSpace(in.RequiredPerm)
+	if in.IsActive != nil {
+		u.IsActive = *in.IsActive
+	}
+	return nil
+}
+
+// ── 凭证 ─────────────────────────────────────────────────
+
+// SetCredential 设置上游凭证；agentID 为空表示公司级凭证
+func (s *McpFederationService) SetCredential(ctx context.Context, companyID, upstreamID string, agentID *string, secret string) error {
+	u, err := s.GetUpstream(ctx, companyID, upstreamID)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(secret) == "" {
+		return fmt.Errorf("secret is required")
+	}
+	if s.encryptKey == "" {
+		return fmt.Errorf("encryption key not configured")
+	}
+	enc, err := llm.EncryptAPIKey(secret, s.encryptKey)
+	if err != nil {
+		return fmt.Errorf("encrypt credential: %w", err)
+	}
+	if agentID != nil && *agentID == "" {
+		agentID = nil
+	}
+	if err := s.repo.UpsertCredential(ctx, &domain.McpUpstreamCredential{
+		CompanyID: companyID, UpstreamID: u.ID, AgentID: agentID, SecretEnc: enc,
+	}); err != nil {
+		return err
+	}
+	s.dropConns(u.ID)
+	return nil
+}
+
+func (s *McpFederationService) ListCredentials(ctx context.Context, companyID, upstreamID string) ([]*domain.McpUpstreamCredential, error) {
+	if _, err := s.GetUpstream(ctx, companyID, upstreamID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListCredentials(ctx, upstreamID)
+}
+
+func (s *McpFederationService) DeleteCredential(ctx context.Context, companyID, upstreamID, credentialID string) error {
+	creds, err := s.ListCredentials(ctx, companyID, upstreamID)
+	if err != nil {
+		return err
+	}
+	for _, c := range creds {
+		if c.ID == credentialID {
+			if err := s.repo.DeleteCredential(ctx, c.ID); err != nil {
+				return err
+			}
+			s.dropConns(upstreamID)
+			return nil
+		}
+	}
+	return fmt.Errorf("credential not found")
+}
+
+// resolveCredential 优先使用 Agent 专属凭证，其次公司级凭证
+func (s *McpFederationService) resolveCredential(ctx context.Context, u *domain.McpUpstream, agentID string) (*domain.McpUpstreamCredential, error) {
+	cred, err := s.repo.GetCredential(ctx, u.ID, &agentID)
+	if err != nil || cred != nil {
+		return cred, err
+	}
+	return s.repo.GetCredential(ctx, u.ID, nil)
+}
+
+func (s *McpFederationService) decrypt(cred *domain.McpUpstreamCredential) (string, error) {
+	if cred == nil {
+		return "", nil
+	}
+	if s.encryptKey == "" {
+		return "", fmt.Errorf("encryption key not configured")
+	}
+	secret, err := llm.DecryptAPIKey(cred.SecretEnc, s.encryptKey)
+	if err != nil {
+		return "", fmt.Errorf("decrypt credential: %w", err)
+	}
+	return secret, nil
+}
+
+// ── 工具合并 & 代理调用 ───────────────────────────────────
+
+// ListTools 返回 Agent 所在公司全部启用上游的工具。各上游并行连接，
+// 单个上游不可用时跳过，且在 federationFailureTTL 内不再重试，避免拖慢每次 tools/list
+func (s *McpFederationService) ListTools(ctx context.Context, agent *domain.Agent) []FederatedTool {
+	upstreams, err := s.repo.ListByCompany(ctx, agent.CompanyID)
+	if err != nil {
+		log.Printf("[federation] list upstreams: %v", err)
+		return nil
+	}
+	results := make([][]mcpclient.Tool, len(upstreams))
+	var wg sync.WaitGroup
+	for i, u := range upstreams {
+		if !u.IsActive {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, u *domain.McpUpstream) {
+			defer wg.Done()
+			tools, err := s.upstreamTools(ctx, u, agent.ID)
+			if err != nil {
+				log.Printf("[federation] list tools %s: %v", u.Name, err)
+				return
+			}
+			results[i] = tools
+		}(i, u)
+	}
+	wg.Wait()
+
+	var out []FederatedTool
+	for i, u := range upstreams {
+		for _, t := range results[i] {
+			out = append(out, FederatedTool{
+				Name:         u.Name + domain.McpToolSeparator + t.Name,
+				UpstreamID:   u.ID,
+				UpstreamName: u.Name,
+				OriginalName: t.Name,
+				Description:  fmt.Sprintf("[%s] %s", u.Name, t.Description),
+				InputSchema:  t.InputSchema,
+				RequiredPerm: u.RequiredPerm,
+			})
+		}
+	}
+	return out
+}
+
+// upstreamTools 单个上游的工具列表（按 federationToolCacheTTL 缓存）；最近失败过的上游直接返回该错误
+func (s *McpFederationService) upstreamTools(ctx context.Context, u *domain.McpUpstream, agentID string) ([]mcpclient.Tool, error) {
+	cred, err := s.resolveCredential(ctx, u, agentID)
+	if err != nil {
+		return nil, err
+	}
+	key := federationConnKey(u, cred)
+	s.mu.Lock()
+	f, failed := s.failures[key]
+	s.mu.Unlock()
+	if failed && time.Since(f.at) < federationFailureTTL {
+		return nil, f.err
+	}
+
+	tools, err := s.listTools(ctx, u, cred, key)
+	s.mu.Lock()
+	if err != nil {
+		s.failures[key] = federationFailure{err: err, at: time.Now()}
+	} else {
+		delete(s.failures, key)
+	}
+	s.mu.Unlock()
+	return tools, err
+}
+
+func (s *McpFederationService) listTools(ctx context.Context, u *domain.McpUpstream, cred *domain.McpUpstreamCredential, key string) ([]mcpclient.Tool, error) {
+	conn, err := s.connWith(ctx, u, cred, key)
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+	defer conn.release()
+	return s.cachedTools(ctx, conn)
+}
+
+// Resolve 将 <upstream>__<tool> 解析为上游和原始工具名；非联邦工具或上游未启用时返回 nil
+func (s *McpFederationService) Resolve(ctx context.Context, companyID, name string) (*domain.McpUpstream, string, error) {
+	prefix, tool, ok := strings.Cut(name, domain.McpToolSeparator)
+	if !ok || prefix == "" || tool == "" {
+		return nil, "", nil
+	}
+	u, err := s.repo.GetByName(ctx, companyID, prefix)
+	if err != nil || u == nil || !u.IsActive {
+		return nil, "", err
+	}
+	return u, tool, nil
+}
+
+// CallTool 代理调用上游工具，并记录 Trace 与审计日志
+func (s *McpFederationService) CallTool(ctx context.Context, agent *domain.Agent, u *domain.McpUpstream, tool string, args json.RawMessage) (*mcpclient.CallResult, error) {
+	started := time.Now()
+	var traceID, spanID string
+	if s.obsSvc != nil {
+		if tr, err := s.obsSvc.StartTrace(ctx, agent.CompanyID, &agent.ID, domain.TraceSourceMCP, &u.ID); err == nil {
+			traceID = tr.ID
+			if sp, err := s.obsSvc.StartSpan(ctx, tr.ID, nil, &agent.ID, domain.SpanTypeMCPTool, u.Name+domain.McpToolSeparator+tool); err == nil {
+				spanID = sp.ID
+			}
+		}
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, federationCallTimeout)
+	defer cancel()
+	result, err := s.callOnce(callCtx, u, agent.ID, tool, args)
+	if err != nil && callCtx.Err() == nil {
+		// 连接可能已失效（上游重启等），丢弃后重试一次
+		s.dropConns(u.ID)
+		result, err = s.callOnce(callCtx, u, agent.ID, tool, args)
+	}
+
+	status := domain.TraceStatusSuccess
+	var errMsg *string
+	switch {
+	case err != nil:
+		status = domain.TraceStatusError
+		msg := err.Error()
+		errMsg = &msg
+		if callCtx.Err() == context.DeadlineExceeded {
+			status = domain.TraceStatusTimeout
+		}
+	case result.IsError:
+		status = domain.TraceStatusError
+	}
+	if traceID != "" {
+		if spanID != "" {
+			_ = s.obsSvc.EndSpan(ctx, spanID, status, nil, nil, nil, errMsg)
+		}
+		_ = s.obsSvc.EndTrace(ctx, traceID, status, errMsg)
+	}
+	s.audit(agent, u, tool, traceID, status, time.Since(started))
+	return result, err
+}
+
+func (s *McpFederationService) callOnce(ctx context.Context, u *domain.McpUpstream, agentID, tool string, args json.RawMessage) (*mcpclient.CallResult, error) {
+	conn, err := s.conn(ctx, u, agentID)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.release()
+	return conn.client.CallTool(ctx, tool, args)
+}
+
+func (s *McpFederationService) audit(agent *domain.Agent, u *domain.McpUpstream, tool, traceID string, status domain.TraceStatus, dur time.Duration) {
+	if s.auditRepo == nil {
+		return
+	}
+	details, _ := json.Marshal(map[string]any{
+		"upstream":    u.Name,
+		"tool":        tool,
+		"status":      status,
+		"trace_id":    traceID,
+		"duration_ms": dur.Milliseconds(),
+	})
+	upstreamID := u.ID
+	entry := &domain.AuditLog{
+		CompanyID:    agent.CompanyID,
+		AgentID:      agent.ID,
+		AgentName:    agent.Name,
+		Action:       domain.AuditActionMCPToolCall,
+		ResourceType: "mcp_upstream",
+		ResourceID:   &upstreamID,
+		Details:      details,
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := s.auditRepo.CreateAuditLog(ctx, entry); err != nil {
+			log.Printf("[federation] audit log: %v", err)
+		}
+	}()
+}
+
+// ── 连接池 ───────────────────────────────────────────────
+
+// federationConnKey 连接按 上游 + 凭证 复用
+func federationConnKey(u *domain.McpUpstream, cred *domain.McpUpstreamCredential) string {
+	key := u.ID + "/"
+	if cred != nil {
+		key += cred.ID
+	}
+	return key
+}
+
+// conn 获取（必要时建立）上游连接并占用，用完须调用 release；按 上游 + 凭证 复用，顺带回收空闲连接
+func (s *McpFederationService) conn(ctx context.Context, u *domain.McpUpstream, agentID string) (*federationConn, error) {
+	cred, err := s.resolveCredential(ctx, u, agentID)
+	if err != nil {
+		return nil, err
+	}
+	return s.connWith(ctx, u, cred, federationConnKey(u, cred))
+}
+
+// connWith 建立失败时移除连接池中的空条目，下次重新拨号
+func (s *McpFederationService) connWith(ctx context.Context, u *domain.McpUpstream, cred *domain.McpUpstreamCredential, key string) (*federationConn, error) {
+	for {
+		s.mu.Lock()
+		s.reapIdleLocked()
+		c, ok := s.conns[key]
+		if !ok {
+			c = &federationConn{}
+			s.conns[key] = c
+		}
+		s.mu.Unlock()
+
+		c.mu.Lock()
+		if c.retired {
+			// 取出后已被回收或丢弃，重新从连接池获取
+			c.mu.Unlock()
+			continue
+		}
+		err := s.ensureClientLocked(ctx, c, u, cred, key)
+		if err == nil {
+			c.lastUsed = time.Now()
+			c.inUse++
+		}
+		c.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+}
+
+// ensureClientLocked 在持有 c.mu 时按需拨号
+func (s *McpFederationService) ensureClientLocked(ctx context.Context, c *federationConn, u *domain.McpUpstream, cred *domain.McpUpstreamCredential, key string) error {
+	if c.client != nil {
+		return nil
+	}
+	if c.err != nil {
+		return c.err
+	}
+	client, err := s.dial(ctx, u, cred)
+	if err != nil {
+		c.err = err
+		s.mu.Lock()
+		if s.conns[key] == c {
+			delete(s.conns, key)
+		}
+		s.mu.Unlock()
+		return err
+	}
+	c.client = client
+	return nil
+}
+
+func (s *McpFederationService) dial(ctx context.Context, u *domain.McpUpstream, cred *domain.McpUpstreamCredential) (mcpclient.Client, error) {
+	secret, err := s.decrypt(cred)
+	if err != nil {
+		return nil, err
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, federationDialTimeout)
+	defer cancel()
+	return dialUpstream(dialCtx, u, secret)
+}
+
+func (s *McpFederationService) cachedTools(ctx context.Context, c *federationConn) ([]mcpclient.Tool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tools != nil && time.Since(c.toolsAt) < federationToolCacheTTL {
+		return c.tools, nil
+	}
+	tools, err := c.client.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.tools = tools
+	c.toolsAt = time.Now()
+	return tools, nil
+}
+
+// reapIdleLocked 回收空闲且未被占用的连接；条目正被其他调用持有（拨号、列举工具等）时跳过，
+// 不在持有 s.mu 时等待 c.mu
+func (s *McpFederationService) reapIdleLocked() {
+	for key, c := range s.conns {
+		if !c.mu.TryLock() {
+			continue
+		}
+		if c.client != nil && c.inUse == 0 && time.Since(c.lastUsed) > federationIdleTimeout {
+			c.retired = true
+			go c.client.Close()
+			delete(s.conns, key)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// dropConns 关闭某上游的全部连接（配置或凭证变更后调用）；进行中的调用结束后才关闭其连接
+func (s *McpFederationService) dropConns(upstreamID string) {
+	var dropped []*federationConn
+	s.mu.Lock()
+	for key := range s.failures {
+		if strings.HasPrefix(key, upstreamID+"/") {
+			delete(s.failures, key)
+		}
+	}
+	for key, c := range s.conns {
+		if strings.HasPrefix(key, upstreamID+"/") {
+			dropped = append(dropped, c)
+			delete(s.conns, key)
+		}
+	}
+	s.mu.Unlock()
+	for _, c := range dropped {
+		c.retire()
+	}
+}
+
+func dialUpstream(ctx context.Context, u *domain.McpUpstream, secret string) (mcpclient.Client, error) {
+	switch u.Transport {
+	case domain.McpTransportHTTP:
+		headers := map[string]string{}
+		if secret != "" {
+			header := u.AuthHeader
+			if header == "" {
+				header = "Authorization"
+			}
+			value := secret
+			if u.AuthScheme != "" {
+				value = u.AuthScheme + " " + secret
+			}
+			headers[header] = value
+		}
+		return mcpclient.DialHTTP(ctx, u.URL, headers)
+
+	case domain.McpTransportStdio:
+		args := []string{"run", "-i", "--rm"}
+		env := os.Environ()
+		if secret != "" && u.AuthEnv != "" {
+			// -e NAME 不带值：从 docker CLI 进程环境继承，避免凭证出现在命令行
+			args = append(args, "-e", u.AuthEnv)
+			env = append(env, u.AuthEnv+"="+secret)
+		}
+		args = append(args, u.Image)
+		if u.Command != "" {
+			args = append(args, u.Command)
+		}
+		args = append(args, u.Args...)
+		return mcpclient.DialStdio(ctx, "docker", args, env)
+	}
+	return nil, fmt.Errorf("unsupported transport: %s", u.Transport)
+}
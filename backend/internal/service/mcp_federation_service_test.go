@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/mcpclient"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+// memMcpUpstreamRepo 上游均无凭证
+type memMcpUpstreamRepo struct {
+	repository.McpUpstreamRepo
+}
+
+func (memMcpUpstreamRepo) GetCredential(context.Context, string, *string) (*domain.McpUpstreamCredential, error) {
+	return nil, nil
+}
+
+// fakeMcpClient 记录关闭；CallTool 在 block 关闭前阻塞
+type fakeMcpClient struct {
+	block  chan struct{}
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newFakeMcpClient() *fakeMcpClient {
+	return &fakeMcpClient{closed: make(chan struct{})}
+}
+
+func (c *fakeMcpClient) ListTools(context.Context) ([]mcpclient.Tool, error) { return nil, nil }
+
+func (c *fakeMcpClient) CallTool(context.Context, string, json.RawMessage) (*mcpclient.CallResult, error) {
+	if c.block != nil {
+		<-c.block
+	}
+	return &mcpclient.CallResult{}, nil
+}
+
+func (c *fakeMcpClient) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+func (c *fakeMcpClient) waitClosed(t *testing.T) {
+	t.Helper()
+	select {
+	case <-c.closed:
+	case <-time.After(time.Second):
+		t.Fatal("connection not closed")
+	}
+}
+
+func (c *fakeMcpClient) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	case <-time.After(20 * time.Millisecond):
+		return false
+	}
+}
+
+// newPooledFederation 连接池中预置 u1 的一条已建立连接
+func newPooledFederation(client mcpclient.Client) (*McpFederationService, *domain.McpUpstream) {
+	s := NewMcpFederationService(memMcpUpstreamRepo{}, nil, nil, "")
+	u := &domain.McpUpstream{ID: "u1"}
+	s.conns[federationConnKey(u, nil)] = &federationConn{client: client, lastUsed: time.Now()}
+	return s, u
+}
+
+func TestFederationReap_SkipsConnectionsInUse(t *testing.T) {
+	client := newFakeMcpClient()
+	s, u := newPooledFederation(client)
+	c, err := s.connWith(context.Background(), u, nil, federationConnKey(u, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.mu.Lock()
+	c.lastUsed = time.Now().Add(-2 * federationIdleTimeout)
+	c.mu.Unlock()
+
+	s.mu.Lock()
+	s.reapIdleLocked()
+	s.mu.Unlock()
+	if client.isClosed() || s.conns[federationConnKey(u, nil)] != c {
+		t.Fatal("connection in use during a call was reaped")
+	}
+
+	c.release()
+	s.mu.Lock()
+	s.reapIdleLocked()
+	s.mu.Unlock()
+	client.waitClosed(t)
+	if _, ok := s.conns[federationConnKey(u, nil)]; ok {
+		t.Error("idle connection left in the pool")
+	}
+}
+
+func TestFederationDropConns_ClosesAfterInFlightCall(t *testing.T) {
+	client := newFakeMcpClient()
+	client.block = make(chan struct{})
+	s, u := newPooledFederation(client)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.callOnce(context.Background(), u, "a1", "echo", nil)
+		done <- err
+	}()
+	// 等待调用占用连接
+	for {
+		s.mu.Lock()
+		c := s.conns[federationConnKey(u, nil)]
+		s.mu.Unlock()
+		c.mu.Lock()
+		inUse := c.inUse
+		c.mu.Unlock()
+		if inUse > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	s.dropConns(u.ID)
+	if client.isClosed() {
+		t.Fatal("connection closed during an in-flight call")
+	}
+	close(client.block)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	client.waitClosed(t)
+}
+
+// TestFederationPool_ConcurrentUseAndDrop 配合 -race 检查连接字段的并发访问
+func TestFederationPool_ConcurrentUseAndDrop(t *testing.T) {
+	s, u := newPooledFederation(newFakeMcpClient())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if c, err := s.connWith(context.Background(), u, nil, federationConnKey(u, nil)); err == nil {
+				_, _ = c.client.CallTool(context.Background(), "echo", nil)
+				c.release()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			s.dropConns(u.ID)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,421 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/event"
+)
+
+const (
+	messageBlocksMax         = 20
+	messageActionsMax        = 10
+	messageCodeMaxLen        = 64 << 10
+	messageActionLabelMaxLen = 64
+	messageExcerptRunes      = 200
+
+	// messageAttachmentTTL 上传后未随消息发送的附件保留时长
+	messageAttachmentTTL = 24 * time.Hour
+)
+
+var messageActionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,64}$`)
+
+// UploadAttachment 上传消息附件（与任务附件共用存储与校验规则），发送消息时以文件块引用
+func (s *MessageService) UploadAttachment(ctx context.Context, actor *domain.Agent, file TaskUploadFile) (*domain.MessageAttachment, error) {
+	if err := validateTaskUpload(&file); err != nil {
+		return nil, err
+	}
+	filename := uuid.NewString() + taskAttachmentExtension(file.OriginalFilename)
+	key := path.Join("messages", actor.CompanyID, filename)
+	if err := s.files.Put(ctx, key, file.Content, file.MimeType); err != nil {
+		return nil, fmt.Errorf("save message attachment: %w", err)
+	}
+	sum := sha256.Sum256(file.Content)
+	a := &domain.MessageAttachment{
+		ID:               uuid.NewString(),
+		CompanyID:        actor.CompanyID,
+		Filename:         filename,
+		OriginalFilename: file.OriginalFilename,
+		FileSize:         file.Size,
+		MimeType:         file.MimeType,
+		StoragePath:      key,
+		SHA256:           hex.EncodeToString(sum[:]),
+		UploadedBy:       actor.ID,
+		CreatedAt:        time.Now().UTC(),
+	}
+	if err := s.messageRepo.CreateAttachment(ctx, a); err != nil {
+		s.removeFiles([]string{key})
+		return nil, err
+	}
+	return a, nil
+}
+
+// OpenAttachment 返回附件元数据与内容，调用方负责关闭；
+// 已发送的附件对能看到该消息的成员可见，未发送的仅上传者可见
+func (s *MessageService) OpenAttachment(ctx context.Context, actor *domain.Agent, id string) (*domain.MessageAttachment, io.ReadCloser, error) {
+	a, err := s.messageRepo.GetAttachment(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if a == nil || a.CompanyID != actor.CompanyID {
+		return nil, nil, fmt.Errorf("attachment not found")
+	}
+	if a.MessageID == nil {
+		if a.UploadedBy != actor.ID {
+			return nil, nil, fmt.Errorf("attachment not found")
+		}
+	} else {
+		m, _, err := s.loadVisible(ctx, actor, *a.MessageID)
+		if err != nil || m.IsDeleted() {
+			return nil, nil, fmt.Errorf("attachment not found")
+		}
+	}
+	rc, err := s.files.Get(ctx, a.StoragePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read attachment: %w", err)
+	}
+	return a, rc, nil
+}
+
+// PurgeLoop 定期清理上传后一直未发送的消息附件
+func (s *MessageService) PurgeLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		keys, err := s.messageRepo.DeleteUnlinkedAttachments(ctx, time.Now().Add(-messageAttachmentTTL))
+		if err != nil {
+			log.Printf("[message] purge unsent attachments: %v", err)
+		} else if len(keys) > 0 {
+			s.removeFiles(keys)
+			log.Printf("[message] purged %d unsent attachments", len(keys))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// removeFiles 尽力删除存储中的文件，失败只记录日志
+func (s *MessageService) removeFiles(keys []string) {
+	for _, key := range keys {
+		if err := s.files.Delete(context.Background(), key); err != nil {
+			log.Printf("[message] remove attachment file %s: %v", key, err)
+		}
+	}
+}
+
+// prepareBlocks 校验消息块并回填卡片快照，写入 msg.Blocks；Content 为空时生成纯文本摘要。
+// 返回需要在消息落库后关联的附件 ID
+func (s *MessageService) prepareBlocks(ctx context.Context, msg *domain.Message, senderID string, blocks []*domain.MessageBlock) ([]string, error) {
+	if len(blocks) > messageBlocksMax {
+		return nil, fmt.Errorf("a message can have at most %d blocks", messageBlocksMax)
+	}
+	var attachmentIDs []string
+	actionIDs := map[string]bool{}
+	for i, b := range blocks {
+		if b == nil {
+			return nil, fmt.Errorf("block %d is empty", i+1)
+		}
+		clean := &domain.MessageBlock{Type: b.Type}
+		switch b.Type {
+		case domain.BlockText:
+			if strings.TrimSpace(b.Text) == "" {
+				return nil, fmt.Errorf("block %d: text is required", i+1)
+			}
+			clean.Text = b.Text
+
+		case domain.BlockCode:
+			if strings.TrimSpace(b.Code) == "" {
+				return nil, fmt.Errorf("block %d: code is required", i+1)
+			}
+			if len(b.Code) > messageCodeMaxLen {
+				return nil, fmt.Errorf("block %d: code exceeds 64KB limit", i+1)
+			}
+			clean.Language, clean.Code = strings.TrimSpace(b.Language), b.Code
+
+		case domain.BlockFile:
+			if b.File == nil || b.File.AttachmentID == "" {
+				return nil, fmt.Errorf("block %d: file.attachment_id is required", i+1)
+			}
+			a, err := s.messageRepo.GetAttachment(ctx, b.File.AttachmentID)
+			if err != nil {
+				return nil, err
+			}
+			if a == nil || a.CompanyID != msg.CompanyID || a.MessageID != nil || a.UploadedBy != senderID {
+				return nil, fmt.Errorf("block %d: attachment not found", i+1)
+			}
+			clean.File = &domain.MessageFile{AttachmentID: a.ID, Filename: a.OriginalFilename, FileSize: a.FileSize, MimeType: a.MimeType}
+			attachmentIDs = append(attachmentIDs, a.ID)
+
+		case domain.BlockTask:
+			if b.Task == nil || b.Task.TaskID == "" {
+				return nil, fmt.Errorf("block %d: task.task_id is required", i+1)
+			}
+			t, err := s.taskRepo.GetByID(ctx, b.Task.TaskID)
+			if err != nil {
+				return nil, err
+			}
+			if t == nil || t.CompanyID != msg.CompanyID {
+				return nil, fmt.Errorf("block %d: task not found", i+1)
+			}
+			clean.Task = &domain.TaskMeta{
+				TaskID: t.ID, Title: t.Title, Status: t.Status, Priority: t.Priority,
+				AssigneeID: t.AssigneeID, DueAt: t.DueAt, Result: t.Result,
+			}
+
+		case domain.BlockApproval:
+			if b.Approval == nil || b.Approval.ID == "" {
+				return nil, fmt.Errorf("block %d: approval.id is required", i+1)
+			}
+			req, err := s.orgSvc.GetApproval(ctx, b.Approval.ID)
+			if err != nil {
+				return nil, err
+			}
+			if req == nil || req.CompanyID != msg.CompanyID {
+				return nil, fmt.Errorf("block %d: approval request not found", i+1)
+			}
+			clean.Approval = approvalCard(req)
+
+		case domain.BlockKnowledge:
+			if b.Knowledge == nil || b.Knowledge.DocID == "" {
+				return nil, fmt.Errorf("block %d: knowledge.doc_id is required", i+1)
+			}
+			doc, err := s.knowledgeRepo.GetByID(ctx, b.Knowledge.DocID)
+			if err != nil {
+				return nil, err
+			}
+			if doc == nil || doc.CompanyID != msg.CompanyID {
+				return nil, fmt.Errorf("block %d: knowledge doc not found", i+1)
+			}
+			clean.Knowledge = &domain.KnowledgeCard{DocID: doc.ID, Title: doc.Title, Excerpt: excerpt(doc.Content, messageExcerptRunes), Tags: doc.Tags}
+
+		case domain.BlockActions:
+			if len(b.Actions) == 0 || len(b.Actions) > messageActionsMax {
+				return nil, fmt.Errorf("block %d: actions must have 1-%d buttons", i+1, messageActionsMax)
+			}
+			for _, a := range b.Actions {
+				if a == nil || !messageActionIDPattern.MatchString(a.ActionID) || strings.HasPrefix(a.ActionID, "approval.") {
+					return nil, fmt.Errorf("block %d: invalid action_id", i+1)
+				}
+				if actionIDs[a.ActionID] {
+					return nil, fmt.Errorf("block %d: duplicate action_id %q", i+1, a.ActionID)
+				}
+				actionIDs[a.ActionID] = true
+				label := strings.TrimSpace(a.Label)
+				if label == "" || utf8.RuneCountInString(label) > messageActionLabelMaxLen {
+					return nil, fmt.Errorf("block %d: action %q needs a label of at most %d characters", i+1, a.ActionID, messageActionLabelMaxLen)
+				}
+				switch a.Style {
+				case "", "primary", "danger":
+				default:
+					return nil, fmt.Errorf("block %d: action style must be primary or danger", i+1)
+				}
+				clean.Actions = append(clean.Actions, &domain.MessageAction{ActionID: a.ActionID, Label: label, Style: a.Style, Value: a.Value})
+			}
+
+		default:
+			return nil, fmt.Errorf("block %d: unknown block type %q", i+1, b.Type)
+		}
+		blocks[i] = clean
+	}
+
+	raw, err := json.Marshal(blocks)
+	if err != nil {
+		return nil, err
+	}
+	msg.Blocks = raw
+	msg.MsgType = domain.MsgTypeRich
+	if strings.TrimSpace(msg.Content) == "" {
+		msg.Content = blocksText(blocks)
+	}
+	return attachmentIDs, nil
+}
+
+func approvalCard(req *domain.ApprovalRequest) *domain.ApprovalCard {
+	return &domain.ApprovalCard{
+		ID:          req.ID,
+		RequestType: req.RequestType,
+		Status:      req.Status,
+		Reason:      req.Reason,
+		RequesterID: req.RequesterID,
+		ApproverID:  req.ApproverID,
+		DecidedAt:   req.DecidedAt,
+	}
+}
+
+func excerpt(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
+
+// blocksText 消息块的纯文本摘要：用于搜索、通知以及不渲染消息块的客户端（如 Agent）
+func blocksText(blocks []*domain.MessageBlock) string {
+	lines := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		switch b.Type {
+		case domain.BlockText:
+			lines = append(lines, b.Text)
+		case domain.BlockCode:
+			lines = append(lines, "```"+b.Language+"\n"+strings.TrimRight(b.Code, "\n")+"\n```")
+		case domain.BlockFile:
+			lines = append(lines, fmt.Sprintf("[文件] %s (%s)", b.File.Filename, formatFileSize(b.File.FileSize)))
+		case domain.BlockTask:
+			lines = append(lines, fmt.Sprintf("[任务] %s（%s）", b.Task.Title, b.Task.Status))
+		case domain.BlockApproval:
+			lines = append(lines, fmt.Sprintf("[审批] %s：%s（%s）", b.Approval.RequestType, b.Approval.Reason, b.Approval.Status))
+		case domain.BlockKnowledge:
+			lines = append(lines, fmt.Sprintf("[知识库] %s", b.Knowledge.Title))
+		case domain.BlockActions:
+			labels := make([]string, 0, len(b.Actions))
+			for _, a := range b.Actions {
+				labels = append(labels, a.Label)
+			}
+			lines = append(lines, "[按钮] "+strings.Join(labels, " / "))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatFileSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// Act 点击消息中的按钮。审批卡片的批准 / 驳回由审批人或董事长处理并更新卡片状态；
+// 其余按钮以 message.action 事件回传给消息发送者，按钮携带的 value 以消息中定义的为准
+func (s *MessageService) Act(ctx context.Context, actor *domain.Agent, messageID, actionID, value, reason string) (*domain.Message, error) {
+	m, ch, err := s.loadVisible(ctx, actor, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if m.IsDeleted() {
+		return nil, fmt.Errorf("message has been deleted")
+	}
+	blocks, err := m.ParseBlocks()
+	if err != nil {
+		return nil, fmt.Errorf("parse message blocks: %w", err)
+	}
+
+	if actionID == domain.ActionApprove || actionID == domain.ActionReject {
+		return s.decideApprovalCard(ctx, actor, m, ch, blocks, actionID, value, reason)
+	}
+
+	var action *domain.MessageAction
+	for _, b := range blocks {
+		if b.Type != domain.BlockActions {
+			continue
+		}
+		for _, a := range b.Actions {
+			if a.ActionID == actionID {
+				action = a
+			}
+		}
+	}
+	if action == nil {
+		return nil, fmt.Errorf("action not found")
+	}
+	s.publishChanged(event.MessageAction, m, ch, actor.ID, func(p *event.MessageChangedPayload) {
+		p.ActionID = action.ActionID
+		p.Value = action.Value
+	})
+	return m, nil
+}
+
+// decideApprovalCard 处理审批卡片；value 为审批请求 ID，消息中只有一张审批卡片时可省略
+func (s *MessageService) decideApprovalCard(ctx context.Context, actor *domain.Agent, m *domain.Message, ch *domain.Channel, blocks []*domain.MessageBlock, actionID, approvalID, reason string) (*domain.Message, error) {
+	var card *domain.ApprovalCard
+	for _, b := range blocks {
+		if b.Type != domain.BlockApproval || (approvalID != "" && b.Approval.ID != approvalID) {
+			continue
+		}
+		if card != nil {
+			return nil, fmt.Errorf("value must specify the approval request id")
+		}
+		card = b.Approval
+	}
+	if card == nil {
+		return nil, fmt.Errorf("approval request not found")
+	}
+	req, err := s.orgSvc.GetApproval(ctx, card.ID)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil || req.CompanyID != m.CompanyID {
+		return nil, fmt.Errorf("approval request not found")
+	}
+	isApprover := req.ApproverID != nil && *req.ApproverID == actor.ID
+	if !isApprover && actor.RoleType != domain.RoleChairman {
+		return nil, fmt.Errorf("permission denied")
+	}
+
+	if req.Status != domain.ApprovalPending {
+		// 已在别处处理：同步卡片状态后提示
+		decidedBy := card.DecidedBy
+		*card = *approvalCard(req)
+		card.DecidedBy = decidedBy
+		if _, err := s.saveBlocks(ctx, m, blocks); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("approval request already %s", req.Status)
+	}
+
+	if actionID == domain.ActionApprove {
+		err = s.orgSvc.ApproveRequest(ctx, req.ID, reason)
+	} else {
+		err = s.orgSvc.RejectRequest(ctx, req.ID, reason)
+	}
+	if err != nil {
+		return nil, err
+	}
+	status, now := domain.ApprovalApproved, time.Now()
+	if actionID == domain.ActionReject {
+		status = domain.ApprovalRejected
+	}
+	card.Status, card.DecidedBy, card.DecidedAt = status, &actor.ID, &now
+
+	raw, err := s.saveBlocks(ctx, m, blocks)
+	if err != nil {
+		return nil, err
+	}
+	s.publishChanged(event.MessageAction, m, ch, actor.ID, func(p *event.MessageChangedPayload) {
+		p.ActionID = actionID
+		p.Value = req.ID
+		p.Blocks = raw
+	})
+	return m, nil
+}
+
+func (s *MessageService) saveBlocks(ctx context.Context, m *domain.Message, blocks []*domain.MessageBlock) (json.RawMessage, error) {
+	raw, err := json.Marshal(blocks)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.messageRepo.UpdateBlocks(ctx, m.ID, raw); err != nil {
+		return nil, err
+	}
+	m.Blocks = raw
+	return raw, nil
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/event"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+func TestPrepareBlocks(t *testing.T) {
+	s := &MessageService{}
+	ctx := context.Background()
+
+	msg := &domain.Message{CompanyID: "c", MsgType: domain.MsgTypeText}
+	_, err := s.prepareBlocks(ctx, msg, "a", []*domain.MessageBlock{
+		{Type: domain.BlockText, Text: "部署完成"},
+		{Type: domain.BlockCode, Language: "go", Code: "fmt.Println(1)\n"},
+		{Type: domain.BlockActions, Actions: []*domain.MessageAction{
+			{ActionID: "rollback", Label: " 回滚 ", Style: "danger", Value: "v1"},
+			{ActionID: "ok", Label: "确认"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("prepareBlocks: %v", err)
+	}
+	if msg.MsgType != domain.MsgTypeRich {
+		t.Errorf("MsgType = %q, want rich", msg.MsgType)
+	}
+	want := "部署完成\n```go\nfmt.Println(1)\n```\n[按钮] 回滚 / 确认"
+	if msg.Content != want {
+		t.Errorf("Content = %q, want %q", msg.Content, want)
+	}
+	blocks, err := msg.ParseBlocks()
+	if err != nil || len(blocks) != 3 || blocks[2].Actions[0].Label != "回滚" {
+		t.Fatalf("ParseBlocks = %+v, %v", blocks, err)
+	}
+
+	// 显式 content 不被覆盖
+	msg = &domain.Message{CompanyID: "c", Content: "看这里"}
+	if _, err := s.prepareBlocks(ctx, msg, "a", []*domain.MessageBlock{{Type: domain.BlockText, Text: "x"}}); err != nil || msg.Content != "看这里" {
+		t.Errorf("content overwritten: %q, %v", msg.Content, err)
+	}
+
+	invalid := map[string][]*domain.MessageBlock{
+		"unknown type":    {{Type: "poll"}},
+		"empty text":      {{Type: domain.BlockText, Text: " "}},
+		"file without id": {{Type: domain.BlockFile, File: &domain.MessageFile{}}},
+		"reserved action": {{Type: domain.BlockActions, Actions: []*domain.MessageAction{{ActionID: domain.ActionApprove, Label: "ok"}}}},
+		"duplicate action": {
+			{Type: domain.BlockActions, Actions: []*domain.MessageAction{{ActionID: "a", Label: "1"}}},
+			{Type: domain.BlockActions, Actions: []*domain.MessageAction{{ActionID: "a", Label: "2"}}},
+		},
+		"bad style":     {{Type: domain.BlockActions, Actions: []*domain.MessageAction{{ActionID: "a", Label: "1", Style: "blink"}}}},
+		"missing label": {{Type: domain.BlockActions, Actions: []*domain.MessageAction{{ActionID: "a"}}}},
+	}
+	for name, blocks := range invalid {
+		if _, err := s.prepareBlocks(ctx, &domain.Message{CompanyID: "c"}, "a", blocks); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestBlocksText(t *testing.T) {
+	got := blocksText([]*domain.MessageBlock{
+		{Type: domain.BlockFile, File: &domain.MessageFile{Filename: "report.pdf", FileSize: 3 << 20}},
+		{Type: domain.BlockTask, Task: &domain.TaskMeta{Title: "发布", Status: domain.TaskStatusDone}},
+		{Type: domain.BlockKnowledge, Knowledge: &domain.KnowledgeCard{Title: "值班手册"}},
+	})
+	for _, want := range []string{"[文件] report.pdf (3.0 MB)", "[任务] 发布", "[知识库] 值班手册"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("blocksText missing %q in %q", want, got)
+		}
+	}
+}
+
+// attachmentMessageRepo 附件关联在 Create 之后才写入；taken 中的附件模拟已被并发发送的消息占用
+type attachmentMessageRepo struct {
+	repository.MessageRepo
+	attachments map[string]*domain.MessageAttachment
+	taken       map[string]bool
+	created     []*domain.Message
+}
+
+func (r *attachmentMessageRepo) GetAttachment(_ context.Context, id string) (*domain.MessageAttachment, error) {
+	return r.attachments[id], nil
+}
+
+func (r *attachmentMessageRepo) Create(_ context.Context, m *domain.Message) error {
+	r.created = append(r.created, m)
+	return nil
+}
+
+func (r *attachmentMessageRepo) LinkAttachments(_ context.Context, messageID string, ids []string) (int64, error) {
+	var n int64
+	for _, id := range ids {
+		if a := r.attachments[id]; a != nil && !r.taken[id] {
+			a.MessageID = &messageID
+			n++
+		}
+	}
+	return n, nil
+}
+
+// messageRollbackTx fn 失败时丢弃事务内创建的消息
+type messageRollbackTx struct {
+	repo *attachmentMessageRepo
+}
+
+func (tx *messageRollbackTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	n := len(tx.repo.created)
+	if err := fn(ctx); err != nil {
+		tx.repo.created = tx.repo.created[:n]
+		return err
+	}
+	return nil
+}
+
+func TestSend_LinksAttachmentsAtomically(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		taken   bool
+		wantErr bool
+	}{
+		{"linked", false, false},
+		{"taken concurrently", true, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &attachmentMessageRepo{
+				attachments: map[string]*domain.MessageAttachment{
+					"f1": {ID: "f1", CompanyID: "c1", OriginalFilename: "a.txt", UploadedBy: "a1"},
+				},
+				taken: map[string]bool{"f1": tt.taken},
+			}
+			agents := &memTaskAgentRepo{agents: map[string]*domain.Agent{"a1": {ID: "a1", CompanyID: "c1"}}}
+			svc := NewMessageService(repo, nil, nil, agents, nil, nil, nil, nil, nil, &messageRollbackTx{repo: repo})
+			var published int
+			unsubscribe := event.Global.Subscribe(event.MessageNew, func(event.Event) { published++ })
+			defer unsubscribe()
+
+			_, err := svc.Send(context.Background(), SendInput{
+				CompanyID: "c1", SenderID: "a1", ReceiverID: "a2",
+				Blocks: []*domain.MessageBlock{{Type: domain.BlockFile, File: &domain.MessageFile{AttachmentID: "f1"}}},
+			})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("send should fail when the attachment cannot be linked")
+				}
+				if len(repo.created) != 0 || published != 0 {
+					t.Errorf("message kept (%d) or published (%d) after failed link", len(repo.created), published)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(repo.created) != 1 || published != 1 || repo.attachments["f1"].MessageID == nil {
+				t.Errorf("created %d, published %d, attachment %+v", len(repo.created), published, repo.attachments["f1"])
+			}
+		})
+	}
+}
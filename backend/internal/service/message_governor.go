@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/event"
+)
+
+// govern 对话预算：人类发言重置所在对话；AI 之间的发言计入预算，超限时拒绝并在首次熔断时告警
+func (s *MessageService) govern(ctx context.Context, msg *domain.Message, ch *domain.Channel, sender *domain.Agent) error {
+	if s.governor == nil || sender == nil {
+		return nil
+	}
+	if sender.IsHuman {
+		if ch != nil {
+			s.governor.HumanInChannel(ch.ID)
+		} else if msg.ReceiverID != nil {
+			s.governor.HumanToAgent(*msg.ReceiverID)
+		}
+		return nil
+	}
+
+	var v ConversationVerdict
+	var receiver *domain.Agent
+	switch {
+	case ch != nil:
+		v = s.governor.CheckChannel(msg.CompanyID, ch.ID, sender.ID)
+	case msg.ReceiverID != nil:
+		var err error
+		if receiver, err = s.agentRepo.GetByID(ctx, *msg.ReceiverID); err != nil {
+			return err
+		}
+		if receiver == nil || receiver.IsHuman {
+			return nil
+		}
+		v = s.governor.CheckPair(msg.CompanyID, sender.ID, receiver.ID)
+	default:
+		return nil
+	}
+	if v.Allowed {
+		return nil
+	}
+	if v.Tripped {
+		s.onConversationTripped(ctx, ch, sender, receiver, v.Reason)
+	}
+	return fmt.Errorf("conversation paused: %s（约 %d 分钟后或人类发言后恢复）", v.Reason, int(v.RetryAfter.Round(time.Minute).Minutes()))
+}
+
+// onConversationTripped 在对话中发布系统消息说明暂停原因，并私信一位人类成员
+func (s *MessageService) onConversationTripped(ctx context.Context, ch *domain.Channel, sender, receiver *domain.Agent, reason string) {
+	notice := fmt.Sprintf("⏸️ %s。AI 成员在此对话中的发言已暂停，人类成员发言后立即恢复。", reason)
+	if ch != nil {
+		s.postSystem(ctx, &domain.Message{CompanyID: ch.CompanyID, ChannelID: &ch.ID, Content: notice}, ch)
+	} else {
+		for _, a := range []*domain.Agent{sender, receiver} {
+			s.postSystem(ctx, &domain.Message{CompanyID: sender.CompanyID, ReceiverID: &a.ID, Content: notice}, nil)
+		}
+	}
+
+	agents, err := s.agentRepo.GetByCompany(ctx, sender.CompanyID)
+	if err != nil {
+		log.Printf("[message] conversation governor: list agents: %v", err)
+		return
+	}
+	var human *domain.Agent
+	for _, a := range agents {
+		if !a.IsHuman {
+			continue
+		}
+		if human == nil || (a.RoleType == domain.RoleChairman && human.RoleType != domain.RoleChairman) {
+			human = a
+		}
+	}
+	if human == nil {
+		log.Printf("[message] conversation governor: no human to notify in company %s", sender.CompanyID)
+		return
+	}
+
+	var where string
+	if ch != nil {
+		where = "频道 #" + ch.Name + " 中"
+	} else {
+		where = fmt.Sprintf("%s 与 %s 的私信中", sender.Name, receiver.Name)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "⚠️ %s的 AI 对话已被暂停：%s。\n", where, reason)
+	fmt.Fprintf(&b, "最后发言：%s。", sender.Name)
+	if ch != nil {
+		b.WriteString("在该频道发言即可恢复。")
+	} else {
+		b.WriteString("私信其中任意一方即可恢复。")
+	}
+	s.postSystem(ctx, &domain.Message{CompanyID: sender.CompanyID, ReceiverID: &human.ID, Content: b.String()}, nil)
+}
+
+// postSystem 持久化并发布一条系统消息（ch 非空时带上频道名与私有标记，供推送过滤）
+func (s *MessageService) postSystem(ctx context.Context, msg *domain.Message, ch *domain.Channel) {
+	msg.ID = uuid.New().String()
+	msg.MsgType = domain.MsgTypeSystem
+	if err := s.messageRepo.Create(ctx, msg); err != nil {
+		log.Printf("[message] system message: %v", err)
+		return
+	}
+	payload := event.MessageNewPayload{
+		MessageID:  msg.ID,
+		CompanyID:  msg.CompanyID,
+		ChannelID:  msg.ChannelID,
+		ReceiverID: msg.ReceiverID,
+		MsgType:    string(msg.MsgType),
+		Content:    msg.Content,
+		CreatedAt:  msg.CreatedAt.Format(time.RFC3339),
+	}
+	if ch != nil {
+		payload.ChannelName = &ch.Name
+		payload.Private = ch.IsPrivate
+	}
+	event.Global.Publish(event.NewEvent(event.MessageNew, payload))
+}
+
+// Governor 对话预算器（未启用时为 nil）
+func (s *MessageService) Governor() *ConversationGovernor {
+	return s.governor
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+// resolveMentions 解析消息中的 @ 提及并写入 msg.Mentions / msg.MentionGroups。
+// 个人提及只查询名称出现在 @ 之后的同公司成员；群组提及只在频道消息中生效，按频道可见成员展开。
+// 返回发送者（系统消息为 nil），供事件 payload 标记是否为人类发送。
+func (s *MessageService) resolveMentions(ctx context.Context, msg *domain.Message, ch *domain.Channel) (*domain.Agent, error) {
+	var sender *domain.Agent
+	if msg.SenderID != nil {
+		a, err := s.agentRepo.GetByID(ctx, *msg.SenderID)
+		if err != nil {
+			return nil, err
+		}
+		if a != nil && a.CompanyID == msg.CompanyID {
+			sender = a
+		}
+	}
+
+	var ids []string
+	if text := mentionCandidates(msg.Content); text != "" {
+		candidates, err := s.agentRepo.ListMentioned(ctx, msg.CompanyID, text)
+		if err != nil {
+			return nil, err
+		}
+		ids = newMentionDirectory(candidates).parse(msg.Content)
+	}
+	groups := domain.StringList{}
+	if ch != nil {
+		groups = parseMentionGroups(msg.Content)
+		if len(groups) > 0 {
+			agents, err := s.agentRepo.GetByCompany(ctx, msg.CompanyID)
+			if err != nil {
+				return nil, err
+			}
+			audience, err := s.channelAudience(ctx, ch, agents)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, expandMentionGroups(groups, audience, sender)...)
+		}
+	}
+
+	mentions := domain.StringList{}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if seen[id] || (msg.SenderID != nil && id == *msg.SenderID) {
+			continue
+		}
+		seen[id] = true
+		mentions = append(mentions, id)
+	}
+	msg.Mentions = mentions
+	msg.MentionGroups = groups
+	return sender, nil
+}
+
+// mentionFragmentMax 每个 @ 之后参与候选查询的最大字符数，足以覆盖「职位-名字」形式的展示名
+const mentionFragmentMax = 64
+
+// mentionCandidates 截取每个 @ 之后的片段（到下一个 @ 或换行为止），以换行拼接；没有 @ 时返回空串
+func mentionCandidates(content string) string {
+	var parts []string
+	for _, frag := range strings.Split(content, "@")[1:] {
+		if i := strings.IndexByte(frag, '\n'); i >= 0 {
+			frag = frag[:i]
+		}
+		if r := []rune(frag); len(r) > mentionFragmentMax {
+			frag = string(r[:mentionFragmentMax])
+		}
+		if frag != "" {
+			parts = append(parts, frag)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// channelAudience 频道可见成员：公开频道为全公司，私有频道为显式成员
+func (s *MessageService) channelAudience(ctx context.Context, ch *domain.Channel, agents []*domain.Agent) ([]*domain.Agent, error) {
+	if !ch.IsPrivate {
+		return agents, nil
+	}
+	members, err := s.channelRepo.ListMembers(ctx, ch.ID)
+	if err != nil {
+		return nil, err
+	}
+	isMember := make(map[string]bool, len(members))
+	for _, m := range members {
+		isMember[m.AgentID] = true
+	}
+	audience := make([]*domain.Agent, 0, len(members))
+	for _, a := range agents {
+		if isMember[a.ID] {
+			audience = append(audience, a)
+		}
+	}
+	return audience, nil
+}
+
+// parseMentionGroups 提取 @channel / @here / @department 群组提及（去重，保持出现顺序）。
+// 关键字后不能紧跟字母数字或下划线，避免把 @channelbot 之类的名字当成群组提及。
+func parseMentionGroups(content string) domain.StringList {
+	var groups domain.StringList
+	seen := map[domain.MentionGroup]bool{}
+	for i := strings.IndexByte(content, '@'); i >= 0; {
+		rest := content[i+1:]
+		for _, g := range domain.MentionGroups {
+			if !strings.HasPrefix(rest, string(g)) || isMentionWordByte(rest, len(g)) {
+				continue
+			}
+			if !seen[g] {
+				seen[g] = true
+				groups = append(groups, string(g))
+			}
+			break
+		}
+		next := strings.IndexByte(rest, '@')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return groups
+}
+
+func isMentionWordByte(s string, i int) bool {
+	if i >= len(s) {
+		return false
+	}
+	c := s[i]
+	return c == '_' || c == '-' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// expandMentionGroups 将群组提及展开为 audience 中的 agent ID
+func expandMentionGroups(groups domain.StringList, audience []*domain.Agent, sender *domain.Agent) []string {
+	var ids []string
+	for _, a := range audience {
+		for _, g := range groups {
+			if mentionGroupIncludes(domain.MentionGroup(g), a, sender) {
+				ids = append(ids, a.ID)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+func mentionGroupIncludes(g domain.MentionGroup, a, sender *domain.Agent) bool {
+	switch g {
+	case domain.MentionChannel:
+		return true
+	case domain.MentionHere:
+		return a.Status != domain.StatusOffline
+	case domain.MentionDepartment:
+		return sameDepartment(a, sender)
+	}
+	return false
+}
+
+// sameDepartment 优先按 department_id 比较，未分配部门时按职位所属部门比较
+func sameDepartment(a, sender *domain.Agent) bool {
+	if sender == nil {
+		return false
+	}
+	if sender.DepartmentID != nil {
+		return a.DepartmentID != nil && *a.DepartmentID == *sender.DepartmentID
+	}
+	slug := sender.DepartmentSlug()
+	return slug != "" && a.DepartmentID == nil && a.DepartmentSlug() == slug
+}
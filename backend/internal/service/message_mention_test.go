@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+func TestParseMentionGroups(t *testing.T) {
+	tests := []struct {
+		content string
+		want    domain.StringList
+	}{
+		{"@channel 今晚发版", domain.StringList{"channel"}},
+		{"@here 谁有空？@channel 也看看，@here", domain.StringList{"here", "channel"}},
+		{"@department请同步进度", domain.StringList{"department"}},
+		{"@channelbot 不是群组提及", nil},
+		{"mail@here_domain.com", nil},
+		{"没有提及", nil},
+	}
+	for _, tt := range tests {
+		if got := parseMentionGroups(tt.content); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseMentionGroups(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestExpandMentionGroups(t *testing.T) {
+	dept := "d1"
+	sender := &domain.Agent{ID: "s", DepartmentID: &dept, Status: domain.StatusOnline}
+	audience := []*domain.Agent{
+		sender,
+		{ID: "a1", DepartmentID: &dept, Status: domain.StatusOffline},
+		{ID: "a2", Status: domain.StatusOnline},
+		{ID: "a3", Position: domain.PositionCTO, Status: domain.StatusBusy},
+	}
+	tests := []struct {
+		groups domain.StringList
+		want   []string
+	}{
+		{domain.StringList{"channel"}, []string{"s", "a1", "a2", "a3"}},
+		{domain.StringList{"here"}, []string{"s", "a2", "a3"}},
+		{domain.StringList{"department"}, []string{"s", "a1"}},
+		{domain.StringList{"department", "here"}, []string{"s", "a1", "a2", "a3"}},
+	}
+	for _, tt := range tests {
+		if got := expandMentionGroups(tt.groups, audience, sender); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandMentionGroups(%v) = %v, want %v", tt.groups, got, tt.want)
+		}
+	}
+
+	// 未分配 department_id 时按职位所属部门比较
+	cto := &domain.Agent{ID: "c", Position: domain.PositionCTO}
+	if got := expandMentionGroups(domain.StringList{"department"}, audience, cto); !reflect.DeepEqual(got, []string{"a3"}) {
+		t.Errorf("expand department by position = %v", got)
+	}
+}
+
+// rosterCountingAgentRepo 记录全公司花名册与提及候选的查询
+type rosterCountingAgentRepo struct {
+	*memTaskAgentRepo
+	rosterLoads int
+	mentioned   []string
+}
+
+func (r *rosterCountingAgentRepo) GetByCompany(ctx context.Context, companyID string) ([]*domain.Agent, error) {
+	r.rosterLoads++
+	return r.memTaskAgentRepo.GetByCompany(ctx, companyID)
+}
+
+func (r *rosterCountingAgentRepo) ListMentioned(ctx context.Context, companyID, text string) ([]*domain.Agent, error) {
+	r.mentioned = append(r.mentioned, text)
+	return r.memTaskAgentRepo.ListMentioned(ctx, companyID, text)
+}
+
+func TestResolveMentions_LooksUpOnlyMentionedNames(t *testing.T) {
+	repo := &rosterCountingAgentRepo{memTaskAgentRepo: &memTaskAgentRepo{agents: map[string]*domain.Agent{
+		"s":  {ID: "s", CompanyID: "c1", Name: "老板", Status: domain.StatusOnline},
+		"a1": {ID: "a1", CompanyID: "c1", Name: "小王", Position: domain.PositionCTO, Status: domain.StatusOnline},
+		"a2": {ID: "a2", CompanyID: "c1", Name: "小李", Status: domain.StatusOffline},
+		"x":  {ID: "x", CompanyID: "c2", Name: "小王"},
+	}}}
+	svc := &MessageService{agentRepo: repo}
+	sender := "s"
+	ch := &domain.Channel{ID: "ch", CompanyID: "c1"}
+
+	tests := []struct {
+		content     string
+		want        domain.StringList
+		wantLookup  string
+		rosterLoads int
+	}{
+		{"没有提及", domain.StringList{}, "", 0},
+		{"@小王请看一下，@老板 自己", domain.StringList{"a1"}, "小王请看一下，\n老板 自己", 0},
+		{"@技术总监-小王\n第二行 @小李", domain.StringList{"a1", "a2"}, "技术总监-小王\n小李", 0},
+		{"@here 上线了", domain.StringList{"a1"}, "here 上线了", 1},
+	}
+	for _, tt := range tests {
+		repo.rosterLoads, repo.mentioned = 0, nil
+		msg := &domain.Message{CompanyID: "c1", SenderID: &sender, Content: tt.content}
+		got, err := svc.resolveMentions(context.Background(), msg, ch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == nil || got.ID != "s" {
+			t.Errorf("%q: sender = %+v", tt.content, got)
+		}
+		if !reflect.DeepEqual(msg.Mentions, tt.want) {
+			t.Errorf("%q: mentions = %v, want %v", tt.content, msg.Mentions, tt.want)
+		}
+		var lookup string
+		if len(repo.mentioned) > 0 {
+			lookup = repo.mentioned[0]
+		}
+		if lookup != tt.wantLookup || repo.rosterLoads != tt.rosterLoads {
+			t.Errorf("%q: looked up %q with %d roster loads, want %q and %d", tt.content, lookup, repo.rosterLoads, tt.wantLookup, tt.rosterLoads)
+		}
+	}
+}
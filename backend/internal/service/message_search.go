@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+// MessageSearchMode 实际使用的搜索方式
+type MessageSearchMode string
+
+const (
+	MessageSearchFullText MessageSearchMode = "fulltext"
+	MessageSearchSemantic MessageSearchMode = "semantic"
+)
+
+const messageSearchMaxLimit = 100
+
+// MessageSearchService 消息历史搜索：Postgres 全文检索 + 可选的 embedding 语义检索
+type MessageSearchService struct {
+	messageRepo  repository.MessageRepo
+	companyRepo  repository.CompanyRepo
+	messageSvc   *MessageService
+	embeddingCli *EmbeddingClient
+}
+
+func NewMessageSearchService(messageRepo repository.MessageRepo, companyRepo repository.CompanyRepo, messageSvc *MessageService, embeddingCli *EmbeddingClient) *MessageSearchService {
+	return &MessageSearchService{
+		messageRepo:  messageRepo,
+		companyRepo:  companyRepo,
+		messageSvc:   messageSvc,
+		embeddingCli: embeddingCli,
+	}
+}
+
+type SearchMessagesInput struct {
+	Query     string
+	Channel   string // 频道名，私有频道要求是成员
+	SenderID  string
+	MentionID string
+	After     *time.Time
+	Before    *time.Time
+	Semantic  bool // 优先语义搜索；公司未配置 embedding 或 pgvector 不可用时降级为全文搜索
+	Limit     int
+	Offset    int
+}
+
+type MessageSearchResult struct {
+	Messages []*domain.Message `json:"data"`
+	Mode     MessageSearchMode `json:"mode"`
+	HasMore  bool              `json:"has_more"`
+}
+
+// Search 搜索 viewer 可见的消息
+func (s *MessageSearchService) Search(ctx context.Context, viewer *domain.Agent, in SearchMessagesInput) (*MessageSearchResult, error) {
+	in.Query = strings.TrimSpace(in.Query)
+	if in.Semantic && in.Query == "" {
+		return nil, fmt.Errorf("query is required for semantic search")
+	}
+	if in.After != nil && in.Before != nil && !in.After.Before(*in.Before) {
+		return nil, fmt.Errorf("after must be earlier than before")
+	}
+	if in.Limit <= 0 {
+		in.Limit = 20
+	}
+	if in.Limit > messageSearchMaxLimit {
+		in.Limit = messageSearchMaxLimit
+	}
+	if in.Offset < 0 {
+		in.Offset = 0
+	}
+
+	q := repository.MessageQuery{
+		CompanyID: viewer.CompanyID,
+		ViewerID:  viewer.ID,
+		Text:      in.Query,
+		SenderID:  in.SenderID,
+		MentionID: in.MentionID,
+		After:     in.After,
+		Before:    in.Before,
+		Limit:     in.Limit + 1,
+		Offset:    in.Offset,
+	}
+	if in.Channel != "" {
+		ch, err := s.messageSvc.accessibleChannel(ctx, viewer.CompanyID, viewer.ID, in.Channel)
+		if err != nil {
+			return nil, err
+		}
+		q.ChannelID = ch.ID
+	}
+
+	var (
+		msgs []*domain.Message
+		mode = MessageSearchFullText
+		err  error
+	)
+	if in.Semantic {
+		if msgs, err = s.semantic(ctx, q); err == nil {
+			mode = MessageSearchSemantic
+		} else {
+			log.Printf("[message-search] semantic search unavailable, falling back to full text: %v", err)
+		}
+	}
+	if mode == MessageSearchFullText {
+		if msgs, err = s.messageRepo.Search(ctx, q); err != nil {
+			return nil, err
+		}
+	}
+
+	res := &MessageSearchResult{Messages: msgs, Mode: mode}
+	if len(msgs) > in.Limit {
+		res.Messages = msgs[:in.Limit]
+		res.HasMore = true
+	}
+	return res, s.messageSvc.attachReactions(ctx, res.Messages)
+}
+
+func (s *MessageSearchService) semantic(ctx context.Context, q repository.MessageQuery) ([]*domain.Message, error) {
+	if s.embeddingCli == nil {
+		return nil, fmt.Errorf("embedding client not configured")
+	}
+	company, err := s.companyRepo.GetByID(ctx, q.CompanyID)
+	if err != nil {
+		return nil, fmt.Errorf("get company: %w", err)
+	}
+	if company == nil || company.EmbeddingBaseURL == "" {
+		return nil, fmt.Errorf("embedding not configured for company")
+	}
+	vec, err := s.embeddingCli.Generate(ctx, company.EmbeddingBaseURL, company.EmbeddingModel, company.EmbeddingApiKey, q.Text)
+	if err != nil {
+		return nil, fmt.Errorf("generate query embedding: %w", err)
+	}
+	return s.messageRepo.SemanticSearch(ctx, q, vec)
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+// searchMessageRepo 只实现搜索相关方法，其余方法调用会 panic
+type searchMessageRepo struct {
+	repository.MessageRepo
+	got   repository.MessageQuery
+	found int
+}
+
+func (r *searchMessageRepo) Search(_ context.Context, q repository.MessageQuery) ([]*domain.Message, error) {
+	r.got = q
+	msgs := make([]*domain.Message, r.found)
+	for i := range msgs {
+		msgs[i] = &domain.Message{ID: string(rune('a' + i))}
+	}
+	return msgs, nil
+}
+
+func (r *searchMessageRepo) ListReactions(context.Context, []string) ([]*domain.MessageReaction, error) {
+	return nil, nil
+}
+
+func TestMessageSearchFallsBackToFullText(t *testing.T) {
+	repo := &searchMessageRepo{found: 3}
+	svc := NewMessageSearchService(repo, nil, NewMessageService(repo, nil, nil, nil, nil, nil, nil, nil, nil, nil), nil)
+	viewer := &domain.Agent{ID: "v", CompanyID: "c"}
+
+	res, err := svc.Search(context.Background(), viewer, SearchMessagesInput{Query: " 迁移 ", Semantic: true, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Mode != MessageSearchFullText {
+		t.Errorf("mode = %s, want fulltext fallback", res.Mode)
+	}
+	if len(res.Messages) != 2 || !res.HasMore {
+		t.Errorf("got %d messages, has_more=%v", len(res.Messages), res.HasMore)
+	}
+	if repo.got.Text != "迁移" || repo.got.ViewerID != "v" || repo.got.CompanyID != "c" || repo.got.Limit != 3 {
+		t.Errorf("query = %+v", repo.got)
+	}
+}
+
+func TestMessageSearchValidation(t *testing.T) {
+	svc := NewMessageSearchService(&searchMessageRepo{}, nil, nil, nil)
+	viewer := &domain.Agent{ID: "v", CompanyID: "c"}
+	if _, err := svc.Search(context.Background(), viewer, SearchMessagesInput{Semantic: true}); err == nil {
+		t.Error("semantic search without query should fail")
+	}
+}
+
+func TestEmbeddingWorkerSkipsMessagesWithoutPgvector(t *testing.T) {
+	// pgvector 不可用时不应列出待处理消息（searchMessageRepo 未实现 ListPendingEmbedding，调用即 panic）
+	w := NewEmbeddingWorker(nil, &searchMessageRepo{}, nil, nil)
+	w.processMessages(context.Background())
+}
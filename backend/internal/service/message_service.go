@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,25 +11,60 @@ import (
 	"github.com/linkclaw/backend/internal/domain"
 	"github.com/linkclaw/backend/internal/event"
 	"github.com/linkclaw/backend/internal/repository"
+	"github.com/linkclaw/backend/internal/storage"
 )
 
 type MessageService struct {
 	messageRepo repository.MessageRepo
 	companyRepo repository.CompanyRepo
+	channelRepo repository.ChannelRepo
+	agentRepo   repository.AgentRepo
+	// 富消息卡片的数据来源与附件存储
+	taskRepo      repository.TaskRepo
+	knowledgeRepo repository.KnowledgeRepo
+	orgSvc        *OrganizationService
+	files         storage.Storage
+	governor      *ConversationGovernor // AI 之间的对话预算，nil 表示不限制
+	tx            repository.Transactor // nil 时不开启事务
 }
 
-func NewMessageService(messageRepo repository.MessageRepo, companyRepo repository.CompanyRepo) *MessageService {
-	return &MessageService{messageRepo: messageRepo, companyRepo: companyRepo}
+func NewMessageService(
+	messageRepo repository.MessageRepo,
+	companyRepo repository.CompanyRepo,
+	channelRepo repository.ChannelRepo,
+	agentRepo repository.AgentRepo,
+	taskRepo repository.TaskRepo,
+	knowledgeRepo repository.KnowledgeRepo,
+	orgSvc *OrganizationService,
+	files storage.Storage,
+	governor *ConversationGovernor,
+	tx repository.Transactor,
+) *MessageService {
+	return &MessageService{
+		messageRepo:   messageRepo,
+		companyRepo:   companyRepo,
+		channelRepo:   channelRepo,
+		agentRepo:     agentRepo,
+		taskRepo:      taskRepo,
+		knowledgeRepo: knowledgeRepo,
+		orgSvc:        orgSvc,
+		files:         files,
+		governor:      governor,
+		tx:            tx,
+	}
 }
 
 type SendMessageInput = SendInput
 
 type SendInput struct {
-	CompanyID  string
-	SenderID   string
-	Channel    string // 群聊频道名，与 ReceiverID 二选一
-	ReceiverID string // DM 目标，与 Channel 二选一
-	Content    string
+	CompanyID    string
+	SenderID     string
+	Channel      string // 群聊频道名，与 ReceiverID 二选一
+	ReceiverID   string // DM 目标，与 Channel 二选一
+	ThreadRootID string // 线程回复：非空时会话由根消息决定，Channel / ReceiverID 被忽略
+	Content      string
+	Blocks       []*domain.MessageBlock // 富消息块；Content 为空时由消息块生成纯文本摘要
+	ScheduleID   string                 // 由定时消息 / 提醒触发时的规则 ID
 }
 
 type MessageOut = domain.Message
@@ -44,14 +80,45 @@ func (s *MessageService) Send(ctx context.Context, in SendInput) (*domain.Messag
 		msg.SenderID = &in.SenderID
 	}
 
+	var root *domain.Message
+	if in.ThreadRootID != "" {
+		var err error
+		if root, err = s.threadRoot(ctx, in.CompanyID, in.ThreadRootID); err != nil {
+			return nil, err
+		}
+		msg.ThreadRootID = &root.ID
+	}
+
+	var ch *domain.Channel
 	switch {
+	case root != nil && root.ChannelID != nil:
+		var err error
+		if ch, err = s.channelRepo.GetByID(ctx, *root.ChannelID); err != nil {
+			return nil, err
+		}
+		if ch == nil || !s.channelReadable(ctx, ch, in.SenderID) {
+			return nil, fmt.Errorf("message not found")
+		}
+		if ch.IsArchived() {
+			return nil, fmt.Errorf("channel %q is archived", ch.Name)
+		}
+		msg.ChannelID = &ch.ID
+
+	case root != nil:
+		// 私信线程：回复对象为会话的另一方
+		peer, ok := dmPeer(root, in.SenderID)
+		if !ok {
+			return nil, fmt.Errorf("message not found")
+		}
+		msg.ReceiverID = &peer
+
 	case in.Channel != "":
-		ch, err := s.companyRepo.GetChannelByName(ctx, in.CompanyID, in.Channel)
-		if err != nil {
+		var err error
+		if ch, err = s.accessibleChannel(ctx, in.CompanyID, in.SenderID, in.Channel); err != nil {
 			return nil, err
 		}
-		if ch == nil {
-			return nil, fmt.Errorf("channel %q not found", in.Channel)
+		if ch.IsArchived() {
+			return nil, fmt.Errorf("channel %q is archived", in.Channel)
 		}
 		msg.ChannelID = &ch.ID
 
@@ -65,42 +132,120 @@ func (s *MessageService) Send(ctx context.Context, in SendInput) (*domain.Messag
 		return nil, fmt.Errorf("must specify channel or receiver_id")
 	}
 
-	if err := s.messageRepo.Create(ctx, msg); err != nil {
+	var attachmentIDs []string
+	if len(in.Blocks) > 0 {
+		var err error
+		if attachmentIDs, err = s.prepareBlocks(ctx, msg, in.SenderID, in.Blocks); err != nil {
+			return nil, err
+		}
+	}
+	if strings.TrimSpace(msg.Content) == "" {
+		return nil, fmt.Errorf("content or blocks is required")
+	}
+
+	sender, err := s.resolveMentions(ctx, msg, ch)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.govern(ctx, msg, ch, sender); err != nil {
+		return nil, err
+	}
+	// 消息与附件关联在同一事务中：附件已被其他消息占用或被清理时整条消息不发送
+	create := func(ctx context.Context) error {
+		if err := s.messageRepo.Create(ctx, msg); err != nil {
+			return err
+		}
+		n, err := s.messageRepo.LinkAttachments(ctx, msg.ID, attachmentIDs)
+		if err != nil {
+			return err
+		}
+		if int(n) != len(attachmentIDs) {
+			return fmt.Errorf("attachments are no longer available (linked %d of %d)", n, len(attachmentIDs))
+		}
+		return nil
+	}
+	if s.tx != nil {
+		err = s.tx.WithinTx(ctx, create)
+	} else {
+		err = create(ctx)
+	}
+	if err != nil {
 		return nil, err
 	}
 	// 发布新消息事件（供 WS Hub 实时推送给前端）
-	var channelName *string
-	if in.Channel != "" {
-		channelName = &in.Channel
+	payload := event.MessageNewPayload{
+		MessageID:     msg.ID,
+		CompanyID:     msg.CompanyID,
+		ChannelID:     msg.ChannelID,
+		ReceiverID:    msg.ReceiverID,
+		SenderID:      msg.SenderID,
+		SenderHuman:   sender != nil && sender.IsHuman,
+		MsgType:       string(msg.MsgType),
+		Content:       msg.Content,
+		Blocks:        msg.Blocks,
+		Mentions:      msg.Mentions,
+		MentionGroups: msg.MentionGroups,
+		CreatedAt:     msg.CreatedAt.Format(time.RFC3339),
+		ThreadRootID:  msg.ThreadRootID,
 	}
-	event.Global.Publish(event.NewEvent(event.MessageNew, event.MessageNewPayload{
-		MessageID:   msg.ID,
-		CompanyID:   msg.CompanyID,
-		ChannelID:   msg.ChannelID,
-		ChannelName: channelName,
-		ReceiverID:  msg.ReceiverID,
-		SenderID:    msg.SenderID,
-		MsgType:     string(msg.MsgType),
-		Content:     msg.Content,
-		CreatedAt:   msg.CreatedAt.Format(time.RFC3339),
-	}))
+	if in.ScheduleID != "" {
+		payload.ScheduleID = &in.ScheduleID
+	}
+	if ch != nil {
+		payload.ChannelName = &ch.Name
+		payload.Private = ch.IsPrivate
+	}
+	if root != nil {
+		payload.ThreadRootSenderID = root.SenderID
+	}
+	event.Global.Publish(event.NewEvent(event.MessageNew, payload))
 	return msg, nil
 }
 
-func (s *MessageService) GetChannelMessages(ctx context.Context, companyID, channelName string, limit int, beforeID string) ([]*domain.Message, error) {
-	ch, err := s.companyRepo.GetChannelByName(ctx, companyID, channelName)
-	if err != nil || ch == nil {
+// accessibleChannel 按名称查找频道；私有频道要求 agentID 为成员（agentID 为空表示系统消息，不做限制）
+func (s *MessageService) accessibleChannel(ctx context.Context, companyID, agentID, channelName string) (*domain.Channel, error) {
+	ch, err := s.channelRepo.GetByName(ctx, companyID, channelName)
+	if err != nil {
+		return nil, err
+	}
+	if ch == nil {
+		return nil, fmt.Errorf("channel %q not found", channelName)
+	}
+	if !s.channelReadable(ctx, ch, agentID) {
 		return nil, fmt.Errorf("channel %q not found", channelName)
 	}
-	return s.messageRepo.ListByChannel(ctx, ch.ID, limit, beforeID)
+	return ch, nil
 }
 
-func (s *MessageService) GetDMMessages(ctx context.Context, agentA, agentB string, limit int, beforeID string) ([]*domain.Message, error) {
-	return s.messageRepo.ListDM(ctx, agentA, agentB, limit, beforeID)
+// channelReadable 公开频道全员可读写；私有频道仅成员（agentID 为空视为系统，不限制）
+func (s *MessageService) channelReadable(ctx context.Context, ch *domain.Channel, agentID string) bool {
+	return !ch.IsPrivate || agentID == "" || s.IsChannelMember(ctx, ch.ID, agentID)
+}
+
+// IsChannelMember agentID 是否为频道的显式成员
+func (s *MessageService) IsChannelMember(ctx context.Context, channelID, agentID string) bool {
+	m, err := s.channelRepo.GetMember(ctx, channelID, agentID)
+	return err == nil && m != nil
+}
+
+func (s *MessageService) GetChannelMessages(ctx context.Context, companyID, agentID, channelName string, limit int, beforeID string) ([]*domain.Message, error) {
+	ch, err := s.accessibleChannel(ctx, companyID, agentID, channelName)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := s.messageRepo.ListByChannel(ctx, ch.ID, limit, beforeID)
+	if err != nil {
+		return nil, err
+	}
+	return msgs, s.attachReactions(ctx, msgs)
 }
 
-func (s *MessageService) ListChannels(ctx context.Context, companyID string) ([]*domain.Channel, error) {
-	return s.companyRepo.GetChannels(ctx, companyID)
+func (s *MessageService) GetDMMessages(ctx context.Context, agentA, agentB string, limit int, beforeID string) ([]*domain.Message, error) {
+	msgs, err := s.messageRepo.ListDM(ctx, agentA, agentB, limit, beforeID)
+	if err != nil {
+		return nil, err
+	}
+	return msgs, s.attachReactions(ctx, msgs)
 }
 
 func (s *MessageService) MarkRead(ctx context.Context, agentID string, messageIDs []string) error {
@@ -124,6 +269,7 @@ func (s *MessageService) SendRaw(ctx context.Context, msg *domain.Message) error
 		SenderID:   msg.SenderID,
 		MsgType:    string(msg.MsgType),
 		Content:    msg.Content,
+		Mentions:   msg.Mentions,
 		CreatedAt:  msg.CreatedAt.Format(time.RFC3339),
 	}))
 	return nil
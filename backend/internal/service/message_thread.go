@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/event"
+)
+
+// messageEmojiMaxLen 表情回应的最大长度（字节），兼容 ":+1:" 形式的短码与组合 emoji
+const messageEmojiMaxLen = 64
+
+// threadRoot 加载线程根消息；传入的是回复时归到其根消息（线程只有一层）
+func (s *MessageService) threadRoot(ctx context.Context, companyID, messageID string) (*domain.Message, error) {
+	root, err := s.messageRepo.GetByID(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if root != nil && root.ThreadRootID != nil {
+		if root, err = s.messageRepo.GetByID(ctx, *root.ThreadRootID); err != nil {
+			return nil, err
+		}
+	}
+	if root == nil || root.CompanyID != companyID {
+		return nil, fmt.Errorf("message not found")
+	}
+	if root.IsDeleted() {
+		return nil, fmt.Errorf("cannot reply to a deleted message")
+	}
+	return root, nil
+}
+
+// dmPeer 返回私信会话中 agentID 的对方；agentID 不是会话参与者时 ok=false
+func dmPeer(m *domain.Message, agentID string) (string, bool) {
+	sender, receiver := "", ""
+	if m.SenderID != nil {
+		sender = *m.SenderID
+	}
+	if m.ReceiverID != nil {
+		receiver = *m.ReceiverID
+	}
+	switch agentID {
+	case sender:
+		return receiver, receiver != ""
+	case receiver:
+		return sender, sender != ""
+	}
+	return "", false
+}
+
+// loadVisible 加载 agent 可见的消息：频道消息需可读该频道，私信需为会话一方
+func (s *MessageService) loadVisible(ctx context.Context, agent *domain.Agent, messageID string) (*domain.Message, *domain.Channel, error) {
+	m, err := s.messageRepo.GetByID(ctx, messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if m == nil || m.CompanyID != agent.CompanyID {
+		return nil, nil, fmt.Errorf("message not found")
+	}
+	if m.ChannelID == nil {
+		if _, ok := dmPeer(m, agent.ID); !ok {
+			return nil, nil, fmt.Errorf("message not found")
+		}
+		return m, nil, nil
+	}
+	ch, err := s.channelRepo.GetByID(ctx, *m.ChannelID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ch == nil || !s.channelReadable(ctx, ch, agent.ID) {
+		return nil, nil, fmt.Errorf("message not found")
+	}
+	return m, ch, nil
+}
+
+// attachReactions 为消息列表填充按表情聚合的回应
+func (s *MessageService) attachReactions(ctx context.Context, msgs []*domain.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		ids = append(ids, m.ID)
+	}
+	reactions, err := s.messageRepo.ListReactions(ctx, ids)
+	if err != nil {
+		return err
+	}
+	grouped := groupReactions(reactions)
+	for _, m := range msgs {
+		m.Reactions = grouped[m.ID]
+	}
+	return nil
+}
+
+// groupReactions 按消息、表情聚合回应，表情顺序为首次出现顺序
+func groupReactions(reactions []*domain.MessageReaction) map[string][]*domain.MessageReactionCount {
+	out := map[string][]*domain.MessageReactionCount{}
+	index := map[[2]string]*domain.MessageReactionCount{}
+	for _, r := range reactions {
+		key := [2]string{r.MessageID, r.Emoji}
+		c, ok := index[key]
+		if !ok {
+			c = &domain.MessageReactionCount{Emoji: r.Emoji}
+			index[key] = c
+			out[r.MessageID] = append(out[r.MessageID], c)
+		}
+		c.Count++
+		c.AgentIDs = append(c.AgentIDs, r.AgentID)
+	}
+	return out
+}
+
+// GetThread 返回线程根消息与回复；messageID 可以是根消息或其中任一回复
+func (s *MessageService) GetThread(ctx context.Context, agent *domain.Agent, messageID string, limit int, afterID string) (*domain.Message, []*domain.Message, error) {
+	m, _, err := s.loadVisible(ctx, agent, messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	root := m
+	if m.ThreadRootID != nil {
+		if root, err = s.messageRepo.GetByID(ctx, *m.ThreadRootID); err != nil {
+			return nil, nil, err
+		}
+		if root == nil {
+			return nil, nil, fmt.Errorf("message not found")
+		}
+	}
+	replies, err := s.messageRepo.ListThread(ctx, root.ID, limit, afterID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.attachReactions(ctx, append([]*domain.Message{root}, replies...)); err != nil {
+		return nil, nil, err
+	}
+	return root, replies, nil
+}
+
+// Edit 修改消息内容（仅发送者本人），原文保存在编辑历史中
+func (s *MessageService) Edit(ctx context.Context, actor *domain.Agent, messageID, content string) (*domain.Message, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, fmt.Errorf("content is required")
+	}
+	m, ch, err := s.loadVisible(ctx, actor, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if m.SenderID == nil || *m.SenderID != actor.ID {
+		return nil, fmt.Errorf("permission denied")
+	}
+	if m.IsDeleted() {
+		return nil, fmt.Errorf("message has been deleted")
+	}
+	if m.Content == content {
+		return m, nil
+	}
+	// 重新解析提及：新增的 @ 会收到编辑通知，未读追踪也随之更新
+	m.Content = content
+	if _, err := s.resolveMentions(ctx, m, ch); err != nil {
+		return nil, err
+	}
+	if err := s.messageRepo.UpdateContent(ctx, m.ID, actor.ID, content, m.Mentions, m.MentionGroups); err != nil {
+		return nil, err
+	}
+	if m, err = s.messageRepo.GetByID(ctx, m.ID); err != nil {
+		return nil, err
+	}
+	s.publishChanged(event.MessageEdited, m, ch, actor.ID, func(p *event.MessageChangedPayload) {
+		p.Content = m.Content
+		p.Mentions = m.Mentions
+	})
+	return m, nil
+}
+
+func (s *MessageService) ListEdits(ctx context.Context, actor *domain.Agent, messageID string) ([]*domain.MessageEdit, error) {
+	m, _, err := s.loadVisible(ctx, actor, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if m.IsDeleted() {
+		return nil, fmt.Errorf("message has been deleted")
+	}
+	return s.messageRepo.ListEdits(ctx, m.ID)
+}
+
+// Delete 软删除消息：发送者本人，或与频道管理相同的权限（channel:manage、频道 owner/admin）可操作
+func (s *MessageService) Delete(ctx context.Context, actor *domain.Agent, messageID string) error {
+	m, ch, err := s.loadVisible(ctx, actor, messageID)
+	if err != nil {
+		return err
+	}
+	if m.SenderID == nil || *m.SenderID != actor.ID {
+		ok, err := s.canManageChannel(ctx, actor, ch)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("permission denied")
+		}
+	}
+	if m.IsDeleted() {
+		return nil
+	}
+	if err := s.messageRepo.SoftDelete(ctx, m.ID, actor.ID); err != nil {
+		return err
+	}
+	s.publishChanged(event.MessageDeleted, m, ch, actor.ID, nil)
+	return nil
+}
+
+// canManageChannel 与 ChannelService.loadManaged 一致：channel:manage 或频道 owner/admin；私信只看 channel:manage
+func (s *MessageService) canManageChannel(ctx context.Context, actor *domain.Agent, ch *domain.Channel) (bool, error) {
+	if actor.HasPermission(channelManagePerm) {
+		return true, nil
+	}
+	if ch == nil {
+		return false, nil
+	}
+	member, err := s.channelRepo.GetMember(ctx, ch.ID, actor.ID)
+	if err != nil {
+		return false, err
+	}
+	return member != nil && member.Role.CanManage(), nil
+}
+
+func normalizeEmoji(emoji string) (string, error) {
+	emoji = strings.TrimSpace(emoji)
+	if emoji == "" || len(emoji) > messageEmojiMaxLen || strings.IndexFunc(emoji, unicode.IsSpace) >= 0 {
+		return "", fmt.Errorf("invalid emoji %q", emoji)
+	}
+	return emoji, nil
+}
+
+// React 添加或取消表情回应，返回该消息最新的回应汇总
+func (s *MessageService) React(ctx context.Context, actor *domain.Agent, messageID, emoji string, remove bool) ([]*domain.MessageReactionCount, error) {
+	emoji, err := normalizeEmoji(emoji)
+	if err != nil {
+		return nil, err
+	}
+	m, ch, err := s.loadVisible(ctx, actor, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if m.IsDeleted() {
+		return nil, fmt.Errorf("message has been deleted")
+	}
+	if remove {
+		err = s.messageRepo.RemoveReaction(ctx, m.ID, actor.ID, emoji)
+	} else {
+		err = s.messageRepo.AddReaction(ctx, &domain.MessageReaction{
+			MessageID: m.ID, AgentID: actor.ID, Emoji: emoji, CompanyID: m.CompanyID,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachReactions(ctx, []*domain.Message{m}); err != nil {
+		return nil, err
+	}
+	s.publishChanged(event.MessageReaction, m, ch, actor.ID, func(p *event.MessageChangedPayload) {
+		p.Emoji = emoji
+		p.Removed = remove
+	})
+	return m.Reactions, nil
+}
+
+// Pin 置顶或取消置顶频道消息；频道内可发言的成员均可操作
+func (s *MessageService) Pin(ctx context.Context, actor *domain.Agent, messageID string, pinned bool) (*domain.Message, error) {
+	m, ch, err := s.loadVisible(ctx, actor, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if ch == nil {
+		return nil, fmt.Errorf("only channel messages can be pinned")
+	}
+	if m.IsDeleted() {
+		return nil, fmt.Errorf("message has been deleted")
+	}
+	if ch.IsArchived() {
+		return nil, fmt.Errorf("channel %q is archived", ch.Name)
+	}
+	if (m.PinnedAt != nil) == pinned {
+		return m, nil
+	}
+	var pinnedBy *string
+	if pinned {
+		pinnedBy = &actor.ID
+	}
+	if err := s.messageRepo.SetPinned(ctx, m.ID, pinnedBy); err != nil {
+		return nil, err
+	}
+	if m, err = s.messageRepo.GetByID(ctx, m.ID); err != nil {
+		return nil, err
+	}
+	s.publishChanged(event.MessagePinned, m, ch, actor.ID, func(p *event.MessageChangedPayload) {
+		p.Pinned = pinned
+	})
+	return m, nil
+}
+
+func (s *MessageService) ListPinned(ctx context.Context, agent *domain.Agent, channelName string) ([]*domain.Message, error) {
+	ch, err := s.accessibleChannel(ctx, agent.CompanyID, agent.ID, channelName)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := s.messageRepo.ListPinned(ctx, ch.ID)
+	if err != nil {
+		return nil, err
+	}
+	return msgs, s.attachReactions(ctx, msgs)
+}
+
+func (s *MessageService) publishChanged(t event.Type, m *domain.Message, ch *domain.Channel, actorID string, fill func(*event.MessageChangedPayload)) {
+	p := event.MessageChangedPayload{
+		MessageID:    m.ID,
+		CompanyID:    m.CompanyID,
+		ChannelID:    m.ChannelID,
+		Private:      ch != nil && ch.IsPrivate,
+		ReceiverID:   m.ReceiverID,
+		SenderID:     m.SenderID,
+		ThreadRootID: m.ThreadRootID,
+		ActorID:      actorID,
+	}
+	if fill != nil {
+		fill(&p)
+	}
+	event.Global.Publish(event.NewEvent(t, p))
+}
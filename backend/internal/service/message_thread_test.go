@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+func TestDMPeer(t *testing.T) {
+	a, b := "a1", "a2"
+	m := &domain.Message{SenderID: &a, ReceiverID: &b}
+	if peer, ok := dmPeer(m, "a1"); !ok || peer != "a2" {
+		t.Errorf("dmPeer(sender) = %q, %v", peer, ok)
+	}
+	if peer, ok := dmPeer(m, "a2"); !ok || peer != "a1" {
+		t.Errorf("dmPeer(receiver) = %q, %v", peer, ok)
+	}
+	if _, ok := dmPeer(m, "a3"); ok {
+		t.Error("outsider should not be a DM participant")
+	}
+}
+
+func TestGroupReactions(t *testing.T) {
+	got := groupReactions([]*domain.MessageReaction{
+		{MessageID: "m1", AgentID: "a1", Emoji: "👍"},
+		{MessageID: "m1", AgentID: "a2", Emoji: "🎉"},
+		{MessageID: "m1", AgentID: "a3", Emoji: "👍"},
+		{MessageID: "m2", AgentID: "a1", Emoji: "👍"},
+	})
+	m1 := got["m1"]
+	if len(m1) != 2 || m1[0].Emoji != "👍" || m1[0].Count != 2 || m1[1].Emoji != "🎉" || m1[1].Count != 1 {
+		t.Fatalf("m1 reactions = %+v", m1)
+	}
+	if len(m1[0].AgentIDs) != 2 || m1[0].AgentIDs[1] != "a3" {
+		t.Errorf("m1 👍 agents = %v", m1[0].AgentIDs)
+	}
+	if len(got["m2"]) != 1 || got["m2"][0].Count != 1 {
+		t.Errorf("m2 reactions = %+v", got["m2"])
+	}
+}
+
+func TestNormalizeEmoji(t *testing.T) {
+	for _, ok := range []string{"👍", " :white_check_mark: "} {
+		if _, err := normalizeEmoji(ok); err != nil {
+			t.Errorf("normalizeEmoji(%q) unexpected error: %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"", "  ", "thumbs up"} {
+		if _, err := normalizeEmoji(bad); err == nil {
+			t.Errorf("normalizeEmoji(%q) should fail", bad)
+		}
+	}
+}
+
+// threadMessageRepo 单条消息的读取与软删除
+type threadMessageRepo struct {
+	repository.MessageRepo
+	msg       *domain.Message
+	deletedBy string
+}
+
+func (r *threadMessageRepo) GetByID(context.Context, string) (*domain.Message, error) {
+	cp := *r.msg
+	return &cp, nil
+}
+
+func (r *threadMessageRepo) SoftDelete(_ context.Context, _, actorID string) error {
+	r.deletedBy = actorID
+	return nil
+}
+
+// threadChannelRepo 单个频道及其成员角色
+type threadChannelRepo struct {
+	repository.ChannelRepo
+	ch      *domain.Channel
+	members map[string]domain.ChannelRole
+}
+
+func (r *threadChannelRepo) GetByID(context.Context, string) (*domain.Channel, error) {
+	return r.ch, nil
+}
+
+func (r *threadChannelRepo) GetByName(_ context.Context, _, name string) (*domain.Channel, error) {
+	if r.ch.Name != name {
+		return nil, nil
+	}
+	return r.ch, nil
+}
+
+func (r *threadChannelRepo) GetMember(_ context.Context, channelID, agentID string) (*domain.ChannelMember, error) {
+	role, ok := r.members[agentID]
+	if !ok {
+		return nil, nil
+	}
+	return &domain.ChannelMember{ChannelID: channelID, AgentID: agentID, Role: role}, nil
+}
+
+func TestMessageDelete_Permissions(t *testing.T) {
+	channelID, sender := "ch1", "author"
+	tests := []struct {
+		name    string
+		actor   *domain.Agent
+		private bool
+		wantErr string
+	}{
+		{"sender", &domain.Agent{ID: "author", CompanyID: "c1"}, false, ""},
+		{"channel owner", &domain.Agent{ID: "owner", CompanyID: "c1"}, false, ""},
+		{"channel admin", &domain.Agent{ID: "admin", CompanyID: "c1"}, true, ""},
+		{"channel:manage", &domain.Agent{ID: "mod", CompanyID: "c1", Permissions: []string{channelManagePerm}}, false, ""},
+		{"chairman", &domain.Agent{ID: "boss", CompanyID: "c1", RoleType: domain.RoleChairman}, false, ""},
+		{"plain member", &domain.Agent{ID: "member", CompanyID: "c1"}, false, "permission denied"},
+		{"non-member of private channel", &domain.Agent{ID: "outsider", CompanyID: "c1"}, true, "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs := &threadMessageRepo{msg: &domain.Message{ID: "m1", CompanyID: "c1", ChannelID: &channelID, SenderID: &sender}}
+			channels := &threadChannelRepo{
+				ch: &domain.Channel{ID: channelID, CompanyID: "c1", IsPrivate: tt.private},
+				members: map[string]domain.ChannelRole{
+					"author": domain.ChannelRoleMember, "member": domain.ChannelRoleMember,
+					"owner": domain.ChannelRoleOwner, "admin": domain.ChannelRoleAdmin,
+				},
+			}
+			svc := NewMessageService(msgs, nil, channels, nil, nil, nil, nil, nil, nil, nil)
+
+			err := svc.Delete(context.Background(), tt.actor, "m1")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if msgs.deletedBy != tt.actor.ID {
+					t.Errorf("deleted by %q, want %q", msgs.deletedBy, tt.actor.ID)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+			if msgs.deletedBy != "" {
+				t.Error("message deleted without permission")
+			}
+		})
+	}
+}
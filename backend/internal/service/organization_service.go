@@ -153,7 +153,18 @@ func (s *OrganizationService) CreateApproval(
 	if err != nil {
 		return nil, err
 	}
+	return s.CreateApprovalFor(ctx, companyID, requesterID, approverID, reqType, payload, reason)
+}
 
+// CreateApprovalFor 创建审批请求并直接指定审批人（如任务升级沿汇报链逐级上报）
+func (s *OrganizationService) CreateApprovalFor(
+	ctx context.Context,
+	companyID, requesterID string,
+	approverID *string,
+	reqType domain.ApprovalRequestType,
+	payload json.RawMessage,
+	reason string,
+) (*domain.ApprovalRequest, error) {
 	if len(payload) == 0 {
 		payload = json.RawMessage("{}")
 	}
@@ -189,7 +200,7 @@ func (s *OrganizationService) ApproveRequest(ctx context.Context, id, decisionRe
 		return fmt.Errorf("approve request: %w", err)
 	}
 
-	if req.RequestType == domain.ApprovalHire {
+	if req.RequestType == domain.ApprovalHire || req.RequestType == domain.ApprovalToolCall {
 		event.Global.Publish(event.NewEvent(event.ApprovalApproved, event.ApprovalApprovedPayload{
 			RequestID:   req.ID,
 			CompanyID:   req.CompanyID,
@@ -210,13 +221,36 @@ func (s *OrganizationService) RejectRequest(ctx context.Context, id, decisionRea
 	}
 
 	now := time.Now()
-	return s.approvalRepo.UpdateStatus(ctx, id, domain.ApprovalRejected, decisionReason, &now)
+	if err := s.approvalRepo.UpdateStatus(ctx, id, domain.ApprovalRejected, decisionReason, &now); err != nil {
+		return err
+	}
+
+	event.Global.Publish(event.NewEvent(event.ApprovalRejected, event.ApprovalRejectedPayload{
+		RequestID:   req.ID,
+		CompanyID:   req.CompanyID,
+		RequestType: string(req.RequestType),
+		RequesterID: req.RequesterID,
+		Reason:      decisionReason,
+	}))
+	return nil
+}
+
+func (s *OrganizationService) GetApproval(ctx context.Context, id string) (*domain.ApprovalRequest, error) {
+	return s.approvalRepo.GetByID(ctx, id)
 }
 
 func (s *OrganizationService) ListApprovals(ctx context.Context, q repository.ApprovalQuery) ([]*domain.ApprovalRequest, int, error) {
 	return s.approvalRepo.List(ctx, q)
 }
 
+// ResolveManagerID 返回 Agent 的直属上级：优先 manager_id，其次所在部门总监
+func (s *OrganizationService) ResolveManagerID(ctx context.Context, agent *domain.Agent) (*string, error) {
+	if agent.ManagerID != nil && *agent.ManagerID != "" {
+		return agent.ManagerID, nil
+	}
+	return s.resolveApproverID(ctx, agent.CompanyID, agent.ID)
+}
+
 func (s *OrganizationService) resolveApproverID(ctx context.Context, companyID, requesterID string) (*string, error) {
 	requester, err := s.agentRepo.GetByID(ctx, requesterID)
 	if err != nil {
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/event"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+const (
+	outboxBatchSize     = 100
+	outboxPollInterval  = 2 * time.Second
+	outboxRetentionDays = 7
+)
+
+// OutboxRelay 将事务内写入 outbox 的事件发布到进程内事件总线（至少一次）。
+// 业务方在事务提交后调用 Dispatch 立即发布；后台 Run 兜底发布遗留事件（如进程在提交后崩溃）。
+type OutboxRelay struct {
+	repo repository.OutboxRepo
+	bus  *event.Bus
+	mu   sync.Mutex // 串行发布，避免同一事件被并发重复发布
+}
+
+func NewOutboxRelay(repo repository.OutboxRepo, bus *event.Bus) *OutboxRelay {
+	return &OutboxRelay{repo: repo, bus: bus}
+}
+
+// Enqueue 写入 outbox；应在业务事务的 ctx 中调用
+func (r *OutboxRelay) Enqueue(ctx context.Context, e event.Event) error {
+	return r.repo.Enqueue(ctx, &domain.OutboxEvent{EventType: string(e.Type), Payload: e.Payload})
+}
+
+// Dispatch 发布全部待发布事件
+func (r *OutboxRelay) Dispatch(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for {
+		pending, err := r.repo.ListPending(ctx, outboxBatchSize)
+		if err != nil {
+			log.Printf("[outbox] list pending: %v", err)
+			return
+		}
+		for _, e := range pending {
+			r.bus.Publish(event.Event{Type: event.Type(e.EventType), Payload: e.Payload})
+			if err := r.repo.MarkPublished(ctx, e.ID); err != nil {
+				log.Printf("[outbox] mark published %s: %v", e.ID, err)
+				return
+			}
+		}
+		if len(pending) < outboxBatchSize {
+			return
+		}
+	}
+}
+
+// Run 后台轮询发布并定期清理已发布事件
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+	lastCleanup := time.Time{}
+	for {
+		r.Dispatch(ctx)
+		if time.Since(lastCleanup) > 24*time.Hour {
+			if err := r.repo.DeletePublishedBefore(ctx, outboxRetentionDays); err != nil {
+				log.Printf("[outbox] cleanup: %v", err)
+			}
+			lastCleanup = time.Now()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -16,17 +16,20 @@ type PersonaOptimizerService struct {
 	personaRepo repository.PersonaOptimizationRepo
 	agentRepo   repository.AgentRepo
 	taskRepo    repository.TaskRepo
+	collabRepo  repository.TaskCollabRepo
 }
 
 func NewPersonaOptimizerService(
 	personaRepo repository.PersonaOptimizationRepo,
 	agentRepo repository.AgentRepo,
 	taskRepo repository.TaskRepo,
+	collabRepo repository.TaskCollabRepo,
 ) *PersonaOptimizerService {
 	return &PersonaOptimizerService{
 		personaRepo: personaRepo,
 		agentRepo:   agentRepo,
 		taskRepo:    taskRepo,
+		collabRepo:  collabRepo,
 	}
 }
 
@@ -82,7 +85,12 @@ func (s *PersonaOptimizerService) GenerateSuggestions(ctx context.Context, compa
 		return nil, fmt.Errorf("query failed tasks: %w", err)
 	}
 
-	candidates := s.buildSuggestions(agent.Persona, len(doneTasks), len(failedTasks))
+	reviews, err := s.collabRepo.ReviewStats(ctx, companyID, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("query review stats: %w", err)
+	}
+
+	candidates := s.buildSuggestions(agent.Persona, len(doneTasks), len(failedTasks), reviews)
 	for _, item := range candidates {
 		item.ID = uuid.New().String()
 		item.CompanyID = companyID
@@ -248,7 +256,7 @@ func (s *PersonaOptimizerService) ListABTests(ctx context.Context, companyID str
 	return s.personaRepo.ListABTests(ctx, companyID)
 }
 
-func (s *PersonaOptimizerService) buildSuggestions(persona string, doneCount, failedCount int) []*domain.PersonaOptimizationSuggestion {
+func (s *PersonaOptimizerService) buildSuggestions(persona string, doneCount, failedCount int, reviews *domain.TaskReviewStats) []*domain.PersonaOptimizationSuggestion {
 	var suggestions []*domain.PersonaOptimizationSuggestion
 
 	if len(persona) > 700 {
@@ -278,6 +286,16 @@ func (s *PersonaOptimizerService) buildSuggestions(persona string, doneCount, fa
 			Confidence:      0.75,
 		})
 	}
+	// 验收被要求修改的次数多于通过次数的一半：交付质量不稳定
+	if reviews != nil && reviews.ChangesRequested > 0 && reviews.ChangesRequested > reviews.Approved/2 {
+		suggestions = append(suggestions, &domain.PersonaOptimizationSuggestion{
+			SuggestionType:  domain.SuggestionTypeContent,
+			Priority:        domain.SuggestionPriorityHigh,
+			SuggestedChange: "提交前自检：逐条对照任务描述与验收标准核对结果，附上验证方式与已知限制后再提交。",
+			Reason:          fmt.Sprintf("验收中 %d 次被要求修改、%d 次通过，返工比例偏高。", reviews.ChangesRequested, reviews.Approved),
+			Confidence:      0.78,
+		})
+	}
 	if len(suggestions) == 0 {
 		suggestions = append(suggestions, &domain.PersonaOptimizationSuggestion{
 			SuggestionType:  domain.SuggestionTypeTone,
@@ -0,0 +1,481 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/event"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+const (
+	presenceTouchInterval = 30 * time.Second // last_seen_at / 活跃标记的写入节流
+	maxStatusHistoryRange = 31 * 24 * time.Hour
+	statusHistoryLimit    = 500
+)
+
+// PresenceConfig 在线状态参数
+type PresenceConfig struct {
+	HeartbeatTTL  time.Duration // 连接超过该时长无心跳视为断开
+	IdleAfter     time.Duration // 在线但超过该时长无操作转为 idle
+	SweepInterval time.Duration
+}
+
+// presenceStore 记录每个 agent 的活跃连接与最近操作时间；多实例部署时存 Redis，
+// 连接以过期时间为分值，进程崩溃后由过期自然清理
+type presenceStore interface {
+	// AddConn 登记或续期连接，返回连接此前是否不存在（或已过期）
+	AddConn(ctx context.Context, agentID, connID string, expireAt time.Time) (bool, error)
+	RemoveConn(ctx context.Context, agentID, connID string) error
+	// LiveConns 清理过期连接后返回剩余连接数
+	LiveConns(ctx context.Context, agentID string, now time.Time) (int, error)
+	// MarkActive 记录一次操作，返回此前是否处于无操作状态
+	MarkActive(ctx context.Context, agentID string, ttl time.Duration) (bool, error)
+	Active(ctx context.Context, agentID string) (bool, error)
+}
+
+// PresenceService 统一维护 agent 在线状态：MCP SSE / HTTP 与 WebSocket 连接按连接计数，
+// 心跳过期视为断开，长时间无操作转为 idle；每次变更写入状态历史并发布 agent.* 事件
+type PresenceService struct {
+	agentRepo   repository.AgentRepo
+	historyRepo repository.AgentStatusHistoryRepo
+	store       presenceStore
+	cfg         PresenceConfig
+
+	mu      sync.Mutex
+	touched map[string]time.Time // agentID → 上次写入 last_seen_at / 活跃标记的时间
+}
+
+// NewPresenceService rdb 为 nil 时退化为进程内存储，仅适用于单实例部署
+func NewPresenceService(agentRepo repository.AgentRepo, historyRepo repository.AgentStatusHistoryRepo, rdb *redis.Client, cfg PresenceConfig) *PresenceService {
+	var store presenceStore = newMemoryPresenceStore()
+	if rdb != nil {
+		store = &redisPresenceStore{rdb: rdb}
+	}
+	return newPresenceService(agentRepo, historyRepo, store, cfg)
+}
+
+func newPresenceService(agentRepo repository.AgentRepo, historyRepo repository.AgentStatusHistoryRepo, store presenceStore, cfg PresenceConfig) *PresenceService {
+	if cfg.HeartbeatTTL <= 0 {
+		cfg.HeartbeatTTL = 90 * time.Second
+	}
+	if cfg.IdleAfter <= 0 {
+		cfg.IdleAfter = 10 * time.Minute
+	}
+	if cfg.SweepInterval <= 0 {
+		cfg.SweepInterval = 30 * time.Second
+	}
+	return &PresenceService{
+		agentRepo:   agentRepo,
+		historyRepo: historyRepo,
+		store:       store,
+		cfg:         cfg,
+		touched:     make(map[string]time.Time),
+	}
+}
+
+// Connect 登记一个新连接；agent 离线或空闲时转为在线
+func (s *PresenceService) Connect(ctx context.Context, agent *domain.Agent, connID string) {
+	if _, err := s.store.AddConn(ctx, agent.ID, connID, time.Now().Add(s.cfg.HeartbeatTTL)); err != nil {
+		log.Printf("[presence] add conn %s/%s: %v", agent.ID, connID, err)
+		return
+	}
+	if _, err := s.store.MarkActive(ctx, agent.ID, s.cfg.IdleAfter); err != nil {
+		log.Printf("[presence] mark active %s: %v", agent.ID, err)
+	}
+	_ = s.agentRepo.UpdateLastSeen(ctx, agent.ID)
+	s.transition(ctx, agent.ID, domain.StatusOnline, domain.PresenceReasonConnect, domain.StatusOffline, domain.StatusIdle)
+}
+
+// Heartbeat 续期连接；连接曾过期被清理时重新上线。不视为操作，不会唤醒 idle
+func (s *PresenceService) Heartbeat(ctx context.Context, agent *domain.Agent, connID string) {
+	added, err := s.store.AddConn(ctx, agent.ID, connID, time.Now().Add(s.cfg.HeartbeatTTL))
+	if err != nil {
+		log.Printf("[presence] heartbeat %s/%s: %v", agent.ID, connID, err)
+		return
+	}
+	if s.shouldTouch("seen:" + agent.ID) {
+		_ = s.agentRepo.UpdateLastSeen(ctx, agent.ID)
+	}
+	if added {
+		s.transition(ctx, agent.ID, domain.StatusOnline, domain.PresenceReasonConnect, domain.StatusOffline)
+	}
+}
+
+// Disconnect 注销连接；最后一个连接关闭时转为离线
+func (s *PresenceService) Disconnect(ctx context.Context, agent *domain.Agent, connID string) {
+	if err := s.store.RemoveConn(ctx, agent.ID, connID); err != nil {
+		log.Printf("[presence] remove conn %s/%s: %v", agent.ID, connID, err)
+		return
+	}
+	n, err := s.store.LiveConns(ctx, agent.ID, time.Now())
+	if err != nil {
+		log.Printf("[presence] live conns %s: %v", agent.ID, err)
+		return
+	}
+	if n == 0 {
+		s.transition(ctx, agent.ID, domain.StatusOffline, domain.PresenceReasonDisconnect,
+			domain.StatusOnline, domain.StatusBusy, domain.StatusIdle)
+	}
+}
+
+// Activity 记录一次主动操作（调用工具、发消息）；空闲的 agent 恢复在线
+func (s *PresenceService) Activity(ctx context.Context, agent *domain.Agent) {
+	if !s.shouldTouch("active:" + agent.ID) {
+		return
+	}
+	wasInactive, err := s.store.MarkActive(ctx, agent.ID, s.cfg.IdleAfter)
+	if err != nil {
+		log.Printf("[presence] mark active %s: %v", agent.ID, err)
+		return
+	}
+	if wasInactive {
+		s.transition(ctx, agent.ID, domain.StatusOnline, domain.PresenceReasonActive, domain.StatusIdle)
+	}
+}
+
+// SetStatus 本人主动设置工作状态（如忙碌）
+func (s *PresenceService) SetStatus(ctx context.Context, agent *domain.Agent, status domain.AgentStatus) error {
+	switch status {
+	case domain.StatusOnline, domain.StatusBusy, domain.StatusIdle, domain.StatusOffline:
+	default:
+		return fmt.Errorf("invalid status %q", status)
+	}
+	if status != domain.StatusIdle {
+		if _, err := s.store.MarkActive(ctx, agent.ID, s.cfg.IdleAfter); err != nil {
+			log.Printf("[presence] mark active %s: %v", agent.ID, err)
+		}
+	}
+	s.transition(ctx, agent.ID, status, domain.PresenceReasonManual)
+	return nil
+}
+
+// transition 当前状态在 from 之内（from 为空表示任意状态）时切换到 to，
+// 以条件更新保证并发连接间不会互相覆盖
+func (s *PresenceService) transition(ctx context.Context, agentID string, to domain.AgentStatus, reason string, from ...domain.AgentStatus) bool {
+	agent, err := s.agentRepo.GetByID(ctx, agentID)
+	if err != nil || agent == nil {
+		return false
+	}
+	prev := agent.Status
+	if prev == to {
+		return false
+	}
+	if len(from) > 0 && !containsStatus(from, prev) {
+		return false
+	}
+	ok, err := s.agentRepo.SetStatusIf(ctx, agentID, prev, to)
+	if err != nil {
+		log.Printf("[presence] set status %s: %v", agentID, err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	if err := s.historyRepo.Record(ctx, &domain.AgentStatusPeriod{
+		CompanyID:  agent.CompanyID,
+		AgentID:    agentID,
+		Status:     to,
+		PrevStatus: prev,
+		Reason:     reason,
+		StartedAt:  time.Now(),
+	}); err != nil {
+		log.Printf("[presence] record history %s: %v", agentID, err)
+	}
+
+	payload := event.AgentStatusPayload{
+		AgentID:    agentID,
+		CompanyID:  agent.CompanyID,
+		Status:     string(to),
+		PrevStatus: string(prev),
+		Reason:     reason,
+	}
+	event.Global.Publish(event.NewEvent(event.AgentStatus, payload))
+	switch {
+	case prev == domain.StatusOffline:
+		event.Global.Publish(event.NewEvent(event.AgentOnline, payload))
+	case to == domain.StatusOffline:
+		event.Global.Publish(event.NewEvent(event.AgentOffline, payload))
+	}
+	return true
+}
+
+func containsStatus(list []domain.AgentStatus, s domain.AgentStatus) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *PresenceService) shouldTouch(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if last, ok := s.touched[key]; ok && now.Sub(last) < presenceTouchInterval {
+		return false
+	}
+	s.touched[key] = now
+	return true
+}
+
+// Run 定期清理心跳过期的 agent 并检测空闲，直到 ctx 结束
+func (s *PresenceService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.SweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.sweep(ctx, time.Now()); err != nil {
+				log.Printf("[presence] %v", err)
+			}
+		}
+	}
+}
+
+func (s *PresenceService) sweep(ctx context.Context, now time.Time) error {
+	agents, err := s.agentRepo.ListPresent(ctx)
+	if err != nil {
+		return err
+	}
+	for _, a := range agents {
+		n, err := s.store.LiveConns(ctx, a.ID, now)
+		if err != nil {
+			log.Printf("[presence] live conns %s: %v", a.ID, err)
+			continue
+		}
+		if n == 0 {
+			s.transition(ctx, a.ID, domain.StatusOffline, domain.PresenceReasonExpired,
+				domain.StatusOnline, domain.StatusBusy, domain.StatusIdle)
+			continue
+		}
+		// 忙碌是本人声明的状态，不自动转为空闲
+		if a.Status != domain.StatusOnline {
+			continue
+		}
+		active, err := s.store.Active(ctx, a.ID)
+		if err != nil {
+			log.Printf("[presence] active %s: %v", a.ID, err)
+			continue
+		}
+		if !active {
+			s.transition(ctx, a.ID, domain.StatusIdle, domain.PresenceReasonIdle, domain.StatusOnline)
+		}
+	}
+	return nil
+}
+
+// History 查询 agent 在 [from, to) 内的状态区间；本人或董事长可查看
+func (s *PresenceService) History(ctx context.Context, actor *domain.Agent, agentID string, from, to time.Time) ([]*domain.AgentStatusPeriod, error) {
+	if err := validateStatusRange(from, to); err != nil {
+		return nil, err
+	}
+	a, err := s.agentRepo.GetByID(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil || a.CompanyID != actor.CompanyID {
+		return nil, fmt.Errorf("agent not found")
+	}
+	if a.ID != actor.ID && actor.RoleType != domain.RoleChairman {
+		return nil, fmt.Errorf("permission denied")
+	}
+	return s.historyRepo.List(ctx, agentID, from, to, statusHistoryLimit)
+}
+
+// Utilization 统计公司内各 agent 在 [from, to) 内的在线利用率
+// ForgetAgent agent 被删除后清理其状态历史与节流记录
+func (s *PresenceService) ForgetAgent(ctx context.Context, agentID string) error {
+	s.mu.Lock()
+	delete(s.touched, "seen:"+agentID)
+	delete(s.touched, "active:"+agentID)
+	s.mu.Unlock()
+	return s.historyRepo.DeleteByAgent(ctx, agentID)
+}
+
+func (s *PresenceService) Utilization(ctx context.Context, companyID string, from, to time.Time) ([]*domain.AgentUtilization, error) {
+	if err := validateStatusRange(from, to); err != nil {
+		return nil, err
+	}
+	agents, err := s.agentRepo.GetByCompany(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	durations, err := s.historyRepo.Durations(ctx, companyID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return foldUtilization(agents, durations, to.Sub(from)), nil
+}
+
+// foldUtilization 汇总各状态时长；区间内无记录的时间计为离线
+func foldUtilization(agents []*domain.Agent, durations []*domain.AgentStatusDuration, window time.Duration) []*domain.AgentUtilization {
+	byAgent := make(map[string]*domain.AgentUtilization, len(agents))
+	out := make([]*domain.AgentUtilization, 0, len(agents))
+	for _, a := range agents {
+		u := &domain.AgentUtilization{AgentID: a.ID, Name: a.Name, IsHuman: a.IsHuman}
+		byAgent[a.ID] = u
+		out = append(out, u)
+	}
+	for _, d := range durations {
+		u := byAgent[d.AgentID]
+		if u == nil {
+			continue
+		}
+		switch d.Status {
+		case domain.StatusOnline:
+			u.OnlineSeconds += d.Seconds
+		case domain.StatusBusy:
+			u.BusySeconds += d.Seconds
+		case domain.StatusIdle:
+			u.IdleSeconds += d.Seconds
+		}
+	}
+	total := int64(window.Seconds())
+	for _, u := range out {
+		present := u.OnlineSeconds + u.BusySeconds + u.IdleSeconds
+		u.OfflineSeconds = max(total-present, 0)
+		if total > 0 {
+			u.Availability = float64(present) / float64(total)
+		}
+		if present > 0 {
+			u.ActiveRatio = float64(u.OnlineSeconds+u.BusySeconds) / float64(present)
+		}
+	}
+	return out
+}
+
+func validateStatusRange(from, to time.Time) error {
+	if !to.After(from) {
+		return fmt.Errorf("to must be after from")
+	}
+	if to.Sub(from) > maxStatusHistoryRange {
+		return fmt.Errorf("range must be within 31 days")
+	}
+	return nil
+}
+
+// ── presence stores ─────────────────────────────────────────
+
+type redisPresenceStore struct {
+	rdb *redis.Client
+}
+
+func presenceConnsKey(agentID string) string  { return "presence:conns:" + agentID }
+func presenceActiveKey(agentID string) string { return "presence:active:" + agentID }
+
+func (r *redisPresenceStore) AddConn(ctx context.Context, agentID, connID string, expireAt time.Time) (bool, error) {
+	key := presenceConnsKey(agentID)
+	// 先清理过期连接，ZADD 返回的新增数才能反映连接是否曾过期
+	if err := r.rdb.ZRemRangeByScore(ctx, key, "-inf", fmt.Sprint(time.Now().Unix())).Err(); err != nil {
+		return false, err
+	}
+	n, err := r.rdb.ZAdd(ctx, key, redis.Z{Score: float64(expireAt.Unix()), Member: connID}).Result()
+	if err != nil {
+		return false, err
+	}
+	r.rdb.ExpireAt(ctx, key, expireAt)
+	return n > 0, nil
+}
+
+func (r *redisPresenceStore) RemoveConn(ctx context.Context, agentID, connID string) error {
+	return r.rdb.ZRem(ctx, presenceConnsKey(agentID), connID).Err()
+}
+
+func (r *redisPresenceStore) LiveConns(ctx context.Context, agentID string, now time.Time) (int, error) {
+	key := presenceConnsKey(agentID)
+	if err := r.rdb.ZRemRangeByScore(ctx, key, "-inf", fmt.Sprint(now.Unix())).Err(); err != nil {
+		return 0, err
+	}
+	n, err := r.rdb.ZCard(ctx, key).Result()
+	return int(n), err
+}
+
+func (r *redisPresenceStore) MarkActive(ctx context.Context, agentID string, ttl time.Duration) (bool, error) {
+	prev, err := r.rdb.SetArgs(ctx, presenceActiveKey(agentID), "1", redis.SetArgs{TTL: ttl, Get: true}).Result()
+	if err == redis.Nil {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return prev == "", nil
+}
+
+func (r *redisPresenceStore) Active(ctx context.Context, agentID string) (bool, error) {
+	n, err := r.rdb.Exists(ctx, presenceActiveKey(agentID)).Result()
+	return n > 0, err
+}
+
+type memoryPresenceStore struct {
+	mu     sync.Mutex
+	conns  map[string]map[string]time.Time // agentID → connID → 过期时间
+	active map[string]time.Time            // agentID → 活跃标记过期时间
+}
+
+func newMemoryPresenceStore() *memoryPresenceStore {
+	return &memoryPresenceStore{
+		conns:  make(map[string]map[string]time.Time),
+		active: make(map[string]time.Time),
+	}
+}
+
+func (m *memoryPresenceStore) AddConn(_ context.Context, agentID, connID string, expireAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	conns := m.conns[agentID]
+	if conns == nil {
+		conns = make(map[string]time.Time)
+		m.conns[agentID] = conns
+	}
+	old, ok := conns[connID]
+	conns[connID] = expireAt
+	return !ok || !old.After(time.Now()), nil
+}
+
+func (m *memoryPresenceStore) RemoveConn(_ context.Context, agentID, connID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.conns[agentID], connID)
+	return nil
+}
+
+func (m *memoryPresenceStore) LiveConns(_ context.Context, agentID string, now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	conns := m.conns[agentID]
+	for id, exp := range conns {
+		if !exp.After(now) {
+			delete(conns, id)
+		}
+	}
+	if len(conns) == 0 {
+		delete(m.conns, agentID)
+	}
+	return len(conns), nil
+}
+
+func (m *memoryPresenceStore) MarkActive(_ context.Context, agentID string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	wasInactive := !m.active[agentID].After(now)
+	m.active[agentID] = now.Add(ttl)
+	return wasInactive, nil
+}
+
+func (m *memoryPresenceStore) Active(_ context.Context, agentID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.active[agentID].After(time.Now()), nil
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+// memPresenceAgentRepo 内存版 AgentRepo，仅实现在线状态相关方法
+type memPresenceAgentRepo struct {
+	repository.AgentRepo
+	agents map[string]*domain.Agent
+}
+
+func (r *memPresenceAgentRepo) GetByID(_ context.Context, id string) (*domain.Agent, error) {
+	if a, ok := r.agents[id]; ok {
+		cp := *a
+		return &cp, nil
+	}
+	return nil, nil
+}
+
+func (r *memPresenceAgentRepo) SetStatusIf(_ context.Context, id string, from, to domain.AgentStatus) (bool, error) {
+	a := r.agents[id]
+	if a == nil || a.Status != from {
+		return false, nil
+	}
+	a.Status = to
+	return true, nil
+}
+
+func (r *memPresenceAgentRepo) ListPresent(context.Context) ([]*domain.Agent, error) {
+	var out []*domain.Agent
+	for _, a := range r.agents {
+		if a.Status != domain.StatusOffline {
+			cp := *a
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (r *memPresenceAgentRepo) UpdateLastSeen(context.Context, string) error { return nil }
+
+type memStatusHistoryRepo struct {
+	repository.AgentStatusHistoryRepo
+	periods []*domain.AgentStatusPeriod
+}
+
+func (r *memStatusHistoryRepo) Record(_ context.Context, p *domain.AgentStatusPeriod) error {
+	r.periods = append(r.periods, p)
+	return nil
+}
+
+func (r *memStatusHistoryRepo) DeleteByAgent(_ context.Context, agentID string) error {
+	kept := r.periods[:0]
+	for _, p := range r.periods {
+		if p.AgentID != agentID {
+			kept = append(kept, p)
+		}
+	}
+	r.periods = kept
+	return nil
+}
+
+func TestPresenceConnectionRefCount(t *testing.T) {
+	ctx := context.Background()
+	agent := &domain.Agent{ID: "a1", CompanyID: "c1", Status: domain.StatusOffline}
+	agents := &memPresenceAgentRepo{agents: map[string]*domain.Agent{"a1": agent}}
+	history := &memStatusHistoryRepo{}
+	svc := newPresenceService(agents, history, newMemoryPresenceStore(), PresenceConfig{})
+
+	svc.Connect(ctx, agent, "sse-1")
+	svc.Connect(ctx, agent, "ws-1")
+	if agent.Status != domain.StatusOnline {
+		t.Fatalf("status after connect = %s, want online", agent.Status)
+	}
+
+	// 关闭其中一个连接不应下线
+	svc.Disconnect(ctx, agent, "sse-1")
+	if agent.Status != domain.StatusOnline {
+		t.Fatalf("status with one live conn = %s, want online", agent.Status)
+	}
+	svc.Disconnect(ctx, agent, "ws-1")
+	if agent.Status != domain.StatusOffline {
+		t.Fatalf("status after last disconnect = %s, want offline", agent.Status)
+	}
+
+	if len(history.periods) != 2 {
+		t.Fatalf("history = %d periods, want 2", len(history.periods))
+	}
+	if p := history.periods[1]; p.PrevStatus != domain.StatusOnline || p.Reason != domain.PresenceReasonDisconnect {
+		t.Errorf("last period = %+v", p)
+	}
+}
+
+func TestPresenceSweep(t *testing.T) {
+	ctx := context.Background()
+	agent := &domain.Agent{ID: "a1", CompanyID: "c1", Status: domain.StatusOffline}
+	busy := &domain.Agent{ID: "a2", CompanyID: "c1", Status: domain.StatusBusy}
+	agents := &memPresenceAgentRepo{agents: map[string]*domain.Agent{"a1": agent, "a2": busy}}
+	history := &memStatusHistoryRepo{}
+	store := newMemoryPresenceStore()
+	svc := newPresenceService(agents, history, store, PresenceConfig{HeartbeatTTL: time.Minute})
+
+	svc.Connect(ctx, agent, "c1")
+	svc.Connect(ctx, busy, "c2")
+
+	// 长时间无操作：在线转空闲，忙碌保持不变
+	store.active["a1"] = time.Now().Add(-time.Second)
+	store.active["a2"] = time.Now().Add(-time.Second)
+	if err := svc.sweep(ctx, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if agent.Status != domain.StatusIdle || busy.Status != domain.StatusBusy {
+		t.Fatalf("after idle sweep: a1=%s a2=%s, want idle/busy", agent.Status, busy.Status)
+	}
+
+	// 有操作后恢复在线
+	svc.Activity(ctx, agent)
+	if agent.Status != domain.StatusOnline {
+		t.Fatalf("after activity = %s, want online", agent.Status)
+	}
+
+	// 心跳过期（进程崩溃，未调用 Disconnect）后下线
+	if err := svc.sweep(ctx, time.Now().Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if agent.Status != domain.StatusOffline || busy.Status != domain.StatusOffline {
+		t.Fatalf("after expiry sweep: a1=%s a2=%s, want offline", agent.Status, busy.Status)
+	}
+	if last := history.periods[len(history.periods)-1]; last.Reason != domain.PresenceReasonExpired {
+		t.Errorf("last reason = %s, want expired", last.Reason)
+	}
+}
+
+func TestFoldUtilization(t *testing.T) {
+	agents := []*domain.Agent{{ID: "a1", Name: "A"}, {ID: "a2", Name: "B"}}
+	durations := []*domain.AgentStatusDuration{
+		{AgentID: "a1", Status: domain.StatusOnline, Seconds: 3600},
+		{AgentID: "a1", Status: domain.StatusBusy, Seconds: 1800},
+		{AgentID: "a1", Status: domain.StatusIdle, Seconds: 1800},
+		{AgentID: "a1", Status: domain.StatusOffline, Seconds: 600},
+		{AgentID: "gone", Status: domain.StatusOnline, Seconds: 100},
+	}
+	got := foldUtilization(agents, durations, 4*time.Hour)
+	if len(got) != 2 {
+		t.Fatalf("got %d rows, want 2", len(got))
+	}
+	u := got[0]
+	if u.OfflineSeconds != 7200 || u.Availability != 0.5 || u.ActiveRatio != 0.75 {
+		t.Errorf("a1 = %+v", u)
+	}
+	if got[1].OfflineSeconds != 14400 || got[1].Availability != 0 || got[1].ActiveRatio != 0 {
+		t.Errorf("a2 = %+v", got[1])
+	}
+}
+
+func TestPresenceForgetAgent(t *testing.T) {
+	ctx := context.Background()
+	a1 := &domain.Agent{ID: "a1", CompanyID: "c1", Status: domain.StatusOffline}
+	a2 := &domain.Agent{ID: "a2", CompanyID: "c1", Status: domain.StatusOffline}
+	agents := &memPresenceAgentRepo{agents: map[string]*domain.Agent{"a1": a1, "a2": a2}}
+	history := &memStatusHistoryRepo{}
+	svc := newPresenceService(agents, history, newMemoryPresenceStore(), PresenceConfig{})
+	svc.Connect(ctx, a1, "ws-1")
+	svc.Connect(ctx, a2, "ws-2")
+
+	if err := svc.ForgetAgent(ctx, "a1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range history.periods {
+		if p.AgentID == "a1" {
+			t.Fatalf("history of deleted agent kept: %+v", p)
+		}
+	}
+	if len(history.periods) == 0 {
+		t.Error("history of other agents removed")
+	}
+	if _, ok := svc.touched["seen:a1"]; ok {
+		t.Error("touch record of deleted agent kept")
+	}
+}
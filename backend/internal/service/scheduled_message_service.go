@@ -0,0 +1,339 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+const (
+	maxActiveSchedulesPerAgent = 100
+	maxScheduleAhead           = 366 * 24 * time.Hour
+	scheduledMessagePoll       = 15 * time.Second
+	scheduledMessageBatch      = 50
+	// scheduledMessageLease 最后一次发送的抢占租期：发送成功后才标记为已发送，进程在租期内崩溃时由下一轮重试
+	scheduledMessageLease = 5 * time.Minute
+)
+
+// errScheduleOrphaned 创建者、频道或收件人已不存在（或创建者已不在私有频道中），规则不再触发
+var errScheduleOrphaned = errors.New("schedule orphaned")
+
+// ScheduledMessageService 定时消息与提醒：到期时通过 MessageService.Send 发送，
+// 提醒以系统私信发给创建者本人，经 Agent 投递通道唤醒其继续跟进
+type ScheduledMessageService struct {
+	repo       repository.ScheduledMessageRepo
+	messageSvc *MessageService
+}
+
+func NewScheduledMessageService(repo repository.ScheduledMessageRepo, messageSvc *MessageService) *ScheduledMessageService {
+	return &ScheduledMessageService{repo: repo, messageSvc: messageSvc}
+}
+
+// ScheduleMessageInput 创建定时消息的参数；SendAt 与 Cron 二选一，提醒不需要 Channel / ReceiverID
+type ScheduleMessageInput struct {
+	Kind       domain.ScheduledMessageKind `json:"kind"`
+	Channel    string                      `json:"channel"`
+	ReceiverID string                      `json:"receiver_id"`
+	TaskID     string                      `json:"task_id"`
+	Content    string                      `json:"content"`
+	SendAt     *time.Time                  `json:"send_at"`
+	Cron       string                      `json:"cron"`
+	Timezone   string                      `json:"timezone"`
+}
+
+func (s *ScheduledMessageService) Create(ctx context.Context, actor *domain.Agent, in ScheduleMessageInput) (*domain.ScheduledMessage, error) {
+	m := &domain.ScheduledMessage{
+		CompanyID: actor.CompanyID,
+		CreatedBy: actor.ID,
+		Kind:      in.Kind,
+		Content:   strings.TrimSpace(in.Content),
+		Status:    domain.ScheduleStatusScheduled,
+	}
+	if m.Kind == "" {
+		m.Kind = domain.ScheduleKindMessage
+	}
+	if m.Content == "" {
+		return nil, fmt.Errorf("content is required")
+	}
+	if err := s.applyTarget(ctx, actor, m, in); err != nil {
+		return nil, err
+	}
+	if err := applySchedule(m, in, time.Now()); err != nil {
+		return nil, err
+	}
+	n, err := s.repo.CountActive(ctx, actor.ID)
+	if err != nil {
+		return nil, err
+	}
+	if n >= maxActiveSchedulesPerAgent {
+		return nil, fmt.Errorf("too many scheduled messages (max %d), cancel some first", maxActiveSchedulesPerAgent)
+	}
+	if err := s.repo.Create(ctx, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// applyTarget 校验发送目标：普通消息按发送者身份检查频道可见性，提醒只发给自己
+func (s *ScheduledMessageService) applyTarget(ctx context.Context, actor *domain.Agent, m *domain.ScheduledMessage, in ScheduleMessageInput) error {
+	channel := strings.TrimPrefix(strings.TrimSpace(in.Channel), "#")
+	switch m.Kind {
+	case domain.ScheduleKindReminder:
+		if channel != "" || in.ReceiverID != "" {
+			return fmt.Errorf("reminders are always sent to yourself, omit channel and receiver_id")
+		}
+	case domain.ScheduleKindMessage:
+		switch {
+		case channel != "" && in.ReceiverID != "":
+			return fmt.Errorf("specify either channel or receiver_id, not both")
+		case channel != "":
+			ch, err := s.messageSvc.accessibleChannel(ctx, actor.CompanyID, actor.ID, channel)
+			if err != nil {
+				return err
+			}
+			if ch.IsArchived() {
+				return fmt.Errorf("channel %q is archived", channel)
+			}
+			m.ChannelID = &ch.ID
+		case in.ReceiverID != "":
+			if in.ReceiverID == actor.ID {
+				return fmt.Errorf("cannot schedule a message to yourself, use a reminder instead")
+			}
+			r, err := s.messageSvc.agentRepo.GetByID(ctx, in.ReceiverID)
+			if err != nil {
+				return err
+			}
+			if r == nil || r.CompanyID != actor.CompanyID {
+				return fmt.Errorf("receiver not found")
+			}
+			m.ReceiverID = &r.ID
+		default:
+			return fmt.Errorf("must specify channel or receiver_id")
+		}
+	default:
+		return fmt.Errorf("invalid kind %q", m.Kind)
+	}
+
+	if in.TaskID != "" {
+		t, err := s.messageSvc.taskRepo.GetByID(ctx, in.TaskID)
+		if err != nil {
+			return err
+		}
+		if t == nil || t.CompanyID != actor.CompanyID {
+			return fmt.Errorf("task not found")
+		}
+		m.TaskID = &t.ID
+	}
+	return nil
+}
+
+// applySchedule 校验发送时间或 cron 与时区，计算首次发送时间
+func applySchedule(m *domain.ScheduledMessage, in ScheduleMessageInput, now time.Time) error {
+	cron := strings.TrimSpace(in.Cron)
+	if (in.SendAt == nil) == (cron == "") {
+		return fmt.Errorf("specify exactly one of send_at or cron")
+	}
+	m.Timezone = in.Timezone
+	if m.Timezone == "" {
+		m.Timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(m.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q", m.Timezone)
+	}
+
+	if in.SendAt != nil {
+		at := *in.SendAt
+		if !at.After(now) {
+			return fmt.Errorf("send_at must be in the future")
+		}
+		if at.Sub(now) > maxScheduleAhead {
+			return fmt.Errorf("send_at must be within one year")
+		}
+		m.NextRunAt = &at
+		return nil
+	}
+
+	sched, err := ParseCron(cron)
+	if err != nil {
+		return err
+	}
+	next := sched.Next(now.In(loc))
+	if next.IsZero() {
+		return fmt.Errorf("cron %q never fires", cron)
+	}
+	m.Cron, m.NextRunAt = cron, &next
+	return nil
+}
+
+// List 列出定时消息；董事长可查看全公司（all=true），其他人只看自己创建的
+func (s *ScheduledMessageService) List(ctx context.Context, actor *domain.Agent, all, activeOnly bool) ([]*domain.ScheduledMessage, error) {
+	createdBy := actor.ID
+	if all && actor.RoleType == domain.RoleChairman {
+		createdBy = ""
+	}
+	return s.repo.List(ctx, actor.CompanyID, createdBy, activeOnly)
+}
+
+func (s *ScheduledMessageService) Get(ctx context.Context, actor *domain.Agent, id string) (*domain.ScheduledMessage, error) {
+	m, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil || m.CompanyID != actor.CompanyID ||
+		(m.CreatedBy != actor.ID && actor.RoleType != domain.RoleChairman) {
+		return nil, fmt.Errorf("scheduled message not found")
+	}
+	return m, nil
+}
+
+// Cancel 取消仍待发送的定时消息（创建者本人或董事长）
+func (s *ScheduledMessageService) Cancel(ctx context.Context, actor *domain.Agent, id string) (*domain.ScheduledMessage, error) {
+	m, err := s.Get(ctx, actor, id)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.repo.Cancel(ctx, m.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("scheduled message already %s", m.Status)
+	}
+	m.Status, m.NextRunAt = domain.ScheduleStatusCancelled, nil
+	return m, nil
+}
+
+// Run 定期发送到期的定时消息，直到 ctx 结束
+func (s *ScheduledMessageService) Run(ctx context.Context) {
+	ticker := time.NewTicker(scheduledMessagePoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.runDue(ctx, time.Now()); err != nil {
+				log.Printf("[scheduled-message] %v", err)
+			}
+		}
+	}
+}
+
+func (s *ScheduledMessageService) runDue(ctx context.Context, now time.Time) error {
+	due, err := s.repo.ListDue(ctx, now, scheduledMessageBatch)
+	if err != nil {
+		return err
+	}
+	for _, m := range due {
+		// 先推进下次发送时间：抢占失败说明其他实例已处理；错过的多次触发只补一次。
+		// 一次性（或已无后续触发）的消息只推进一个租期，发送成功后再标记为已发送
+		var next *time.Time
+		if m.Cron != "" {
+			if loc, err := time.LoadLocation(m.Timezone); err == nil {
+				if sched, err := ParseCron(m.Cron); err == nil {
+					if n := sched.Next(now.In(loc)); !n.IsZero() {
+						next = &n
+					}
+				}
+			}
+		}
+		claim := now.Add(scheduledMessageLease)
+		if next != nil {
+			claim = *next
+		}
+		ok, err := s.repo.Advance(ctx, m.ID, *m.NextRunAt, claim)
+		if err != nil || !ok {
+			continue
+		}
+
+		var msgID *string
+		var status domain.ScheduledMessageStatus
+		lastErr := ""
+		msg, err := s.fire(ctx, m)
+		switch {
+		case err != nil:
+			lastErr = err.Error()
+			log.Printf("[scheduled-message] %s: %v", m.ID, err)
+			// 一次性消息发送失败或目标已不存在时不再重试
+			if next == nil || errors.Is(err, errScheduleOrphaned) {
+				status = domain.ScheduleStatusFailed
+			}
+		case next == nil:
+			msgID, status = &msg.ID, domain.ScheduleStatusSent
+		default:
+			msgID = &msg.ID
+		}
+		if err := s.repo.SetResult(ctx, m.ID, msgID, lastErr, status); err != nil {
+			log.Printf("[scheduled-message] %s: %v", m.ID, err)
+		}
+	}
+	return nil
+}
+
+// fire 以创建者身份发送消息，或以系统身份向创建者发送提醒
+func (s *ScheduledMessageService) fire(ctx context.Context, m *domain.ScheduledMessage) (*domain.Message, error) {
+	owner, err := s.messageSvc.agentRepo.GetByID(ctx, m.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+	if owner == nil {
+		return nil, fmt.Errorf("%w: owner %s no longer exists", errScheduleOrphaned, m.CreatedBy)
+	}
+
+	in := SendInput{CompanyID: m.CompanyID, Content: m.Content, ScheduleID: m.ID}
+	switch {
+	case m.Kind == domain.ScheduleKindReminder:
+		in.ReceiverID = owner.ID
+		in.Content = s.reminderContent(ctx, m)
+	case m.ChannelID != nil:
+		ch, err := s.messageSvc.channelRepo.GetByID(ctx, *m.ChannelID)
+		if err != nil {
+			return nil, err
+		}
+		if ch == nil {
+			return nil, fmt.Errorf("%w: channel %s no longer exists", errScheduleOrphaned, *m.ChannelID)
+		}
+		if ch.IsPrivate && !s.messageSvc.IsChannelMember(ctx, ch.ID, owner.ID) {
+			return nil, fmt.Errorf("%w: owner %s is no longer a member of #%s", errScheduleOrphaned, owner.ID, ch.Name)
+		}
+		in.SenderID, in.Channel = owner.ID, ch.Name
+	case m.ReceiverID != nil:
+		r, err := s.messageSvc.agentRepo.GetByID(ctx, *m.ReceiverID)
+		if err != nil {
+			return nil, err
+		}
+		if r == nil {
+			return nil, fmt.Errorf("%w: receiver %s no longer exists", errScheduleOrphaned, *m.ReceiverID)
+		}
+		in.SenderID, in.ReceiverID = owner.ID, r.ID
+	default:
+		return nil, fmt.Errorf("%w: no target", errScheduleOrphaned)
+	}
+	return s.messageSvc.Send(ctx, in)
+}
+
+// reminderContent 提醒正文；关联任务时附上任务当前状态，便于 Agent 直接跟进
+func (s *ScheduledMessageService) reminderContent(ctx context.Context, m *domain.ScheduledMessage) string {
+	var b strings.Builder
+	b.WriteString("⏰ 提醒：")
+	b.WriteString(m.Content)
+	if m.TaskID != nil {
+		t, err := s.messageSvc.taskRepo.GetByID(ctx, *m.TaskID)
+		switch {
+		case err != nil:
+			log.Printf("[scheduled-message] %s: load task %s: %v", m.ID, *m.TaskID, err)
+		case t == nil:
+			fmt.Fprintf(&b, "\n关联任务 %s 已不存在。", *m.TaskID)
+		default:
+			fmt.Fprintf(&b, "\n关联任务「%s」（%s）当前状态：%s。", t.Title, t.ID, t.Status)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+func TestApplySchedule(t *testing.T) {
+	now := time.Date(2026, 1, 1, 9, 30, 0, 0, time.UTC) // 周四
+	at := func(d time.Duration) *time.Time { v := now.Add(d); return &v }
+
+	tests := []struct {
+		name    string
+		in      ScheduleMessageInput
+		want    time.Time
+		wantErr string
+	}{
+		{"one-shot", ScheduleMessageInput{SendAt: at(time.Hour)}, now.Add(time.Hour), ""},
+		{"past", ScheduleMessageInput{SendAt: at(-time.Minute)}, time.Time{}, "in the future"},
+		{"too far", ScheduleMessageInput{SendAt: at(400 * 24 * time.Hour)}, time.Time{}, "within one year"},
+		{"neither", ScheduleMessageInput{}, time.Time{}, "exactly one"},
+		{"both", ScheduleMessageInput{SendAt: at(time.Hour), Cron: "@daily"}, time.Time{}, "exactly one"},
+		{"bad cron", ScheduleMessageInput{Cron: "0 9 * *"}, time.Time{}, "expected 5 fields"},
+		{"bad timezone", ScheduleMessageInput{Cron: "@daily", Timezone: "Mars/Base"}, time.Time{}, "invalid timezone"},
+		{"weekday cron", ScheduleMessageInput{Cron: "0 9 * * 1-5"}, time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC), ""},
+		{"cron in timezone", ScheduleMessageInput{Cron: "0 18 * * *", Timezone: "Asia/Shanghai"}, time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC), ""},
+	}
+	for _, tt := range tests {
+		m := &domain.ScheduledMessage{}
+		err := applySchedule(m, tt.in, now)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if m.NextRunAt == nil || !m.NextRunAt.Equal(tt.want) {
+			t.Errorf("%s: next run = %v, want %v", tt.name, m.NextRunAt, tt.want)
+		}
+	}
+}
+
+// memScheduleRepo 内存定时消息表，Advance 按 next_run_at 条件抢占
+type memScheduleRepo struct {
+	repository.ScheduledMessageRepo
+	items map[string]*domain.ScheduledMessage
+}
+
+func (r *memScheduleRepo) ListDue(_ context.Context, now time.Time, _ int) ([]*domain.ScheduledMessage, error) {
+	var out []*domain.ScheduledMessage
+	for _, m := range r.items {
+		if m.Status == domain.ScheduleStatusScheduled && m.NextRunAt != nil && !m.NextRunAt.After(now) {
+			cp := *m
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (r *memScheduleRepo) Advance(_ context.Context, id string, prev, next time.Time) (bool, error) {
+	m := r.items[id]
+	if m.Status != domain.ScheduleStatusScheduled || m.NextRunAt == nil || !m.NextRunAt.Equal(prev) {
+		return false, nil
+	}
+	m.NextRunAt = &next
+	m.RunCount++
+	return true, nil
+}
+
+func (r *memScheduleRepo) SetResult(_ context.Context, id string, messageID *string, lastError string, status domain.ScheduledMessageStatus) error {
+	m := r.items[id]
+	if messageID != nil {
+		m.LastMessageID = messageID
+	}
+	m.LastError = lastError
+	if status != "" {
+		m.Status = status
+	}
+	if status == domain.ScheduleStatusSent || status == domain.ScheduleStatusFailed {
+		m.NextRunAt = nil
+	}
+	return nil
+}
+
+// scheduleMessageRepo 记录发送的消息；sendErr 非 nil 时发送失败，onSend 在写入前调用
+type scheduleMessageRepo struct {
+	repository.MessageRepo
+	sent    []*domain.Message
+	sendErr error
+	onSend  func()
+}
+
+func (r *scheduleMessageRepo) Create(_ context.Context, m *domain.Message) error {
+	if r.onSend != nil {
+		r.onSend()
+	}
+	if r.sendErr != nil {
+		return r.sendErr
+	}
+	r.sent = append(r.sent, m)
+	return nil
+}
+
+func (r *scheduleMessageRepo) LinkAttachments(context.Context, string, []string) (int64, error) {
+	return 0, nil
+}
+
+type scheduleFixture struct {
+	svc      *ScheduledMessageService
+	repo     *memScheduleRepo
+	messages *scheduleMessageRepo
+	channels *threadChannelRepo
+}
+
+func newScheduleFixture(items ...*domain.ScheduledMessage) *scheduleFixture {
+	f := &scheduleFixture{
+		repo:     &memScheduleRepo{items: map[string]*domain.ScheduledMessage{}},
+		messages: &scheduleMessageRepo{},
+		channels: &threadChannelRepo{
+			ch:      &domain.Channel{ID: "ch1", CompanyID: "c1", Name: "ops", IsPrivate: true},
+			members: map[string]domain.ChannelRole{"owner": domain.ChannelRoleMember},
+		},
+	}
+	for _, m := range items {
+		f.repo.items[m.ID] = m
+	}
+	agents := &memTaskAgentRepo{agents: map[string]*domain.Agent{
+		"owner": {ID: "owner", CompanyID: "c1"},
+		"peer":  {ID: "peer", CompanyID: "c1"},
+	}}
+	msgSvc := NewMessageService(f.messages, nil, f.channels, agents, nil, nil, nil, nil, nil, nil)
+	f.svc = NewScheduledMessageService(f.repo, msgSvc)
+	return f
+}
+
+func dueSchedule(id, cron string, now time.Time) *domain.ScheduledMessage {
+	due := now.Add(-time.Second)
+	peer := "peer"
+	return &domain.ScheduledMessage{
+		ID: id, CompanyID: "c1", CreatedBy: "owner", Kind: domain.ScheduleKindMessage, ReceiverID: &peer,
+		Content: "早上好", Cron: cron, Timezone: "UTC", Status: domain.ScheduleStatusScheduled, NextRunAt: &due,
+	}
+}
+
+func TestScheduleRunDue_OneShotMarkedSentAfterSend(t *testing.T) {
+	now := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	f := newScheduleFixture(dueSchedule("s1", "", now))
+	m := f.repo.items["s1"]
+	f.messages.onSend = func() {
+		if m.Status != domain.ScheduleStatusScheduled {
+			t.Errorf("status = %s while sending, want scheduled", m.Status)
+		}
+	}
+
+	if err := f.svc.runDue(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+	if m.Status != domain.ScheduleStatusSent || m.NextRunAt != nil || m.LastMessageID == nil {
+		t.Errorf("status %s next %v last message %v, want sent", m.Status, m.NextRunAt, m.LastMessageID)
+	}
+	if len(f.messages.sent) != 1 {
+		t.Errorf("sent %d messages, want 1", len(f.messages.sent))
+	}
+}
+
+func TestScheduleRunDue_ClaimExpiresAfterCrash(t *testing.T) {
+	now := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	f := newScheduleFixture(dueSchedule("s1", "", now))
+	m := f.repo.items["s1"]
+	// 模拟抢占后、发送前进程崩溃：只推进了租期
+	if ok, _ := f.repo.Advance(context.Background(), "s1", *m.NextRunAt, now.Add(scheduledMessageLease)); !ok {
+		t.Fatal("claim failed")
+	}
+
+	if err := f.svc.runDue(context.Background(), now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.messages.sent) != 0 {
+		t.Fatal("claimed message was sent again within its lease")
+	}
+	if err := f.svc.runDue(context.Background(), now.Add(scheduledMessageLease)); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.messages.sent) != 1 || m.Status != domain.ScheduleStatusSent {
+		t.Errorf("sent %d, status %s; want retried after lease expiry", len(f.messages.sent), m.Status)
+	}
+}
+
+func TestScheduleRunDue_Failures(t *testing.T) {
+	now := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	t.Run("one-shot send error fails", func(t *testing.T) {
+		f := newScheduleFixture(dueSchedule("s1", "", now))
+		f.messages.sendErr = errors.New("db down")
+		if err := f.svc.runDue(context.Background(), now); err != nil {
+			t.Fatal(err)
+		}
+		if m := f.repo.items["s1"]; m.Status != domain.ScheduleStatusFailed || !strings.Contains(m.LastError, "db down") {
+			t.Errorf("status %s error %q, want failed", m.Status, m.LastError)
+		}
+	})
+
+	t.Run("recurring send error keeps schedule", func(t *testing.T) {
+		f := newScheduleFixture(dueSchedule("s1", "0 9 * * *", now))
+		f.messages.sendErr = errors.New("db down")
+		if err := f.svc.runDue(context.Background(), now); err != nil {
+			t.Fatal(err)
+		}
+		m := f.repo.items["s1"]
+		if want := now.Add(24 * time.Hour); m.Status != domain.ScheduleStatusScheduled || m.NextRunAt == nil || !m.NextRunAt.Equal(want) {
+			t.Errorf("status %s next %v, want scheduled at %v", m.Status, m.NextRunAt, want)
+		}
+	})
+
+	t.Run("recurring owner left private channel", func(t *testing.T) {
+		m := dueSchedule("s1", "0 9 * * *", now)
+		channelID := "ch1"
+		m.ReceiverID, m.ChannelID = nil, &channelID
+		f := newScheduleFixture(m)
+		delete(f.channels.members, "owner")
+
+		if err := f.svc.runDue(context.Background(), now); err != nil {
+			t.Fatal(err)
+		}
+		if m.Status != domain.ScheduleStatusFailed || m.NextRunAt != nil || !strings.Contains(m.LastError, "no longer a member") {
+			t.Errorf("status %s next %v error %q, want orphaned", m.Status, m.NextRunAt, m.LastError)
+		}
+		if len(f.messages.sent) != 0 {
+			t.Error("message sent to a channel the owner left")
+		}
+	})
+
+	t.Run("recurring owner still in private channel", func(t *testing.T) {
+		m := dueSchedule("s1", "0 9 * * *", now)
+		channelID := "ch1"
+		m.ReceiverID, m.ChannelID = nil, &channelID
+		f := newScheduleFixture(m)
+
+		if err := f.svc.runDue(context.Background(), now); err != nil {
+			t.Fatal(err)
+		}
+		if m.Status != domain.ScheduleStatusScheduled || len(f.messages.sent) != 1 || *f.messages.sent[0].ChannelID != "ch1" {
+			t.Errorf("status %s sent %d, want channel message sent", m.Status, len(f.messages.sent))
+		}
+	})
+}
@@ -0,0 +1,364 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/event"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+// 自动分配时参考的历史任务条数
+const assignHistoryLimit = 500
+
+// AssigneeCandidate 自动分配候选人及评分明细
+type AssigneeCandidate struct {
+	AgentID  string   `json:"agent_id"`
+	Name     string   `json:"name"`
+	Position string   `json:"position"`
+	Status   string   `json:"status"`
+	Load     int      `json:"load"`
+	Score    float64  `json:"score"`
+	Reasons  []string `json:"reasons"`
+}
+
+// Reassign 改派任务给 assigneeID（任务回到 assigned，需新负责人重新 accept）
+func (s *TaskService) Reassign(ctx context.Context, taskID string, actor *domain.Agent, assigneeID, reason string) (*domain.Task, error) {
+	t, err := s.loadForAssignment(ctx, taskID, actor)
+	if err != nil {
+		return nil, err
+	}
+	if t.AssigneeID != nil && *t.AssigneeID == assigneeID {
+		return nil, fmt.Errorf("task is already assigned to this agent")
+	}
+	assignee, err := s.agentRepo.GetByID(ctx, assigneeID)
+	if err != nil || assignee == nil || assignee.CompanyID != t.CompanyID {
+		return nil, fmt.Errorf("assignee not found")
+	}
+	content := fmt.Sprintf("%s 将任务改派给 %s", actor.Name, assignee.Name)
+	if err := s.changeAssignee(ctx, t, assignee, appendReason(content, reason), actor.ID); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Unassign 取消分配（任务回到 pending，可被认领或重新分配）
+func (s *TaskService) Unassign(ctx context.Context, taskID string, actor *domain.Agent, reason string) (*domain.Task, error) {
+	t, err := s.loadForAssignment(ctx, taskID, actor)
+	if err != nil {
+		return nil, err
+	}
+	if t.AssigneeID == nil {
+		return nil, fmt.Errorf("task is not assigned")
+	}
+	content := fmt.Sprintf("%s 取消了任务分配", actor.Name)
+	if err := s.changeAssignee(ctx, t, nil, appendReason(content, reason), actor.ID); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Claim 认领本部门可见的未分配 pending 任务
+func (s *TaskService) Claim(ctx context.Context, taskID string, agent *domain.Agent) (*domain.Task, error) {
+	t, err := s.taskRepo.GetByID(ctx, taskID)
+	if err != nil || t == nil || t.CompanyID != agent.CompanyID {
+		return nil, fmt.Errorf("task not found")
+	}
+	if t.Status != domain.TaskStatusPending || t.AssigneeID != nil {
+		return nil, fmt.Errorf("only unassigned pending tasks can be claimed")
+	}
+	if !s.claimableBy(ctx, t, agent) {
+		return nil, fmt.Errorf("task is not visible to your department")
+	}
+	if err := s.changeAssignee(ctx, t, agent, fmt.Sprintf("%s 认领了任务", agent.Name), agent.ID); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ListClaimable 当前 Agent 可认领的任务（未分配的 pending 任务，按部门可见性过滤）
+func (s *TaskService) ListClaimable(ctx context.Context, agent *domain.Agent) ([]*domain.Task, error) {
+	tasks, _, err := s.taskRepo.List(ctx, repository.TaskQuery{
+		CompanyID:  agent.CompanyID,
+		Status:     domain.TaskStatusPending,
+		Unassigned: true,
+		ParentID:   new(string),
+		Limit:      200,
+	})
+	if err != nil {
+		return nil, err
+	}
+	claimable := make([]*domain.Task, 0, len(tasks))
+	for _, t := range tasks {
+		if s.claimableBy(ctx, t, agent) {
+			claimable = append(claimable, t)
+		}
+	}
+	return claimable, nil
+}
+
+// SuggestAssignees 按职位、部门、在线状态、当前负载和相似标签历史成功率为任务排序候选人
+func (s *TaskService) SuggestAssignees(ctx context.Context, t *domain.Task) ([]*AssigneeCandidate, error) {
+	agents, err := s.agentRepo.GetByCompany(ctx, t.CompanyID)
+	if err != nil {
+		return nil, err
+	}
+	load, err := s.taskRepo.CountOpenByAssignee(ctx, t.CompanyID)
+	if err != nil {
+		return nil, err
+	}
+	var history []*domain.Task
+	if len(t.Tags) > 0 {
+		if history, err = s.taskRepo.ListFinishedTagged(ctx, t.CompanyID, assignHistoryLimit); err != nil {
+			return nil, err
+		}
+	}
+	return rankAssignees(t, taskDepartments(t, s.creatorOf(ctx, t)), agents, load, history), nil
+}
+
+// AutoAssign 将未分配任务分配给评分最高的候选人；actor 为 nil 表示系统自动分配
+func (s *TaskService) AutoAssign(ctx context.Context, t *domain.Task, actor *domain.Agent) (*AssigneeCandidate, error) {
+	if t.Status != domain.TaskStatusPending || t.AssigneeID != nil {
+		return nil, fmt.Errorf("only unassigned pending tasks can be auto-assigned")
+	}
+	candidates, err := s.SuggestAssignees(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no eligible assignee")
+	}
+	best := candidates[0]
+	assignee, err := s.agentRepo.GetByID(ctx, best.AgentID)
+	if err != nil || assignee == nil {
+		return nil, fmt.Errorf("assignee not found")
+	}
+	actorID, by := "", "系统"
+	if actor != nil {
+		actorID, by = actor.ID, actor.Name
+	} else if t.CreatedBy != nil {
+		actorID = *t.CreatedBy // 系统代创建者分配，评论记在创建者名下
+	}
+	content := fmt.Sprintf("%s 自动分配给 %s（评分 %.1f：%s）", by, assignee.Name, best.Score, strings.Join(best.Reasons, "，"))
+	if err := s.changeAssignee(ctx, t, assignee, content, actorID); err != nil {
+		return nil, err
+	}
+	return best, nil
+}
+
+// loadForAssignment 加载任务并校验改派权限：董事长/task:manage、任务创建者或当前负责人
+func (s *TaskService) loadForAssignment(ctx context.Context, taskID string, actor *domain.Agent) (*domain.Task, error) {
+	t, err := s.taskRepo.GetByID(ctx, taskID)
+	if err != nil || t == nil || t.CompanyID != actor.CompanyID {
+		return nil, fmt.Errorf("task not found")
+	}
+	switch t.Status {
+	case domain.TaskStatusPending, domain.TaskStatusAssigned, domain.TaskStatusInProgress:
+	default:
+		return nil, fmt.Errorf("cannot reassign task in status %s", t.Status)
+	}
+	isCreator := t.CreatedBy != nil && *t.CreatedBy == actor.ID
+	isAssignee := t.AssigneeID != nil && *t.AssigneeID == actor.ID
+	if !actor.HasPermission(domain.PermTaskManage) && !isCreator && !isAssignee {
+		return nil, fmt.Errorf("permission denied")
+	}
+	return t, nil
+}
+
+// changeAssignee 在事务中更新负责人、记录评论（actorID 为空时不记录）并广播；assignee 为 nil 时取消分配。
+// 提交后私信新负责人与被替换的原负责人。
+func (s *TaskService) changeAssignee(ctx context.Context, t *domain.Task, assignee *domain.Agent, comment, actorID string) error {
+	next := *t
+	next.Version = t.Version + 1
+	next.AssigneeID = nil
+	next.Status = domain.TaskStatusPending
+	if assignee != nil {
+		id := assignee.ID
+		next.AssigneeID = &id
+		next.Status = domain.TaskStatusAssigned
+	}
+	updated := event.NewEvent(event.TaskUpdated, event.TaskUpdatedPayload{
+		TaskID: next.ID, CompanyID: next.CompanyID, Status: string(next.Status), Title: next.Title, AssigneeID: next.AssigneeID,
+	})
+	err := s.commitWithEvents(ctx, func(ctx context.Context) error {
+		if err := s.taskRepo.UpdateAssignee(ctx, t.ID, t.Version, next.AssigneeID, next.Status); err != nil {
+			return err
+		}
+		if err := s.collabRepo.AddEvents(ctx, []*domain.TaskEvent{
+			newTaskEvent(t, actorID, "assignee_id", derefString(t.AssigneeID), derefString(next.AssigneeID)),
+		}); err != nil {
+			return err
+		}
+		if actorID != "" {
+			if err := s.collabRepo.AddComment(ctx, &domain.TaskComment{
+				ID:        uuid.New().String(),
+				TaskID:    t.ID,
+				CompanyID: t.CompanyID,
+				AgentID:   actorID,
+				Content:   comment,
+			}); err != nil {
+				return err
+			}
+		}
+		return s.broadcastTaskUpdate(ctx, &next)
+	}, updated)
+	if err != nil {
+		return err
+	}
+
+	prev := t.AssigneeID
+	*t = next
+	if assignee != nil && assignee.ID != actorID {
+		s.sendSystemDM(ctx, t.CompanyID, assignee.ID,
+			fmt.Sprintf("📌 任务「%s」已分配给你（%s）。请使用 accept_task 开始。任务 ID：%s", t.Title, comment, t.ID))
+	}
+	if prev != nil && *prev != actorID && (assignee == nil || *prev != assignee.ID) {
+		s.sendSystemDM(ctx, t.CompanyID, *prev,
+			fmt.Sprintf("任务「%s」已不再由你负责（%s）。任务 ID：%s", t.Title, comment, t.ID))
+	}
+	return nil
+}
+
+func (s *TaskService) creatorOf(ctx context.Context, t *domain.Task) *domain.Agent {
+	if t.CreatedBy == nil {
+		return nil
+	}
+	creator, _ := s.agentRepo.GetByID(ctx, *t.CreatedBy)
+	return creator
+}
+
+func (s *TaskService) claimableBy(ctx context.Context, t *domain.Task, agent *domain.Agent) bool {
+	depts := taskDepartments(t, s.creatorOf(ctx, t))
+	if len(depts) == 0 {
+		return true
+	}
+	for _, d := range depts {
+		if d == agent.DepartmentSlug() || (agent.DepartmentID != nil && d == "id:"+*agent.DepartmentID) {
+			return true
+		}
+	}
+	return false
+}
+
+// taskDepartments 任务面向的部门：标签中的部门 slug 优先，否则取创建者所在部门；
+// 董事长/高管/人类创建且无部门标签的任务不限部门（返回空）。
+func taskDepartments(t *domain.Task, creator *domain.Agent) []string {
+	var depts []string
+	for _, tag := range t.Tags {
+		if domain.IsDepartmentSlug(tag) {
+			depts = append(depts, tag)
+		}
+	}
+	if len(depts) > 0 || creator == nil || creator.IsHuman || creator.RoleType == domain.RoleChairman {
+		return depts
+	}
+	if creator.DepartmentID != nil {
+		depts = append(depts, "id:"+*creator.DepartmentID)
+	}
+	if slug := creator.DepartmentSlug(); slug != "" && slug != "executive" {
+		depts = append(depts, slug)
+	} else if creator.DepartmentID == nil {
+		return nil
+	}
+	return depts
+}
+
+// rankAssignees 候选人评分（高分在前）：
+// 职位命中标签 +3，部门匹配 +2，在线 +1.5 / 忙碌 +0.5 / 闲置 -0.5 / 离线 -2，每个未完成任务 -0.75，
+// 相似标签历史成功率（拉普拉斯平滑）映射到 [-1, +1]，另按完成数加经验分（最多 +1）。
+// 人类、董事长及未完成入职的 Agent 不参与。
+func rankAssignees(t *domain.Task, depts []string, agents []*domain.Agent, load map[string]int, history []*domain.Task) []*AssigneeCandidate {
+	tags := make(map[string]bool, len(t.Tags))
+	for _, tag := range t.Tags {
+		tags[tag] = true
+	}
+	type record struct{ done, failed int }
+	records := make(map[string]*record)
+	for _, h := range history {
+		if h.AssigneeID == nil || !sharesTag(h.Tags, tags) {
+			continue
+		}
+		r := records[*h.AssigneeID]
+		if r == nil {
+			r = &record{}
+			records[*h.AssigneeID] = r
+		}
+		if h.Status == domain.TaskStatusDone {
+			r.done++
+		} else {
+			r.failed++
+		}
+	}
+
+	candidates := make([]*AssigneeCandidate, 0, len(agents))
+	for _, a := range agents {
+		if a.IsHuman || a.RoleType == domain.RoleChairman || !a.Initialized {
+			continue
+		}
+		c := &AssigneeCandidate{AgentID: a.ID, Name: a.Name, Position: string(a.Position), Status: string(a.Status), Load: load[a.ID]}
+		if tags[string(a.Position)] {
+			c.Score += 3
+			c.Reasons = append(c.Reasons, "职位匹配")
+		}
+		for _, d := range depts {
+			if d == a.DepartmentSlug() || (a.DepartmentID != nil && d == "id:"+*a.DepartmentID) {
+				c.Score += 2
+				c.Reasons = append(c.Reasons, "部门匹配")
+				break
+			}
+		}
+		switch a.Status {
+		case domain.StatusOnline:
+			c.Score += 1.5
+			c.Reasons = append(c.Reasons, "在线")
+		case domain.StatusBusy:
+			c.Score += 0.5
+			c.Reasons = append(c.Reasons, "忙碌")
+		case domain.StatusIdle:
+			// 仍连接但长时间无操作：可能响应较慢，仍优于离线
+			c.Score -= 0.5
+			c.Reasons = append(c.Reasons, "闲置")
+		default:
+			c.Score -= 2
+			c.Reasons = append(c.Reasons, "离线")
+		}
+		if c.Load > 0 {
+			c.Score -= 0.75 * float64(c.Load)
+			c.Reasons = append(c.Reasons, fmt.Sprintf("进行中 %d 个", c.Load))
+		}
+		if r := records[a.ID]; r != nil {
+			rate := float64(r.done+1) / float64(r.done+r.failed+2)
+			c.Score += 2*rate - 1 + 0.2*min(float64(r.done), 5)
+			c.Reasons = append(c.Reasons, fmt.Sprintf("相似任务 %d/%d 成功", r.done, r.done+r.failed))
+		}
+		candidates = append(candidates, c)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].Load < candidates[j].Load
+	})
+	return candidates
+}
+
+func sharesTag(list domain.StringList, tags map[string]bool) bool {
+	for _, tag := range list {
+		if tags[tag] {
+			return true
+		}
+	}
+	return false
+}
+
+func appendReason(content, reason string) string {
+	if reason = strings.TrimSpace(reason); reason != "" {
+		return content + "，原因：" + reason
+	}
+	return content
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+func assignAgent(id string, pos domain.Position, status domain.AgentStatus) *domain.Agent {
+	return &domain.Agent{ID: id, Name: id, Position: pos, Status: status, Initialized: true, RoleType: domain.RoleEmployee}
+}
+
+func TestRankAssignees(t *testing.T) {
+	task := &domain.Task{ID: "t", Tags: domain.StringList{"backend_dev", "api"}}
+	agents := []*domain.Agent{
+		assignAgent("busy-backend", domain.PositionBackendDev, domain.StatusOnline),
+		assignAgent("idle-backend", domain.PositionBackendDev, domain.StatusOnline),
+		assignAgent("frontend", domain.PositionFrontendDev, domain.StatusOnline),
+		assignAgent("offline-backend", domain.PositionBackendDev, domain.StatusOffline),
+		{ID: "human", IsHuman: true, Initialized: true, Position: domain.PositionBackendDev, Status: domain.StatusOnline},
+		{ID: "new", Initialized: false, Position: domain.PositionBackendDev, Status: domain.StatusOnline},
+	}
+	load := map[string]int{"busy-backend": 3}
+	frontend := "frontend"
+	history := []*domain.Task{
+		{AssigneeID: &frontend, Status: domain.TaskStatusDone, Tags: domain.StringList{"api"}},
+		{AssigneeID: &frontend, Status: domain.TaskStatusDone, Tags: domain.StringList{"api"}},
+	}
+
+	got := rankAssignees(task, []string{"engineering"}, agents, load, history)
+	order := make([]string, 0, len(got))
+	for _, c := range got {
+		order = append(order, c.AgentID)
+	}
+	want := []string{"idle-backend", "frontend", "busy-backend", "offline-backend"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
+
+func TestRankAssignees_IdleBetweenOnlineAndOffline(t *testing.T) {
+	task := &domain.Task{ID: "t", Tags: domain.StringList{"backend_dev"}}
+	agents := []*domain.Agent{
+		assignAgent("offline", domain.PositionBackendDev, domain.StatusOffline),
+		assignAgent("idle", domain.PositionBackendDev, domain.StatusIdle),
+		assignAgent("online", domain.PositionBackendDev, domain.StatusOnline),
+	}
+
+	got := rankAssignees(task, nil, agents, nil, nil)
+	order := make([]string, 0, len(got))
+	for _, c := range got {
+		order = append(order, c.AgentID)
+	}
+	if want := []string{"online", "idle", "offline"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+	if got[1].Score >= got[0].Score || got[1].Score <= got[2].Score {
+		t.Errorf("scores = %.2f / %.2f / %.2f", got[0].Score, got[1].Score, got[2].Score)
+	}
+}
+
+func TestTaskDepartments(t *testing.T) {
+	dept := "dept-1"
+	tests := []struct {
+		name    string
+		task    *domain.Task
+		creator *domain.Agent
+		want    []string
+	}{
+		{"department tag wins", &domain.Task{Tags: domain.StringList{"marketing", "x"}}, assignAgent("c", domain.PositionBackendDev, ""), []string{"marketing"}},
+		{"creator department", &domain.Task{}, assignAgent("c", domain.PositionBackendDev, ""), []string{"engineering"}},
+		{"creator custom department", &domain.Task{}, &domain.Agent{DepartmentID: &dept, Position: domain.PositionBackendDev}, []string{"id:dept-1", "engineering"}},
+		{"executive is open", &domain.Task{}, assignAgent("c", domain.PositionCTO, ""), nil},
+		{"chairman is open", &domain.Task{}, &domain.Agent{RoleType: domain.RoleChairman}, nil},
+		{"no creator is open", &domain.Task{}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := taskDepartments(tt.task, tt.creator); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 改派不要求 task:manage：创建者与当前负责人同样可以操作，其他人由服务层拒绝
+func TestReassign_Permissions(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   *domain.Agent
+		wantErr string
+	}{
+		{"assignee", &domain.Agent{ID: "worker", CompanyID: "c1"}, ""},
+		{"creator", &domain.Agent{ID: "owner", CompanyID: "c1"}, ""},
+		{"manager", &domain.Agent{ID: "lead", CompanyID: "c1", Permissions: domain.StringList{domain.PermTaskManage}}, ""},
+		{"unrelated", &domain.Agent{ID: "peer", CompanyID: "c1"}, "permission denied"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := inProgressTask()
+			creator := "owner"
+			task.CreatedBy = &creator
+			f := newTaskServiceFixture(task)
+			f.addAgents(&domain.Agent{ID: "next", Name: "next", CompanyID: "c1"})
+
+			_, err := f.svc.Reassign(context.Background(), "t1", tt.actor, "next", "换人")
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				if *f.tasks.tasks["t1"].AssigneeID != "worker" {
+					t.Error("task reassigned despite denial")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.tasks.tasks["t1"]; *got.AssigneeID != "next" || got.Status != domain.TaskStatusAssigned {
+				t.Errorf("task = %s/%s, want assigned to next", *got.AssigneeID, got.Status)
+			}
+		})
+	}
+}
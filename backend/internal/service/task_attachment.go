@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+const (
+	// TaskAttachmentMaxSize 单个附件大小上限
+	TaskAttachmentMaxSize = 10 << 20 // 10MB
+	// taskAttachmentMaxPerTask 单个任务附件数量上限
+	taskAttachmentMaxPerTask = 50
+)
+
+var (
+	taskAttachmentAllowedExtensions = map[string]struct{}{
+		".jpg":    {},
+		".jpeg":   {},
+		".png":    {},
+		".gif":    {},
+		".webp":   {},
+		".bmp":    {},
+		".svg":    {},
+		".pdf":    {},
+		".doc":    {},
+		".docx":   {},
+		".xls":    {},
+		".xlsx":   {},
+		".ppt":    {},
+		".pptx":   {},
+		".txt":    {},
+		".md":     {},
+		".csv":    {},
+		".zip":    {},
+		".tar.gz": {},
+	}
+	taskAttachmentAllowedMIMETypes = map[string]struct{}{
+		"application/pdf":    {},
+		"application/msword": {},
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document": {},
+		"application/vnd.ms-excel": {},
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         {},
+		"application/vnd.ms-powerpoint":                                             {},
+		"application/vnd.openxmlformats-officedocument.presentationml.presentation": {},
+		"text/plain":                   {},
+		"text/markdown":                {},
+		"text/csv":                     {},
+		"application/zip":              {},
+		"application/x-zip-compressed": {},
+		"application/x-tar":            {},
+		"application/gzip":             {},
+		"application/x-gzip":           {},
+	}
+)
+
+// validateTaskUpload 校验附件大小与类型，并补全 Size / MimeType
+func validateTaskUpload(file *TaskUploadFile) error {
+	file.OriginalFilename = filepath.Base(strings.TrimSpace(file.OriginalFilename))
+	if file.OriginalFilename == "" || file.OriginalFilename == "." || file.OriginalFilename == "/" {
+		return fmt.Errorf("attachment filename is required")
+	}
+	file.Size = int64(len(file.Content))
+	if file.Size == 0 {
+		return fmt.Errorf("file %q is empty", file.OriginalFilename)
+	}
+	if file.Size > TaskAttachmentMaxSize {
+		return fmt.Errorf("file %q exceeds 10MB limit", file.OriginalFilename)
+	}
+	// 不信任客户端声明的 Content-Type，以内容嗅探结果为准
+	mimeType, generic := detectTaskUploadType(file.OriginalFilename, file.Content)
+	if !isAllowedTaskAttachment(file.OriginalFilename, mimeType, generic) {
+		return fmt.Errorf("file %q type is not allowed", file.OriginalFilename)
+	}
+	file.MimeType = mimeType
+	return nil
+}
+
+// detectTaskUploadType 用 http.DetectContentType 嗅探内容类型；内容没有可识别特征时（纯文本、zip 容器、
+// 未知二进制、XML）按扩展名细化，并返回 generic=true
+func detectTaskUploadType(filename string, content []byte) (mimeType string, generic bool) {
+	sniffed := http.DetectContentType(content)
+	switch strings.Split(sniffed, ";")[0] {
+	case "application/octet-stream", "text/plain", "application/zip", "text/xml":
+		if byExt := mime.TypeByExtension(taskAttachmentExtension(filename)); byExt != "" {
+			return byExt, true
+		}
+		return sniffed, true
+	}
+	return sniffed, false
+}
+
+// isAllowedTaskAttachment 按 MIME 白名单判断；仅当内容类型不可识别时才退回扩展名白名单，
+// 避免把 HTML 等内容改名为 .txt 绕过检查
+func isAllowedTaskAttachment(filename, mimeType string, generic bool) bool {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+	if strings.HasPrefix(mimeType, "image/") {
+		return true
+	}
+	if _, ok := taskAttachmentAllowedMIMETypes[mimeType]; ok {
+		return true
+	}
+	if !generic {
+		return false
+	}
+	ext := taskAttachmentExtension(filename)
+	_, ok := taskAttachmentAllowedExtensions[ext]
+	return ok
+}
+
+func taskAttachmentExtension(name string) string {
+	lower := strings.ToLower(strings.TrimSpace(name))
+	if strings.HasSuffix(lower, ".tar.gz") {
+		return ".tar.gz"
+	}
+	return strings.ToLower(filepath.Ext(lower))
+}
+
+// storeAttachments 写入存储并落库；任一步失败时删除已写入的文件
+func (s *TaskService) storeAttachments(ctx context.Context, task *domain.Task, uploadedBy *string, files []TaskUploadFile) ([]*domain.TaskAttachment, error) {
+	now := time.Now().UTC()
+	attachments := make([]*domain.TaskAttachment, 0, len(files))
+	written := make([]string, 0, len(files))
+
+	for _, file := range files {
+		filename := uuid.NewString() + taskAttachmentExtension(file.OriginalFilename)
+		key := path.Join("tasks", task.ID, filename)
+		if err := s.files.Put(ctx, key, file.Content, file.MimeType); err != nil {
+			s.removeFiles(written)
+			return nil, fmt.Errorf("save task attachment: %w", err)
+		}
+		written = append(written, key)
+
+		sum := sha256.Sum256(file.Content)
+		attachments = append(attachments, &domain.TaskAttachment{
+			ID:               uuid.NewString(),
+			TaskID:           task.ID,
+			CompanyID:        task.CompanyID,
+			Filename:         filename,
+			OriginalFilename: file.OriginalFilename,
+			FileSize:         file.Size,
+			MimeType:         file.MimeType,
+			StoragePath:      key,
+			SHA256:           hex.EncodeToString(sum[:]),
+			UploadedBy:       uploadedBy,
+			CreatedAt:        now,
+		})
+	}
+
+	if err := s.taskRepo.CreateAttachments(ctx, attachments); err != nil {
+		s.removeFiles(written)
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func attachmentKeys(attachments []*domain.TaskAttachment) []string {
+	keys := make([]string, 0, len(attachments))
+	for _, a := range attachments {
+		keys = append(keys, a.StoragePath)
+	}
+	return keys
+}
+
+// removeFiles 尽力删除存储中的文件，失败只记录日志
+func (s *TaskService) removeFiles(keys []string) {
+	for _, key := range keys {
+		if err := s.files.Delete(context.Background(), key); err != nil {
+			log.Printf("[task] remove attachment file %s: %v", key, err)
+		}
+	}
+}
+
+// AddAttachments 为已有任务上传附件；公司内成员均可上传，已结束的任务不可再添加
+func (s *TaskService) AddAttachments(ctx context.Context, taskID string, actor *domain.Agent, files []TaskUploadFile) ([]*domain.TaskAttachment, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files uploaded")
+	}
+	t, err := s.taskRepo.GetByID(ctx, taskID)
+	if err != nil || t == nil || t.CompanyID != actor.CompanyID {
+		return nil, fmt.Errorf("task not found")
+	}
+	switch t.Status {
+	case domain.TaskStatusDone, domain.TaskStatusFailed, domain.TaskStatusCancelled:
+		return nil, fmt.Errorf("cannot add attachments to task in status %s", t.Status)
+	}
+	if len(t.Attachments)+len(files) > taskAttachmentMaxPerTask {
+		return nil, fmt.Errorf("a task can have at most %d attachments", taskAttachmentMaxPerTask)
+	}
+	for i := range files {
+		if err := validateTaskUpload(&files[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	var attachments []*domain.TaskAttachment
+	err = s.commitWithEvents(ctx, func(ctx context.Context) error {
+		var err error
+		if attachments, err = s.storeAttachments(ctx, t, &actor.ID, files); err != nil {
+			return err
+		}
+		events := make([]*domain.TaskEvent, 0, len(attachments))
+		for _, a := range attachments {
+			events = append(events, newTaskEvent(t, actor.ID, "attachment", "", a.OriginalFilename))
+		}
+		return s.collabRepo.AddEvents(ctx, events)
+	})
+	if err != nil {
+		// 事务回滚后附件记录不存在，已写入存储的文件一并删除
+		s.removeFiles(attachmentKeys(attachments))
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (s *TaskService) ListAttachments(ctx context.Context, taskID, companyID string) ([]*domain.TaskAttachment, error) {
+	t, err := s.taskRepo.GetByID(ctx, taskID)
+	if err != nil || t == nil || t.CompanyID != companyID {
+		return nil, fmt.Errorf("task not found")
+	}
+	return t.Attachments, nil
+}
+
+// getAttachment 加载附件并校验其属于 taskID 且在同一公司
+func (s *TaskService) getAttachment(ctx context.Context, taskID, attachmentID, companyID string) (*domain.TaskAttachment, error) {
+	a, err := s.taskRepo.GetAttachment(ctx, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil || a.TaskID != taskID || a.CompanyID != companyID {
+		return nil, fmt.Errorf("attachment not found")
+	}
+	return a, nil
+}
+
+// OpenAttachment 返回附件元数据与内容，调用方负责关闭
+func (s *TaskService) OpenAttachment(ctx context.Context, taskID, attachmentID, companyID string) (*domain.TaskAttachment, io.ReadCloser, error) {
+	a, err := s.getAttachment(ctx, taskID, attachmentID, companyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	rc, err := s.files.Get(ctx, a.StoragePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read attachment: %w", err)
+	}
+	return a, rc, nil
+}
+
+// DeleteAttachment 删除附件；仅上传者、任务创建者或 task:manage 可操作
+func (s *TaskService) DeleteAttachment(ctx context.Context, taskID, attachmentID string, actor *domain.Agent) error {
+	a, err := s.getAttachment(ctx, taskID, attachmentID, actor.CompanyID)
+	if err != nil {
+		return err
+	}
+	t, err := s.taskRepo.GetByID(ctx, taskID)
+	if err != nil || t == nil {
+		return fmt.Errorf("task not found")
+	}
+	isUploader := a.UploadedBy != nil && *a.UploadedBy == actor.ID
+	isCreator := t.CreatedBy != nil && *t.CreatedBy == actor.ID
+	if !actor.HasPermission(domain.PermTaskManage) && !isUploader && !isCreator {
+		return fmt.Errorf("permission denied")
+	}
+
+	err = s.commitWithEvents(ctx, func(ctx context.Context) error {
+		if err := s.taskRepo.DeleteAttachment(ctx, a.ID); err != nil {
+			return err
+		}
+		return s.collabRepo.AddEvents(ctx, []*domain.TaskEvent{
+			newTaskEvent(t, actor.ID, "attachment", a.OriginalFilename, ""),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	s.removeFiles([]string{a.StoragePath})
+	return nil
+}
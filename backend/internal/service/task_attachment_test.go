@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+func TestValidateTaskUpload_SniffsContentType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	tests := []struct {
+		name, filename, declared string
+		content                  []byte
+		want                     string // 期望 MIME 前缀（扩展名映射依赖系统 mime.types）；空表示应拒绝
+	}{
+		{"png ignores declared type", "shot.png", "application/pdf", png, "image/png"},
+		{"pdf", "spec.pdf", "", []byte("%PDF-1.7\n"), "application/pdf"},
+		{"plain text", "notes.txt", "", []byte("hello"), "text/plain; charset=utf-8"},
+		{"markdown by extension", "README.md", "text/html", []byte("# 标题\n"), "text/"},
+		{"html renamed to txt", "page.txt", "text/plain", []byte("<html><script>alert(1)</script></html>"), ""},
+		{"html declared as image", "x.png", "image/png", []byte("<!DOCTYPE html><p>hi"), ""},
+		{"unknown binary with bad extension", "tool.exe", "", []byte{0x4d, 0x5a, 0x90, 0x00, 0x03}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := TaskUploadFile{OriginalFilename: tt.filename, MimeType: tt.declared, Content: tt.content}
+			err := validateTaskUpload(&f)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("accepted as %s, want rejection", f.MimeType)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(f.MimeType, tt.want) {
+				t.Errorf("mime = %q, want %q", f.MimeType, tt.want)
+			}
+		})
+	}
+}
+
+// memFileStorage 内存文件存储
+type memFileStorage struct {
+	files map[string][]byte
+}
+
+func (s *memFileStorage) Put(_ context.Context, key string, data []byte, _ string) error {
+	s.files[key] = data
+	return nil
+}
+
+func (s *memFileStorage) Get(context.Context, string) (io.ReadCloser, error) {
+	panic("not implemented")
+}
+
+func (s *memFileStorage) Delete(_ context.Context, key string) error {
+	delete(s.files, key)
+	return nil
+}
+
+func TestAddAttachments_RemovesFilesWhenTxFails(t *testing.T) {
+	f := newTaskServiceFixture(inProgressTask())
+	files := &memFileStorage{files: map[string][]byte{}}
+	f.svc.files = files
+	f.collab.eventErr = errors.New("db down")
+	actor := reviewAgent("worker", "")
+
+	_, err := f.svc.AddAttachments(context.Background(), "t1", actor, []TaskUploadFile{
+		{OriginalFilename: "a.txt", Content: []byte("a")},
+		{OriginalFilename: "b.txt", Content: []byte("b")},
+	})
+	if err == nil {
+		t.Fatal("expected error from AddEvents")
+	}
+	if len(files.files) != 0 {
+		t.Errorf("%d files left in storage after rollback", len(files.files))
+	}
+
+	f.collab.eventErr = nil
+	got, err := f.svc.AddAttachments(context.Background(), "t1", actor, []TaskUploadFile{{OriginalFilename: "a.txt", Content: []byte("a")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || files.files[got[0].StoragePath] == nil {
+		t.Errorf("stored %d attachments, files = %v", len(got), files.files)
+	}
+}
+
+func TestDelete_RemovesSubtreeAttachments(t *testing.T) {
+	root, child, grandchild, other := "t1", "t2", "t3", "t4"
+	f := newTaskServiceFixture(
+		&domain.Task{ID: root, CompanyID: "c1"},
+		&domain.Task{ID: child, CompanyID: "c1", ParentID: &root},
+		&domain.Task{ID: grandchild, CompanyID: "c1", ParentID: &child},
+		&domain.Task{ID: other, CompanyID: "c1"},
+	)
+	files := &memFileStorage{files: map[string][]byte{}}
+	f.svc.files = files
+	for _, id := range []string{root, child, grandchild, other} {
+		key := "tasks/" + id + "/a.txt"
+		files.files[key] = []byte("a")
+		_ = f.tasks.CreateAttachments(context.Background(), []*domain.TaskAttachment{{TaskID: id, StoragePath: key}})
+	}
+
+	if err := f.svc.Delete(context.Background(), root, "c1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.tasks.tasks) != 1 || f.tasks.tasks[other] == nil {
+		t.Errorf("tasks left = %v, want only %s", f.tasks.tasks, other)
+	}
+	if len(files.files) != 1 || files.files["tasks/"+other+"/a.txt"] == nil {
+		t.Errorf("files left = %v, want only the unrelated task's file", files.files)
+	}
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/event"
+)
+
+// commentPreviewLen 通知私信中评论摘要的最大字数
+const commentPreviewLen = 80
+
+// mentionDirectory 同公司 Agent 的 @ 名称索引：ID、名字与 "职位-名字" 都可被 @
+type mentionDirectory struct {
+	names  []string          // 按长度降序，优先匹配最长的名称
+	byName map[string]string // 名称 → Agent ID
+	labels map[string]string // Agent ID → 展示名
+}
+
+func newMentionDirectory(agents []*domain.Agent) *mentionDirectory {
+	d := &mentionDirectory{byName: map[string]string{}, labels: map[string]string{}}
+	add := func(name, id string) {
+		if name == "" {
+			return
+		}
+		if _, ok := d.byName[name]; !ok {
+			d.names = append(d.names, name)
+		}
+		d.byName[name] = id
+	}
+	for _, a := range agents {
+		label := a.Name
+		if meta, ok := domain.PositionMetaByPosition[a.Position]; ok {
+			label = meta.DisplayName + "-" + a.Name
+		}
+		d.labels[a.ID] = label
+		add(a.ID, a.ID)
+		add(a.Name, a.ID)
+		add(label, a.ID)
+	}
+	sort.Slice(d.names, func(i, j int) bool { return len(d.names[i]) > len(d.names[j]) })
+	return d
+}
+
+// parse 提取 content 中 @ 到的 Agent ID（去重，保持出现顺序）。
+// 名称后可直接跟正文（中文习惯不加空格），因此按最长前缀匹配而非按空白切分。
+func (d *mentionDirectory) parse(content string) []string {
+	var ids []string
+	seen := map[string]bool{}
+	for i := strings.IndexByte(content, '@'); i >= 0; {
+		rest := content[i+1:]
+		for _, name := range d.names {
+			// 以字母数字结尾的名称后不能紧跟字母数字，避免 @Bob 命中 @Bobby
+			if strings.HasPrefix(rest, name) && !(isMentionWordByte(name, len(name)-1) && isMentionWordByte(rest, len(name))) {
+				if id := d.byName[name]; !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+				break
+			}
+		}
+		next := strings.IndexByte(rest, '@')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return ids
+}
+
+func (d *mentionDirectory) label(id string) string {
+	if l, ok := d.labels[id]; ok {
+		return l
+	}
+	return id
+}
+
+func (s *TaskService) mentionDirectory(ctx context.Context, companyID string) *mentionDirectory {
+	agents, err := s.agentRepo.GetByCompany(ctx, companyID)
+	if err != nil {
+		return newMentionDirectory(nil)
+	}
+	return newMentionDirectory(agents)
+}
+
+// AddComment 添加评论（parentCommentID 非空时为回复），解析 @提及并通知被提及者、被回复者与关注者
+func (s *TaskService) AddComment(ctx context.Context, taskID, agentID, content string, parentCommentID *string) (*domain.TaskComment, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, fmt.Errorf("comment content is required")
+	}
+	t, err := s.taskRepo.GetByID(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, fmt.Errorf("task not found")
+	}
+	var parent *domain.TaskComment
+	if parentCommentID != nil && *parentCommentID != "" {
+		if parent, err = s.collabRepo.GetComment(ctx, *parentCommentID); err != nil {
+			return nil, err
+		}
+		if parent == nil || parent.TaskID != taskID {
+			return nil, fmt.Errorf("parent comment not found")
+		}
+	} else {
+		parentCommentID = nil
+	}
+
+	dir := s.mentionDirectory(ctx, t.CompanyID)
+	c := &domain.TaskComment{
+		ID:              uuid.New().String(),
+		TaskID:          taskID,
+		CompanyID:       t.CompanyID,
+		AgentID:         agentID,
+		ParentCommentID: parentCommentID,
+		Content:         content,
+		Mentions:        domain.StringList(dir.parse(content)),
+	}
+	if c.Mentions == nil {
+		c.Mentions = domain.StringList{}
+	}
+	if err := s.collabRepo.AddComment(ctx, c); err != nil {
+		return nil, err
+	}
+
+	s.notifyComment(ctx, t, c, parent, dir, c.Mentions)
+	s.publishCommented(t, c, false)
+	return c, nil
+}
+
+// EditComment 修改评论内容（仅作者本人），保留编辑历史；仅通知本次新增的 @提及
+func (s *TaskService) EditComment(ctx context.Context, commentID string, actor *domain.Agent, content string) (*domain.TaskComment, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, fmt.Errorf("comment content is required")
+	}
+	c, err := s.collabRepo.GetComment(ctx, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil || c.CompanyID != actor.CompanyID {
+		return nil, fmt.Errorf("comment not found")
+	}
+	if c.AgentID != actor.ID {
+		return nil, fmt.Errorf("permission denied")
+	}
+	if c.Content == content {
+		return c, nil
+	}
+	t, err := s.taskRepo.GetByID(ctx, c.TaskID)
+	if err != nil || t == nil {
+		return nil, fmt.Errorf("task not found")
+	}
+
+	dir := s.mentionDirectory(ctx, c.CompanyID)
+	mentions := domain.StringList(dir.parse(content))
+	if mentions == nil {
+		mentions = domain.StringList{}
+	}
+	if err := s.collabRepo.UpdateComment(ctx, c.ID, actor.ID, content, mentions); err != nil {
+		return nil, err
+	}
+
+	previous := make(map[string]bool, len(c.Mentions))
+	for _, id := range c.Mentions {
+		previous[id] = true
+	}
+	var added []string
+	for _, id := range mentions {
+		if !previous[id] {
+			added = append(added, id)
+		}
+	}
+	now := time.Now()
+	c.Content = content
+	c.Mentions = mentions
+	c.EditedAt = &now
+
+	s.notifyMentions(ctx, t, c, dir, added)
+	s.publishCommented(t, c, true)
+	return c, nil
+}
+
+func (s *TaskService) ListCommentEdits(ctx context.Context, commentID, companyID string) ([]*domain.TaskCommentEdit, error) {
+	c, err := s.collabRepo.GetComment(ctx, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil || c.CompanyID != companyID {
+		return nil, fmt.Errorf("comment not found")
+	}
+	return s.collabRepo.ListCommentEdits(ctx, commentID)
+}
+
+// notifyComment 依次通知被 @ 者、被回复的评论作者和任务关注者；每人最多一条，作者本人不通知
+func (s *TaskService) notifyComment(ctx context.Context, t *domain.Task, c *domain.TaskComment, parent *domain.TaskComment, dir *mentionDirectory, mentions []string) {
+	notified := s.notifyMentions(ctx, t, c, dir, mentions)
+	author := dir.label(c.AgentID)
+	preview := commentPreview(c.Content)
+
+	if parent != nil && parent.AgentID != c.AgentID && !notified[parent.AgentID] {
+		notified[parent.AgentID] = true
+		s.sendSystemDM(ctx, t.CompanyID, parent.AgentID, fmt.Sprintf(
+			"↩️ %s 回复了你在任务「%s」中的评论：%s\n任务 ID：%s，评论 ID：%s", author, t.Title, preview, t.ID, c.ID))
+	}
+
+	watchers, err := s.collabRepo.ListWatchers(ctx, t.ID)
+	if err != nil {
+		return
+	}
+	for _, w := range watchers {
+		if w.AgentID == c.AgentID || notified[w.AgentID] {
+			continue
+		}
+		notified[w.AgentID] = true
+		s.sendSystemDM(ctx, t.CompanyID, w.AgentID, fmt.Sprintf(
+			"👀 你关注的任务「%s」有新评论（%s）：%s\n任务 ID：%s，评论 ID：%s", t.Title, author, preview, t.ID, c.ID))
+	}
+}
+
+// notifyMentions 私信通知被 @ 的 Agent，返回已通知集合
+func (s *TaskService) notifyMentions(ctx context.Context, t *domain.Task, c *domain.TaskComment, dir *mentionDirectory, mentions []string) map[string]bool {
+	notified := map[string]bool{}
+	author := dir.label(c.AgentID)
+	for _, id := range mentions {
+		if id == c.AgentID || notified[id] {
+			continue
+		}
+		notified[id] = true
+		s.sendSystemDM(ctx, t.CompanyID, id, fmt.Sprintf(
+			"💬 %s 在任务「%s」的评论中提到了你：%s\n任务 ID：%s，评论 ID：%s（可用 add_task_comment 传 parent_comment_id 回复）",
+			author, t.Title, commentPreview(c.Content), t.ID, c.ID))
+	}
+	return notified
+}
+
+func (s *TaskService) publishCommented(t *domain.Task, c *domain.TaskComment, edited bool) {
+	event.Global.Publish(event.NewEvent(event.TaskCommented, event.TaskCommentedPayload{
+		TaskID:          t.ID,
+		CompanyID:       t.CompanyID,
+		Title:           t.Title,
+		CommentID:       c.ID,
+		ParentCommentID: c.ParentCommentID,
+		AgentID:         c.AgentID,
+		Content:         c.Content,
+		Mentions:        []string(c.Mentions),
+		Edited:          edited,
+	}))
+}
+
+func commentPreview(content string) string {
+	r := []rune(content)
+	if len(r) > commentPreviewLen {
+		return string(r[:commentPreviewLen]) + "…"
+	}
+	return content
+}
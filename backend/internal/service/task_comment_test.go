@@ -0,0 +1,38 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+func TestMentionDirectoryParse(t *testing.T) {
+	dir := newMentionDirectory([]*domain.Agent{
+		{ID: "a1", Name: "小王", Position: domain.PositionCTO},
+		{ID: "a2", Name: "小王子"},
+		{ID: "a3", Name: "Alice"},
+		{ID: "a4", Name: "Bob"},
+	})
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"@小王 看一下", []string{"a1"}},
+		{"@小王子帮忙确认", []string{"a2"}},
+		{"@技术总监-小王 和 @Alice 请跟进，@小王 也看看", []string{"a1", "a3"}},
+		{"@a3 ping", []string{"a3"}},
+		{"邮件 foo@bar.com 无人被提及", nil},
+		{"@Bob请看", []string{"a4"}},
+		{"@Bob, ping", []string{"a4"}},
+		{"@Bobby 不是 Bob", nil},
+		{"@Alice_bot 也不是", nil},
+		{"@小王abc 中文名后可紧跟字母", []string{"a1"}},
+		{"没有提及", nil},
+	}
+	for _, tt := range tests {
+		if got := dir.parse(tt.content); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parse(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+// TaskDAGEdge 依赖边：From 完成后 To 才能开始
+type TaskDAGEdge struct {
+	From string `json:"from"` // depends_on_id
+	To   string `json:"to"`   // task_id
+}
+
+// TaskDAG 父任务及其全部子任务的依赖图
+type TaskDAG struct {
+	RootID           string         `json:"root_id"`
+	Nodes            []*domain.Task `json:"nodes"`
+	Edges            []TaskDAGEdge  `json:"edges"`
+	ExternalEdges    []TaskDAGEdge  `json:"external_edges"`    // 依赖了图外任务的边
+	TopologicalOrder []string       `json:"topological_order"` // 可执行顺序（前置在前）
+	CriticalPath     []string       `json:"critical_path"`     // 剩余未完成任务数最多的依赖链
+}
+
+// GetDAG 构建父任务 + 子任务（递归）的依赖图
+func (s *TaskService) GetDAG(ctx context.Context, companyID, rootID string) (*TaskDAG, error) {
+	root, err := s.taskRepo.GetByID(ctx, rootID)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil || root.CompanyID != companyID {
+		return nil, fmt.Errorf("task not found")
+	}
+	descendants, err := s.taskRepo.ListDescendants(ctx, rootID)
+	if err != nil {
+		return nil, err
+	}
+	root.Subtasks = nil
+	nodes := append([]*domain.Task{root}, descendants...)
+	ids := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		ids = append(ids, n.ID)
+	}
+	deps, err := s.collabRepo.ListDependenciesAmong(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return buildTaskDAG(rootID, nodes, deps)
+}
+
+// buildTaskDAG 拓扑排序（Kahn，按节点原顺序稳定）并求关键路径：
+// 节点权重为 1（未完成）或 0（done/cancelled），取权重和最大的依赖链。
+func buildTaskDAG(rootID string, nodes []*domain.Task, deps []*domain.TaskDependency) (*TaskDAG, error) {
+	dag := &TaskDAG{RootID: rootID, Nodes: nodes, Edges: []TaskDAGEdge{}, ExternalEdges: []TaskDAGEdge{}}
+	index := make(map[string]int, len(nodes))
+	for i, n := range nodes {
+		index[n.ID] = i
+	}
+
+	succ := make([][]int, len(nodes))
+	preds := make([][]int, len(nodes))
+	indeg := make([]int, len(nodes))
+	for _, d := range deps {
+		to, ok := index[d.TaskID]
+		if !ok {
+			continue
+		}
+		from, ok := index[d.DependsOnID]
+		if !ok {
+			dag.ExternalEdges = append(dag.ExternalEdges, TaskDAGEdge{From: d.DependsOnID, To: d.TaskID})
+			continue
+		}
+		dag.Edges = append(dag.Edges, TaskDAGEdge{From: d.DependsOnID, To: d.TaskID})
+		succ[from] = append(succ[from], to)
+		preds[to] = append(preds[to], from)
+		indeg[to]++
+	}
+
+	order := make([]int, 0, len(nodes))
+	var queue []int
+	for i := range nodes {
+		if indeg[i] == 0 {
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		order = append(order, n)
+		for _, m := range succ[n] {
+			if indeg[m]--; indeg[m] == 0 {
+				queue = append(queue, m)
+			}
+		}
+	}
+	if len(order) != len(nodes) {
+		return nil, fmt.Errorf("dependency cycle detected")
+	}
+
+	best := make([]int, len(nodes))
+	prev := make([]int, len(nodes))
+	end := -1
+	for _, n := range order {
+		prev[n] = -1
+		for _, p := range preds[n] {
+			if prev[n] == -1 || best[p] > best[prev[n]] {
+				prev[n] = p
+			}
+		}
+		if prev[n] != -1 {
+			best[n] = best[prev[n]]
+		}
+		if s := nodes[n].Status; s != domain.TaskStatusDone && s != domain.TaskStatusCancelled {
+			best[n]++
+		}
+		if best[n] > 0 && (end == -1 || best[n] > best[end]) {
+			end = n
+		}
+	}
+
+	dag.TopologicalOrder = make([]string, 0, len(order))
+	for _, n := range order {
+		dag.TopologicalOrder = append(dag.TopologicalOrder, nodes[n].ID)
+	}
+	dag.CriticalPath = []string{}
+	for n := end; n != -1; n = prev[n] {
+		dag.CriticalPath = append([]string{nodes[n].ID}, dag.CriticalPath...)
+	}
+	return dag, nil
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+func dagTask(id string, status domain.TaskStatus) *domain.Task {
+	return &domain.Task{ID: id, Title: id, Status: status}
+}
+
+func dagDep(task, dependsOn string) *domain.TaskDependency {
+	return &domain.TaskDependency{TaskID: task, DependsOnID: dependsOn}
+}
+
+func TestBuildTaskDAG(t *testing.T) {
+	// root ← a ← b ← d，root ← c ← d；a 已完成；x 为图外依赖
+	nodes := []*domain.Task{
+		dagTask("root", domain.TaskStatusPending),
+		dagTask("a", domain.TaskStatusDone),
+		dagTask("b", domain.TaskStatusAssigned),
+		dagTask("c", domain.TaskStatusAssigned),
+		dagTask("d", domain.TaskStatusPending),
+	}
+	deps := []*domain.TaskDependency{
+		dagDep("b", "a"), dagDep("d", "b"), dagDep("d", "c"), dagDep("c", "root"), dagDep("c", "x"),
+	}
+
+	dag, err := buildTaskDAG("root", nodes, deps)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if want := []string{"root", "a", "c", "b", "d"}; !reflect.DeepEqual(dag.TopologicalOrder, want) {
+		t.Errorf("topological order = %v, want %v", dag.TopologicalOrder, want)
+	}
+	// a 已完成不计权重：root→c→d 与 a→b→d 中前者剩余更多
+	if want := []string{"root", "c", "d"}; !reflect.DeepEqual(dag.CriticalPath, want) {
+		t.Errorf("critical path = %v, want %v", dag.CriticalPath, want)
+	}
+	if len(dag.Edges) != 4 || len(dag.ExternalEdges) != 1 || dag.ExternalEdges[0].From != "x" {
+		t.Errorf("edges = %v, external = %v", dag.Edges, dag.ExternalEdges)
+	}
+}
+
+func TestBuildTaskDAG_Cycle(t *testing.T) {
+	nodes := []*domain.Task{dagTask("a", domain.TaskStatusPending), dagTask("b", domain.TaskStatusPending)}
+	if _, err := buildTaskDAG("a", nodes, []*domain.TaskDependency{dagDep("a", "b"), dagDep("b", "a")}); err == nil {
+		t.Fatal("expected cycle error")
+	}
+}
+
+func TestBuildTaskDAG_AllDone(t *testing.T) {
+	nodes := []*domain.Task{dagTask("a", domain.TaskStatusDone), dagTask("b", domain.TaskStatusDone)}
+	dag, err := buildTaskDAG("a", nodes, []*domain.TaskDependency{dagDep("b", "a")})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if len(dag.CriticalPath) != 0 {
+		t.Errorf("critical path should be empty when everything is done, got %v", dag.CriticalPath)
+	}
+}
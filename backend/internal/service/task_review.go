@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+// submitForReview in_progress → in_review，记录验收人并私信通知
+func (s *TaskService) submitForReview(ctx context.Context, t *domain.Task, reviewerID, result string) error {
+	if err := s.transitionWith(ctx, t, *t.AssigneeID, domain.TaskStatusInReview, &result, nil, &reviewerID, nil); err != nil {
+		return err
+	}
+	s.sendSystemDM(ctx, t.CompanyID, reviewerID, fmt.Sprintf(
+		"🔍 任务「%s」已提交，等待你验收。\n结果：%s\n请使用 approve_task 通过，或 request_task_changes 要求修改。任务 ID：%s",
+		t.Title, result, t.ID))
+	return nil
+}
+
+// Approve 验收通过：in_review → done
+func (s *TaskService) Approve(ctx context.Context, taskID string, reviewer *domain.Agent, comment string) (*domain.Task, error) {
+	t, err := s.loadForReview(ctx, taskID, reviewer)
+	if err != nil {
+		return nil, err
+	}
+	comment = strings.TrimSpace(comment)
+	err = s.transition(ctx, t, reviewer.ID, domain.TaskStatusDone, t.Result, nil, func(ctx context.Context) error {
+		return s.recordReview(ctx, t, reviewer, domain.TaskReviewApproved, comment, "✅ 验收通过")
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.sendSystemDM(ctx, t.CompanyID, *t.AssigneeID, appendReason(fmt.Sprintf("✅ 任务「%s」已由 %s 验收通过", t.Title, reviewer.Name), comment))
+	s.notifyUnblocked(ctx, t)
+	return t, nil
+}
+
+// RequestChanges 要求修改：in_review → in_progress，负责人修改后重新提交
+func (s *TaskService) RequestChanges(ctx context.Context, taskID string, reviewer *domain.Agent, comment string) (*domain.Task, error) {
+	comment = strings.TrimSpace(comment)
+	if comment == "" {
+		return nil, fmt.Errorf("comment is required when requesting changes")
+	}
+	t, err := s.loadForReview(ctx, taskID, reviewer)
+	if err != nil {
+		return nil, err
+	}
+	err = s.transition(ctx, t, reviewer.ID, domain.TaskStatusInProgress, t.Result, nil, func(ctx context.Context) error {
+		return s.recordReview(ctx, t, reviewer, domain.TaskReviewChangesRequested, comment, "🔁 要求修改")
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.sendSystemDM(ctx, t.CompanyID, *t.AssigneeID, fmt.Sprintf(
+		"🔁 任务「%s」验收未通过，%s 要求修改：%s\n修改完成后请再次使用 submit_task_result 提交。任务 ID：%s",
+		t.Title, reviewer.Name, comment, t.ID))
+	return t, nil
+}
+
+// loadForReview 加载待验收任务并校验验收人（指定验收人或拥有 task:manage 权限者，负责人本人除外）
+func (s *TaskService) loadForReview(ctx context.Context, taskID string, reviewer *domain.Agent) (*domain.Task, error) {
+	t, err := s.taskRepo.GetByID(ctx, taskID)
+	if err != nil || t == nil || t.CompanyID != reviewer.CompanyID {
+		return nil, fmt.Errorf("task not found")
+	}
+	if t.Status != domain.TaskStatusInReview || t.AssigneeID == nil {
+		return nil, fmt.Errorf("task is not awaiting review (status %s)", t.Status)
+	}
+	if *t.AssigneeID == reviewer.ID {
+		return nil, fmt.Errorf("cannot review your own task")
+	}
+	isReviewer := t.ReviewerID != nil && *t.ReviewerID == reviewer.ID
+	if !isReviewer && !reviewer.HasPermission(domain.PermTaskManage) {
+		return nil, fmt.Errorf("you are not the reviewer of this task")
+	}
+	return t, nil
+}
+
+func (s *TaskService) recordReview(ctx context.Context, t *domain.Task, reviewer *domain.Agent, outcome domain.TaskReviewOutcome, comment, label string) error {
+	if err := s.collabRepo.AddReview(ctx, &domain.TaskReview{
+		ID:         uuid.New().String(),
+		TaskID:     t.ID,
+		CompanyID:  t.CompanyID,
+		ReviewerID: reviewer.ID,
+		AssigneeID: *t.AssigneeID,
+		Outcome:    outcome,
+		Comment:    comment,
+	}); err != nil {
+		return err
+	}
+	content := label
+	if comment != "" {
+		content += "：" + comment
+	}
+	return s.collabRepo.AddComment(ctx, &domain.TaskComment{
+		ID:        uuid.New().String(),
+		TaskID:    t.ID,
+		CompanyID: t.CompanyID,
+		AgentID:   reviewer.ID,
+		Content:   content,
+	})
+}
+
+// resolveReviewer 按验收策略确定验收人；返工后再次提交沿用上一轮仍然有效的验收人。
+// 策略找不到人时依次回退到负责人的上级、任务创建者；都没有时返回错误，任务不会跳过验收
+func (s *TaskService) resolveReviewer(ctx context.Context, t *domain.Task) (string, error) {
+	if id := s.validReviewer(ctx, t, t.ReviewerID); id != "" {
+		return id, nil
+	}
+	assigneeID := *t.AssigneeID
+	assignee, err := s.agentRepo.GetByID(ctx, assigneeID)
+	if err != nil {
+		return "", err
+	}
+	var candidates []*string
+	switch t.ReviewPolicy {
+	case domain.TaskReviewCreator:
+		candidates = append(candidates, t.CreatedBy)
+	case domain.TaskReviewQA:
+		candidates = append(candidates, s.pickAgent(ctx, t.CompanyID, assigneeID, func(a *domain.Agent) bool {
+			return a.Position == domain.PositionQAEngineer
+		}))
+	}
+	if assignee != nil {
+		candidates = append(candidates, s.managerOf(ctx, assignee))
+	}
+	if t.ReviewPolicy != domain.TaskReviewCreator {
+		candidates = append(candidates, t.CreatedBy)
+	}
+	for _, c := range candidates {
+		if id := s.validReviewer(ctx, t, c); id != "" {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("no reviewer available for review policy %s", t.ReviewPolicy)
+}
+
+// validReviewer 候选验收人仍在本公司且不是负责人本人时返回其 ID，否则返回空串
+func (s *TaskService) validReviewer(ctx context.Context, t *domain.Task, id *string) string {
+	if id == nil || *id == "" || *id == *t.AssigneeID {
+		return ""
+	}
+	a, err := s.agentRepo.GetByID(ctx, *id)
+	if err != nil || a == nil || a.CompanyID != t.CompanyID {
+		return ""
+	}
+	return a.ID
+}
+
+// managerOf 直属上级：优先 manager_id，其次所在部门总监；都没有时返回 nil
+func (s *TaskService) managerOf(ctx context.Context, agent *domain.Agent) *string {
+	if agent.ManagerID != nil && *agent.ManagerID != "" {
+		return agent.ManagerID
+	}
+	dirPos, ok := domain.DepartmentDirectors[domain.DepartmentOf(agent.Position)]
+	if !ok {
+		return nil
+	}
+	return s.pickAgent(ctx, agent.CompanyID, agent.ID, func(a *domain.Agent) bool {
+		return a.Position == dirPos
+	})
+}
+
+// pickAgent 在符合条件的同事中选择负载最低者（在线优先），排除 excludeID
+func (s *TaskService) pickAgent(ctx context.Context, companyID, excludeID string, match func(*domain.Agent) bool) *string {
+	agents, err := s.agentRepo.GetByCompany(ctx, companyID)
+	if err != nil {
+		return nil
+	}
+	load, _ := s.taskRepo.CountOpenByAssignee(ctx, companyID)
+	var best *domain.Agent
+	for _, a := range agents {
+		if a.ID == excludeID || !match(a) {
+			continue
+		}
+		if best == nil || lessBusy(a, best, load) {
+			best = a
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	id := best.ID
+	return &id
+}
+
+func lessBusy(a, b *domain.Agent, load map[string]int) bool {
+	aOnline, bOnline := a.Status == domain.StatusOnline, b.Status == domain.StatusOnline
+	if aOnline != bOnline {
+		return aOnline
+	}
+	return load[a.ID] < load[b.ID]
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/linkclaw/backend/internal/domain"
+)
+
+func reviewAgent(id string, pos domain.Position) *domain.Agent {
+	return &domain.Agent{ID: id, Name: id, CompanyID: "c1", Position: pos, RoleType: domain.RoleEmployee}
+}
+
+func reviewTask(policy domain.TaskReviewPolicy) *domain.Task {
+	t := inProgressTask()
+	t.ReviewPolicy = policy
+	creator := "creator"
+	t.CreatedBy = &creator
+	return t
+}
+
+func TestTaskSubmit_ReviewerByPolicy(t *testing.T) {
+	manager := "manager"
+	tests := []struct {
+		name   string
+		policy domain.TaskReviewPolicy
+		agents []*domain.Agent
+		want   string
+	}{
+		{"creator", domain.TaskReviewCreator, nil, "creator"},
+		{"manager", domain.TaskReviewManager, []*domain.Agent{reviewAgent("manager", domain.PositionCTO)}, "manager"},
+		{"qa", domain.TaskReviewQA, []*domain.Agent{reviewAgent("qa", domain.PositionQAEngineer)}, "qa"},
+		{"qa falls back to manager", domain.TaskReviewQA, []*domain.Agent{reviewAgent("manager", domain.PositionCTO)}, "manager"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTaskServiceFixture(reviewTask(tt.policy))
+			worker := reviewAgent("worker", domain.PositionBackendDev)
+			worker.ManagerID = &manager
+			f.addAgents(worker, reviewAgent("creator", domain.PositionProductManager))
+			if tt.policy != domain.TaskReviewCreator {
+				delete(f.agents.agents, "creator")
+			}
+			f.addAgents(tt.agents...)
+
+			got, err := f.svc.Submit(context.Background(), "t1", "worker", nil, "完成")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != domain.TaskStatusInReview || got.ReviewerID == nil || *got.ReviewerID != tt.want {
+				t.Fatalf("status %s reviewer %v, want in_review by %s", got.Status, got.ReviewerID, tt.want)
+			}
+			if len(f.messages.created) != 1 || *f.messages.created[0].ReceiverID != tt.want {
+				t.Errorf("reviewer was not notified: %+v", f.messages.created)
+			}
+		})
+	}
+}
+
+func TestTaskSubmit_NoReviewerFails(t *testing.T) {
+	task := reviewTask(domain.TaskReviewQA)
+	task.CreatedBy = nil
+	f := newTaskServiceFixture(task)
+	f.addAgents(reviewAgent("worker", domain.PositionBackendDev))
+
+	_, err := f.svc.Submit(context.Background(), "t1", "worker", nil, "完成")
+	if err == nil || !strings.Contains(err.Error(), "no reviewer available") {
+		t.Fatalf("err = %v, want no reviewer available", err)
+	}
+	if f.tasks.tasks["t1"].Status != domain.TaskStatusInProgress {
+		t.Error("task must not skip review when no reviewer is found")
+	}
+}
+
+func TestTaskSubmit_PreviousReviewer(t *testing.T) {
+	t.Run("reused while still valid", func(t *testing.T) {
+		task := reviewTask(domain.TaskReviewQA)
+		prev := "qa-old"
+		task.ReviewerID = &prev
+		f := newTaskServiceFixture(task)
+		f.addAgents(reviewAgent("worker", domain.PositionBackendDev),
+			reviewAgent("qa-old", domain.PositionQAEngineer), reviewAgent("qa-new", domain.PositionQAEngineer))
+
+		got, err := f.svc.Submit(context.Background(), "t1", "worker", nil, "改好了")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *got.ReviewerID != "qa-old" {
+			t.Errorf("reviewer = %s, want previous round's qa-old", *got.ReviewerID)
+		}
+	})
+
+	for name, prev := range map[string]string{"left the company": "qa-gone", "now the assignee": "worker"} {
+		t.Run(name, func(t *testing.T) {
+			task := reviewTask(domain.TaskReviewQA)
+			task.ReviewerID = &prev
+			f := newTaskServiceFixture(task)
+			f.addAgents(reviewAgent("worker", domain.PositionBackendDev), reviewAgent("qa-new", domain.PositionQAEngineer))
+
+			got, err := f.svc.Submit(context.Background(), "t1", "worker", nil, "改好了")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got.ReviewerID != "qa-new" {
+				t.Errorf("reviewer = %s, want re-resolved qa-new", *got.ReviewerID)
+			}
+		})
+	}
+}
+
+func inReviewTask() *domain.Task {
+	t := reviewTask(domain.TaskReviewQA)
+	t.Status = domain.TaskStatusInReview
+	reviewer, result := "qa", "完成"
+	t.ReviewerID, t.Result = &reviewer, &result
+	return t
+}
+
+func TestTaskApprove(t *testing.T) {
+	f := newTaskServiceFixture(inReviewTask())
+	qa := reviewAgent("qa", domain.PositionQAEngineer)
+
+	got, err := f.svc.Approve(context.Background(), "t1", qa, " 不错 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != domain.TaskStatusDone {
+		t.Errorf("status = %s, want done", got.Status)
+	}
+	if len(f.collab.reviews) != 1 || f.collab.reviews[0].Outcome != domain.TaskReviewApproved || f.collab.reviews[0].Comment != "不错" {
+		t.Errorf("reviews = %+v", f.collab.reviews)
+	}
+	if len(f.collab.comments) != 1 || f.collab.comments[0].Content != "✅ 验收通过：不错" {
+		t.Errorf("comments = %+v", f.collab.comments)
+	}
+}
+
+func TestTaskApprove_Permissions(t *testing.T) {
+	tests := []struct {
+		name     string
+		reviewer *domain.Agent
+		wantErr  string
+	}{
+		{"other agent", reviewAgent("someone", domain.PositionBackendDev), "not the reviewer"},
+		{"assignee", reviewAgent("worker", domain.PositionBackendDev), "own task"},
+		{"other company", &domain.Agent{ID: "qa", CompanyID: "c2"}, "not found"},
+		{"task manager", &domain.Agent{ID: "lead", CompanyID: "c1", Permissions: []string{"task:manage"}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTaskServiceFixture(inReviewTask())
+			_, err := f.svc.Approve(context.Background(), "t1", tt.reviewer, "")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTaskRequestChanges(t *testing.T) {
+	f := newTaskServiceFixture(inReviewTask())
+	qa := reviewAgent("qa", domain.PositionQAEngineer)
+
+	if _, err := f.svc.RequestChanges(context.Background(), "t1", qa, "  "); err == nil {
+		t.Fatal("empty comment should be rejected")
+	}
+	got, err := f.svc.RequestChanges(context.Background(), "t1", qa, "缺少测试")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != domain.TaskStatusInProgress {
+		t.Errorf("status = %s, want in_progress", got.Status)
+	}
+	if len(f.collab.reviews) != 1 || f.collab.reviews[0].Outcome != domain.TaskReviewChangesRequested {
+		t.Errorf("reviews = %+v", f.collab.reviews)
+	}
+	if len(f.messages.created) != 1 || *f.messages.created[0].ReceiverID != "worker" {
+		t.Errorf("assignee was not notified: %+v", f.messages.created)
+	}
+}
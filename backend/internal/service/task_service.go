@@ -4,9 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
-	"path"
-	"path/filepath"
+	"log"
 	"strings"
 	"time"
 
@@ -15,11 +13,7 @@ import (
 	"github.com/linkclaw/backend/internal/domain"
 	"github.com/linkclaw/backend/internal/event"
 	"github.com/linkclaw/backend/internal/repository"
-)
-
-const (
-	taskUploadDirBase  = "/uploads/tasks"
-	taskUploadPathBase = "/uploads/tasks"
+	"github.com/linkclaw/backend/internal/storage"
 )
 
 type TaskService struct {
@@ -27,10 +21,14 @@ type TaskService struct {
 	collabRepo  repository.TaskCollabRepo
 	messageRepo repository.MessageRepo
 	companyRepo repository.CompanyRepo
+	agentRepo   repository.AgentRepo
+	tx          repository.Transactor // nil 时不开启事务
+	outbox      *OutboxRelay          // nil 时提交后直接发布事件
+	files       storage.Storage       // 附件存储
 }
 
-func NewTaskService(taskRepo repository.TaskRepo, collabRepo repository.TaskCollabRepo, messageRepo repository.MessageRepo, companyRepo repository.CompanyRepo) *TaskService {
-	return &TaskService{taskRepo: taskRepo, collabRepo: collabRepo, messageRepo: messageRepo, companyRepo: companyRepo}
+func NewTaskService(taskRepo repository.TaskRepo, collabRepo repository.TaskCollabRepo, messageRepo repository.MessageRepo, companyRepo repository.CompanyRepo, agentRepo repository.AgentRepo, tx repository.Transactor, outbox *OutboxRelay, files storage.Storage) *TaskService {
+	return &TaskService{taskRepo: taskRepo, collabRepo: collabRepo, messageRepo: messageRepo, companyRepo: companyRepo, agentRepo: agentRepo, tx: tx, outbox: outbox, files: files}
 }
 
 type CreateTaskInput struct {
@@ -43,6 +41,10 @@ type CreateTaskInput struct {
 	CreatedBy   *string
 	Tags        domain.StringList
 	Attachments []TaskUploadFile
+	AutoAssign  bool // 未指定负责人时按职位/部门/负载/历史成功率自动分配
+	DueAt       *time.Time
+
+	ReviewPolicy domain.TaskReviewPolicy // 提交后由谁验收；为空表示提交即完成
 }
 
 type TaskUploadFile struct {
@@ -53,9 +55,33 @@ type TaskUploadFile struct {
 }
 
 func (s *TaskService) Create(ctx context.Context, in CreateTaskInput) (*domain.Task, error) {
+	t, err := s.insertTask(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	s.afterCreate(ctx, t, in.AutoAssign)
+	return t, nil
+}
+
+// insertTask 校验并写入任务及其附件，不发布事件；可在调用方的事务内执行
+func (s *TaskService) insertTask(ctx context.Context, in CreateTaskInput) (*domain.Task, error) {
 	if in.Priority == "" {
 		in.Priority = domain.TaskPriorityMedium
 	}
+	if !in.ReviewPolicy.Valid() {
+		return nil, fmt.Errorf("invalid review policy: %s", in.ReviewPolicy)
+	}
+	if in.DueAt != nil && !in.DueAt.After(time.Now()) {
+		return nil, fmt.Errorf("due_at must be in the future")
+	}
+	if len(in.Attachments) > taskAttachmentMaxPerTask {
+		return nil, fmt.Errorf("a task can have at most %d attachments", taskAttachmentMaxPerTask)
+	}
+	for i := range in.Attachments {
+		if err := validateTaskUpload(&in.Attachments[i]); err != nil {
+			return nil, err
+		}
+	}
 	status := domain.TaskStatusPending
 	if in.AssigneeID != nil && *in.AssigneeID != "" {
 		status = domain.TaskStatusAssigned
@@ -71,86 +97,50 @@ func (s *TaskService) Create(ctx context.Context, in CreateTaskInput) (*domain.T
 		AssigneeID:  in.AssigneeID,
 		CreatedBy:   in.CreatedBy,
 		Tags:        in.Tags,
-	}
-	if err := s.taskRepo.Create(ctx, t); err != nil {
-		return nil, err
-	}
-	if len(in.Attachments) > 0 {
-		attachments, err := s.storeAttachments(ctx, t, in.Attachments)
-		if err != nil {
-			return nil, err
-		}
-		t.Attachments = attachments
-	}
-	event.Global.Publish(event.NewEvent(event.TaskCreated, event.TaskCreatedPayload{
-		TaskID: t.ID, CompanyID: t.CompanyID, Title: t.Title, AssigneeID: t.AssigneeID,
-	}))
-	return t, nil
-}
+		DueAt:       in.DueAt,
 
-func (s *TaskService) storeAttachments(ctx context.Context, task *domain.Task, files []TaskUploadFile) ([]*domain.TaskAttachment, error) {
-	dirPath := filepath.Join(taskUploadDirBase, task.ID)
-	if err := os.MkdirAll(dirPath, 0o755); err != nil {
-		return nil, fmt.Errorf("create task upload dir: %w", err)
+		ReviewPolicy: in.ReviewPolicy,
 	}
-
-	now := time.Now().UTC()
-	attachments := make([]*domain.TaskAttachment, 0, len(files))
-	writtenPaths := make([]string, 0, len(files))
-
-	for _, file := range files {
-		ext := taskAttachmentExtension(file.OriginalFilename)
-		filename := uuid.NewString() + ext
-
-		absPath := filepath.Join(dirPath, filename)
-		if err := os.WriteFile(absPath, file.Content, 0o644); err != nil {
-			cleanupTaskFiles(writtenPaths)
-			return nil, fmt.Errorf("save task attachment: %w", err)
+	// 任务与附件记录同一事务写入；事务回滚时删除已写入存储的文件
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		if err := s.taskRepo.Create(ctx, t); err != nil {
+			return err
 		}
-		writtenPaths = append(writtenPaths, absPath)
-
-		size := file.Size
-		if size <= 0 {
-			size = int64(len(file.Content))
+		if len(in.Attachments) == 0 {
+			return nil
 		}
-		mimeType := strings.TrimSpace(file.MimeType)
-		if mimeType == "" {
-			mimeType = "application/octet-stream"
+		attachments, err := s.storeAttachments(ctx, t, t.CreatedBy, in.Attachments)
+		if err != nil {
+			return err
 		}
-
-		attachments = append(attachments, &domain.TaskAttachment{
-			ID:               uuid.NewString(),
-			TaskID:           task.ID,
-			CompanyID:        task.CompanyID,
-			Filename:         filename,
-			OriginalFilename: file.OriginalFilename,
-			FileSize:         size,
-			MimeType:         mimeType,
-			StoragePath:      path.Join(taskUploadPathBase, task.ID, filename),
-			UploadedBy:       task.CreatedBy,
-			CreatedAt:        now,
-		})
-	}
-
-	if err := s.taskRepo.CreateAttachments(ctx, attachments); err != nil {
-		cleanupTaskFiles(writtenPaths)
+		t.Attachments = attachments
+		return nil
+	})
+	if err != nil {
+		s.removeFiles(attachmentKeys(t.Attachments))
 		return nil, err
 	}
-	return attachments, nil
+	return t, nil
 }
 
-func cleanupTaskFiles(paths []string) {
-	for _, p := range paths {
-		_ = os.Remove(p)
+// withinTx 在事务中执行 fn；未配置事务时直接执行
+func (s *TaskService) withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.tx != nil {
+		return s.tx.WithinTx(ctx, fn)
 	}
+	return fn(ctx)
 }
 
-func taskAttachmentExtension(name string) string {
-	lower := strings.ToLower(strings.TrimSpace(name))
-	if strings.HasSuffix(lower, ".tar.gz") {
-		return ".tar.gz"
+// afterCreate 任务写入（提交）后发布 TaskCreated，并按需自动分配
+func (s *TaskService) afterCreate(ctx context.Context, t *domain.Task, autoAssign bool) {
+	event.Global.Publish(event.NewEvent(event.TaskCreated, event.TaskCreatedPayload{
+		TaskID: t.ID, CompanyID: t.CompanyID, Title: t.Title, AssigneeID: t.AssigneeID,
+	}))
+	if autoAssign && t.AssigneeID == nil {
+		if _, err := s.AutoAssign(ctx, t, nil); err != nil {
+			log.Printf("[task] auto assign %s: %v", t.ID, err)
+		}
 	}
-	return strings.ToLower(filepath.Ext(lower))
 }
 
 func (s *TaskService) GetByID(ctx context.Context, id string) (*domain.Task, error) {
@@ -161,6 +151,11 @@ func (s *TaskService) List(ctx context.Context, q repository.TaskQuery) ([]*doma
 	return s.taskRepo.List(ctx, q)
 }
 
+// Search 带全文检索、扩展过滤、排序与游标分页的任务查询
+func (s *TaskService) Search(ctx context.Context, q repository.TaskQuery) (*repository.TaskPage, error) {
+	return s.taskRepo.Search(ctx, q)
+}
+
 func (s *TaskService) UpdateTags(ctx context.Context, taskID string, tags domain.StringList) error {
 	if tags == nil {
 		tags = domain.StringList{}
@@ -168,29 +163,59 @@ func (s *TaskService) UpdateTags(ctx context.Context, taskID string, tags domain
 	return s.taskRepo.UpdateTags(ctx, taskID, tags)
 }
 
-func (s *TaskService) AddComment(ctx context.Context, taskID, agentID, content string) (*domain.TaskComment, error) {
-	content = strings.TrimSpace(content)
-	if content == "" {
-		return nil, fmt.Errorf("comment content is required")
-	}
+// SetDueAt 设置或清除（dueAt 为 nil）截止时间，重置截止提醒与逾期标记；仅创建者、负责人或 task:manage 可操作
+func (s *TaskService) SetDueAt(ctx context.Context, taskID string, actor *domain.Agent, dueAt *time.Time) (*domain.Task, error) {
 	t, err := s.taskRepo.GetByID(ctx, taskID)
-	if err != nil {
-		return nil, err
-	}
-	if t == nil {
+	if err != nil || t == nil || t.CompanyID != actor.CompanyID {
 		return nil, fmt.Errorf("task not found")
 	}
-	c := &domain.TaskComment{
-		ID:        uuid.New().String(),
-		TaskID:    taskID,
-		CompanyID: t.CompanyID,
-		AgentID:   agentID,
-		Content:   content,
+	switch t.Status {
+	case domain.TaskStatusDone, domain.TaskStatusFailed, domain.TaskStatusCancelled:
+		return nil, fmt.Errorf("cannot change due date of task in status %s", t.Status)
+	}
+	isCreator := t.CreatedBy != nil && *t.CreatedBy == actor.ID
+	isAssignee := t.AssigneeID != nil && *t.AssigneeID == actor.ID
+	if !actor.HasPermission(domain.PermTaskManage) && !isCreator && !isAssignee {
+		return nil, fmt.Errorf("permission denied")
+	}
+	if dueAt != nil && !dueAt.After(time.Now()) {
+		return nil, fmt.Errorf("due_at must be in the future")
+	}
+
+	content := "🗓 清除了截止时间"
+	if dueAt != nil {
+		content = "🗓 截止时间设为 " + dueAt.Format("2006-01-02 15:04")
 	}
-	if err := s.collabRepo.AddComment(ctx, c); err != nil {
+	updated := event.NewEvent(event.TaskUpdated, event.TaskUpdatedPayload{
+		TaskID: t.ID, CompanyID: t.CompanyID, Status: string(t.Status), Title: t.Title, AssigneeID: t.AssigneeID,
+	})
+	err = s.commitWithEvents(ctx, func(ctx context.Context) error {
+		if err := s.taskRepo.UpdateDueAt(ctx, t.ID, t.Version, dueAt); err != nil {
+			return err
+		}
+		if err := s.collabRepo.AddEvents(ctx, []*domain.TaskEvent{
+			newTaskEvent(t, actor.ID, "due_at", formatDue(t.DueAt), formatDue(dueAt)),
+		}); err != nil {
+			return err
+		}
+		return s.collabRepo.AddComment(ctx, &domain.TaskComment{
+			ID:        uuid.New().String(),
+			TaskID:    t.ID,
+			CompanyID: t.CompanyID,
+			AgentID:   actor.ID,
+			Content:   content,
+		})
+	}, updated)
+	if err != nil {
 		return nil, err
 	}
-	return c, nil
+	t.DueAt = dueAt
+	t.Version++
+	t.DueWarnedAt, t.OverdueAt = nil, nil
+	if t.AssigneeID != nil && *t.AssigneeID != actor.ID {
+		s.sendSystemDM(ctx, t.CompanyID, *t.AssigneeID, fmt.Sprintf("任务「%s」：%s（%s）。任务 ID：%s", t.Title, content, actor.Name, t.ID))
+	}
+	return t, nil
 }
 
 func (s *TaskService) DeleteComment(ctx context.Context, commentID, agentID, companyID string) error {
@@ -218,6 +243,14 @@ func (s *TaskService) AddDependency(ctx context.Context, taskID, dependsOnID str
 	if t.CompanyID != target.CompanyID {
 		return nil, fmt.Errorf("cross-company dependency not allowed")
 	}
+	// 新增 task → dependsOn 的边时，若 dependsOn 已（间接）依赖 task 则成环
+	cyclic, err := s.collabRepo.DependencyReachable(ctx, dependsOnID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if cyclic {
+		return nil, fmt.Errorf("dependency would create a cycle")
+	}
 	d := &domain.TaskDependency{
 		ID:          uuid.New().String(),
 		TaskID:      taskID,
@@ -270,64 +303,161 @@ func (s *TaskService) GetTaskDetail(ctx context.Context, id string) (*domain.Tas
 	if err != nil {
 		return nil, err
 	}
+	reviews, err := s.collabRepo.ListReviews(ctx, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	activity, err := s.collabRepo.ListEvents(ctx, t.ID)
+	if err != nil {
+		return nil, err
+	}
 	t.Comments = comments
 	t.Dependencies = deps
 	t.Watchers = watchers
+	t.Reviews = reviews
+	t.Activity = activity
 	return t, nil
 }
 
-// Accept 将任务从 assigned 变为 in_progress，并广播 task_update 消息
-func (s *TaskService) Accept(ctx context.Context, taskID, agentID string) (*domain.Task, error) {
+// loadForTransition 读取待变更状态的任务；version 非 nil 时须与当前版本一致。
+// 之后的条件更新仍以该版本为准，读取后被并发修改同样返回 repository.ErrTaskConflict
+func (s *TaskService) loadForTransition(ctx context.Context, taskID string, version *int) (*domain.Task, error) {
 	t, err := s.taskRepo.GetByID(ctx, taskID)
 	if err != nil || t == nil {
 		return nil, fmt.Errorf("task not found")
 	}
+	if version != nil && *version != t.Version {
+		return nil, repository.ErrTaskConflict
+	}
+	return t, nil
+}
+
+// Accept 将任务从 assigned 变为 in_progress，并广播 task_update 消息。
+// 存在未完成的前置依赖时拒绝，force=true 时强制开始并在评论中留痕。
+// version 为调用方预期的任务版本，nil 表示不校验。
+func (s *TaskService) Accept(ctx context.Context, taskID, agentID string, version *int, force bool) (*domain.Task, error) {
+	t, err := s.loadForTransition(ctx, taskID, version)
+	if err != nil {
+		return nil, err
+	}
 	if t.AssigneeID == nil || *t.AssigneeID != agentID {
 		return nil, fmt.Errorf("task not assigned to you")
 	}
 	if !t.Status.CanTransitionTo(domain.TaskStatusInProgress) {
 		return nil, fmt.Errorf("cannot accept task in status %s", t.Status)
 	}
-	if err = s.taskRepo.UpdateStatus(ctx, taskID, domain.TaskStatusInProgress, nil, nil); err != nil {
+	blockers, err := s.collabRepo.ListOpenBlockers(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if len(blockers) > 0 && !force {
+		return nil, fmt.Errorf("task is blocked by unfinished dependencies: %s", describeBlockers(blockers))
+	}
+	err = s.transition(ctx, t, agentID, domain.TaskStatusInProgress, nil, nil, func(ctx context.Context) error {
+		if len(blockers) == 0 {
+			return nil
+		}
+		return s.collabRepo.AddComment(ctx, &domain.TaskComment{
+			ID:        uuid.New().String(),
+			TaskID:    taskID,
+			CompanyID: t.CompanyID,
+			AgentID:   agentID,
+			Content:   "在前置依赖未完成时强制开始：" + describeBlockers(blockers),
+		})
+	})
+	if err != nil {
 		return nil, err
 	}
-	t.Status = domain.TaskStatusInProgress
-	s.broadcastTaskUpdate(ctx, t)
-	event.Global.Publish(event.NewEvent(event.TaskUpdated, event.TaskUpdatedPayload{
-		TaskID: t.ID, CompanyID: t.CompanyID, Status: string(t.Status), Title: t.Title, AssigneeID: t.AssigneeID,
-	}))
 	return t, nil
 }
 
-// Submit 将任务标记为完成
-func (s *TaskService) Submit(ctx context.Context, taskID, agentID, result string) (*domain.Task, error) {
-	t, err := s.taskRepo.GetByID(ctx, taskID)
-	if err != nil || t == nil {
-		return nil, fmt.Errorf("task not found")
+// Submit 提交任务结果：配置了验收策略时进入 in_review 等待验收，否则直接完成
+func (s *TaskService) Submit(ctx context.Context, taskID, agentID string, version *int, result string) (*domain.Task, error) {
+	t, err := s.loadForTransition(ctx, taskID, version)
+	if err != nil {
+		return nil, err
 	}
 	if t.AssigneeID == nil || *t.AssigneeID != agentID {
 		return nil, fmt.Errorf("task not assigned to you")
 	}
-	if !t.Status.CanTransitionTo(domain.TaskStatusDone) {
+	if t.Status != domain.TaskStatusInProgress {
 		return nil, fmt.Errorf("cannot submit task in status %s", t.Status)
 	}
-	if err = s.taskRepo.UpdateStatus(ctx, taskID, domain.TaskStatusDone, &result, nil); err != nil {
+	if t.ReviewPolicy != domain.TaskReviewNone {
+		reviewerID, err := s.resolveReviewer(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		if err = s.submitForReview(ctx, t, reviewerID, result); err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+	if err = s.transition(ctx, t, agentID, domain.TaskStatusDone, &result, nil, nil); err != nil {
 		return nil, err
 	}
-	t.Status = domain.TaskStatusDone
-	t.Result = &result
-	s.broadcastTaskUpdate(ctx, t)
-	event.Global.Publish(event.NewEvent(event.TaskUpdated, event.TaskUpdatedPayload{
-		TaskID: t.ID, CompanyID: t.CompanyID, Status: string(t.Status), Title: t.Title, AssigneeID: t.AssigneeID,
-	}))
+	s.notifyUnblocked(ctx, t)
 	return t, nil
 }
 
+// notifyUnblocked 任务完成后，通知因此解除全部阻塞的下游任务负责人
+func (s *TaskService) notifyUnblocked(ctx context.Context, done *domain.Task) {
+	dependents, err := s.collabRepo.ListDependents(ctx, done.ID)
+	if err != nil {
+		log.Printf("[task] list dependents of %s: %v", done.ID, err)
+		return
+	}
+	for _, d := range dependents {
+		if d.AssigneeID == nil || (d.Status != domain.TaskStatusPending && d.Status != domain.TaskStatusAssigned) {
+			continue
+		}
+		blockers, err := s.collabRepo.ListOpenBlockers(ctx, d.ID)
+		if err != nil || len(blockers) > 0 {
+			continue
+		}
+		content := fmt.Sprintf("🔓 任务「%s」的前置依赖已全部完成（最后完成：「%s」），可以开始了。任务 ID：%s", d.Title, done.Title, d.ID)
+		s.sendSystemDM(ctx, d.CompanyID, *d.AssigneeID, content)
+	}
+}
+
+// sendSystemDM 向 Agent 发送系统私信
+func (s *TaskService) sendSystemDM(ctx context.Context, companyID, agentID, content string) {
+	receiverID := agentID
+	msg := &domain.Message{
+		ID:         uuid.New().String(),
+		CompanyID:  companyID,
+		ReceiverID: &receiverID,
+		Content:    content,
+		MsgType:    domain.MsgTypeSystem,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.messageRepo.Create(ctx, msg); err != nil {
+		log.Printf("[task] system dm to %s: %v", agentID, err)
+		return
+	}
+	event.Global.Publish(event.NewEvent(event.MessageNew, event.MessageNewPayload{
+		MessageID:  msg.ID,
+		CompanyID:  msg.CompanyID,
+		ReceiverID: msg.ReceiverID,
+		MsgType:    string(msg.MsgType),
+		Content:    msg.Content,
+		CreatedAt:  msg.CreatedAt.Format(time.RFC3339),
+	}))
+}
+
+func describeBlockers(blockers []*domain.Task) string {
+	parts := make([]string, 0, len(blockers))
+	for _, b := range blockers {
+		parts = append(parts, fmt.Sprintf("「%s」(%s, %s)", b.Title, b.ID, b.Status))
+	}
+	return strings.Join(parts, "、")
+}
+
 // Fail 将任务标记为失败
-func (s *TaskService) Fail(ctx context.Context, taskID, agentID, reason string) (*domain.Task, error) {
-	t, err := s.taskRepo.GetByID(ctx, taskID)
-	if err != nil || t == nil {
-		return nil, fmt.Errorf("task not found")
+func (s *TaskService) Fail(ctx context.Context, taskID, agentID string, version *int, reason string) (*domain.Task, error) {
+	t, err := s.loadForTransition(ctx, taskID, version)
+	if err != nil {
+		return nil, err
 	}
 	if t.AssigneeID == nil || *t.AssigneeID != agentID {
 		return nil, fmt.Errorf("task not assigned to you")
@@ -335,27 +465,130 @@ func (s *TaskService) Fail(ctx context.Context, taskID, agentID, reason string)
 	if !t.Status.CanTransitionTo(domain.TaskStatusFailed) {
 		return nil, fmt.Errorf("cannot fail task in status %s", t.Status)
 	}
-	if err = s.taskRepo.UpdateStatus(ctx, taskID, domain.TaskStatusFailed, nil, &reason); err != nil {
+	if err = s.transition(ctx, t, agentID, domain.TaskStatusFailed, nil, &reason, nil); err != nil {
 		return nil, err
 	}
-	t.Status = domain.TaskStatusFailed
-	t.FailReason = &reason
-	s.broadcastTaskUpdate(ctx, t)
-	event.Global.Publish(event.NewEvent(event.TaskUpdated, event.TaskUpdatedPayload{
-		TaskID: t.ID, CompanyID: t.CompanyID, Status: string(t.Status), Title: t.Title, AssigneeID: t.AssigneeID,
-	}))
 	return t, nil
 }
 
+// Delete 删除任务及其全部子任务，提交后清理存储中的附件文件
 func (s *TaskService) Delete(ctx context.Context, id, companyID string) error {
-	return s.taskRepo.Delete(ctx, id, companyID)
+	t, err := s.taskRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if t == nil || t.CompanyID != companyID {
+		return nil
+	}
+	// 子任务随父任务一并删除；锁住层级，避免删除期间有任务被挂到这棵子树下
+	var keys []string
+	if err := s.commitWithEvents(ctx, func(ctx context.Context) error {
+		if err := s.taskRepo.LockHierarchy(ctx, companyID); err != nil {
+			return err
+		}
+		descendants, err := s.taskRepo.ListDescendants(ctx, id)
+		if err != nil {
+			return err
+		}
+		ids := []string{id}
+		for _, d := range descendants {
+			ids = append(ids, d.ID)
+		}
+		keys, err = s.taskRepo.Delete(ctx, companyID, ids)
+		return err
+	}); err != nil {
+		return err
+	}
+	s.removeFiles(keys)
+	return nil
+}
+
+// transition 以乐观锁将任务从当前状态迁移到 to：状态变更、extra、task_update 消息与 TaskUpdated 事件
+// 在同一事务中写入，事件仅在提交后发布。任务已被并发修改时返回 repository.ErrTaskConflict。
+func (s *TaskService) transition(ctx context.Context, t *domain.Task, actorID string, to domain.TaskStatus, result, failReason *string, extra func(ctx context.Context) error) error {
+	return s.transitionWith(ctx, t, actorID, to, result, failReason, nil, extra)
+}
+
+// transitionWith 同 transition，reviewerID 非 nil 时在同一条版本化更新中写入验收人
+func (s *TaskService) transitionWith(ctx context.Context, t *domain.Task, actorID string, to domain.TaskStatus, result, failReason, reviewerID *string, extra func(ctx context.Context) error) error {
+	next := *t
+	next.Status = to
+	next.Version = t.Version + 1
+	if result != nil {
+		next.Result = result
+	}
+	if failReason != nil {
+		next.FailReason = failReason
+	}
+	if reviewerID != nil {
+		next.ReviewerID = reviewerID
+	}
+	updated := event.NewEvent(event.TaskUpdated, event.TaskUpdatedPayload{
+		TaskID: next.ID, CompanyID: next.CompanyID, Status: string(next.Status), Title: next.Title, AssigneeID: next.AssigneeID,
+	})
+	err := s.commitWithEvents(ctx, func(ctx context.Context) error {
+		if err := s.taskRepo.TransitionStatus(ctx, t.ID, t.Status, t.Version, to, result, failReason, reviewerID); err != nil {
+			return err
+		}
+		if err := s.collabRepo.AddEvents(ctx, []*domain.TaskEvent{
+			newTaskEvent(t, actorID, "status", string(t.Status), string(to)),
+		}); err != nil {
+			return err
+		}
+		if extra != nil {
+			if err := extra(ctx); err != nil {
+				return err
+			}
+		}
+		return s.broadcastTaskUpdate(ctx, &next)
+	}, updated)
+	if err != nil {
+		return err
+	}
+	*t = next
+	return nil
+}
+
+// commitWithEvents 在事务中执行 fn 并将 events 写入 outbox，提交成功后才发布；回滚的变更不会产生事件
+func (s *TaskService) commitWithEvents(ctx context.Context, fn func(ctx context.Context) error, events ...event.Event) error {
+	run := func(ctx context.Context) error {
+		if err := fn(ctx); err != nil {
+			return err
+		}
+		if s.outbox == nil {
+			return nil
+		}
+		for _, e := range events {
+			if err := s.outbox.Enqueue(ctx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var err error
+	if s.tx != nil {
+		err = s.tx.WithinTx(ctx, run)
+	} else {
+		err = run(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	if s.outbox != nil {
+		s.outbox.Dispatch(context.WithoutCancel(ctx))
+	} else {
+		for _, e := range events {
+			event.Global.Publish(e)
+		}
+	}
+	return nil
 }
 
 // broadcastTaskUpdate 在 #general 频道发布 task_update 消息
-func (s *TaskService) broadcastTaskUpdate(ctx context.Context, t *domain.Task) {
+func (s *TaskService) broadcastTaskUpdate(ctx context.Context, t *domain.Task) error {
 	ch, err := s.companyRepo.GetChannelByName(ctx, t.CompanyID, "general")
 	if err != nil || ch == nil {
-		return
+		return nil
 	}
 	meta := domain.TaskMeta{
 		TaskID:     t.ID,
@@ -377,5 +610,5 @@ func (s *TaskService) broadcastTaskUpdate(ctx context.Context, t *domain.Task) {
 		MsgType:   domain.MsgTypeTaskUpdate,
 		TaskMeta:  metaJSON,
 	}
-	s.messageRepo.Create(ctx, msg) //nolint:errcheck
+	return s.messageRepo.Create(ctx, msg)
 }
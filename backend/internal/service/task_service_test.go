@@ -0,0 +1,420 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/event"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+// memTaskRepo 内存任务表，条件更新按 status/version 判断；其余方法调用会 panic
+type memTaskRepo struct {
+	repository.TaskRepo
+	tasks map[string]*domain.Task
+	// afterGet 在 GetByID 返回前调用，用于模拟读取后的并发修改
+	afterGet func(id string)
+	// onLock 在 LockHierarchy 获得锁时调用，用于模拟等锁期间提交的并发修改
+	onLock func()
+}
+
+func newMemTaskRepo(tasks ...*domain.Task) *memTaskRepo {
+	r := &memTaskRepo{tasks: make(map[string]*domain.Task)}
+	for _, t := range tasks {
+		r.tasks[t.ID] = t
+	}
+	return r
+}
+
+func (r *memTaskRepo) GetByID(_ context.Context, id string) (*domain.Task, error) {
+	t, ok := r.tasks[id]
+	if !ok {
+		return nil, nil
+	}
+	cp := *t
+	if r.afterGet != nil {
+		r.afterGet(id)
+	}
+	return &cp, nil
+}
+
+func (r *memTaskRepo) Create(_ context.Context, t *domain.Task) error {
+	cp := *t
+	r.tasks[t.ID] = &cp
+	return nil
+}
+
+func (r *memTaskRepo) CreateAttachments(_ context.Context, list []*domain.TaskAttachment) error {
+	for _, a := range list {
+		t := r.tasks[a.TaskID]
+		t.Attachments = append(t.Attachments, a)
+	}
+	return nil
+}
+
+func (r *memTaskRepo) TransitionStatus(_ context.Context, id string, from domain.TaskStatus, version int, to domain.TaskStatus, result, failReason, reviewerID *string) error {
+	t, ok := r.tasks[id]
+	if !ok || t.Status != from || t.Version != version {
+		return repository.ErrTaskConflict
+	}
+	t.Status, t.Version = to, t.Version+1
+	if result != nil {
+		t.Result = result
+	}
+	if failReason != nil {
+		t.FailReason = failReason
+	}
+	if reviewerID != nil {
+		t.ReviewerID = reviewerID
+	}
+	return nil
+}
+
+func (r *memTaskRepo) Update(_ context.Context, t *domain.Task, version int) error {
+	cur, ok := r.tasks[t.ID]
+	if !ok || cur.Version != version {
+		return repository.ErrTaskConflict
+	}
+	cp := *t
+	r.tasks[t.ID] = &cp
+	return nil
+}
+
+func (r *memTaskRepo) UpdateDueAt(_ context.Context, id string, version int, dueAt *time.Time) error {
+	t, ok := r.tasks[id]
+	if !ok || t.Version != version {
+		return repository.ErrTaskConflict
+	}
+	t.DueAt, t.Version = dueAt, t.Version+1
+	t.DueWarnedAt, t.OverdueAt = nil, nil
+	return nil
+}
+
+func (r *memTaskRepo) Delete(_ context.Context, companyID string, ids []string) ([]string, error) {
+	var paths []string
+	for _, id := range ids {
+		if t, ok := r.tasks[id]; ok && t.CompanyID == companyID {
+			paths = append(paths, attachmentKeys(t.Attachments)...)
+			delete(r.tasks, id)
+		}
+	}
+	return paths, nil
+}
+
+func (r *memTaskRepo) ListDescendants(_ context.Context, rootID string) ([]*domain.Task, error) {
+	var out []*domain.Task
+	frontier := []string{rootID}
+	for len(frontier) > 0 {
+		id := frontier[0]
+		frontier = frontier[1:]
+		for _, t := range r.tasks {
+			if t.ParentID != nil && *t.ParentID == id {
+				out = append(out, t)
+				frontier = append(frontier, t.ID)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (r *memTaskRepo) LockHierarchy(context.Context, string) error {
+	if r.onLock != nil {
+		r.onLock()
+	}
+	return nil
+}
+
+func (r *memTaskRepo) UpdateAssignee(_ context.Context, id string, version int, assigneeID *string, status domain.TaskStatus) error {
+	t, ok := r.tasks[id]
+	if !ok || t.Version != version {
+		return repository.ErrTaskConflict
+	}
+	t.AssigneeID, t.Status, t.Version = assigneeID, status, t.Version+1
+	return nil
+}
+
+func (r *memTaskRepo) CountOpenByAssignee(context.Context, string) (map[string]int, error) {
+	return map[string]int{}, nil
+}
+
+// memTaskCollabRepo 记录写入的状态事件、评论与依赖；依赖不参与阻塞判断
+type memTaskCollabRepo struct {
+	repository.TaskCollabRepo
+	events   []*domain.TaskEvent
+	comments []*domain.TaskComment
+	reviews  []*domain.TaskReview
+	deps     []*domain.TaskDependency
+	depErr   error // 非 nil 时 AddDependency 返回该错误
+	eventErr error // 非 nil 时 AddEvents 返回该错误
+}
+
+func (r *memTaskCollabRepo) AddDependency(_ context.Context, d *domain.TaskDependency) error {
+	if r.depErr != nil {
+		return r.depErr
+	}
+	r.deps = append(r.deps, d)
+	return nil
+}
+
+func (r *memTaskCollabRepo) DependencyReachable(context.Context, string, string) (bool, error) {
+	return false, nil
+}
+
+func (r *memTaskCollabRepo) AddEvents(_ context.Context, events []*domain.TaskEvent) error {
+	if r.eventErr != nil {
+		return r.eventErr
+	}
+	r.events = append(r.events, events...)
+	return nil
+}
+
+func (r *memTaskCollabRepo) AddComment(_ context.Context, c *domain.TaskComment) error {
+	r.comments = append(r.comments, c)
+	return nil
+}
+
+func (r *memTaskCollabRepo) AddReview(_ context.Context, rv *domain.TaskReview) error {
+	r.reviews = append(r.reviews, rv)
+	return nil
+}
+
+func (r *memTaskCollabRepo) ListOpenBlockers(context.Context, string) ([]*domain.Task, error) {
+	return nil, nil
+}
+
+func (r *memTaskCollabRepo) ListDependents(context.Context, string) ([]*domain.Task, error) {
+	return nil, nil
+}
+
+// memTaskMessageRepo 记录任务相关的系统消息
+type memTaskMessageRepo struct {
+	repository.MessageRepo
+	created []*domain.Message
+}
+
+func (r *memTaskMessageRepo) Create(_ context.Context, m *domain.Message) error {
+	r.created = append(r.created, m)
+	return nil
+}
+
+// noGeneralCompanyRepo 公司没有 #general 频道，跳过 task_update 广播
+type noGeneralCompanyRepo struct {
+	repository.CompanyRepo
+}
+
+func (noGeneralCompanyRepo) GetChannelByName(context.Context, string, string) (*domain.Channel, error) {
+	return nil, nil
+}
+
+// memTaskAgentRepo 内存 agent 名单
+type memTaskAgentRepo struct {
+	repository.AgentRepo
+	agents map[string]*domain.Agent
+}
+
+func (r *memTaskAgentRepo) GetByID(_ context.Context, id string) (*domain.Agent, error) {
+	return r.agents[id], nil
+}
+
+func (r *memTaskAgentRepo) GetByCompany(_ context.Context, companyID string) ([]*domain.Agent, error) {
+	var out []*domain.Agent
+	for _, a := range r.agents {
+		if a.CompanyID == companyID {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (r *memTaskAgentRepo) ListMentioned(_ context.Context, companyID, text string) ([]*domain.Agent, error) {
+	var out []*domain.Agent
+	for _, a := range r.agents {
+		if a.CompanyID == companyID && a.Name != "" && (strings.Contains(text, a.Name) || strings.Contains(text, a.ID)) {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+// memOutboxRepo 事务内写入的事件先暂存，提交后才可被 ListPending 读到
+type memOutboxRepo struct {
+	staged    []*domain.OutboxEvent
+	committed []*domain.OutboxEvent
+	published map[string]bool
+}
+
+func (r *memOutboxRepo) Enqueue(_ context.Context, e *domain.OutboxEvent) error {
+	e.ID = string(rune('a' + len(r.staged) + len(r.committed)))
+	r.staged = append(r.staged, e)
+	return nil
+}
+
+func (r *memOutboxRepo) ListPending(context.Context, int) ([]*domain.OutboxEvent, error) {
+	var out []*domain.OutboxEvent
+	for _, e := range r.committed {
+		if !r.published[e.ID] {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (r *memOutboxRepo) MarkPublished(_ context.Context, id string) error {
+	if r.published == nil {
+		r.published = make(map[string]bool)
+	}
+	r.published[id] = true
+	return nil
+}
+
+func (r *memOutboxRepo) DeletePublishedBefore(context.Context, int) error { return nil }
+
+// stagingTx fn 成功时提交暂存的 outbox 事件，失败时丢弃
+type stagingTx struct {
+	outbox *memOutboxRepo
+}
+
+func (tx *stagingTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx.outbox.staged = nil
+	if err := fn(ctx); err != nil {
+		tx.outbox.staged = nil
+		return err
+	}
+	tx.outbox.committed = append(tx.outbox.committed, tx.outbox.staged...)
+	tx.outbox.staged = nil
+	return nil
+}
+
+type taskServiceFixture struct {
+	svc      *TaskService
+	tasks    *memTaskRepo
+	collab   *memTaskCollabRepo
+	messages *memTaskMessageRepo
+	agents   *memTaskAgentRepo
+	outbox   *memOutboxRepo
+}
+
+func newTaskServiceFixture(tasks ...*domain.Task) *taskServiceFixture {
+	f := &taskServiceFixture{
+		tasks:    newMemTaskRepo(tasks...),
+		collab:   &memTaskCollabRepo{},
+		messages: &memTaskMessageRepo{},
+		agents:   &memTaskAgentRepo{agents: make(map[string]*domain.Agent)},
+		outbox:   &memOutboxRepo{},
+	}
+	f.svc = NewTaskService(f.tasks, f.collab, f.messages, noGeneralCompanyRepo{}, f.agents,
+		&stagingTx{outbox: f.outbox}, NewOutboxRelay(f.outbox, event.Global), nil)
+	return f
+}
+
+func (f *taskServiceFixture) addAgents(agents ...*domain.Agent) {
+	for _, a := range agents {
+		f.agents.agents[a.ID] = a
+	}
+}
+
+// recordTaskUpdated 收集测试期间发布的 TaskUpdated 事件
+func recordTaskUpdated(t *testing.T) *[]event.Event {
+	t.Helper()
+	var got []event.Event
+	unsubscribe := event.Global.Subscribe(event.TaskUpdated, func(e event.Event) { got = append(got, e) })
+	t.Cleanup(unsubscribe)
+	return &got
+}
+
+func inProgressTask() *domain.Task {
+	assignee := "worker"
+	return &domain.Task{
+		ID: "t1", CompanyID: "c1", Title: "写文档", Status: domain.TaskStatusInProgress,
+		AssigneeID: &assignee, ReviewPolicy: domain.TaskReviewNone, Version: 3,
+	}
+}
+
+func TestTaskTransition_PublishesThroughOutboxAfterCommit(t *testing.T) {
+	f := newTaskServiceFixture(inProgressTask())
+	published := recordTaskUpdated(t)
+	version := 3
+
+	got, err := f.svc.Fail(context.Background(), "t1", "worker", &version, "环境不可用")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != domain.TaskStatusFailed || got.Version != 4 {
+		t.Errorf("task = %s v%d, want failed v4", got.Status, got.Version)
+	}
+	if len(f.outbox.committed) != 1 || f.outbox.committed[0].EventType != string(event.TaskUpdated) {
+		t.Fatalf("outbox = %+v, want one task.updated", f.outbox.committed)
+	}
+	if !f.outbox.published[f.outbox.committed[0].ID] {
+		t.Error("outbox event not marked published")
+	}
+	if len(*published) != 1 {
+		t.Errorf("published %d events, want 1", len(*published))
+	}
+	if len(f.collab.events) != 1 {
+		t.Errorf("recorded %d task events, want 1", len(f.collab.events))
+	}
+}
+
+func TestTaskTransition_StaleVersionConflicts(t *testing.T) {
+	f := newTaskServiceFixture(inProgressTask())
+	published := recordTaskUpdated(t)
+	stale := 2
+
+	if _, err := f.svc.Fail(context.Background(), "t1", "worker", &stale, "x"); !errors.Is(err, repository.ErrTaskConflict) {
+		t.Fatalf("err = %v, want ErrTaskConflict", err)
+	}
+	if f.tasks.tasks["t1"].Status != domain.TaskStatusInProgress {
+		t.Error("task changed despite version conflict")
+	}
+	if len(f.outbox.committed) != 0 || len(*published) != 0 {
+		t.Error("conflicting transition produced events")
+	}
+}
+
+func TestTaskTransition_ConcurrentChangeAfterReadConflicts(t *testing.T) {
+	f := newTaskServiceFixture(inProgressTask())
+	published := recordTaskUpdated(t)
+	// 读取之后、条件更新之前任务被其他请求修改
+	f.tasks.afterGet = func(id string) { f.tasks.tasks[id].Version++ }
+	version := 3
+
+	if _, err := f.svc.Submit(context.Background(), "t1", "worker", &version, "done"); !errors.Is(err, repository.ErrTaskConflict) {
+		t.Fatalf("err = %v, want ErrTaskConflict", err)
+	}
+	if f.tasks.tasks["t1"].Status != domain.TaskStatusInProgress {
+		t.Error("task changed despite concurrent modification")
+	}
+	if len(f.outbox.committed) != 0 || len(*published) != 0 {
+		t.Error("rolled back transition produced events")
+	}
+}
+
+func TestSetDueAt_ConflictsWithConcurrentUpdate(t *testing.T) {
+	f := newTaskServiceFixture(inProgressTask())
+	f.tasks.afterGet = func(id string) { f.tasks.tasks[id].Version++ }
+	due := time.Now().Add(time.Hour)
+
+	if _, err := f.svc.SetDueAt(context.Background(), "t1", &domain.Agent{ID: "worker", CompanyID: "c1"}, &due); !errors.Is(err, repository.ErrTaskConflict) {
+		t.Fatalf("err = %v, want ErrTaskConflict", err)
+	}
+	if f.tasks.tasks["t1"].DueAt != nil || len(f.collab.events) != 0 {
+		t.Error("due date changed despite concurrent modification")
+	}
+}
+
+func TestTaskTransition_NilVersionSkipsCheck(t *testing.T) {
+	f := newTaskServiceFixture(inProgressTask())
+
+	got, err := f.svc.Submit(context.Background(), "t1", "worker", nil, "done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != domain.TaskStatusDone {
+		t.Errorf("status = %s, want done", got.Status)
+	}
+}
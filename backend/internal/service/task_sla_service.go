@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/event"
+)
+
+// TaskSLAConfig 截止提醒与 SLA 超时配置；SLA 按优先级给出在某状态的最长停留时间，未配置的优先级不检查
+type TaskSLAConfig struct {
+	Interval      time.Duration
+	DueWarning    time.Duration
+	AssignedSLA   map[domain.TaskPriority]time.Duration
+	InProgressSLA map[domain.TaskPriority]time.Duration
+	Reescalation  time.Duration // 升级后仍未处理时，每隔多久再向上升级一级；0 表示只升级一次
+}
+
+// ParseTaskSLA 解析 "urgent=30,high=120" 形式的按优先级 SLA 分钟数；0 表示该优先级不检查
+func ParseTaskSLA(spec string) (map[domain.TaskPriority]time.Duration, error) {
+	out := make(map[domain.TaskPriority]time.Duration)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid task SLA %q: want priority=minutes", part)
+		}
+		p := domain.TaskPriority(strings.TrimSpace(name))
+		switch p {
+		case domain.TaskPriorityLow, domain.TaskPriorityMedium, domain.TaskPriorityHigh, domain.TaskPriorityUrgent:
+		default:
+			return nil, fmt.Errorf("invalid task SLA %q: unknown priority %q", part, p)
+		}
+		if _, dup := out[p]; dup {
+			return nil, fmt.Errorf("invalid task SLA %q: duplicate priority %q", part, p)
+		}
+		m, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil || m < 0 {
+			return nil, fmt.Errorf("invalid task SLA %q: want a non-negative number of minutes", part)
+		}
+		out[p] = time.Duration(m) * time.Minute
+	}
+	return out, nil
+}
+
+// TaskSLAService 定时扫描任务：截止前提醒负责人、标记逾期、检测 SLA 超时并沿汇报链升级，未处理时继续向上升级
+type TaskSLAService struct {
+	tasks  *TaskService
+	orgSvc *OrganizationService
+	cfg    TaskSLAConfig
+	minSLA time.Duration
+}
+
+func NewTaskSLAService(tasks *TaskService, orgSvc *OrganizationService, cfg TaskSLAConfig) *TaskSLAService {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	s := &TaskSLAService{tasks: tasks, orgSvc: orgSvc, cfg: cfg}
+	for _, m := range []map[domain.TaskPriority]time.Duration{cfg.AssignedSLA, cfg.InProgressSLA} {
+		for _, d := range m {
+			if d > 0 && (s.minSLA == 0 || d < s.minSLA) {
+				s.minSLA = d
+			}
+		}
+	}
+	return s
+}
+
+// Run 按 Interval 周期扫描，直到 ctx 结束
+func (s *TaskSLAService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Check(ctx)
+		}
+	}
+}
+
+// Check 执行一轮扫描；各步骤互不影响，出错仅记录日志
+func (s *TaskSLAService) Check(ctx context.Context) {
+	now := time.Now()
+	if s.cfg.DueWarning > 0 {
+		if err := s.warnDueSoon(ctx, now); err != nil {
+			log.Printf("[task-sla] due warning: %v", err)
+		}
+	}
+	if err := s.markOverdue(ctx); err != nil {
+		log.Printf("[task-sla] overdue: %v", err)
+	}
+	if s.minSLA > 0 {
+		if err := s.checkSLA(ctx, now); err != nil {
+			log.Printf("[task-sla] sla: %v", err)
+		}
+	}
+	if s.cfg.Reescalation > 0 {
+		if err := s.reescalate(ctx, now); err != nil {
+			log.Printf("[task-sla] reescalation: %v", err)
+		}
+	}
+}
+
+func (s *TaskSLAService) warnDueSoon(ctx context.Context, now time.Time) error {
+	tasks, err := s.tasks.taskRepo.ListDueSoon(ctx, now.Add(s.cfg.DueWarning))
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		ok, err := s.tasks.taskRepo.MarkDueWarned(ctx, t.ID)
+		if err != nil || !ok {
+			continue
+		}
+		s.tasks.sendSystemDM(ctx, t.CompanyID, *t.AssigneeID, fmt.Sprintf(
+			"⏰ 任务「%s」将于 %s 截止（剩余约 %s），请抓紧处理。任务 ID：%s",
+			t.Title, t.DueAt.Format("2006-01-02 15:04"), t.DueAt.Sub(now).Round(time.Minute), t.ID))
+	}
+	return nil
+}
+
+func (s *TaskSLAService) markOverdue(ctx context.Context) error {
+	tasks, err := s.tasks.taskRepo.ListNewlyOverdue(ctx)
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		target, level := s.escalationTarget(ctx, t)
+		ok, err := s.tasks.taskRepo.MarkOverdue(ctx, t.ID, level)
+		if err != nil || !ok {
+			continue
+		}
+		reason := fmt.Sprintf("任务「%s」已于 %s 逾期，当前状态 %s", t.Title, t.DueAt.Format("2006-01-02 15:04"), t.Status)
+		if t.AssigneeID != nil {
+			s.tasks.sendSystemDM(ctx, t.CompanyID, *t.AssigneeID, fmt.Sprintf("⚠️ %s，已上报。任务 ID：%s", reason, t.ID))
+		}
+		s.escalate(ctx, t, "overdue", reason, target, level)
+		event.Global.Publish(event.NewEvent(event.TaskOverdue, slaPayload(t, target, level)))
+	}
+	return nil
+}
+
+func (s *TaskSLAService) checkSLA(ctx context.Context, now time.Time) error {
+	tasks, err := s.tasks.taskRepo.ListSLACandidates(ctx, now.Add(-s.minSLA))
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		limit, breached := s.slaBreached(t, now)
+		if !breached {
+			continue
+		}
+		target, level := s.escalationTarget(ctx, t)
+		ok, err := s.tasks.taskRepo.MarkSLABreached(ctx, t.ID, level)
+		if err != nil || !ok {
+			continue
+		}
+		reason := fmt.Sprintf("任务「%s」（%s）在 %s 状态已停留 %s，超过 SLA %s",
+			t.Title, t.Priority, t.Status, now.Sub(t.StatusChangedAt).Round(time.Minute), limit)
+		if t.AssigneeID != nil {
+			s.tasks.sendSystemDM(ctx, t.CompanyID, *t.AssigneeID, fmt.Sprintf("⚠️ %s，已上报。任务 ID：%s", reason, t.ID))
+		}
+		s.escalate(ctx, t, "sla_breached", reason, target, level)
+		event.Global.Publish(event.NewEvent(event.TaskSLABreached, slaPayload(t, target, level)))
+	}
+	return nil
+}
+
+// slaBreached 判断任务在当前状态的停留时间是否超过其优先级的 SLA
+func (s *TaskSLAService) slaBreached(t *domain.Task, now time.Time) (time.Duration, bool) {
+	var limits map[domain.TaskPriority]time.Duration
+	switch t.Status {
+	case domain.TaskStatusAssigned:
+		limits = s.cfg.AssignedSLA
+	case domain.TaskStatusInProgress:
+		limits = s.cfg.InProgressSLA
+	default:
+		return 0, false
+	}
+	priority := t.Priority
+	if priority == "" {
+		priority = domain.TaskPriorityMedium
+	}
+	limit, ok := limits[priority]
+	if !ok || limit <= 0 {
+		return 0, false
+	}
+	return limit, now.Sub(t.StatusChangedAt) > limit
+}
+
+// reescalate 逾期或 SLA 超时的任务在上次升级后 Reescalation 内仍未处理时，再向汇报链上一级升级；链路到顶后停止
+func (s *TaskSLAService) reescalate(ctx context.Context, now time.Time) error {
+	tasks, err := s.tasks.taskRepo.ListEscalationDue(ctx, now.Add(-s.cfg.Reescalation))
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		level := t.EscalationLevel + 1
+		target, reached := s.managerAt(ctx, t, level)
+		if !reached {
+			if err := s.tasks.taskRepo.ClearEscalation(ctx, t.ID); err != nil {
+				log.Printf("[task-sla] clear escalation for task %s: %v", t.ID, err)
+			}
+			continue
+		}
+		ok, err := s.tasks.taskRepo.MarkEscalated(ctx, t.ID, t.EscalationLevel, level)
+		if err != nil || !ok {
+			continue
+		}
+		kind, typ := "sla_breached", event.TaskSLABreached
+		reason := fmt.Sprintf("任务「%s」（%s）在 %s 状态已停留 %s，上报后仍未处理",
+			t.Title, t.Priority, t.Status, now.Sub(t.StatusChangedAt).Round(time.Minute))
+		if t.OverdueAt != nil && t.DueAt != nil {
+			kind, typ = "overdue", event.TaskOverdue
+			reason = fmt.Sprintf("任务「%s」已于 %s 逾期，上报后仍未处理", t.Title, t.DueAt.Format("2006-01-02 15:04"))
+		}
+		s.escalate(ctx, t, kind, reason, target, level)
+		event.Global.Publish(event.NewEvent(typ, slaPayload(t, target, level)))
+	}
+	return nil
+}
+
+// escalationTarget 沿汇报链上溯 escalation_level+1 级，链路到顶时取最高一级
+func (s *TaskSLAService) escalationTarget(ctx context.Context, t *domain.Task) (*string, int) {
+	level := t.EscalationLevel + 1
+	target, _ := s.managerAt(ctx, t, level)
+	return target, level
+}
+
+// managerAt 从负责人（未分配时从创建者）沿汇报链上溯 level 级；链路不足 level 级时返回最高一级，reached 为 false
+func (s *TaskSLAService) managerAt(ctx context.Context, t *domain.Task, level int) (target *string, reached bool) {
+	subjectID := escalationSubject(t)
+	if subjectID == nil {
+		return nil, false
+	}
+	seen := map[string]bool{*subjectID: true}
+	cur := *subjectID
+	steps := 0
+	for steps < level {
+		agent, err := s.tasks.agentRepo.GetByID(ctx, cur)
+		if err != nil || agent == nil {
+			break
+		}
+		next, err := s.orgSvc.ResolveManagerID(ctx, agent)
+		if err != nil || next == nil || seen[*next] {
+			break
+		}
+		seen[*next] = true
+		target, cur = next, *next
+		steps++
+	}
+	return target, steps == level
+}
+
+// escalate 向汇报链上级发起 task_escalation 审批并私信；找不到上级时私信任务创建者
+func (s *TaskSLAService) escalate(ctx context.Context, t *domain.Task, kind, reason string, target *string, level int) {
+	if target == nil {
+		if t.CreatedBy != nil && (t.AssigneeID == nil || *t.CreatedBy != *t.AssigneeID) {
+			s.tasks.sendSystemDM(ctx, t.CompanyID, *t.CreatedBy, fmt.Sprintf("🚨 %s，请跟进。任务 ID：%s", reason, t.ID))
+		}
+		return
+	}
+	payload, _ := json.Marshal(map[string]any{
+		"task_id":          t.ID,
+		"title":            t.Title,
+		"kind":             kind,
+		"status":           t.Status,
+		"priority":         t.Priority,
+		"assignee_id":      t.AssigneeID,
+		"due_at":           t.DueAt,
+		"escalation_level": level,
+	})
+	if _, err := s.orgSvc.CreateApprovalFor(ctx, t.CompanyID, *escalationSubject(t), target, domain.ApprovalTaskEscalation, payload, reason); err != nil {
+		log.Printf("[task-sla] escalation approval for task %s: %v", t.ID, err)
+	}
+	s.tasks.sendSystemDM(ctx, t.CompanyID, *target, fmt.Sprintf(
+		"🚨 任务升级（第 %d 级）：%s。请协调处理，必要时重新分配。任务 ID：%s", level, reason, t.ID))
+}
+
+func escalationSubject(t *domain.Task) *string {
+	if t.AssigneeID != nil {
+		return t.AssigneeID
+	}
+	return t.CreatedBy
+}
+
+func slaPayload(t *domain.Task, target *string, level int) event.TaskSLAPayload {
+	return event.TaskSLAPayload{
+		TaskID:          t.ID,
+		CompanyID:       t.CompanyID,
+		Title:           t.Title,
+		Status:          string(t.Status),
+		Priority:        string(t.Priority),
+		AssigneeID:      t.AssigneeID,
+		DueAt:           t.DueAt,
+		StatusSince:     t.StatusChangedAt,
+		EscalatedTo:     target,
+		EscalationLevel: level,
+	}
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+func TestTaskSLABreached(t *testing.T) {
+	now := time.Now()
+	svc := NewTaskSLAService(nil, nil, TaskSLAConfig{
+		AssignedSLA:   map[domain.TaskPriority]time.Duration{domain.TaskPriorityUrgent: 30 * time.Minute, domain.TaskPriorityMedium: 8 * time.Hour},
+		InProgressSLA: map[domain.TaskPriority]time.Duration{domain.TaskPriorityUrgent: 4 * time.Hour},
+	})
+	if svc.minSLA != 30*time.Minute {
+		t.Fatalf("minSLA = %v, want 30m", svc.minSLA)
+	}
+	tests := []struct {
+		name   string
+		status domain.TaskStatus
+		prio   domain.TaskPriority
+		since  time.Duration
+		want   bool
+	}{
+		{"urgent assigned breached", domain.TaskStatusAssigned, domain.TaskPriorityUrgent, 31 * time.Minute, true},
+		{"urgent assigned within", domain.TaskStatusAssigned, domain.TaskPriorityUrgent, 29 * time.Minute, false},
+		{"empty priority is medium", domain.TaskStatusAssigned, "", 9 * time.Hour, true},
+		{"in_progress uses its own table", domain.TaskStatusInProgress, domain.TaskPriorityUrgent, time.Hour, false},
+		{"unconfigured priority never breaches", domain.TaskStatusInProgress, domain.TaskPriorityLow, 1000 * time.Hour, false},
+		{"other status ignored", domain.TaskStatusInReview, domain.TaskPriorityUrgent, 1000 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &domain.Task{Status: tt.status, Priority: tt.prio, StatusChangedAt: now.Add(-tt.since)}
+			if _, got := svc.slaBreached(task, now); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTaskSLA(t *testing.T) {
+	got, err := ParseTaskSLA(" urgent=30, high = 120,low=0 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[domain.TaskPriority]time.Duration{
+		domain.TaskPriorityUrgent: 30 * time.Minute,
+		domain.TaskPriorityHigh:   2 * time.Hour,
+		domain.TaskPriorityLow:    0,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, err := ParseTaskSLA(""); err != nil || len(got) != 0 {
+		t.Errorf("empty spec = %v, %v", got, err)
+	}
+	for _, spec := range []string{"critical=5", "high", "high=-1", "high=1.5", "high=abc", "high=10,high=20"} {
+		if _, err := ParseTaskSLA(spec); err == nil {
+			t.Errorf("ParseTaskSLA(%q) should fail", spec)
+		}
+	}
+}
+
+// slaTaskRepo 在内存任务表上模拟逾期标记与升级相关查询
+type slaTaskRepo struct {
+	*memTaskRepo
+}
+
+func (r *slaTaskRepo) ListNewlyOverdue(context.Context) ([]*domain.Task, error) {
+	var out []*domain.Task
+	for _, t := range r.tasks {
+		if t.DueAt != nil && !t.DueAt.After(time.Now()) && t.OverdueAt == nil {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (r *slaTaskRepo) MarkOverdue(_ context.Context, id string, level int) (bool, error) {
+	t := r.tasks[id]
+	if t.OverdueAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	t.OverdueAt, t.EscalatedAt = &now, &now
+	t.EscalationLevel = max(t.EscalationLevel, level)
+	return true, nil
+}
+
+func (r *slaTaskRepo) ListEscalationDue(_ context.Context, before time.Time) ([]*domain.Task, error) {
+	var out []*domain.Task
+	for _, t := range r.tasks {
+		open := t.Status != domain.TaskStatusDone && t.Status != domain.TaskStatusFailed && t.Status != domain.TaskStatusCancelled
+		if t.EscalatedAt != nil && !t.EscalatedAt.After(before) && t.OverdueAt != nil && open {
+			cp := *t
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (r *slaTaskRepo) MarkEscalated(_ context.Context, id string, from, to int) (bool, error) {
+	t := r.tasks[id]
+	if t.EscalationLevel != from {
+		return false, nil
+	}
+	now := time.Now()
+	t.EscalationLevel, t.EscalatedAt = to, &now
+	return true, nil
+}
+
+func (r *slaTaskRepo) ClearEscalation(_ context.Context, id string) error {
+	r.tasks[id].EscalatedAt = nil
+	return nil
+}
+
+// memApprovalRepo 记录创建的审批
+type memApprovalRepo struct {
+	repository.ApprovalRepo
+	created []*domain.ApprovalRequest
+}
+
+func (r *memApprovalRepo) Create(_ context.Context, req *domain.ApprovalRequest) error {
+	r.created = append(r.created, req)
+	return nil
+}
+
+func TestTaskSLA_ReescalatesUpTheChainUntilTop(t *testing.T) {
+	due := time.Now().Add(-time.Hour)
+	assignee := "worker"
+	f := newTaskServiceFixture(&domain.Task{
+		ID: "t1", CompanyID: "c1", Title: "发版", Status: domain.TaskStatusInProgress,
+		Priority: domain.TaskPriorityHigh, AssigneeID: &assignee, DueAt: &due,
+	})
+	repo := &slaTaskRepo{memTaskRepo: f.tasks}
+	f.svc.taskRepo = repo
+	f.addAgents(
+		&domain.Agent{ID: "worker", CompanyID: "c1", ManagerID: ptr("lead")},
+		&domain.Agent{ID: "lead", CompanyID: "c1", ManagerID: ptr("director")},
+		&domain.Agent{ID: "director", CompanyID: "c1", ManagerID: ptr("ceo")},
+		&domain.Agent{ID: "ceo", CompanyID: "c1"},
+	)
+	approvals := &memApprovalRepo{}
+	svc := NewTaskSLAService(f.svc, NewOrganizationService(nil, f.agents, approvals), TaskSLAConfig{Reescalation: 4 * time.Hour})
+	ctx := context.Background()
+	task := f.tasks.tasks["t1"]
+	approvers := func() []string {
+		var ids []string
+		for _, a := range approvals.created {
+			ids = append(ids, *a.ApproverID)
+		}
+		return ids
+	}
+
+	svc.Check(ctx)
+	svc.Check(ctx) // 间隔未到，不重复升级
+	if got := approvers(); !reflect.DeepEqual(got, []string{"lead"}) || task.EscalationLevel != 1 {
+		t.Fatalf("after overdue: approvers %v, level %d", got, task.EscalationLevel)
+	}
+
+	for _, want := range []string{"director", "ceo"} {
+		*task.EscalatedAt = task.EscalatedAt.Add(-5 * time.Hour)
+		svc.Check(ctx)
+		if got := approvers(); got[len(got)-1] != want {
+			t.Fatalf("approvers %v, want next %s", got, want)
+		}
+	}
+	if task.EscalationLevel != 3 {
+		t.Errorf("level = %d, want 3", task.EscalationLevel)
+	}
+
+	// 汇报链已到顶：不再创建审批，并停止后续升级
+	*task.EscalatedAt = task.EscalatedAt.Add(-5 * time.Hour)
+	svc.Check(ctx)
+	if got := approvers(); len(got) != 3 || task.EscalatedAt != nil || task.EscalationLevel != 3 {
+		t.Errorf("at top: approvers %v, escalated_at %v, level %d", got, task.EscalatedAt, task.EscalationLevel)
+	}
+}
+
+func TestTaskSLA_StopsReescalatingOnceHandled(t *testing.T) {
+	due := time.Now().Add(-time.Hour)
+	assignee := "worker"
+	f := newTaskServiceFixture(&domain.Task{
+		ID: "t1", CompanyID: "c1", Title: "发版", Status: domain.TaskStatusInProgress,
+		Priority: domain.TaskPriorityHigh, AssigneeID: &assignee, DueAt: &due,
+	})
+	f.svc.taskRepo = &slaTaskRepo{memTaskRepo: f.tasks}
+	f.addAgents(
+		&domain.Agent{ID: "worker", CompanyID: "c1", ManagerID: ptr("lead")},
+		&domain.Agent{ID: "lead", CompanyID: "c1", ManagerID: ptr("director")},
+		&domain.Agent{ID: "director", CompanyID: "c1"},
+	)
+	approvals := &memApprovalRepo{}
+	svc := NewTaskSLAService(f.svc, NewOrganizationService(nil, f.agents, approvals), TaskSLAConfig{Reescalation: time.Hour})
+	ctx := context.Background()
+
+	svc.Check(ctx)
+	task := f.tasks.tasks["t1"]
+	task.Status = domain.TaskStatusDone
+	*task.EscalatedAt = task.EscalatedAt.Add(-2 * time.Hour)
+	svc.Check(ctx)
+	if len(approvals.created) != 1 || task.EscalationLevel != 1 {
+		t.Errorf("handled task escalated again: %d approvals, level %d", len(approvals.created), task.EscalationLevel)
+	}
+}
@@ -0,0 +1,641 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+const taskTemplateMaxNodes = 200
+
+var (
+	templateParamName   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	templatePlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+)
+
+// TaskTemplateService 任务模板管理、实例化与周期性调度
+type TaskTemplateService struct {
+	repo  repository.TaskTemplateRepo
+	tasks *TaskService
+}
+
+func NewTaskTemplateService(repo repository.TaskTemplateRepo, tasks *TaskService) *TaskTemplateService {
+	return &TaskTemplateService{repo: repo, tasks: tasks}
+}
+
+// ── 模板管理 ────────────────────────────────────────────
+
+func (s *TaskTemplateService) CreateTemplate(ctx context.Context, actor *domain.Agent, t *domain.TaskTemplate) error {
+	if !actor.HasPermission(domain.PermTaskManage) {
+		return fmt.Errorf("permission denied")
+	}
+	t.ID = ""
+	t.CompanyID = actor.CompanyID
+	t.CreatedBy = &actor.ID
+	if err := validateTaskTemplate(t); err != nil {
+		return err
+	}
+	if existing, err := s.repo.GetByName(ctx, t.CompanyID, t.Name); err != nil {
+		return err
+	} else if existing != nil {
+		return fmt.Errorf("template %q already exists", t.Name)
+	}
+	return s.repo.Create(ctx, t)
+}
+
+// UpdateTemplate 整体替换模板名称、描述、参数与任务树
+func (s *TaskTemplateService) UpdateTemplate(ctx context.Context, actor *domain.Agent, id string, in *domain.TaskTemplate) (*domain.TaskTemplate, error) {
+	if !actor.HasPermission(domain.PermTaskManage) {
+		return nil, fmt.Errorf("permission denied")
+	}
+	t, err := s.GetTemplate(ctx, actor.CompanyID, id)
+	if err != nil {
+		return nil, err
+	}
+	t.Name, t.Description, t.Params, t.Spec = in.Name, in.Description, in.Params, in.Spec
+	if err := validateTaskTemplate(t); err != nil {
+		return nil, err
+	}
+	if existing, err := s.repo.GetByName(ctx, t.CompanyID, t.Name); err != nil {
+		return nil, err
+	} else if existing != nil && existing.ID != t.ID {
+		return nil, fmt.Errorf("template %q already exists", t.Name)
+	}
+	if err := s.repo.Update(ctx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *TaskTemplateService) GetTemplate(ctx context.Context, companyID, id string) (*domain.TaskTemplate, error) {
+	t, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil || t.CompanyID != companyID {
+		return nil, fmt.Errorf("template not found")
+	}
+	return t, nil
+}
+
+// FindTemplate 按 ID 或名称查找模板（供 MCP 工具使用）
+func (s *TaskTemplateService) FindTemplate(ctx context.Context, companyID, idOrName string) (*domain.TaskTemplate, error) {
+	if t, err := s.repo.GetByName(ctx, companyID, idOrName); err != nil || t != nil {
+		return t, err
+	}
+	if _, err := uuid.Parse(idOrName); err != nil {
+		return nil, fmt.Errorf("template not found")
+	}
+	return s.GetTemplate(ctx, companyID, idOrName)
+}
+
+func (s *TaskTemplateService) ListTemplates(ctx context.Context, companyID string) ([]*domain.TaskTemplate, error) {
+	return s.repo.ListByCompany(ctx, companyID)
+}
+
+func (s *TaskTemplateService) DeleteTemplate(ctx context.Context, actor *domain.Agent, id string) error {
+	if !actor.HasPermission(domain.PermTaskManage) {
+		return fmt.Errorf("permission denied")
+	}
+	if _, err := s.GetTemplate(ctx, actor.CompanyID, id); err != nil {
+		return err
+	}
+	// 模板与其周期规则在同一事务中删除，避免留下指向已删除模板的规则
+	return s.tasks.withinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.DeleteRecurrencesByTemplate(ctx, id); err != nil {
+			return err
+		}
+		return s.repo.Delete(ctx, id)
+	})
+}
+
+// ── 实例化 ──────────────────────────────────────────────
+
+// Instantiate 以 actor 身份按模板创建任务树（经 TaskService.Create），返回根任务
+func (s *TaskTemplateService) Instantiate(ctx context.Context, templateID string, actor *domain.Agent, params map[string]string) (*domain.Task, error) {
+	t, err := s.GetTemplate(ctx, actor.CompanyID, templateID)
+	if err != nil {
+		return nil, err
+	}
+	return s.instantiate(ctx, t, actor, params, time.Now())
+}
+
+// instantiate 在单个事务中创建整棵任务树及依赖，任一步失败全部回滚；提交后才发布事件与自动分配
+func (s *TaskTemplateService) instantiate(ctx context.Context, t *domain.TaskTemplate, actor *domain.Agent, params map[string]string, now time.Time) (*domain.Task, error) {
+	var inst *templateInstance
+	var root *domain.Task
+	if err := s.tasks.withinTx(ctx, func(ctx context.Context) error {
+		var err error
+		inst, root, err = s.build(ctx, t, actor, params, now)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	inst.publish(ctx)
+	return root, nil
+}
+
+// build 写入任务树与依赖，需在事务内调用
+func (s *TaskTemplateService) build(ctx context.Context, t *domain.TaskTemplate, actor *domain.Agent, params map[string]string, now time.Time) (*templateInstance, *domain.Task, error) {
+	values, err := templateValues(t.Params, params, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	inst := &templateInstance{svc: s, actor: actor, values: values, now: now, ids: map[string]string{}}
+	root, err := inst.create(ctx, &t.Spec, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, d := range inst.deps {
+		if _, err := s.tasks.AddDependency(ctx, d.taskID, inst.ids[d.dependsOn]); err != nil {
+			return nil, nil, fmt.Errorf("add dependency %s: %w", d.dependsOn, err)
+		}
+	}
+	return inst, root, nil
+}
+
+type templateInstance struct {
+	svc     *TaskTemplateService
+	actor   *domain.Agent
+	values  map[string]string
+	now     time.Time
+	ids     map[string]string // 节点 key → 任务 ID
+	deps    []struct{ taskID, dependsOn string }
+	created []*domain.Task
+	auto    map[string]bool // 需要自动分配的任务 ID
+}
+
+// publish 事务提交后逐个发布 TaskCreated 并执行自动分配
+func (inst *templateInstance) publish(ctx context.Context) {
+	for _, t := range inst.created {
+		inst.svc.tasks.afterCreate(ctx, t, inst.auto[t.ID])
+	}
+}
+
+func (inst *templateInstance) create(ctx context.Context, n *domain.TaskTemplateNode, parentID *string) (*domain.Task, error) {
+	creatorID := inst.actor.ID
+	in := CreateTaskInput{
+		CompanyID:    inst.actor.CompanyID,
+		ParentID:     parentID,
+		Title:        renderTemplate(n.Title, inst.values),
+		Description:  renderTemplate(n.Description, inst.values),
+		Priority:     n.Priority,
+		CreatedBy:    &creatorID,
+		ReviewPolicy: n.Review,
+	}
+	for _, tag := range n.Tags {
+		if tag = strings.TrimSpace(renderTemplate(tag, inst.values)); tag != "" {
+			in.Tags = append(in.Tags, tag)
+		}
+	}
+	if n.DueInHours > 0 {
+		due := inst.now.Add(time.Duration(n.DueInHours) * time.Hour)
+		in.DueAt = &due
+	}
+	if n.Assignee == domain.TemplateAssigneeAuto {
+		in.AutoAssign = true
+	} else {
+		assignee, err := inst.svc.resolveAssignee(ctx, n.Assignee, inst.actor)
+		if err != nil {
+			return nil, err
+		}
+		in.AssigneeID = assignee
+	}
+
+	task, err := inst.svc.tasks.insertTask(ctx, in)
+	if err != nil {
+		return nil, fmt.Errorf("create %q: %w", in.Title, err)
+	}
+	inst.created = append(inst.created, task)
+	if in.AutoAssign {
+		if inst.auto == nil {
+			inst.auto = map[string]bool{}
+		}
+		inst.auto[task.ID] = true
+	}
+	if n.Key != "" {
+		inst.ids[n.Key] = task.ID
+	}
+	for _, dep := range n.DependsOn {
+		inst.deps = append(inst.deps, struct{ taskID, dependsOn string }{task.ID, dep})
+	}
+	for i := range n.Subtasks {
+		sub, err := inst.create(ctx, &n.Subtasks[i], &task.ID)
+		if err != nil {
+			return nil, err
+		}
+		task.Subtasks = append(task.Subtasks, sub)
+	}
+	return task, nil
+}
+
+// resolveAssignee 解析节点负责人写法（见 domain.TemplateAssignee*）；找不到合适人选时返回 nil（任务待认领）
+func (s *TaskTemplateService) resolveAssignee(ctx context.Context, spec string, actor *domain.Agent) (*string, error) {
+	switch {
+	case spec == "":
+		return nil, nil
+	case spec == domain.TemplateAssigneeCreator:
+		id := actor.ID
+		return &id, nil
+	case spec == domain.TemplateAssigneeManager:
+		return s.tasks.managerOf(ctx, actor), nil
+	case strings.HasPrefix(spec, domain.TemplateAssigneeAgentPrefix):
+		id := strings.TrimPrefix(spec, domain.TemplateAssigneeAgentPrefix)
+		a, err := s.tasks.agentRepo.GetByID(ctx, id)
+		if err != nil || a == nil || a.CompanyID != actor.CompanyID {
+			return nil, fmt.Errorf("template assignee %s not found", id)
+		}
+		return &id, nil
+	}
+	// 职位：同一自定义部门的同事优先
+	pos := domain.Position(spec)
+	if actor.DepartmentID != nil {
+		if id := s.tasks.pickAgent(ctx, actor.CompanyID, "", func(a *domain.Agent) bool {
+			return a.Position == pos && a.DepartmentID != nil && *a.DepartmentID == *actor.DepartmentID
+		}); id != nil {
+			return id, nil
+		}
+	}
+	return s.tasks.pickAgent(ctx, actor.CompanyID, "", func(a *domain.Agent) bool {
+		return a.Position == pos
+	}), nil
+}
+
+// templateValues 合并内置变量、参数默认值与调用方传入的参数；缺少必填参数时报错
+func templateValues(defs domain.TaskTemplateParams, params map[string]string, now time.Time) (map[string]string, error) {
+	year, week := now.ISOWeek()
+	values := map[string]string{
+		"date":  now.Format("2006-01-02"),
+		"month": now.Format("2006-01"),
+		"year":  now.Format("2006"),
+		"week":  fmt.Sprintf("%d-W%02d", year, week),
+	}
+	var missing []string
+	for _, p := range defs {
+		v := strings.TrimSpace(params[p.Name])
+		if v == "" {
+			v = p.Default
+		}
+		if v == "" && p.Required {
+			missing = append(missing, p.Name)
+			continue
+		}
+		values[p.Name] = v
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing template params: %s", strings.Join(missing, ", "))
+	}
+	for k, v := range params {
+		if _, ok := values[k]; !ok {
+			values[k] = v
+		}
+	}
+	return values, nil
+}
+
+// renderTemplate 替换 {{name}} 占位符；未知变量原样保留
+func renderTemplate(text string, values map[string]string) string {
+	return templatePlaceholder.ReplaceAllStringFunc(text, func(m string) string {
+		if v, ok := values[templatePlaceholder.FindStringSubmatch(m)[1]]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// validateTaskTemplate 校验参数名、节点字段、key 唯一性以及依赖引用和环
+func validateTaskTemplate(t *domain.TaskTemplate) error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	seenParams := map[string]bool{}
+	for _, p := range t.Params {
+		if !templateParamName.MatchString(p.Name) {
+			return fmt.Errorf("invalid param name %q", p.Name)
+		}
+		if seenParams[p.Name] {
+			return fmt.Errorf("duplicate param %q", p.Name)
+		}
+		seenParams[p.Name] = true
+	}
+
+	deps := map[string][]string{}
+	count := 0
+	var walk func(n *domain.TaskTemplateNode, path string) error
+	walk = func(n *domain.TaskTemplateNode, path string) error {
+		if count++; count > taskTemplateMaxNodes {
+			return fmt.Errorf("template has more than %d tasks", taskTemplateMaxNodes)
+		}
+		if strings.TrimSpace(n.Title) == "" {
+			return fmt.Errorf("%s: title is required", path)
+		}
+		switch n.Priority {
+		case "", domain.TaskPriorityLow, domain.TaskPriorityMedium, domain.TaskPriorityHigh, domain.TaskPriorityUrgent:
+		default:
+			return fmt.Errorf("%s: invalid priority %q", path, n.Priority)
+		}
+		if !n.Review.Valid() {
+			return fmt.Errorf("%s: invalid review policy %q", path, n.Review)
+		}
+		if err := validateTemplateAssignee(n.Assignee); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if n.DueInHours < 0 {
+			return fmt.Errorf("%s: due_in_hours must not be negative", path)
+		}
+		if len(n.DependsOn) > 0 && n.Key == "" {
+			return fmt.Errorf("%s: key is required when depends_on is set", path)
+		}
+		if n.Key != "" {
+			if _, dup := deps[n.Key]; dup {
+				return fmt.Errorf("%s: duplicate key %q", path, n.Key)
+			}
+			deps[n.Key] = n.DependsOn
+		}
+		for i := range n.Subtasks {
+			if err := walk(&n.Subtasks[i], fmt.Sprintf("%s.subtasks[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(&t.Spec, "spec"); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(deps))
+	for k, ds := range deps {
+		keys = append(keys, k)
+		for _, d := range ds {
+			if _, ok := deps[d]; !ok {
+				return fmt.Errorf("task %q depends on unknown key %q", k, d)
+			}
+			if d == k {
+				return fmt.Errorf("task %q cannot depend on itself", k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	state := map[string]int{} // 0 未访问，1 访问中，2 完成
+	var visit func(k string) bool
+	visit = func(k string) bool {
+		switch state[k] {
+		case 1:
+			return false
+		case 2:
+			return true
+		}
+		state[k] = 1
+		for _, d := range deps[k] {
+			if !visit(d) {
+				return false
+			}
+		}
+		state[k] = 2
+		return true
+	}
+	for _, k := range keys {
+		if !visit(k) {
+			return fmt.Errorf("dependencies contain a cycle involving %q", k)
+		}
+	}
+	return nil
+}
+
+func validateTemplateAssignee(spec string) error {
+	switch {
+	case spec == "", spec == domain.TemplateAssigneeCreator, spec == domain.TemplateAssigneeManager, spec == domain.TemplateAssigneeAuto:
+		return nil
+	case strings.HasPrefix(spec, domain.TemplateAssigneeAgentPrefix):
+		if strings.TrimPrefix(spec, domain.TemplateAssigneeAgentPrefix) == "" {
+			return fmt.Errorf("assignee %q: missing agent id", spec)
+		}
+		return nil
+	}
+	if _, ok := domain.PositionMetaByPosition[domain.Position(spec)]; !ok {
+		return fmt.Errorf("unknown assignee %q (use creator, manager, auto, agent:<id> or a position)", spec)
+	}
+	return nil
+}
+
+// ── 周期规则 ────────────────────────────────────────────
+
+// TaskRecurrenceInput 创建/修改周期规则的参数；Enabled 为 nil 时创建默认启用、修改保持不变
+type TaskRecurrenceInput struct {
+	Cron     string            `json:"cron"`
+	Timezone string            `json:"timezone"`
+	Params   map[string]string `json:"params"`
+	Enabled  *bool             `json:"enabled"`
+}
+
+func (s *TaskTemplateService) CreateRecurrence(ctx context.Context, actor *domain.Agent, templateID string, in TaskRecurrenceInput) (*domain.TaskRecurrence, error) {
+	if !actor.HasPermission(domain.PermTaskManage) {
+		return nil, fmt.Errorf("permission denied")
+	}
+	t, err := s.GetTemplate(ctx, actor.CompanyID, templateID)
+	if err != nil {
+		return nil, err
+	}
+	r := &domain.TaskRecurrence{
+		CompanyID:  actor.CompanyID,
+		TemplateID: t.ID,
+		Enabled:    in.Enabled == nil || *in.Enabled,
+		CreatedBy:  actor.ID,
+	}
+	if err := applyRecurrenceInput(r, t, in, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateRecurrence(ctx, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *TaskTemplateService) UpdateRecurrence(ctx context.Context, actor *domain.Agent, id string, in TaskRecurrenceInput) (*domain.TaskRecurrence, error) {
+	if !actor.HasPermission(domain.PermTaskManage) {
+		return nil, fmt.Errorf("permission denied")
+	}
+	r, err := s.getRecurrence(ctx, actor.CompanyID, id)
+	if err != nil {
+		return nil, err
+	}
+	t, err := s.GetTemplate(ctx, actor.CompanyID, r.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+	if in.Enabled != nil {
+		r.Enabled = *in.Enabled
+	}
+	if in.Cron == "" {
+		in.Cron = r.Cron
+	}
+	if in.Timezone == "" {
+		in.Timezone = r.Timezone
+	}
+	if in.Params == nil {
+		in.Params = recurrenceParams(r)
+	}
+	if err := applyRecurrenceInput(r, t, in, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateRecurrence(ctx, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *TaskTemplateService) ListRecurrences(ctx context.Context, companyID, templateID string) ([]*domain.TaskRecurrence, error) {
+	return s.repo.ListRecurrences(ctx, companyID, templateID)
+}
+
+func (s *TaskTemplateService) DeleteRecurrence(ctx context.Context, actor *domain.Agent, id string) error {
+	if !actor.HasPermission(domain.PermTaskManage) {
+		return fmt.Errorf("permission denied")
+	}
+	if _, err := s.getRecurrence(ctx, actor.CompanyID, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteRecurrence(ctx, id)
+}
+
+func (s *TaskTemplateService) getRecurrence(ctx context.Context, companyID, id string) (*domain.TaskRecurrence, error) {
+	r, err := s.repo.GetRecurrence(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil || r.CompanyID != companyID {
+		return nil, fmt.Errorf("recurrence not found")
+	}
+	return r, nil
+}
+
+// applyRecurrenceInput 校验 cron、时区与模板必填参数，并据此计算下次触发时间
+func applyRecurrenceInput(r *domain.TaskRecurrence, t *domain.TaskTemplate, in TaskRecurrenceInput, now time.Time) error {
+	if in.Timezone == "" {
+		in.Timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(in.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q", in.Timezone)
+	}
+	sched, err := ParseCron(in.Cron)
+	if err != nil {
+		return err
+	}
+	if _, err := templateValues(t.Params, in.Params, now); err != nil {
+		return err
+	}
+	r.Cron, r.Timezone = strings.TrimSpace(in.Cron), in.Timezone
+	r.Params = domain.JSONMap{}
+	for k, v := range in.Params {
+		r.Params[k] = v
+	}
+	r.NextRunAt = nil
+	if next := sched.Next(now.In(loc)); !next.IsZero() {
+		r.NextRunAt = &next
+	}
+	return nil
+}
+
+func recurrenceParams(r *domain.TaskRecurrence) map[string]string {
+	out := make(map[string]string, len(r.Params))
+	for k, v := range r.Params {
+		out[k] = fmt.Sprint(v)
+	}
+	return out
+}
+
+// Run 每分钟检查到期的周期规则并实例化模板，直到 ctx 结束
+func (s *TaskTemplateService) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.runDue(ctx, time.Now()); err != nil {
+				log.Printf("[task-template] recurrence: %v", err)
+			}
+		}
+	}
+}
+
+// errRecurrenceClaimed 到期的周期规则已被其他实例处理
+var errRecurrenceClaimed = errors.New("recurrence already claimed")
+
+func (s *TaskTemplateService) runDue(ctx context.Context, now time.Time) error {
+	due, err := s.repo.ListDueRecurrences(ctx, now, 50)
+	if err != nil {
+		return err
+	}
+	for _, r := range due {
+		var next *time.Time
+		if loc, err := time.LoadLocation(r.Timezone); err == nil {
+			if sched, err := ParseCron(r.Cron); err == nil {
+				if n := sched.Next(now.In(loc)); !n.IsZero() {
+					next = &n
+				}
+			}
+		}
+
+		// 推进下次触发时间与实例化在同一事务中：抢占失败说明其他实例已处理；
+		// 实例化失败时一并回滚，下一轮重试。错过的多次触发只补一次
+		var inst *templateInstance
+		var task *domain.Task
+		err := s.tasks.withinTx(ctx, func(ctx context.Context) error {
+			ok, err := s.repo.AdvanceRecurrence(ctx, r.ID, *r.NextRunAt, next)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return errRecurrenceClaimed
+			}
+			inst, task, err = s.runRecurrence(ctx, r, now)
+			return err
+		})
+		if errors.Is(err, errRecurrenceClaimed) {
+			continue
+		}
+
+		var taskID *string
+		lastErr := ""
+		if err != nil {
+			lastErr = err.Error()
+			log.Printf("[task-template] recurrence %s: %v", r.ID, err)
+		} else {
+			inst.publish(ctx)
+			taskID = &task.ID
+		}
+		if err := s.repo.SetRecurrenceResult(ctx, r.ID, taskID, lastErr); err != nil {
+			log.Printf("[task-template] recurrence %s: %v", r.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *TaskTemplateService) runRecurrence(ctx context.Context, r *domain.TaskRecurrence, now time.Time) (*templateInstance, *domain.Task, error) {
+	t, err := s.GetTemplate(ctx, r.CompanyID, r.TemplateID)
+	if err != nil {
+		return nil, nil, err
+	}
+	actor, err := s.tasks.agentRepo.GetByID(ctx, r.CreatedBy)
+	if err != nil {
+		return nil, nil, err
+	}
+	if actor == nil {
+		return nil, nil, fmt.Errorf("recurrence owner %s no longer exists", r.CreatedBy)
+	}
+	return s.build(ctx, t, actor, recurrenceParams(r), now)
+}
@@ -0,0 +1,323 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/event"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+func TestTemplateValuesAndRender(t *testing.T) {
+	now := time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)
+	defs := domain.TaskTemplateParams{
+		{Name: "version", Required: true},
+		{Name: "env", Default: "staging"},
+	}
+	if _, err := templateValues(defs, nil, now); err == nil || !strings.Contains(err.Error(), "version") {
+		t.Fatalf("expected missing version error, got %v", err)
+	}
+	values, err := templateValues(defs, map[string]string{"version": "1.2.0"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := renderTemplate("发布 {{version}} 到 {{ env }}（{{week}}，{{unknown}}）", values)
+	want := "发布 1.2.0 到 staging（2025-W03，{{unknown}}）"
+	if got != want {
+		t.Errorf("render = %q, want %q", got, want)
+	}
+}
+
+func TestValidateTaskTemplate(t *testing.T) {
+	node := func(key, title string, deps ...string) domain.TaskTemplateNode {
+		return domain.TaskTemplateNode{Key: key, Title: title, DependsOn: deps}
+	}
+	tests := []struct {
+		name    string
+		spec    domain.TaskTemplateNode
+		wantErr string
+	}{
+		{"valid tree", domain.TaskTemplateNode{Title: "发版 {{version}}", Subtasks: []domain.TaskTemplateNode{
+			node("build", "构建"),
+			{Key: "test", Title: "测试", Assignee: "qa_engineer", DependsOn: []string{"build"}},
+			{Key: "ship", Title: "上线", Assignee: "manager", DependsOn: []string{"build", "test"}},
+		}}, ""},
+		{"missing title", domain.TaskTemplateNode{Subtasks: nil}, "title is required"},
+		{"unknown assignee", domain.TaskTemplateNode{Title: "x", Assignee: "wizard"}, "unknown assignee"},
+		{"unknown dependency", domain.TaskTemplateNode{Title: "x", Subtasks: []domain.TaskTemplateNode{node("a", "a", "b")}}, "unknown key"},
+		{"duplicate key", domain.TaskTemplateNode{Title: "x", Subtasks: []domain.TaskTemplateNode{node("a", "a"), node("a", "b")}}, "duplicate key"},
+		{"cycle", domain.TaskTemplateNode{Title: "x", Subtasks: []domain.TaskTemplateNode{node("a", "a", "b"), node("b", "b", "a")}}, "cycle"},
+		{"deps need key", domain.TaskTemplateNode{Title: "x", Subtasks: []domain.TaskTemplateNode{node("a", "a"), node("", "b", "a")}}, "key is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTaskTemplate(&domain.TaskTemplate{Name: "release", Spec: tt.spec})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyRecurrenceInput(t *testing.T) {
+	tpl := &domain.TaskTemplate{Params: domain.TaskTemplateParams{{Name: "team", Required: true}}}
+	now := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	r := &domain.TaskRecurrence{}
+	if err := applyRecurrenceInput(r, tpl, TaskRecurrenceInput{Cron: "0 9 * * 1"}, now); err == nil {
+		t.Fatal("expected missing param error")
+	}
+	err := applyRecurrenceInput(r, tpl, TaskRecurrenceInput{Cron: "0 9 * * 1", Params: map[string]string{"team": "web"}}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2025, 1, 20, 9, 0, 0, 0, time.UTC); r.NextRunAt == nil || !r.NextRunAt.Equal(want) {
+		t.Errorf("next = %v, want %v", r.NextRunAt, want)
+	}
+	if !reflect.DeepEqual(recurrenceParams(r), map[string]string{"team": "web"}) {
+		t.Errorf("params = %v", r.Params)
+	}
+}
+
+// memTemplateRepo 内存模板与周期规则；GetByID 对非 UUID 返回与 Postgres 相同的语法错误
+type memTemplateRepo struct {
+	repository.TaskTemplateRepo
+	templates   map[string]*domain.TaskTemplate
+	recurrences map[string]*domain.TaskRecurrence
+	deleteErr   error
+}
+
+func (r *memTemplateRepo) Delete(_ context.Context, id string) error {
+	if r.deleteErr != nil {
+		return r.deleteErr
+	}
+	delete(r.templates, id)
+	return nil
+}
+
+func (r *memTemplateRepo) DeleteRecurrencesByTemplate(_ context.Context, templateID string) error {
+	for id, rec := range r.recurrences {
+		if rec.TemplateID == templateID {
+			delete(r.recurrences, id)
+		}
+	}
+	return nil
+}
+
+func (r *memTemplateRepo) GetByID(_ context.Context, id string) (*domain.TaskTemplate, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("invalid input syntax for type uuid: %q", id)
+	}
+	return r.templates[id], nil
+}
+
+func (r *memTemplateRepo) GetByName(_ context.Context, companyID, name string) (*domain.TaskTemplate, error) {
+	for _, t := range r.templates {
+		if t.CompanyID == companyID && t.Name == name {
+			return t, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memTemplateRepo) ListDueRecurrences(_ context.Context, now time.Time, _ int) ([]*domain.TaskRecurrence, error) {
+	var out []*domain.TaskRecurrence
+	for _, rec := range r.recurrences {
+		if rec.Enabled && rec.NextRunAt != nil && !rec.NextRunAt.After(now) {
+			cp := *rec
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (r *memTemplateRepo) AdvanceRecurrence(_ context.Context, id string, prev time.Time, next *time.Time) (bool, error) {
+	rec := r.recurrences[id]
+	if rec.NextRunAt == nil || !rec.NextRunAt.Equal(prev) {
+		return false, nil
+	}
+	rec.NextRunAt = next
+	return true, nil
+}
+
+func (r *memTemplateRepo) SetRecurrenceResult(_ context.Context, id string, taskID *string, lastError string) error {
+	rec := r.recurrences[id]
+	if taskID != nil {
+		rec.LastTaskID = taskID
+	}
+	rec.LastError = lastError
+	return nil
+}
+
+// rollbackTx fn 失败时恢复任务表与周期规则的快照
+type rollbackTx struct {
+	tasks     *memTaskRepo
+	templates *memTemplateRepo
+}
+
+func (tx *rollbackTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tasks := make(map[string]*domain.Task, len(tx.tasks.tasks))
+	for id, t := range tx.tasks.tasks {
+		tasks[id] = t
+	}
+	recurrences := make(map[string]*domain.TaskRecurrence, len(tx.templates.recurrences))
+	values := make(map[string]domain.TaskRecurrence, len(tx.templates.recurrences))
+	for id, rec := range tx.templates.recurrences {
+		recurrences[id], values[id] = rec, *rec
+	}
+	if err := fn(ctx); err != nil {
+		tx.tasks.tasks = tasks
+		for id, rec := range recurrences {
+			*rec = values[id]
+		}
+		tx.templates.recurrences = recurrences
+		return err
+	}
+	return nil
+}
+
+const testTemplateID = "5b0f3c1e-7a52-4a8e-9c1d-2f4b6e8a0c13"
+
+func newTemplateFixture() (*TaskTemplateService, *taskServiceFixture, *memTemplateRepo) {
+	f := newTaskServiceFixture()
+	f.addAgents(&domain.Agent{ID: "owner", Name: "owner", CompanyID: "c1"})
+	repo := &memTemplateRepo{
+		templates: map[string]*domain.TaskTemplate{testTemplateID: {
+			ID: testTemplateID, CompanyID: "c1", Name: "weekly report",
+			Spec: domain.TaskTemplateNode{Title: "周报 {{week}}", Subtasks: []domain.TaskTemplateNode{
+				{Key: "draft", Title: "起草", Assignee: domain.TemplateAssigneeCreator},
+				{Key: "review", Title: "审阅", DependsOn: []string{"draft"}},
+			}},
+		}},
+		recurrences: map[string]*domain.TaskRecurrence{},
+	}
+	f.svc.tx = &rollbackTx{tasks: f.tasks, templates: repo}
+	return NewTaskTemplateService(repo, f.svc), f, repo
+}
+
+func recordTaskCreated(t *testing.T) *[]event.Event {
+	t.Helper()
+	var got []event.Event
+	unsubscribe := event.Global.Subscribe(event.TaskCreated, func(e event.Event) { got = append(got, e) })
+	t.Cleanup(unsubscribe)
+	return &got
+}
+
+func TestInstantiate_CreatesTreeAndPublishesAfterCommit(t *testing.T) {
+	svc, f, _ := newTemplateFixture()
+	created := recordTaskCreated(t)
+
+	root, err := svc.Instantiate(context.Background(), testTemplateID, f.agents.agents["owner"], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(root.Subtasks) != 2 || len(f.tasks.tasks) != 3 {
+		t.Fatalf("created %d tasks, want root with 2 subtasks", len(f.tasks.tasks))
+	}
+	if len(f.collab.deps) != 1 || f.collab.deps[0].TaskID != root.Subtasks[1].ID || f.collab.deps[0].DependsOnID != root.Subtasks[0].ID {
+		t.Errorf("deps = %+v", f.collab.deps)
+	}
+	if len(*created) != 3 {
+		t.Errorf("published %d task.created events, want 3", len(*created))
+	}
+}
+
+func TestInstantiate_RollsBackOnFailure(t *testing.T) {
+	svc, f, _ := newTemplateFixture()
+	f.collab.depErr = errors.New("db down")
+	created := recordTaskCreated(t)
+
+	if _, err := svc.Instantiate(context.Background(), testTemplateID, f.agents.agents["owner"], nil); err == nil {
+		t.Fatal("expected dependency error")
+	}
+	if len(f.tasks.tasks) != 0 {
+		t.Errorf("%d tasks left after rollback", len(f.tasks.tasks))
+	}
+	if len(*created) != 0 {
+		t.Errorf("published %d task.created events for a rolled back instantiation", len(*created))
+	}
+}
+
+func TestRunDue_AdvancesOnlyAfterSuccess(t *testing.T) {
+	svc, f, repo := newTemplateFixture()
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	due := now.Add(-time.Minute)
+	repo.recurrences["r1"] = &domain.TaskRecurrence{
+		ID: "r1", CompanyID: "c1", TemplateID: testTemplateID, Cron: "0 9 * * 1", Timezone: "UTC",
+		Enabled: true, NextRunAt: &due, CreatedBy: "owner",
+	}
+	f.collab.depErr = errors.New("db down")
+
+	if err := svc.runDue(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+	rec := repo.recurrences["r1"]
+	if !rec.NextRunAt.Equal(due) {
+		t.Errorf("next_run_at advanced to %v despite failure", rec.NextRunAt)
+	}
+	if !strings.Contains(rec.LastError, "db down") || len(f.tasks.tasks) != 0 {
+		t.Errorf("last_error = %q, tasks = %d", rec.LastError, len(f.tasks.tasks))
+	}
+
+	f.collab.depErr = nil
+	if err := svc.runDue(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Add(7 * 24 * time.Hour); rec.NextRunAt == nil || !rec.NextRunAt.Equal(want) {
+		t.Errorf("next_run_at = %v, want %v", rec.NextRunAt, want)
+	}
+	if rec.LastError != "" || rec.LastTaskID == nil || f.tasks.tasks[*rec.LastTaskID] == nil {
+		t.Errorf("last_error = %q, last_task_id = %v", rec.LastError, rec.LastTaskID)
+	}
+}
+
+func TestDeleteTemplate_RemovesRecurrencesInSameTx(t *testing.T) {
+	svc, _, repo := newTemplateFixture()
+	manager := &domain.Agent{ID: "lead", CompanyID: "c1", Permissions: domain.StringList{domain.PermTaskManage}}
+	repo.recurrences["r1"] = &domain.TaskRecurrence{ID: "r1", CompanyID: "c1", TemplateID: testTemplateID}
+	repo.recurrences["r2"] = &domain.TaskRecurrence{ID: "r2", CompanyID: "c1", TemplateID: "other"}
+
+	repo.deleteErr = errors.New("db down")
+	if err := svc.DeleteTemplate(context.Background(), manager, testTemplateID); err == nil {
+		t.Fatal("expected delete error")
+	}
+	if repo.recurrences["r1"] == nil {
+		t.Error("recurrence removed although the template delete was rolled back")
+	}
+
+	repo.deleteErr = nil
+	if err := svc.DeleteTemplate(context.Background(), manager, testTemplateID); err != nil {
+		t.Fatal(err)
+	}
+	if repo.templates[testTemplateID] != nil || repo.recurrences["r1"] != nil {
+		t.Errorf("template or its recurrence left behind: %v", repo.recurrences)
+	}
+	if repo.recurrences["r2"] == nil {
+		t.Error("recurrence of another template removed")
+	}
+}
+
+func TestFindTemplate(t *testing.T) {
+	svc, _, _ := newTemplateFixture()
+	for _, ref := range []string{"weekly report", testTemplateID} {
+		if got, err := svc.FindTemplate(context.Background(), "c1", ref); err != nil || got == nil || got.ID != testTemplateID {
+			t.Errorf("FindTemplate(%q) = %v, %v", ref, got, err)
+		}
+	}
+	if _, err := svc.FindTemplate(context.Background(), "c1", "monthly report"); err == nil || err.Error() != "template not found" {
+		t.Errorf("err = %v, want template not found", err)
+	}
+}
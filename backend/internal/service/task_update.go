@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/event"
+)
+
+// UpdateTaskInput 任务通用修改；nil 字段保持不变。ParentID 为空串表示移到顶层，Version 为空时使用当前版本
+type UpdateTaskInput struct {
+	Version     *int
+	Title       *string
+	Description *string
+	Priority    *domain.TaskPriority
+	ParentID    *string
+	Tags        *domain.StringList
+}
+
+// Update 修改任务标题、描述、优先级、标签或父任务，并逐字段记录变更历史；仅创建者或 task:manage 可操作
+func (s *TaskService) Update(ctx context.Context, taskID string, actor *domain.Agent, in UpdateTaskInput) (*domain.Task, error) {
+	t, err := s.taskRepo.GetByID(ctx, taskID)
+	if err != nil || t == nil || t.CompanyID != actor.CompanyID {
+		return nil, fmt.Errorf("task not found")
+	}
+	isCreator := t.CreatedBy != nil && *t.CreatedBy == actor.ID
+	if !actor.HasPermission(domain.PermTaskManage) && !isCreator {
+		return nil, fmt.Errorf("permission denied")
+	}
+	version := t.Version
+	if in.Version != nil {
+		version = *in.Version
+	}
+
+	next := *t
+	var changes []*domain.TaskEvent
+	if in.Title != nil {
+		title := strings.TrimSpace(*in.Title)
+		if title == "" {
+			return nil, fmt.Errorf("title is required")
+		}
+		if title != t.Title {
+			next.Title = title
+			changes = append(changes, newTaskEvent(t, actor.ID, "title", t.Title, title))
+		}
+	}
+	if in.Description != nil && *in.Description != t.Description {
+		next.Description = *in.Description
+		changes = append(changes, newTaskEvent(t, actor.ID, "description", t.Description, *in.Description))
+	}
+	if in.Priority != nil && *in.Priority != t.Priority {
+		switch *in.Priority {
+		case domain.TaskPriorityLow, domain.TaskPriorityMedium, domain.TaskPriorityHigh, domain.TaskPriorityUrgent:
+		default:
+			return nil, fmt.Errorf("invalid priority: %s", *in.Priority)
+		}
+		next.Priority = *in.Priority
+		changes = append(changes, newTaskEvent(t, actor.ID, "priority", string(t.Priority), string(*in.Priority)))
+	}
+	if in.Tags != nil && strings.Join(*in.Tags, ",") != strings.Join(t.Tags, ",") {
+		next.Tags = *in.Tags
+		changes = append(changes, newTaskEvent(t, actor.ID, "tags", strings.Join(t.Tags, ","), strings.Join(*in.Tags, ",")))
+	}
+	if in.ParentID != nil && *in.ParentID != derefString(t.ParentID) {
+		if *in.ParentID == "" {
+			next.ParentID = nil
+		} else {
+			if *in.ParentID == t.ID {
+				return nil, fmt.Errorf("task cannot be its own parent")
+			}
+			parentID := *in.ParentID
+			next.ParentID = &parentID
+		}
+		changes = append(changes, newTaskEvent(t, actor.ID, "parent_id", derefString(t.ParentID), *in.ParentID))
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("no changes")
+	}
+
+	next.Version = version + 1
+	updated := event.NewEvent(event.TaskUpdated, event.TaskUpdatedPayload{
+		TaskID: next.ID, CompanyID: next.CompanyID, Status: string(next.Status), Title: next.Title, AssigneeID: next.AssigneeID,
+	})
+	err = s.commitWithEvents(ctx, func(ctx context.Context) error {
+		// 成环校验与写入在同一事务内，并按公司串行化，避免并发的反向移动形成环
+		if next.ParentID != nil && derefString(next.ParentID) != derefString(t.ParentID) {
+			if err := s.taskRepo.LockHierarchy(ctx, t.CompanyID); err != nil {
+				return err
+			}
+			if err := s.checkNewParent(ctx, t, *next.ParentID); err != nil {
+				return err
+			}
+		}
+		if err := s.taskRepo.Update(ctx, &next, version); err != nil {
+			return err
+		}
+		return s.collabRepo.AddEvents(ctx, changes)
+	}, updated)
+	if err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// checkNewParent 校验新父任务存在于同一公司，且不是任务自身或其后代（避免成环）；需在持有 LockHierarchy 的事务内调用
+func (s *TaskService) checkNewParent(ctx context.Context, t *domain.Task, parentID string) error {
+	if parentID == t.ID {
+		return fmt.Errorf("task cannot be its own parent")
+	}
+	parent, err := s.taskRepo.GetByID(ctx, parentID)
+	if err != nil || parent == nil || parent.CompanyID != t.CompanyID {
+		return fmt.Errorf("parent task not found")
+	}
+	descendants, err := s.taskRepo.ListDescendants(ctx, t.ID)
+	if err != nil {
+		return err
+	}
+	for _, d := range descendants {
+		if d.ID == parentID {
+			return fmt.Errorf("cannot move task under its own subtask")
+		}
+	}
+	return nil
+}
+
+// newTaskEvent 构造一条字段变更记录；actorID 为空表示系统操作
+func newTaskEvent(t *domain.Task, actorID, field, from, to string) *domain.TaskEvent {
+	e := &domain.TaskEvent{
+		ID:        uuid.New().String(),
+		TaskID:    t.ID,
+		CompanyID: t.CompanyID,
+		Field:     field,
+		OldValue:  from,
+		NewValue:  to,
+	}
+	if actorID != "" {
+		e.ActorID = &actorID
+	}
+	return e
+}
+
+func derefString(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
+
+func formatDue(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
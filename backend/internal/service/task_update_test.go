@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+// treeTasks 构造 root <- child 的两级任务树，以及一个独立的 other 任务
+func treeTasks() []*domain.Task {
+	creator := "owner"
+	return []*domain.Task{
+		{ID: "root", CompanyID: "c1", Title: "根", Status: domain.TaskStatusPending, Priority: domain.TaskPriorityMedium, CreatedBy: &creator, Version: 1},
+		{ID: "child", CompanyID: "c1", Title: "子", Status: domain.TaskStatusPending, Priority: domain.TaskPriorityMedium, ParentID: ptr("root"), CreatedBy: &creator, Version: 1},
+		{ID: "other", CompanyID: "c1", Title: "其他", Status: domain.TaskStatusPending, Priority: domain.TaskPriorityMedium, CreatedBy: &creator, Version: 1},
+	}
+}
+
+func TestTaskUpdate_ChangesFieldsAndRecordsEvents(t *testing.T) {
+	f := newTaskServiceFixture(treeTasks()...)
+	published := recordTaskUpdated(t)
+	owner := &domain.Agent{ID: "owner", CompanyID: "c1"}
+
+	got, err := f.svc.Update(context.Background(), "other", owner, UpdateTaskInput{
+		Title:    ptr("  新标题 "),
+		Priority: ptr(domain.TaskPriorityHigh),
+		ParentID: ptr("root"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "新标题" || got.Priority != domain.TaskPriorityHigh || derefString(got.ParentID) != "root" || got.Version != 2 {
+		t.Errorf("task = %+v", got)
+	}
+	if stored := f.tasks.tasks["other"]; stored.Title != "新标题" || derefString(stored.ParentID) != "root" {
+		t.Errorf("stored task = %+v", stored)
+	}
+	fields := map[string]bool{}
+	for _, e := range f.collab.events {
+		fields[e.Field] = true
+	}
+	if len(f.collab.events) != 3 || !fields["title"] || !fields["priority"] || !fields["parent_id"] {
+		t.Errorf("events = %+v, want title/priority/parent_id", f.collab.events)
+	}
+	if len(*published) != 1 {
+		t.Errorf("published %d events, want 1", len(*published))
+	}
+}
+
+func TestTaskUpdate_Rejects(t *testing.T) {
+	owner := &domain.Agent{ID: "owner", CompanyID: "c1"}
+	tests := []struct {
+		name    string
+		taskID  string
+		actor   *domain.Agent
+		in      UpdateTaskInput
+		wantErr string
+	}{
+		{"own parent", "root", owner, UpdateTaskInput{ParentID: ptr("root")}, "task cannot be its own parent"},
+		{"under descendant", "root", owner, UpdateTaskInput{ParentID: ptr("child")}, "cannot move task under its own subtask"},
+		{"missing parent", "other", owner, UpdateTaskInput{ParentID: ptr("nope")}, "parent task not found"},
+		{"not creator", "other", &domain.Agent{ID: "x", CompanyID: "c1"}, UpdateTaskInput{Title: ptr("t")}, "permission denied"},
+		{"other company", "other", &domain.Agent{ID: "owner", CompanyID: "c2"}, UpdateTaskInput{Title: ptr("t")}, "task not found"},
+		{"no changes", "other", owner, UpdateTaskInput{Title: ptr("其他")}, "no changes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTaskServiceFixture(treeTasks()...)
+			_, err := f.svc.Update(context.Background(), tt.taskID, tt.actor, tt.in)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+			if f.tasks.tasks[tt.taskID].Version != 1 || len(f.collab.events) != 0 || len(f.outbox.committed) != 0 {
+				t.Error("rejected update changed state")
+			}
+		})
+	}
+}
+
+func TestTaskUpdate_StaleVersionConflicts(t *testing.T) {
+	f := newTaskServiceFixture(treeTasks()...)
+	stale := 0
+
+	_, err := f.svc.Update(context.Background(), "other", &domain.Agent{ID: "owner", CompanyID: "c1"},
+		UpdateTaskInput{Version: &stale, Title: ptr("t")})
+	if !errors.Is(err, repository.ErrTaskConflict) {
+		t.Fatalf("err = %v, want ErrTaskConflict", err)
+	}
+}
+
+// 等锁期间另一请求已把 root 移到 other 之下；加锁后的成环校验必须看到这次修改
+func TestTaskUpdate_ChecksCycleAfterLock(t *testing.T) {
+	f := newTaskServiceFixture(treeTasks()...)
+	f.tasks.onLock = func() { f.tasks.tasks["root"].ParentID = ptr("other") }
+
+	_, err := f.svc.Update(context.Background(), "other", &domain.Agent{ID: "owner", CompanyID: "c1"},
+		UpdateTaskInput{ParentID: ptr("child")})
+	if err == nil || err.Error() != "cannot move task under its own subtask" {
+		t.Fatalf("err = %v, want cycle rejection", err)
+	}
+	if f.tasks.tasks["other"].ParentID != nil {
+		t.Error("task moved despite cycle")
+	}
+}
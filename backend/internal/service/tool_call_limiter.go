@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ToolCallLimitConfig MCP 工具调用限流与循环检测配置
+type ToolCallLimitConfig struct {
+	AgentPerMinute     float64            // 每个 Agent 全部工具合计每分钟上限
+	ToolPerMinute      map[string]float64 // 按工具覆盖：每个 Agent 对该工具的每分钟上限
+	LoopWindow         time.Duration      // 循环检测窗口
+	LoopThreshold      int                // 窗口内完全相同（工具 + 参数）的调用达到此次数视为循环
+	ToolLoopThresholds map[string]int     // 按工具覆盖循环阈值
+	Cooldown           time.Duration      // 判定循环后该工具的冷却时间
+}
+
+// DefaultToolCallLimitConfig 默认限流配置；写操作类工具默认更严格
+func DefaultToolCallLimitConfig() *ToolCallLimitConfig {
+	return &ToolCallLimitConfig{
+		AgentPerMinute: 120,
+		ToolPerMinute: map[string]float64{
+			"send_message": 30,
+			"create_task":  20,
+		},
+		LoopWindow:         time.Minute,
+		LoopThreshold:      5,
+		ToolLoopThresholds: map[string]int{},
+		Cooldown:           2 * time.Minute,
+	}
+}
+
+// ParseToolLimits 解析 "tool=n,tool2=m" 形式的按工具配置；n 为 0 表示对该工具关闭此项限制
+func ParseToolLimits(s string) (map[string]float64, error) {
+	out := make(map[string]float64)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid tool limit %q: want tool=n", part)
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid tool limit %q: want a non-negative number", part)
+		}
+		out[strings.TrimSpace(name)] = n
+	}
+	return out, nil
+}
+
+// ToolCallVerdict 限流判定结果
+type ToolCallVerdict struct {
+	Allowed      bool
+	Reason       string
+	RetryAfter   time.Duration
+	LoopDetected bool // 本次调用首次触发循环判定（用于告警，冷却期内不重复）
+	Repeats      int  // 触发循环时窗口内的重复次数
+}
+
+type toolCallRecord struct {
+	fingerprint string
+	at          time.Time
+}
+
+// toolCallSweepInterval 清理空闲限流状态的间隔；桶闲置一分钟即已回满，可直接丢弃
+const toolCallSweepInterval = time.Minute
+
+// ToolCallLimiter 按 Agent / Agent+工具 的令牌桶限流，并检测重复调用循环（进程内状态）
+type ToolCallLimiter struct {
+	cfg *ToolCallLimitConfig
+
+	mu        sync.Mutex
+	buckets   map[string]*TokenBucket     // 容量为每分钟上限，允许一分钟内的突发
+	recent    map[string][]toolCallRecord // agentID -> 窗口内调用
+	cooldowns map[string]time.Time        // agentID/tool -> 冷却截止
+	lastSweep time.Time
+}
+
+func NewToolCallLimiter(cfg *ToolCallLimitConfig) *ToolCallLimiter {
+	if cfg == nil {
+		cfg = DefaultToolCallLimitConfig()
+	}
+	return &ToolCallLimiter{
+		cfg:       cfg,
+		buckets:   make(map[string]*TokenBucket),
+		recent:    make(map[string][]toolCallRecord),
+		cooldowns: make(map[string]time.Time),
+	}
+}
+
+// Check 判定一次调用是否放行，放行时同时计入限流与循环检测
+func (l *ToolCallLimiter) Check(ctx context.Context, agentID, tool string, args json.RawMessage) ToolCallVerdict {
+	now := time.Now()
+	toolKey := agentID + "/" + tool
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	if until, ok := l.cooldowns[toolKey]; ok {
+		if now.Before(until) {
+			return ToolCallVerdict{
+				Reason:     fmt.Sprintf("检测到你在重复调用 %s，已暂停该工具", tool),
+				RetryAfter: until.Sub(now),
+			}
+		}
+		delete(l.cooldowns, toolKey)
+	}
+
+	// 循环检测：窗口内完全相同的调用
+	fp := toolCallFingerprint(tool, args)
+	records := l.recent[agentID][:0]
+	repeats := 1
+	for _, r := range l.recent[agentID] {
+		if now.Sub(r.at) > l.cfg.LoopWindow {
+			continue
+		}
+		records = append(records, r)
+		if r.fingerprint == fp {
+			repeats++
+		}
+	}
+	l.recent[agentID] = records
+	if threshold := l.loopThreshold(tool); threshold > 0 && repeats >= threshold {
+		l.cooldowns[toolKey] = now.Add(l.cfg.Cooldown)
+		return ToolCallVerdict{
+			Reason:       fmt.Sprintf("检测到你在 %s 内以相同参数调用 %s %d 次，已暂停该工具", l.cfg.LoopWindow, tool, repeats),
+			RetryAfter:   l.cfg.Cooldown,
+			LoopDetected: true,
+			Repeats:      repeats,
+		}
+	}
+
+	// 两个桶都有余量才放行并同时扣减，任一被限都不消耗另一个的配额
+	var toolBucket, agentBucket *TokenBucket
+	if perMin := l.cfg.ToolPerMinute[tool]; perMin > 0 {
+		if toolBucket = l.bucket(toolKey, perMin); !toolBucket.Peek() {
+			return ToolCallVerdict{Reason: fmt.Sprintf("%s 调用过于频繁（上限 %.0f 次/分钟）", tool, perMin), RetryAfter: l.retryAfter(perMin)}
+		}
+	}
+	if l.cfg.AgentPerMinute > 0 {
+		if agentBucket = l.bucket(agentID, l.cfg.AgentPerMinute); !agentBucket.Peek() {
+			return ToolCallVerdict{
+				Reason:     fmt.Sprintf("工具调用过于频繁（上限 %.0f 次/分钟）", l.cfg.AgentPerMinute),
+				RetryAfter: l.retryAfter(l.cfg.AgentPerMinute),
+			}
+		}
+	}
+	if toolBucket != nil {
+		toolBucket.Take()
+	}
+	if agentBucket != nil {
+		agentBucket.Take()
+	}
+
+	l.recent[agentID] = append(l.recent[agentID], toolCallRecord{fingerprint: fp, at: now})
+	return ToolCallVerdict{Allowed: true}
+}
+
+func (l *ToolCallLimiter) loopThreshold(tool string) int {
+	if n, ok := l.cfg.ToolLoopThresholds[tool]; ok {
+		return n
+	}
+	return l.cfg.LoopThreshold
+}
+
+// bucket 获取或创建令牌桶
+func (l *ToolCallLimiter) bucket(key string, perMinute float64) *TokenBucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = NewTokenBucket(perMinute, perMinute/60)
+		l.buckets[key] = b
+	}
+	return b
+}
+
+// sweep 定期丢弃已回满的桶、过期的冷却与窗口外的调用记录，避免不再活跃的 Agent 长期占用内存
+func (l *ToolCallLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < toolCallSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.LastRefill()) >= time.Minute {
+			delete(l.buckets, key)
+		}
+	}
+	for key, until := range l.cooldowns {
+		if !now.Before(until) {
+			delete(l.cooldowns, key)
+		}
+	}
+	for agentID, records := range l.recent {
+		if len(records) == 0 || now.Sub(records[len(records)-1].at) > l.cfg.LoopWindow {
+			delete(l.recent, agentID)
+		}
+	}
+}
+
+func (l *ToolCallLimiter) retryAfter(perMinute float64) time.Duration {
+	return time.Duration(float64(time.Minute) / perMinute)
+}
+
+// toolCallFingerprint 工具名 + 规范化参数（键排序）的哈希
+func toolCallFingerprint(tool string, args json.RawMessage) string {
+	canonical := []byte(args)
+	var v any
+	if err := json.Unmarshal(args, &v); err == nil {
+		if b, err := json.Marshal(v); err == nil {
+			canonical = b
+		}
+	}
+	sum := sha256.Sum256(append([]byte(tool+"\x00"), canonical...))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestToolCallLimiter_LoopDetection(t *testing.T) {
+	l := NewToolCallLimiter(&ToolCallLimitConfig{
+		AgentPerMinute:     1000,
+		LoopWindow:         time.Minute,
+		LoopThreshold:      3,
+		ToolLoopThresholds: map[string]int{"list_tasks": 0}, // 0 = 不检测
+		Cooldown:           time.Minute,
+	})
+	ctx := context.Background()
+	args := json.RawMessage(`{"to":"a1","content":"hi"}`)
+	reordered := json.RawMessage(`{"content":"hi", "to":"a1"}`)
+
+	if v := l.Check(ctx, "agent-1", "send_message", args); !v.Allowed {
+		t.Fatalf("1st call should pass: %+v", v)
+	}
+	if v := l.Check(ctx, "agent-1", "send_message", reordered); !v.Allowed {
+		t.Fatalf("2nd call should pass: %+v", v)
+	}
+	v := l.Check(ctx, "agent-1", "send_message", args)
+	if v.Allowed || !v.LoopDetected || v.Repeats != 3 {
+		t.Fatalf("3rd identical call should trip loop detection: %+v", v)
+	}
+	// 冷却期内：拒绝但不重复告警
+	if v := l.Check(ctx, "agent-1", "send_message", json.RawMessage(`{"to":"a2"}`)); v.Allowed || v.LoopDetected {
+		t.Fatalf("tool should be cooling down without re-alert: %+v", v)
+	}
+	// 其他工具、其他 Agent 不受影响
+	if v := l.Check(ctx, "agent-1", "create_task", args); !v.Allowed {
+		t.Fatalf("other tool should pass: %+v", v)
+	}
+	if v := l.Check(ctx, "agent-2", "send_message", args); !v.Allowed {
+		t.Fatalf("other agent should pass: %+v", v)
+	}
+	for i := 0; i < 10; i++ {
+		if v := l.Check(ctx, "agent-1", "list_tasks", nil); !v.Allowed {
+			t.Fatalf("loop detection disabled for list_tasks: %+v", v)
+		}
+	}
+}
+
+func TestToolCallLimiter_RateLimits(t *testing.T) {
+	l := NewToolCallLimiter(&ToolCallLimitConfig{
+		AgentPerMinute: 5,
+		ToolPerMinute:  map[string]float64{"create_task": 2},
+		LoopWindow:     time.Minute,
+	})
+	ctx := context.Background()
+	arg := func(i int) json.RawMessage { return json.RawMessage(fmt.Sprintf(`{"title":"t%d"}`, i)) }
+
+	for i := 0; i < 2; i++ {
+		if v := l.Check(ctx, "agent-1", "create_task", arg(i)); !v.Allowed {
+			t.Fatalf("create_task #%d should pass: %+v", i, v)
+		}
+	}
+	if v := l.Check(ctx, "agent-1", "create_task", arg(2)); v.Allowed || v.RetryAfter <= 0 {
+		t.Fatalf("per-tool limit should apply: %+v", v)
+	}
+	// 工具被限不消耗 Agent 配额：还剩 3 次
+	for i := 0; i < 3; i++ {
+		if v := l.Check(ctx, "agent-1", "list_tasks", arg(i)); !v.Allowed {
+			t.Fatalf("list_tasks #%d should pass: %+v", i, v)
+		}
+	}
+	if v := l.Check(ctx, "agent-1", "list_tasks", arg(9)); v.Allowed {
+		t.Fatalf("per-agent limit should apply: %+v", v)
+	}
+}
+
+func TestToolCallLimiter_AgentLimitKeepsToolQuota(t *testing.T) {
+	l := NewToolCallLimiter(&ToolCallLimitConfig{
+		AgentPerMinute: 2,
+		ToolPerMinute:  map[string]float64{"create_task": 5, "send_message": 0},
+		LoopWindow:     time.Minute,
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if v := l.Check(ctx, "agent-1", "list_tasks", json.RawMessage(fmt.Sprintf(`{"page":%d}`, i))); !v.Allowed {
+			t.Fatalf("list_tasks #%d should pass: %+v", i, v)
+		}
+	}
+	if v := l.Check(ctx, "agent-1", "create_task", nil); v.Allowed {
+		t.Fatalf("per-agent limit should apply: %+v", v)
+	}
+	// Agent 被限不消耗工具配额
+	if b := l.buckets["agent-1/create_task"]; b == nil || b.tokens != 5 {
+		t.Errorf("create_task bucket = %+v, want 5 tokens left", b)
+	}
+	// 上限为 0 的工具不单独限流，也不建桶
+	if _, ok := l.buckets["agent-1/send_message"]; ok {
+		t.Error("send_message=0 should not create a bucket")
+	}
+}
+
+func TestToolCallLimiter_SweepsIdleState(t *testing.T) {
+	l := NewToolCallLimiter(&ToolCallLimitConfig{AgentPerMinute: 10, LoopWindow: time.Minute, LoopThreshold: 3})
+	ctx := context.Background()
+	l.Check(ctx, "idle", "list_tasks", nil)
+	l.Check(ctx, "active", "list_tasks", nil)
+
+	// idle 两分钟前最后一次调用
+	past := time.Now().Add(-2 * time.Minute)
+	l.buckets["idle"].lastRefill = past
+	l.recent["idle"][0].at = past
+	l.cooldowns["idle/list_tasks"] = past
+	l.lastSweep = past
+
+	l.Check(ctx, "active", "get_task", nil)
+	if _, ok := l.buckets["idle"]; ok {
+		t.Error("idle bucket was not evicted")
+	}
+	if _, ok := l.recent["idle"]; ok {
+		t.Error("idle call history was not evicted")
+	}
+	if _, ok := l.cooldowns["idle/list_tasks"]; ok {
+		t.Error("expired cooldown was not evicted")
+	}
+	if l.buckets["active"] == nil || len(l.recent["active"]) != 2 {
+		t.Error("active agent state should be kept")
+	}
+}
+
+func TestParseToolLimits(t *testing.T) {
+	got, err := ParseToolLimits(" send_message=30, create_task=5 ,list_tasks=0")
+	if err != nil || got["send_message"] != 30 || got["create_task"] != 5 || got["list_tasks"] != 0 || len(got) != 3 {
+		t.Fatalf("unexpected: %v %v", got, err)
+	}
+	for _, bad := range []string{"send_message", "x=abc", "x=-1"} {
+		if _, err := ParseToolLimits(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
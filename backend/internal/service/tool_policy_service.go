@@ -0,0 +1,620 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+// toolPolicyCacheTTL 公司策略缓存时长；写操作会立即失效
+const toolPolicyCacheTTL = 30 * time.Second
+
+// ToolPolicyDecision 工具权限评估结果
+type ToolPolicyDecision struct {
+	Allowed          bool                         `json:"allowed"`
+	RequiresApproval bool                         `json:"requires_approval,omitempty"` // 允许，但需审批后执行
+	Source           string                       `json:"source"`                      // policy / baseline
+	Level            domain.ToolPolicySubjectType `json:"level,omitempty"`
+	PolicyID         string                       `json:"policy_id,omitempty"`
+	Reason           string                       `json:"reason"`
+}
+
+type cachedToolPolicies struct {
+	list     []*domain.ToolPolicy
+	loadedAt time.Time
+}
+
+// ToolPolicyService 管理声明式工具权限策略，并在 MCP 调用时评估
+type ToolPolicyService struct {
+	repo   repository.ToolPolicyRepo
+	orgSvc *OrganizationService
+	tx     repository.Transactor // nil 时不开启事务
+
+	mu    sync.RWMutex
+	cache map[string]cachedToolPolicies
+}
+
+func NewToolPolicyService(repo repository.ToolPolicyRepo, orgSvc *OrganizationService, tx repository.Transactor) *ToolPolicyService {
+	return &ToolPolicyService{
+		repo:   repo,
+		orgSvc: orgSvc,
+		tx:     tx,
+		cache:  make(map[string]cachedToolPolicies),
+	}
+}
+
+// ToolPolicyInput 创建/更新策略参数
+type ToolPolicyInput struct {
+	Name        string
+	Description string
+	SubjectType domain.ToolPolicySubjectType
+	SubjectID   string
+	Tool        string
+	Effect      domain.ToolPolicyEffect
+	Conditions  domain.ToolArgConditions
+	IsActive    *bool
+}
+
+// ── CRUD ─────────────────────────────────────────────────
+
+func (s *ToolPolicyService) List(ctx context.Context, companyID string) ([]*domain.ToolPolicy, error) {
+	return s.repo.ListByCompany(ctx, companyID)
+}
+
+func (s *ToolPolicyService) Get(ctx context.Context, companyID, id string) (*domain.ToolPolicy, error) {
+	p, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, fmt.Errorf("tool policy not found")
+	}
+	if p.CompanyID != companyID {
+		return nil, fmt.Errorf("forbidden")
+	}
+	return p, nil
+}
+
+func (s *ToolPolicyService) Create(ctx context.Context, companyID, actorID string, in ToolPolicyInput) (*domain.ToolPolicy, error) {
+	if err := validateToolPolicyInput(&in); err != nil {
+		return nil, err
+	}
+	active := true
+	if in.IsActive != nil {
+		active = *in.IsActive
+	}
+	p := &domain.ToolPolicy{
+		CompanyID:   companyID,
+		Name:        in.Name,
+		Description: in.Description,
+		SubjectType: in.SubjectType,
+		SubjectID:   in.SubjectID,
+		Tool:        in.Tool,
+		Effect:      in.Effect,
+		Conditions:  in.Conditions,
+		IsActive:    active,
+		CreatedBy:   nullableString(actorID),
+	}
+	var created *domain.ToolPolicy
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.Create(ctx, p); err != nil {
+			return err
+		}
+		var err error
+		if created, err = s.repo.GetByID(ctx, p.ID); err != nil {
+			return err
+		}
+		return s.recordChange(ctx, companyID, p.ID, actorID, domain.ToolPolicyChangeCreate, nil, created)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(companyID)
+	return created, nil
+}
+
+func (s *ToolPolicyService) Update(ctx context.Context, companyID, actorID, id string, in ToolPolicyInput) (*domain.ToolPolicy, error) {
+	before, err := s.Get(ctx, companyID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateToolPolicyInput(&in); err != nil {
+		return nil, err
+	}
+	p := *before
+	p.Name = in.Name
+	p.Description = in.Description
+	p.SubjectType = in.SubjectType
+	p.SubjectID = in.SubjectID
+	p.Tool = in.Tool
+	p.Effect = in.Effect
+	p.Conditions = in.Conditions
+	if in.IsActive != nil {
+		p.IsActive = *in.IsActive
+	}
+	var updated *domain.ToolPolicy
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.Update(ctx, &p); err != nil {
+			return err
+		}
+		var err error
+		if updated, err = s.repo.GetByID(ctx, id); err != nil {
+			return err
+		}
+		return s.recordChange(ctx, companyID, id, actorID, domain.ToolPolicyChangeUpdate, before, updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(companyID)
+	return updated, nil
+}
+
+func (s *ToolPolicyService) Delete(ctx context.Context, companyID, actorID, id string) error {
+	before, err := s.Get(ctx, companyID, id)
+	if err != nil {
+		return err
+	}
+	err = s.withinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.Delete(ctx, id); err != nil {
+			return err
+		}
+		return s.recordChange(ctx, companyID, id, actorID, domain.ToolPolicyChangeDelete, before, nil)
+	})
+	if err != nil {
+		return err
+	}
+	s.invalidate(companyID)
+	return nil
+}
+
+func (s *ToolPolicyService) ListChanges(ctx context.Context, companyID, policyID string, limit, offset int) ([]*domain.ToolPolicyChange, int, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return s.repo.ListChanges(ctx, companyID, policyID, limit, offset)
+}
+
+// recordChange 写入策略变更审计；与策略写入处于同一事务，失败时整个操作回滚
+func (s *ToolPolicyService) recordChange(ctx context.Context, companyID, policyID, actorID string, action domain.ToolPolicyChangeAction, before, after *domain.ToolPolicy) error {
+	change := &domain.ToolPolicyChange{
+		CompanyID: companyID,
+		PolicyID:  policyID,
+		ActorID:   nullableString(actorID),
+		Action:    action,
+	}
+	if before != nil {
+		change.Before, _ = json.Marshal(before)
+	}
+	if after != nil {
+		change.After, _ = json.Marshal(after)
+	}
+	if err := s.repo.CreateChange(ctx, change); err != nil {
+		return fmt.Errorf("record tool policy change: %w", err)
+	}
+	return nil
+}
+
+// withinTx s.tx 为 nil 时直接执行 fn
+func (s *ToolPolicyService) withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.tx != nil {
+		return s.tx.WithinTx(ctx, fn)
+	}
+	return fn(ctx)
+}
+
+// ── 缓存 ─────────────────────────────────────────────────
+
+// PoliciesFor 返回公司当前策略（带缓存）
+func (s *ToolPolicyService) PoliciesFor(ctx context.Context, companyID string) ([]*domain.ToolPolicy, error) {
+	s.mu.RLock()
+	c, ok := s.cache[companyID]
+	s.mu.RUnlock()
+	if ok && time.Since(c.loadedAt) < toolPolicyCacheTTL {
+		return c.list, nil
+	}
+
+	list, err := s.repo.ListByCompany(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.cache[companyID] = cachedToolPolicies{list: list, loadedAt: time.Now()}
+	s.mu.Unlock()
+	return list, nil
+}
+
+func (s *ToolPolicyService) invalidate(companyID string) {
+	s.mu.Lock()
+	delete(s.cache, companyID)
+	s.mu.Unlock()
+}
+
+// ── 校验 ─────────────────────────────────────────────────
+
+func validateToolPolicyInput(in *ToolPolicyInput) error {
+	in.Name = strings.TrimSpace(in.Name)
+	in.Tool = strings.TrimSpace(in.Tool)
+	in.SubjectID = strings.TrimSpace(in.SubjectID)
+	if in.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if in.Tool == "" {
+		return fmt.Errorf("tool is required")
+	}
+	if _, err := path.Match(in.Tool, ""); err != nil {
+		return fmt.Errorf("invalid tool pattern: %s", in.Tool)
+	}
+	switch in.SubjectType {
+	case domain.ToolPolicySubjectCompany:
+		in.SubjectID = ""
+	case domain.ToolPolicySubjectRoleType, domain.ToolPolicySubjectDepartment,
+		domain.ToolPolicySubjectPosition, domain.ToolPolicySubjectAgent:
+		if in.SubjectID == "" {
+			return fmt.Errorf("subject_id is required for subject_type %s", in.SubjectType)
+		}
+	default:
+		return fmt.Errorf("invalid subject_type: %s", in.SubjectType)
+	}
+	switch in.Effect {
+	case domain.ToolPolicyAllow, domain.ToolPolicyDeny, domain.ToolPolicyRequireApproval:
+	default:
+		return fmt.Errorf("invalid effect: %s", in.Effect)
+	}
+	for i, cond := range in.Conditions {
+		if strings.TrimSpace(cond.Arg) == "" {
+			return fmt.Errorf("conditions[%d]: arg is required", i)
+		}
+		if len(cond.Values) == 0 {
+			return fmt.Errorf("conditions[%d]: values is required", i)
+		}
+		switch cond.Op {
+		case domain.ToolArgIn, domain.ToolArgNotIn, domain.ToolArgPrefix:
+		case domain.ToolArgGlob:
+			for _, v := range cond.Values {
+				if _, err := path.Match(v, ""); err != nil {
+					return fmt.Errorf("conditions[%d]: invalid glob %q", i, v)
+				}
+			}
+		case domain.ToolArgRegex:
+			for _, v := range cond.Values {
+				if _, err := regexp.Compile(v); err != nil {
+					return fmt.Errorf("conditions[%d]: invalid regex %q", i, v)
+				}
+			}
+		default:
+			return fmt.Errorf("conditions[%d]: invalid op %s", i, cond.Op)
+		}
+	}
+	if in.Conditions == nil {
+		in.Conditions = domain.ToolArgConditions{}
+	}
+	return nil
+}
+
+// ── 评估 ─────────────────────────────────────────────────
+
+// EvaluateToolCall 评估一次工具调用。baseline 为内置权限（ToolDef.Perm）的结果，
+// 当没有任何策略命中时回退到 baseline。
+func EvaluateToolCall(policies []*domain.ToolPolicy, agent *domain.Agent, tool string, args json.RawMessage, baseline bool) ToolPolicyDecision {
+	var parsed map[string]any
+	if len(args) > 0 {
+		_ = json.Unmarshal(args, &parsed)
+	}
+	return evaluateToolPolicies(policies, agent, tool, parsed, false, baseline)
+}
+
+// ToolVisible 判断工具是否应出现在 tools/list 中。
+// 列表阶段没有参数：带条件的 allow 视为可见，带条件的 deny 不隐藏工具。
+func ToolVisible(policies []*domain.ToolPolicy, agent *domain.Agent, tool string, baseline bool) bool {
+	return evaluateToolPolicies(policies, agent, tool, nil, true, baseline).Allowed
+}
+
+func evaluateToolPolicies(policies []*domain.ToolPolicy, agent *domain.Agent, tool string, args map[string]any, listing, baseline bool) ToolPolicyDecision {
+	for _, level := range domain.ToolPolicySubjectOrder {
+		var allows, denies, gates []*domain.ToolPolicy
+		for _, p := range policies {
+			if !p.IsActive || p.SubjectType != level || !toolPolicyMatchesTool(p.Tool, tool) || !toolPolicyMatchesSubject(p, agent) {
+				continue
+			}
+			switch {
+			case p.Effect == domain.ToolPolicyDeny || p.Conditions.Invalid():
+				denies = append(denies, p) // 条件已损坏的策略一律按 deny 处理
+			case p.Effect == domain.ToolPolicyRequireApproval:
+				gates = append(gates, p)
+			default:
+				allows = append(allows, p)
+			}
+		}
+
+		// 同一层级 deny > require_approval > allow
+		for _, p := range denies {
+			if p.Conditions.Invalid() {
+				return ToolPolicyDecision{
+					Allowed: false, Source: "policy", Level: level, PolicyID: p.ID,
+					Reason: fmt.Sprintf("策略「%s」的参数条件无法解析，按禁止处理", p.Name),
+				}
+			}
+			if len(p.Conditions) == 0 || (!listing && toolArgsMatch(p.Conditions, args)) {
+				return ToolPolicyDecision{
+					Allowed: false, Source: "policy", Level: level, PolicyID: p.ID,
+					Reason: fmt.Sprintf("策略「%s」禁止使用工具 %s", p.Name, tool),
+				}
+			}
+		}
+		// 审批闸门与 deny 一样：条件未命中时不做决定
+		for _, p := range gates {
+			if len(p.Conditions) == 0 || (!listing && toolArgsMatch(p.Conditions, args)) {
+				return ToolPolicyDecision{
+					Allowed: true, RequiresApproval: !listing, Source: "policy", Level: level, PolicyID: p.ID,
+					Reason: fmt.Sprintf("策略「%s」要求调用工具 %s 前经过审批", p.Name, tool),
+				}
+			}
+		}
+		if len(allows) == 0 {
+			continue // 仅有未命中的条件 deny / 审批闸门，交给更宽泛的层级
+		}
+		for _, p := range allows {
+			if listing || len(p.Conditions) == 0 || toolArgsMatch(p.Conditions, args) {
+				return ToolPolicyDecision{
+					Allowed: true, Source: "policy", Level: level, PolicyID: p.ID,
+					Reason: fmt.Sprintf("策略「%s」允许使用工具 %s", p.Name, tool),
+				}
+			}
+		}
+		return ToolPolicyDecision{
+			Allowed: false, Source: "policy", Level: level, PolicyID: allows[0].ID,
+			Reason: fmt.Sprintf("调用参数不满足策略「%s」的条件", allows[0].Name),
+		}
+	}
+
+	if baseline {
+		return ToolPolicyDecision{Allowed: true, Source: "baseline", Reason: "内置权限允许"}
+	}
+	return ToolPolicyDecision{Allowed: false, Source: "baseline", Reason: "内置权限不足"}
+}
+
+func toolPolicyMatchesTool(pattern, tool string) bool {
+	if pattern == tool {
+		return true
+	}
+	ok, _ := path.Match(pattern, tool)
+	return ok
+}
+
+func toolPolicyMatchesSubject(p *domain.ToolPolicy, agent *domain.Agent) bool {
+	switch p.SubjectType {
+	case domain.ToolPolicySubjectCompany:
+		return true
+	case domain.ToolPolicySubjectRoleType:
+		return p.SubjectID == string(agent.RoleType)
+	case domain.ToolPolicySubjectPosition:
+		return p.SubjectID == string(agent.Position)
+	case domain.ToolPolicySubjectDepartment:
+		if agent.DepartmentID != nil && *agent.DepartmentID == p.SubjectID {
+			return true
+		}
+		return p.SubjectID == agent.DepartmentSlug()
+	case domain.ToolPolicySubjectAgent:
+		return p.SubjectID == agent.ID
+	}
+	return false
+}
+
+// toolArgsMatch 全部条件满足才返回 true
+func toolArgsMatch(conds domain.ToolArgConditions, args map[string]any) bool {
+	for _, cond := range conds {
+		values, present := lookupToolArg(args, cond.Arg)
+		if !present {
+			// 缺失参数：not_in 视为满足，其余视为不满足
+			if cond.Op == domain.ToolArgNotIn {
+				continue
+			}
+			return false
+		}
+		for _, v := range values {
+			if !toolArgValueMatches(cond, v) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func toolArgValueMatches(cond domain.ToolArgCondition, v string) bool {
+	switch cond.Op {
+	case domain.ToolArgIn:
+		return containsString(cond.Values, v)
+	case domain.ToolArgNotIn:
+		return !containsString(cond.Values, v)
+	case domain.ToolArgPrefix:
+		for _, p := range cond.Values {
+			if strings.HasPrefix(v, p) {
+				return true
+			}
+		}
+	case domain.ToolArgGlob:
+		for _, p := range cond.Values {
+			if ok, _ := path.Match(p, v); ok {
+				return true
+			}
+		}
+	case domain.ToolArgRegex:
+		for _, re := range cond.Regexps() {
+			if re.MatchString(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lookupToolArg 按 a.b 路径取参数；数组参数展开为多个值（每个值都需满足条件）
+func lookupToolArg(args map[string]any, key string) ([]string, bool) {
+	var cur any = args
+	for _, part := range strings.Split(key, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		cur, ok = m[part]
+		if !ok || cur == nil {
+			return nil, false
+		}
+	}
+	switch v := cur.(type) {
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			out = append(out, toolArgString(item))
+		}
+		return out, true
+	default:
+		return []string{toolArgString(v)}, true
+	}
+}
+
+func toolArgString(v any) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	default:
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func nullableString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// ── 审批闸门 ─────────────────────────────────────────────
+
+// toolCallApprovalPayload 写入 ApprovalRequest.Payload，供审批人查看
+type toolCallApprovalPayload struct {
+	CallID    string          `json:"call_id"`
+	Tool      string          `json:"tool"`
+	Arguments json.RawMessage `json:"arguments"`
+	PolicyID  string          `json:"policy_id,omitempty"`
+}
+
+// RequestApproval 为命中 require_approval 的调用创建待审批记录和审批请求（走上级审批链）。
+// 两条记录在同一事务中写入，避免留下找不到调用的审批
+func (s *ToolPolicyService) RequestApproval(ctx context.Context, agent *domain.Agent, tool string, args json.RawMessage, decision ToolPolicyDecision, reason string) (*domain.ToolCallRequest, error) {
+	if len(args) == 0 {
+		args = json.RawMessage(`{}`)
+	}
+	call := &domain.ToolCallRequest{
+		ID:        uuid.New().String(),
+		CompanyID: agent.CompanyID,
+		AgentID:   agent.ID,
+		Tool:      tool,
+		Arguments: args,
+		PolicyID:  nullableString(decision.PolicyID),
+		Status:    domain.ToolCallPending,
+	}
+	payload, _ := json.Marshal(toolCallApprovalPayload{
+		CallID: call.ID, Tool: tool, Arguments: args, PolicyID: decision.PolicyID,
+	})
+	if strings.TrimSpace(reason) == "" {
+		reason = decision.Reason
+	}
+	run := func(ctx context.Context) error {
+		approval, err := s.orgSvc.CreateApproval(ctx, agent.CompanyID, agent.ID, domain.ApprovalToolCall, payload, reason)
+		if err != nil {
+			return fmt.Errorf("create tool call approval: %w", err)
+		}
+		call.ApprovalID = &approval.ID
+		return s.repo.CreateCall(ctx, call)
+	}
+	if err := s.withinTx(ctx, run); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+// GetCall 获取 Agent 自己的待审批调用
+func (s *ToolPolicyService) GetCall(ctx context.Context, agentID, callID string) (*domain.ToolCallRequest, error) {
+	call, err := s.repo.GetCall(ctx, callID)
+	if err != nil {
+		return nil, err
+	}
+	if call == nil || call.AgentID != agentID {
+		return nil, fmt.Errorf("tool call not found")
+	}
+	return call, nil
+}
+
+func (s *ToolPolicyService) ListCalls(ctx context.Context, agentID string, limit int) ([]*domain.ToolCallRequest, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+	return s.repo.ListCallsByAgent(ctx, agentID, limit)
+}
+
+// BeginApprovedCall 审批通过后认领待执行调用；已被处理或不存在时返回 nil
+func (s *ToolPolicyService) BeginApprovedCall(ctx context.Context, approvalID string) (*domain.ToolCallRequest, error) {
+	call, err := s.repo.GetCallByApprovalID(ctx, approvalID)
+	if err != nil || call == nil {
+		return nil, err
+	}
+	ok, err := s.repo.TransitionCall(ctx, call.ID, domain.ToolCallPending, domain.ToolCallRunning)
+	if err != nil || !ok {
+		return nil, err
+	}
+	call.Status = domain.ToolCallRunning
+	return call, nil
+}
+
+// RejectCall 审批被拒后关闭待执行调用；已被处理或不存在时返回 nil
+func (s *ToolPolicyService) RejectCall(ctx context.Context, approvalID string) (*domain.ToolCallRequest, error) {
+	call, err := s.repo.GetCallByApprovalID(ctx, approvalID)
+	if err != nil || call == nil {
+		return nil, err
+	}
+	ok, err := s.repo.TransitionCall(ctx, call.ID, domain.ToolCallPending, domain.ToolCallRejected)
+	if err != nil || !ok {
+		return nil, err
+	}
+	call.Status = domain.ToolCallRejected
+	return call, nil
+}
+
+// FinishCall 记录执行结果
+func (s *ToolPolicyService) FinishCall(ctx context.Context, callID string, result json.RawMessage, isError bool) error {
+	status := domain.ToolCallSucceeded
+	if isError {
+		status = domain.ToolCallFailed
+	}
+	return s.repo.FinishCall(ctx, callID, status, result, isError)
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/linkclaw/backend/internal/domain"
+	"github.com/linkclaw/backend/internal/repository"
+)
+
+func TestEvaluateToolCall(t *testing.T) {
+	deptID := "dept-eng"
+	agent := &domain.Agent{
+		ID:           "agent-1",
+		CompanyID:    "c1",
+		RoleType:     domain.RoleEmployee,
+		Position:     domain.PositionBackendDev,
+		DepartmentID: &deptID,
+	}
+
+	denyDocker := &domain.ToolPolicy{ID: "p1", Name: "禁止 docker", SubjectType: domain.ToolPolicySubjectCompany,
+		Tool: "docker_*", Effect: domain.ToolPolicyDeny, IsActive: true}
+	allowDockerForBackend := &domain.ToolPolicy{ID: "p2", Name: "后端可用 docker", SubjectType: domain.ToolPolicySubjectPosition,
+		SubjectID: "backend_dev", Tool: "docker_*", Effect: domain.ToolPolicyAllow, IsActive: true}
+	generalOnly := &domain.ToolPolicy{ID: "p3", Name: "仅限 general", SubjectType: domain.ToolPolicySubjectDepartment,
+		SubjectID: "engineering", Tool: "send_message", Effect: domain.ToolPolicyAllow, IsActive: true,
+		Conditions: domain.ToolArgConditions{{Arg: "channel", Op: domain.ToolArgIn, Values: []string{"general"}}}}
+	denyRmForAgent := &domain.ToolPolicy{ID: "p4", Name: "禁止删除容器", SubjectType: domain.ToolPolicySubjectAgent,
+		SubjectID: "agent-1", Tool: "docker_rm", Effect: domain.ToolPolicyDeny, IsActive: true}
+	inactive := &domain.ToolPolicy{ID: "p5", Name: "停用", SubjectType: domain.ToolPolicySubjectCompany,
+		Tool: "*", Effect: domain.ToolPolicyDeny, IsActive: false}
+
+	tests := []struct {
+		name      string
+		policies  []*domain.ToolPolicy
+		tool      string
+		args      string
+		baseline  bool
+		wantAllow bool
+		wantID    string
+	}{
+		{name: "无策略回退内置权限", tool: "hire", baseline: false, wantAllow: false},
+		{name: "公司级 deny", policies: []*domain.ToolPolicy{denyDocker}, tool: "docker_run", baseline: true, wantAllow: false, wantID: "p1"},
+		{name: "职位级 allow 覆盖公司级 deny", policies: []*domain.ToolPolicy{denyDocker, allowDockerForBackend}, tool: "docker_run", baseline: false, wantAllow: true, wantID: "p2"},
+		{name: "Agent 级 deny 最优先", policies: []*domain.ToolPolicy{denyDocker, allowDockerForBackend, denyRmForAgent}, tool: "docker_rm", baseline: true, wantAllow: false, wantID: "p4"},
+		{name: "参数条件满足", policies: []*domain.ToolPolicy{generalOnly}, tool: "send_message", args: `{"channel":"general"}`, baseline: true, wantAllow: true, wantID: "p3"},
+		{name: "参数条件不满足", policies: []*domain.ToolPolicy{generalOnly}, tool: "send_message", args: `{"channel":"finance"}`, baseline: true, wantAllow: false, wantID: "p3"},
+		{name: "缺失参数视为不满足", policies: []*domain.ToolPolicy{generalOnly}, tool: "send_message", args: `{"receiver_id":"x"}`, baseline: true, wantAllow: false, wantID: "p3"},
+		{name: "停用策略忽略", policies: []*domain.ToolPolicy{inactive}, tool: "send_message", baseline: true, wantAllow: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args json.RawMessage
+			if tt.args != "" {
+				args = json.RawMessage(tt.args)
+			}
+			got := EvaluateToolCall(tt.policies, agent, tt.tool, args, tt.baseline)
+			if got.Allowed != tt.wantAllow {
+				t.Fatalf("Allowed = %v, want %v (%s)", got.Allowed, tt.wantAllow, got.Reason)
+			}
+			if got.PolicyID != tt.wantID {
+				t.Fatalf("PolicyID = %q, want %q", got.PolicyID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestToolVisible_ConditionalPolicies(t *testing.T) {
+	agent := &domain.Agent{ID: "a1", RoleType: domain.RoleEmployee, Position: domain.PositionQAEngineer}
+	policies := []*domain.ToolPolicy{
+		{ID: "p1", Name: "条件 allow", SubjectType: domain.ToolPolicySubjectRoleType, SubjectID: "employee",
+			Tool: "hire", Effect: domain.ToolPolicyAllow, IsActive: true,
+			Conditions: domain.ToolArgConditions{{Arg: "position", Op: domain.ToolArgPrefix, Values: []string{"qa"}}}},
+		{ID: "p2", Name: "条件 deny", SubjectType: domain.ToolPolicySubjectCompany,
+			Tool: "send_message", Effect: domain.ToolPolicyDeny, IsActive: true,
+			Conditions: domain.ToolArgConditions{{Arg: "channel", Op: domain.ToolArgIn, Values: []string{"finance"}}}},
+	}
+	if !ToolVisible(policies, agent, "hire", false) {
+		t.Fatal("带条件的 allow 应使工具可见")
+	}
+	if !ToolVisible(policies, agent, "send_message", true) {
+		t.Fatal("带条件的 deny 不应隐藏工具")
+	}
+}
+
+func TestEvaluateToolCall_RequireApproval(t *testing.T) {
+	agent := &domain.Agent{ID: "a1", RoleType: domain.RoleHR, Position: domain.PositionHRManager}
+	policies := []*domain.ToolPolicy{
+		{ID: "allow", Name: "HR 可开除", SubjectType: domain.ToolPolicySubjectRoleType, SubjectID: "hr",
+			Tool: "fire", Effect: domain.ToolPolicyAllow, IsActive: true},
+		{ID: "gate", Name: "开除需审批", SubjectType: domain.ToolPolicySubjectRoleType, SubjectID: "hr",
+			Tool: "fire", Effect: domain.ToolPolicyRequireApproval, IsActive: true},
+		{ID: "ssh-gate", Name: "生产机需审批", SubjectType: domain.ToolPolicySubjectCompany,
+			Tool: "ssh_exec", Effect: domain.ToolPolicyRequireApproval, IsActive: true,
+			Conditions: domain.ToolArgConditions{{Arg: "host", Op: domain.ToolArgPrefix, Values: []string{"prod-"}}}},
+	}
+
+	got := EvaluateToolCall(policies, agent, "fire", json.RawMessage(`{"agent_id":"x"}`), true)
+	if !got.Allowed || !got.RequiresApproval || got.PolicyID != "gate" {
+		t.Fatalf("同层级审批闸门应优先于 allow: %+v", got)
+	}
+
+	got = EvaluateToolCall(policies, agent, "ssh_exec", json.RawMessage(`{"host":"prod-db"}`), true)
+	if !got.RequiresApproval || got.PolicyID != "ssh-gate" {
+		t.Fatalf("命中条件应要求审批: %+v", got)
+	}
+
+	got = EvaluateToolCall(policies, agent, "ssh_exec", json.RawMessage(`{"host":"dev-1"}`), true)
+	if !got.Allowed || got.RequiresApproval || got.Source != "baseline" {
+		t.Fatalf("未命中条件应回退内置权限: %+v", got)
+	}
+
+	if !ToolVisible(policies, agent, "fire", false) {
+		t.Fatal("需审批的工具应在列表中可见")
+	}
+}
+
+// scannedConditions 模拟从数据库读取 conditions 列
+func scannedConditions(t *testing.T, raw string) domain.ToolArgConditions {
+	t.Helper()
+	var c domain.ToolArgConditions
+	if err := c.Scan([]byte(raw)); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestEvaluateToolCall_RegexCondition(t *testing.T) {
+	agent := &domain.Agent{ID: "a1", RoleType: domain.RoleEmployee}
+	policies := []*domain.ToolPolicy{
+		{ID: "deny", Name: "禁止删除生产库", SubjectType: domain.ToolPolicySubjectCompany, Tool: "db_drop",
+			Effect: domain.ToolPolicyDeny, IsActive: true,
+			Conditions: scannedConditions(t, `[{"arg":"db","op":"regex","values":["^prod[-_]"]}]`)},
+	}
+	if got := EvaluateToolCall(policies, agent, "db_drop", json.RawMessage(`{"db":"prod_orders"}`), true); got.Allowed {
+		t.Fatalf("命中正则应禁止: %+v", got)
+	}
+	if got := EvaluateToolCall(policies, agent, "db_drop", json.RawMessage(`{"db":"staging"}`), true); !got.Allowed {
+		t.Fatalf("未命中正则应回退内置权限: %+v", got)
+	}
+}
+
+func TestEvaluateToolCall_InvalidStoredConditionsDeny(t *testing.T) {
+	agent := &domain.Agent{ID: "a1", RoleType: domain.RoleEmployee}
+	for name, raw := range map[string]string{
+		"bad json":  `{"arg":`,
+		"bad regex": `[{"arg":"db","op":"regex","values":["("]}]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			conds := scannedConditions(t, raw)
+			if !conds.Invalid() {
+				t.Fatalf("conditions = %+v, want invalid", conds)
+			}
+			policies := []*domain.ToolPolicy{
+				{ID: "p1", Name: "仅限测试库", SubjectType: domain.ToolPolicySubjectCompany, Tool: "db_drop",
+					Effect: domain.ToolPolicyAllow, IsActive: true, Conditions: conds},
+			}
+			got := EvaluateToolCall(policies, agent, "db_drop", json.RawMessage(`{"db":"prod"}`), true)
+			if got.Allowed || got.PolicyID != "p1" {
+				t.Fatalf("损坏的策略应按 deny 处理: %+v", got)
+			}
+			if ToolVisible(policies, agent, "db_drop", true) {
+				t.Fatal("损坏的策略应隐藏工具")
+			}
+		})
+	}
+}
+
+// memToolPolicyRepo 内存策略与变更记录；changeErr 非 nil 时审计写入失败
+type memToolPolicyRepo struct {
+	repository.ToolPolicyRepo
+	policies  map[string]*domain.ToolPolicy
+	changes   []*domain.ToolPolicyChange
+	changeErr error
+}
+
+func (r *memToolPolicyRepo) Create(_ context.Context, p *domain.ToolPolicy) error {
+	p.ID = uuid.New().String()
+	cp := *p
+	r.policies[p.ID] = &cp
+	return nil
+}
+
+func (r *memToolPolicyRepo) GetByID(_ context.Context, id string) (*domain.ToolPolicy, error) {
+	if p, ok := r.policies[id]; ok {
+		cp := *p
+		return &cp, nil
+	}
+	return nil, nil
+}
+
+func (r *memToolPolicyRepo) Delete(_ context.Context, id string) error {
+	delete(r.policies, id)
+	return nil
+}
+
+func (r *memToolPolicyRepo) CreateChange(_ context.Context, c *domain.ToolPolicyChange) error {
+	if r.changeErr != nil {
+		return r.changeErr
+	}
+	r.changes = append(r.changes, c)
+	return nil
+}
+
+// policySnapshotTx fn 失败时恢复策略表快照
+type policySnapshotTx struct{ repo *memToolPolicyRepo }
+
+func (tx policySnapshotTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	saved := make(map[string]*domain.ToolPolicy, len(tx.repo.policies))
+	for id, p := range tx.repo.policies {
+		saved[id] = p
+	}
+	if err := fn(ctx); err != nil {
+		tx.repo.policies = saved
+		return err
+	}
+	return nil
+}
+
+func TestToolPolicyWrites_FailWithAudit(t *testing.T) {
+	repo := &memToolPolicyRepo{policies: map[string]*domain.ToolPolicy{}}
+	svc := NewToolPolicyService(repo, nil, policySnapshotTx{repo})
+	ctx := context.Background()
+	in := ToolPolicyInput{Name: "禁止 docker", SubjectType: domain.ToolPolicySubjectCompany, Tool: "docker_*", Effect: domain.ToolPolicyDeny}
+
+	repo.changeErr = errors.New("db down")
+	if _, err := svc.Create(ctx, "c1", "owner", in); err == nil {
+		t.Fatal("create should fail when the audit row cannot be written")
+	}
+	if len(repo.policies) != 0 {
+		t.Fatalf("policy kept without an audit row: %v", repo.policies)
+	}
+
+	repo.changeErr = nil
+	p, err := svc.Create(ctx, "c1", "owner", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.changes) != 1 || repo.changes[0].Action != domain.ToolPolicyChangeCreate {
+		t.Fatalf("changes = %+v", repo.changes)
+	}
+
+	repo.changeErr = errors.New("db down")
+	if err := svc.Delete(ctx, "c1", "owner", p.ID); err == nil {
+		t.Fatal("delete should fail when the audit row cannot be written")
+	}
+	if repo.policies[p.ID] == nil {
+		t.Error("policy deleted without an audit row")
+	}
+}
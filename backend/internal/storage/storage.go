@@ -0,0 +1,56 @@
+// Package storage 是附件等二进制文件的存储抽象，提供本地磁盘与 S3 兼容（含 MinIO）两种后端。
+// key 为以 / 分隔的相对路径，如 "tasks/<task_id>/<file>"。
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrNotFound key 不存在
+var ErrNotFound = errors.New("object not found")
+
+// Storage 文件存储后端
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get 返回文件内容，调用方负责关闭；不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除文件；不存在视为成功
+	Delete(ctx context.Context, key string) error
+}
+
+// Config 存储后端配置
+type Config struct {
+	Backend  string // local（默认）/ s3
+	LocalDir string
+	S3       S3Config
+}
+
+// New 按配置创建存储后端
+func New(cfg Config) (Storage, error) {
+	switch cfg.Backend {
+	case "", "local":
+		return NewLocal(cfg.LocalDir), nil
+	case "s3":
+		return NewS3(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
+
+// cleanKey 校验 key，拒绝绝对路径与 .. 段
+func cleanKey(key string) (string, error) {
+	key = strings.TrimPrefix(key, "/")
+	if key == "" {
+		return "", fmt.Errorf("empty storage key")
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return "", fmt.Errorf("invalid storage key %q", key)
+		}
+	}
+	return key, nil
+}
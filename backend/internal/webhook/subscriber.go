@@ -31,10 +31,23 @@ func (s *Subscriber) Start() {
 		event.AgentStatus,
 		event.TaskCreated,
 		event.TaskUpdated,
+		event.TaskOverdue,
+		event.TaskSLABreached,
+		event.TaskCommented,
 		event.MessageNew,
+		event.MessageEdited,
+		event.MessageDeleted,
+		event.MessageReaction,
+		event.MessagePinned,
+		event.MessageAction,
+		event.ChannelCreated,
+		event.ChannelUpdated,
+		event.ChannelMemberAdded,
+		event.ChannelMemberLeft,
 		event.ApprovalApproved,
 		event.BudgetAlertCreated,
 		event.ErrorAlertCreated,
+		event.DigestCreated,
 	} {
 		s.unsubs = append(s.unsubs, event.Global.Subscribe(t, h))
 	}
@@ -79,13 +92,7 @@ func (s *Subscriber) mapEventTypes(e event.Event, payload domain.JSONMap) []doma
 	case event.AgentOffline:
 		return []domain.WebhookEventType{domain.WebhookEventAgentOffline}
 	case event.AgentStatus:
-		status, _ := payload["status"].(string)
-		if status == "online" {
-			return []domain.WebhookEventType{domain.WebhookEventAgentOnline}
-		}
-		if status == "offline" {
-			return []domain.WebhookEventType{domain.WebhookEventAgentOffline}
-		}
+		// 上线 / 离线另有 agent.online / agent.offline 事件，避免重复触发
 		return nil
 	case event.TaskCreated:
 		return []domain.WebhookEventType{domain.WebhookEventTaskCreated}
@@ -96,14 +103,30 @@ func (s *Subscriber) mapEventTypes(e event.Event, payload domain.JSONMap) []doma
 			out = append(out, domain.WebhookEventTaskCompleted)
 		}
 		return out
+	case event.TaskOverdue:
+		return []domain.WebhookEventType{domain.WebhookEventTaskOverdue}
+	case event.TaskSLABreached:
+		return []domain.WebhookEventType{domain.WebhookEventTaskSLABreach}
+	case event.TaskCommented:
+		return []domain.WebhookEventType{domain.WebhookEventTaskCommented}
 	case event.MessageNew:
 		return []domain.WebhookEventType{domain.WebhookEventMessageNew}
+	case event.MessageEdited, event.MessageDeleted, event.MessageReaction, event.MessagePinned:
+		return []domain.WebhookEventType{domain.WebhookEventMessageUpdate}
+	case event.MessageAction:
+		return []domain.WebhookEventType{domain.WebhookEventMessageAction}
+	case event.ChannelCreated, event.ChannelUpdated:
+		return []domain.WebhookEventType{domain.WebhookEventChannel}
+	case event.ChannelMemberAdded, event.ChannelMemberLeft:
+		return []domain.WebhookEventType{domain.WebhookEventChannelMember}
 	case event.ApprovalApproved:
 		return []domain.WebhookEventType{domain.WebhookEventApprovalEvent}
 	case event.BudgetAlertCreated:
 		return []domain.WebhookEventType{domain.WebhookEventBudgetAlert}
 	case event.ErrorAlertCreated:
 		return []domain.WebhookEventType{domain.WebhookEventErrorAlert}
+	case event.DigestCreated:
+		return []domain.WebhookEventType{domain.WebhookEventDigest}
 	default:
 		return nil
 	}
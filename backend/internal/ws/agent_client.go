@@ -4,16 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"log"
-	"strings"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 
 	"github.com/linkclaw/backend/internal/domain"
 	"github.com/linkclaw/backend/internal/event"
-	"github.com/linkclaw/backend/internal/repository"
 	"github.com/linkclaw/backend/internal/service"
 )
 
@@ -21,24 +21,30 @@ import (
 var agentConns sync.Map // map[agentID]*AgentClient
 
 // AgentClient 代表一个 Agent 专属的 WebSocket 连接
-// 与前端 Client 不同：包含入职报到流程、事件过滤、未读消息重推
+// 与前端 Client 不同：包含入职报到流程，事件经投递日志带 seq 推送，需要客户端 ack
 type AgentClient struct {
-	conn       *websocket.Conn
-	agent      *domain.Agent
-	agentRepo  repository.AgentRepo
-	messageSvc *service.MessageService
-	send       chan []byte
-	done       chan struct{}
+	conn        *websocket.Conn
+	agent       *domain.Agent
+	connID      string
+	presence    *service.PresenceService
+	messageSvc  *service.MessageService
+	deliverySvc *service.AgentDeliveryService
+	lastSeq     int64 // 客户端重连时带上的已处理位置
+	send        chan []byte
+	done        chan struct{}
 }
 
 // UpgradeAgent 升级 HTTP 连接为 Agent 专属 WebSocket
-// 同一 Agent 只保留最新连接，旧连接会被关闭
+// 同一 Agent 只保留最新连接，旧连接会被关闭。
+// 重连时通过 ?last_seq= 告知已处理到的 seq，服务端据此累计确认并续传之后的投递。
 func UpgradeAgent(
 	c *gin.Context,
 	agent *domain.Agent,
-	agentRepo repository.AgentRepo,
+	presence *service.PresenceService,
 	messageSvc *service.MessageService,
+	deliverySvc *service.AgentDeliveryService,
 ) {
+	lastSeq, _ := strconv.ParseInt(c.Query("last_seq"), 10, 64)
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("[agent-ws] upgrade error: %v", err)
@@ -46,12 +52,15 @@ func UpgradeAgent(
 	}
 
 	ac := &AgentClient{
-		conn:       conn,
-		agent:      agent,
-		agentRepo:  agentRepo,
-		messageSvc: messageSvc,
-		send:       make(chan []byte, 256),
-		done:       make(chan struct{}),
+		conn:        conn,
+		agent:       agent,
+		connID:      "agent-ws:" + uuid.New().String(),
+		presence:    presence,
+		messageSvc:  messageSvc,
+		deliverySvc: deliverySvc,
+		lastSeq:     lastSeq,
+		send:        make(chan []byte, 256),
+		done:        make(chan struct{}),
 	}
 
 	// 关闭该 Agent 的旧连接
@@ -61,9 +70,7 @@ func UpgradeAgent(
 		prev.conn.Close()
 	}
 
-	ctx := context.Background()
-	_ = agentRepo.UpdateStatus(ctx, agent.ID, domain.StatusOnline)
-	_ = agentRepo.UpdateLastSeen(ctx, agent.ID)
+	presence.Connect(context.Background(), agent, ac.connID)
 
 	go ac.writePump()
 	go ac.readPump()
@@ -83,7 +90,7 @@ func (ac *AgentClient) readPump() {
 	_ = ac.conn.SetReadDeadline(time.Now().Add(pongWait))
 	ac.conn.SetPongHandler(func(string) error {
 		_ = ac.conn.SetReadDeadline(time.Now().Add(pongWait))
-		_ = ac.agentRepo.UpdateLastSeen(context.Background(), ac.agent.ID)
+		ac.presence.Heartbeat(context.Background(), ac.agent, ac.connID)
 		return nil
 	})
 
@@ -106,20 +113,33 @@ func (ac *AgentClient) handleFrame(raw []byte) {
 	switch frame.Type {
 	case "message.send":
 		var d sendMessageData
-		if err := json.Unmarshal(frame.Data, &d); err != nil || d.Content == "" {
+		if err := json.Unmarshal(frame.Data, &d); err != nil || (d.Content == "" && len(d.Blocks) == 0) {
 			return
 		}
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
+		ac.presence.Activity(ctx, ac.agent)
 		_, _ = ac.messageSvc.Send(ctx, service.SendInput{
-			CompanyID:  ac.agent.CompanyID,
-			SenderID:   ac.agent.ID,
-			Channel:    d.Channel,
-			ReceiverID: d.ReceiverID,
-			Content:    d.Content,
+			CompanyID:    ac.agent.CompanyID,
+			SenderID:     ac.agent.ID,
+			Channel:      d.Channel,
+			ReceiverID:   d.ReceiverID,
+			ThreadRootID: d.ThreadRootID,
+			Content:      d.Content,
+			Blocks:       d.Blocks,
 		})
+	case "ack":
+		var d ackData
+		if err := json.Unmarshal(frame.Data, &d); err != nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if _, err := ac.deliverySvc.Ack(ctx, ac.agent.ID, d.Seq, d.Seqs); err != nil {
+			log.Printf("[agent-ws] ack error for agent %s: %v", ac.agent.ID, err)
+		}
 	case "ping":
-		_ = ac.agentRepo.UpdateLastSeen(context.Background(), ac.agent.ID)
+		ac.presence.Heartbeat(context.Background(), ac.agent, ac.connID)
 		ac.sendJSON(WSMessage{Type: "pong", Data: struct{}{}})
 	}
 }
@@ -152,10 +172,21 @@ func (ac *AgentClient) writePump() {
 	}
 }
 
-// eventLoop 核心事件循环：入职报到 + 事件订阅 + 未读消息重推
+// eventLoop 入职报到 + 接入投递日志：报到完成前不推送任何事件
 func (ac *AgentClient) eventLoop() {
 	agent := ac.agent
 	initialized := agent.Initialized
+	ctx := context.Background()
+
+	// 客户端带回的 last_seq 视为累计确认
+	if _, err := ac.deliverySvc.Ack(ctx, agent.ID, ac.lastSeq, nil); err != nil {
+		log.Printf("[agent-ws] resume ack error for agent %s: %v", agent.ID, err)
+	}
+	cursor, err := ac.deliverySvc.Cursor(ctx, agent.ID)
+	if err != nil {
+		log.Printf("[agent-ws] load delivery cursor for agent %s: %v", agent.ID, err)
+		cursor = &domain.AgentDeliveryCursor{AgentID: agent.ID}
+	}
 
 	// 发送 connected 事件
 	ac.sendJSON(WSMessage{
@@ -163,6 +194,8 @@ func (ac *AgentClient) eventLoop() {
 		Data: map[string]interface{}{
 			"agent_id":    agent.ID,
 			"initialized": initialized,
+			"acked_seq":   cursor.AckedSeq,
+			"last_seq":    cursor.LastSeq,
 		},
 	})
 
@@ -174,103 +207,65 @@ func (ac *AgentClient) eventLoop() {
 		})
 	}
 
-	// 已报到 → 推送未读消息
-	if initialized {
-		ac.pushUnreadMessages()
+	detach := func() {}
+	attach := func() {
+		detach = ac.deliverySvc.Attach(agent.ID, ac)
+		if err := ac.deliverySvc.Resume(ctx, agent, ac); err != nil {
+			log.Printf("[agent-ws] resume error for agent %s: %v", agent.ID, err)
+		}
 	}
 
-	// 订阅事件
-	ch := make(chan event.Event, 32)
-	filter := func(e event.Event) {
-		if ac.isEventRelevant(e) {
-			select {
-			case ch <- e:
-			default:
-			}
-		}
+	// 已报到 → 续传未确认的投递
+	if initialized {
+		attach()
 	}
 
-	unsub1 := event.Global.Subscribe(event.MessageNew, filter)
-	unsub2 := event.Global.Subscribe(event.TaskCreated, filter)
-	unsub3 := event.Global.Subscribe(event.TaskUpdated, filter)
+	initCh := make(chan struct{}, 1)
 	unsubInit := event.Global.Subscribe(event.AgentInitialized, func(e event.Event) {
 		var p event.AgentInitializedPayload
 		if err := json.Unmarshal(e.Payload, &p); err == nil && p.AgentID == agent.ID {
 			select {
-			case ch <- e:
+			case initCh <- struct{}{}:
 			default:
 			}
 		}
 	})
 
-	retryTicker := time.NewTicker(5 * time.Minute)
 	defer func() {
-		unsub1()
-		unsub2()
-		unsub3()
 		unsubInit()
-		retryTicker.Stop()
-		_ = ac.agentRepo.UpdateStatus(context.Background(), agent.ID, domain.StatusOffline)
+		detach()
+		// 被新连接替换时新连接已登记，计数不会归零
+		ac.presence.Disconnect(context.Background(), agent, ac.connID)
 	}()
 
 	for {
 		select {
 		case <-ac.done:
 			return
-		case e := <-ch:
-			if e.Type == event.AgentInitialized {
-				initialized = true
-				ac.pushUnreadMessages()
-				continue
-			}
+		case <-initCh:
 			if !initialized {
-				continue
-			}
-			ac.sendJSON(WSMessage{
-				Type: string(e.Type),
-				Data: e.Payload,
-			})
-		case <-retryTicker.C:
-			if initialized {
-				ac.pushUnreadMessages()
+				initialized = true
+				attach()
 			}
 		}
 	}
 }
 
-// pushUnreadMessages 查询并推送该 Agent 的所有未读消息
-func (ac *AgentClient) pushUnreadMessages() {
-	msgs, err := ac.messageSvc.GetUnreadMessages(
-		context.Background(), ac.agent.ID, ac.agent.CompanyID,
-	)
+// Deliver 推送一条投递（实现 service.DeliverySink）；发送缓冲区满时返回 false
+func (ac *AgentClient) Deliver(d *domain.AgentDelivery) bool {
+	b, err := json.Marshal(WSMessage{Type: d.EventType, Seq: d.Seq, Data: d.Payload})
 	if err != nil {
-		log.Printf("[agent-ws] pushUnread error for agent %s: %v", ac.agent.ID, err)
-		return
+		return false
 	}
-	if len(msgs) == 0 {
-		return
-	}
-	log.Printf("[agent-ws] pushing %d unread messages to agent %s (%s)",
-		len(msgs), ac.agent.Name, ac.agent.ID)
-	for _, msg := range msgs {
-		payload := event.MessageNewPayload{
-			MessageID:  msg.ID,
-			CompanyID:  msg.CompanyID,
-			ChannelID:  msg.ChannelID,
-			ReceiverID: msg.ReceiverID,
-			SenderID:   msg.SenderID,
-			MsgType:    string(msg.MsgType),
-			Content:    msg.Content,
-			CreatedAt:  msg.CreatedAt.Format(time.RFC3339),
-		}
-		ac.sendJSON(WSMessage{
-			Type: string(event.MessageNew),
-			Data: payload,
-		})
+	select {
+	case ac.send <- b:
+		return true
+	default:
+		return false
 	}
 }
 
-// sendJSON 将消息序列化为 JSON 并推入发送队列
+// sendJSON 将控制帧序列化为 JSON 并推入发送队列（不经投递日志，缓冲区满时丢弃）
 func (ac *AgentClient) sendJSON(v interface{}) {
 	b, err := json.Marshal(v)
 	if err != nil {
@@ -282,58 +277,6 @@ func (ac *AgentClient) sendJSON(v interface{}) {
 	}
 }
 
-// isEventRelevant 判断事件是否需要推送给该 agent
-func (ac *AgentClient) isEventRelevant(e event.Event) bool {
-	agent := ac.agent
-	switch e.Type {
-	case event.MessageNew:
-		var p event.MessageNewPayload
-		if err := json.Unmarshal(e.Payload, &p); err != nil {
-			return false
-		}
-		if p.CompanyID != agent.CompanyID {
-			return false
-		}
-		// DM：只推给目标 agent
-		if p.ReceiverID != nil {
-			return *p.ReceiverID == agent.ID
-		}
-		// 频道消息：跳过其他 AI Agent 发的（防止 Agent 间无限循环），但 @本人 的除外
-		if p.SenderID != nil && *p.SenderID != agent.ID {
-			sender, _ := ac.agentRepo.GetByID(context.Background(), *p.SenderID)
-			if sender != nil && !sender.IsHuman {
-				mentioned := strings.Contains(p.Content, "@"+agent.Name)
-				if !mentioned {
-					if meta, ok := domain.PositionMetaByPosition[agent.Position]; ok {
-						mentioned = strings.Contains(p.Content, "@"+meta.DisplayName+"-"+agent.Name)
-					}
-				}
-				if !mentioned {
-					return false
-				}
-			}
-		}
-		return true
-
-	case event.TaskCreated, event.TaskUpdated:
-		var p struct {
-			CompanyID  string  `json:"company_id"`
-			AssigneeID *string `json:"assignee_id"`
-		}
-		if err := json.Unmarshal(e.Payload, &p); err != nil {
-			return false
-		}
-		if p.CompanyID != agent.CompanyID {
-			return false
-		}
-		if agent.RoleType == domain.RoleHR || agent.RoleType == domain.RoleChairman {
-			return true
-		}
-		return p.AssigneeID != nil && *p.AssigneeID == agent.ID
-	}
-	return false
-}
-
 // initPrompt 新员工首次连接时的入职引导提示
 const initPrompt = `你刚刚上线，首次连接到公司协作系统。
 
@@ -362,7 +305,7 @@ const initPrompt = `你刚刚上线，首次连接到公司协作系统。
 - 报到完成后，系统会自动推送未读消息
 - 如果你想了解入职前的历史消息，可以调用 mcp__linkclaw__get_messages 查看频道或私信的历史记录
 - 处理完每条消息后，必须调用 mcp__linkclaw__mark_messages_read 确认已读，传入处理过的 message_id（多条用逗号分隔）
-- 如果不确认已读，系统会在 5 分钟后重复推送该消息
+- 未被确认的推送（客户端在消息处理完成后自动回执，或你标记已读）会按退避间隔（30 秒起逐次翻倍，最长 30 分钟）重投，最多 8 次；断线重连后从最后确认的位置继续推送
 
 ## 沟通规范（严格遵守）
 
@@ -8,10 +8,10 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 
 	"github.com/linkclaw/backend/internal/domain"
-	"github.com/linkclaw/backend/internal/repository"
 	"github.com/linkclaw/backend/internal/service"
 )
 
@@ -36,13 +36,15 @@ type Client struct {
 	CompanyID  string
 	AgentID    string
 	agent      *domain.Agent
-	agentRepo  repository.AgentRepo
+	connID     string
+	presence   *service.PresenceService
 	messageSvc *service.MessageService
 }
 
 // WSMessage 推送给前端的事件格式
 type WSMessage struct {
 	Type string      `json:"type"`
+	Seq  int64       `json:"seq,omitempty"` // Agent 投递序号，客户端处理后需 ack
 	Data interface{} `json:"data"`
 }
 
@@ -54,13 +56,21 @@ type incomingFrame struct {
 
 // sendMessageData 前端发送消息的数据
 type sendMessageData struct {
-	Channel    string `json:"channel"`
-	ReceiverID string `json:"receiver_id"`
-	Content    string `json:"content"`
+	Channel      string                 `json:"channel"`
+	ReceiverID   string                 `json:"receiver_id"`
+	ThreadRootID string                 `json:"thread_root_id"`
+	Content      string                 `json:"content"`
+	Blocks       []*domain.MessageBlock `json:"blocks"`
+}
+
+// ackData Agent 确认投递：seq 为累计确认（<= seq 的全部投递），seqs 为乱序处理时的单条确认
+type ackData struct {
+	Seq  int64   `json:"seq"`
+	Seqs []int64 `json:"seqs"`
 }
 
 // Upgrade 升级 HTTP 连接为 WebSocket，并绑定 agent 身份与服务依赖
-func Upgrade(c *gin.Context, hub *Hub, agent *domain.Agent, agentRepo repository.AgentRepo, messageSvc *service.MessageService) {
+func Upgrade(c *gin.Context, hub *Hub, agent *domain.Agent, presence *service.PresenceService, messageSvc *service.MessageService) {
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("ws upgrade: %v", err)
@@ -73,15 +83,14 @@ func Upgrade(c *gin.Context, hub *Hub, agent *domain.Agent, agentRepo repository
 		CompanyID:  agent.CompanyID,
 		AgentID:    agent.ID,
 		agent:      agent,
-		agentRepo:  agentRepo,
+		connID:     "ws:" + uuid.New().String(),
+		presence:   presence,
 		messageSvc: messageSvc,
 	}
 	hub.register <- client
 
-	// 标记在线
-	ctx := context.Background()
-	_ = agentRepo.UpdateStatus(ctx, agent.ID, domain.StatusOnline)
-	_ = agentRepo.UpdateLastSeen(ctx, agent.ID)
+	// 登记连接（同一用户多个标签页各算一个连接）
+	presence.Connect(context.Background(), agent, client.connID)
 
 	go client.writePump()
 	go client.readPump()
@@ -91,14 +100,14 @@ func (c *Client) readPump() {
 	defer func() {
 		c.hub.unregister <- c
 		c.conn.Close()
-		// 断开即离线
-		_ = c.agentRepo.UpdateStatus(context.Background(), c.AgentID, domain.StatusOffline)
+		// 最后一个连接断开才离线
+		c.presence.Disconnect(context.Background(), c.agent, c.connID)
 	}()
 	c.conn.SetReadLimit(maxMsgSize)
 	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error {
 		_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
-		_ = c.agentRepo.UpdateLastSeen(context.Background(), c.AgentID)
+		c.presence.Heartbeat(context.Background(), c.agent, c.connID)
 		return nil
 	})
 	for {
@@ -118,7 +127,7 @@ func (c *Client) handleFrame(raw []byte) {
 	switch frame.Type {
 	case "message.send":
 		var d sendMessageData
-		if err := json.Unmarshal(frame.Data, &d); err != nil || d.Content == "" {
+		if err := json.Unmarshal(frame.Data, &d); err != nil || (d.Content == "" && len(d.Blocks) == 0) {
 			return
 		}
 		if c.messageSvc == nil {
@@ -126,15 +135,18 @@ func (c *Client) handleFrame(raw []byte) {
 		}
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
+		c.presence.Activity(ctx, c.agent)
 		_, _ = c.messageSvc.Send(ctx, service.SendInput{
-			CompanyID:  c.CompanyID,
-			SenderID:   c.AgentID,
-			Channel:    d.Channel,
-			ReceiverID: d.ReceiverID,
-			Content:    d.Content,
+			CompanyID:    c.CompanyID,
+			SenderID:     c.AgentID,
+			Channel:      d.Channel,
+			ReceiverID:   d.ReceiverID,
+			ThreadRootID: d.ThreadRootID,
+			Content:      d.Content,
+			Blocks:       d.Blocks,
 		})
 	case "ping":
-		_ = c.agentRepo.UpdateLastSeen(context.Background(), c.AgentID)
+		c.presence.Heartbeat(context.Background(), c.agent, c.connID)
 		c.SendJSON(WSMessage{Type: "pong", Data: struct{}{}})
 	}
 }
@@ -1,30 +1,41 @@
 package ws
 
 import (
+	"context"
 	"encoding/json"
+	"log"
+	"time"
 
 	"github.com/linkclaw/backend/internal/event"
 )
 
+// ChannelMembers 查询频道成员，用于私有频道事件只推送给成员
+type ChannelMembers interface {
+	MemberIDs(ctx context.Context, channelID string) ([]string, error)
+}
+
 // Hub 管理所有 WebSocket 连接，并将事件广播给同公司的客户端
 type Hub struct {
 	clients    map[*Client]bool
 	register   chan *Client
 	unregister chan *Client
 	broadcast  chan broadcastMsg
+	channels   ChannelMembers
 }
 
 type broadcastMsg struct {
 	CompanyID string
+	AgentIDs  map[string]bool // 非 nil 时只推送给这些 agent
 	Msg       interface{}
 }
 
-func NewHub() *Hub {
+func NewHub(channels ChannelMembers) *Hub {
 	return &Hub{
 		clients:    make(map[*Client]bool),
 		register:   make(chan *Client, 16),
 		unregister: make(chan *Client, 16),
 		broadcast:  make(chan broadcastMsg, 256),
+		channels:   channels,
 	}
 }
 
@@ -48,6 +59,9 @@ func (h *Hub) Run() {
 				if client.CompanyID != msg.CompanyID {
 					continue
 				}
+				if msg.AgentIDs != nil && !msg.AgentIDs[client.AgentID] {
+					continue
+				}
 				client.SendJSON(msg.Msg)
 			}
 		}
@@ -56,8 +70,12 @@ func (h *Hub) Run() {
 
 // Broadcast 向指定公司的所有 WS 客户端广播消息
 func (h *Hub) Broadcast(companyID string, msg interface{}) {
+	h.send(broadcastMsg{CompanyID: companyID, Msg: msg})
+}
+
+func (h *Hub) send(m broadcastMsg) {
 	select {
-	case h.broadcast <- broadcastMsg{CompanyID: companyID, Msg: msg}:
+	case h.broadcast <- m:
 	default:
 	}
 }
@@ -69,21 +87,61 @@ func (h *Hub) subscribeEvents() {
 		if companyID == "" {
 			return
 		}
-		h.Broadcast(companyID, WSMessage{
-			Type: string(e.Type),
-			Data: e.Payload,
+		audience, ok := h.privateAudience(e)
+		if !ok {
+			return
+		}
+		h.send(broadcastMsg{
+			CompanyID: companyID,
+			AgentIDs:  audience,
+			Msg: WSMessage{
+				Type: string(e.Type),
+				Data: e.Payload,
+			},
 		})
 	}
 
 	for _, t := range []event.Type{
 		event.AgentOnline, event.AgentOffline, event.AgentStatus,
-		event.TaskCreated, event.TaskUpdated,
-		event.MessageNew,
+		event.TaskCreated, event.TaskUpdated, event.TaskCommented,
+		event.MessageNew, event.MessageEdited, event.MessageDeleted, event.MessageReaction, event.MessagePinned, event.MessageAction,
+		event.ChannelCreated, event.ChannelUpdated, event.ChannelMemberAdded, event.ChannelMemberLeft,
 	} {
 		event.Global.Subscribe(t, forward)
 	}
 }
 
+// privateAudience 私有频道相关事件只推送给频道成员；ok=false 表示无法确定成员、放弃推送
+func (h *Hub) privateAudience(e event.Event) (map[string]bool, bool) {
+	var p struct {
+		ChannelID *string `json:"channel_id"`
+		Private   bool    `json:"private"`
+		AgentID   string  `json:"agent_id"`
+	}
+	if json.Unmarshal(e.Payload, &p) != nil || !p.Private || p.ChannelID == nil {
+		return nil, true
+	}
+	if h.channels == nil {
+		return nil, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ids, err := h.channels.MemberIDs(ctx, *p.ChannelID)
+	if err != nil {
+		log.Printf("[ws] load members of channel %s: %v", *p.ChannelID, err)
+		return nil, false
+	}
+	audience := make(map[string]bool, len(ids)+1)
+	for _, id := range ids {
+		audience[id] = true
+	}
+	// 被移出的成员也需要收到移除通知
+	if e.Type == event.ChannelMemberLeft && p.AgentID != "" {
+		audience[p.AgentID] = true
+	}
+	return audience, true
+}
+
 func extractCompanyID(e event.Event) string {
 	switch e.Type {
 	case event.AgentOnline, event.AgentOffline, event.AgentStatus:
@@ -96,11 +154,31 @@ func extractCompanyID(e event.Event) string {
 		if json.Unmarshal(e.Payload, &p) == nil {
 			return p.CompanyID
 		}
+	case event.TaskCommented:
+		var p event.TaskCommentedPayload
+		if json.Unmarshal(e.Payload, &p) == nil {
+			return p.CompanyID
+		}
 	case event.MessageNew:
 		var p event.MessageNewPayload
 		if json.Unmarshal(e.Payload, &p) == nil {
 			return p.CompanyID
 		}
+	case event.MessageEdited, event.MessageDeleted, event.MessageReaction, event.MessagePinned, event.MessageAction:
+		var p event.MessageChangedPayload
+		if json.Unmarshal(e.Payload, &p) == nil {
+			return p.CompanyID
+		}
+	case event.ChannelCreated, event.ChannelUpdated:
+		var p event.ChannelPayload
+		if json.Unmarshal(e.Payload, &p) == nil {
+			return p.CompanyID
+		}
+	case event.ChannelMemberAdded, event.ChannelMemberLeft:
+		var p event.ChannelMemberPayload
+		if json.Unmarshal(e.Payload, &p) == nil {
+			return p.CompanyID
+		}
 	}
 	return ""
 }
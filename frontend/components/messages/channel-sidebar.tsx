@@ -1,7 +1,7 @@
 "use client";
 
 import { cn } from "@/lib/utils";
-import { POSITION_LABELS } from "@/lib/types";
+import { AgentStatus, POSITION_LABELS } from "@/lib/types";
 import { Hash, ChevronDown, ChevronRight } from "lucide-react";
 import { useState } from "react";
 
@@ -15,7 +15,7 @@ interface Channel {
 interface DMTarget {
   id: string;
   name: string;
-  status: "online" | "busy" | "offline";
+  status: AgentStatus;
   position: string;
 }
 
@@ -32,6 +32,7 @@ function StatusDot({ status }: { status: string }) {
   const colors: Record<string, string> = {
     online: "bg-green-500",
     busy: "bg-yellow-500",
+    idle: "bg-green-500/40",
     offline: "bg-zinc-500",
   };
   return (
@@ -0,0 +1,225 @@
+"use client";
+
+import { useState } from "react";
+import { toast } from "sonner";
+import { FileText, BookOpen, ShieldCheck, Download } from "lucide-react";
+import { api } from "@/lib/api";
+import { cn } from "@/lib/utils";
+import {
+  APPROVAL_ACTION_APPROVE,
+  APPROVAL_ACTION_REJECT,
+  ApprovalCard,
+  KnowledgeCard,
+  Message,
+  MessageAction,
+  MessageBlock,
+  MessageFile,
+} from "@/lib/types";
+import { TaskProgressCard } from "./task-progress-card";
+import { MarkdownContent } from "./markdown-content";
+
+const approvalStatusLabel: Record<string, { label: string; color: string }> = {
+  pending:   { label: "待审批", color: "text-yellow-400" },
+  approved:  { label: "已批准", color: "text-green-400" },
+  rejected:  { label: "已驳回", color: "text-red-400" },
+  cancelled: { label: "已取消", color: "text-zinc-500" },
+};
+
+const approvalTypeLabel: Record<string, string> = {
+  hire: "招聘",
+  fire: "解雇",
+  budget_override: "预算超支",
+  task_escalation: "任务升级",
+  custom: "自定义",
+};
+
+function formatFileSize(bytes: number): string {
+  if (bytes < 1024) return `${bytes} B`;
+  if (bytes < 1024 * 1024) return `${(bytes / 1024).toFixed(1)} KB`;
+  return `${(bytes / 1024 / 1024).toFixed(1)} MB`;
+}
+
+// 附件需要鉴权，不能直接用 <a href>，取回 blob 后触发下载
+async function downloadAttachment(file: MessageFile) {
+  try {
+    const token = localStorage.getItem("lc_token");
+    const res = await fetch(`/api/v1/messages/attachments/${file.attachment_id}`, {
+      headers: token ? { Authorization: `Bearer ${token}` } : {},
+    });
+    if (!res.ok) throw new Error("下载失败");
+    const url = URL.createObjectURL(await res.blob());
+    const a = document.createElement("a");
+    a.href = url;
+    a.download = file.filename;
+    a.click();
+    URL.revokeObjectURL(url);
+  } catch (e) {
+    toast.error(e instanceof Error ? e.message : "下载失败");
+  }
+}
+
+function FileBlock({ file }: { file: MessageFile }) {
+  return (
+    <button
+      type="button"
+      onClick={() => downloadAttachment(file)}
+      className="my-1 flex items-center gap-2 border border-zinc-700 rounded-lg px-3 py-2 bg-zinc-900/60 max-w-sm text-left hover:border-zinc-500"
+    >
+      <FileText className="w-4 h-4 text-zinc-400 flex-shrink-0" />
+      <div className="flex-1 min-w-0">
+        <div className="text-sm text-zinc-100 truncate">{file.filename}</div>
+        <div className="text-xs text-zinc-500">{formatFileSize(file.file_size)}</div>
+      </div>
+      <Download className="w-4 h-4 text-zinc-500 flex-shrink-0" />
+    </button>
+  );
+}
+
+function KnowledgeBlock({ card }: { card: KnowledgeCard }) {
+  return (
+    <a
+      href={`/knowledge?doc=${card.doc_id}`}
+      className="my-1 block border border-zinc-700 rounded-lg p-3 bg-zinc-900/60 max-w-sm hover:border-zinc-500"
+    >
+      <div className="flex items-center gap-2">
+        <BookOpen className="w-4 h-4 text-emerald-400 flex-shrink-0" />
+        <span className="text-sm font-medium text-zinc-100 truncate">{card.title}</span>
+      </div>
+      {card.excerpt && <p className="text-xs text-zinc-400 mt-1 line-clamp-3">{card.excerpt}</p>}
+      {card.tags?.length > 0 && (
+        <div className="flex flex-wrap gap-1 mt-2">
+          {card.tags.map((t) => (
+            <span key={t} className="text-[10px] px-1.5 py-0.5 rounded bg-zinc-800 text-zinc-400">{t}</span>
+          ))}
+        </div>
+      )}
+    </a>
+  );
+}
+
+function ApprovalBlock({
+  card,
+  busy,
+  onAct,
+}: {
+  card: ApprovalCard;
+  busy: boolean;
+  onAct: (actionId: string, value: string) => void;
+}) {
+  const status = approvalStatusLabel[card.status] ?? approvalStatusLabel.pending;
+  return (
+    <div className="my-1 border border-zinc-700 rounded-lg p-3 bg-zinc-900/60 max-w-sm">
+      <div className="flex items-center gap-2">
+        <ShieldCheck className="w-4 h-4 text-blue-400 flex-shrink-0" />
+        <span className="text-sm font-medium text-zinc-100">
+          审批：{approvalTypeLabel[card.request_type] ?? card.request_type}
+        </span>
+        <span className={cn("text-xs ml-auto", status.color)}>{status.label}</span>
+      </div>
+      {card.reason && <p className="text-xs text-zinc-400 mt-1">{card.reason}</p>}
+      {card.status === "pending" && (
+        <div className="flex gap-2 mt-2">
+          <ActionButton
+            action={{ action_id: APPROVAL_ACTION_APPROVE, label: "批准", style: "primary" }}
+            disabled={busy}
+            onClick={() => onAct(APPROVAL_ACTION_APPROVE, card.id)}
+          />
+          <ActionButton
+            action={{ action_id: APPROVAL_ACTION_REJECT, label: "驳回", style: "danger" }}
+            disabled={busy}
+            onClick={() => onAct(APPROVAL_ACTION_REJECT, card.id)}
+          />
+        </div>
+      )}
+    </div>
+  );
+}
+
+function ActionButton({
+  action,
+  disabled,
+  onClick,
+}: {
+  action: MessageAction;
+  disabled: boolean;
+  onClick: () => void;
+}) {
+  return (
+    <button
+      type="button"
+      disabled={disabled}
+      onClick={onClick}
+      className={cn(
+        "px-3 py-1 rounded-md text-xs font-medium transition-colors disabled:opacity-50",
+        action.style === "primary" && "bg-blue-600 hover:bg-blue-500 text-white",
+        action.style === "danger" && "bg-red-600/80 hover:bg-red-500 text-white",
+        !action.style && "bg-zinc-700 hover:bg-zinc-600 text-zinc-100",
+      )}
+    >
+      {action.label}
+    </button>
+  );
+}
+
+function Block({
+  block,
+  busy,
+  onAct,
+}: {
+  block: MessageBlock;
+  busy: boolean;
+  onAct: (actionId: string, value?: string) => void;
+}) {
+  switch (block.type) {
+    case "text":
+      return <MarkdownContent content={block.text ?? ""} />;
+    case "code":
+      return <MarkdownContent content={"```" + (block.language ?? "") + "\n" + (block.code ?? "") + "\n```"} />;
+    case "file":
+      return block.file ? <FileBlock file={block.file} /> : null;
+    case "task":
+      return block.task ? <TaskProgressCard meta={block.task} /> : null;
+    case "approval":
+      return block.approval ? <ApprovalBlock card={block.approval} busy={busy} onAct={onAct} /> : null;
+    case "knowledge":
+      return block.knowledge ? <KnowledgeBlock card={block.knowledge} /> : null;
+    case "actions":
+      return (
+        <div className="flex flex-wrap gap-2 my-1">
+          {block.actions?.map((a) => (
+            <ActionButton key={a.action_id} action={a} disabled={busy} onClick={() => onAct(a.action_id)} />
+          ))}
+        </div>
+      );
+    default:
+      return null;
+  }
+}
+
+// MessageBlocks 渲染富消息；按钮点击回传服务端，审批卡片用返回的最新块刷新
+export function MessageBlocks({ msg }: { msg: Message }) {
+  const [busy, setBusy] = useState(false);
+  const [blocks, setBlocks] = useState(msg.blocks);
+
+  async function handleAct(actionId: string, value?: string) {
+    setBusy(true);
+    try {
+      const updated = await api.post<Message>(`/api/v1/messages/${msg.id}/actions`, { action_id: actionId, value });
+      setBlocks(updated.blocks);
+      if (actionId === APPROVAL_ACTION_APPROVE) toast.success("已批准");
+      else if (actionId === APPROVAL_ACTION_REJECT) toast.success("已驳回");
+    } catch (e) {
+      toast.error(e instanceof Error ? e.message : "操作失败");
+    } finally {
+      setBusy(false);
+    }
+  }
+
+  return (
+    <div className="space-y-1">
+      {blocks?.map((b, i) => (
+        <Block key={i} block={b} busy={busy} onAct={handleAct} />
+      ))}
+    </div>
+  );
+}
@@ -5,6 +5,7 @@ import { Message } from "@/lib/types";
 import { formatRelativeTime } from "@/lib/utils";
 import { TaskProgressCard } from "./task-progress-card";
 import { MarkdownContent } from "./markdown-content";
+import { MessageBlocks } from "./message-blocks";
 
 interface MessageFeedProps {
   messages: Message[];
@@ -70,7 +71,11 @@ function MessageBubble({
           onDoubleClick={() => onReply?.(msg, senderLabel)}
           title="双击回复"
         >
-          <MarkdownContent content={msg.content} />
+          {msg.msg_type === "rich" && msg.blocks ? (
+            <MessageBlocks msg={msg} />
+          ) : (
+            <MarkdownContent content={msg.content} />
+          )}
         </div>
       </div>
     </div>
@@ -19,6 +19,7 @@ const statusConfig: Record<string, { label: string; icon: React.ElementType; col
   pending:     { label: "待分配", icon: Clock,       color: "text-zinc-400",  progress: 0  },
   assigned:    { label: "已分配", icon: Clock,       color: "text-blue-400",  progress: 20 },
   in_progress: { label: "进行中", icon: Loader2,     color: "text-yellow-400", progress: 60 },
+  in_review:   { label: "待验收", icon: Clock,       color: "text-purple-400", progress: 85 },
   done:        { label: "已完成", icon: CheckCircle2, color: "text-green-400", progress: 100 },
   failed:      { label: "已失败", icon: XCircle,     color: "text-red-400",   progress: 100 },
   cancelled:   { label: "已取消", icon: XCircle,     color: "text-zinc-500",  progress: 0  },
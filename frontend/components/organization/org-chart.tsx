@@ -16,6 +16,7 @@ interface DeptNodeProps {
 function statusDotClass(status: Agent["status"]) {
   if (status === "online") return "bg-green-500";
   if (status === "busy") return "bg-yellow-500";
+  if (status === "idle") return "bg-green-500/40";
   return "bg-zinc-500";
 }
 
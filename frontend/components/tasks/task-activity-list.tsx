@@ -0,0 +1,56 @@
+"use client";
+
+import type { TaskEvent } from "@/lib/types";
+import { formatDate } from "@/lib/utils";
+
+const FIELD_LABELS: Record<string, string> = {
+  status: "状态",
+  assignee_id: "负责人",
+  title: "标题",
+  description: "描述",
+  priority: "优先级",
+  tags: "标签",
+  parent_id: "父任务",
+  due_at: "截止时间",
+};
+
+interface TaskActivityListProps {
+  events: TaskEvent[];
+  isLoading: boolean;
+}
+
+function displayValue(value: string) {
+  if (!value) return "（空）";
+  return value.length > 60 ? `${value.slice(0, 60)}…` : value;
+}
+
+export function TaskActivityList({ events, isLoading }: TaskActivityListProps) {
+  if (isLoading) {
+    return (
+      <div className="space-y-2">
+        <div className="animate-pulse h-4 bg-zinc-800 rounded" />
+        <div className="animate-pulse h-4 bg-zinc-800 rounded" />
+      </div>
+    );
+  }
+
+  if (events.length === 0) {
+    return <p className="text-zinc-400 text-sm">暂无变更记录</p>;
+  }
+
+  return (
+    <ol className="space-y-2">
+      {[...events].reverse().map((e) => (
+        <li key={e.id} className="bg-zinc-950 border border-zinc-800 rounded-md p-3">
+          <p className="text-sm text-zinc-50">
+            <span className="text-zinc-400">{e.actor_id ?? "系统"}</span> 修改了{FIELD_LABELS[e.field] ?? e.field}
+          </p>
+          <p className="text-xs text-zinc-400 mt-1 break-all">
+            {displayValue(e.old_value)} → <span className="text-zinc-50">{displayValue(e.new_value)}</span>
+          </p>
+          <p className="text-xs text-zinc-500 mt-2">{formatDate(e.created_at)}</p>
+        </li>
+      ))}
+    </ol>
+  );
+}
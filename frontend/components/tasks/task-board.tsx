@@ -12,6 +12,7 @@ const columns: Array<{ status: TaskStatus; label: string }> = [
   { status: "pending", label: "待分配" },
   { status: "assigned", label: "已分配" },
   { status: "in_progress", label: "进行中" },
+  { status: "in_review", label: "待验收" },
   { status: "done", label: "已完成" },
 ];
 
@@ -72,6 +73,7 @@ function Column({ status, label }: { status: TaskStatus; label: string }) {
             status === "pending" ? "bg-zinc-500" :
             status === "assigned" ? "bg-blue-500" :
             status === "in_progress" ? "bg-amber-500" :
+            status === "in_review" ? "bg-purple-500" :
             "bg-emerald-500"
           )} />
         </div>
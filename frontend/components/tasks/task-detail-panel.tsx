@@ -1,7 +1,7 @@
 "use client";
 
 import { useEffect, useMemo, useState, type KeyboardEvent } from "react";
-import { Eye, EyeOff, MessageSquare, Pencil, Tag, Workflow, X } from "lucide-react";
+import { Eye, EyeOff, History, MessageSquare, Pencil, Tag, Workflow, X } from "lucide-react";
 import { toast } from "sonner";
 import {
   addWatcher,
@@ -9,11 +9,12 @@ import {
   updateTags,
   useTaskDetail,
 } from "@/hooks/use-task-detail";
+import { TaskActivityList } from "@/components/tasks/task-activity-list";
 import { TaskCommentList } from "@/components/tasks/task-comment-list";
 import { TaskDependencyList } from "@/components/tasks/task-dependency-list";
 import { cn, formatDate, getPriorityColor, getStatusColor } from "@/lib/utils";
 
-type DetailTab = "comments" | "dependencies";
+type DetailTab = "comments" | "dependencies" | "activity";
 
 interface TaskDetailPanelProps {
   taskId: string;
@@ -272,13 +273,23 @@ export function TaskDetailPanel({ taskId }: TaskDetailPanelProps) {
             <Workflow className="w-4 h-4" />
             依赖任务
           </button>
+          <button
+            type="button"
+            onClick={() => setActiveTab("activity")}
+            className={cn("inline-flex items-center gap-1.5 px-2.5 py-1.5 rounded-md text-sm transition-colors", activeTab === "activity" ? "bg-zinc-800 text-zinc-50" : "text-zinc-400 hover:text-zinc-50")}
+          >
+            <History className="w-4 h-4" />
+            动态
+          </button>
         </div>
 
-        {activeTab === "comments" ? (
+        {activeTab === "comments" && (
           <TaskCommentList taskId={task.id} comments={task.comments ?? []} isLoading={isLoading} />
-        ) : (
+        )}
+        {activeTab === "dependencies" && (
           <TaskDependencyList taskId={task.id} dependencies={task.dependencies ?? []} isLoading={isLoading} />
         )}
+        {activeTab === "activity" && <TaskActivityList events={task.activity ?? []} isLoading={isLoading} />}
       </div>
     </div>
   );
@@ -30,6 +30,7 @@ const statusFilters: Array<{ value: "all" | TaskStatus; label: string }> = [
   { value: "pending", label: "Pending" },
   { value: "assigned", label: "Assigned" },
   { value: "in_progress", label: "In Progress" },
+  { value: "in_review", label: "In Review" },
   { value: "done", label: "Done" },
   { value: "failed", label: "Failed" },
   { value: "cancelled", label: "Cancelled" },
@@ -39,9 +40,10 @@ const statusOrder: Record<TaskStatus, number> = {
   pending: 0,
   assigned: 1,
   in_progress: 2,
-  done: 3,
-  failed: 4,
-  cancelled: 5,
+  in_review: 3,
+  done: 4,
+  failed: 5,
+  cancelled: 6,
 };
 
 const priorityOrder: Record<TaskPriority, number> = {
@@ -244,6 +246,7 @@ function statusGlowClass(status: TaskStatus): string {
     case "pending": return "";
     case "assigned": return "status-glow-busy";
     case "in_progress": return "status-glow-busy";
+    case "in_review": return "status-glow-busy";
     case "done": return "status-glow-online";
     case "failed": return "status-glow-offline";
     default: return "";
@@ -1,13 +1,17 @@
 "use client";
 
+import { useCallback } from "react";
 import useSWR from "swr";
 import { api } from "@/lib/api";
-import { Agent, PaginatedResponse } from "@/lib/types";
+import { Agent, AgentStatusPeriod, AgentUtilization, PaginatedResponse } from "@/lib/types";
+import { useRealtime } from "./use-realtime";
 
 const fetcher = (url: string) => api.get<PaginatedResponse<Agent>>(url);
 
 export function useAgents() {
   const { data, error, isLoading, mutate } = useSWR("/api/v1/agents", fetcher);
+  // 在线状态变化时刷新列表
+  useRealtime("agent.status", useCallback(() => { mutate(); }, [mutate]));
   return {
     agents: data?.data ?? [],
     total: data?.total ?? 0,
@@ -28,3 +32,29 @@ export function useAgent(id: string) {
   );
   return { agent: data, isLoading, error, mutate };
 }
+
+function rangeQuery(from?: string, to?: string) {
+  const params = new URLSearchParams();
+  if (from) params.set("from", from);
+  if (to) params.set("to", to);
+  const qs = params.toString();
+  return qs ? `?${qs}` : "";
+}
+
+// useAgentStatusHistory 状态区间历史（本人或董事长可查看），默认最近 7 天
+export function useAgentStatusHistory(id: string, from?: string, to?: string) {
+  const { data, error, isLoading } = useSWR(
+    id ? `/api/v1/agents/${id}/status-history${rangeQuery(from, to)}` : null,
+    (url) => api.get<{ data: AgentStatusPeriod[]; total: number }>(url)
+  );
+  return { periods: data?.data ?? [], isLoading, error };
+}
+
+// useAgentUtilization 全员在线利用率（仅董事长），默认最近 7 天
+export function useAgentUtilization(from?: string, to?: string) {
+  const { data, error, isLoading } = useSWR(
+    `/api/v1/agents/utilization${rangeQuery(from, to)}`,
+    (url) => api.get<{ data: AgentUtilization[]; from: string; to: string }>(url)
+  );
+  return { utilization: data?.data ?? [], isLoading, error };
+}
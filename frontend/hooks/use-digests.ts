@@ -0,0 +1,36 @@
+"use client";
+
+import useSWR, { mutate as mutateCache } from "swr";
+import { api } from "@/lib/api";
+import type { Digest, DigestSettings, DigestSettingsPayload } from "@/lib/types";
+
+const SETTINGS_KEY = "/api/v1/digest/settings";
+const DIGESTS_KEY = "/api/v1/digests";
+
+export function useDigestSettings() {
+  const { data, error, isLoading, mutate } = useSWR(SETTINGS_KEY, (url) =>
+    api.get<DigestSettings>(url)
+  );
+  return { settings: data ?? null, isLoading, error, mutate };
+}
+
+export async function updateDigestSettings(body: DigestSettingsPayload) {
+  const res = await api.put<DigestSettings>(SETTINGS_KEY, body);
+  await mutateCache(SETTINGS_KEY, res, false);
+  return res;
+}
+
+// 本人最近的摘要，新的在前
+export function useDigests() {
+  const { data, error, isLoading, mutate } = useSWR(DIGESTS_KEY, (url) =>
+    api.get<{ data: Digest[] }>(url)
+  );
+  return { digests: data?.data ?? [], isLoading, error, mutate };
+}
+
+// 立即生成一次摘要；区间内没有新内容时返回 409
+export async function generateDigest() {
+  const res = await api.post<Digest>(DIGESTS_KEY, {});
+  await Promise.all([mutateCache(DIGESTS_KEY), mutateCache(SETTINGS_KEY)]);
+  return res;
+}
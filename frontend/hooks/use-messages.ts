@@ -1,8 +1,8 @@
 "use client";
 
-import useSWR from "swr";
+import useSWR, { mutate as mutateCache } from "swr";
 import { api } from "@/lib/api";
-import { Message, PaginatedResponse } from "@/lib/types";
+import { Message, PaginatedResponse, ScheduledMessage, ScheduleMessagePayload } from "@/lib/types";
 
 // 首次连接一次性拉取全部历史（limit=500）
 const INITIAL_LIMIT = 500;
@@ -34,3 +34,34 @@ export function useMessages(channel?: string, receiverId?: string, beforeId?: st
     mutate,
   };
 }
+
+const SCHEDULED_KEY = "/api/v1/scheduled-messages";
+
+// 定时消息与提醒；all 仅对董事长生效
+export function useScheduledMessages(opts: { all?: boolean; active?: boolean } = {}) {
+  const query = new URLSearchParams();
+  if (opts.all)    query.set("all", "true");
+  if (opts.active) query.set("active", "true");
+  const key = `${SCHEDULED_KEY}?${query.toString()}`;
+
+  const { data, error, isLoading, mutate } = useSWR(key, (url) =>
+    api.get<{ data: ScheduledMessage[] }>(url)
+  );
+  return { scheduled: data?.data ?? [], isLoading, error, mutate };
+}
+
+function revalidateScheduled() {
+  return mutateCache((key) => typeof key === "string" && key.startsWith(SCHEDULED_KEY));
+}
+
+export async function scheduleMessage(body: ScheduleMessagePayload) {
+  const res = await api.post<ScheduledMessage>(SCHEDULED_KEY, body);
+  await revalidateScheduled();
+  return res;
+}
+
+export async function cancelScheduledMessage(id: string) {
+  const res = await api.post<ScheduledMessage>(`${SCHEDULED_KEY}/${id}/cancel`, {});
+  await revalidateScheduled();
+  return res;
+}
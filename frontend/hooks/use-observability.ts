@@ -3,11 +3,13 @@
 import useSWR, { mutate as mutateCache } from "swr";
 import { api } from "@/lib/api";
 import type {
+  AgentDeliveryStats,
   BudgetAlertLevel,
   BudgetAlertStatus,
   BudgetPeriod,
   BudgetScopeType,
   ConversationQualityScore,
+  ConversationStat,
   ErrorAlertScopeType,
   LLMBudgetAlert,
   LLMBudgetPolicy,
@@ -26,6 +28,8 @@ const BUDGET_POLICIES_KEY = `${OBS_BASE}/budget-policies`;
 const BUDGET_ALERTS_KEY = `${OBS_BASE}/budget-alerts`;
 const ERROR_POLICIES_KEY = `${OBS_BASE}/error-policies`;
 const QUALITY_SCORES_KEY = `${OBS_BASE}/quality-scores`;
+const AGENT_DELIVERIES_KEY = `${OBS_BASE}/agent-deliveries`;
+const CONVERSATIONS_KEY = `${OBS_BASE}/conversations`;
 
 interface SingleResponse<T> {
   data: T;
@@ -154,6 +158,25 @@ export function useQualityScores(params: QualityScoreQueryParams = {}) {
   return { scores: data?.data ?? [], total: data?.total ?? 0, isLoading, error, mutate };
 }
 
+export function useAgentDeliveries(enabled = true) {
+  const { data, error, isLoading, mutate } = useSWR(enabled ? AGENT_DELIVERIES_KEY : null, (url) =>
+    api.get<SingleResponse<AgentDeliveryStats>>(url)
+  );
+  return { stats: data?.data ?? null, isLoading, error, mutate };
+}
+
+export function useConversations(enabled = true) {
+  const { data, error, isLoading, mutate } = useSWR(enabled ? CONVERSATIONS_KEY : null, (url) =>
+    api.get<SingleResponse<ConversationStat[]>>(url)
+  );
+  return { conversations: data?.data ?? [], isLoading, error, mutate };
+}
+
+export async function resumeConversation(key: string) {
+  await api.post<{ ok: true }>(`${CONVERSATIONS_KEY}/resume`, { key });
+  await mutateCache(CONVERSATIONS_KEY);
+}
+
 export async function createBudgetPolicy(body: BudgetPolicyPayload) {
   const res = await api.post<SingleResponse<LLMBudgetPolicy>>(BUDGET_POLICIES_KEY, body);
   await mutateCache(BUDGET_POLICIES_KEY);
@@ -62,3 +62,18 @@ export async function updateTags(taskId: string, tags: string[]) {
   await refreshTaskDetail(taskId);
   return res;
 }
+
+export interface UpdateTaskPayload {
+  version?: number;
+  title?: string;
+  description?: string;
+  priority?: TaskDetail["priority"];
+  parent_id?: string;
+  tags?: string[];
+}
+
+export async function updateTask(taskId: string, payload: UpdateTaskPayload) {
+  const res = await api.patch<TaskDetail>(`/api/v1/tasks/${taskId}`, payload);
+  await refreshTaskDetail(taskId);
+  return res;
+}
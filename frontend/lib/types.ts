@@ -1,3 +1,5 @@
+import type { ApprovalRequestType, ApprovalStatus } from "./types/organization";
+
 export interface Company {
   id: string;
   name: string;
@@ -41,6 +43,9 @@ export const POSITION_DEPARTMENTS: Record<string, string> = {
   accountant: "财务", financial_analyst: "财务",
 };
 
+// idle：仍在线但长时间无操作，由服务端自动切换
+export type AgentStatus = "online" | "busy" | "idle" | "offline";
+
 export interface Agent {
   id: string;
   company_id: string;
@@ -53,14 +58,14 @@ export interface Agent {
   is_human: boolean;
   permissions: string[];
   persona: string;
-  status: "online" | "busy" | "offline";
+  status: AgentStatus;
   api_key_prefix: string;
   last_seen_at: string | null;
   created_at: string;
   updated_at: string;
 }
 
-export type TaskStatus = "pending" | "assigned" | "in_progress" | "done" | "failed" | "cancelled";
+export type TaskStatus = "pending" | "assigned" | "in_progress" | "in_review" | "done" | "failed" | "cancelled";
 export type TaskPriority = "low" | "medium" | "high" | "urgent";
 
 export interface TaskAttachment {
@@ -72,6 +77,7 @@ export interface TaskAttachment {
   file_size: number;
   mime_type: string;
   storage_path: string;
+  sha256: string;
   uploaded_by: string | null;
   created_at: string;
 }
@@ -87,6 +93,10 @@ export interface Task {
   assignee_id: string | null;
   created_by: string | null;
   due_at: string | null;
+  overdue_at?: string | null;
+  sla_breached_at?: string | null;
+  escalation_level?: number;
+  escalated_at?: string | null;
   result: string | null;
   fail_reason: string | null;
   attachments?: TaskAttachment[];
@@ -111,7 +121,22 @@ export interface Channel {
   name: string;
   description: string;
   is_default: boolean;
+  is_private: boolean;
+  created_by: string | null;
+  archived_at: string | null;
   created_at: string;
+  updated_at: string;
+}
+
+export type ChannelRole = 'owner' | 'admin' | 'member';
+
+export interface ChannelMember {
+  channel_id: string;
+  agent_id: string;
+  company_id: string;
+  role: ChannelRole;
+  added_by: string | null;
+  joined_at: string;
 }
 
 export interface Message {
@@ -122,9 +147,167 @@ export interface Message {
   channel_id: string | null;
   receiver_id: string | null;
   content: string;
-  msg_type: "text" | "system" | "task_update";
+  msg_type: "text" | "system" | "task_update" | "rich";
   task_meta?: TaskMeta;      // task_update 时非空
+  blocks?: MessageBlock[] | null; // rich 时非空，content 为纯文本摘要
+  mentions: string[];        // 被提及的 agent ID（群组提及已展开）
+  mention_groups: MentionGroup[];
+  thread_root_id: string | null;
+  reply_count: number;
+  last_reply_at: string | null;
+  edited_at: string | null;
+  deleted_at: string | null; // 已删除时 content 为空
+  pinned_at: string | null;
+  pinned_by: string | null;
+  reactions?: MessageReactionCount[];
+  created_at: string;
+}
+
+export type MentionGroup = "channel" | "here" | "department";
+
+// ===== 富消息块 =====
+
+export type MessageBlockType = "text" | "code" | "file" | "task" | "approval" | "knowledge" | "actions";
+
+export interface MessageFile {
+  attachment_id: string;
+  filename: string;
+  file_size: number;
+  mime_type: string;
+}
+
+export interface ApprovalCard {
+  id: string;
+  request_type: ApprovalRequestType;
+  status: ApprovalStatus;
+  reason: string;
+  requester_id: string;
+  approver_id?: string;
+  decided_by?: string;
+  decided_at?: string;
+}
+
+export interface KnowledgeCard {
+  doc_id: string;
+  title: string;
+  excerpt: string;
+  tags: string[];
+}
+
+export interface MessageAction {
+  action_id: string;
+  label: string;
+  style?: "primary" | "danger";
+  value?: string;
+}
+
+// 按 type 只有对应字段非空
+export interface MessageBlock {
+  type: MessageBlockType;
+  text?: string;
+  language?: string;
+  code?: string;
+  file?: MessageFile;
+  task?: TaskMeta;
+  approval?: ApprovalCard;
+  knowledge?: KnowledgeCard;
+  actions?: MessageAction[];
+}
+
+// 审批卡片的内置操作，value 为审批请求 ID
+export const APPROVAL_ACTION_APPROVE = "approval.approve";
+export const APPROVAL_ACTION_REJECT = "approval.reject";
+
+// ===== 定时消息与提醒 =====
+
+export type ScheduledMessageKind = "message" | "reminder";
+export type ScheduledMessageStatus = "scheduled" | "sent" | "cancelled" | "failed";
+
+export interface ScheduledMessage {
+  id: string;
+  company_id: string;
+  created_by: string;
+  kind: ScheduledMessageKind;
+  channel_id?: string;
+  receiver_id?: string;
+  task_id?: string;          // 提醒关联的任务
+  content: string;
+  cron: string;              // 空表示一次性
+  timezone: string;
+  status: ScheduledMessageStatus;
+  next_run_at: string | null;
+  last_run_at: string | null;
+  last_message_id: string | null;
+  last_error: string;
+  run_count: number;
   created_at: string;
+  updated_at: string;
+}
+
+// POST /scheduled-messages；send_at 与 cron 二选一，reminder 不需要 channel / receiver_id
+export interface ScheduleMessagePayload {
+  kind?: ScheduledMessageKind;
+  channel?: string;
+  receiver_id?: string;
+  task_id?: string;
+  content: string;
+  send_at?: string;
+  cron?: string;
+  timezone?: string;
+}
+
+// ===== 每日摘要 =====
+
+export type DigestDelivery = "dm" | "webhook";
+
+// GET /digest/settings；channel_ids 为空表示所有可见频道
+export interface DigestSettings {
+  agent_id: string;
+  company_id: string;
+  enabled: boolean;
+  cron: string;
+  timezone: string;
+  delivery: DigestDelivery;  // webhook 时触发 digest.created，由外部转发邮件等
+  channel_ids: string[];
+  include_dms: boolean;
+  include_tasks: boolean;
+  include_approvals: boolean;
+  next_run_at: string | null;
+  last_run_at: string | null;
+  last_error: string;
+}
+
+// PUT /digest/settings；只修改出现的字段
+export type DigestSettingsPayload = Partial<Pick<DigestSettings,
+  "enabled" | "cron" | "timezone" | "delivery" | "channel_ids" | "include_dms" | "include_tasks" | "include_approvals">>;
+
+export interface Digest {
+  id: string;
+  company_id: string;
+  agent_id: string;
+  period_start: string;
+  period_end: string;
+  content: string;           // Markdown
+  message_count: number;
+  task_count: number;
+  approval_count: number;
+  delivery: DigestDelivery;
+  message_id: string | null; // 私信投递时的消息 ID
+  llm_error?: string;        // LLM 不可用时退化为统计摘要
+  created_at: string;
+}
+
+// GET /messages/search 返回结果；mode 为实际使用的搜索方式（语义搜索不可用时降级为 fulltext）
+export interface MessageSearchResult {
+  data: Message[];
+  mode: "fulltext" | "semantic";
+  has_more: boolean;
+}
+
+export interface MessageReactionCount {
+  emoji: string;
+  count: number;
+  agent_ids: string[];
 }
 
 export interface KnowledgeDoc {
@@ -487,3 +670,27 @@ export const SEARCH_ERROR_CODES = {
 export * from "./types/organization";
 export * from "./types/task-collaboration";
 export * from "./types/observability";
+
+// AgentStatusPeriod 一段状态区间；ended_at 为 null 表示当前状态
+export interface AgentStatusPeriod {
+  id: number;
+  company_id: string;
+  agent_id: string;
+  status: AgentStatus;
+  prev_status: AgentStatus;
+  reason: "connect" | "disconnect" | "expired" | "idle" | "active" | "manual";
+  started_at: string;
+  ended_at: string | null;
+}
+
+export interface AgentUtilization {
+  agent_id: string;
+  name: string;
+  is_human: boolean;
+  online_seconds: number;
+  busy_seconds: number;
+  idle_seconds: number;
+  offline_seconds: number;
+  availability: number; // 在线（含忙碌、空闲）占统计区间的比例
+  active_ratio: number; // 在线时长中非空闲的比例
+}
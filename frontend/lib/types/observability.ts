@@ -117,3 +117,42 @@ export interface ConversationQualityScore {
   feedback: string | null;
   created_at: string;
 }
+
+export interface AgentDeliveryMetrics {
+  enqueued: number;
+  delivered: number;
+  redelivered: number;
+  acked: number;
+  /** Pushes rejected because the connection's send buffer was full; they are redelivered later */
+  dropped: number;
+  dropped_by_agent: Record<string, number>;
+  /** Events not logged because the delivery queue was full; unread messages are backfilled */
+  overflowed: number;
+}
+
+export interface AgentDeliveryBacklog {
+  agent_id: string;
+  pending: number;
+  exhausted: number;
+  oldest_pending?: string;
+  last_seq: number;
+  acked_seq: number;
+}
+
+export interface AgentDeliveryStats {
+  metrics: AgentDeliveryMetrics;
+  backlog: AgentDeliveryBacklog[];
+}
+
+/** AI-to-AI conversation budget; key is "dm:<a>|<b>" or "ch:<channel_id>" */
+export interface ConversationStat {
+  key: string;
+  company_id: string;
+  channel_id?: string;
+  participants: string[];
+  turns: number;
+  limit: number;
+  paused_until?: string;
+  reason?: string;
+  trips: number;
+}
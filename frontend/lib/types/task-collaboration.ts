@@ -5,7 +5,19 @@ export interface TaskComment {
   task_id: string;
   company_id: string;
   agent_id: string;
+  parent_comment_id?: string;
   content: string;
+  mentions: string[];
+  edited_at?: string;
+  created_at: string;
+}
+
+export interface TaskCommentEdit {
+  id: string;
+  comment_id: string;
+  company_id: string;
+  editor_id: string;
+  old_content: string;
   created_at: string;
 }
 
@@ -23,9 +35,21 @@ export interface TaskWatcher {
   created_at: string;
 }
 
+export interface TaskEvent {
+  id: string;
+  task_id: string;
+  company_id: string;
+  actor_id: string | null;
+  field: string;
+  old_value: string;
+  new_value: string;
+  created_at: string;
+}
+
 export interface TaskDetail extends Task {
   tags: string[];
   comments: TaskComment[];
   dependencies: TaskDependency[];
   watchers: TaskWatcher[];
+  activity?: TaskEvent[];
 }
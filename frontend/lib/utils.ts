@@ -33,10 +33,12 @@ export function getStatusColor(status: string): string {
   const colors: Record<string, string> = {
     online: "bg-green-500",
     busy: "bg-yellow-500",
+    idle: "bg-green-500/40",
     offline: "bg-zinc-400",
     pending: "bg-zinc-400",
     assigned: "bg-blue-500",
     in_progress: "bg-yellow-500",
+    in_review: "bg-purple-500",
     done: "bg-green-500",
     failed: "bg-red-500",
     cancelled: "bg-zinc-400",
@@ -0,0 +1,149 @@
+import { describe, it, expect, beforeEach, vi } from 'vitest';
+
+import type { WSClientOptions, WSMessage } from './ws-client.js';
+
+vi.mock('../logger.js', () => ({
+  logger: {
+    debug: vi.fn(),
+    info: vi.fn(),
+    warn: vi.fn(),
+    error: vi.fn(),
+  },
+}));
+
+// Fake WSClient: captures the handlers and every frame sent
+const ws = vi.hoisted(() => ({
+  opts: null as WSClientOptions | null,
+  sent: [] as WSMessage[],
+}));
+
+vi.mock('./ws-client.js', () => ({
+  WSClient: class {
+    constructor(opts: WSClientOptions) {
+      ws.opts = opts;
+    }
+    connect() {}
+    disconnect() {}
+    isConnected() {
+      return true;
+    }
+    send(msg: WSMessage) {
+      ws.sent.push(msg);
+    }
+  },
+}));
+
+import { LinkClawChannel } from './linkclaw.js';
+
+const AGENT = 'agent-self';
+const PEER = 'agent-peer';
+
+function ackedSeqs(): number[] {
+  return ws.sent
+    .filter((m) => m.type === 'ack')
+    .flatMap((m) => (m.data as { seqs: number[] }).seqs);
+}
+
+function deliver(seq: number, data: Record<string, unknown>, type = 'message.new') {
+  ws.opts!.onMessage({ type, seq, data });
+}
+
+function dm(id: string, createdAt: string, extra: Record<string, unknown> = {}) {
+  return {
+    message_id: id,
+    company_id: 'c1',
+    sender_id: PEER,
+    receiver_id: AGENT,
+    msg_type: 'text',
+    content: 'hi',
+    created_at: createdAt,
+    ...extra,
+  };
+}
+
+describe('LinkClawChannel acks', () => {
+  let channel: LinkClawChannel;
+  let onMessage: ReturnType<typeof vi.fn>;
+
+  beforeEach(async () => {
+    ws.opts = null;
+    ws.sent = [];
+    vi.stubGlobal('fetch', vi.fn(async () => ({ ok: false })));
+    onMessage = vi.fn();
+    channel = new LinkClawChannel({
+      baseUrl: 'http://localhost:8080',
+      apiKey: 'key',
+      onMessage,
+      onChatMetadata: vi.fn(),
+    });
+    await channel.connect();
+    ws.opts!.onMessage({ type: 'connected', data: { agent_id: AGENT, initialized: true } });
+  });
+
+  it('does not ack a message until it is processed', () => {
+    deliver(1, dm('m1', '2026-01-01T00:00:01Z'));
+
+    expect(onMessage).toHaveBeenCalledTimes(1);
+    expect(ackedSeqs()).toEqual([]);
+
+    channel.markProcessed(`lc:dm:${PEER}`, '2026-01-01T00:00:01Z');
+    expect(ackedSeqs()).toEqual([1]);
+  });
+
+  it('acks only deliveries up to the processed timestamp', () => {
+    deliver(1, dm('m1', '2026-01-01T00:00:01Z'));
+    deliver(2, dm('m2', '2026-01-01T00:00:02Z'));
+    deliver(3, dm('m3', '2026-01-01T00:00:03Z'));
+
+    channel.markProcessed(`lc:dm:${PEER}`, '2026-01-01T00:00:02Z');
+    expect(ackedSeqs()).toEqual([1, 2]);
+
+    channel.markProcessed(`lc:dm:${PEER}`, '2026-01-01T00:00:03Z');
+    expect(ackedSeqs()).toEqual([1, 2, 3]);
+  });
+
+  it('keeps other chats pending', () => {
+    deliver(1, dm('m1', '2026-01-01T00:00:01Z'));
+    deliver(2, dm('m2', '2026-01-01T00:00:01Z', { sender_id: 'agent-other' }));
+
+    channel.markProcessed(`lc:dm:${PEER}`, '2026-01-01T00:00:05Z');
+    expect(ackedSeqs()).toEqual([1]);
+  });
+
+  it('compares timestamps across zone offsets', () => {
+    deliver(1, dm('m1', '2026-01-01T08:00:01+08:00'));
+
+    channel.markProcessed(`lc:dm:${PEER}`, '2026-01-01T00:00:00Z');
+    expect(ackedSeqs()).toEqual([]);
+
+    channel.markProcessed(`lc:dm:${PEER}`, '2026-01-01T00:00:01Z');
+    expect(ackedSeqs()).toEqual([1]);
+  });
+
+  it('acks redeliveries of already processed messages on arrival', () => {
+    deliver(1, dm('m1', '2026-01-01T00:00:01Z'));
+    channel.markProcessed(`lc:dm:${PEER}`, '2026-01-01T00:00:01Z');
+
+    deliver(4, dm('m1', '2026-01-01T00:00:01Z'));
+    expect(ackedSeqs()).toEqual([1, 4]);
+  });
+
+  it('acks skipped events immediately', () => {
+    deliver(1, dm('m1', '2026-01-01T00:00:01Z', { sender_id: AGENT }));
+    deliver(2, dm('m2', '2026-01-01T00:00:02Z', { msg_type: 'system' }));
+    deliver(3, { task_id: 't1' }, 'task.updated');
+
+    expect(onMessage).not.toHaveBeenCalled();
+    expect(ackedSeqs()).toEqual([1, 2, 3]);
+  });
+
+  it('leaves the delivery unacked when storing it fails', () => {
+    onMessage.mockImplementation(() => {
+      throw new Error('disk full');
+    });
+    deliver(1, dm('m1', '2026-01-01T00:00:01Z'));
+    channel.markProcessed(`lc:dm:${PEER}`, '2026-01-01T00:00:01Z');
+
+    expect(ackedSeqs()).toEqual([]);
+  });
+});
@@ -31,6 +31,13 @@ function parseJid(jid: string): { channel: string } | { receiverId: string } | n
   return null;
 }
 
+/** a <= b; backend timestamps and local ISO strings may differ in zone offset */
+function notAfter(a: string, b: string): boolean {
+  const ta = Date.parse(a);
+  const tb = Date.parse(b);
+  return Number.isNaN(ta) || Number.isNaN(tb) ? a <= b : ta <= tb;
+}
+
 // Backend event payloads
 interface MessageNewPayload {
   message_id: string;
@@ -41,6 +48,19 @@ interface MessageNewPayload {
   msg_type: string;
   content: string;
   created_at: string;
+  // Set when sent by a scheduled message / reminder
+  schedule_id?: string;
+}
+
+// message.action: someone clicked a button on one of our messages
+interface MessageActionPayload {
+  message_id: string;
+  company_id: string;
+  channel_id?: string;
+  receiver_id?: string;
+  actor_id: string;
+  action_id: string;
+  value?: string;
 }
 
 interface AgentInfo {
@@ -66,6 +86,11 @@ export class LinkClawChannel implements Channel {
   private agentNames = new Map<string, string>();
   private channelNames = new Map<string, string>();
 
+  // Deliveries handed to the agent but not yet processed, per JID
+  private pendingAcks = new Map<string, { seq: number; timestamp: string }[]>();
+  // Per-JID timestamp the agent has processed up to (see markProcessed)
+  private processedUpTo = new Map<string, string>();
+
   constructor(opts: LinkClawChannelOptions) {
     this.opts = opts;
   }
@@ -101,6 +126,12 @@ export class LinkClawChannel implements Channel {
       throw new Error('WS not connected');
     }
 
+    // Reminders arrive in the agent's own DM; there is nobody to reply to
+    if ('receiverId' in parsed && parsed.receiverId === this.agentId) {
+      logger.info({ jid }, 'Dropping reply to own reminder chat');
+      return;
+    }
+
     const data: Record<string, string> = { content: text };
     if ('channel' in parsed) {
       data.channel = parsed.channel;
@@ -119,6 +150,28 @@ export class LinkClawChannel implements Channel {
     return jid.startsWith('lc:');
   }
 
+  /**
+   * Ack deliveries of jid whose message timestamp is <= timestamp.
+   * Called once the agent has processed them, so a crash or failed run
+   * before that leaves them unacked and the server redelivers.
+   */
+  markProcessed(jid: string, timestamp: string): void {
+    if (!timestamp) return;
+    const prev = this.processedUpTo.get(jid);
+    if (!prev || !notAfter(timestamp, prev)) this.processedUpTo.set(jid, timestamp);
+
+    const pending = this.pendingAcks.get(jid);
+    if (!pending) return;
+    const done = pending.filter((p) => notAfter(p.timestamp, timestamp));
+    const rest = pending.filter((p) => !notAfter(p.timestamp, timestamp));
+    if (rest.length > 0) {
+      this.pendingAcks.set(jid, rest);
+    } else {
+      this.pendingAcks.delete(jid);
+    }
+    if (done.length > 0) this.ack(done.map((p) => p.seq));
+  }
+
   async disconnect(): Promise<void> {
     this.wsClient?.disconnect();
     this._connected = false;
@@ -148,23 +201,54 @@ export class LinkClawChannel implements Channel {
 
     logger.info({ eventType: type }, 'WS event received');
 
-    if (type === 'message.new') {
-      this.handleMessageNew(data as MessageNewPayload);
+    let forwarded: NewMessage | null = null;
+    try {
+      if (type === 'message.new') {
+        forwarded = this.handleMessageNew(data as MessageNewPayload);
+      } else if (type === 'message.action') {
+        forwarded = this.handleMessageAction(data as MessageActionPayload);
+      }
+      // task.created / task.updated — could be handled here in the future
+    } catch (err) {
+      // Leave the delivery unacked so the server redelivers it
+      logger.error({ err, eventType: type, seq: msg.seq }, 'Failed to handle WS event');
+      return;
+    }
+    if (!msg.seq) return;
+
+    // Messages handed to the agent are acked by markProcessed once processed;
+    // skipped events and redeliveries of already-processed messages right away.
+    if (forwarded) {
+      const done = this.processedUpTo.get(forwarded.chat_jid);
+      if (!done || !notAfter(forwarded.timestamp, done)) {
+        const pending = this.pendingAcks.get(forwarded.chat_jid) || [];
+        pending.push({ seq: msg.seq, timestamp: forwarded.timestamp });
+        this.pendingAcks.set(forwarded.chat_jid, pending);
+        return;
+      }
     }
-    // task.created / task.updated — could be handled here in the future
+    this.ack([msg.seq]);
+  }
+
+  // Ack deliveries individually: redeliveries can arrive after newer events,
+  // so a cumulative ack could confirm one we never received. The server
+  // resumes from its own acked position on reconnect.
+  private ack(seqs: number[]): void {
+    this.wsClient?.send({ type: 'ack', data: { seqs } });
   }
 
-  private handleMessageNew(p: MessageNewPayload): void {
+  /** Returns the message handed to onMessage, or null when skipped */
+  private handleMessageNew(p: MessageNewPayload): NewMessage | null {
     logger.info({ messageId: p.message_id, senderId: p.sender_id, channelId: p.channel_id, receiverId: p.receiver_id }, 'handleMessageNew received');
     // Skip own messages to avoid echo loops
     if (p.sender_id === this.agentId) {
       logger.debug({ messageId: p.message_id }, 'Skipping own message');
-      return;
+      return null;
     }
-    // Skip non-text messages
-    if (p.msg_type !== 'text') {
+    // Skip system / task_update messages; rich messages carry a plain-text summary in content
+    if (p.msg_type !== 'text' && p.msg_type !== 'rich') {
       logger.debug({ messageId: p.message_id, msgType: p.msg_type }, 'Skipping non-text message');
-      return;
+      return null;
     }
 
     // Resolve JID: channel messages use channel name, DMs use sender id
@@ -178,11 +262,16 @@ export class LinkClawChannel implements Channel {
     } else if (p.sender_id) {
       chatJid = dmJid(p.sender_id);
       isGroup = false;
+    } else if (p.schedule_id && p.receiver_id === this.agentId) {
+      // Reminder the agent scheduled for itself: wake it up in its own DM
+      chatJid = dmJid(this.agentId);
+      isGroup = false;
     } else {
-      return;
+      return null;
     }
 
-    const senderName = (p.sender_id && this.agentNames.get(p.sender_id)) || p.sender_id || 'system';
+    const senderName =
+      (p.sender_id && this.agentNames.get(p.sender_id)) || p.sender_id || (p.schedule_id ? 'reminder' : 'system');
 
     const newMsg: NewMessage = {
       id: p.message_id,
@@ -196,6 +285,40 @@ export class LinkClawChannel implements Channel {
 
     this.opts.onChatMetadata(chatJid, p.created_at, undefined, 'linkclaw', isGroup);
     this.opts.onMessage(chatJid, newMsg);
+    return newMsg;
+  }
+
+  private handleMessageAction(p: MessageActionPayload): NewMessage | null {
+    if (p.actor_id === this.agentId) return null;
+
+    // Reply where the clicked message lives: its channel, or a DM with the clicker
+    let chatJid: string;
+    let isGroup: boolean;
+    if (p.channel_id) {
+      const chName = this.channelNames.get(p.channel_id) || p.channel_id;
+      chatJid = channelJid(chName);
+      isGroup = true;
+    } else {
+      chatJid = dmJid(p.actor_id);
+      isGroup = false;
+    }
+
+    const actorName = this.agentNames.get(p.actor_id) || p.actor_id;
+    const value = p.value ? ` (value: ${p.value})` : '';
+    const now = new Date().toISOString();
+    const newMsg: NewMessage = {
+      id: `${p.message_id}:action:${p.action_id}:${now}`,
+      chat_jid: chatJid,
+      sender: p.actor_id,
+      sender_name: actorName,
+      content: `[clicked button "${p.action_id}"${value} on message ${p.message_id}]`,
+      timestamp: now,
+      is_from_me: false,
+    };
+
+    this.opts.onChatMetadata(chatJid, now, undefined, 'linkclaw', isGroup);
+    this.opts.onMessage(chatJid, newMsg);
+    return newMsg;
   }
 
   // --- API helpers ---
@@ -6,6 +6,8 @@ import { logger } from '../logger.js';
 
 export interface WSMessage {
   type: string;
+  /** Delivery sequence number for agent events; must be acked once handled */
+  seq?: number;
   data: unknown;
 }
 
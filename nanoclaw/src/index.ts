@@ -144,7 +144,11 @@ async function processGroupMessages(chatJid: string): Promise<boolean> {
   const sinceTimestamp = lastAgentTimestamp[chatJid] || '';
   const missedMessages = getMessagesSince(chatJid, sinceTimestamp, ASSISTANT_NAME);
 
-  if (missedMessages.length === 0) return true;
+  if (missedMessages.length === 0) {
+    // Redeliveries of messages already processed before a restart
+    channel.markProcessed?.(chatJid, sinceTimestamp);
+    return true;
+  }
 
   // For non-main groups, check if trigger is required and present
   if (!isMainGroup && group.requiresTrigger !== false) {
@@ -215,6 +219,7 @@ async function processGroupMessages(chatJid: string): Promise<boolean> {
     // the user got their response and re-processing would send duplicates.
     if (outputSentToUser) {
       logger.warn({ group: group.name }, 'Agent error after output was sent, skipping cursor rollback to prevent duplicates');
+      channel.markProcessed?.(chatJid, lastAgentTimestamp[chatJid]);
       return true;
     }
     // Roll back cursor so retries can re-process these messages
@@ -224,6 +229,7 @@ async function processGroupMessages(chatJid: string): Promise<boolean> {
     return false;
   }
 
+  channel.markProcessed?.(chatJid, lastAgentTimestamp[chatJid]);
   return true;
 }
 
@@ -425,6 +431,8 @@ async function startMessageLoop(): Promise<void> {
             lastAgentTimestamp[chatJid] =
               messagesToSend[messagesToSend.length - 1].timestamp;
             saveState();
+            // The active container owns these messages now
+            channel.markProcessed?.(chatJid, lastAgentTimestamp[chatJid]);
             // Show typing indicator while the container processes the piped message
             channel.setTyping?.(chatJid, true)?.catch((err) =>
               logger.warn({ chatJid, err }, 'Failed to set typing indicator'),
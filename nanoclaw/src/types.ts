@@ -87,6 +87,9 @@ export interface Channel {
   disconnect(): Promise<void>;
   // Optional: typing indicator. Channels that support it implement it.
   setTyping?(jid: string, isTyping: boolean): Promise<void>;
+  // Optional: the agent has processed inbound messages of jid up to timestamp.
+  // Channels with delivery acks confirm them here instead of on arrival.
+  markProcessed?(jid: string, timestamp: string): void;
 }
 
 // Callback type that channels use to deliver inbound messages